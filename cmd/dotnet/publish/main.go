@@ -135,6 +135,10 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating symlink: %w", err)
 	}
 
+	if err := buildMigrationsBundle(ctx, proj, pkgLayer); err != nil {
+		return err
+	}
+
 	// Infer the entrypoint in case an explicit override was not provided.
 	entrypoint := os.Getenv(env.Entrypoint)
 	if entrypoint != "" {
@@ -161,6 +165,50 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// buildMigrationsBundle generates an EF Core migrations bundle if enabled with
+// dotnet.EFMigrationsBundleEnv. The bundle is a standalone executable which applies the migrations
+// without the SDK, and is added as a separate process type so it can run as a release step.
+func buildMigrationsBundle(ctx *gcp.Context, proj string, pkgLayer *libcnb.Layer) error {
+	enabled, err := env.IsPresentAndTrue(dotnet.EFMigrationsBundleEnv)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if !enabled {
+		return nil
+	}
+	p, err := dotnet.ReadProjectFile(ctx, proj)
+	if err != nil {
+		return fmt.Errorf("reading project file: %w", err)
+	}
+	version := dotnet.EFCoreDesignVersion(p)
+	if version == "" {
+		return gcp.UserErrorf("%s is set but %s does not reference Microsoft.EntityFrameworkCore.Design, which is required to generate a migrations bundle", dotnet.EFMigrationsBundleEnv, proj)
+	}
+
+	toolLayer, err := ctx.Layer("dotnet-ef", gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	tool, err := dotnet.InstallEFTool(ctx, toolLayer, version)
+	if err != nil {
+		return fmt.Errorf("installing dotnet-ef: %w", err)
+	}
+
+	bundleLayer, err := ctx.Layer("efbundle", gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	bundle := filepath.Join(bundleLayer.Path, "efbundle")
+	ctx.Logf("Generating EF Core migrations bundle.")
+	cmd := []string{tool, "migrations", "bundle", "--project", proj, "--configuration", "Release", "--output", bundle, "--force"}
+	// The bundle is built from the project again, NUGET_PACKAGES lets it reuse the restored packages.
+	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true", "NUGET_PACKAGES="+pkgLayer.Path), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	ctx.AddProcess(dotnet.EFMigrationsProcess, []string{bundle}, gcp.AsDirectProcess())
+	return nil
+}
+
 // getEntrypoint retrieves the appropriate entrypoint for this build.
 // * Check the output directory for a binary or a library with the same name as the project file (e.g. app.csproj --> app or app.dll).
 // * If not found, parse the project file for an AssemblyName field and check for the associated binary or library file in the output directory.
@@ -23,6 +23,7 @@ import (
 	"text/template"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -338,3 +339,46 @@ func TestDeleteFolder(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildMigrationsBundle(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		wantErr bool
+	}{
+		{
+			name: "not enabled",
+		},
+		{
+			name: "disabled",
+			env:  "false",
+		},
+		{
+			name:    "enabled without design package",
+			env:     "true",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			env:     "yes please",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			proj := filepath.Join(dir, "app.csproj")
+			if err := os.WriteFile(proj, []byte(`<Project Sdk="Microsoft.NET.Sdk.Web"></Project>`), 0644); err != nil {
+				t.Fatalf("writing %s: %v", proj, err)
+			}
+			if tc.env != "" {
+				t.Setenv(dotnet.EFMigrationsBundleEnv, tc.env)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			err := buildMigrationsBundle(ctx, proj, nil)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("buildMigrationsBundle() got error: %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements elixir/release buildpack.
+// The release buildpack compiles the application and its dependencies and assembles a Mix release.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/elixir"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	depsLayer    = "deps"
+	buildLayer   = "build"
+	releaseLayer = "release"
+
+	// mixEnvEnv is the env var selecting the Mix environment.
+	mixEnvEnv     = "MIX_ENV"
+	defaultMixEnv = "prod"
+
+	// depsCacheKey is the metadata key used to store the hash the deps and build layers are keyed on.
+	depsCacheKey = "deps-sha"
+
+	runtimeConfig = "config/runtime.exs"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	mixExists, err := ctx.FileExists("mix.exs")
+	if err != nil {
+		return nil, err
+	}
+	if !mixExists {
+		return gcp.OptOutFileNotFound("mix.exs"), nil
+	}
+	return gcp.OptInFileFound("mix.exs"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	mixEnv := os.Getenv(mixEnvEnv)
+	if mixEnv == "" {
+		mixEnv = defaultMixEnv
+		if err := ctx.Setenv(mixEnvEnv, mixEnv); err != nil {
+			return err
+		}
+	}
+
+	dl, err := ctx.Layer(depsLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", depsLayer, err)
+	}
+	bl, err := ctx.Layer(buildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", buildLayer, err)
+	}
+	if err := checkDepsCache(ctx, mixEnv, dl, bl); err != nil {
+		return err
+	}
+	// Mix reads the location of deps and _build from these env vars, which keeps them out of the
+	// application directory so they are cached between builds.
+	if err := ctx.Setenv("MIX_DEPS_PATH", dl.Path); err != nil {
+		return err
+	}
+	if err := ctx.Setenv("MIX_BUILD_ROOT", bl.Path); err != nil {
+		return err
+	}
+
+	if _, err := ctx.Exec([]string{"mix", "deps.get", "--only", mixEnv}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"mix", "compile"}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	hasAssets, err := hasAssetsDeploy(ctx)
+	if err != nil {
+		return err
+	}
+	if hasAssets {
+		if _, err := ctx.Exec([]string{"mix", "assets.deploy"}, gcp.WithUserAttribution); err != nil {
+			return err
+		}
+	}
+
+	rl, err := ctx.Layer(releaseLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", releaseLayer, err)
+	}
+	if err := ctx.ClearLayer(rl); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", releaseLayer, err)
+	}
+	if _, err := ctx.Exec([]string{"mix", "release", "--overwrite", "--path", rl.Path}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+
+	runtimeConfigExists, err := ctx.FileExists(ctx.ApplicationRoot(), runtimeConfig)
+	if err != nil {
+		return err
+	}
+	if runtimeConfigExists {
+		// Phoenix only starts the endpoint in a release when PHX_SERVER is set, see the
+		// config/runtime.exs generated by `mix phx.new`.
+		rl.LaunchEnvironment.Default("PHX_SERVER", "true")
+	} else {
+		ctx.Warnf("%s not found, environment variables such as PORT and DATABASE_URL are only read at build time.", runtimeConfig)
+	}
+	// The release writes its runtime files next to the release by default, which is read-only.
+	rl.LaunchEnvironment.Default("RELEASE_TMP", "/tmp")
+
+	app, err := elixir.AppName(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.AddProcess(gcp.WebProcess, []string{filepath.Join(rl.Path, "bin", app), "start"}, gcp.AsDirectProcess(), gcp.AsDefaultProcess())
+	return nil
+}
+
+// checkDepsCache clears the deps and build layers if mix.lock, the Mix environment or the Erlang
+// and Elixir versions changed since they were populated.
+func checkDepsCache(ctx *gcp.Context, mixEnv string, dl, bl *libcnb.Layer) error {
+	versions, err := elixir.DetectVersions(ctx)
+	if err != nil {
+		return err
+	}
+	opts := []cache.Option{cache.WithStrings(mixEnv, versions.Erlang, versions.Elixir, versions.ElixirOTP)}
+	lock := filepath.Join(ctx.ApplicationRoot(), "mix.lock")
+	lockExists, err := ctx.FileExists(lock)
+	if err != nil {
+		return err
+	}
+	if lockExists {
+		opts = append(opts, cache.WithFiles(lock))
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, dl, depsCacheKey, opts...)
+	if err != nil {
+		return err
+	}
+	if cached {
+		ctx.CacheHit(depsLayer)
+		return nil
+	}
+	ctx.CacheMiss(depsLayer)
+	for _, l := range []*libcnb.Layer{dl, bl} {
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+	}
+	cache.Add(ctx, dl, depsCacheKey, hash)
+	return nil
+}
+
+// hasAssetsDeploy returns true if mix.exs defines the assets.deploy alias generated by Phoenix.
+func hasAssetsDeploy(ctx *gcp.Context) (bool, error) {
+	content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), "mix.exs"))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Contains(content, []byte(`"assets.deploy"`)), nil
+}
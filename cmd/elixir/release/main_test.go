@@ -0,0 +1,137 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+const mixExs = `defmodule Hello.MixProject do
+  use Mix.Project
+
+  def project do
+    [
+      app: :hello,
+      version: "0.1.0",
+      aliases: aliases()
+    ]
+  end
+%s
+end
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "mix.exs",
+			files: map[string]string{
+				"mix.exs": "",
+			},
+			want: 0,
+		},
+		{
+			name: "no mix.exs",
+			files: map[string]string{
+				"main.ex": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name              string
+		files             map[string]string
+		envs              []string
+		wantCommands      []string
+		doNotWantCommands []string
+	}{
+		{
+			name: "release",
+			files: map[string]string{
+				"mix.exs":            fmt.Sprintf(mixExs, ""),
+				"config/runtime.exs": "",
+			},
+			wantCommands: []string{
+				"mix deps.get --only prod",
+				"mix compile",
+				"mix release --overwrite --path",
+			},
+			doNotWantCommands: []string{
+				"mix assets.deploy",
+			},
+		},
+		{
+			name: "phoenix assets",
+			files: map[string]string{
+				"mix.exs": fmt.Sprintf(mixExs, `
+  defp aliases do
+    ["assets.deploy": ["esbuild default --minify", "phx.digest"]]
+  end`),
+			},
+			wantCommands: []string{
+				"mix assets.deploy",
+			},
+		},
+		{
+			name: "MIX_ENV set",
+			files: map[string]string{
+				"mix.exs": fmt.Sprintf(mixExs, ""),
+			},
+			envs: []string{"MIX_ENV=staging"},
+			wantCommands: []string{
+				"mix deps.get --only staging",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithEnvs(tc.envs...),
+				bpt.WithExecMocks(mockprocess.New(`^mix`)),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements elixir/runtime buildpack.
+// The runtime buildpack installs Erlang/OTP, Elixir, Hex and rebar.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/elixir"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+)
+
+const (
+	erlangLayer = "erlang"
+	elixirLayer = "elixir"
+	mixLayer    = "mix"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if result := runtime.CheckOverride("elixir"); result != nil {
+		return result, nil
+	}
+	mixExists, err := ctx.FileExists("mix.exs")
+	if err != nil {
+		return nil, err
+	}
+	if !mixExists {
+		return gcp.OptOutFileNotFound("mix.exs"), nil
+	}
+	return gcp.OptInFileFound("mix.exs"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	versions, err := elixir.DetectVersions(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Using Erlang/OTP %s and Elixir %s", versions.Erlang, versions.Elixir)
+
+	// Releases include the Erlang runtime system, so Erlang and Elixir are only required at build
+	// time and are not included in the run image.
+	erl, err := ctx.Layer(erlangLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", erlangLayer, err)
+	}
+	if err := elixir.InstallErlang(ctx, erl, versions.Erlang); err != nil {
+		return err
+	}
+	exl, err := ctx.Layer(elixirLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", elixirLayer, err)
+	}
+	if err := elixir.InstallElixir(ctx, exl, versions.Elixir, versions.ElixirOTP); err != nil {
+		return err
+	}
+	path := filepath.Join(exl.Path, "bin") + string(os.PathListSeparator) + filepath.Join(erl.Path, "bin") + string(os.PathListSeparator) + os.Getenv("PATH")
+	if err := ctx.Setenv("PATH", path); err != nil {
+		return err
+	}
+
+	// Hex and rebar are installed in MIX_HOME, they are reinstalled when Elixir changes.
+	ml, err := ctx.Layer(mixLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", mixLayer, err)
+	}
+	ml.BuildEnvironment.Override("MIX_HOME", ml.Path)
+	ml.BuildEnvironment.Override("HEX_HOME", filepath.Join(ml.Path, "hex"))
+	if err := ctx.Setenv("MIX_HOME", ml.Path); err != nil {
+		return err
+	}
+	if err := ctx.Setenv("HEX_HOME", filepath.Join(ml.Path, "hex")); err != nil {
+		return err
+	}
+	if ctx.GetMetadata(ml, "version") == versions.Elixir {
+		ctx.CacheHit(mixLayer)
+		return nil
+	}
+	ctx.CacheMiss(mixLayer)
+	if err := ctx.ClearLayer(ml); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", mixLayer, err)
+	}
+	for _, tool := range []string{"local.hex", "local.rebar"} {
+		if _, err := ctx.Exec([]string{"mix", tool, "--force"}); err != nil {
+			return err
+		}
+	}
+	ctx.SetMetadata(ml, "version", versions.Elixir)
+	return nil
+}
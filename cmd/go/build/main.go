@@ -50,14 +50,34 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 
 func buildFn(ctx *gcp.Context) error {
 	// Keep GOCACHE in Devmode for faster rebuilds.
-	cl, err := ctx.Layer("gocache", gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	cl, err := ctx.Layer("gocache", gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
+	if err := golang.CheckBuildCache(ctx, cl); err != nil {
+		return fmt.Errorf("checking GOCACHE layer: %w", err)
+	}
 	if devmode.Enabled(ctx) {
 		cl.LaunchEnvironment.Override("GOCACHE", cl.Path)
 	}
 
+	mode, err := golang.ResolveBuildMode(ctx)
+	if err != nil {
+		return err
+	}
+	buildEnv := append([]string{"GOCACHE=" + cl.Path}, mode.Env()...)
+	if mode.NeedsCCompiler() {
+		tl, err := ctx.Layer("cc", gcp.BuildLayer, gcp.CacheLayer)
+		if err != nil {
+			return fmt.Errorf("creating layer: %w", err)
+		}
+		ccEnv, err := golang.InstallCCToolchain(ctx, tl)
+		if err != nil {
+			return err
+		}
+		buildEnv = append(buildEnv, ccEnv...)
+	}
+
 	// Create a layer for the compiled binary.  Add it to PATH in case
 	// users wish to invoke the binary manually.
 	bl, err := ctx.Layer("bin", gcp.LaunchLayer)
@@ -74,7 +94,7 @@ func buildFn(ctx *gcp.Context) error {
 
 	// Build the application.
 	bld := []string{"go", "build"}
-	bld = append(bld, goBuildFlags()...)
+	bld = append(bld, goBuildFlags(mode)...)
 	bld = append(bld, "-o", outBin)
 	bld = append(bld, buildable)
 	// BuildDirEnv should only be set by App Engine buildpacks.
@@ -82,7 +102,7 @@ func buildFn(ctx *gcp.Context) error {
 	if workdir == "" {
 		workdir = ctx.ApplicationRoot()
 	}
-	if _, err := ctx.Exec(bld, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(bld, gcp.WithEnv(buildEnv...), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution); err != nil {
 		return err
 	}
 
@@ -151,13 +171,24 @@ func searchBuildables(ctx *gcp.Context) ([]string, error) {
 	return buildables, nil
 }
 
-func goBuildFlags() []string {
+func goBuildFlags(mode golang.BuildMode) []string {
 	var flags []string
 	if v := os.Getenv(env.GoGCFlags); v != "" {
 		flags = append(flags, "-gcflags", v)
 	}
+	var ldflags []string
+	if mode.Static {
+		flags = append(flags, "-tags", "netgo,osusergo")
+		if mode.NeedsCCompiler() {
+			// cgo code must be linked statically by the external linker.
+			ldflags = append(ldflags, "-linkmode=external", "-extldflags=-static")
+		}
+	}
 	if v := os.Getenv(env.GoLDFlags); v != "" {
-		flags = append(flags, "-ldflags", v)
+		ldflags = append(ldflags, v)
+	}
+	if len(ldflags) > 0 {
+		flags = append(flags, "-ldflags", strings.Join(ldflags, " "))
 	}
 	return flags
 }
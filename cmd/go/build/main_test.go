@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 )
 
 func TestDetect(t *testing.T) {
@@ -54,9 +55,11 @@ func TestGoBuildFlags(t *testing.T) {
 	t.Cleanup(func() {
 		clearAndSetEnv(oldEnv)
 	})
+	cgo := true
 	testCases := []struct {
 		name     string
 		env      []string
+		mode     golang.BuildMode
 		expected []string
 	}{
 		{
@@ -78,13 +81,24 @@ func TestGoBuildFlags(t *testing.T) {
 			env:      []string{"GOOGLE_GOGCFLAGS=gcflags1 gcflags2", "GOOGLE_GOLDFLAGS=ldflags1 ldflags2"},
 			expected: []string{"-gcflags", "gcflags1 gcflags2", "-ldflags", "ldflags1 ldflags2"},
 		},
+		{
+			name:     "static without cgo",
+			mode:     golang.BuildMode{Static: true},
+			expected: []string{"-tags", "netgo,osusergo"},
+		},
+		{
+			name:     "static with cgo and GOOGLE_GOLDFLAGS",
+			env:      []string{"GOOGLE_GOLDFLAGS=-s -w"},
+			mode:     golang.BuildMode{Static: true, CGOEnabled: &cgo},
+			expected: []string{"-tags", "netgo,osusergo", "-ldflags", "-linkmode=external -extldflags=-static -s -w"},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			clearAndSetEnv(tc.env)
-			result := goBuildFlags()
+			result := goBuildFlags(tc.mode)
 			if !reflect.DeepEqual(tc.expected, result) {
-				t.Errorf("goBuildFlags() = %v, want %v", result, tc.expected)
+				t.Errorf("goBuildFlags(%+v) = %v, want %v", tc.mode, result, tc.expected)
 			}
 		})
 	}
@@ -18,6 +18,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
@@ -27,6 +30,9 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
 
+// springBootExplodedDir is the directory next to a layered Spring Boot jar that it is extracted to.
+const springBootExplodedDir = "spring-boot-app"
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -48,6 +54,15 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	command := []string{"java", "-jar", executable}
+	if !devmode.Enabled(ctx) {
+		layeredCommand, err := springBootLayeredCommand(ctx, executable)
+		if err != nil {
+			return err
+		}
+		if layeredCommand != nil {
+			command = layeredCommand
+		}
+	}
 
 	// Configure the entrypoint and metadata for dev mode.
 	if devmode.Enabled(ctx) {
@@ -68,6 +83,53 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// springBootLayeredCommand explodes a layered Spring Boot jar next to the jar and adds an
+// application slice for each of its layers, so that dependencies, the loader and application
+// classes are exported in separate image layers. It returns the command which starts the exploded
+// application, or nil if the jar is not a layered Spring Boot jar.
+func springBootLayeredCommand(ctx *gcp.Context, jar string) ([]string, error) {
+	if v := os.Getenv(java.SpringBootLayersEnv); v != "" {
+		if enabled, err := strconv.ParseBool(v); err != nil {
+			return nil, gcp.UserErrorf("parsing %s: %v", java.SpringBootLayersEnv, err)
+		} else if !enabled {
+			return nil, nil
+		}
+	}
+	layers, err := java.SpringBootLayers(jar)
+	if err != nil || len(layers) == 0 {
+		return nil, err
+	}
+	launcher, err := java.MainManifestEntry(jar)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(filepath.Dir(jar), springBootExplodedDir)
+	if err := ctx.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	ctx.Logf("Extracting layered Spring Boot jar %s to %s", jar, dir)
+	if err := java.ExplodeJar(jar, dir); err != nil {
+		return nil, fmt.Errorf("extracting %s: %w", jar, err)
+	}
+	rel, err := filepath.Rel(ctx.ApplicationRoot(), dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range layers {
+		if len(l.Paths) == 0 {
+			continue
+		}
+		var paths []string
+		for _, p := range l.Paths {
+			paths = append(paths, filepath.Join(rel, strings.TrimSuffix(p, "/")))
+		}
+		ctx.Debugf("Adding slice for Spring Boot layer %q: %v", l.Name, paths)
+		ctx.AddSlice(paths...)
+	}
+	return []string{"java", "-cp", dir, launcher}, nil
+}
+
 func getEntrypoint(ctx *gcp.Context) string {
 	if entrypoint := os.Getenv(env.Entrypoint); entrypoint != "" {
 		return entrypoint
@@ -15,12 +15,105 @@
 package main
 
 import (
+	"archive/zip"
+	"os"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
 	// The buildpack always opts in.
 	buildpacktest.TestDetect(t, detectFn, "no files", map[string]string{}, []string{}, 0)
 }
+
+func TestSpringBootLayeredCommand(t *testing.T) {
+	testCases := []struct {
+		name       string
+		env        map[string]string
+		files      map[string]string
+		wantCmd    bool
+		wantSlices []libcnb.Slice
+	}{
+		{
+			name:  "not a layered jar",
+			files: map[string]string{"META-INF/MANIFEST.MF": "Main-Class: com.example.Main\n"},
+		},
+		{
+			name: "layered jar",
+			files: map[string]string{
+				"META-INF/MANIFEST.MF": "Main-Class: org.springframework.boot.loader.launch.JarLauncher\n",
+				"BOOT-INF/layers.idx":  "- \"dependencies\":\n  - \"BOOT-INF/lib/\"\n- \"snapshot-dependencies\":\n- \"application\":\n  - \"BOOT-INF/classes/\"\n  - \"META-INF/\"\n",
+				"BOOT-INF/lib/dep.jar": "dep",
+			},
+			wantCmd: true,
+			wantSlices: []libcnb.Slice{
+				{Paths: []string{"target/spring-boot-app/BOOT-INF/lib"}},
+				{Paths: []string{"target/spring-boot-app/BOOT-INF/classes", "target/spring-boot-app/META-INF"}},
+			},
+		},
+		{
+			name: "layered jar disabled",
+			env:  map[string]string{"GOOGLE_JAVA_SPRING_BOOT_LAYERS": "false"},
+			files: map[string]string{
+				"META-INF/MANIFEST.MF": "Main-Class: org.springframework.boot.loader.launch.JarLauncher\n",
+				"BOOT-INF/layers.idx":  "- \"application\":\n  - \"META-INF/\"\n",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			appDir := t.TempDir()
+			jar := filepath.Join(appDir, "target", "app.jar")
+			writeJar(t, jar, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir))
+
+			got, err := springBootLayeredCommand(ctx, jar)
+			if err != nil {
+				t.Fatalf("springBootLayeredCommand() got error: %v", err)
+			}
+			var want []string
+			if tc.wantCmd {
+				want = []string{"java", "-cp", filepath.Join(appDir, "target", "spring-boot-app"), "org.springframework.boot.loader.launch.JarLauncher"}
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("springBootLayeredCommand() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantSlices, ctx.Slices()); diff != "" {
+				t.Errorf("springBootLayeredCommand() slices mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func writeJar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating %s: %v", path, err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		zf, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("creating zip entry %s: %v", name, err)
+		}
+		if _, err := zf.Write([]byte(content)); err != nil {
+			t.Fatalf("writing zip entry %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing zip writer: %v", err)
+	}
+}
@@ -18,21 +18,21 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 )
 
 const (
-	// TODO(mpeddada): Upgrade the GraalVM version. The version has currently been
-	// downgraded from 21.1.0 as building a native image for a standard GCF
-	// workflow by calling `native-image -cp ...` was resulting in a parsing error.
-	graalvmVersion = "21.0.0"
-	graalvmURL     = "https://github.com/graalvm/graalvm-ce-builds/releases/download/vm-%[1]s/graalvm-ce-java11-linux-amd64-%[1]s.tar.gz"
-	layerName      = "java-graalvm"
-	versionKey     = "version"
+	// graalvmTool is the name of the GraalVM Community Edition release in the tools manifest. Since
+	// GraalVM for JDK 17, the native-image tool is included in the release.
+	graalvmTool = "graalvm"
+	layerName   = "java-graalvm"
+	versionKey  = "version"
 )
 
 var (
@@ -72,8 +72,12 @@ func installGraalVM(ctx *gcp.Context) error {
 		return fmt.Errorf("creating %v layer: %w", graalLayer, err)
 	}
 
+	tool, err := fetch.PinnedTool(graalvmTool)
+	if err != nil {
+		return err
+	}
 	metaVersion := ctx.GetMetadata(graalLayer, versionKey)
-	if graalvmVersion == metaVersion {
+	if tool.Version == metaVersion {
 		ctx.CacheHit(layerName)
 		ctx.Logf("GraalVM cache hit, skipping installation.")
 		return nil
@@ -85,21 +89,17 @@ func installGraalVM(ctx *gcp.Context) error {
 	}
 
 	// Install graalvm into layer.
-	archiveURL := fmt.Sprintf(graalvmURL, graalvmVersion)
-	command := fmt.Sprintf(
-		"curl --fail --show-error --silent --location %s "+
-			"| tar xz --directory %s --strip-components=1", archiveURL, graalLayer.Path)
-	if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
+	archive := filepath.Join(graalLayer.Path, "graalvm.tar.gz")
+	if err := fetch.VerifiedFile(tool, archive); err != nil {
 		return err
 	}
-
-	// Install native-image component
-	graalUpdater := filepath.Join(graalLayer.Path, "bin", "gu")
-	_, err = ctx.Exec([]string{graalUpdater, "install", "native-image"}, gcp.WithUserAttribution)
-	if err != nil {
+	if _, err := ctx.Exec([]string{"tar", "xzf", archive, "--directory", graalLayer.Path, "--strip-components=1"}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	if err := os.Remove(archive); err != nil {
 		return err
 	}
 
-	ctx.SetMetadata(graalLayer, versionKey, graalvmVersion)
+	ctx.SetMetadata(graalLayer, versionKey, tool.Version)
 	return nil
 }
@@ -87,6 +87,16 @@ func buildFn(ctx *gcp.Context) error {
 
 	command := []string{gradle, "clean", "assemble", "-x", "test", "--build-cache"}
 
+	// Kotlin/JS projects are run with Node.js, so build the Node.js executable instead of the jars.
+	jsTask, err := java.KotlinJSTask(ctx)
+	if err != nil {
+		return err
+	}
+	if jsTask != "" {
+		ctx.Logf("Building Kotlin/JS Node.js executable with task %s", jsTask)
+		command = []string{gradle, "clean", jsTask, "--build-cache"}
+	}
+
 	if buildArgs := os.Getenv(env.BuildArgs); buildArgs != "" {
 		if strings.Contains(buildArgs, "project-cache-dir") {
 			ctx.Warnf("Detected project-cache-dir property set in GOOGLE_BUILD_ARGS. Dependency caching may not work properly.")
@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements java/kotlin-js buildpack.
+// The kotlin-js buildpack configures Kotlin/JS and Kotlin Multiplatform applications, which the
+// gradle buildpack builds into a Node.js executable, to run with Node.js.
+package main
+
+import (
+	"fmt"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	task, err := java.KotlinJSTask(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if task == "" {
+		return gcp.OptOut("Gradle build does not produce a Kotlin/JS Node.js executable"), nil
+	}
+	return gcp.OptIn(fmt.Sprintf("found Kotlin/JS Node.js executable built by Gradle task %s", task)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	main, err := java.KotlinJSMain(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Running Kotlin/JS executable %s with Node.js", main)
+	ctx.AddWebProcess([]string{"node", main})
+	return nil
+}
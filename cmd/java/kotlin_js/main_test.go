@@ -0,0 +1,100 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+const multiplatformBuild = `plugins {
+    kotlin("multiplatform") version "2.0.0"
+}
+
+kotlin {
+    js {
+        nodejs()
+        binaries.executable()
+    }
+}`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "kotlin js node executable",
+			files: map[string]string{
+				"build.gradle.kts": multiplatformBuild,
+			},
+			want: 0,
+		},
+		{
+			name: "kotlin jvm",
+			files: map[string]string{
+				"build.gradle.kts": `plugins { kotlin("jvm") version "2.0.0" }`,
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantExitCode int
+	}{
+		{
+			name: "built executable",
+			files: map[string]string{
+				"build.gradle.kts":                      multiplatformBuild,
+				"build/js/packages/app/package.json":    `{"name": "app", "main": "kotlin/app.js"}`,
+				"build/js/packages/app/kotlin/app.js":   "",
+				"build/js/node_modules/ws/package.json": `{"name": "ws"}`,
+			},
+		},
+		{
+			name: "not built",
+			files: map[string]string{
+				"build.gradle.kts": multiplatformBuild,
+			},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+		})
+	}
+}
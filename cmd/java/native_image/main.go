@@ -19,9 +19,11 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
@@ -30,11 +32,21 @@ import (
 
 const (
 	invokerMain = "com.google.cloud.functions.invoker.runner.Invoker"
+
+	// nativeImageLayer is the name of the layer the native executable is stored in.
+	nativeImageLayer = "native-image"
+	// nativeImageInputsKey is the metadata key used to store the hash of the native-image inputs.
+	nativeImageInputsKey = "inputs-sha"
+	// m2Layer is the name of the layer the local Maven repository is cached in.
+	m2Layer = "m2"
 )
 
 var (
 	requiresGraalvm = []libcnb.BuildPlanRequire{{Name: "graalvm"}}
 	planRequires    = libcnb.BuildPlan{Requires: requiresGraalvm}
+
+	// lookPath is stubbed in tests.
+	lookPath = exec.LookPath
 )
 
 func main() {
@@ -46,6 +58,9 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	if err := useGraalVM(ctx); err != nil {
+		return err
+	}
 	entrypoint, err := createImage(ctx)
 	if err != nil {
 		return err
@@ -70,8 +85,12 @@ func createImage(ctx *gcp.Context) ([]string, error) {
 		return buildFunctionsFramework(ctx, functionTarget, pom)
 	}
 
+	if args, ok := findFrameworkNativeBuild(ctx, pom); ok {
+		return buildMaven(ctx, args)
+	}
+
 	if buildProfile, ok := findNativeBuildProfile(ctx, pom); ok {
-		return buildMaven(ctx, buildProfile)
+		return buildMaven(ctx, []string{"package", "-P" + buildProfile})
 	}
 
 	// The presence of the `spring-boot-maven-plugin` may not always guarantee that
@@ -96,11 +115,32 @@ func buildDefault(ctx *gcp.Context) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("finding executable jar: %w", err)
 	}
-	return buildCommandLine(ctx, []string{"-jar", jar})
+	return buildCommandLine(ctx, []string{"-jar", jar}, []string{jar})
 }
 
 // buildCommandLine runs the native-image build via command line and returns the image entrypoint.
-func buildCommandLine(ctx *gcp.Context, buildArgs []string) ([]string, error) {
+// The native executable is cached and the build is skipped if the given input files, the build
+// arguments and the GraalVM release are unchanged since the previous build.
+func buildCommandLine(ctx *gcp.Context, buildArgs, inputs []string) ([]string, error) {
+	nativeLayer, err := ctx.Layer(nativeImageLayer, gcp.LaunchLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating layer: %w", err)
+	}
+	finalImage := filepath.Join(nativeLayer.Path, "bin", "native-app")
+
+	userArgs := os.Getenv(env.NativeImageBuildArgs)
+	hash, cached, err := nativeImageCached(ctx, nativeLayer, finalImage, append([]string{userArgs}, buildArgs...), inputs)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		ctx.Logf("Native image inputs are unchanged, reusing the native executable from the cache.")
+		return []string{finalImage}, nil
+	}
+	if err := ctx.ClearLayer(nativeLayer); err != nil {
+		return nil, fmt.Errorf("clearing layer %q: %w", nativeLayer.Name, err)
+	}
+
 	niDir, err := ctx.TempDir("native-image")
 	if err != nil {
 		return nil, err
@@ -109,41 +149,68 @@ func buildCommandLine(ctx *gcp.Context, buildArgs []string) ([]string, error) {
 
 	// Use a temporary image path because this command may generate extra files
 	// (*.o and *.build_artifacts.txt) alongside the binary in the temp dir.
-	userArgs := os.Getenv(env.NativeImageBuildArgs)
-	command := fmt.Sprintf("native-image --no-fallback --no-server -H:+StaticExecutableWithDynamicLibC %s %s %s",
+	command := fmt.Sprintf("native-image --no-fallback --static-nolibc %s %s -o %s",
 		userArgs, strings.Join(buildArgs, " "), tempImagePath)
 
 	if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
 		return nil, err
 	}
 
-	nativeLayer, err := ctx.Layer("native-image", gcp.LaunchLayer)
-	if err != nil {
-		return nil, fmt.Errorf("creating layer: %w", err)
-	}
-	finalImage := filepath.Join(nativeLayer.Path, "bin", "native-app")
-
-	if err := ctx.MkdirAll(finalImage, 0755); err != nil {
+	if err := ctx.MkdirAll(filepath.Dir(finalImage), 0755); err != nil {
 		return nil, err
 	}
 	if err := ctx.Rename(tempImagePath, finalImage); err != nil {
 		return nil, err
 	}
+	cache.Add(ctx, nativeLayer, nativeImageInputsKey, hash)
 
 	return []string{finalImage}, nil
 }
 
-// buildMaven runs the Maven native-image build and returns the image entrypoint.
-func buildMaven(ctx *gcp.Context, buildProfile string) ([]string, error) {
-	mvn, err := java.MvnCmd(ctx)
+// nativeImageCached returns the hash of the native-image inputs and whether the native executable
+// in the layer was built from the same inputs.
+func nativeImageCached(ctx *gcp.Context, l *libcnb.Layer, image string, args, inputs []string) (string, bool, error) {
+	opts := []cache.Option{cache.WithStrings(args...), cache.WithFiles(inputs...)}
+	// The release file of GraalVM records its version, so the executable is rebuilt on upgrades.
+	if graalvmHome := os.Getenv("GRAALVM_HOME"); graalvmHome != "" {
+		release := filepath.Join(graalvmHome, "release")
+		exists, err := ctx.FileExists(release)
+		if err != nil {
+			return "", false, err
+		}
+		if exists {
+			opts = append(opts, cache.WithFiles(release))
+		}
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, nativeImageInputsKey, opts...)
 	if err != nil {
-		return nil, err
+		return "", false, err
+	}
+	if !cached {
+		ctx.CacheMiss(l.Name)
+		return hash, false, nil
+	}
+	exists, err := ctx.FileExists(image)
+	if err != nil {
+		return "", false, err
+	}
+	if !exists {
+		ctx.CacheMiss(l.Name)
+		return hash, false, nil
 	}
-	command := []string{mvn, "package", "-DskipTests", "--batch-mode", "-Dhttp.keepAlive=false"}
+	ctx.CacheHit(l.Name)
+	return hash, true, nil
+}
 
-	if buildProfile != "" {
-		command = append(command, "-P"+buildProfile)
+// buildMaven runs the Maven native-image build with the given goals and arguments and returns the
+// image entrypoint.
+func buildMaven(ctx *gcp.Context, args []string) ([]string, error) {
+	mvn, err := java.MvnCmd(ctx)
+	if err != nil {
+		return nil, err
 	}
+	command := append([]string{mvn}, args...)
+	command = append(command, "-DskipTests", "--batch-mode", "-Dhttp.keepAlive=false")
 
 	if _, err := ctx.Exec(command, gcp.WithUserAttribution); err != nil {
 		return nil, err
@@ -156,6 +223,49 @@ func buildMaven(ctx *gcp.Context, buildProfile string) ([]string, error) {
 	return []string{imagePath}, nil
 }
 
+// useGraalVM sets JAVA_HOME and GRAALVM_HOME to the GraalVM installed by the graalvm buildpack,
+// which framework build plugins such as Quarkus use to locate native-image. They are only set for
+// the native image build, the other buildpacks keep the JDK of the Java runtime.
+func useGraalVM(ctx *gcp.Context) error {
+	bin, err := lookPath("native-image")
+	if err != nil {
+		return gcp.InternalErrorf("locating native-image installed by the graalvm buildpack: %w", err)
+	}
+	bin, err = filepath.EvalSymlinks(bin)
+	if err != nil {
+		return gcp.InternalErrorf("resolving %s: %w", bin, err)
+	}
+	home := filepath.Dir(filepath.Dir(bin))
+	for _, name := range []string{"JAVA_HOME", "GRAALVM_HOME"} {
+		if err := ctx.Setenv(name, home); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useMavenCache links ~/.m2 to a cached m2 layer, so that the Maven plugins and dependencies of
+// the native image build are not downloaded on every build. The local repository the maven
+// buildpack linked to ~/.m2 is reused if it ran before.
+func useMavenCache(ctx *gcp.Context) error {
+	homeM2 := filepath.Join(ctx.HomeDir(), ".m2")
+	if target, err := os.Readlink(homeM2); err == nil {
+		ctx.Debugf("Using the Maven repository cached in %s", target)
+		return nil
+	}
+	m2CachedRepo, err := ctx.Layer(m2Layer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", m2Layer, err)
+	}
+	if err := java.CheckCacheExpiration(ctx, m2CachedRepo); err != nil {
+		return fmt.Errorf("validating the cache: %w", err)
+	}
+	if err := ctx.RemoveAll(homeM2); err != nil {
+		return err
+	}
+	return ctx.Symlink(m2CachedRepo.Path, homeM2)
+}
+
 // parsePomFile returns a parsed pom.xml if it exists.
 func parsePomFile(ctx *gcp.Context) (*java.MavenProject, error) {
 	pomExists, err := ctx.FileExists("pom.xml")
@@ -165,6 +275,9 @@ func parsePomFile(ctx *gcp.Context) (*java.MavenProject, error) {
 	if !pomExists {
 		return nil, nil
 	}
+	if err := useMavenCache(ctx); err != nil {
+		return nil, err
+	}
 
 	tmpDir, err := ctx.TempDir("native-image-maven")
 	if err != nil {
@@ -199,15 +312,47 @@ func parsePomFile(ctx *gcp.Context) (*java.MavenProject, error) {
 	return project, nil
 }
 
-// findNativeBuildProfile returns the profile in which the native-image-plugin is defined
+// findFrameworkNativeBuild returns the Maven goals and arguments which build a native executable
+// using the native support of the framework of the project, and a bool which returns true if a
+// supported framework is found, false if not.
+func findFrameworkNativeBuild(ctx *gcp.Context, project *java.MavenProject) ([]string, bool) {
+	for _, plugin := range project.Plugins {
+		switch {
+		case (plugin.GroupID == "io.quarkus" || plugin.GroupID == "io.quarkus.platform") &&
+			plugin.ArtifactID == "quarkus-maven-plugin":
+			ctx.Logf("Building a Quarkus native executable")
+			return []string{"package", "-Dquarkus.native.enabled=true", "-Dquarkus.package.jar.enabled=false"}, true
+		case plugin.GroupID == "io.micronaut.maven" && plugin.ArtifactID == "micronaut-maven-plugin":
+			ctx.Logf("Building a Micronaut native executable")
+			return []string{"package", "-Dpackaging=native-image"}, true
+		}
+	}
+	// Spring Boot 3 projects declare the GraalVM Native Build Tools plugin, which is configured
+	// by the `native` profile of spring-boot-starter-parent.
+	if springBootPluginDefined(ctx, project) && nativeBuildToolsPluginDefined(project.Plugins) {
+		ctx.Logf("Building a Spring Boot native executable")
+		return []string{"-Pnative", "native:compile"}, true
+	}
+	return nil, false
+}
+
+// nativeBuildToolsPluginDefined checks if a native image Maven plugin is defined.
+func nativeBuildToolsPluginDefined(plugins []java.MavenPlugin) bool {
+	for _, plugin := range plugins {
+		if (plugin.GroupID == "org.graalvm.nativeimage" && plugin.ArtifactID == "native-image-maven-plugin") ||
+			(plugin.GroupID == "org.graalvm.buildtools" && plugin.ArtifactID == "native-maven-plugin") {
+			return true
+		}
+	}
+	return false
+}
+
+// findNativeBuildProfile returns the profile in which a native image Maven plugin is defined
 // and a bool which returns true if the plugin is found, false if not.
 func findNativeBuildProfile(ctx *gcp.Context, project *java.MavenProject) (string, bool) {
 	for _, profile := range project.Profiles {
-		for _, plugin := range profile.Plugins {
-			if plugin.GroupID == "org.graalvm.nativeimage" &&
-				plugin.ArtifactID == "native-image-maven-plugin" {
-				return profile.ID, true
-			}
+		if nativeBuildToolsPluginDefined(profile.Plugins) {
+			return profile.ID, true
 		}
 	}
 
@@ -261,7 +406,12 @@ func buildSpringBoot(ctx *gcp.Context) ([]string, error) {
 	} else if classpath == "" || main == "" {
 		return nil, nil
 	}
-	return buildCommandLine(ctx, []string{"--class-path", classpath, main})
+	// The classpath points to a temporary directory, so the jar it was extracted from is hashed.
+	jar, err := java.ExecutableJar(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("finding executable jar: %w", err)
+	}
+	return buildCommandLine(ctx, []string{"--class-path", classpath, main}, []string{jar})
 }
 
 // classpathAndMainFromSpringBoot returns classpath and main class of an exploded Spring Boot fat JAR
@@ -307,7 +457,11 @@ func buildFunctionsFramework(ctx *gcp.Context, functionTarget string, project *j
 		return nil, err
 	}
 
-	entrypoint, err := buildCommandLine(ctx, []string{"-cp", classpath, invokerMain})
+	inputs, err := classpathFiles(ctx, classpath)
+	if err != nil {
+		return nil, err
+	}
+	entrypoint, err := buildCommandLine(ctx, []string{"-cp", classpath, invokerMain}, inputs)
 	if err != nil {
 		return nil, err
 	}
@@ -331,3 +485,23 @@ func createFunctionsClasspath(ctx *gcp.Context, project *java.MavenProject) (str
 
 	return classpath, nil
 }
+
+// classpathFiles returns the files matched by the entries of the given classpath.
+func classpathFiles(ctx *gcp.Context, classpath string) ([]string, error) {
+	var files []string
+	for _, entry := range filepath.SplitList(classpath) {
+		if entry == "" {
+			continue
+		}
+		// A classpath wildcard matches all jars in the directory.
+		if strings.HasSuffix(entry, "*") {
+			entry += ".jar"
+		}
+		matches, err := ctx.Glob(entry)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -18,15 +18,18 @@ import (
 	"archive/zip"
 	"bytes"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -119,6 +122,183 @@ func TestFindSpringBootPlugin(t *testing.T) {
 	}
 }
 
+func TestFindFrameworkNativeBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mavenProject *java.MavenProject
+		want         []string
+		wantOK       bool
+	}{
+		{
+			name: "quarkus",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "io.quarkus.platform", ArtifactID: "quarkus-maven-plugin"},
+				},
+			},
+			want:   []string{"package", "-Dquarkus.native.enabled=true", "-Dquarkus.package.jar.enabled=false"},
+			wantOK: true,
+		},
+		{
+			name: "micronaut",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "io.micronaut.maven", ArtifactID: "micronaut-maven-plugin"},
+				},
+			},
+			want:   []string{"package", "-Dpackaging=native-image"},
+			wantOK: true,
+		},
+		{
+			name: "spring boot 3 with native build tools",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "org.springframework.boot", ArtifactID: "spring-boot-maven-plugin"},
+					{GroupID: "org.graalvm.buildtools", ArtifactID: "native-maven-plugin"},
+				},
+			},
+			want:   []string{"-Pnative", "native:compile"},
+			wantOK: true,
+		},
+		{
+			name: "spring boot without native build tools",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "org.springframework.boot", ArtifactID: "spring-boot-maven-plugin"},
+				},
+			},
+		},
+		{
+			name:         "no plugins",
+			mavenProject: &java.MavenProject{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := findFrameworkNativeBuild(gcp.NewContext(), tc.mavenProject)
+			if ok != tc.wantOK {
+				t.Errorf("findFrameworkNativeBuild() ok = %t, want %t", ok, tc.wantOK)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("findFrameworkNativeBuild() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindNativeBuildProfile(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mavenProject *java.MavenProject
+		want         string
+		wantOK       bool
+	}{
+		{
+			name: "native image maven plugin",
+			mavenProject: &java.MavenProject{
+				Profiles: []java.MavenProfile{
+					{ID: "graal", Plugins: []java.MavenPlugin{{GroupID: "org.graalvm.nativeimage", ArtifactID: "native-image-maven-plugin"}}},
+				},
+			},
+			want:   "graal",
+			wantOK: true,
+		},
+		{
+			name: "native build tools plugin",
+			mavenProject: &java.MavenProject{
+				Profiles: []java.MavenProfile{
+					{ID: "dev"},
+					{ID: "native", Plugins: []java.MavenPlugin{{GroupID: "org.graalvm.buildtools", ArtifactID: "native-maven-plugin"}}},
+				},
+			},
+			want:   "native",
+			wantOK: true,
+		},
+		{
+			name: "no native profile",
+			mavenProject: &java.MavenProject{
+				Profiles: []java.MavenProfile{{ID: "dev"}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := findNativeBuildProfile(gcp.NewContext(), tc.mavenProject)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("findNativeBuildProfile() = (%q, %t), want (%q, %t)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestNativeImageCached(t *testing.T) {
+	appDir := t.TempDir()
+	jar := filepath.Join(appDir, "app.jar")
+	if err := ioutil.WriteFile(jar, []byte("v1"), 0644); err != nil {
+		t.Fatalf("writing jar: %v", err)
+	}
+	layer := &libcnb.Layer{Name: nativeImageLayer, Path: t.TempDir(), Metadata: map[string]any{}}
+	image := filepath.Join(layer.Path, "bin", "native-app")
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir))
+
+	hash, cached, err := nativeImageCached(ctx, layer, image, []string{"-jar", jar}, []string{jar})
+	if err != nil {
+		t.Fatalf("nativeImageCached() got error: %v", err)
+	}
+	if cached {
+		t.Errorf("nativeImageCached() on empty layer = true, want false")
+	}
+	ctx.SetMetadata(layer, nativeImageInputsKey, hash)
+
+	// The hash matches but the executable does not exist.
+	if _, cached, err = nativeImageCached(ctx, layer, image, []string{"-jar", jar}, []string{jar}); err != nil || cached {
+		t.Errorf("nativeImageCached() without executable = %t, %v, want false, nil", cached, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(image), 0755); err != nil {
+		t.Fatalf("creating bin dir: %v", err)
+	}
+	if err := ioutil.WriteFile(image, []byte("binary"), 0755); err != nil {
+		t.Fatalf("writing image: %v", err)
+	}
+	if _, cached, err = nativeImageCached(ctx, layer, image, []string{"-jar", jar}, []string{jar}); err != nil || !cached {
+		t.Errorf("nativeImageCached() with unchanged inputs = %t, %v, want true, nil", cached, err)
+	}
+	if _, cached, err = nativeImageCached(ctx, layer, image, []string{"--verbose", "-jar", jar}, []string{jar}); err != nil || cached {
+		t.Errorf("nativeImageCached() with changed args = %t, %v, want false, nil", cached, err)
+	}
+
+	if err := ioutil.WriteFile(jar, []byte("v2"), 0644); err != nil {
+		t.Fatalf("writing jar: %v", err)
+	}
+	if _, cached, err = nativeImageCached(ctx, layer, image, []string{"-jar", jar}, []string{jar}); err != nil || cached {
+		t.Errorf("nativeImageCached() with changed jar = %t, %v, want false, nil", cached, err)
+	}
+}
+
+func TestClasspathFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"app.jar", "dependency/a.jar", "dependency/b.jar", "dependency/README"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+	classpath := strings.Join([]string{"", filepath.Join(dir, "app.jar"), filepath.Join(dir, "dependency", "*")}, string(filepath.ListSeparator))
+
+	got, err := classpathFiles(gcp.NewContext(), classpath)
+	if err != nil {
+		t.Fatalf("classpathFiles() got error: %v", err)
+	}
+	want := []string{filepath.Join(dir, "app.jar"), filepath.Join(dir, "dependency", "a.jar"), filepath.Join(dir, "dependency", "b.jar")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("classpathFiles() mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestGetClasspathAndMainFromSpringBoot(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -199,3 +379,75 @@ func setupTestJar(t *testing.T, mfContent []byte) string {
 	}
 	return jarPath
 }
+
+func TestUseGraalVM(t *testing.T) {
+	home := t.TempDir()
+	bin := filepath.Join(home, "bin", "native-image")
+	if err := os.MkdirAll(filepath.Dir(bin), 0755); err != nil {
+		t.Fatalf("creating bin dir: %v", err)
+	}
+	if err := ioutil.WriteFile(bin, nil, 0755); err != nil {
+		t.Fatalf("writing native-image: %v", err)
+	}
+	defer func(f func(string) (string, error)) { lookPath = f }(lookPath)
+	lookPath = func(file string) (string, error) {
+		return filepath.Join(home, "bin", file), nil
+	}
+	t.Setenv("JAVA_HOME", "/usr/lib/jvm/java-17")
+	t.Setenv("GRAALVM_HOME", "")
+
+	if err := useGraalVM(gcp.NewContext()); err != nil {
+		t.Fatalf("useGraalVM() got error: %v", err)
+	}
+	for _, name := range []string{"JAVA_HOME", "GRAALVM_HOME"} {
+		if got := os.Getenv(name); got != home {
+			t.Errorf("useGraalVM() %s = %q, want %q", name, got, home)
+		}
+	}
+}
+
+func TestUseMavenCache(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mavenRepo   bool
+		wantM2Layer bool
+	}{
+		{
+			name:        "no maven buildpack",
+			wantM2Layer: true,
+		},
+		{
+			name:      "repository of the maven buildpack",
+			mavenRepo: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			layers := t.TempDir()
+			want := filepath.Join(layers, m2Layer)
+			if tc.mavenRepo {
+				want = t.TempDir()
+				if err := os.Symlink(want, filepath.Join(home, ".m2")); err != nil {
+					t.Fatalf("linking ~/.m2: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+			if err := useMavenCache(ctx); err != nil {
+				t.Fatalf("useMavenCache() got error: %v", err)
+			}
+			got, err := os.Readlink(filepath.Join(home, ".m2"))
+			if err != nil {
+				t.Fatalf("reading ~/.m2 link: %v", err)
+			}
+			if got != want {
+				t.Errorf("useMavenCache() ~/.m2 = %q, want %q", got, want)
+			}
+			if _, err := os.Stat(filepath.Join(layers, m2Layer)); os.IsNotExist(err) == tc.wantM2Layer {
+				t.Errorf("useMavenCache() created %s layer = %t, want %t", m2Layer, !tc.wantM2Layer, tc.wantM2Layer)
+			}
+		})
+	}
+}
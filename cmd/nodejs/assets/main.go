@@ -0,0 +1,155 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/assets buildpack.
+// The assets buildpack builds the Node.js assets of PHP and Python apps with a package.json when
+// GOOGLE_ASSETS_RUNTIME is set to nodejs. The app itself is served by the PHP or Python runtime.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	nodeLayer   = "node"
+	packageJSON = "package.json"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	assets, err := runtime.AssetsRuntime()
+	if err != nil {
+		return nil, err
+	}
+	if assets != "nodejs" {
+		return gcp.OptOutEnvNotSet(env.AssetsRuntime), nil
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), packageJSON)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return gcp.OptOutFileNotFound(packageJSON), nil
+	}
+	plan := libcnb.BuildPlan{Provides: []libcnb.BuildPlanProvide{{Name: runtime.AssetsPlanName(assets)}}}
+	return gcp.OptInEnvSet(env.AssetsRuntime, gcp.WithBuildPlans(plan)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	version, err := nodejs.RequestedNodejsVersion(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	// Node.js is only needed to build the assets, so it is not added to the app image.
+	nl, err := ctx.Layer(nodeLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", nodeLayer, err)
+	}
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nl); err != nil {
+		return err
+	}
+	bin := filepath.Join(nl.Path, "bin")
+	if err := ctx.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		return err
+	}
+
+	pkgTool, err := packageManager(ctx)
+	if err != nil {
+		return err
+	}
+	if pkgTool != "npm" {
+		if err := ctx.Setenv("COREPACK_ENABLE_DOWNLOAD_PROMPT", "0"); err != nil {
+			return err
+		}
+		if _, err := ctx.Exec([]string{"corepack", "enable", "--install-directory", bin, pkgTool}, gcp.WithUserAttribution); err != nil {
+			return err
+		}
+	}
+	buildEnv := append([]string{"NODE_ENV=" + nodejs.EnvDevelopment, "CI=true"}, nodejs.PackageManagerConfigEnv(ctx, pkgTool)...)
+
+	install, ok := nodejs.InstallCommand()
+	if !ok {
+		install, err = installCommand(ctx, pkgTool)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := ctx.Exec(install, gcp.WithUserAttribution, gcp.WithEnv(buildEnv...)); err != nil {
+		return err
+	}
+
+	cmds, _ := nodejs.DetermineBuildCommands(pjs, pkgTool)
+	if len(cmds) == 0 {
+		ctx.Warnf("No build script found in %s, only the dependencies were installed.", packageJSON)
+	}
+	for _, cmd := range cmds {
+		if _, err := ctx.Exec(strings.Fields(cmd), gcp.WithUserAttribution, gcp.WithEnv(buildEnv...)); err != nil {
+			return err
+		}
+	}
+
+	ctx.Logf("Removing node_modules, which is only needed to build the assets.")
+	return ctx.RemoveAll(ctx.ApplicationRoot(), "node_modules")
+}
+
+// packageManager returns the package manager of the app based on its lock file.
+func packageManager(ctx *gcp.Context) (string, error) {
+	for _, lock := range []struct{ file, pkgTool string }{
+		{nodejs.PNPMLock, "pnpm"},
+		{nodejs.YarnLock, "yarn"},
+	} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), lock.file)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return lock.pkgTool, nil
+		}
+	}
+	return "npm", nil
+}
+
+// installCommand returns the command which installs all dependencies, including devDependencies,
+// with the given package manager.
+func installCommand(ctx *gcp.Context, pkgTool string) ([]string, error) {
+	if pkgTool != "npm" {
+		return []string{pkgTool, "install"}, nil
+	}
+	for _, lock := range []string{nodejs.PackageLock, nodejs.NPMShrinkwrap} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), lock)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return []string{"npm", "ci"}, nil
+		}
+	}
+	return []string{"npm", "install"}, nil
+}
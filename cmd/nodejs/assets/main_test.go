@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name: "with package.json and assets runtime",
+			files: map[string]string{
+				"index.php":    "",
+				"package.json": "",
+			},
+			env:  []string{"GOOGLE_ASSETS_RUNTIME=nodejs"},
+			want: 0,
+		},
+		{
+			name: "without assets runtime",
+			files: map[string]string{
+				"index.php":    "",
+				"package.json": "",
+			},
+			want: 100,
+		},
+		{
+			name: "without package.json",
+			files: map[string]string{
+				"index.php": "",
+			},
+			env:  []string{"GOOGLE_ASSETS_RUNTIME=nodejs"},
+			want: 100,
+		},
+		{
+			name: "with unsupported assets runtime",
+			files: map[string]string{
+				"package.json": "",
+			},
+			env:  []string{"GOOGLE_ASSETS_RUNTIME=ruby"},
+			want: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestInstallCommand(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name: "without lock file",
+			want: []string{"npm", "install"},
+		},
+		{
+			name:  "with package-lock.json",
+			files: []string{"package-lock.json"},
+			want:  []string{"npm", "ci"},
+		},
+		{
+			name:  "with yarn.lock",
+			files: []string{"yarn.lock"},
+			want:  []string{"yarn", "install"},
+		},
+		{
+			name:  "with pnpm-lock.yaml",
+			files: []string{"pnpm-lock.yaml", "package-lock.json"},
+			want:  []string{"pnpm", "install"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			pkgTool, err := packageManager(ctx)
+			if err != nil {
+				t.Fatalf("packageManager() got error: %v", err)
+			}
+			got, err := installCommand(ctx, pkgTool)
+			if err != nil {
+				t.Fatalf("installCommand(%q) got error: %v", pkgTool, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("installCommand(%q) mismatch (-want +got):\n%s", pkgTool, diff)
+			}
+		})
+	}
+}
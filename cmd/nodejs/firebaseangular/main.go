@@ -18,77 +18,42 @@ package main
 
 import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
-	// "google3/third_party/golang/hashicorp/version/version"
-	"github.com/Masterminds/semver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
-var (
-	// minAngularVersion is the lowest version of angular supported by the firebase angular buildpack.
-	minAngularVersion = semver.MustParse("17.2.0")
-)
-
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	// b/319754948
-	// In monorepo scenarios, we'll probably need to support environment variable that can be used to
-	// know where the application directory is located within the repository.
-	angularJSONExists, err := ctx.FileExists("angular.json")
-	if err != nil {
-		return nil, err
+	if result := runtime.CheckFrameworkOverride("angular"); result != nil {
+		return result, nil
 	}
-	if angularJSONExists {
-		return gcp.OptInFileFound("angular.json"), nil
-	}
-	return gcp.OptOut("angular config not found"), nil
-}
-
-func buildFn(ctx *gcp.Context) error {
-	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	version, err := nodejs.Version(ctx, pjs, "@angular/core")
+	a, err := nodejs.DetectFrameworkAdapter(ctx, pjs, "angular")
 	if err != nil {
-		return err
-	}
-
-	err = validateVersion(ctx, version)
-	if err != nil {
-		return err
+		return nil, err
 	}
-
-	buildScript, exists := pjs.Scripts["build"]
-	if exists && buildScript != "ng build" && buildScript != "apphosting-adapter-angular-build" {
-		ctx.Warnf("*** You are using a custom build command (your build command is NOT 'ng build'), we will accept it as is but will error if output structure is not as expected ***")
+	if a == nil {
+		return gcp.OptOut("angular config not found"), nil
 	}
+	return gcp.OptIn("angular config found"), nil
+}
 
-	al, err := ctx.Layer("npm_modules", gcp.BuildLayer, gcp.CacheLayer)
+func buildFn(ctx *gcp.Context) error {
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
 	if err != nil {
 		return err
 	}
-	if err = nodejs.InstallAngularBuildAdaptor(ctx, al, version); err != nil {
-		return err
-	}
-	// This env var indicates to the package manager buildpack that a different command needs to be run
-	nodejs.OverrideAngularBuildScript(al)
-
-	return nil
+	return nodejs.ApplyFrameworkAdapter(ctx, adapter(), pjs)
 }
 
-func validateVersion(ctx *gcp.Context, depVersion string) error {
-	version, err := semver.NewVersion(depVersion)
-	if err != nil {
-		return gcp.InternalErrorf("parsing angular version: %v, %s", err, depVersion)
-	}
-	if version.LessThan(minAngularVersion) {
-		ctx.Warnf("Unsupported version of angular: %s", depVersion)
-		ctx.Warnf("Update the angular dependencies to >=%s", minAngularVersion.String())
-		return gcp.UserErrorf("unsupported version of angular %s", depVersion)
-	}
-	return nil
+func adapter() nodejs.FrameworkAdapter {
+	a, _ := nodejs.FrameworkAdapterByName("angular")
+	return a
 }
@@ -79,10 +79,10 @@ func TestBuild(t *testing.T) {
 			}`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 			wantCommands: []string{
-				"npm install --prefix npm_modules @apphosting/adapter-angular@17.2",
+				"npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2",
 			},
 		},
 		{
@@ -101,7 +101,7 @@ func TestBuild(t *testing.T) {
 				}`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@latest`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@latest`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-19.0.0.tgz","version":"19.0.0","integrity":"sha512-adaptor"}]`)),
 			},
 		},
 		{
@@ -124,7 +124,7 @@ func TestBuild(t *testing.T) {
 				}`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 		},
 		{
@@ -162,7 +162,7 @@ func TestBuild(t *testing.T) {
 			}`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 			wantExitCode: 0,
 		},
@@ -185,7 +185,7 @@ func TestBuild(t *testing.T) {
 				}`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 		},
 		{
@@ -205,7 +205,7 @@ func TestBuild(t *testing.T) {
 				}`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 		},
 		{
@@ -223,7 +223,7 @@ dependencies:
 `,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 		},
 		{
@@ -239,7 +239,7 @@ dependencies:
 	version: 17.2.0`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 		},
 		{
@@ -256,7 +256,7 @@ dependencies:
 	`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
 			},
 		},
 	}
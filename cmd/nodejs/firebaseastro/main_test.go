@@ -0,0 +1,163 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "with astro config",
+			files: map[string]string{
+				"astro.config.mjs": "",
+			},
+			want: 0,
+		},
+		{
+			name: "with typescript astro config",
+			files: map[string]string{
+				"astro.config.ts": "",
+			},
+			want: 0,
+		},
+		{
+			name: "without astro config",
+			files: map[string]string{
+				"index.js": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	packageLock := `{
+		"packages": {
+			"node_modules/astro": {
+				"version": "4.5.9"
+			}
+		}
+	}`
+	testCases := []struct {
+		name           string
+		wantExitCode   int
+		wantCommands   []string
+		wantNoCommands []string
+		mocks          []*mockprocess.Mock
+		files          map[string]string
+	}{
+		{
+			name: "server output installs adaptor",
+			files: map[string]string{
+				"astro.config.mjs": `export default defineConfig({ output: 'server' });`,
+				"package.json": `{
+					"scripts": {
+						"build": "astro build"
+					},
+					"dependencies": {
+						"astro": "^4.5.0"
+					}
+				}`,
+				"package-lock.json": packageLock,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-astro@4.5`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-astro-4.5.0.tgz","version":"4.5.0","integrity":"sha512-adaptor"}]`)),
+			},
+			wantCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-astro@4.5",
+			},
+		},
+		{
+			name: "static output does not install adaptor",
+			files: map[string]string{
+				"astro.config.mjs": `export default defineConfig({});`,
+				"package.json": `{
+					"scripts": {
+						"build": "astro build"
+					},
+					"dependencies": {
+						"astro": "^4.5.0"
+					}
+				}`,
+				"package-lock.json": packageLock,
+			},
+			wantNoCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-astro@4.5",
+			},
+		},
+		{
+			name: "error out if the version is below 3.0.0",
+			files: map[string]string{
+				"astro.config.mjs": `export default defineConfig({ output: 'server' });`,
+				"package.json": `{
+					"dependencies": {
+						"astro": "2.10.15"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/astro": {
+							"version": "2.10.15"
+						}
+					}
+				}`,
+			},
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithExecMocks(tc.mocks...),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.wantNoCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
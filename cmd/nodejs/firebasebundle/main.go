@@ -18,6 +18,11 @@
 // 1. Copy over static assets to the output bundle dir
 // 2. Delete unnecessary files
 // 3. Override run script with a new one to run the optimized build
+// 4. Keep only the standalone server of Next.js apps built with output: 'standalone'
+// 5. Write the manifest of the env vars the image expects to the output bundle dir
+// 6. Serve Next.js apps built with output: 'export' with nginx instead of Node.js, under their basePath
+// 7. Size the Node.js heap to the memory set in apphosting.yaml
+// 8. Warn about the directories the framework writes to at runtime which are not declared writable
 package main
 
 import (
@@ -26,13 +31,23 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/apphostingschema"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/envschema"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"gopkg.in/yaml.v2"
 )
 
 const (
 	defaultPublicDir        = "public"
 	firebaseOutputBundleDir = "FIREBASE_OUTPUT_BUNDLE_DIR"
+	appHostingYAML          = "apphosting.yaml"
+
+	// nginxVerConstraint is the version of nginx static exports are served with.
+	nginxVerConstraint = "^1.21.6"
+	defaultNginxPort   = 8080
 )
 
 func main() {
@@ -46,7 +61,16 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	bundlePath := filepath.Join(ctx.ApplicationRoot(), ".apphosting", "bundle.yaml")
+	// The adapters write the bundle into the workspace package selected with GOOGLE_NODEJS_APP_DIR.
+	appDir, err := nodejs.AppDir(ctx)
+	if err != nil {
+		return err
+	}
+	pkgDir, err := nodejs.WorkspacePackageDir(ctx)
+	if err != nil {
+		return err
+	}
+	bundlePath := filepath.Join(appDir, ".apphosting", "bundle.yaml")
 	bundleYaml, err := readBundleYaml(ctx, bundlePath)
 	if err != nil {
 		return err
@@ -58,13 +82,40 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.InternalErrorf("looking up output bundle env %s", firebaseOutputBundleDir)
 	}
 
-	workspacePublicDir := filepath.Join(ctx.ApplicationRoot(), defaultPublicDir)
-	outputPublicDir := filepath.Join(outputBundleDir, defaultPublicDir)
+	appHostingSchema, err := apphostingschema.ReadAndValidateAppHostingSchemaFromFile(filepath.Join(ctx.ApplicationRoot(), appHostingYAML))
+	if err != nil {
+		return gcp.UserErrorf("reading %s: %w", appHostingYAML, err)
+	}
+	if err := writeEnvSchema(ctx, appHostingSchema, outputBundleDir); err != nil {
+		return err
+	}
+	if err := warnUndeclaredWritablePaths(ctx, appHostingSchema, appDir); err != nil {
+		return err
+	}
+
+	staticExport, err := nodejs.NextjsStaticExport(ctx, appDir)
+	if err != nil {
+		return err
+	}
+	if staticExport {
+		return serveNextjsStaticExport(ctx, appDir, outputBundleDir)
+	}
+	if err := setHeapSize(ctx, appHostingSchema.RunConfig); err != nil {
+		return err
+	}
+
+	// The static assets directory can be set with buildConfig.outputDirectory in apphosting.yaml.
+	publicDir := defaultPublicDir
+	if dir := os.Getenv(nodejs.OutputDirEnv); dir != "" {
+		publicDir = filepath.Clean(dir)
+	}
+	workspacePublicDir := filepath.Join(appDir, publicDir)
+	outputPublicDir := filepath.Join(outputBundleDir, publicDir)
 	if bundleYaml == nil {
 		ctx.Logf("bundle.yaml does not exist, assuming default configs")
 
 		// if public folder exists assume that the code there should be in cdn
-		ctx.Logf("No static assets declared, copying public directory (if it exists) to staticAssets by default")
+		ctx.Logf("No static assets declared, copying %s directory (if it exists) to staticAssets by default", publicDir)
 		err := copyPublicDirToOutputBundleDir(outputPublicDir, workspacePublicDir, ctx)
 		if err != nil {
 			return err
@@ -74,7 +125,8 @@ func buildFn(ctx *gcp.Context) error {
 			return err
 		}
 
-		return nil
+		_, err = useNextjsStandalone(ctx, appDir)
+		return err
 	}
 
 	ctx.Logf("Copying static assets.")
@@ -85,7 +137,7 @@ func buildFn(ctx *gcp.Context) error {
 
 	if bundleYaml.StaticAssets == nil {
 		// copy public folder by default if there are no static assets declared
-		ctx.Logf("No static assets declared, copying public directory (if it exists) to staticAssets by default")
+		ctx.Logf("No static assets declared, copying %s directory (if it exists) to staticAssets by default", publicDir)
 		err := copyPublicDirToOutputBundleDir(outputPublicDir, workspacePublicDir, ctx)
 		if err != nil {
 			return err
@@ -93,26 +145,31 @@ func buildFn(ctx *gcp.Context) error {
 	} else {
 		for _, staticAsset := range bundleYaml.StaticAssets {
 			ctx.MkdirAll(filepath.Join(outputBundleDir, staticAsset), 0744)
-			err := fileutil.MaybeCopyPathContents(filepath.Join(outputBundleDir, staticAsset), filepath.Join(ctx.ApplicationRoot(), staticAsset), fileutil.AllPaths)
+			err := fileutil.MaybeCopyPathContents(filepath.Join(outputBundleDir, staticAsset), filepath.Join(appDir, staticAsset), fileutil.AllPaths)
 			if err != nil {
 				ctx.Logf("%s dir not detected", staticAsset)
 			}
 		}
 	}
 
+	standalone, err := useNextjsStandalone(ctx, appDir)
+	if err != nil {
+		return err
+	}
+
 	ctx.Logf("Deleting unneeded dirs.")
 	if bundleYaml.NeededDirs == nil {
 		ctx.Logf("No directories declared, keeping all by default")
 	} else {
 		neededDirMap := convertToMap(bundleYaml.NeededDirs)
 
-		files, err := ctx.ReadDir(ctx.ApplicationRoot())
+		files, err := ctx.ReadDir(appDir)
 		if err != nil {
 			return err
 		}
 		for _, file := range files {
 			if _, ok := neededDirMap[file.Name()]; !ok {
-				err := ctx.RemoveAll(filepath.Join(ctx.ApplicationRoot(), file.Name()))
+				err := ctx.RemoveAll(filepath.Join(appDir, file.Name()))
 				if err != nil {
 					return err
 				}
@@ -121,12 +178,163 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.Logf("Configuring run command entry point")
-	if bundleYaml.RunCommand != "" {
-		ctx.AddWebProcess(strings.Split(bundleYaml.RunCommand, " "))
+	if !standalone && bundleYaml.RunCommand != "" {
+		ctx.AddProcess(gcp.WebProcess, strings.Split(bundleYaml.RunCommand, " "), gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(pkgDir))
+	}
+	return nil
+}
+
+// useNextjsStandalone replaces the application with the standalone server of Next.js apps built
+// with output: 'standalone' and configures it as the web process. It returns false if the app was
+// not built with standalone output.
+func useNextjsStandalone(ctx *gcp.Context, appDir string) (bool, error) {
+	// Only create the launch layer when it is used, it would otherwise be exported empty.
+	standalone, err := nodejs.UseNextjsStandalone(ctx, appDir)
+	if err != nil || !standalone {
+		return false, err
+	}
+	l, err := ctx.Layer("nextjs_standalone", gcp.LaunchLayer)
+	if err != nil {
+		return false, err
+	}
+	serverDir, err := nodejs.CopyNextjsStandalone(ctx, l, appDir)
+	if err != nil || serverDir == "" {
+		return false, err
+	}
+	ctx.AddProcess(gcp.WebProcess, []string{"node", "server.js"}, gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(serverDir))
+	return true, nil
+}
+
+// serveNextjsStaticExport copies the static site of a Next.js app built with output: 'export' to
+// the output bundle dir as static assets and configures nginx to serve it as the web process.
+// node_modules is removed from the application and the Node.js runtime is excluded from the image
+// since no Node.js process runs in it.
+func serveNextjsStaticExport(ctx *gcp.Context, appDir, outputBundleDir string) error {
+	exportDir := filepath.Join(appDir, nodejs.NextjsExportDir)
+	exists, err := ctx.FileExists(exportDir)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return gcp.UserErrorf("Next.js static export directory %s not found, make sure the build runs `next build`", exportDir)
+	}
+	ctx.Logf("Next.js static export detected, serving %s with nginx", nodejs.NextjsExportDir)
+
+	if err := copyPublicDirToOutputBundleDir(filepath.Join(outputBundleDir, nodejs.NextjsExportDir), exportDir, ctx); err != nil {
+		return err
+	}
+	rawBundleYaml, err := yaml.Marshal(bundleYaml{StaticAssets: []string{nodejs.NextjsExportDir}})
+	if err != nil {
+		return gcp.InternalErrorf("marshalling bundle.yaml: %w", err)
+	}
+	if err := ctx.WriteFile(filepath.Join(outputBundleDir, "bundle.yaml"), rawBundleYaml, 0644); err != nil {
+		return err
+	}
+
+	nl, err := ctx.Layer("nginx", gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return err
 	}
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.Nginx, nginxVerConstraint, nl); err != nil {
+		return err
+	}
+	// The workers and connections are sized to the CPU and memory of the instance at launch.
+	if err := nginx.WriteTuningExecD(nl.Exec.Path); err != nil {
+		return gcp.InternalErrorf("writing nginx tuning exec.d: %w", err)
+	}
+	cl, err := ctx.Layer("nginx_config", gcp.LaunchLayer)
+	if err != nil {
+		return err
+	}
+	// The basePath and assetPrefix are read by the nextjs buildpack, which ran before.
+	routing, err := nodejs.NextjsRoutingFromLayers(filepath.Dir(ctx.LayersDir()))
+	if err != nil {
+		return err
+	}
+	conf, err := nginx.WriteStaticSiteConfigToPath(cl.Path, nginx.StaticSiteConfig{
+		Port:          defaultNginxPort,
+		Root:          exportDir,
+		MimeTypesPath: filepath.Join(nl.Path, "conf", "mime.types"),
+		BasePath:      routing.BasePath,
+		AssetPrefix:   routing.LocalAssetPrefix(),
+	})
+	if err != nil {
+		return gcp.InternalErrorf("writing nginx config: %w", err)
+	}
+	defer conf.Close()
+
+	if err := ctx.RemoveAll(filepath.Join(appDir, "node_modules")); err != nil {
+		return err
+	}
+	if err := nodejs.ExcludeRuntimeFromLaunch(ctx, filepath.Dir(ctx.LayersDir())); err != nil {
+		return err
+	}
+	ctx.AddProcess(gcp.WebProcess, []string{filepath.Join(nl.Path, "sbin", "nginx"), "-p", nl.Path, "-c", conf.Name()}, gcp.AsDirectProcess(), gcp.AsDefaultProcess())
 	return nil
 }
 
+// setHeapSize sizes the Node.js heap at launch to the runConfig.memoryMiB set in apphosting.yaml.
+func setHeapSize(ctx *gcp.Context, runConfig apphostingschema.RunConfig) error {
+	if runConfig.MemoryMiB == nil {
+		return nil
+	}
+	l, err := ctx.Layer("nodejs_heap_size", gcp.LaunchLayer)
+	if err != nil {
+		return err
+	}
+	return nodejs.WriteHeapSizeExecD(ctx, l, *runConfig.MemoryMiB)
+}
+
+// writeEnvSchema writes the manifest of the env vars declared in apphosting.yaml and set by the
+// launch layers of the previous buildpacks to the output bundle dir, so that deployment tooling
+// can catch missing runtime secrets before a rollout.
+func writeEnvSchema(ctx *gcp.Context, appHostingSchema apphostingschema.AppHostingSchema, outputBundleDir string) error {
+	buildpackVars, err := envschema.FromLayers(filepath.Dir(ctx.LayersDir()))
+	if err != nil {
+		return gcp.InternalErrorf("listing env vars set by buildpacks: %w", err)
+	}
+	schema := envschema.New(envschema.FromAppHostingSchema(appHostingSchema), buildpackVars)
+	ctx.Logf("Writing the manifest of %d env vars to %s", len(schema.Variables), envschema.FileName)
+	if err := envschema.Write(schema, filepath.Join(outputBundleDir, envschema.FileName)); err != nil {
+		return gcp.InternalErrorf("writing %s: %w", envschema.FileName, err)
+	}
+	return nil
+}
+
+// warnUndeclaredWritablePaths warns about the directories the framework of the app writes to at
+// runtime which are not declared in writablePaths of apphosting.yaml, since the writes fail on a
+// read-only filesystem.
+func warnUndeclaredWritablePaths(ctx *gcp.Context, appHostingSchema apphostingschema.AppHostingSchema, appDir string) error {
+	pjs, err := nodejs.ReadPackageJSONIfExists(appDir)
+	if err != nil {
+		return err
+	}
+	paths, err := nodejs.RuntimeWritablePaths(ctx, pjs, appDir)
+	if err != nil {
+		return err
+	}
+	relAppDir, err := filepath.Rel(ctx.ApplicationRoot(), appDir)
+	if err != nil {
+		return gcp.InternalErrorf("resolving %s relative to the application root: %w", appDir, err)
+	}
+	for _, p := range paths {
+		rel := filepath.Join(relAppDir, p)
+		if !declaredWritable(appHostingSchema.WritablePaths, rel) {
+			ctx.Warnf("The app writes to %s at runtime, which fails if the filesystem is read-only. Declare it in writablePaths of %s, for example:\n  writablePaths:\n  - path: %s\n    sizeMiB: 256", rel, appHostingYAML, rel)
+		}
+	}
+	return nil
+}
+
+func declaredWritable(writablePaths []apphostingschema.WritablePath, rel string) bool {
+	for _, wp := range writablePaths {
+		if wp.Contains(rel) {
+			return true
+		}
+	}
+	return false
+}
+
 // BundleYaml represents the contents of a bundle.yaml file.
 type bundleYaml struct {
 	RunCommand   string   `yaml:"runCommand"`
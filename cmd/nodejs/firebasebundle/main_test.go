@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
@@ -46,6 +47,8 @@ func TestBuild(t *testing.T) {
 		name          string
 		files         map[string]string
 		expectedFiles []string
+		wantOutput    string
+		envs          []string
 		codeDir       string
 	}{
 		{
@@ -54,9 +57,35 @@ func TestBuild(t *testing.T) {
 				"public/test1":  "",
 				"test_dir/test": "",
 			},
-			expectedFiles: []string{"test_dir/public/test1", "test_dir/bundle.yaml"},
+			expectedFiles: []string{"test_dir/public/test1", "test_dir/bundle.yaml", "test_dir/envschema.yaml"},
 			codeDir:       "CodeDir-no-bundleyaml",
 		},
+		{
+			name: "writes the env vars declared in apphosting.yaml to envschema.yaml",
+			files: map[string]string{
+				"apphosting.yaml": `env:
+- variable: API_KEY
+  secret: apiKey
+  availability:
+  - RUNTIME`,
+				".apphosting/bundle.yaml": "",
+				"test_dir/test":           "",
+			},
+			expectedFiles: []string{"test_dir/envschema.yaml"},
+			wantOutput:    "Writing the manifest of 1 env vars to envschema.yaml",
+			codeDir:       "CodeDir-envschema",
+		},
+		{
+			name: "sizes the Node.js heap given apphosting.yaml sets the memory",
+			files: map[string]string{
+				"apphosting.yaml": `runConfig:
+  memoryMiB: 2048`,
+				".apphosting/bundle.yaml": "",
+				"test_dir/test":           "",
+			},
+			wantOutput: "Setting the Node.js heap size to 1536 MiB for instances with 2048 MiB of memory",
+			codeDir:    "CodeDir-heapsize",
+		},
 		{
 			name: "copies ./public dir given bundle.yaml is empty",
 			files: map[string]string{
@@ -94,6 +123,39 @@ neededDirs:
 			expectedFiles: []string{"test_dir/static/test1", "test_dir/public/test1", "test_dir/bundle.yaml", ".apphosting/bundle.yaml"},
 			codeDir:       "CodeDir-staticassets-neededdirs-bundleyaml",
 		},
+		{
+			name: "copies the buildConfig output directory given no bundle.yaml",
+			files: map[string]string{
+				"dist/index.html": "",
+				"test_dir/test":   "",
+			},
+			envs:          []string{"GOOGLE_NODEJS_OUTPUT_DIR=dist"},
+			expectedFiles: []string{"test_dir/dist/index.html", "test_dir/bundle.yaml"},
+			codeDir:       "CodeDir-outputdir-no-bundleyaml",
+		},
+		{
+			name: "keeps only the standalone server given next.config.js sets standalone output",
+			files: map[string]string{
+				"next.config.js":             "module.exports = { output: 'standalone' }",
+				".next/standalone/server.js": "",
+				".next/static/chunks/app.js": "",
+				".apphosting/bundle.yaml":    "runCommand: node .next/standalone/server.js",
+				"test_dir/test":              "",
+			},
+			expectedFiles: []string{"test_dir/bundle.yaml", ".next/static/chunks/app.js"},
+			wantOutput:    "Copying the Next.js standalone output into the image",
+			codeDir:       "CodeDir-nextjs-standalone",
+		},
+		{
+			name: "warns given a Next.js app does not declare .next/cache writable",
+			files: map[string]string{
+				"package.json":            `{"dependencies": {"next": "^14.0.0"}}`,
+				".apphosting/bundle.yaml": "",
+				"test_dir/test":           "",
+			},
+			wantOutput: "The app writes to .next/cache at runtime",
+			codeDir:    "CodeDir-nextjs-writablepaths",
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -101,12 +163,15 @@ neededDirs:
 				bpt.WithTestName(tc.name),
 				bpt.WithFiles(tc.files),
 				bpt.WithTempDir(tc.codeDir),
-				bpt.WithEnvs(fmt.Sprintf("%s=test_dir", firebaseOutputBundleDir)),
+				bpt.WithEnvs(append(tc.envs, fmt.Sprintf("%s=test_dir", firebaseOutputBundleDir))...),
 			}
 			result, err := bpt.RunBuild(t, buildFn, opts...)
 			if err != nil {
 				t.Fatalf("error running build: %v, result: %#v", err, result)
 			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q:\n%s", tc.wantOutput, result.Output)
+			}
 			if tc.expectedFiles != nil {
 				for _, f := range tc.expectedFiles {
 					_, err := os.ReadFile(filepath.Join(os.TempDir(), tc.codeDir, f))
@@ -18,87 +18,68 @@ package main
 
 import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
-	"github.com/Masterminds/semver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
-var (
-	// minNextVersion is the lowest version of nextjs supported by the firebasenextjs buildpack.
-	minNextVersion = semver.MustParse("13.0.0")
-)
-
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	// TODO (b/311402770)
-	// In monorepo scenarios, we'll probably need to support environment variable that can be used to
-	// know where the application directory is located within the repository.
-	// TODO (b/313959098)
-	// Verify nextjs version
-	nextConfigExists, err := ctx.FileExists("next.config.js")
+	if result := runtime.CheckFrameworkOverride("nextjs"); result != nil {
+		return result, nil
+	}
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if nextConfigExists {
-		return gcp.OptInFileFound("next.config.js"), nil
-	}
-
-	nextConfigModuleExists, err := ctx.FileExists("next.config.mjs")
+	a, err := nodejs.DetectFrameworkAdapter(ctx, pjs, "nextjs")
 	if err != nil {
 		return nil, err
 	}
-	if nextConfigModuleExists {
-		return gcp.OptInFileFound("next.config.mjs"), nil
+	if a == nil {
+		return gcp.OptOut("nextjs config not found"), nil
 	}
-	return gcp.OptOut("nextjs config not found"), nil
+	return gcp.OptIn("nextjs config found"), nil
 }
 
 func buildFn(ctx *gcp.Context) error {
-	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
 	if err != nil {
 		return err
 	}
-
-	version, err := nodejs.Version(ctx, pjs, "next")
+	appDir, err := nodejs.AppDir(ctx)
 	if err != nil {
 		return err
 	}
-
-	err = validateVersion(ctx, version)
-	if err != nil {
+	if err := writeRouting(ctx, appDir); err != nil {
 		return err
 	}
-
-	buildScript, exists := pjs.Scripts["build"]
-	if exists && buildScript == "next build" {
-		njsl, err := ctx.Layer("npm_modules", gcp.BuildLayer, gcp.CacheLayer)
-		if err != nil {
-			return err
-		}
-		err = nodejs.InstallNextJsBuildAdaptor(ctx, njsl, version)
-		if err != nil {
-			return err
-		}
-		// This env var indicates to the package manager buildpack that a different command needs to be run
-		nodejs.OverrideNextjsBuildScript(njsl)
-	} else if exists && buildScript != "apphosting-adapter-nextjs-build" {
-		ctx.Warnf("*** You are using a custom build command (your build command is NOT 'next build'), we will accept it as is but some features will not be enabled ***")
-	}
-	return err
+	return nodejs.ApplyFrameworkAdapter(ctx, adapter(), pjs)
 }
 
-func validateVersion(ctx *gcp.Context, depVersion string) error {
-	version, err := semver.NewVersion(depVersion)
+// writeRouting stores the basePath and assetPrefix of the app as layer metadata for the buildpack
+// generating the web server config.
+func writeRouting(ctx *gcp.Context, appDir string) error {
+	routing, err := nodejs.ReadNextjsRouting(ctx, appDir)
 	if err != nil {
-		return gcp.InternalErrorf("parsing next version: %v", err)
+		return err
+	}
+	if routing == (nodejs.NextjsRouting{}) {
+		return nil
 	}
-	if version.LessThan(minNextVersion) {
-		ctx.Warnf("Unsupported version of next: %s", depVersion)
-		ctx.Warnf("Update the next dependencies to >=%s", minNextVersion.String())
-		return gcp.UserErrorf("unsupported version of next %s", depVersion)
+	ctx.Logf("Next.js app served under basePath %q with assetPrefix %q", routing.BasePath, routing.AssetPrefix)
+	l, err := ctx.Layer(nodejs.NextjsRoutingLayer, gcp.BuildLayer)
+	if err != nil {
+		return err
 	}
+	nodejs.WriteNextjsRoutingMetadata(ctx, l, routing)
 	return nil
 }
+
+func adapter() nodejs.FrameworkAdapter {
+	a, _ := nodejs.FrameworkAdapterByName("nextjs")
+	return a
+}
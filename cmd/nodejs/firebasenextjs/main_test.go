@@ -25,6 +25,7 @@ func TestDetect(t *testing.T) {
 	testCases := []struct {
 		name  string
 		files map[string]string
+		env   []string
 		want  int
 	}{
 		{
@@ -42,10 +43,26 @@ func TestDetect(t *testing.T) {
 			},
 			want: 100,
 		},
+		{
+			name: "pinned framework without next config",
+			files: map[string]string{
+				"index.js": "",
+			},
+			env:  []string{"GOOGLE_FRAMEWORK=nextjs"},
+			want: 0,
+		},
+		{
+			name: "other pinned framework with next config",
+			files: map[string]string{
+				"next.config.js": "",
+			},
+			env:  []string{"GOOGLE_FRAMEWORK=angular"},
+			want: 100,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
 		})
 	}
 }
@@ -79,12 +96,50 @@ func TestBuild(t *testing.T) {
 			}`,
 			},
 			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-nextjs@13.0`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@13.0`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-13.0.0.tgz","version":"13.0.0","integrity":"sha512-adaptor"}]`)),
 			},
 			wantCommands: []string{
-				"npm install --prefix npm_modules @apphosting/adapter-nextjs@13.0",
+				"npm pack --json --pack-destination .* @apphosting/adapter-nextjs@13.0",
+			},
+		},
+		{
+			name: "static export skips the adaptor",
+			files: map[string]string{
+				"package.json": `{
+				"scripts": {
+					"build": "next build"
+				},
+				"dependencies": {
+					"next": "14.2.0"
+				}
+			}`, "package-lock.json": `{
+				"packages": {
+					"node_modules/next": {
+						"version": "14.2.0"
+					}
+				}
+			}`,
+				"next.config.js": `module.exports = { output: 'export' }`,
 			},
 		},
+		{
+			name: "invalid base path",
+			files: map[string]string{
+				"package.json": `{
+				"dependencies": {
+					"next": "14.2.0"
+				}
+			}`, "package-lock.json": `{
+				"packages": {
+					"node_modules/next": {
+						"version": "14.2.0"
+					}
+				}
+			}`,
+				"next.config.js": `module.exports = { output: 'export', basePath: 'docs' }`,
+			},
+			wantExitCode: 1,
+		},
 		{
 			name: "build script doesnt exist",
 			files: map[string]string{
@@ -0,0 +1,189 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "with nuxt config",
+			files: map[string]string{
+				"index.js":       "",
+				"nuxt.config.ts": "",
+			},
+			want: 0,
+		},
+		{
+			name: "with nuxt dependency",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"nuxt": "^3.11.0"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "with nuxt dev dependency",
+			files: map[string]string{
+				"package.json": `{"devDependencies": {"nuxt": "^3.11.0"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "without nuxt",
+			files: map[string]string{
+				"index.js":     "",
+				"package.json": `{"dependencies": {"express": "^4.0.0"}}`,
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		wantExitCode int
+		wantCommands []string
+		mocks        []*mockprocess.Mock
+		files        map[string]string
+	}{
+		{
+			name: "replace build script",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "nuxt build"
+					},
+					"devDependencies": {
+						"nuxt": "^3.11.0"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/nuxt": {
+							"version": "3.11.2"
+						}
+					}
+				}`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nuxt@3.11`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nuxt-3.11.0.tgz","version":"3.11.0","integrity":"sha512-adaptor"}]`)),
+			},
+			wantCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-nuxt@3.11",
+			},
+		},
+		{
+			name: "custom build script is kept",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "nuxt generate"
+					},
+					"dependencies": {
+						"nuxt": "3.11.2"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/nuxt": {
+							"version": "3.11.2"
+						}
+					}
+				}`,
+			},
+		},
+		{
+			name: "read version from pnpm-lock.yaml",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "nuxi build"
+					},
+					"dependencies": {
+						"nuxt": "^3.10.0"
+					}
+				}`,
+				"pnpm-lock.yaml": `
+dependencies:
+  nuxt:
+    version: 3.10.3(vite@5.1.4)
+`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nuxt@3.10`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nuxt-3.10.0.tgz","version":"3.10.0","integrity":"sha512-adaptor"}]`)),
+			},
+			wantCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-nuxt@3.10",
+			},
+		},
+		{
+			name: "error out if the version is below 3.0.0",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"nuxt": "2.17.3"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/nuxt": {
+							"version": "2.17.3"
+						}
+					}
+				}`,
+			},
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithExecMocks(tc.mocks...),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
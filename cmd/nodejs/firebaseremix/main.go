@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/firebaseremix buildpack.
+// The nodejs/firebaseremix buildpack does some prep work for remix and react router and overwrites the build script.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// frameworks are the names of the framework adapters handled by the firebaseremix buildpack.
+var frameworks = []string{"remix", "react-router"}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if result := runtime.CheckFrameworkOverride(frameworks...); result != nil {
+		return result, nil
+	}
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
+	if err != nil {
+		return nil, err
+	}
+	adapter, err := nodejs.DetectFrameworkAdapter(ctx, pjs, frameworks...)
+	if err != nil {
+		return nil, err
+	}
+	if adapter == nil {
+		return gcp.OptOut("remix and react router framework mode dependencies not found"), nil
+	}
+	return gcp.OptIn(adapter.Name() + " dependency found in package.json"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
+	if err != nil {
+		return err
+	}
+	adapter, err := nodejs.DetectFrameworkAdapter(ctx, pjs, frameworks...)
+	if err != nil {
+		return err
+	}
+	if adapter == nil {
+		return gcp.UserErrorf("remix and react router framework mode dependencies not found in package.json")
+	}
+	return nodejs.ApplyFrameworkAdapter(ctx, adapter, pjs)
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name: "with remix dependency",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"@remix-run/node": "^2.8.1"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "with react router framework mode",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"react-router": "^7.1.0"}, "devDependencies": {"@react-router/dev": "^7.1.0"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "react router as a library",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"react-router": "^7.1.0"}}`,
+			},
+			want: 100,
+		},
+		{
+			name: "without package.json",
+			files: map[string]string{
+				"index.js": "",
+			},
+			want: 100,
+		},
+		{
+			name: "pinned react router framework",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"react-router": "^7.1.0"}}`,
+			},
+			env:  []string{"GOOGLE_FRAMEWORK=react-router"},
+			want: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name           string
+		wantExitCode   int
+		wantCommands   []string
+		wantNoCommands []string
+		mocks          []*mockprocess.Mock
+		files          map[string]string
+	}{
+		{
+			name: "replace remix build script",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "remix vite:build"
+					},
+					"dependencies": {
+						"@remix-run/node": "^2.8.1"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/@remix-run/node": {
+							"version": "2.8.1"
+						}
+					}
+				}`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-remix@2.8`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-remix-2.8.0.tgz","version":"2.8.0","integrity":"sha512-adaptor"}]`)),
+			},
+			wantCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-remix@2.8",
+			},
+		},
+		{
+			name: "replace react router build script",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "react-router build"
+					},
+					"dependencies": {
+						"react-router": "^7.1.0"
+					},
+					"devDependencies": {
+						"@react-router/dev": "^7.1.0"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/react-router": {
+							"version": "7.1.3"
+						}
+					}
+				}`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-react-router@7.1`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-react-router-7.1.0.tgz","version":"7.1.0","integrity":"sha512-adaptor"}]`)),
+			},
+			wantCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-react-router@7.1",
+			},
+		},
+		{
+			name: "custom build script is kept",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "npm run css && remix vite:build"
+					},
+					"dependencies": {
+						"@remix-run/node": "^2.8.1"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/@remix-run/node": {
+							"version": "2.8.1"
+						}
+					}
+				}`,
+			},
+			wantNoCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-remix@2.8",
+			},
+		},
+		{
+			name: "error out if the remix version is below 2.0.0",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"@remix-run/node": "1.19.3"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/@remix-run/node": {
+							"version": "1.19.3"
+						}
+					}
+				}`,
+			},
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithExecMocks(tc.mocks...),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.wantNoCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
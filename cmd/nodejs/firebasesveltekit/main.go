@@ -0,0 +1,59 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/firebasesveltekit buildpack.
+// The nodejs/firebasesveltekit buildpack does some prep work for sveltekit and overwrites the build script.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if result := runtime.CheckFrameworkOverride("sveltekit"); result != nil {
+		return result, nil
+	}
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a, err := nodejs.DetectFrameworkAdapter(ctx, pjs, "sveltekit")
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return gcp.OptOut("sveltekit dependency not found"), nil
+	}
+	return gcp.OptIn("sveltekit dependency found"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
+	if err != nil {
+		return err
+	}
+	return nodejs.ApplyFrameworkAdapter(ctx, adapter(), pjs)
+}
+
+func adapter() nodejs.FrameworkAdapter {
+	a, _ := nodejs.FrameworkAdapterByName("sveltekit")
+	return a
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "with sveltekit dev dependency",
+			files: map[string]string{
+				"svelte.config.js": "",
+				"package.json":     `{"devDependencies": {"@sveltejs/kit": "^2.5.0"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "with sveltekit dependency",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"@sveltejs/kit": "^2.5.0"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "svelte without sveltekit",
+			files: map[string]string{
+				"svelte.config.js": "",
+				"package.json":     `{"devDependencies": {"svelte": "^4.2.0"}}`,
+			},
+			want: 100,
+		},
+		{
+			name: "without package.json",
+			files: map[string]string{
+				"index.js": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		wantExitCode int
+		wantCommands []string
+		mocks        []*mockprocess.Mock
+		files        map[string]string
+	}{
+		{
+			name: "replace build script",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "vite build"
+					},
+					"devDependencies": {
+						"@sveltejs/kit": "^2.5.0"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/@sveltejs/kit": {
+							"version": "2.5.4"
+						}
+					}
+				}`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-sveltekit@2.5`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-sveltekit-2.5.0.tgz","version":"2.5.0","integrity":"sha512-adaptor"}]`)),
+			},
+			wantCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-sveltekit@2.5",
+			},
+		},
+		{
+			name: "custom build script is kept",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "vite build && node scripts/postbuild.js"
+					},
+					"devDependencies": {
+						"@sveltejs/kit": "2.5.4"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/@sveltejs/kit": {
+							"version": "2.5.4"
+						}
+					}
+				}`,
+			},
+		},
+		{
+			name: "read version from pnpm-lock.yaml",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "vite build"
+					},
+					"devDependencies": {
+						"@sveltejs/kit": "^2.0.0"
+					}
+				}`,
+				"pnpm-lock.yaml": `
+devDependencies:
+  '@sveltejs/kit':
+    version: 2.3.1(@sveltejs/vite-plugin-svelte@3.0.2)(svelte@4.2.12)(vite@5.1.4)
+`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-sveltekit@2.3`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-sveltekit-2.3.0.tgz","version":"2.3.0","integrity":"sha512-adaptor"}]`)),
+			},
+			wantCommands: []string{
+				"npm pack --json --pack-destination .* @apphosting/adapter-sveltekit@2.3",
+			},
+		},
+		{
+			name: "error out if the version is below 1.0.0",
+			files: map[string]string{
+				"package.json": `{
+					"devDependencies": {
+						"@sveltejs/kit": "1.0.0-next.589"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/@sveltejs/kit": {
+							"version": "1.0.0-next.589"
+						}
+					}
+				}`,
+			},
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithExecMocks(tc.mocks...),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
@@ -22,9 +22,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appenv"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
-	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
@@ -54,7 +54,6 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
-	nm := filepath.Join(ml.Path, "node_modules")
 	if nmExists, _ := ctx.FileExists("node_modules"); nmExists {
 		buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.NpmNodeModulesCounterID).Increment(1)
 
@@ -86,7 +85,17 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
-	buildCmds, isCustomBuild := nodejs.DetermineBuildCommands(pjs, "npm")
+	// Dependencies are installed for the whole workspace, while the build scripts and start command
+	// come from the selected workspace package.
+	pkgDir, err := nodejs.WorkspacePackageDir(ctx)
+	if err != nil {
+		return err
+	}
+	appPjs, err := nodejs.ReadAppPackageJSON(ctx)
+	if err != nil {
+		return err
+	}
+	buildCmds, isCustomBuild := nodejs.DetermineBuildCommands(appPjs, "npm")
 	// Respect the user's NODE_ENV value if it's set
 	buildNodeEnv, nodeEnvPresent := os.LookupEnv(nodejs.EnvNodeEnv)
 	if !nodeEnvPresent {
@@ -101,86 +110,103 @@ func buildFn(ctx *gcp.Context) error {
 
 	if vendorNpmDeps {
 		buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.NpmVendorDependenciesCounterID).Increment(1)
-		if _, err := ctx.Exec([]string{"npm", "rebuild"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution); err != nil {
+		if _, err := ctx.Exec([]string{"npm", "rebuild"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithEnv(nodejs.ConcurrencyEnv(ctx, "npm")...), gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "npm")...), gcp.WithUserAttribution); err != nil {
 			return err
 		}
 	} else {
-		cached, err := nodejs.CheckOrClearCache(ctx, ml, cache.WithStrings(buildNodeEnv), cache.WithFiles("package.json", lockfile))
+		err := nodejs.WithNativeBinaryCache(ctx, func() error {
+			return nodejs.InstallDependencies(ctx, ml, "npm", lockfile, buildNodeEnv, func() error {
+				ctx.Logf("Installing application dependencies.")
+				cmd, ok := nodejs.InstallCommand()
+				if !ok {
+					installCmd, err := nodejs.NPMInstallCommand(ctx)
+					if err != nil {
+						return err
+					}
+					cmd = []string{"npm", installCmd, "--quiet"}
+				}
+				if _, err := ctx.Exec(cmd, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithEnv(nodejs.ConcurrencyEnv(ctx, "npm")...), gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "npm")...), gcp.WithUserAttribution); err != nil {
+					return err
+				}
+				// Ensure node_modules exists even if no dependencies were installed.
+				return ctx.MkdirAll("node_modules", 0755)
+			})
+		})
 		if err != nil {
-			return fmt.Errorf("checking cache: %w", err)
-		}
-		if cached {
-			// Restore cached node_modules.
-			if _, err := ctx.Exec([]string{"cp", "--archive", nm, "node_modules"}, gcp.WithUserTimingAttribution); err != nil {
-				return err
-			}
-
-			// Always run npm install to run preinstall/postinstall scripts.
-			// Otherwise it should be a no-op because the lockfile is unchanged.
-			if _, err := ctx.Exec([]string{"npm", "install", "--quiet"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution); err != nil {
-				return err
-			}
-		} else {
-			ctx.Logf("Installing application dependencies.")
-			installCmd, err := nodejs.NPMInstallCommand(ctx)
-			if err != nil {
-				return err
-			}
-
-			if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution); err != nil {
-				return err
-			}
-			// Ensure node_modules exists even if no dependencies were installed.
-			if err := ctx.MkdirAll("node_modules", 0755); err != nil {
-				return err
-			}
-			if _, err := ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution); err != nil {
-				return err
-			}
+			return err
 		}
 	}
+	if err := nodejs.Audit(ctx, "npm"); err != nil {
+		return err
+	}
+	if err := nodejs.GeneratePrismaClient(ctx, appPjs, "npm"); err != nil {
+		return err
+	}
 
 	if len(buildCmds) > 0 {
-		// If there are multiple build scripts to run, run them one-by-one so the logs are
-		// easier to understand.
-		for _, cmd := range buildCmds {
-			split := strings.Split(cmd, " ")
-			if _, err := ctx.Exec(split, gcp.WithUserAttribution); err != nil {
-				if !isCustomBuild {
-					return fmt.Errorf(`%w
+		err := nodejs.WithBuildCaches(ctx, appPjs, func() error {
+			// If there are multiple build scripts to run, run them one-by-one so the logs are
+			// easier to understand.
+			for _, cmd := range buildCmds {
+				split := strings.Split(cmd, " ")
+				if _, err := ctx.Exec(split, gcp.WithUserAttribution, gcp.WithWorkDir(pkgDir)); err != nil {
+					if !isCustomBuild {
+						return fmt.Errorf(`%w
 NOTE: Running the default build script can be skipped by passing the empty environment variable "%s=" to the build`, err, nodejs.GoogleNodeRunScriptsEnv)
+					}
+					return err
 				}
-				return err
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 
-		shouldPrune, err := shouldPrune(ctx, pjs)
+		appDir := pkgDir
+		if appDir == "" {
+			appDir = ctx.ApplicationRoot()
+		}
+		shouldPrune, err := shouldPrune(ctx, pjs, appPjs, appDir)
 		if err != nil {
 			return err
 		}
 		if shouldPrune {
 			// npm prune deletes devDependencies from node_modules
-			if _, err := ctx.Exec([]string{"npm", "prune", "--production"}, gcp.WithUserAttribution); err != nil {
+			err := nodejs.WithPrismaClient(ctx, func() error {
+				_, err := ctx.Exec([]string{"npm", "prune", "--production"}, gcp.WithUserAttribution, gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "npm")...))
+				return err
+			})
+			if err != nil {
 				return err
 			}
 		}
 	}
 
+	if err := nodejs.SliceNodeModules(ctx); err != nil {
+		return err
+	}
+
 	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
 	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
-	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
+	if pkgDir != "" {
+		el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(pkgDir, "node_modules", ".bin"))
+	}
+	if err := appenv.Set(ctx, el, appenv.NodeEnv); err != nil {
+		return err
+	}
 
 	// Configure the entrypoint for production.
-	cmd, err := nodejs.DefaultStartCommand(ctx, pjs)
+	cmd, err := nodejs.DefaultStartCommand(ctx, appPjs)
 	if err != nil {
 		return fmt.Errorf("detecting start command: %w", err)
 	}
 
 	if !devmode.Enabled(ctx) {
-		ctx.AddWebProcess(cmd)
+		ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(pkgDir))
 		return nil
 	}
 
@@ -196,7 +222,7 @@ NOTE: Running the default build script can be skipped by passing the empty envir
 	return nil
 }
 
-func shouldPrune(ctx *gcp.Context, pjs *nodejs.PackageJSON) (bool, error) {
+func shouldPrune(ctx *gcp.Context, pjs, appPjs *nodejs.PackageJSON, appDir string) (bool, error) {
 	// if we are vendoring dependencies, we do not need to prune
 	if nodejs.IsUsingVendoredDependencies() {
 		return false, nil
@@ -206,15 +232,34 @@ func shouldPrune(ctx *gcp.Context, pjs *nodejs.PackageJSON) (bool, error) {
 	if !nodejs.HasDevDependencies(pjs) {
 		return false, nil
 	}
-	if nodeEnv := nodejs.NodeEnv(); nodeEnv != nodejs.EnvProduction {
+	force, err := nodejs.PruneDevDependencies()
+	if err != nil {
+		return false, err
+	}
+	if nodeEnv := nodejs.NodeEnv(); nodeEnv != nodejs.EnvProduction && !force {
 		ctx.Logf("Retaining devDependencies because $NODE_ENV=%q.", nodeEnv)
 		return false, nil
 	}
 	canPrune, err := nodejs.SupportsNPMPrune(ctx)
-	if err == nil && !canPrune {
-		ctx.Warnf("Retaining devDependencies because the version of NPM you are using does not support 'npm prune'.")
+	if err != nil {
+		return false, err
+	}
+	if !canPrune {
+		ctx.CategoryWarnf(gcp.WarningSize, "Retaining devDependencies because the version of NPM you are using does not support 'npm prune'.")
+		return false, nil
+	}
+	if !force {
+		return true, nil
+	}
+	reasons, err := nodejs.DevDependenciesNeededAtRuntime(ctx, appPjs, appDir)
+	if err != nil {
+		return false, err
+	}
+	if len(reasons) > 0 {
+		ctx.CategoryWarnf(gcp.WarningSize, "Retaining devDependencies despite %s=true because %s.", nodejs.PruneDevDependenciesEnv, strings.Join(reasons, ", "))
+		return false, nil
 	}
-	return canPrune, err
+	return true, nil
 }
 
 func upgradeNPM(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
@@ -108,6 +108,36 @@ func TestBuild(t *testing.T) {
 				"npm run build",
 			},
 		},
+		{
+			name: "prune devDependencies with NODE_ENV set",
+			app:  "typescript",
+			envs: []string{"NODE_ENV=development", nodejs.PruneDevDependenciesEnv + "=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.4")),
+			},
+			wantCommands: []string{
+				"npm ci.*NODE_ENV=development",
+				"npm run build",
+				"npm prune --production",
+			},
+		},
+		{
+			name: "retain devDependencies used by the start script",
+			app:  "typescript",
+			envs: []string{"NODE_ENV=development", nodejs.PruneDevDependenciesEnv + "=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.4")),
+			},
+			files: map[string]string{
+				"package.json": `{"scripts": {"build": "tsc -p .", "start": "ts-node index.ts"}, "devDependencies": {"ts-node": "^10.0.0"}}`,
+			},
+			wantCommands: []string{
+				"npm run build",
+			},
+			doNotWantCommands: []string{
+				"npm prune",
+			},
+		},
 		{
 			name: "node scripts env set",
 			app:  "gcp_build_npm",
@@ -139,6 +169,31 @@ func TestBuild(t *testing.T) {
 				"npm run gcp-build",
 			},
 		},
+		{
+			name: "audit passes",
+			app:  "package_lock",
+			envs: []string{"GOOGLE_NODEJS_AUDIT_LEVEL=critical"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+				mockprocess.New(`^npm audit`, mockprocess.WithExitCode(1), mockprocess.WithStdout(`{"metadata": {"vulnerabilities": {"high": 1}}}`)),
+			},
+			wantCommands: []string{
+				"npm audit --omit=dev --json",
+			},
+		},
+		{
+			name: "audit fails",
+			app:  "package_lock",
+			envs: []string{"GOOGLE_NODEJS_AUDIT_LEVEL=high"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+				mockprocess.New(`^npm audit`, mockprocess.WithExitCode(1), mockprocess.WithStdout(`{"metadata": {"vulnerabilities": {"high": 1}}}`)),
+			},
+			wantExitCode: 1,
+			doNotWantCommands: []string{
+				"npm run build",
+			},
+		},
 		{
 			name: "node rebuild for vendored deps",
 			envs: []string{"GOOGLE_VENDOR_NPM_DEPENDENCIES=true"},
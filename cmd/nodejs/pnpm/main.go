@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appenv"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 )
@@ -28,6 +29,8 @@ import (
 const (
 	cacheTag  = "prod dependencies"
 	pnpmLayer = "pnpm_engine"
+	// pnpmModulesLayer caches the installed node_modules directories.
+	pnpmModulesLayer = "pnpm_modules"
 )
 
 func main() {
@@ -63,7 +66,21 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.InternalErrorf("installing pnpm: %w", err)
 	}
 
-	if err := pnpmInstallModules(ctx, pjs); err != nil {
+	// Dependencies are installed for the whole workspace, while the build scripts and start command
+	// come from the selected workspace package.
+	pkgDir, err := nodejs.WorkspacePackageDir(ctx)
+	if err != nil {
+		return err
+	}
+	appPjs, err := nodejs.ReadAppPackageJSON(ctx)
+	if err != nil {
+		return err
+	}
+	if err := pnpmInstallModules(ctx, appPjs, pkgDir); err != nil {
+		return err
+	}
+
+	if err := nodejs.SliceNodeModules(ctx); err != nil {
 		return err
 	}
 
@@ -72,14 +89,19 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.InternalErrorf("creating layer: %w", err)
 	}
 	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
-	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
+	if pkgDir != "" {
+		el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(pkgDir, "node_modules", ".bin"))
+	}
+	if err := appenv.Set(ctx, el, appenv.NodeEnv); err != nil {
+		return err
+	}
 
 	// Configure the entrypoint for production.
-	ctx.AddWebProcess([]string{"pnpm", "run", "start"})
+	ctx.AddProcess(gcp.WebProcess, []string{"pnpm", "run", "start"}, gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(pkgDir))
 	return nil
 }
 
-func pnpmInstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
+func pnpmInstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON, pkgDir string) error {
 	buildCmds, _ := nodejs.DetermineBuildCommands(pjs, "pnpm")
 	// Respect the user's NODE_ENV value if it's set
 	buildNodeEnv, nodeEnvPresent := os.LookupEnv(nodejs.EnvNodeEnv)
@@ -92,31 +114,95 @@ func pnpmInstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 			buildNodeEnv = nodejs.EnvProduction
 		}
 	}
-	cmd := []string{"pnpm", "install"}
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true"), gcp.WithEnv("NODE_ENV="+buildNodeEnv)); err != nil {
-		return gcp.UserErrorf("installing pnpm dependencies: %w", err)
+	ml, err := ctx.Layer(pnpmModulesLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return gcp.InternalErrorf("creating %v layer: %w", pnpmModulesLayer, err)
+	}
+	err = nodejs.WithNativeBinaryCache(ctx, func() error {
+		return nodejs.InstallDependencies(ctx, ml, "pnpm", nodejs.PNPMLock, buildNodeEnv, func() error {
+			cmd, ok := nodejs.InstallCommand()
+			if !ok {
+				cmd = []string{"pnpm", "install"}
+			}
+			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true"), gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithEnv(nodejs.ConcurrencyEnv(ctx, "pnpm")...), gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "pnpm")...)); err != nil {
+				return gcp.UserErrorf("installing pnpm dependencies: %w", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := nodejs.Audit(ctx, "pnpm"); err != nil {
+		return err
+	}
+	if err := nodejs.GeneratePrismaClient(ctx, pjs, "pnpm"); err != nil {
+		return err
 	}
 	if len(buildCmds) > 0 {
-		// If there are multiple build scripts to run, run them one-by-one so the logs are
-		// easier to understand.
-		for _, cmd := range buildCmds {
-			split := strings.Split(cmd, " ")
-			if _, err := ctx.Exec(split, gcp.WithUserAttribution); err != nil {
-				return err
+		err := nodejs.WithBuildCaches(ctx, pjs, func() error {
+			// If there are multiple build scripts to run, run them one-by-one so the logs are
+			// easier to understand.
+			for _, cmd := range buildCmds {
+				split := strings.Split(cmd, " ")
+				if _, err := ctx.Exec(split, gcp.WithUserAttribution, gcp.WithWorkDir(pkgDir)); err != nil {
+					return err
+				}
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
-	if buildNodeEnv == nodejs.EnvDevelopment && !nodeEnvPresent && nodejs.HasDevDependencies(pjs) {
-		// If we installed dependencies with NODE_ENV=development and the user didn't explicitly set
-		// NODE_ENV we should prune the devDependencies from the final app image.
+	shouldPrune, err := shouldPrune(ctx, pjs, pkgDir, buildNodeEnv == nodejs.EnvDevelopment && !nodeEnvPresent)
+	if err != nil {
+		return err
+	}
+	if shouldPrune {
 		cmd := []string{"pnpm", "prune", "--prod"}
-		if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true")); err != nil {
-			return gcp.UserErrorf("pruning devDependencies: %w", err)
+		err := nodejs.WithPrismaClient(ctx, func() error {
+			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true"), gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "pnpm")...)); err != nil {
+				return gcp.UserErrorf("pruning devDependencies: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// shouldPrune returns true if the devDependencies should be pruned from the final app image. This
+// is the case if we installed dependencies with NODE_ENV=development and the user didn't explicitly
+// set NODE_ENV, or if pruning was requested with GOOGLE_NODEJS_PRUNE_DEV_DEPENDENCIES.
+func shouldPrune(ctx *gcp.Context, pjs *nodejs.PackageJSON, pkgDir string, installedDevDeps bool) (bool, error) {
+	if !nodejs.HasDevDependencies(pjs) {
+		return false, nil
+	}
+	force, err := nodejs.PruneDevDependencies()
+	if err != nil {
+		return false, err
+	}
+	if !force {
+		return installedDevDeps, nil
+	}
+	appDir := pkgDir
+	if appDir == "" {
+		appDir = ctx.ApplicationRoot()
+	}
+	reasons, err := nodejs.DevDependenciesNeededAtRuntime(ctx, pjs, appDir)
+	if err != nil {
+		return false, err
+	}
+	if len(reasons) > 0 {
+		ctx.CategoryWarnf(gcp.WarningSize, "Retaining devDependencies despite %s=true because %s.", nodejs.PruneDevDependenciesEnv, strings.Join(reasons, ", "))
+		return false, nil
+	}
+	return true, nil
+}
+
 func installPNPM(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	layer, err := ctx.Layer(pnpmLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
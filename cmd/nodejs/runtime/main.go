@@ -13,19 +13,22 @@
 // limitations under the License.
 
 // Implements nodejs/runtime buildpack.
-// The runtime buildpack installs the Node.js runtime.
+// The runtime buildpack installs the Node.js runtime and disables the telemetry of frameworks.
 package main
 
 import (
 	"fmt"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 )
 
-const nodeLayer = "node"
+const (
+	telemetryLayer = "telemetry"
+)
 
 func main() {
 	gcp.Main(detectFn, buildFn)
@@ -55,22 +58,42 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 		return gcp.OptIn("found .js files"), nil
 	}
 
+	jsTask, err := java.KotlinJSTask(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if jsTask != "" {
+		return gcp.OptIn("found Kotlin/JS Node.js executable in Gradle build"), nil
+	}
+
 	return gcp.OptOut("neither package.json nor any .js files found"), nil
 }
 
 func buildFn(ctx *gcp.Context) error {
-	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	pjs, err := nodejs.ReadAppPackageJSON(ctx)
 	if err != nil {
 		return err
 	}
+	// Workspace packages often inherit the Node.js engine from the package.json of the workspace.
+	if pjs == nil || (pjs.Engines.Node == "" && pjs.Volta.Node == "") {
+		if pjs, err = nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot()); err != nil {
+			return err
+		}
+	}
 	version, err := nodejs.RequestedNodejsVersion(ctx, pjs)
 	if err != nil {
 		return err
 	}
-	nrl, err := ctx.Layer(nodeLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+	nrl, err := ctx.Layer(nodejs.RuntimeLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", nodejs.RuntimeLayer, err)
+	}
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nrl); err != nil {
+		return err
+	}
+	tl, err := ctx.Layer(telemetryLayer, gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
-		return fmt.Errorf("creating %v layer: %w", nodeLayer, err)
+		return fmt.Errorf("creating %v layer: %w", telemetryLayer, err)
 	}
-	_, err = runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nrl)
-	return err
+	return nodejs.DisableTelemetry(ctx, tl)
 }
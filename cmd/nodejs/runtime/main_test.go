@@ -67,6 +67,31 @@ func TestDetect(t *testing.T) {
 			},
 			want: 100,
 		},
+		{
+			name: "kotlin js node executable",
+			files: map[string]string{
+				"build.gradle.kts": `plugins {
+    kotlin("multiplatform") version "2.0.0"
+}
+
+kotlin {
+    js {
+        nodejs()
+        binaries.executable()
+    }
+}`,
+			},
+			want: 0,
+		},
+		{
+			name: "kotlin jvm",
+			files: map[string]string{
+				"build.gradle.kts": `plugins {
+    kotlin("jvm") version "2.0.0"
+}`,
+			},
+			want: 100,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
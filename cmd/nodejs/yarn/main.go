@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appenv"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
@@ -67,30 +68,59 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("installing Yarn: %w", err)
 	}
 
-	if yarn2, err := nodejs.IsYarn2(ctx.ApplicationRoot()); err != nil {
+	// Dependencies are installed for the whole workspace, while the build scripts and start command
+	// come from the selected workspace package.
+	pkgDir, err := nodejs.WorkspacePackageDir(ctx)
+	if err != nil {
+		return err
+	}
+	appPjs, err := nodejs.ReadAppPackageJSON(ctx)
+	if err != nil {
 		return err
-	} else if yarn2 {
-		if err := yarn2InstallModules(ctx, pjs); err != nil {
+	}
+	yarn2, err := nodejs.IsYarn2(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	pnp := false
+	if yarn2 {
+		if pnp, err = nodejs.IsYarnPnP(ctx); err != nil {
 			return err
 		}
-	} else {
-		if err := yarn1InstallModules(ctx, pjs); err != nil {
-			return err
+	}
+	err = nodejs.WithBuildCaches(ctx, appPjs, func() error {
+		if yarn2 {
+			return yarn2InstallModules(ctx, appPjs, pkgDir, pnp)
 		}
+		return yarn1InstallModules(ctx, appPjs, pkgDir)
+	})
+	if err != nil {
+		return err
 	}
 
 	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
-	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
-	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
+	// In Plug'n'Play mode there is no node_modules directory, dependencies are resolved by .pnp.cjs.
+	if !pnp {
+		if err := nodejs.SliceNodeModules(ctx); err != nil {
+			return err
+		}
+		el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
+		if pkgDir != "" {
+			el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(pkgDir, "node_modules", ".bin"))
+		}
+	}
+	if err := appenv.Set(ctx, el, appenv.NodeEnv); err != nil {
+		return err
+	}
 
 	// Configure the entrypoint for production.
 	cmd := []string{"yarn", "run", "start"}
 
 	if !devmode.Enabled(ctx) {
-		ctx.AddWebProcess(cmd)
+		ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(pkgDir))
 		return nil
 	}
 
@@ -106,7 +136,7 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
+func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON, pkgDir string) error {
 	freezeLockfile, err := nodejs.UseFrozenLockfile(ctx)
 	if err != nil {
 		return err
@@ -165,7 +195,7 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		cmd = append(cmd, "--frozen-lockfile")
 	}
 	gcpBuild := nodejs.HasGCPBuild(pjs)
-	appHostingBuildScript, appHostingBuildScriptPresent := os.LookupEnv(nodejs.AppHostingBuildEnv)
+	appHostingBuildScript, appHostingBuildScriptPresent := nodejs.BuildCommand(pjs)
 	if gcpBuild || appHostingBuildScriptPresent {
 		// Setting --production=false causes the devDependencies to be installed regardless of the
 		// NODE_ENV value. The allows the customer's lifecycle hooks to access to them. We purge the
@@ -173,19 +203,33 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		cmd = append(cmd, "--production=false")
 	}
 
+	if installCmd, ok := nodejs.InstallCommand(); ok {
+		cmd = installCmd
+	}
+
 	// Add the layer's node_modules/.bin to the path so it is available in postinstall scripts.
 	nodeBin := filepath.Join(layerModules, ".bin")
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin))); err != nil {
+	err = nodejs.WithNativeBinaryCache(ctx, func() error {
+		_, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin)), gcp.WithEnv(nodejs.ConcurrencyEnv(ctx, "yarn")...), gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "yarn")...))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := nodejs.Audit(ctx, "yarn"); err != nil {
+		return err
+	}
+	if err := nodejs.GeneratePrismaClient(ctx, pjs, "yarn"); err != nil {
 		return err
 	}
 
 	if gcpBuild || appHostingBuildScriptPresent {
 		if appHostingBuildScriptPresent {
-			if _, err := ctx.Exec(strings.Split(appHostingBuildScript, " "), gcp.WithUserAttribution); err != nil {
+			if _, err := ctx.Exec(strings.Split(appHostingBuildScript, " "), gcp.WithUserAttribution, gcp.WithWorkDir(pkgDir)); err != nil {
 				return err
 			}
 		} else {
-			if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution); err != nil {
+			if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution, gcp.WithWorkDir(pkgDir)); err != nil {
 				return err
 			}
 		}
@@ -202,7 +246,11 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 			if freezeLockfile {
 				cmd = append(cmd, "--frozen-lockfile")
 			}
-			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+			err := nodejs.WithPrismaClient(ctx, func() error {
+				_, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "yarn")...))
+				return err
+			})
+			if err != nil {
 				return err
 			}
 		}
@@ -211,7 +259,7 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	return nil
 }
 
-func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
+func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON, pkgDir string, pnp bool) error {
 	if err := ar.GenerateYarnConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
@@ -227,13 +275,45 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	if yarnCacheExists {
 		cmd = append(cmd, "--immutable-cache")
 	}
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+	if installCmd, ok := nodejs.InstallCommand(); ok {
+		cmd = installCmd
+	}
+	install := func() error {
+		var pnpEnv []string
+		if pnp {
+			pnpEnv = nodejs.YarnPnPEnv()
+		}
+		_, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(pnpEnv...), gcp.WithEnv(nodejs.ConcurrencyEnv(ctx, "yarn")...), gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "yarn")...))
+		return err
+	}
+	if pnp {
+		ctx.Logf("Installing dependencies in Yarn Plug'n'Play mode")
+		err = nodejs.WithYarnCache(ctx, install)
+	} else {
+		err = install()
+	}
+	if err != nil {
+		return err
+	}
+	if err := nodejs.Audit(ctx, "yarn"); err != nil {
+		return err
+	}
+	if err := nodejs.GeneratePrismaClient(ctx, pjs, "yarn"); err != nil {
 		return err
 	}
 
-	// Run the gcp-build script if it exists.
-	if nodejs.HasGCPBuild(pjs) {
-		if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution); err != nil {
+	// Run the build command or the gcp-build script if it exists. Build commands which are not run
+	// with yarn need the Plug'n'Play loader to resolve dependencies.
+	buildCommand, _ := nodejs.BuildCommand(pjs)
+	if cmd := strings.Fields(buildCommand); len(cmd) > 0 {
+		if pnp {
+			cmd = nodejs.YarnPnPCommand(cmd)
+		}
+		if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithWorkDir(pkgDir)); err != nil {
+			return err
+		}
+	} else if nodejs.HasGCPBuild(pjs) {
+		if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution, gcp.WithWorkDir(pkgDir)); err != nil {
 			return err
 		}
 	}
@@ -253,15 +333,15 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		return err
 	}
 	if !hasWorkPlugin {
-		ctx.Warnf("Keeping devDependencies because the Yarn workspace-tools plugin is not installed. You can add it to your project by running 'yarn plugin import workspace-tools'")
+		ctx.CategoryWarnf(gcp.WarningSize, "Keeping devDependencies because the Yarn workspace-tools plugin is not installed. You can add it to your project by running 'yarn plugin import workspace-tools'")
 		return nil
 	}
 	// For Yarn2, dependency pruning is via the workspaces plugin.
 	ctx.Logf("Pruning devDependencies")
-	if _, err := ctx.Exec([]string{"yarn", "workspaces", "focus", "--all", "--production"}, gcp.WithUserAttribution); err != nil {
+	return nodejs.WithPrismaClient(ctx, func() error {
+		_, err := ctx.Exec([]string{"yarn", "workspaces", "focus", "--all", "--production"}, gcp.WithUserAttribution, gcp.WithEnv(nodejs.PackageManagerConfigEnv(ctx, "yarn")...))
 		return err
-	}
-	return nil
+	})
 }
 
 func installYarn(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
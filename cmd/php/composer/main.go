@@ -48,5 +48,15 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("composer install: %w", err)
 	}
 
+	framework, err := php.DetectFramework(ctx)
+	if err != nil || framework == nil {
+		return err
+	}
+	switch framework.Name {
+	case php.Laravel.Name:
+		return php.LaravelOptimize(ctx)
+	case php.Symfony.Name:
+		return php.SymfonyBuild(ctx)
+	}
 	return nil
 }
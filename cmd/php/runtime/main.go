@@ -20,16 +20,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/webconfig"
 )
 
 const (
 	phpIniName = "php.ini"
+	// extensionsIni is the ini file which loads the extensions installed by the buildpack during
+	// the build.
+	extensionsIni = "extensions.ini"
 )
 
 func main() {
@@ -37,6 +43,16 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	result, err := detectPHP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The static assets of hybrid apps are built by the runtime selected with
+	// GOOGLE_ASSETS_RUNTIME before PHP.
+	return runtime.RequireAssets(ctx, result)
+}
+
+func detectPHP(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if result := runtime.CheckOverride("php"); result != nil {
 		return result, nil
 	}
@@ -86,7 +102,48 @@ func buildFn(ctx *gcp.Context) error {
 	setPeclConfig(phpl)
 	setPHPFpmConfig(phpl)
 
-	return addPHPIni(ctx, phpl)
+	extensions, err := installExtensions(ctx)
+	if err != nil {
+		return err
+	}
+	return addPHPIni(ctx, phpl, extensions)
+}
+
+// installExtensions installs the PHP extensions the application requires which the runtime does
+// not load, and Xdebug in development mode, and returns the ini directives which load them. The
+// directives are read from the ini directories of PHP during the build, so that composer finds the
+// extensions.
+func installExtensions(ctx *gcp.Context) (string, error) {
+	names, err := php.RequiredExtensions(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(names) > 0 {
+		ctx.RecordSetting("php extensions", strings.Join(names, ", "), gcp.SourceComposerJSON)
+	}
+	devMode, err := webconfig.DevModeRequested(ctx)
+	if err != nil {
+		return "", err
+	}
+	if devMode && !slices.Contains(names, php.Xdebug) {
+		names = append(names, php.Xdebug)
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	l, err := ctx.Layer("php-extensions", gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+	if err != nil {
+		return "", fmt.Errorf("creating layer: %w", err)
+	}
+	iniDir := filepath.Join(l.Path, "php.d")
+	directives, err := php.InstallExtensions(ctx, l, names, filepath.Join(iniDir, extensionsIni))
+	if err != nil {
+		return "", err
+	}
+	if directives != "" {
+		l.BuildEnvironment.Default(php.IniScanDirEnv, string(os.PathListSeparator)+iniDir)
+	}
+	return directives, nil
 }
 
 func setPeclConfig(phpl *libcnb.Layer) {
@@ -98,7 +155,9 @@ func setPHPFpmConfig(phpl *libcnb.Layer) {
 	phpl.LaunchEnvironment.Append("PATH", string(os.PathListSeparator), filepath.Join(phpl.Path, "sbin"))
 }
 
-func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer) error {
+// addPHPIni writes the php.ini of the runtime, followed by the extensions directives, which PHP
+// reads at launch.
+func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer, extensions string) error {
 	destDir := filepath.Join(phpl.Path, "etc")
 	destPath := filepath.Join(destDir, phpIniName)
 
@@ -106,7 +165,7 @@ func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer) error {
 		return fmt.Errorf("creating etc folder: %w", err)
 	}
 
-	if err := ctx.WriteFile(destPath, []byte(php.PHPIni), os.FileMode(0755)); err != nil {
+	if err := ctx.WriteFile(destPath, []byte(php.PHPIni+extensions), os.FileMode(0755)); err != nil {
 		return err
 	}
 
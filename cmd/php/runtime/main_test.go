@@ -65,6 +65,15 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "composer.json with nodejs assets",
+			files: map[string]string{
+				"composer.json": "",
+				"package.json":  "",
+			},
+			env:  []string{"GOOGLE_ASSETS_RUNTIME=nodejs"},
+			want: 0,
+		},
 		{
 			name:  "no composer.json and no php files",
 			files: map[string]string{},
@@ -19,8 +19,15 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -30,6 +37,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/webconfig"
 	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -43,19 +51,41 @@ const (
 	defaultFrontController = "index.php"
 	defaultNginxBinary     = "nginx"
 	defaultNginxPort       = 8080
+	defaultTLSPort         = 8443
 	defaultRoot            = "/workspace"
 	nginxConf              = "nginx.conf"
+	nginxModulesDir        = "/layers/google.utils.nginx/nginx/modules"
 	nginxLog               = "nginx.log"
 
+	// php
+	// phpIniDir is added to the ini directories of PHP at launch.
+	phpIniDir     = "php.d"
+	directivesIni = "directives.ini"
+	opcacheDir    = "opcache"
+	preloadScript = "preload.php"
+	preloadIni    = "preload.ini"
+	// overridesDir holds the user-provided config files with their placeholders expanded.
+	overridesDir = "overrides"
+
+	// wordpress
+	// wordpressUploadSize is the default upload_max_filesize and post_max_size of WordPress
+	// applications, which nginx accepts as the request body size too.
+	wordpressUploadSize = "64M"
+	wordpressUploads    = "wp-content/uploads"
+
 	// php-fpm
 	defaultDynamicWorkers = false
 	defaultFPMBinary      = "php-fpm"
-	defaultFPMWorkers     = 2
 	phpFpmPid             = "php-fpm.pid"
+	// defaultSlowlog routes the php-fpm slow log to stderr, which is collected by the logging agent.
+	defaultSlowlog = "/proc/self/fd/2"
 )
 
 var (
 	overrides = webconfig.OverrideProperties{}
+
+	// fpmTimeoutRegexp matches a php-fpm time value, which is a number of seconds, minutes or hours.
+	fpmTimeoutRegexp = regexp.MustCompile(`^[0-9]+[smh]?$`)
 )
 
 func main() {
@@ -78,10 +108,26 @@ func buildFn(ctx *gcp.Context) error {
 		if err != nil {
 			return err
 		}
+		unknown, err := appyaml.UnknownPhpRuntimeConfigKeys(ctx.ApplicationRoot())
+		if err != nil {
+			return err
+		}
+		if err := webconfig.CheckUnknownKeys(ctx, "app.yaml runtime_config", unknown, appyaml.PhpRuntimeConfigKeys()); err != nil {
+			return err
+		}
 		overrides = webconfig.OverriddenProperties(ctx, runtimeConfig)
-		webconfig.SetEnvVariables(l, overrides)
 	}
 
+	extra, unknown, err := php.ReadComposerExtra(ctx)
+	if err != nil {
+		return err
+	}
+	if err := webconfig.CheckUnknownKeys(ctx, fmt.Sprintf("%q composer extra", php.ComposerExtraKey), unknown, php.ComposerExtraKeys()); err != nil {
+		return err
+	}
+	overrides = webconfig.MergeComposerExtra(overrides, extra)
+	webconfig.SetEnvVariables(l, overrides)
+
 	if customNginxConf, present := os.LookupEnv(php.CustomNginxConfig); present {
 		overrides.NginxConfOverride = true
 		overrides.NginxConfOverrideFileName = filepath.Join(defaultRoot, customNginxConf)
@@ -92,29 +138,88 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 	overrides.NginxServesStaticFiles = nginxServesStaticFiles
+	recordSettings(ctx, overrides, extra)
+	if err := applyFrameworkDefaults(ctx, &overrides); err != nil {
+		return err
+	}
+	if err := applyDevMode(ctx, &overrides, extra); err != nil {
+		return err
+	}
+	if err := linkWordPressUploads(ctx, overrides); err != nil {
+		return err
+	}
+
+	if err := webconfig.ValidateRoutes(overrides.Routes); err != nil {
+		return err
+	}
+	if len(overrides.Routes) > 0 && (overrides.NginxConfOverride || (overrides.Server != "" && overrides.Server != php.ServerNginx)) {
+		ctx.Warnf("Ignoring the %d routes, which only apply to the nginx config generated by the buildpack", len(overrides.Routes))
+	}
+
+	if err := webconfig.ValidateKeepalive(overrides); err != nil {
+		return err
+	}
+	if err := webconfig.ValidateTLS(overrides); err != nil {
+		return err
+	}
+	if ignored := nginxOnlySettings(overrides); len(ignored) > 0 && (overrides.NginxConfOverride || (overrides.Server != "" && overrides.Server != php.ServerNginx)) {
+		ctx.Warnf("Ignoring %s, which only apply to the nginx config generated by the buildpack", strings.Join(ignored, ", "))
+	}
+
+	if err := webconfig.ValidatePHPIni(overrides.PHPIniDirectives); err != nil {
+		return err
+	}
+	if err := configurePHPIni(ctx, l, overrides); err != nil {
+		return err
+	}
+	if err := configurePreload(ctx, l, overrides); err != nil {
+		return err
+	}
+
+	if overrides.Server != "" && overrides.Server != php.ServerNginx {
+		return configureAppServer(ctx, l, overrides)
+	}
+
+	if err := expandOverrides(ctx, l, &overrides); err != nil {
+		return err
+	}
+	if err := configureTLS(ctx, l, overrides); err != nil {
+		return err
+	}
 
 	fpmConfFile, err := writeFpmConfig(ctx, l.Path, overrides)
 	if err != nil {
 		return err
 	}
 	defer fpmConfFile.Close()
+	if err := configureFPMWorkers(ctx, l, overrides); err != nil {
+		return err
+	}
 
-	nginxServerConfFile, err := writeNginxServerConfig(l.Path, overrides)
+	nginxServerConfFile, err := writeNginxServerConfig(l.Path, ctx.ApplicationRoot(), overrides)
 	if err != nil {
 		return err
 	}
 	defer nginxServerConfFile.Close()
 
-	procExists, err := ctx.FileExists("Procfile")
+	customEntrypoint, err := hasCustomEntrypoint(ctx)
 	if err != nil {
 		return err
 	}
-	_, entrypointExists := os.LookupEnv(env.Entrypoint)
 
-	if !procExists && !entrypointExists {
+	if !customEntrypoint {
+		if err := testConfigOverrides(ctx, fpmConfFile.Name(), overrides); err != nil {
+			return err
+		}
+		nginxBinary := defaultNginxBinary
+		if overrides.Brotli && !overrides.NginxConfOverride {
+			if nginxBinary, err = nginx.WriteBrotliLoader(l.Path, defaultNginxBinary, nginxModulesDir, filepath.Join(l.Path, nginxConf)); err != nil {
+				return gcp.InternalErrorf("writing brotli loader: %w", err)
+			}
+		}
 		cmd := []string{
 			filepath.Join(os.Getenv("PID1_DIR"), "pid1"),
-			"--nginxBinaryPath", defaultNginxBinary,
+			"--nginxBinaryPath", nginxBinary,
 			"--nginxErrLogFilePath", filepath.Join(l.Path, nginxLog),
 			"--customAppCmd", fmt.Sprintf("%q", fmt.Sprintf("%s -R --nodaemonize --fpm-config %s", defaultFPMBinary, fpmConfFile.Name())),
 			"--pid1LogFilePath", filepath.Join(l.Path, pid1Log),
@@ -134,6 +239,623 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// applyFrameworkDefaults sets the document root to the one of the framework the application uses,
+// unless it is configured. WordPress applications get the nginx config of WordPress and larger
+// uploads, unless their size is configured.
+func applyFrameworkDefaults(ctx *gcp.Context, overrides *webconfig.OverrideProperties) error {
+	framework, err := php.DetectFramework(ctx)
+	if err != nil || framework == nil {
+		return err
+	}
+	if overrides.DocumentRoot == "" && framework.DocumentRoot != "" {
+		ctx.Logf("Detected %s, serving %s", framework.Name, framework.DocumentRoot)
+		overrides.DocumentRoot = framework.DocumentRoot
+		ctx.RecordSetting("document root", filepath.Join(defaultRoot, framework.DocumentRoot), gcp.SourceFramework)
+	}
+	if framework.Name != php.WordPress.Name {
+		return nil
+	}
+	ctx.Logf("Detected WordPress, routing the permalinks to %s", defaultFrontController)
+	overrides.WordPress = true
+	setIniDefaults(ctx, overrides, map[string]string{
+		"upload_max_filesize": wordpressUploadSize,
+		"post_max_size":       wordpressUploadSize,
+	}, gcp.SourceFramework)
+	return nil
+}
+
+// applyDevMode enables the development mode if it is set with php.DevModeEnv or dev_mode, which
+// adds php.DevModeIniDirectives unless they are configured. Xdebug is installed by the runtime
+// buildpack.
+func applyDevMode(ctx *gcp.Context, overrides *webconfig.OverrideProperties, extra php.ComposerExtra) error {
+	enabled, err := webconfig.DevMode(overrides.DevMode)
+	if err != nil {
+		return err
+	}
+	_, fromEnv := os.LookupEnv(php.DevModeEnv)
+	source := gcp.SourceDefault
+	switch {
+	case fromEnv:
+		source = gcp.SourceEnv
+	case extra.DevMode:
+		source = gcp.SourceComposerExtra
+	case overrides.DevMode:
+		source = gcp.SourceAppYAML
+	}
+	overrides.DevMode = enabled
+	ctx.RecordSetting("dev mode", strconv.FormatBool(enabled), source)
+	if !enabled {
+		return nil
+	}
+	ctx.Warnf("Development mode is enabled, errors are shown in the responses and Xdebug is loaded; do not serve production traffic with it")
+	setIniDefaults(ctx, overrides, php.DevModeIniDirectives, source)
+	return nil
+}
+
+// setIniDefaults adds the php.ini directives of defaults to overrides unless they are configured,
+// and records them with source.
+func setIniDefaults(ctx *gcp.Context, overrides *webconfig.OverrideProperties, defaults map[string]string, source string) {
+	// The directives may be shared with the app.yaml runtime_config.
+	directives := make(map[string]string, len(overrides.PHPIniDirectives)+len(defaults))
+	for name, value := range overrides.PHPIniDirectives {
+		directives[name] = value
+	}
+	var names []string
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := directives[name]; ok {
+			continue
+		}
+		directives[name] = defaults[name]
+		ctx.RecordSetting("php.ini "+name, defaults[name], source)
+	}
+	overrides.PHPIniDirectives = directives
+}
+
+// linkWordPressUploads replaces wp-content/uploads of WordPress applications with a link to the
+// mounted volume set with wordpress_uploads_dir, so that the uploads are written to and served from
+// the volume. The link dangles during the build, the volume is only mounted at launch.
+func linkWordPressUploads(ctx *gcp.Context, overrides webconfig.OverrideProperties) error {
+	dir := overrides.WordPressUploadsDir
+	if dir == "" {
+		return nil
+	}
+	if !overrides.WordPress {
+		ctx.Warnf("Ignoring wordpress_uploads_dir %s, the application is not WordPress", dir)
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		return gcp.UserErrorf("invalid wordpress_uploads_dir %q, it must be the absolute path of a mounted volume", dir)
+	}
+	uploads := filepath.Join(ctx.ApplicationRoot(), wordpressUploads)
+	fi, err := os.Lstat(uploads)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return gcp.InternalErrorf("stat %s: %v", uploads, err)
+	case fi.Mode()&os.ModeSymlink != 0:
+		if err := ctx.RemoveAll(uploads); err != nil {
+			return err
+		}
+	case fi.IsDir():
+		files, err := ctx.ReadDir(uploads)
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 {
+			return gcp.UserErrorf("%s is not empty, move its files to the volume mounted at %s and remove it from the application", wordpressUploads, dir)
+		}
+		if err := ctx.RemoveAll(uploads); err != nil {
+			return err
+		}
+	default:
+		return gcp.UserErrorf("%s is not a directory", wordpressUploads)
+	}
+	if err := ctx.MkdirAll(filepath.Dir(uploads), 0755); err != nil {
+		return err
+	}
+	if err := ctx.Symlink(dir, uploads); err != nil {
+		return err
+	}
+	ctx.Logf("Linked %s to %s", wordpressUploads, dir)
+	return nil
+}
+
+// nginxOnlySettings returns the names of the settings which are set and only apply to the nginx
+// config generated by the buildpack.
+func nginxOnlySettings(overrides webconfig.OverrideProperties) []string {
+	var names []string
+	for _, s := range []struct {
+		name string
+		set  bool
+	}{
+		{"gzip", overrides.Gzip},
+		{"brotli", overrides.Brotli},
+		{"http2", overrides.HTTP2},
+		{"keepalive_timeout", overrides.KeepaliveTimeout != ""},
+		{"keepalive_requests", overrides.KeepaliveRequests != ""},
+		{"tls_certificate", overrides.TLSCertificateFileName != ""},
+	} {
+		if s.set {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// configureTLS sets the launch env vars with the paths of the TLS certificate and key, and writes
+// the exec.d executable which includes them in the nginx config at launch. The executable is
+// removed from the cached layer when TLS is not configured.
+func configureTLS(ctx *gcp.Context, l *libcnb.Layer, overrides webconfig.OverrideProperties) error {
+	if overrides.TLSCertificateFileName == "" || overrides.NginxConfOverride {
+		return ctx.RemoveAll(filepath.Join(l.Exec.Path, nginx.TLSExecD))
+	}
+	port := tlsPort(overrides)
+	if port == defaultNginxPort {
+		return gcp.UserErrorf("tls_port must not be %d, which serves plain HTTP", defaultNginxPort)
+	}
+	for _, f := range []string{overrides.TLSCertificateFileName, overrides.TLSCertificateKeyFileName} {
+		// Files outside the application, such as secrets, are mounted at deploy time.
+		if !strings.HasPrefix(f, defaultRoot+"/") {
+			continue
+		}
+		exists, err := ctx.FileExists(f)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return gcp.UserErrorf("TLS file %s does not exist in the application", f)
+		}
+	}
+	// The exclude-secrets buildpack keeps the files the launch environment refers to in the image.
+	l.LaunchEnvironment.Default(nginx.TLSCertificateEnv, overrides.TLSCertificateFileName)
+	l.LaunchEnvironment.Default(nginx.TLSCertificateKeyEnv, overrides.TLSCertificateKeyFileName)
+	if err := nginx.WriteTLSExecD(l.Exec.Path); err != nil {
+		return gcp.InternalErrorf("writing nginx TLS exec.d: %w", err)
+	}
+	ctx.Logf("Terminating TLS on port %d, override the certificate with %s and %s", port, nginx.TLSCertificateEnv, nginx.TLSCertificateKeyEnv)
+	return nil
+}
+
+// expandOverrides writes the user-provided nginx and php-fpm config files which contain
+// placeholders to the layer with the placeholders expanded, and points overrides at the expanded
+// files, so that the same files work without the paths of the build hard-coded. The PORT
+// placeholder is only known at launch, the files which contain it are written to
+// webconfig.LaunchOverridesDir by an exec.d executable. They are also written there with
+// webconfig.DefaultPort during the build, so that they can be checked.
+func expandOverrides(ctx *gcp.Context, l *libcnb.Layer, overrides *webconfig.OverrideProperties) error {
+	dir := filepath.Join(l.Path, overridesDir)
+	if err := ctx.RemoveAll(dir); err != nil {
+		return err
+	}
+	root := defaultRoot
+	if overrides.DocumentRoot != "" {
+		root = filepath.Join(defaultRoot, overrides.DocumentRoot)
+	}
+	socket := filepath.Join(l.Path, appSocket)
+	if env.IsFlex() {
+		socket = defaultFlexAddress
+	}
+	values := map[string]string{
+		webconfig.PlaceholderDocumentRoot: root,
+		webconfig.PlaceholderPHPFPMSocket: socket,
+	}
+	var templates []string
+	for _, f := range []struct {
+		set  bool
+		path *string
+		name string
+	}{
+		{overrides.NginxConfOverride, &overrides.NginxConfOverrideFileName, "nginx.conf"},
+		{overrides.NginxServerConfInclude, &overrides.NginxServerConfIncludeFileName, "nginx-app.conf"},
+		{overrides.NginxHTTPInclude, &overrides.NginxHTTPIncludeFileName, "nginx-http.conf"},
+		{overrides.PHPFPMOverride, &overrides.PHPFPMOverrideFileName, "php-fpm.conf"},
+	} {
+		if !f.set {
+			continue
+		}
+		content, err := os.ReadFile(*f.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return gcp.UserErrorf("reading %s: %v", *f.path, err)
+		}
+		expanded, ok := webconfig.ExpandPlaceholders(string(content), values)
+		withPort, hasPort := webconfig.ExpandPlaceholders(expanded, map[string]string{webconfig.PlaceholderPort: webconfig.DefaultPort})
+		if !ok && !hasPort {
+			continue
+		}
+		if err := ctx.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, f.name)
+		if err := ctx.WriteFile(path, []byte(expanded), 0644); err != nil {
+			return err
+		}
+		if !hasPort {
+			ctx.Logf("Expanded the placeholders of %s into %s", *f.path, path)
+			*f.path = path
+			continue
+		}
+		templates = append(templates, path)
+		if err := ctx.MkdirAll(webconfig.LaunchOverridesDir, 0755); err != nil {
+			return err
+		}
+		launchPath := filepath.Join(webconfig.LaunchOverridesDir, f.name)
+		if err := ctx.WriteFile(launchPath, []byte(withPort), 0644); err != nil {
+			return err
+		}
+		ctx.Logf("Expanded the placeholders of %s into %s, ${%s} is expanded at launch", *f.path, launchPath, webconfig.PlaceholderPort)
+		*f.path = launchPath
+	}
+	execD := filepath.Join(l.Exec.Path, webconfig.PortExecD)
+	if len(templates) == 0 {
+		return ctx.RemoveAll(execD)
+	}
+	if err := webconfig.WritePortExecD(l.Exec.Path, templates); err != nil {
+		return gcp.InternalErrorf("writing %s exec.d: %w", webconfig.PortExecD, err)
+	}
+	return nil
+}
+
+// testConfigOverrides checks the user-provided nginx config and the php-fpm config including the
+// user-provided one with `nginx -t` and `php-fpm -t`, so that an invalid config fails the build
+// with the offending line instead of the container at launch.
+func testConfigOverrides(ctx *gcp.Context, fpmConf string, overrides webconfig.OverrideProperties) error {
+	if overrides.NginxConfOverride {
+		bin, err := exec.LookPath(defaultNginxBinary)
+		if err != nil {
+			ctx.Warnf("Skipping the check of %s, nginx is not installed: %v", overrides.NginxConfOverrideFileName, err)
+		} else {
+			// Log to stderr, as the default error log may not be writable during the build.
+			cmd := []string{bin, "-t", "-q", "-e", "stderr", "-c", overrides.NginxConfOverrideFileName}
+			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithMessageProducer(func(r *gcp.ExecResult) string {
+				return fmt.Sprintf("invalid nginx config %s: %s", overrides.NginxConfOverrideFileName, nginx.NginxTestError(r.Combined))
+			})); err != nil {
+				return err
+			}
+		}
+	}
+	if overrides.PHPFPMOverride {
+		bin, err := fpmBinary()
+		if err != nil {
+			ctx.Warnf("Skipping the check of %s, php-fpm is not installed: %v", overrides.PHPFPMOverrideFileName, err)
+			return nil
+		}
+		cmd := []string{bin, "-t", "-R", "--fpm-config", fpmConf}
+		// The workers sized at launch are read from an env var which is only set at launch.
+		if _, err := ctx.Exec(cmd, gcp.WithEnv(nginx.FPMMaxChildrenEnv+"=1"), gcp.WithUserAttribution, gcp.WithMessageProducer(func(r *gcp.ExecResult) string {
+			return fmt.Sprintf("invalid php-fpm config %s: %s", overrides.PHPFPMOverrideFileName, nginx.FPMTestError(r.Combined))
+		})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fpmBinary returns the path of php-fpm, which is only on the PATH at launch, next to the bin
+// directory of php during the build.
+func fpmBinary() (string, error) {
+	if bin, err := exec.LookPath(defaultFPMBinary); err == nil {
+		return bin, nil
+	}
+	phpBin, err := exec.LookPath("php")
+	if err != nil {
+		return "", err
+	}
+	bin := filepath.Join(filepath.Dir(filepath.Dir(phpBin)), "sbin", defaultFPMBinary)
+	if _, err := os.Stat(bin); err != nil {
+		return "", err
+	}
+	return bin, nil
+}
+
+// configureFPMWorkers writes the exec.d executable which sizes the php-fpm workers to the memory
+// and CPU of the container at launch, unless the number of workers is set. The executable is
+// removed from the cached layer otherwise.
+func configureFPMWorkers(ctx *gcp.Context, l *libcnb.Layer, overrides webconfig.OverrideProperties) error {
+	if overrides.PHPFPMWorkers != "" {
+		return ctx.RemoveAll(filepath.Join(l.Exec.Path, nginx.FPMWorkersExecD))
+	}
+	if overrides.PHPFPMWorkerMemory != "" {
+		l.LaunchEnvironment.Default(nginx.FPMWorkerMemoryEnv, overrides.PHPFPMWorkerMemory)
+	}
+	if err := nginx.WriteFPMWorkersExecD(l.Exec.Path); err != nil {
+		return gcp.InternalErrorf("writing php-fpm workers exec.d: %w", err)
+	}
+	ctx.Logf("Sizing the php-fpm workers to the container at launch, override them with %s", nginx.FPMMaxChildrenEnv)
+	return nil
+}
+
+// configurePreload writes the ini file which preloads the classes of the application into OPcache
+// when PHP starts, and adds its directory to the ini directories of PHP at launch.
+func configurePreload(ctx *gcp.Context, l *libcnb.Layer, overrides webconfig.OverrideProperties) error {
+	dir := filepath.Join(l.Path, opcacheDir)
+	if overrides.OPcachePreload == "" {
+		if err := ctx.RemoveAll(dir); err != nil {
+			return err
+		}
+		return ctx.RemoveAll(l.Path, phpIniDir, preloadIni)
+	}
+	script := overrides.OPcachePreload
+	if script == php.PreloadClassmap {
+		classmap := filepath.Join(ctx.ApplicationRoot(), php.ComposerClassmap)
+		exists, err := ctx.FileExists(classmap)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return gcp.UserErrorf("opcache_preload is %q but %s does not exist, install the dependencies with composer and --optimize-autoloader", php.PreloadClassmap, php.ComposerClassmap)
+		}
+		script = filepath.Join(dir, preloadScript)
+		if err := ctx.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := ctx.WriteFile(script, []byte(php.ClassmapPreloadScript(defaultRoot)), 0644); err != nil {
+			return err
+		}
+	} else {
+		exists, err := ctx.FileExists(script)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return gcp.UserErrorf("opcache_preload script %s does not exist", script)
+		}
+	}
+
+	// FrankenPHP embeds its own PHP with OPcache built in.
+	loadExtension := false
+	if overrides.Server != php.ServerFrankenPHP {
+		result, err := ctx.Exec([]string{"php", "-r", "echo extension_loaded('Zend OPcache') ? 'yes' : 'no';"})
+		if err != nil {
+			return err
+		}
+		loadExtension = strings.TrimSpace(result.Stdout) != "yes"
+	}
+	// Preloading runs as this user when PHP starts as root. The run image has the same users as the
+	// build image.
+	var username string
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	if err := writePHPIni(ctx, l, preloadIni, php.PreloadIni(script, username, loadExtension)); err != nil {
+		return err
+	}
+	ctx.Logf("Preloading %s into OPcache", script)
+	return nil
+}
+
+// configurePHPIni writes the php_ini directives to an ini file which PHP reads after php.ini.
+func configurePHPIni(ctx *gcp.Context, l *libcnb.Layer, overrides webconfig.OverrideProperties) error {
+	if len(overrides.PHPIniDirectives) == 0 {
+		return ctx.RemoveAll(l.Path, phpIniDir, directivesIni)
+	}
+	return writePHPIni(ctx, l, directivesIni, php.IniContent(overrides.PHPIniDirectives))
+}
+
+// writePHPIni writes an ini file to the directory which is added to the ini directories of PHP at
+// launch.
+func writePHPIni(ctx *gcp.Context, l *libcnb.Layer, name, content string) error {
+	dir := filepath.Join(l.Path, phpIniDir)
+	if err := ctx.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ctx.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		return err
+	}
+	l.LaunchEnvironment.Default(php.IniScanDirEnv, string(filepath.ListSeparator)+dir)
+	return nil
+}
+
+// tlsPort returns the port nginx terminates TLS on.
+func tlsPort(overrides webconfig.OverrideProperties) int {
+	// TLSPort is checked by webconfig.ValidateTLS.
+	if port, err := strconv.Atoi(overrides.TLSPort); err == nil {
+		return port
+	}
+	return defaultTLSPort
+}
+
+// hasCustomEntrypoint returns true if the web process is set with a Procfile or env.Entrypoint.
+func hasCustomEntrypoint(ctx *gcp.Context) (bool, error) {
+	procExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return false, err
+	}
+	_, entrypointExists := os.LookupEnv(env.Entrypoint)
+	return procExists || entrypointExists, nil
+}
+
+// configureAppServer installs FrankenPHP or RoadRunner, writes its config to the webconfig layer
+// and runs the app under it instead of nginx and php-fpm.
+func configureAppServer(ctx *gcp.Context, l *libcnb.Layer, overrides webconfig.OverrideProperties) error {
+	if !slices.Contains(php.AppServers, overrides.Server) {
+		return gcp.UserErrorf("invalid server %q, it must be one of %s", overrides.Server, strings.Join(php.AppServers, ", "))
+	}
+	cfg, err := appServerConfig(ctx, overrides)
+	if err != nil {
+		return err
+	}
+	name, content, err := php.AppServerConfigFile(overrides.Server, cfg)
+	if err != nil {
+		return err
+	}
+	confPath := filepath.Join(l.Path, name)
+	if err := ctx.WriteFile(confPath, content, 0644); err != nil {
+		return err
+	}
+
+	sl, err := ctx.Layer(overrides.Server, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	binPath, err := php.InstallAppServer(ctx, sl, overrides.Server)
+	if err != nil {
+		return err
+	}
+
+	customEntrypoint, err := hasCustomEntrypoint(ctx)
+	if err != nil || customEntrypoint {
+		return err
+	}
+	ctx.AddProcess(gcp.WebProcess, php.AppServerCommand(overrides.Server, binPath, confPath), gcp.AsDirectProcess(), gcp.AsDefaultProcess())
+	return nil
+}
+
+// appServerConfig returns the config of FrankenPHP or RoadRunner for the overrides.
+func appServerConfig(ctx *gcp.Context, overrides webconfig.OverrideProperties) (php.AppServerConfig, error) {
+	cfg := php.AppServerConfig{
+		Root:            defaultRoot,
+		FrontController: defaultFrontController,
+		Worker:          overrides.WorkerScriptFileName,
+	}
+	if overrides.DocumentRoot != "" {
+		cfg.Root = filepath.Join(defaultRoot, overrides.DocumentRoot)
+	}
+	if overrides.FrontController != "" {
+		cfg.FrontController = overrides.FrontController
+	}
+	if cfg.Worker == "" {
+		worker, err := php.DefaultWorker(ctx, overrides.Server)
+		if err != nil {
+			return php.AppServerConfig{}, err
+		}
+		if worker != "" {
+			ctx.Logf("Running the Laravel Octane worker %s under %s", worker, overrides.Server)
+		}
+		cfg.Worker = worker
+	}
+	cfg.Octane = php.IsOctaneWorker(ctx, cfg.Worker)
+	return cfg, nil
+}
+
+// recordSettings records the effective web server settings and the source each one came from.
+func recordSettings(ctx *gcp.Context, overrides webconfig.OverrideProperties, extra php.ComposerExtra) {
+	switch {
+	case extra.DocumentRoot != "":
+		ctx.RecordSetting("document root", filepath.Join(defaultRoot, overrides.DocumentRoot), gcp.SourceComposerExtra)
+	case overrides.DocumentRoot != "":
+		ctx.RecordSetting("document root", filepath.Join(defaultRoot, overrides.DocumentRoot), gcp.SourceAppYAML)
+	default:
+		ctx.RecordSetting("document root", defaultRoot, gcp.SourceDefault)
+	}
+	switch {
+	case extra.FrontControllerFile != "":
+		ctx.RecordSetting("front controller", overrides.FrontController, gcp.SourceComposerExtra)
+	case overrides.FrontController != "":
+		ctx.RecordSetting("front controller", overrides.FrontController, gcp.SourceAppYAML)
+	default:
+		ctx.RecordSetting("front controller", defaultFrontController, gcp.SourceDefault)
+	}
+	switch {
+	case extra.PHPFPMWorkers != "":
+		ctx.RecordSetting("php-fpm workers", overrides.PHPFPMWorkers, gcp.SourceComposerExtra)
+	case overrides.PHPFPMWorkers != "":
+		ctx.RecordSetting("php-fpm workers", overrides.PHPFPMWorkers, gcp.SourceAppYAML)
+	default:
+		ctx.RecordSetting("php-fpm workers", "auto", gcp.SourceDefault)
+	}
+	switch {
+	case extra.PHPFPMSlowlogTimeout != "":
+		ctx.RecordSetting("php-fpm slowlog timeout", overrides.PHPFPMSlowlogTimeout, gcp.SourceComposerExtra)
+	case overrides.PHPFPMSlowlogTimeout != "":
+		ctx.RecordSetting("php-fpm slowlog timeout", overrides.PHPFPMSlowlogTimeout, gcp.SourceAppYAML)
+	}
+	if _, present := os.LookupEnv(php.NginxServesStaticFiles); present {
+		ctx.RecordSetting(php.NginxServesStaticFiles, strconv.FormatBool(overrides.NginxServesStaticFiles), gcp.SourceEnv)
+	} else {
+		ctx.RecordSetting(php.NginxServesStaticFiles, "false", gcp.SourceDefault)
+	}
+	switch {
+	case extra.Server != "":
+		ctx.RecordSetting("server", overrides.Server, gcp.SourceComposerExtra)
+	case overrides.Server != "":
+		ctx.RecordSetting("server", overrides.Server, gcp.SourceAppYAML)
+	default:
+		ctx.RecordSetting("server", php.ServerNginx, gcp.SourceDefault)
+	}
+	for _, c := range []struct {
+		name          string
+		extra, config bool
+	}{
+		{"gzip", extra.Gzip, overrides.Gzip},
+		{"brotli", extra.Brotli, overrides.Brotli},
+		{"http2", extra.HTTP2, overrides.HTTP2},
+	} {
+		switch {
+		case c.extra:
+			ctx.RecordSetting(c.name, "true", gcp.SourceComposerExtra)
+		case c.config:
+			ctx.RecordSetting(c.name, "true", gcp.SourceAppYAML)
+		}
+	}
+	switch {
+	case extra.KeepaliveTimeout != "":
+		ctx.RecordSetting("keepalive timeout", overrides.KeepaliveTimeout, gcp.SourceComposerExtra)
+	case overrides.KeepaliveTimeout != "":
+		ctx.RecordSetting("keepalive timeout", overrides.KeepaliveTimeout, gcp.SourceAppYAML)
+	}
+	switch {
+	case extra.KeepaliveRequests != "":
+		ctx.RecordSetting("keepalive requests", overrides.KeepaliveRequests, gcp.SourceComposerExtra)
+	case overrides.KeepaliveRequests != "":
+		ctx.RecordSetting("keepalive requests", overrides.KeepaliveRequests, gcp.SourceAppYAML)
+	}
+	switch {
+	case extra.TLSCertificate != "":
+		ctx.RecordSetting("tls certificate", overrides.TLSCertificateFileName, gcp.SourceComposerExtra)
+	case overrides.TLSCertificateFileName != "":
+		ctx.RecordSetting("tls certificate", overrides.TLSCertificateFileName, gcp.SourceAppYAML)
+	}
+	switch {
+	case extra.TLSPort != "":
+		ctx.RecordSetting("tls port", overrides.TLSPort, gcp.SourceComposerExtra)
+	case overrides.TLSPort != "":
+		ctx.RecordSetting("tls port", overrides.TLSPort, gcp.SourceAppYAML)
+	case overrides.TLSCertificateFileName != "":
+		ctx.RecordSetting("tls port", strconv.Itoa(defaultTLSPort), gcp.SourceDefault)
+	}
+	switch {
+	case extra.OPcachePreload != "":
+		ctx.RecordSetting("opcache preload", overrides.OPcachePreload, gcp.SourceComposerExtra)
+	case overrides.OPcachePreload != "":
+		ctx.RecordSetting("opcache preload", overrides.OPcachePreload, gcp.SourceAppYAML)
+	}
+	switch {
+	case extra.WordPressUploadsDir != "":
+		ctx.RecordSetting("wordpress uploads dir", overrides.WordPressUploadsDir, gcp.SourceComposerExtra)
+	case overrides.WordPressUploadsDir != "":
+		ctx.RecordSetting("wordpress uploads dir", overrides.WordPressUploadsDir, gcp.SourceAppYAML)
+	}
+	var iniNames []string
+	for name := range overrides.PHPIniDirectives {
+		iniNames = append(iniNames, name)
+	}
+	sort.Strings(iniNames)
+	for _, name := range iniNames {
+		source := gcp.SourceAppYAML
+		if _, ok := extra.PHPIni[name]; ok {
+			source = gcp.SourceComposerExtra
+		}
+		ctx.RecordSetting("php.ini "+name, overrides.PHPIniDirectives[name], source)
+	}
+	_, customNginxConf := os.LookupEnv(php.CustomNginxConfig)
+	switch {
+	case customNginxConf:
+		ctx.RecordSetting("nginx config", overrides.NginxConfOverrideFileName, gcp.SourceEnv)
+	case extra.NginxConfOverride != "":
+		ctx.RecordSetting("nginx config", overrides.NginxConfOverrideFileName, gcp.SourceComposerExtra)
+	case overrides.NginxConfOverride:
+		ctx.RecordSetting("nginx config", overrides.NginxConfOverrideFileName, gcp.SourceAppYAML)
+	}
+}
+
 func getInstalledPhpVersion(ctx *gcp.Context) (string, error) {
 	version, err := php.ExtractVersion(ctx)
 	if err != nil {
@@ -195,7 +917,6 @@ func fpmConfig(layer string, addNoDecorateWorkers bool, overrides webconfig.Over
 
 	fpm := nginx.FPMConfig{
 		PidPath:              filepath.Join(layer, phpFpmPid),
-		NumWorkers:           defaultFPMWorkers,
 		ListenAddress:        filepath.Join(layer, appSocket),
 		DynamicWorkers:       defaultDynamicWorkers,
 		Username:             user.Username,
@@ -210,9 +931,64 @@ func fpmConfig(layer string, addNoDecorateWorkers bool, overrides webconfig.Over
 		fpm.ConfOverride = overrides.PHPFPMOverrideFileName
 	}
 
+	if err := setWorkers(&fpm, overrides); err != nil {
+		return nginx.FPMConfig{}, err
+	}
+	if err := setSlowlog(&fpm, overrides); err != nil {
+		return nginx.FPMConfig{}, err
+	}
+
 	return fpm, nil
 }
 
+// setWorkers sets the number of php-fpm workers of fpm, or reads them from the env var set at
+// launch by the exec.d executable which sizes them to the container if they are not set.
+func setWorkers(fpm *nginx.FPMConfig, overrides webconfig.OverrideProperties) error {
+	if m := overrides.PHPFPMWorkerMemory; m != "" {
+		if n, err := strconv.Atoi(m); err != nil || n <= 0 {
+			return gcp.UserErrorf("invalid php_fpm_worker_memory %q, it must be a positive number of MiB", m)
+		}
+	}
+	workers := overrides.PHPFPMWorkers
+	if workers == "" {
+		fpm.WorkersEnv = nginx.FPMMaxChildrenEnv
+		return nil
+	}
+	n, err := strconv.Atoi(workers)
+	if err != nil || n <= 0 {
+		return gcp.UserErrorf("invalid php_fpm_workers %q, it must be a positive number", workers)
+	}
+	fpm.NumWorkers = n
+	return nil
+}
+
+// setSlowlog enables the php-fpm slow log of fpm if a slow log timeout other than 0 is set.
+func setSlowlog(fpm *nginx.FPMConfig, overrides webconfig.OverrideProperties) error {
+	timeout := overrides.PHPFPMSlowlogTimeout
+	if timeout == "" {
+		return nil
+	}
+	if !fpmTimeoutRegexp.MatchString(timeout) {
+		return gcp.UserErrorf("invalid php_fpm_slowlog_timeout %q, it must be a number of seconds optionally followed by s, m or h, such as 5s", timeout)
+	}
+	if n, _ := strconv.Atoi(strings.TrimRight(timeout, "smh")); n == 0 {
+		return nil
+	}
+	fpm.SlowlogTimeout = timeout
+	fpm.Slowlog = defaultSlowlog
+	if overrides.PHPFPMSlowlogFileName != "" {
+		fpm.Slowlog = overrides.PHPFPMSlowlogFileName
+	}
+	if depth := overrides.PHPFPMSlowlogTraceDepth; depth != "" {
+		d, err := strconv.Atoi(depth)
+		if err != nil || d <= 0 {
+			return gcp.UserErrorf("invalid php_fpm_slowlog_trace_depth %q, it must be a positive number", depth)
+		}
+		fpm.SlowlogTraceDepth = d
+	}
+	return nil
+}
+
 func addNginxConfCmdArgs(path, nginxServerConfFileName string, overrides webconfig.OverrideProperties) ([]string, error) {
 	var args []string
 	if env.IsFlex() {
@@ -264,10 +1040,45 @@ func nginxConfig(layer string, overrides webconfig.OverrideProperties) nginx.Con
 		nginx.NginxConfInclude = overrides.NginxServerConfIncludeFileName
 	}
 
+	nginx.Routes = nginxRoutes(overrides.Routes)
+	nginx.Gzip = overrides.Gzip
+	nginx.Brotli = overrides.Brotli
+	nginx.HTTP2 = overrides.HTTP2
+	nginx.KeepaliveTimeout = overrides.KeepaliveTimeout
+	// KeepaliveRequests is checked by webconfig.ValidateKeepalive.
+	nginx.KeepaliveRequests, _ = strconv.Atoi(overrides.KeepaliveRequests)
+	if overrides.TLSCertificateFileName != "" {
+		nginx.TLSPort = tlsPort(overrides)
+	}
+	if overrides.WordPress {
+		nginx.WordPress = true
+		// The uploaded files are in the request body.
+		nginx.ClientMaxBodySize = overrides.PHPIniDirectives["post_max_size"]
+	}
+
 	return nginx
 }
 
-func writeNginxServerConfig(path string, overrides webconfig.OverrideProperties) (*os.File, error) {
+// nginxRoutes returns the routes with the directories of static routes resolved against the
+// application root.
+func nginxRoutes(routes []nginx.Route) []nginx.Route {
+	var resolved []nginx.Route
+	for _, r := range routes {
+		if r.Dir != "" {
+			r.Dir = filepath.Join(defaultRoot, r.Dir)
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved
+}
+
+func writeNginxServerConfig(path, appDir string, overrides webconfig.OverrideProperties) (*os.File, error) {
 	conf := nginxConfig(path, overrides)
+	buildInfoFile, buildID, err := nginx.BuildInfoConfig(path, appDir, time.Now())
+	if err != nil {
+		return nil, gcp.UserErrorf("writing build info: %v", err)
+	}
+	conf.BuildInfoFile = buildInfoFile
+	conf.BuildID = buildID
 	return nginx.WriteNginxConfigToPath(path, conf)
 }
@@ -28,7 +28,10 @@ import (
 )
 
 const (
-	layerName = "pip"
+	layerName                = "pip"
+	wheelsLayerName          = "pip-wheels"
+	systemPackagesLayerName  = "system-packages"
+	systemLibrariesLayerName = "system-libraries"
 )
 
 // metadata represents metadata stored for a dependencies layer.
@@ -76,7 +79,36 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
 	}
 
-	if err := python.InstallRequirements(ctx, l, reqs...); err != nil {
+	pkgs, err := python.SystemPackages(ctx, reqs...)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) > 0 {
+		sl, err := ctx.Layer(systemPackagesLayerName, gcp.BuildLayer, gcp.CacheLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", systemPackagesLayerName, err)
+		}
+		rl, err := ctx.Layer(systemLibrariesLayerName, gcp.CacheLayer, gcp.LaunchLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", systemLibrariesLayerName, err)
+		}
+		if err := python.InstallSystemPackages(ctx, sl, rl, pkgs); err != nil {
+			return err
+		}
+	}
+
+	var wl *libcnb.Layer
+	if len(reqs) > 0 {
+		wl, err = ctx.Layer(wheelsLayerName, gcp.CacheLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", wheelsLayerName, err)
+		}
+		if err := python.PrepareWheelCache(ctx, wl, reqs...); err != nil {
+			return err
+		}
+	}
+
+	if err := python.InstallRequirements(ctx, l, wl, reqs...); err != nil {
 		return fmt.Errorf("installing dependencies: %w", err)
 	}
 
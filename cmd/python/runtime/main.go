@@ -40,6 +40,16 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	result, err := detectPython(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The static assets of hybrid apps are built by the runtime selected with
+	// GOOGLE_ASSETS_RUNTIME before Python.
+	return runtime.RequireAssets(ctx, result)
+}
+
+func detectPython(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if flex.NeedsSupervisorPackage(ctx) {
 		return gcp.OptIn("supervisor package is required"), nil
 	}
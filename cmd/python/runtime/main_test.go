@@ -50,6 +50,15 @@ func TestDetect(t *testing.T) {
 			env:  []string{"GOOGLE_RUNTIME=php"},
 			want: 100,
 		},
+		{
+			name: "py files with nodejs assets",
+			files: map[string]string{
+				"main.py":      "",
+				"package.json": "",
+			},
+			env:  []string{"GOOGLE_ASSETS_RUNTIME=nodejs"},
+			want: 0,
+		},
 		{
 			name:  "no py files",
 			files: map[string]string{},
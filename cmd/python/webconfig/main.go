@@ -0,0 +1,182 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/webconfig buildpack.
+// The webconfig buildpack serves static files from nginx and proxies all other requests to
+// gunicorn over a unix socket.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/webconfig"
+)
+
+const (
+	// pid1
+	appSocket = "app.sock"
+	pid1Log   = "pid1.log"
+
+	// nginx
+	defaultNginxBinary = "nginx"
+	defaultRoot        = "/workspace"
+	nginxConf          = "nginx.conf"
+	nginxLog           = "nginx.log"
+	staticExpires      = "1h"
+
+	// portExecD is the exec.d executable which writes the nginx config listening on PORT to
+	// launchConfigDir at launch.
+	portExecD = "python-webconfig-port"
+	// launchConfigDir is the directory the nginx config with the PORT placeholder expanded is
+	// written to at launch.
+	launchConfigDir = "/tmp/python_webconfig"
+
+	// gunicorn
+	uvicornWorker = "uvicorn.workers.UvicornWorker"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if os.Getenv(python.StaticPathsEnv) == "" {
+		return gcp.OptOut(fmt.Sprintf("%s not set", python.StaticPathsEnv)), nil
+	}
+	if os.Getenv(env.Entrypoint) != "" {
+		return gcp.OptOut("custom entrypoint present"), nil
+	}
+	procExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return nil, err
+	}
+	if procExists {
+		return gcp.OptOut("Procfile present"), nil
+	}
+	return gcp.OptIn(fmt.Sprintf("%s set", python.StaticPathsEnv)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	paths, err := python.ParseStaticPaths(os.Getenv(python.StaticPathsEnv))
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), p.Dir)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			ctx.Warnf("Static files directory %q served at %s does not exist", p.Dir, p.Prefix)
+		}
+	}
+
+	l, err := ctx.Layer("webconfig", gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+
+	conf := proxyConfig(l.Path, paths)
+	conf.BuildInfoFile, conf.BuildID, err = nginx.BuildInfoConfig(l.Path, ctx.ApplicationRoot(), time.Now())
+	if err != nil {
+		return gcp.UserErrorf("writing build info: %v", err)
+	}
+	if conf.BuildInfoFile != "" {
+		ctx.Logf("Serving build %s metadata at %s", conf.BuildID, nginx.BuildInfoPath)
+	}
+	nginxServerConfFile, err := nginx.WriteProxyConfigToPath(l.Path, conf)
+	if err != nil {
+		return err
+	}
+	defer nginxServerConfFile.Close()
+	// The port is only known at launch, when the exec.d executable writes the config listening on
+	// it to launchConfigDir.
+	if err := webconfig.WriteNamedPortExecD(l.Exec.Path, portExecD, launchConfigDir, []string{nginxServerConfFile.Name()}); err != nil {
+		return gcp.InternalErrorf("writing %s exec.d: %w", portExecD, err)
+	}
+
+	appModule := os.Getenv(python.AppModuleEnv)
+	source := gcp.SourceEnv
+	if appModule == "" {
+		appModule = python.DefaultAppModule
+		source = gcp.SourceDefault
+	}
+	ctx.RecordSetting("app module", appModule, source)
+
+	var asgi bool
+	reqExists, err := ctx.FileExists(ctx.ApplicationRoot(), "requirements.txt")
+	if err != nil {
+		return err
+	}
+	if reqExists {
+		if asgi, err = python.UsesUvicorn(ctx, filepath.Join(ctx.ApplicationRoot(), "requirements.txt")); err != nil {
+			return err
+		}
+	}
+	for _, p := range conf.StaticLocations {
+		ctx.RecordSetting("static path "+p.Prefix, p.Dir, gcp.SourceEnv)
+	}
+
+	cmd := []string{
+		filepath.Join(os.Getenv("PID1_DIR"), "pid1"),
+		"--nginxBinaryPath", defaultNginxBinary,
+		"--nginxErrLogFilePath", filepath.Join(l.Path, nginxLog),
+		"--customAppCmd", fmt.Sprintf("%q", strings.Join(gunicornCommand(l.Path, appModule, asgi), " ")),
+		"--pid1LogFilePath", filepath.Join(l.Path, pid1Log),
+		// Ideally, we should be able to use the path of the nginx layer and not hardcode it here.
+		// This needs some investigation on how to pass values between build steps of buildpacks.
+		"--mimeTypesPath", filepath.Join("/layers/google.utils.nginx/nginx", "conf/mime.types"),
+		"--customAppSocket", filepath.Join(l.Path, appSocket),
+		"--nginxConfigPath", filepath.Join(l.Path, nginxConf),
+		"--serverConfigPath", filepath.Join(launchConfigDir, filepath.Base(nginxServerConfFile.Name())),
+	}
+	ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDefaultProcess())
+	return nil
+}
+
+// proxyConfig returns the nginx config serving the given static paths and proxying all other
+// requests to the application socket in the layer. It listens on the PORT placeholder, which is
+// expanded at launch.
+func proxyConfig(layer string, paths []python.StaticPath) nginx.ProxyConfig {
+	conf := nginx.ProxyConfig{
+		Port:             "${" + webconfig.PlaceholderPort + "}",
+		AppListenAddress: "unix:" + filepath.Join(layer, appSocket),
+		StaticExpires:    staticExpires,
+	}
+	for _, p := range paths {
+		conf.StaticLocations = append(conf.StaticLocations, nginx.StaticLocation{
+			Prefix: p.Prefix,
+			Dir:    filepath.Join(defaultRoot, p.Dir),
+		})
+	}
+	return conf
+}
+
+// gunicornCommand returns the command serving the application on the unix socket in the layer.
+// ASGI applications are served with the uvicorn worker class.
+func gunicornCommand(layer, appModule string, asgi bool) []string {
+	cmd := []string{"gunicorn", "--bind", "unix:" + filepath.Join(layer, appSocket)}
+	if asgi {
+		cmd = append(cmd, "--worker-class", uvicornWorker)
+	}
+	return append(cmd, appModule)
+}
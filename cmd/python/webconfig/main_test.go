@@ -0,0 +1,168 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name:  "static paths set",
+			files: map[string]string{"main.py": ""},
+			env:   []string{"GOOGLE_PYTHON_STATIC_PATHS=true"},
+			want:  0,
+		},
+		{
+			name:  "static paths not set",
+			files: map[string]string{"main.py": ""},
+			want:  100,
+		},
+		{
+			name:  "custom entrypoint",
+			files: map[string]string{"main.py": ""},
+			env:   []string{"GOOGLE_PYTHON_STATIC_PATHS=true", "GOOGLE_ENTRYPOINT=gunicorn main:app"},
+			want:  100,
+		},
+		{
+			name: "procfile",
+			files: map[string]string{
+				"main.py":  "",
+				"Procfile": "web: gunicorn main:app",
+			},
+			env:  []string{"GOOGLE_PYTHON_STATIC_PATHS=true"},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		env          []string
+		wantExitCode int
+	}{
+		{
+			name: "default static paths",
+			env:  []string{"GOOGLE_PYTHON_STATIC_PATHS=true"},
+		},
+		{
+			name:         "invalid static paths",
+			env:          []string{"GOOGLE_PYTHON_STATIC_PATHS=static"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(map[string]string{"main.py": "", "static/app.css": ""}),
+				bpt.WithEnvs(tc.env...),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+		})
+	}
+}
+
+func TestProxyConfig(t *testing.T) {
+	paths := []python.StaticPath{{Prefix: "/static", Dir: "staticfiles"}, {Prefix: "/media", Dir: "media"}}
+	want := nginx.ProxyConfig{
+		Port:             "${PORT}",
+		AppListenAddress: "unix:/layers/webconfig/app.sock",
+		StaticLocations: []nginx.StaticLocation{
+			{Prefix: "/static", Dir: "/workspace/staticfiles"},
+			{Prefix: "/media", Dir: "/workspace/media"},
+		},
+		StaticExpires: "1h",
+	}
+	if diff := cmp.Diff(want, proxyConfig("/layers/webconfig", paths)); diff != "" {
+		t.Errorf("proxyConfig() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteProxyConfig(t *testing.T) {
+	dir := t.TempDir()
+	f, err := nginx.WriteProxyConfigToPath(dir, proxyConfig("/layers/webconfig", []python.StaticPath{{Prefix: "/static", Dir: "static"}}))
+	if err != nil {
+		t.Fatalf("WriteProxyConfigToPath() got error: %v", err)
+	}
+	f.Close()
+	got, err := os.ReadFile(filepath.Join(dir, "nginxserver.conf"))
+	if err != nil {
+		t.Fatalf("reading nginx config: %v", err)
+	}
+	for _, want := range []string{
+		"server         unix:/layers/webconfig/app.sock fail_timeout=0;",
+		"listen	${PORT} default_server;",
+		"location /static/ {",
+		"alias	/workspace/static/;",
+		"proxy_pass	http://app_server;",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("nginx config does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestGunicornCommand(t *testing.T) {
+	testCases := []struct {
+		name string
+		asgi bool
+		want []string
+	}{
+		{
+			name: "wsgi",
+			want: []string{"gunicorn", "--bind", "unix:/layers/webconfig/app.sock", "mysite.wsgi:application"},
+		},
+		{
+			name: "asgi",
+			asgi: true,
+			want: []string{"gunicorn", "--bind", "unix:/layers/webconfig/app.sock", "--worker-class", "uvicorn.workers.UvicornWorker", "mysite.wsgi:application"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := gunicornCommand("/layers/webconfig", "mysite.wsgi:application", tc.asgi)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("gunicornCommand() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
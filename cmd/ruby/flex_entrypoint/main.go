@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appenv"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -30,7 +31,6 @@ import (
 
 const (
 	flexEntrypoint = "flex_entrypoint"
-	production     = "production"
 )
 
 func main() {
@@ -63,9 +63,9 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating layer: %w", err)
 	}
 	// Set the launch environment to production so it uses 0.0.0.0 host when it starts the entrypoint.
-	l.LaunchEnvironment.Default("RACK_ENV", production)
-	l.LaunchEnvironment.Default("RAILS_ENV", production)
-	l.LaunchEnvironment.Default("APP_ENV", production)
+	if err := appenv.Set(ctx, l, appenv.Ruby...); err != nil {
+		return err
+	}
 
 	ctx.Logf("Using entrypoint %s", entrypoint)
 	ctx.AddProcess(gcp.WebProcess, []string{entrypoint}, gcp.AsDefaultProcess())
@@ -80,7 +80,7 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 	if version.LessThan(recommendedVersion) {
-		ctx.Warnf("Found a deprecated version of functions-framework (%s); consider updating your Gemfile to use functions_framework %s or later.", version, recommendedVersion)
+		ctx.CategoryWarnf(gcp.WarningDeprecation, "Found a deprecated version of functions-framework (%s); consider updating your Gemfile to use functions_framework %s or later.", version, recommendedVersion)
 	}
 
 	cloudfunctions.AddFrameworkVersionLabel(ctx, &cloudfunctions.FrameworkVersionInfo{
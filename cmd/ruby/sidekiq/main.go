@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements ruby/sidekiq buildpack.
+// The sidekiq buildpack declares a worker process running Sidekiq alongside the web process.
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appenv"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
+)
+
+const (
+	workerProcess = "worker"
+	layerName     = "sidekiq"
+	sidekiqConfig = "config/sidekiq.yml"
+)
+
+var (
+	procfileWorkerRe = regexp.MustCompile(`(?m)^worker:`)
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	usesSidekiq, err := ruby.UsesSidekiq(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !usesSidekiq {
+		return gcp.OptOut("sidekiq gem not found"), nil
+	}
+	procExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return nil, err
+	}
+	if procExists {
+		content, err := ctx.ReadFile("Procfile")
+		if err != nil {
+			return nil, err
+		}
+		if procfileWorkerRe.Match(content) {
+			return gcp.OptOut("worker process declared in Procfile"), nil
+		}
+	}
+	return gcp.OptIn("found sidekiq gem"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	if err := ruby.ValidateRedisURL(ctx); err != nil {
+		return err
+	}
+
+	l, err := ctx.Layer(layerName, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	l.Profile.ProcessAdd(workerProcess, "sidekiq_concurrency.sh", ruby.SidekiqConcurrencyScript())
+	if err := appenv.Set(ctx, l, appenv.RailsEnv); err != nil {
+		return err
+	}
+
+	cmd, err := workerCommand(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Adding %s process: %s", workerProcess, cmd)
+	ctx.AddProcess(workerProcess, []string{cmd})
+
+	// The web process is only inferred if it is not configured, the entrypoint buildpack runs
+	// afterwards and takes precedence.
+	procExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return err
+	}
+	if procExists || os.Getenv(env.Entrypoint) != "" {
+		return nil
+	}
+	web, err := ruby.InferEntrypoint(ctx, ctx.ApplicationRoot())
+	if err != nil {
+		ctx.Warnf("Unable to infer the web process, set %s or create a Procfile to declare it.", env.Entrypoint)
+		return nil
+	}
+	ctx.Logf("Adding %s process: %s", gcp.WebProcess, web)
+	ctx.AddProcess(gcp.WebProcess, []string{web}, gcp.AsDefaultProcess())
+	return nil
+}
+
+// workerCommand returns the command running Sidekiq. The concurrency is only passed on the command
+// line if the application does not configure Sidekiq with config/sidekiq.yml.
+func workerCommand(ctx *gcp.Context) (string, error) {
+	cmd := []string{"bundle", "exec", "sidekiq", "--environment", "$RAILS_ENV"}
+	configExists, err := ctx.FileExists(ctx.ApplicationRoot(), sidekiqConfig)
+	if err != nil {
+		return "", err
+	}
+	if configExists {
+		cmd = append(cmd, "--config", sidekiqConfig)
+	} else {
+		cmd = append(cmd, "--concurrency", "$"+ruby.SidekiqConcurrencyEnv)
+	}
+	return strings.Join(cmd, " "), nil
+}
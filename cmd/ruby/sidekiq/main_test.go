@@ -0,0 +1,150 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const gemfileLock = `GEM
+  specs:
+    sidekiq (7.2.2)
+      redis-client (>= 0.19.0)
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "sidekiq in Gemfile.lock",
+			files: map[string]string{
+				"Gemfile.lock": gemfileLock,
+			},
+			want: 0,
+		},
+		{
+			name: "Procfile without worker",
+			files: map[string]string{
+				"Gemfile.lock": gemfileLock,
+				"Procfile":     "web: bundle exec puma",
+			},
+			want: 0,
+		},
+		{
+			name: "Procfile with worker",
+			files: map[string]string{
+				"Gemfile.lock": gemfileLock,
+				"Procfile":     "web: bundle exec puma\nworker: bundle exec sidekiq -c 10",
+			},
+			want: 100,
+		},
+		{
+			name: "no sidekiq",
+			files: map[string]string{
+				"Gemfile.lock": "GEM\n  specs:\n    rails (7.1.3)\n",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		wantExitCode int
+	}{
+		{
+			name: "redis url not set",
+		},
+		{
+			name: "valid redis url",
+			envs: []string{"REDIS_URL=redis://10.0.0.3:6379/0"},
+		},
+		{
+			name:         "invalid redis url",
+			envs:         []string{"REDIS_URL=10.0.0.3:6379"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(map[string]string{"Gemfile.lock": gemfileLock, "bin/rails": ""}),
+				bpt.WithEnvs(tc.envs...),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+		})
+	}
+}
+
+func TestWorkerCommand(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{
+			name: "concurrency from memory",
+			want: "bundle exec sidekiq --environment $RAILS_ENV --concurrency $SIDEKIQ_CONCURRENCY",
+		},
+		{
+			name:  "sidekiq config",
+			files: []string{"config/sidekiq.yml"},
+			want:  "bundle exec sidekiq --environment $RAILS_ENV --config config/sidekiq.yml",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			got, err := workerCommand(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("workerCommand() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("workerCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/exclude-secrets buildpack.
+// The exclude-secrets buildpack removes credentials, such as private keys, and local .env files,
+// such as .env.local, from the application and from the launch layers after the application is
+// built so that they are not shipped in the image. Files can be kept with GOOGLE_KEEP_SECRET_FILES, files the launch
+// environment refers to are kept. The modes of the remaining files are normalized when
+// GOOGLE_NORMALIZE_FILE_MODES is set.
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/secretfiles"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	// Always opt in, the buildpack is not optional in the builder order groups.
+	return gcp.OptInAlways(), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	// The source is synced into the container in development mode, so files must not be removed.
+	if devmode.Enabled(ctx) {
+		ctx.Logf("Keeping all files in development mode")
+		return nil
+	}
+	if err := secretfiles.Exclude(ctx, ctx.ApplicationRoot(), filepath.Dir(ctx.LayersDir())); err != nil {
+		return err
+	}
+	umask, ok, err := gcp.FileModeUmask()
+	if err != nil || !ok {
+		return err
+	}
+	return ctx.NormalizeFileModes(ctx.ApplicationRoot(), umask)
+}
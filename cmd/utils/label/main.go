@@ -14,15 +14,17 @@
 
 // Implements utils/label-image buildpack.
 // The label-image buildpack adds any environment variables with the "GOOGLE_LABEL_" prefix as
-// labels in the final application image.
+// labels in the final application image, along with the git metadata of the source.
 package main
 
 import (
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gitmeta"
 )
 
 func main() {
@@ -34,6 +36,7 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	userKeys := map[string]bool{}
 	for _, e := range os.Environ() {
 		if !strings.HasPrefix(e, env.LabelPrefix) {
 			continue
@@ -46,6 +49,29 @@ func buildFn(ctx *gcp.Context) error {
 			value = parts[1]
 		}
 		ctx.AddLabel(key, value)
+		userKeys[strings.ReplaceAll(strings.ToLower(key), "_", "-")] = true
+	}
+	return addGitLabels(ctx, userKeys)
+}
+
+// addGitLabels adds the git metadata of the source as labels, unless labels with the same keys
+// were set with env vars, and records it in the build report.
+func addGitLabels(ctx *gcp.Context, userKeys map[string]bool) error {
+	git, err := gitmeta.Read(ctx.ApplicationRoot())
+	if err != nil {
+		ctx.Warnf("Failed to read the git metadata of the source, some git labels may be missing: %v", err)
+	}
+	labels := git.Labels()
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ctx.RecordSetting(strings.ReplaceAll(k, "-", " "), labels[k], git.Source)
+		if !userKeys[k] {
+			ctx.AddLabel(k, labels[k])
+		}
 	}
 	return nil
 }
@@ -40,6 +40,18 @@ func TestBuild(t *testing.T) {
 			envs: []string{"GOOGLE_LABEL_FOO=bar"},
 			want: labelLog + " google.foo: bar",
 		},
+		{
+			name: "git metadata from env vars",
+			app:  "with_framework",
+			envs: []string{"COMMIT_SHA=4b825dc6", "BRANCH_NAME=main"},
+			want: labelLog + " google.git-commit: 4b825dc6",
+		},
+		{
+			name: "git label set with env var",
+			app:  "with_framework",
+			envs: []string{"COMMIT_SHA=4b825dc6", "GOOGLE_LABEL_GIT_COMMIT=override"},
+			want: labelLog + " google.git-commit: override",
+		},
 		{
 			name: "random env var",
 			app:  "with_framework",
@@ -13,7 +13,8 @@
 // limitations under the License.
 
 // Implements utils/nginx buildpack.
-// The nginx buildpack installs the nginx web server, pid1 and serve binaries.
+// The nginx buildpack installs the nginx web server, pid1 and serve binaries, along with the brotli
+// modules if the brotli compression is enabled.
 package main
 
 import (
@@ -22,6 +23,7 @@ import (
 	"path/filepath"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/webconfig"
 	"github.com/buildpacks/libcnb"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
@@ -34,6 +36,10 @@ const (
 
 	// pid1VerConstraint is used to control updating to a new major version.
 	pid1VerConstraint = "^1.0.0"
+
+	// brotliVersionKey is the nginx layer metadata key of the nginx version the brotli modules were
+	// installed for.
+	brotliVersionKey = "brotli_version"
 )
 
 func main() {
@@ -54,6 +60,16 @@ func buildFn(ctx *gcp.Context) error {
 	nl.LaunchEnvironment.Append("PATH", string(os.PathListSeparator), filepath.Join(nl.Path, "sbin"))
 	nl.BuildEnvironment.Default("NGINX_ROOT", nl.Path)
 
+	brotli, err := webconfig.BrotliRequested(ctx)
+	if err != nil {
+		return err
+	}
+	if brotli {
+		if err := installBrotli(ctx, nl); err != nil {
+			return err
+		}
+	}
+
 	// install pid1
 	pl, err := install(ctx, "pid1", pid1VerConstraint, runtime.Pid1)
 	if err != nil {
@@ -77,3 +93,23 @@ func install(ctx *gcp.Context, name, verConstraint string, ir runtime.Installabl
 
 	return l, nil
 }
+
+// installBrotli installs the brotli modules built for the nginx version of the layer into its
+// modules directory, unless they were installed for it by a previous build.
+func installBrotli(ctx *gcp.Context, nl *libcnb.Layer) error {
+	version := ctx.GetMetadata(nl, "version")
+	if ctx.GetMetadata(nl, brotliVersionKey) == version {
+		ctx.CacheHit(string(runtime.NginxBrotli))
+		return nil
+	}
+	ctx.CacheMiss(string(runtime.NginxBrotli))
+	modulesDir := filepath.Join(nl.Path, "modules")
+	if err := ctx.MkdirAll(modulesDir, 0755); err != nil {
+		return gcp.InternalErrorf("creating %s: %w", modulesDir, err)
+	}
+	if err := runtime.InstallModuleTarball(ctx, runtime.NginxBrotli, version, modulesDir); err != nil {
+		return err
+	}
+	ctx.SetMetadata(nl, brotliVersionKey, version)
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/smoke-test buildpack.
+// The smoke-test buildpack starts the default process of the application in the build container
+// when GOOGLE_SMOKE_TEST is set, and fails the build if it exits or does not listen on PORT, so
+// applications which crash at startup are caught before the image is exported.
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/smoketest"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	enabled, err := env.IsPresentAndTrue(env.SmokeTest)
+	if err != nil {
+		return nil, gcp.UserErrorf("%v", err)
+	}
+	if !enabled {
+		return gcp.OptOutEnvNotSet(env.SmokeTest), nil
+	}
+	return gcp.OptInEnvSet(env.SmokeTest), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	// The development server watches the source instead of serving a built application.
+	if devmode.Enabled(ctx) {
+		ctx.Logf("Skipping the smoke test in development mode")
+		return nil
+	}
+	opts, err := smoketest.OptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	layersRoot := filepath.Dir(ctx.LayersDir())
+	p, err := smoketest.DefaultProcess(layersRoot)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		ctx.Warnf("Skipping the smoke test, no default or web process is set for the application.")
+		return nil
+	}
+	environ, err := smoketest.LaunchEnv(layersRoot, p.Type, os.Environ())
+	if err != nil {
+		return err
+	}
+	return smoketest.Run(ctx, p, environ, opts)
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/warmup buildpack.
+// The warmup buildpack wraps the default process of the application when GOOGLE_WARMUP_URLS or
+// GOOGLE_WARMUP_COMMAND is set, so that the warm-up requests and command run when the container
+// starts. With GOOGLE_WARMUP_PROXY the port of the container only accepts connections after the
+// warm-up.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/smoketest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/warmup"
+)
+
+const (
+	// helperName is the name of the helper copied to the launch layer. The binary of the buildpack
+	// runs the warm-up instead of the buildpack when it is invoked with this name.
+	helperName = "warmup"
+	layerName  = "warmup"
+)
+
+func main() {
+	if filepath.Base(os.Args[0]) == helperName {
+		warmup.Main()
+		return
+	}
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	for _, name := range []string{env.WarmupURLs, env.WarmupCommand} {
+		if os.Getenv(name) != "" {
+			return gcp.OptInEnvSet(name), nil
+		}
+	}
+	return gcp.OptOut("neither GOOGLE_WARMUP_URLS nor GOOGLE_WARMUP_COMMAND is set"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	// The development server restarts on changes, warming it up would delay every restart.
+	if devmode.Enabled(ctx) {
+		ctx.Logf("Skipping the warm-up in development mode")
+		return nil
+	}
+	cfg, err := warmup.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled() {
+		return nil
+	}
+	p, err := smoketest.DefaultProcess(filepath.Dir(ctx.LayersDir()))
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		ctx.Warnf("Skipping the warm-up, no default or web process is set for the application.")
+		return nil
+	}
+
+	l, err := ctx.Layer(layerName, gcp.LaunchLayer)
+	if err != nil {
+		return gcp.InternalErrorf("creating %v layer: %w", layerName, err)
+	}
+	helper := filepath.Join(l.Path, "bin", helperName)
+	if err := copyExecutable(ctx, helper); err != nil {
+		return err
+	}
+	// Runtime values of the variables take precedence over the ones of the build.
+	for _, name := range []string{env.WarmupURLs, env.WarmupCommand, env.WarmupTimeout, env.WarmupProxy} {
+		if v := os.Getenv(name); v != "" {
+			l.LaunchEnvironment.Default(name, v)
+		}
+	}
+
+	cmd := p.Command
+	if !p.Direct {
+		cmd = []string{"bash", "-c", strings.Join(p.Command, " ")}
+	}
+	ctx.Logf("Warming up the %s process %q when it starts.", p.Type, strings.Join(p.Command, " "))
+	ctx.AddProcess(p.Type, append([]string{helper, "--"}, cmd...), gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(p.WorkingDirectory))
+	return nil
+}
+
+// copyExecutable copies the binary of the buildpack to path.
+func copyExecutable(ctx *gcp.Context, path string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return gcp.InternalErrorf("finding the buildpack executable: %w", err)
+	}
+	data, err := ctx.ReadFile(exe)
+	if err != nil {
+		return err
+	}
+	if err := ctx.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ctx.WriteFile(path, data, 0755)
+}
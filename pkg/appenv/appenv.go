@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package appenv sets the environment variables which select the configuration of the frameworks
+// of an app, such as NODE_ENV, consistently across languages.
+package appenv
+
+import (
+	"os"
+	"path/filepath"
+
+	apphostingschema "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/apphostingschema"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// production is the default value of the variables.
+	production = "production"
+	// appHostingYAML is the name of the App Hosting config in the application root.
+	appHostingYAML = "apphosting.yaml"
+)
+
+// Var is an environment variable selecting the configuration of a framework, along with its
+// default value.
+type Var struct {
+	Name    string
+	Default string
+}
+
+var (
+	// NodeEnv selects the configuration of Node.js frameworks and package managers.
+	NodeEnv = Var{Name: "NODE_ENV", Default: production}
+	// RailsEnv selects the configuration of Rails apps.
+	RailsEnv = Var{Name: "RAILS_ENV", Default: production}
+	// RackEnv selects the configuration of Rack apps.
+	RackEnv = Var{Name: "RACK_ENV", Default: production}
+	// AppEnv selects the configuration of Sinatra and Hanami apps.
+	AppEnv = Var{Name: "APP_ENV", Default: production}
+
+	// Ruby are the variables set for Ruby apps.
+	Ruby = []Var{RackEnv, RailsEnv, AppEnv}
+)
+
+// BuildValue returns the value of the variable during the build: the value set in the build
+// environment, or else its default.
+func BuildValue(v Var) string {
+	if value := os.Getenv(v.Name); value != "" {
+		return value
+	}
+	return v.Default
+}
+
+// Set sets the variables in the build and launch environments of the layer, unless they are set
+// when the app runs, and records them in the build report.
+//
+// A value set in the build environment is used at launch too, unless the variable is declared in
+// apphosting.yaml without RUNTIME availability, in which case the default is used at launch. A
+// variable declared with RUNTIME availability only is not set in the build environment and is set
+// by App Hosting at launch, so the default applies to the build. An invalid apphosting.yaml is
+// reported and ignored.
+func Set(ctx *gcp.Context, l *libcnb.Layer, vars ...Var) error {
+	buildOnly, err := buildOnlyVars(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range vars {
+		build, source := v.Default, gcp.SourceDefault
+		if value := os.Getenv(v.Name); value != "" {
+			build, source = value, gcp.SourceEnv
+		}
+		l.BuildEnvironment.Default(v.Name, build)
+		if buildOnly[v.Name] && build != v.Default {
+			l.LaunchEnvironment.Default(v.Name, v.Default)
+			ctx.RecordSetting(v.Name+" at build", build, gcp.SourceAppHostingYAML)
+			ctx.RecordSetting(v.Name, v.Default, gcp.SourceDefault)
+			continue
+		}
+		l.LaunchEnvironment.Default(v.Name, build)
+		ctx.RecordSetting(v.Name, build, source)
+	}
+	return nil
+}
+
+// buildOnlyVars returns the names of the variables declared in apphosting.yaml which are only
+// available during the build. None are returned if apphosting.yaml is invalid, App Hosting reports
+// its errors itself.
+func buildOnlyVars(ctx *gcp.Context) (map[string]bool, error) {
+	vars := map[string]bool{}
+	path := filepath.Join(ctx.ApplicationRoot(), appHostingYAML)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return vars, err
+	}
+	schema, err := apphostingschema.ReadAndValidateAppHostingSchemaFromFile(path)
+	if err != nil {
+		ctx.Warnf("Ignoring the availability of the variables in %s, it is invalid: %v", appHostingYAML, err)
+		return vars, nil
+	}
+	for _, ev := range schema.Env {
+		if len(ev.Availability) == 0 {
+			continue
+		}
+		runtime := false
+		for _, a := range ev.Availability {
+			if a == "RUNTIME" {
+				runtime = true
+			}
+		}
+		if !runtime {
+			vars[ev.Variable] = true
+		}
+	}
+	return vars, nil
+}
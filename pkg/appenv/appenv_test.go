@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appenv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSet(t *testing.T) {
+	testCases := []struct {
+		name           string
+		env            map[string]string
+		appHostingYAML string
+		wantBuild      libcnb.Environment
+		wantLaunch     libcnb.Environment
+		wantSettings   []builderoutput.ConfigSetting
+	}{
+		{
+			name:       "default",
+			wantBuild:  libcnb.Environment{"NODE_ENV.default": "production"},
+			wantLaunch: libcnb.Environment{"NODE_ENV.default": "production"},
+			wantSettings: []builderoutput.ConfigSetting{
+				{Name: "NODE_ENV", Value: "production", Source: gcp.SourceDefault},
+			},
+		},
+		{
+			name:       "env var applies to build and launch",
+			env:        map[string]string{"NODE_ENV": "staging"},
+			wantBuild:  libcnb.Environment{"NODE_ENV.default": "staging"},
+			wantLaunch: libcnb.Environment{"NODE_ENV.default": "staging"},
+			wantSettings: []builderoutput.ConfigSetting{
+				{Name: "NODE_ENV", Value: "staging", Source: gcp.SourceEnv},
+			},
+		},
+		{
+			name: "build only apphosting.yaml variable",
+			env:  map[string]string{"NODE_ENV": "development"},
+			appHostingYAML: `
+env:
+  - variable: NODE_ENV
+    value: development
+    availability:
+      - BUILD
+`,
+			wantBuild:  libcnb.Environment{"NODE_ENV.default": "development"},
+			wantLaunch: libcnb.Environment{"NODE_ENV.default": "production"},
+			wantSettings: []builderoutput.ConfigSetting{
+				{Name: "NODE_ENV at build", Value: "development", Source: gcp.SourceAppHostingYAML},
+				{Name: "NODE_ENV", Value: "production", Source: gcp.SourceDefault},
+			},
+		},
+		{
+			name: "build and runtime apphosting.yaml variable",
+			env:  map[string]string{"NODE_ENV": "staging"},
+			appHostingYAML: `
+env:
+  - variable: NODE_ENV
+    value: staging
+`,
+			wantBuild:  libcnb.Environment{"NODE_ENV.default": "staging"},
+			wantLaunch: libcnb.Environment{"NODE_ENV.default": "staging"},
+			wantSettings: []builderoutput.ConfigSetting{
+				{Name: "NODE_ENV", Value: "staging", Source: gcp.SourceEnv},
+			},
+		},
+		{
+			name: "runtime only apphosting.yaml variable",
+			appHostingYAML: `
+env:
+  - variable: NODE_ENV
+    value: staging
+    availability:
+      - RUNTIME
+`,
+			wantBuild:  libcnb.Environment{"NODE_ENV.default": "production"},
+			wantLaunch: libcnb.Environment{"NODE_ENV.default": "production"},
+			wantSettings: []builderoutput.ConfigSetting{
+				{Name: "NODE_ENV", Value: "production", Source: gcp.SourceDefault},
+			},
+		},
+		{
+			name: "invalid apphosting.yaml is ignored",
+			env:  map[string]string{"NODE_ENV": "development"},
+			appHostingYAML: `
+env:
+  - variable: NODE_ENV
+    value: development
+    availability:
+      - SOMETIMES
+`,
+			wantBuild:  libcnb.Environment{"NODE_ENV.default": "development"},
+			wantLaunch: libcnb.Environment{"NODE_ENV.default": "development"},
+			wantSettings: []builderoutput.ConfigSetting{
+				{Name: "NODE_ENV", Value: "development", Source: gcp.SourceEnv},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NODE_ENV", "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			if tc.appHostingYAML != "" {
+				if err := os.WriteFile(filepath.Join(dir, appHostingYAML), []byte(tc.appHostingYAML), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			l := &libcnb.Layer{BuildEnvironment: libcnb.Environment{}, LaunchEnvironment: libcnb.Environment{}}
+
+			if err := Set(ctx, l, NodeEnv); err != nil {
+				t.Fatalf("Set() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantBuild, l.BuildEnvironment); diff != "" {
+				t.Errorf("Set() build environment mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantLaunch, l.LaunchEnvironment); diff != "" {
+				t.Errorf("Set() launch environment mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantSettings, ctx.Settings()); diff != "" {
+				t.Errorf("Set() settings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildValue(t *testing.T) {
+	t.Setenv("RAILS_ENV", "")
+	if got := BuildValue(RailsEnv); got != "production" {
+		t.Errorf("BuildValue(RailsEnv) = %q, want %q", got, "production")
+	}
+	t.Setenv("RAILS_ENV", "staging")
+	if got := BuildValue(RailsEnv); got != "staging" {
+		t.Errorf("BuildValue(RailsEnv) = %q, want %q", got, "staging")
+	}
+}
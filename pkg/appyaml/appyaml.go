@@ -17,9 +17,12 @@ package appyaml
 
 import (
 	"os"
+	"reflect"
+	"sort"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
 	"gopkg.in/yaml.v2"
 )
 
@@ -28,39 +31,68 @@ type appYaml struct {
 	RuntimeConfig RuntimeConfig `yaml:"runtime_config"`
 }
 
+// ignoredPhpRuntimeConfigKeys are runtime_config keys which are valid in GAE Flexible app.yaml but
+// are handled by the platform rather than the PHP buildpacks.
+var ignoredPhpRuntimeConfigKeys = []string{"operating_system", "runtime_version"}
+
 // RuntimeConfig The runtime_config specified in users app.yaml.
 type RuntimeConfig struct {
-	DocumentRoot            string `yaml:"document_root"`
-	ComposerFlags           string `yaml:"composer_flags"`
-	FrontControllerFile     string `yaml:"front_controller_file"`
-	NginxConfOverride       string `yaml:"nginx_conf_override"`
-	NginxConfInclude        string `yaml:"nginx_conf_include"`
-	NginxConfHTTPInclude    string `yaml:"nginx_conf_http_include"`
-	PHPFPMConfOverride      string `yaml:"php_fpm_conf_override"`
-	PHPIniOverride          string `yaml:"php_ini_override"`
-	SupervisordConfAddition string `yaml:"supervisord_conf_addition"`
-	SupervisordConfOverride string `yaml:"supervisord_conf_override"`
+	DocumentRoot            string            `yaml:"document_root"`
+	ComposerFlags           string            `yaml:"composer_flags"`
+	FrontControllerFile     string            `yaml:"front_controller_file"`
+	NginxConfOverride       string            `yaml:"nginx_conf_override"`
+	NginxConfInclude        string            `yaml:"nginx_conf_include"`
+	NginxConfHTTPInclude    string            `yaml:"nginx_conf_http_include"`
+	PHPFPMConfOverride      string            `yaml:"php_fpm_conf_override"`
+	PHPIniOverride          string            `yaml:"php_ini_override"`
+	SupervisordConfAddition string            `yaml:"supervisord_conf_addition"`
+	SupervisordConfOverride string            `yaml:"supervisord_conf_override"`
+	PHPFPMSlowlogTimeout    string            `yaml:"php_fpm_slowlog_timeout"`
+	PHPFPMSlowlog           string            `yaml:"php_fpm_slowlog"`
+	PHPFPMSlowlogTraceDepth string            `yaml:"php_fpm_slowlog_trace_depth"`
+	PHPFPMWorkers           string            `yaml:"php_fpm_workers"`
+	PHPFPMWorkerMemory      string            `yaml:"php_fpm_worker_memory"`
+	Server                  string            `yaml:"server"`
+	WorkerScript            string            `yaml:"worker_script"`
+	Routes                  []nginx.Route     `yaml:"routes"`
+	Gzip                    bool              `yaml:"gzip"`
+	Brotli                  bool              `yaml:"brotli"`
+	HTTP2                   bool              `yaml:"http2"`
+	KeepaliveTimeout        string            `yaml:"keepalive_timeout"`
+	KeepaliveRequests       string            `yaml:"keepalive_requests"`
+	TLSCertificate          string            `yaml:"tls_certificate"`
+	TLSCertificateKey       string            `yaml:"tls_certificate_key"`
+	TLSPort                 string            `yaml:"tls_port"`
+	OPcachePreload          string            `yaml:"opcache_preload"`
+	WordPressUploadsDir     string            `yaml:"wordpress_uploads_dir"`
+	DevMode                 bool              `yaml:"dev_mode"`
+	PHPIni                  map[string]string `yaml:"php_ini"`
 }
 
 // appYamlIfExists looks up the app.yaml file specified by env var and returns its content if exists.
 func appYamlIfExists(root string) (*appYaml, error) {
+	content, err := appYamlContentIfExists(root)
+	if err != nil || content == nil {
+		return nil, err
+	}
+	a := &appYaml{}
+	if err := yaml.Unmarshal(content, &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// appYamlContentIfExists returns the raw content of the app.yaml file specified by env var, or nil
+// if it is not specified.
+func appYamlContentIfExists(root string) ([]byte, error) {
 	exist, path, err := appYamlExists(root)
 	if err != nil {
 		return nil, err
 	}
-	if exist {
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil, err
-		}
-		a := &appYaml{}
-		err = yaml.Unmarshal([]byte(content), &a)
-		if err != nil {
-			return nil, err
-		}
-		return a, nil
+	if !exist {
+		return nil, nil
 	}
-	return nil, nil
+	return os.ReadFile(path)
 }
 
 // appYamlExists returns true if the specified app.yaml file exists and its path.
@@ -107,3 +139,41 @@ func PhpConfiguration(root string) (RuntimeConfig, error) {
 
 	return a.RuntimeConfig, nil
 }
+
+// UnknownPhpRuntimeConfigKeys returns the keys in the runtime_config of GAE Flexible app.yaml which
+// are not recognized by the PHP buildpacks, in sorted order.
+func UnknownPhpRuntimeConfigKeys(root string) ([]string, error) {
+	content, err := appYamlContentIfExists(root)
+	if err != nil || content == nil {
+		return nil, err
+	}
+	var raw struct {
+		RuntimeConfig map[string]interface{} `yaml:"runtime_config"`
+	}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, k := range PhpRuntimeConfigKeys() {
+		known[k] = true
+	}
+	var unknown []string
+	for k := range raw.RuntimeConfig {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// PhpRuntimeConfigKeys returns the runtime_config keys recognized by the PHP buildpacks.
+func PhpRuntimeConfigKeys() []string {
+	keys := append([]string{}, ignoredPhpRuntimeConfigKeys...)
+	t := reflect.TypeOf(RuntimeConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("yaml"))
+	}
+	sort.Strings(keys)
+	return keys
+}
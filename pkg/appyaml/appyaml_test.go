@@ -17,8 +17,11 @@ package appyaml
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
 )
 
 func TestGetEntrypointIfExists(t *testing.T) {
@@ -108,6 +111,22 @@ runtime_config:
 `),
 			want: RuntimeConfig{DocumentRoot: "web"},
 		},
+		{
+			name: "runtime_config with routes",
+			env:  []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path: "app.yaml",
+			content: []byte(`
+runtime_config:
+ routes:
+ - path: /build
+   type: static
+   headers:
+     Cache-Control: immutable
+`),
+			want: RuntimeConfig{Routes: []nginx.Route{
+				{Path: "/build", Type: nginx.RouteStatic, Headers: map[string]string{"Cache-Control": "immutable"}},
+			}},
+		},
 		{
 			name: "missing runtime_config",
 			env:  []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
@@ -125,8 +144,59 @@ runtime_config:
 			if err != nil != tc.wantErr {
 				t.Fatalf("got err=%t, want err=%t: %v", err != nil, tc.wantErr, err)
 			}
-			if got != tc.want {
-				t.Errorf("PhpConfiguration returns %q, want %q", got, tc.want)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("PhpConfiguration returns %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnknownPhpRuntimeConfigKeys(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     []string
+		path    string
+		content []byte
+		want    []string
+	}{
+		{
+			name: "no app.yaml",
+		},
+		{
+			name: "known keys",
+			env:  []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path: "app.yaml",
+			content: []byte(`
+runtime: php
+runtime_config:
+ document_root: web
+ operating_system: ubuntu22
+`),
+		},
+		{
+			name: "unknown keys",
+			env:  []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path: "app.yaml",
+			content: []byte(`
+runtime_config:
+ docment_root: web
+ front_controller_file: app.php
+ nginx_conf: nginx.conf
+`),
+			want: []string{"docment_root", "nginx_conf"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempRoot := t.TempDir()
+			writeFile(tc.path, tempRoot, tc.content, tc.env, t)
+
+			got, err := UnknownPhpRuntimeConfigKeys(tempRoot)
+			if err != nil {
+				t.Fatalf("UnknownPhpRuntimeConfigKeys() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("UnknownPhpRuntimeConfigKeys() = %v, want %v", got, tc.want)
 			}
 		})
 	}
@@ -40,6 +40,12 @@ const (
 	NpmInstallLatencyID                   MetricID = "8"
 	ComposerInstallLatencyID              MetricID = "9"
 	PipInstallLatencyID                   MetricID = "10"
+	HTTPRequestsCounterID                 MetricID = "11"
+	HTTPConnectionsCounterID              MetricID = "12"
+	DNSLookupsCounterID                   MetricID = "13"
+	DNSCacheHitsCounterID                 MetricID = "14"
+	TelemetryOptOutsCounterID             MetricID = "15"
+	CacheCorruptionsCounterID             MetricID = "16"
 )
 
 var (
@@ -94,5 +100,35 @@ var (
 			"pip_install_latency",
 			"The latency for executions of `pip install`",
 		),
+		HTTPRequestsCounterID: newDescriptor(
+			HTTPRequestsCounterID,
+			"http_requests",
+			"The number of requests made with the shared HTTP client",
+		),
+		HTTPConnectionsCounterID: newDescriptor(
+			HTTPConnectionsCounterID,
+			"http_connections_opened",
+			"The number of connections opened by the shared HTTP client",
+		),
+		DNSLookupsCounterID: newDescriptor(
+			DNSLookupsCounterID,
+			"dns_lookups",
+			"The number of DNS lookups made by the shared HTTP client",
+		),
+		DNSCacheHitsCounterID: newDescriptor(
+			DNSCacheHitsCounterID,
+			"dns_cache_hits",
+			"The number of DNS lookups served from the shared HTTP client cache",
+		),
+		TelemetryOptOutsCounterID: newDescriptor(
+			TelemetryOptOutsCounterID,
+			"telemetry_opt_outs",
+			"The number of env vars set to opt out of the telemetry of frameworks and CLIs",
+		),
+		CacheCorruptionsCounterID: newDescriptor(
+			CacheCorruptionsCounterID,
+			"cache_corruptions",
+			"The number of cached layers cleared because their restored content was corrupted",
+		),
 	}
 )
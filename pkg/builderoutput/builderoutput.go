@@ -49,6 +49,8 @@ type BuilderOutput struct {
 	Stats                    []BuilderStat                 `json:"stats"`
 	Warnings                 []string                      `json:"warnings"`
 	CustomImage              bool                          `json:"customImage"`
+	Settings                 []ConfigSetting               `json:"settings,omitempty"`
+	DetectPlan               []DetectRecord                `json:"detectPlan,omitempty"`
 }
 
 // New constructs a BuilderOutput and returns a pointer.
@@ -70,3 +72,28 @@ type BuilderStat struct {
 	DurationMs       int64  `json:"totalDurationMs"`
 	UserDurationMs   int64  `json:"userDurationMs"`
 }
+
+// ConfigSetting records the effective value of a build setting and the configuration source it
+// was taken from.
+type ConfigSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// DetectRecord records the outcome of a buildpack's detect phase.
+type DetectRecord struct {
+	BuildpackID      string           `json:"buildpackId"`
+	BuildpackVersion string           `json:"buildpackVersion"`
+	Pass             bool             `json:"pass"`
+	Reason           string           `json:"reason"`
+	Error            string           `json:"error,omitempty"`
+	Plans            []DetectPlanItem `json:"plans,omitempty"`
+}
+
+// DetectPlanItem lists the dependencies provided and required by one alternative build plan of a
+// buildpack.
+type DetectPlanItem struct {
+	Provides []string `json:"provides,omitempty"`
+	Requires []string `json:"requires,omitempty"`
+}
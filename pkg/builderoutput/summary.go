@@ -0,0 +1,200 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builderoutput
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSummaryWarnings limits the new warnings listed in a summary, so that it fits in a comment.
+const maxSummaryWarnings = 10
+
+// Summary compares a build with the previous build of the same app, for example the build of the
+// base branch of a pull request.
+type Summary struct {
+	// Current is the builder output of the build.
+	Current BuilderOutput
+	// Previous is the builder output of the previous build, or nil if there is none.
+	Previous *BuilderOutput
+	// ImageSize is the size of the built image in bytes, or 0 if unknown.
+	ImageSize int64
+	// PreviousImageSize is the size of the previous image in bytes, or 0 if unknown.
+	PreviousImageSize int64
+}
+
+// Markdown returns the summary as markdown suitable for a pull request comment: the image size
+// and build duration compared to the previous build, the changed runtime versions and settings,
+// and the warnings which the previous build did not have.
+func (s Summary) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("### Build summary\n\n")
+	if e := s.Current.Error; e.Message != "" {
+		fmt.Fprintf(&sb, "**Build failed** in `%s`: %s\n\n", e.BuildpackID, firstLine(e.Message))
+	}
+
+	var prev BuilderOutput
+	if s.Previous != nil {
+		prev = *s.Previous
+	}
+	sb.WriteString("| | This build | Previous build | Change |\n|---|---|---|---|\n")
+	sb.WriteString(row("Image size", s.ImageSize, s.PreviousImageSize, formatBytes))
+	sb.WriteString(row("Build duration", s.Current.TotalDurationMs(), prev.TotalDurationMs(), formatMillis))
+
+	if s.Previous != nil {
+		added, removed := diff(s.Current.InstalledRuntimeVersions, prev.InstalledRuntimeVersions)
+		if len(added)+len(removed) > 0 {
+			sb.WriteString("\n**Runtime changes**\n\n")
+			for _, v := range added {
+				fmt.Fprintf(&sb, "- Added `%s`\n", v)
+			}
+			for _, v := range removed {
+				fmt.Fprintf(&sb, "- Removed `%s`\n", v)
+			}
+		}
+		if changes := settingChanges(s.Current.Settings, prev.Settings); len(changes) > 0 {
+			sb.WriteString("\n**Setting changes**\n\n")
+			for _, c := range changes {
+				sb.WriteString(c)
+			}
+		}
+	}
+
+	warnings, _ := diff(s.Current.Warnings, prev.Warnings)
+	if len(warnings) > 0 {
+		title := "New warnings"
+		if s.Previous == nil {
+			title = "Warnings"
+		}
+		fmt.Fprintf(&sb, "\n**%s (%d)**\n\n", title, len(warnings))
+		for i, w := range warnings {
+			if i == maxSummaryWarnings {
+				fmt.Fprintf(&sb, "- and %d more\n", len(warnings)-maxSummaryWarnings)
+				break
+			}
+			fmt.Fprintf(&sb, "- %s\n", firstLine(w))
+		}
+	}
+	return sb.String()
+}
+
+// TotalDurationMs returns the sum of the build durations of the buildpacks.
+func (bo BuilderOutput) TotalDurationMs() int64 {
+	var total int64
+	for _, s := range bo.Stats {
+		total += s.DurationMs
+	}
+	return total
+}
+
+// row returns a table row comparing the current and previous values, where 0 is unknown.
+func row(name string, cur, prev int64, format func(int64) string) string {
+	curs, prevs, change := "-", "-", "-"
+	if cur > 0 {
+		curs = format(cur)
+	}
+	if prev > 0 {
+		prevs = format(prev)
+	}
+	if cur > 0 && prev > 0 {
+		d := cur - prev
+		sign := "+"
+		if d < 0 {
+			sign, d = "-", -d
+		}
+		change = fmt.Sprintf("%s%s (%s%.1f%%)", sign, format(d), sign, float64(d)*100/float64(prev))
+		if d == 0 {
+			change = "none"
+		}
+	}
+	return fmt.Sprintf("| %s | %s | %s | %s |\n", name, curs, prevs, change)
+}
+
+// settingChanges returns a list item for each setting which was added, removed or changed.
+func settingChanges(cur, prev []ConfigSetting) []string {
+	prevValues := make(map[string]string)
+	for _, s := range prev {
+		prevValues[s.Name] = s.Value
+	}
+	var changes []string
+	for _, s := range cur {
+		old, ok := prevValues[s.Name]
+		delete(prevValues, s.Name)
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("- `%s`: `%s` (%s)\n", s.Name, s.Value, s.Source))
+		case old != s.Value:
+			changes = append(changes, fmt.Sprintf("- `%s`: `%s` → `%s` (%s)\n", s.Name, old, s.Value, s.Source))
+		}
+	}
+	var removed []string
+	for name := range prevValues {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		changes = append(changes, fmt.Sprintf("- `%s`: no longer set\n", name))
+	}
+	return changes
+}
+
+// diff returns the values of cur which are not in prev and the values of prev which are not in
+// cur, without duplicates and in their original order.
+func diff(cur, prev []string) (added, removed []string) {
+	return missing(cur, prev), missing(prev, cur)
+}
+
+func missing(values, from []string) []string {
+	seen := make(map[string]bool)
+	for _, v := range from {
+		seen[v] = true
+	}
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGT"[exp])
+}
+
+func formatMillis(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if d >= time.Second {
+		d = d.Round(100 * time.Millisecond)
+	}
+	return d.String()
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builderoutput
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSummaryMarkdown(t *testing.T) {
+	previous := BuilderOutput{
+		InstalledRuntimeVersions: []string{"8.2.10"},
+		Stats:                    []BuilderStat{{BuildpackID: "google.php.runtime", DurationMs: 40000}},
+		Warnings:                 []string{"old warning"},
+		Settings: []ConfigSetting{
+			{Name: "document root", Value: "/workspace", Source: "default"},
+			{Name: "gzip", Value: "true", Source: "composer.json extra"},
+		},
+	}
+	testCases := []struct {
+		name    string
+		summary Summary
+		want    string
+	}{
+		{
+			name: "compared to the previous build",
+			summary: Summary{
+				Current: BuilderOutput{
+					InstalledRuntimeVersions: []string{"8.3.1"},
+					Stats: []BuilderStat{
+						{BuildpackID: "google.php.runtime", DurationMs: 30000},
+						{BuildpackID: "google.php.composer", DurationMs: 20000},
+					},
+					Warnings: []string{"old warning", "new warning\nwith details"},
+					Settings: []ConfigSetting{
+						{Name: "document root", Value: "/workspace/public", Source: "composer.json extra"},
+						{Name: "brotli", Value: "true", Source: "composer.json extra"},
+					},
+				},
+				Previous:          &previous,
+				ImageSize:         300 * 1024 * 1024,
+				PreviousImageSize: 200 * 1024 * 1024,
+			},
+			want: "### Build summary\n\n" +
+				"| | This build | Previous build | Change |\n|---|---|---|---|\n" +
+				"| Image size | 300.0 MiB | 200.0 MiB | +100.0 MiB (+50.0%) |\n" +
+				"| Build duration | 50s | 40s | +10s (+25.0%) |\n" +
+				"\n**Runtime changes**\n\n- Added `8.3.1`\n- Removed `8.2.10`\n" +
+				"\n**Setting changes**\n\n" +
+				"- `document root`: `/workspace` → `/workspace/public` (composer.json extra)\n" +
+				"- `brotli`: `true` (composer.json extra)\n" +
+				"- `gzip`: no longer set\n" +
+				"\n**New warnings (1)**\n\n- new warning\n",
+		},
+		{
+			name: "without a previous build",
+			summary: Summary{
+				Current: BuilderOutput{
+					Stats:    []BuilderStat{{BuildpackID: "google.go.build", DurationMs: 1234}},
+					Warnings: []string{"a warning"},
+				},
+			},
+			want: "### Build summary\n\n" +
+				"| | This build | Previous build | Change |\n|---|---|---|---|\n" +
+				"| Image size | - | - | - |\n" +
+				"| Build duration | 1.2s | - | - |\n" +
+				"\n**Warnings (1)**\n\n- a warning\n",
+		},
+		{
+			name: "failed build",
+			summary: Summary{
+				Current: BuilderOutput{
+					Error: buildererror.Error{BuildpackID: "google.go.build", Message: "compile failed\nmain.go:1"},
+				},
+				Previous:          &BuilderOutput{},
+				ImageSize:         1024,
+				PreviousImageSize: 1024,
+			},
+			want: "### Build summary\n\n" +
+				"**Build failed** in `google.go.build`: compile failed\n\n" +
+				"| | This build | Previous build | Change |\n|---|---|---|---|\n" +
+				"| Image size | 1.0 KiB | 1.0 KiB | none |\n" +
+				"| Build duration | - | - | - |\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.summary.Markdown()); diff != "" {
+				t.Errorf("Markdown() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSummaryMarkdownLimitsWarnings(t *testing.T) {
+	var warnings []string
+	for i := 0; i < maxSummaryWarnings+3; i++ {
+		warnings = append(warnings, string(rune('a'+i)))
+	}
+	got := Summary{Current: BuilderOutput{Warnings: warnings}, Previous: &BuilderOutput{}}.Markdown()
+	want := "- j\n- and 3 more\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("Markdown() = %q, want suffix %q", got, want)
+	}
+}
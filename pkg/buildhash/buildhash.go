@@ -0,0 +1,169 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package buildhash computes a content-addressed hash of the inputs of a build, so that a build
+// whose source, apphosting.yaml, builder and arguments are unchanged since the previously
+// published image can be skipped and the image reused.
+package buildhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// Label is the image label the hash of the build inputs is recorded in.
+	Label = "google.build-config-hash"
+	// LabelEnv is the build env var the label image buildpack records as Label.
+	LabelEnv = "GOOGLE_LABEL_BUILD_CONFIG_HASH"
+
+	// builderMetadataLabel is the label of a builder image which lists its buildpacks.
+	builderMetadataLabel = "io.buildpacks.builder.metadata"
+	// version is hashed first, so that changing what is hashed invalidates the recorded hashes.
+	version = "2"
+)
+
+// Inputs are the inputs of a build which determine the image it produces.
+type Inputs struct {
+	// SourceDir is the directory of the application source. The .git directory is not hashed.
+	SourceDir string
+	// AppHostingYAML is the path of the apphosting.yaml of the backend, if any.
+	AppHostingYAML string
+	// BuilderDigest is the digest of the builder image. Buildpack versions are not bumped when the
+	// runtimes and tools they install are updated, so only the digest identifies these updates.
+	BuilderDigest string
+	// Buildpacks are the buildpacks of the builder, as `<id>@<version>`.
+	Buildpacks []string
+	// Args are the arguments of the build, such as the env vars it is run with.
+	Args []string
+}
+
+// Compute returns the hex encoded SHA-256 of the inputs. Files are hashed with their path relative
+// to the source directory and their executable bit, and symlinks with their target.
+func Compute(in Inputs) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %s\n", version)
+	err := filepath.WalkDir(in.SourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(in.SourceDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "link %q %q\n", rel, target)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum, err := fileSum(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "file %q %t %s\n", rel, info.Mode()&0111 != 0, sum)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hashing source %s: %w", in.SourceDir, err)
+	}
+	if in.AppHostingYAML != "" {
+		sum, err := fileSum(in.AppHostingYAML)
+		if err != nil {
+			return "", fmt.Errorf("hashing apphosting.yaml: %w", err)
+		}
+		fmt.Fprintf(h, "apphosting.yaml %s\n", sum)
+	}
+	fmt.Fprintf(h, "builder %q\n", in.BuilderDigest)
+	buildpacks := append([]string{}, in.Buildpacks...)
+	sort.Strings(buildpacks)
+	for _, bp := range buildpacks {
+		fmt.Fprintf(h, "buildpack %q\n", bp)
+	}
+	// The order of the arguments is kept, as later arguments may override earlier ones.
+	for _, arg := range in.Args {
+		fmt.Fprintf(h, "arg %q\n", arg)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileSum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BuilderBuildpacks returns the buildpacks of the builder image img as `<id>@<version>`.
+func BuilderBuildpacks(img v1.Image) ([]string, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading image config: %w", err)
+	}
+	label, ok := cfg.Config.Labels[builderMetadataLabel]
+	if !ok {
+		return nil, fmt.Errorf("image has no %s label, it is not a builder", builderMetadataLabel)
+	}
+	var metadata struct {
+		Buildpacks []struct {
+			ID      string `json:"id"`
+			Version string `json:"version"`
+		} `json:"buildpacks"`
+	}
+	if err := json.Unmarshal([]byte(label), &metadata); err != nil {
+		return nil, fmt.Errorf("parsing %s label: %w", builderMetadataLabel, err)
+	}
+	var buildpacks []string
+	for _, bp := range metadata.Buildpacks {
+		buildpacks = append(buildpacks, bp.ID+"@"+bp.Version)
+	}
+	return buildpacks, nil
+}
+
+// Recorded returns the hash recorded in the labels of img, or "" if there is none.
+func Recorded(img v1.Image) (string, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return "", fmt.Errorf("reading image config: %w", err)
+	}
+	return cfg.Config.Labels[Label], nil
+}
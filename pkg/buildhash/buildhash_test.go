@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildhash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+func TestCompute(t *testing.T) {
+	testCases := []struct {
+		name        string
+		change      func(t *testing.T, in *Inputs)
+		wantChanged bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(t *testing.T, in *Inputs) {},
+		},
+		{
+			name: "source file changed",
+			change: func(t *testing.T, in *Inputs) {
+				writeFile(t, filepath.Join(in.SourceDir, "index.js"), "console.log('changed');", 0644)
+			},
+			wantChanged: true,
+		},
+		{
+			name: "source file added",
+			change: func(t *testing.T, in *Inputs) {
+				writeFile(t, filepath.Join(in.SourceDir, "lib/util.js"), "", 0644)
+			},
+			wantChanged: true,
+		},
+		{
+			name: "source file made executable",
+			change: func(t *testing.T, in *Inputs) {
+				if err := os.Chmod(filepath.Join(in.SourceDir, "index.js"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantChanged: true,
+		},
+		{
+			name: "git directory changed",
+			change: func(t *testing.T, in *Inputs) {
+				writeFile(t, filepath.Join(in.SourceDir, ".git/HEAD"), "ref: refs/heads/other", 0644)
+			},
+		},
+		{
+			name: "apphosting.yaml changed",
+			change: func(t *testing.T, in *Inputs) {
+				writeFile(t, in.AppHostingYAML, "runConfig:\n  cpu: 2\n", 0644)
+			},
+			wantChanged: true,
+		},
+		{
+			name: "buildpacks reordered",
+			change: func(t *testing.T, in *Inputs) {
+				in.Buildpacks = []string{"google.nodejs.npm@1.2.0", "google.nodejs.runtime@1.0.0"}
+			},
+		},
+		{
+			name: "buildpack upgraded",
+			change: func(t *testing.T, in *Inputs) {
+				in.Buildpacks = []string{"google.nodejs.runtime@1.0.1", "google.nodejs.npm@1.2.0"}
+			},
+			wantChanged: true,
+		},
+		{
+			name: "builder image rebuilt",
+			change: func(t *testing.T, in *Inputs) {
+				in.BuilderDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+			},
+			wantChanged: true,
+		},
+		{
+			name: "build env changed",
+			change: func(t *testing.T, in *Inputs) {
+				in.Args = []string{"--env=NODE_ENV=development"}
+			},
+			wantChanged: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			in := Inputs{
+				SourceDir:      t.TempDir(),
+				AppHostingYAML: filepath.Join(t.TempDir(), "apphosting.yaml"),
+				BuilderDigest:  "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				Buildpacks:     []string{"google.nodejs.runtime@1.0.0", "google.nodejs.npm@1.2.0"},
+				Args:           []string{"--env=NODE_ENV=production"},
+			}
+			writeFile(t, filepath.Join(in.SourceDir, "index.js"), "console.log('hello');", 0644)
+			writeFile(t, filepath.Join(in.SourceDir, ".git/HEAD"), "ref: refs/heads/main", 0644)
+			writeFile(t, in.AppHostingYAML, "runConfig:\n  cpu: 1\n", 0644)
+			before, err := Compute(in)
+			if err != nil {
+				t.Fatalf("Compute() got error: %v", err)
+			}
+
+			tc.change(t, &in)
+			after, err := Compute(in)
+			if err != nil {
+				t.Fatalf("Compute() got error: %v", err)
+			}
+			if gotChanged := before != after; gotChanged != tc.wantChanged {
+				t.Errorf("Compute() changed = %t, want %t", gotChanged, tc.wantChanged)
+			}
+		})
+	}
+}
+
+func TestBuilderBuildpacks(t *testing.T) {
+	img := labeledImage(t, map[string]string{builderMetadataLabel: `{
+		"description": "test builder",
+		"buildpacks": [
+			{"id": "google.nodejs.runtime", "version": "1.0.0"},
+			{"id": "google.nodejs.npm", "version": "1.2.0"}
+		]
+	}`})
+	got, err := BuilderBuildpacks(img)
+	if err != nil {
+		t.Fatalf("BuilderBuildpacks() got error: %v", err)
+	}
+	want := []string{"google.nodejs.runtime@1.0.0", "google.nodejs.npm@1.2.0"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("BuilderBuildpacks() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := BuilderBuildpacks(labeledImage(t, nil)); err == nil {
+		t.Error("BuilderBuildpacks() of an image without builder metadata got no error")
+	}
+}
+
+func TestRecorded(t *testing.T) {
+	got, err := Recorded(labeledImage(t, map[string]string{Label: "abc123"}))
+	if err != nil {
+		t.Fatalf("Recorded() got error: %v", err)
+	}
+	if got != "abc123" {
+		t.Errorf("Recorded() = %q, want %q", got, "abc123")
+	}
+}
+
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// labeledImage returns an empty image with the given labels.
+func labeledImage(t *testing.T, labels map[string]string) v1.Image {
+	t.Helper()
+	cfg, err := empty.Image.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.Config.Labels = labels
+	img, err := mutate.ConfigFile(empty.Image, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
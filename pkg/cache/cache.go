@@ -16,12 +16,14 @@
 package cache
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 
 	"github.com/buildpacks/libcnb"
+	"golang.org/x/text/unicode/norm"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
@@ -38,7 +40,7 @@ func WithStrings(strings ...string) Option {
 
 // WithFiles returns a cache option that hashes contents of the files. Callers can
 // detect if a file did not exist by checking returned error values against
-// os.IsNotFound(...).
+// os.IsNotFound(...). The contents of text files are normalized, see normalizeContent.
 func WithFiles(files ...string) Option {
 	return func() ([]string, error) {
 		var strings []string
@@ -47,12 +49,27 @@ func WithFiles(files ...string) Option {
 			if err != nil {
 				return nil, err
 			}
-			strings = append(strings, string(b))
+			strings = append(strings, normalizeContent(b))
 		}
 		return strings, nil
 	}
 }
 
+// normalizeContent returns the contents of a file in a form which does not depend on the system
+// it was checked out on: the UTF-8 byte order mark is removed, CRLF and CR line endings are
+// replaced with LF and the text is converted to Unicode normalization form C, which macOS file
+// systems and editors do not always preserve. Binary files, which contain NUL bytes, are returned
+// unchanged.
+func normalizeContent(b []byte) string {
+	if bytes.IndexByte(b, 0) >= 0 {
+		return string(b)
+	}
+	b = bytes.TrimPrefix(b, []byte("\uFEFF"))
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	b = bytes.ReplaceAll(b, []byte("\r"), []byte("\n"))
+	return norm.NFC.String(string(b))
+}
+
 // hash creates a sha256 hash from the given cache options.
 func hash(ctx *gcp.Context, opts ...Option) (string, error) {
 	h := sha256.New()
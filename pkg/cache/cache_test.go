@@ -218,6 +218,67 @@ func TestWithFiles(t *testing.T) {
 	}
 }
 
+func TestWithFilesNormalizesContents(t *testing.T) {
+	testCases := []struct {
+		name     string
+		contents string
+		want     string
+		wantSame bool
+	}{
+		{
+			name:     "CRLF line endings",
+			contents: "lockfileVersion: 3\r\npackages: {}\r\n",
+			want:     "lockfileVersion: 3\npackages: {}\n",
+			wantSame: true,
+		},
+		{
+			name:     "CR line endings",
+			contents: "lockfileVersion: 3\rpackages: {}\r",
+			want:     "lockfileVersion: 3\npackages: {}\n",
+			wantSame: true,
+		},
+		{
+			name:     "byte order mark",
+			contents: "\uFEFF{\"name\": \"app\"}",
+			want:     "{\"name\": \"app\"}",
+			wantSame: true,
+		},
+		{
+			name:     "decomposed unicode",
+			contents: "author: Jose\u0301",
+			want:     "author: Jos\u00e9",
+			wantSame: true,
+		},
+		{
+			name:     "binary file",
+			contents: "\x00\x01\r\n",
+			want:     "\x00\x01\n",
+		},
+		{
+			name:     "different contents",
+			contents: "lockfileVersion: 3\n",
+			want:     "lockfileVersion: 2\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			temp := t.TempDir()
+			ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}))
+			got, err := hash(ctx, WithFiles(writeFile(t, temp, "got", tc.contents)))
+			if err != nil {
+				t.Fatalf("Hash(WithFiles()) got err=%v, want err=nil", err)
+			}
+			want, err := hash(ctx, WithFiles(writeFile(t, temp, "want", tc.want)))
+			if err != nil {
+				t.Fatalf("Hash(WithFiles()) got err=%v, want err=nil", err)
+			}
+			if same := got == want; same != tc.wantSame {
+				t.Errorf("Hash(WithFiles(%q)) == Hash(WithFiles(%q)) is %t, want %t", tc.contents, tc.want, same, tc.wantSame)
+			}
+		})
+	}
+}
+
 func TestWithFilesError(t *testing.T) {
 	ctx := gcp.NewContext()
 
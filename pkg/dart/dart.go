@@ -57,6 +57,7 @@ func DetectSDKVersion() (string, error) {
 func fetchLatestSdkVersion() (string, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
+	retryClient.HTTPClient = gcp.HTTPClient()
 
 	resp, err := retryClient.StandardClient().Get(versionURL)
 	if err != nil {
@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"fmt"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// EFMigrationsBundleEnv is an env var used to opt in to building an EF Core migrations bundle,
+	// which is added to the image as the EFMigrationsProcess process type.
+	EFMigrationsBundleEnv = "GOOGLE_DOTNET_EF_MIGRATIONS_BUNDLE"
+	// EFMigrationsProcess is the name of the process type which applies the EF Core migrations.
+	EFMigrationsProcess = "migrate"
+
+	efCoreDesignPackage = "Microsoft.EntityFrameworkCore.Design"
+	efToolVersionKey    = "version"
+)
+
+// EFCoreDesignVersion returns the version of Microsoft.EntityFrameworkCore.Design referenced by
+// the project, or an empty string if it is not referenced. The package is required to generate a
+// migrations bundle.
+func EFCoreDesignVersion(p Project) string {
+	for _, ig := range p.ItemGroups {
+		for _, pr := range ig.PackageReferences {
+			if pr.Include == efCoreDesignPackage {
+				return pr.Version
+			}
+		}
+	}
+	return ""
+}
+
+// InstallEFTool installs the dotnet-ef tool matching the EF Core version in the given layer if
+// it is not already cached, and returns the path of the tool.
+func InstallEFTool(ctx *gcp.Context, l *libcnb.Layer, version string) (string, error) {
+	tool := filepath.Join(l.Path, "dotnet-ef")
+	if ctx.GetMetadata(l, efToolVersionKey) == version {
+		ctx.CacheHit(l.Name)
+		return tool, nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return "", fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	cmd := []string{"dotnet", "tool", "install", "dotnet-ef", "--tool-path", l.Path, "--version", version}
+	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution); err != nil {
+		return "", err
+	}
+	ctx.SetMetadata(l, efToolVersionKey, version)
+	return tool, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestEFCoreDesignVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		project string
+		want    string
+	}{
+		{
+			name: "design package referenced",
+			project: `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <ItemGroup>
+    <PackageReference Include="Microsoft.EntityFrameworkCore.SqlServer" Version="8.0.4" />
+    <PackageReference Include="Microsoft.EntityFrameworkCore.Design" Version="8.0.4">
+      <PrivateAssets>all</PrivateAssets>
+    </PackageReference>
+  </ItemGroup>
+</Project>`,
+			want: "8.0.4",
+		},
+		{
+			name: "only runtime package",
+			project: `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <ItemGroup>
+    <PackageReference Include="Microsoft.EntityFrameworkCore.SqlServer" Version="8.0.4" />
+  </ItemGroup>
+</Project>`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := readProjectFile([]byte(tc.project), "app.csproj")
+			if err != nil {
+				t.Fatalf("readProjectFile() got error: %v", err)
+			}
+			if got := EFCoreDesignVersion(p); got != tc.want {
+				t.Errorf("EFCoreDesignVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstallEFTool(t *testing.T) {
+	testCases := []struct {
+		name          string
+		layerMetadata map[string]any
+		mocks         []*mockprocess.Mock
+	}{
+		{
+			name:          "not cached",
+			layerMetadata: map[string]any{},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^dotnet tool install dotnet-ef --tool-path .* --version 8.0.4$`, mockprocess.WithStdout("Tool 'dotnet-ef' was successfully installed.")),
+			},
+		},
+		{
+			name:          "cached",
+			layerMetadata: map[string]any{"version": "8.0.4"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^dotnet tool install`, mockprocess.WithExitCode(1)),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eCmd, err := mockprocess.NewExecCmd(tc.mocks...)
+			if err != nil {
+				t.Fatalf("error creating mock exec command: %v", err)
+			}
+			ctx := gcp.NewContext(gcp.WithExecCmd(eCmd))
+			l := &libcnb.Layer{Name: "dotnet-ef", Path: t.TempDir(), Metadata: tc.layerMetadata}
+			if _, err := InstallEFTool(ctx, l, "8.0.4"); err != nil {
+				t.Fatalf("InstallEFTool() got error: %v", err)
+			}
+			if got := ctx.GetMetadata(l, "version"); got != "8.0.4" {
+				t.Errorf("InstallEFTool() layer version = %q, want %q", got, "8.0.4")
+			}
+		})
+	}
+}
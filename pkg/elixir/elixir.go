@@ -0,0 +1,229 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elixir contains Elixir buildpack library code.
+package elixir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// ErlangVersionEnv is an env var used to set the Erlang/OTP version. The Elixir version is set
+	// with GOOGLE_RUNTIME_VERSION.
+	// Example: `26.2.5`.
+	ErlangVersionEnv = "GOOGLE_ERLANG_VERSION"
+
+	// ToolVersions is the asdf version file the Erlang and Elixir versions are read from.
+	ToolVersions = ".tool-versions"
+
+	defaultErlangVersion = "26.2.5"
+	defaultElixirVersion = "1.16.3"
+
+	versionKey = "version"
+	stackKey   = "stack"
+)
+
+var (
+	// otpURL is the template used to generate an Erlang/OTP download URL for an OS and version.
+	otpURL = "https://builds.hex.pm/builds/otp/%s/OTP-%s.tar.gz"
+	// elixirURL is the template used to generate an Elixir download URL for a version compiled
+	// with an OTP major version.
+	elixirURL = "https://builds.hex.pm/builds/elixir/v%s-otp-%s.zip"
+
+	// hexOS maps the OS of the stack to the name used by the hex.pm builds.
+	hexOS = map[string]string{
+		"ubuntu1804": "ubuntu-18.04",
+		"ubuntu2204": "ubuntu-22.04",
+	}
+
+	// elixirOTPSuffixRegexp matches the OTP suffix of an Elixir version, for example `-otp-26`.
+	elixirOTPSuffixRegexp = regexp.MustCompile(`-otp-(\d+)$`)
+	// appNameRegexp matches the application name in the project definition of mix.exs.
+	appNameRegexp = regexp.MustCompile(`(?m)\bapp:\s*:(\w+)`)
+)
+
+// Versions contains the Erlang/OTP and Elixir versions of an application.
+type Versions struct {
+	Erlang string
+	Elixir string
+	// ElixirOTP is the OTP major version Elixir is compiled with.
+	ElixirOTP string
+}
+
+// DetectVersions determines the Erlang/OTP and Elixir versions from the environment or
+// .tool-versions, falling back to default versions.
+func DetectVersions(ctx *gcp.Context) (Versions, error) {
+	tools, err := readToolVersions(ctx)
+	if err != nil {
+		return Versions{}, err
+	}
+	v := Versions{Erlang: defaultErlangVersion, Elixir: defaultElixirVersion}
+	if tv, ok := tools["erlang"]; ok {
+		v.Erlang = tv
+	}
+	if ev := os.Getenv(ErlangVersionEnv); ev != "" {
+		v.Erlang = ev
+	}
+	if tv, ok := tools["elixir"]; ok {
+		v.Elixir = tv
+	}
+	if ev := os.Getenv(env.RuntimeVersion); ev != "" {
+		v.Elixir = ev
+	}
+
+	v.ElixirOTP = strings.SplitN(v.Erlang, ".", 2)[0]
+	if m := elixirOTPSuffixRegexp.FindStringSubmatch(v.Elixir); m != nil {
+		v.Elixir = strings.TrimSuffix(v.Elixir, m[0])
+		v.ElixirOTP = m[1]
+	}
+	v.Elixir = strings.TrimPrefix(v.Elixir, "v")
+	return v, nil
+}
+
+// readToolVersions returns the first version of each tool listed in .tool-versions.
+func readToolVersions(ctx *gcp.Context) (map[string]string, error) {
+	tools := map[string]string{}
+	path := filepath.Join(ctx.ApplicationRoot(), ToolVersions)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return tools, err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if _, ok := tools[fields[0]]; !ok {
+			tools[fields[0]] = fields[1]
+		}
+	}
+	return tools, nil
+}
+
+// InstallErlang installs Erlang/OTP in the given layer if it is not already cached.
+func InstallErlang(ctx *gcp.Context, l *libcnb.Layer, version string) error {
+	if isCached(ctx, l, version) {
+		ctx.CacheHit(l.Name)
+		return nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	osName, ok := hexOS[runtime.OSForStack(ctx)]
+	if !ok {
+		return gcp.InternalErrorf("Erlang/OTP is not available for stack %q", ctx.StackID())
+	}
+	url := fmt.Sprintf(otpURL, osName, version)
+	ctx.Logf("Installing Erlang/OTP %s", version)
+	archive := filepath.Join(l.Path, "otp.tar.gz")
+	if err := download(ctx, "erlang-"+osName, version, url, archive); err != nil {
+		ctx.Warnf("Failed to download Erlang/OTP from %s. You can specify the version by setting the %s environment variable or in %s.", url, ErlangVersionEnv, ToolVersions)
+		return err
+	}
+	if _, err := ctx.Exec([]string{"tar", "-xzf", archive, "-C", l.Path, "--strip-components=1"}); err != nil {
+		return gcp.InternalErrorf("extracting Erlang/OTP: %w", err)
+	}
+	if err := os.Remove(archive); err != nil {
+		return err
+	}
+	// The Install script rewrites the paths of the release to the installation directory.
+	if _, err := ctx.Exec([]string{"./Install", "-minimal", l.Path}, gcp.WithWorkDir(l.Path)); err != nil {
+		return gcp.InternalErrorf("installing Erlang/OTP: %w", err)
+	}
+	ctx.SetMetadata(l, stackKey, ctx.StackID())
+	ctx.SetMetadata(l, versionKey, version)
+	return nil
+}
+
+// InstallElixir installs Elixir compiled with the given OTP major version in the given layer if
+// it is not already cached.
+func InstallElixir(ctx *gcp.Context, l *libcnb.Layer, version, otp string) error {
+	key := version + "-otp-" + otp
+	if isCached(ctx, l, key) {
+		ctx.CacheHit(l.Name)
+		return nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	url := fmt.Sprintf(elixirURL, version, otp)
+	ctx.Logf("Installing Elixir %s (OTP %s)", version, otp)
+	zip := filepath.Join(l.Path, "elixir.zip")
+	if err := download(ctx, "elixir-otp-"+otp, version, url, zip); err != nil {
+		ctx.Warnf("Failed to download Elixir from %s. You can specify the version by setting the %s environment variable or in %s.", url, env.RuntimeVersion, ToolVersions)
+		return err
+	}
+	if _, err := ctx.Exec([]string{"unzip", "-q", zip, "-d", l.Path}); err != nil {
+		return gcp.InternalErrorf("extracting Elixir: %w", err)
+	}
+	if err := os.Remove(zip); err != nil {
+		return err
+	}
+	ctx.SetMetadata(l, stackKey, ctx.StackID())
+	ctx.SetMetadata(l, versionKey, key)
+	return nil
+}
+
+// download downloads the file at url to outPath. The file is verified against the digest of the
+// tool named name if the tools manifest pins it at version, versions selected by the application
+// are not pinned and cannot be verified.
+func download(ctx *gcp.Context, name, version, url, outPath string) error {
+	tool, ok, err := fetch.PinnedToolVersion(name, version)
+	if err != nil {
+		return err
+	}
+	if ok && tool.URL == url {
+		return fetch.VerifiedFile(tool, outPath)
+	}
+	ctx.CategoryWarnf(gcp.WarningSecurity, "%s %s is not pinned in the tools manifest, its download from %s cannot be verified", name, version, url)
+	return fetch.File(url, outPath)
+}
+
+func isCached(ctx *gcp.Context, l *libcnb.Layer, version string) bool {
+	return ctx.GetMetadata(l, versionKey) == version && ctx.GetMetadata(l, stackKey) == ctx.StackID()
+}
+
+// AppName returns the application name declared in the project definition of mix.exs, which is
+// the default name of its release.
+func AppName(ctx *gcp.Context) (string, error) {
+	content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), "mix.exs"))
+	if err != nil {
+		return "", err
+	}
+	m := appNameRegexp.FindSubmatch(content)
+	if m == nil {
+		return "", gcp.UserErrorf("finding the application name in mix.exs, the project must declare `app: :name`")
+	}
+	return string(m[1]), nil
+}
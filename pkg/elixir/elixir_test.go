@@ -0,0 +1,150 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elixir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetectVersions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		toolVersions string
+		env          map[string]string
+		want         Versions
+	}{
+		{
+			name: "defaults",
+			want: Versions{Erlang: "26.2.5", Elixir: "1.16.3", ElixirOTP: "26"},
+		},
+		{
+			name:         "tool versions",
+			toolVersions: "# pinned\nerlang 25.3.2.9\nelixir 1.15.7-otp-25 1.15.7\nnodejs 20.11.0\n",
+			want:         Versions{Erlang: "25.3.2.9", Elixir: "1.15.7", ElixirOTP: "25"},
+		},
+		{
+			name:         "elixir without otp suffix uses erlang major",
+			toolVersions: "erlang 27.0\nelixir 1.17.1\n",
+			want:         Versions{Erlang: "27.0", Elixir: "1.17.1", ElixirOTP: "27"},
+		},
+		{
+			name:         "env overrides tool versions",
+			toolVersions: "erlang 25.3.2.9\nelixir 1.15.7-otp-25\n",
+			env:          map[string]string{"GOOGLE_ERLANG_VERSION": "26.2.1", "GOOGLE_RUNTIME_VERSION": "1.16.0"},
+			want:         Versions{Erlang: "26.2.1", Elixir: "1.16.0", ElixirOTP: "26"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_ERLANG_VERSION", "")
+			t.Setenv("GOOGLE_RUNTIME_VERSION", "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			if tc.toolVersions != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".tool-versions"), []byte(tc.toolVersions), 0644); err != nil {
+					t.Fatalf("writing .tool-versions: %v", err)
+				}
+			}
+
+			got, err := DetectVersions(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("DetectVersions() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("DetectVersions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstallCached(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithStackID("google.22"))
+	erlang := &libcnb.Layer{Name: "erlang", Path: t.TempDir(), Metadata: map[string]any{"version": "26.2.5", "stack": "google.22"}}
+	if err := InstallErlang(ctx, erlang, "26.2.5"); err != nil {
+		t.Errorf("InstallErlang() got error: %v", err)
+	}
+	elixir := &libcnb.Layer{Name: "elixir", Path: t.TempDir(), Metadata: map[string]any{"version": "1.16.3-otp-26", "stack": "google.22"}}
+	if err := InstallElixir(ctx, elixir, "1.16.3", "26"); err != nil {
+		t.Errorf("InstallElixir() got error: %v", err)
+	}
+}
+
+func TestDefaultVersionsPinned(t *testing.T) {
+	for name, version := range map[string]string{
+		"erlang-ubuntu-18.04": defaultErlangVersion,
+		"erlang-ubuntu-22.04": defaultErlangVersion,
+		"elixir-otp-26":       defaultElixirVersion,
+	} {
+		if _, ok, err := fetch.PinnedToolVersion(name, version); err != nil || !ok {
+			t.Errorf("PinnedToolVersion(%q, %q) = %t, %v, want the default version pinned", name, version, ok, err)
+		}
+	}
+}
+
+func TestAppName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mixExs  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "phoenix project",
+			mixExs: `defmodule Hello.MixProject do
+  use Mix.Project
+
+  def project do
+    [
+      app: :hello,
+      version: "0.1.0",
+      elixir: "~> 1.14",
+      deps: deps()
+    ]
+  end
+end
+`,
+			want: "hello",
+		},
+		{
+			name:    "missing app",
+			mixExs:  "defmodule Hello.MixProject do\nend\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "mix.exs"), []byte(tc.mixExs), 0644); err != nil {
+				t.Fatalf("writing mix.exs: %v", err)
+			}
+			got, err := AppName(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AppName() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("AppName() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
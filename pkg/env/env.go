@@ -28,6 +28,11 @@ const (
 	// Example: `nodejs` will cause the nodejs/runtime buildpack to opt-in.
 	Runtime = "GOOGLE_RUNTIME"
 
+	// Framework is an env var used to skip the autodetection of framework buildpacks. The buildpack of
+	// the given framework opts in and the buildpacks of other frameworks opt out.
+	// Example: `nextjs` will cause the nodejs/firebasenextjs buildpack to opt-in.
+	Framework = "GOOGLE_FRAMEWORK"
+
 	// RuntimeVersion is an env var used to specify which runtime version to install.
 	// RuntimeVersion must be respected by each runtime buildpack.
 	// Example: `13.7.0` for Node.js, `1.14.1` for Go.
@@ -37,6 +42,99 @@ const (
 	// Example: `true`, `True`, `1` will enable development mode.
 	DebugMode = "GOOGLE_DEBUG"
 
+	// DebugTarball enables writing a snapshot of the workspace and layer directories to the builder
+	// output directory to help debug failed builds. Files that may contain secrets are redacted.
+	// Example: `true` writes a snapshot when a buildpack fails, `always` writes one after every buildpack.
+	DebugTarball = "GOOGLE_DEBUG_TARBALL"
+
+	// DebugTarballAlways is the DebugTarball value used to snapshot after every buildpack.
+	DebugTarballAlways = "always"
+
+	// DebugPauseOnFailure keeps the build container alive for the given duration when a buildpack
+	// fails so that the failing state can be inspected interactively. It is ignored on hosted
+	// platforms (App Engine, Cloud Functions, Flex) where the build container cannot be attached to.
+	// Example: `10m` pauses for ten minutes after a failure.
+	DebugPauseOnFailure = "GOOGLE_DEBUG_PAUSE_ON_FAILURE"
+
+	// ExplainConfig enables logging a table of the effective build settings and the configuration
+	// source (env var, apphosting.yaml, composer.json, app.yaml or default) each value came from.
+	// The table is always included in the builder output.
+	// Example: `true`, `True`, `1` will log the table.
+	ExplainConfig = "GOOGLE_EXPLAIN_CONFIG"
+
+	// DetectPlanDir is the directory where each buildpack records the outcome of its detect phase.
+	// The records are included in the builder output, logged by the first buildpack to build when
+	// ExplainConfig is enabled, and can be rendered with tools/detectplan.
+	// Example: `/workspace/.detect-plan`, defaults to a directory under $TMPDIR.
+	DetectPlanDir = "GOOGLE_DETECT_PLAN_DIR"
+
+	// StrictConfig fails the build when configuration contains keys which are not recognized, such as
+	// unknown keys in the google-buildpacks composer.json extra or the app.yaml runtime_config.
+	// Otherwise unrecognized keys only produce a warning.
+	// Example: `true`, `True`, `1` will enable strict validation.
+	StrictConfig = "GOOGLE_STRICT_CONFIG"
+
+	// FailOnWarnings fails the build when a buildpack emits more warnings of the given categories than
+	// allowed by WarningsBudget. Categories are `deprecation`, `security` and `size`.
+	// Example: `deprecation,security`, or `all` for every category.
+	FailOnWarnings = "GOOGLE_FAIL_ON_WARNINGS"
+
+	// WarningsBudget is the number of warnings of the categories selected with FailOnWarnings each
+	// buildpack may emit before the build fails.
+	// Example: `3`, defaults to 0.
+	WarningsBudget = "GOOGLE_WARNINGS_BUDGET"
+
+	// NormalizeFileModes normalizes the modes of the files in launch layers and in the application
+	// directory, which are world-writable in some CI checkouts. Directories are set to 0777 and
+	// files to 0666, or 0777 if they are executable, with the bits of the given umask cleared. The
+	// umask must have a leading zero and remove the write permission of others.
+	// Example: `true` or `1` for a umask of 022 (directories 755, files 644), or `027`.
+	NormalizeFileModes = "GOOGLE_NORMALIZE_FILE_MODES"
+
+	// BuildTimeBudget is the total time the buildpacks of a build may take. The build fails as soon
+	// as the budget is exhausted, with the time taken by each buildpack and the slowest commands.
+	// Example: `10m`, or `600` seconds.
+	BuildTimeBudget = "GOOGLE_BUILD_TIME_BUDGET"
+
+	// BuildPhaseTimeBudgets sets soft time limits for individual buildpacks, which produce a warning
+	// when exceeded and are reported in the breakdown when the BuildTimeBudget is exhausted.
+	// Example: `google.nodejs.npm=5m,google.python.pip=90s`.
+	BuildPhaseTimeBudgets = "GOOGLE_BUILD_PHASE_TIME_BUDGETS"
+
+	// BuildInfo is an env var used to serve the build metadata (build ID, commit, build time and
+	// adapter version) from nginx at /__build.json and in an X-Build-Id response header.
+	// The build ID and commit are read from BUILD_ID and COMMIT_SHA as set by Cloud Build.
+	// Example: `true`, `True`, `1` will serve the build metadata.
+	BuildInfo = "GOOGLE_BUILD_INFO"
+
+	// BuildCommit is an env var used to set the commit recorded in the build metadata when the CI
+	// system does not set COMMIT_SHA.
+	// Example: `4b825dc642cb6eb9a060e54bf8d69288fbee4904`.
+	BuildCommit = "GOOGLE_BUILD_COMMIT"
+
+	// BuildAdapterVersion is an env var used to set the framework adapter version recorded in the
+	// build metadata.
+	// Example: `14.0.1`.
+	BuildAdapterVersion = "GOOGLE_BUILD_ADAPTER_VERSION"
+
+	// EnforceCacheIsolation is an env var used by builder operators to only reuse cache layers
+	// written in the same cache namespace, which the platform writes to the cache-namespace file of
+	// the platform directory. Cache layers of builds without a namespace are not reused.
+	// Example: `true`, `True`, `1` will enforce cache isolation.
+	EnforceCacheIsolation = "GOOGLE_ENFORCE_CACHE_ISOLATION"
+
+	// BuildHosts is an env var used to resolve hosts to fixed addresses during the build, such as
+	// registries which are only reachable with split-horizon DNS. Entries are `host=address` and
+	// repeated hosts have multiple addresses. Downloads of the buildpacks always use them, commands
+	// such as npm only if /etc/hosts is writable in the build container.
+	// Example: `registry.corp.example=10.0.0.5,npm.corp.example=10.0.0.6`.
+	BuildHosts = "GOOGLE_BUILD_HOSTS"
+
+	// BuildResolver is an env var used to set the DNS server hosts are resolved with during the
+	// build, with the same scope as BuildHosts.
+	// Example: `10.0.0.53` or `10.0.0.53:5353`.
+	BuildResolver = "GOOGLE_BUILD_RESOLVER"
+
 	// DevMode is an env var used to enable development mode in buildpacks.
 	// DevMode should be respected by all buildpacks that are not product-specific.
 	// Example: `true`, `True`, `1` will enable development mode.
@@ -51,6 +149,68 @@ const (
 	// Buildpacks for Go and Java support clearing the source.
 	ClearSource = "GOOGLE_CLEAR_SOURCE"
 
+	// KeepSecretFiles is an env var used to keep credentials, such as private keys, and local .env
+	// files, such as .env.local, in the final image. By default they are removed after the build,
+	// unless the launch environment refers to them. Patterns are matched against the path relative to
+	// the application root and the file name.
+	// Example: `certs/*.pem,deploy.key`, or `*` to keep all of them.
+	KeepSecretFiles = "GOOGLE_KEEP_SECRET_FILES"
+
+	// AssetsRuntime is an env var used to build the static assets of an application served by
+	// another runtime. The assets are built before the server runtime, and the dependencies of the
+	// assets runtime are not included in the final image.
+	// Example: `nodejs` to build the front end of a PHP or Python application with its package.json.
+	AssetsRuntime = "GOOGLE_ASSETS_RUNTIME"
+
+	// ToolChecksums is an env var used to override the SHA-256 digests pinned for the tools the
+	// buildpacks download at build time, for example when they are served from a mirror which
+	// repackages them.
+	// Example: `zig=d45312e61ebcc48032b77bc4cf7fd6915c11fa16e4aad116b66c9468211230ea`.
+	ToolChecksums = "GOOGLE_TOOL_CHECKSUMS"
+
+	// SmokeTest is an env var used to start the application in the build container after it is
+	// built and fail the build if it exits or does not listen on PORT, before the image is exported.
+	// Example: `true` to run the smoke test.
+	SmokeTest = "GOOGLE_SMOKE_TEST"
+
+	// SmokeTestPath is an env var used to request a path of the application during the smoke test.
+	// The build fails if the response status is 400 or higher.
+	// Example: `/healthz`.
+	SmokeTestPath = "GOOGLE_SMOKE_TEST_PATH"
+
+	// SmokeTestTimeout is an env var used to configure how long the smoke test waits for the
+	// application to listen on PORT. Defaults to 30s.
+	// Example: `2m`.
+	SmokeTestTimeout = "GOOGLE_SMOKE_TEST_TIMEOUT"
+
+	// WarmupURLs is an env var used to request paths of the application when the container starts,
+	// once the application listens, so that the first requests do not pay for lazy initialization
+	// and JIT compilation.
+	// Example: `/,/api/health`.
+	WarmupURLs = "GOOGLE_WARMUP_URLS"
+
+	// WarmupCommand is an env var used to run a shell command when the container starts, once the
+	// application listens. The URL of the application is set in WARMUP_URL.
+	// Example: `php artisan cache:warm` or `curl -s $WARMUP_URL/graphql -d @warmup.json`.
+	WarmupCommand = "GOOGLE_WARMUP_COMMAND"
+
+	// WarmupTimeout is an env var used to configure how long the application has to listen and the
+	// warm-up actions have to complete. Defaults to 60s.
+	// Example: `2m`.
+	WarmupTimeout = "GOOGLE_WARMUP_TIMEOUT"
+
+	// WarmupProxy is an env var used to only accept connections on PORT after the warm-up. The
+	// application then listens on an internal port and a TCP proxy forwards the connections to it
+	// for the lifetime of the container. Defaults to false.
+	// Example: `true`.
+	WarmupProxy = "GOOGLE_WARMUP_PROXY"
+
+	// DisableTelemetry is an env var used to control whether the telemetry sent by framework CLIs
+	// and adapters, such as Next.js and Astro, is disabled during the build and at runtime.
+	// Defaults to true. Opt-out env vars set by the user are kept as is.
+	// Example: `false` keeps the telemetry of the frameworks enabled.
+	DisableTelemetry = "GOOGLE_DISABLE_TELEMETRY"
+
 	// Buildable is an env var used to specify the buildable unit to build.
 	// Buildable should be respected by buildpacks that build source.
 	// Example: `./maindir` for Go will build the package rooted at maindir.
@@ -99,6 +259,14 @@ const (
 	// GoLDFlags is an env var used to pass through linker flags to the Go linker.
 	// Example: `-s -w` is sometimes used to strip and reduce binary size.
 	GoLDFlags = "GOOGLE_GOLDFLAGS"
+	// GoCGOEnabled is an env var used to set CGO_ENABLED for the Go build. If unset, cgo is enabled
+	// when go.mod requires a module that needs it, such as go-sqlite3 or confluent-kafka-go.
+	// Example: `false` builds without cgo.
+	GoCGOEnabled = "GOOGLE_GO_CGO_ENABLED"
+	// GoStatic is an env var used to build a fully statically linked Go binary. cgo is disabled
+	// and the netgo and osusergo build tags are used unless cgo is required.
+	// Example: `true`, `True`, `1` will build a static binary.
+	GoStatic = "GOOGLE_GO_STATIC"
 
 	// UseNativeImage is used to enable the GraalVM Java buildpack for native image compilation.
 	// Example: `true`, `True`, `1` will enable development mode.
@@ -170,6 +338,16 @@ func IsDebugMode() (bool, error) {
 	return IsPresentAndTrue(DebugMode)
 }
 
+// DebugTarballMode returns whether a debug snapshot should be written on failure and whether one
+// should be written after every buildpack, regardless of the outcome.
+func DebugTarballMode() (onFailure, always bool, err error) {
+	if os.Getenv(DebugTarball) == DebugTarballAlways {
+		return true, true, nil
+	}
+	onFailure, err = IsPresentAndTrue(DebugTarball)
+	return onFailure, false, err
+}
+
 // IsDevMode indicates that the builder is running in Development mode.
 func IsDevMode() (bool, error) {
 	return IsPresentAndTrue(DevMode)
@@ -46,22 +46,22 @@ func Tarball(url, dir string, stripComponents int) error {
 
 // ARVersions downloads list of versions from artifact registry.
 var ARVersions = func(url, fallbackURL string, ctx *gcp.Context) ([]string, error) {
-	versions, err := crane.ListTags(url)
+	versions, err := crane.ListTags(url, crane.WithTransport(gcp.HTTPTransport()))
 	if err != nil || len(versions) == 0 {
 		ctx.Logf("Failed to list versions from %s. Size of versions is %d. Error is: %v", url, len(versions), err)
 		ctx.Logf("Attempting to list versions from %s as a fallback", fallbackURL)
-		versions, err = crane.ListTags(fallbackURL)
+		versions, err = crane.ListTags(fallbackURL, crane.WithTransport(gcp.HTTPTransport()))
 	}
 	return versions, err
 }
 
 // ARImage downloads tarball from images in artifact registry.
 var ARImage = func(url, fallbackURL, dir string, stripComponents int, ctx *gcp.Context) error {
-	image, err := crane.Pull(url)
+	image, err := crane.Pull(url, crane.WithTransport(gcp.HTTPTransport()))
 	if err != nil {
 		ctx.Logf("Failed to download runtime from %s: %v", url, err)
 		ctx.Logf("Attempting to download from %s as a fallback", fallbackURL)
-		image, err = crane.Pull(fallbackURL)
+		image, err = crane.Pull(fallbackURL, crane.WithTransport(gcp.HTTPTransport()))
 		if err != nil {
 			return err
 		}
@@ -255,6 +255,7 @@ func isValidTarDestination(dest, rootDir string, tarType byte) bool {
 func doGet(url string) (*http.Response, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
+	retryClient.HTTPClient = gcp.HTTPClient()
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, gcp.UserErrorf("fetching %s: %v", url, err)
@@ -0,0 +1,142 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// toolsManifest pins the version, download URL and SHA-256 digest of every tool the buildpacks
+// download at a fixed version. Bumping a tool requires updating all three.
+//
+//go:embed tools.json
+var toolsManifest []byte
+
+// Tool is a tool downloaded at build time.
+type Tool struct {
+	// Name of the tool in the manifest, for example `zig`.
+	Name    string `json:"-"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	// SHA256 is the hex encoded digest of the file at URL.
+	SHA256 string `json:"sha256"`
+}
+
+// PinnedTool returns the named tool from the manifest, with its digest replaced by any override
+// set in env.ToolChecksums.
+func PinnedTool(name string) (Tool, error) {
+	tool, ok, err := pinnedTool(name)
+	if err != nil {
+		return Tool{}, err
+	}
+	if !ok {
+		return Tool{}, gcp.InternalErrorf("tool %q is not pinned in the tools manifest", name)
+	}
+	return tool, nil
+}
+
+// PinnedToolVersion returns the named tool from the manifest like PinnedTool, and false if the
+// manifest does not list the tool or pins another version of it, such as a version selected by
+// the application.
+func PinnedToolVersion(name, version string) (Tool, bool, error) {
+	tool, ok, err := pinnedTool(name)
+	if err != nil || !ok || tool.Version != version {
+		return Tool{}, false, err
+	}
+	return tool, true, nil
+}
+
+func pinnedTool(name string) (Tool, bool, error) {
+	var tools map[string]Tool
+	if err := json.Unmarshal(toolsManifest, &tools); err != nil {
+		return Tool{}, false, gcp.InternalErrorf("parsing tools manifest: %v", err)
+	}
+	tool, ok := tools[name]
+	if !ok {
+		return Tool{}, false, nil
+	}
+	tool.Name = name
+	overrides, err := checksumOverrides()
+	if err != nil {
+		return Tool{}, false, err
+	}
+	if digest, ok := overrides[name]; ok {
+		tool.SHA256 = digest
+	}
+	return tool, true, nil
+}
+
+// checksumOverrides parses env.ToolChecksums into a map of tool name to digest.
+func checksumOverrides() (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, entry := range strings.Split(os.Getenv(env.ToolChecksums), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, digest, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, gcp.UserErrorf("invalid entry %q in %s, want <tool>=<sha256>", entry, env.ToolChecksums)
+		}
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+			return nil, gcp.UserErrorf("invalid SHA-256 digest %q for %q in %s", digest, name, env.ToolChecksums)
+		}
+		overrides[name] = strings.ToLower(digest)
+	}
+	return overrides, nil
+}
+
+// VerifiedFile downloads the tool to outPath and verifies it against the pinned digest. The file
+// is removed if the digest does not match. Tools without a pinned digest are not downloaded.
+func VerifiedFile(tool Tool, outPath string) error {
+	if tool.SHA256 == "" {
+		return gcp.InternalErrorf("%s %s has no SHA-256 digest pinned in the tools manifest; set %s=%s=<sha256> to provide it", tool.Name, tool.Version, env.ToolChecksums, tool.Name)
+	}
+	if err := verifiedFile(tool, outPath); err != nil {
+		os.Remove(outPath)
+		return err
+	}
+	return nil
+}
+
+func verifiedFile(tool Tool, outPath string) error {
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	response, err := doGet(tool.URL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), response.Body); err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", tool.URL, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != strings.ToLower(tool.SHA256) {
+		return gcp.UserErrorf("checksum of %s %s from %s does not match: got sha256 %s, want %s; set %s to override it", tool.Name, tool.Version, tool.URL, got, tool.SHA256, env.ToolChecksums)
+	}
+	return nil
+}
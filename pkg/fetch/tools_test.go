@@ -0,0 +1,177 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+)
+
+// testJSONDigest is the SHA-256 digest of testdata/test.json.
+const testJSONDigest = "5f8f04f6a3a892aaabbddb6cf273894493773960d4a325b105fee46eef4304f1"
+
+func TestPinnedTool(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tool       string
+		checksums  string
+		wantSHA256 string
+		wantError  bool
+	}{
+		{
+			name:       "pinned",
+			tool:       "zig",
+			wantSHA256: "d45312e61ebcc48032b77bc4cf7fd6915c11fa16e4aad116b66c9468211230ea",
+		},
+		{
+			name:       "override",
+			tool:       "zig",
+			checksums:  "other=" + testJSONDigest + ", zig=" + testJSONDigest,
+			wantSHA256: testJSONDigest,
+		},
+		{
+			name:      "not pinned",
+			tool:      "unknown",
+			wantError: true,
+		},
+		{
+			name:      "invalid override",
+			tool:      "zig",
+			checksums: "zig",
+			wantError: true,
+		},
+		{
+			name:      "invalid override digest",
+			tool:      "zig",
+			checksums: "zig=abc",
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.ToolChecksums, tc.checksums)
+
+			got, err := PinnedTool(tc.tool)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("PinnedTool(%q) got error: %v, want error? %v", tc.tool, err, tc.wantError)
+			}
+			if got.SHA256 != tc.wantSHA256 {
+				t.Errorf("PinnedTool(%q).SHA256 = %q, want %q", tc.tool, got.SHA256, tc.wantSHA256)
+			}
+		})
+	}
+}
+
+func TestPinnedToolVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tool    string
+		version string
+		want    bool
+	}{
+		{
+			name:    "pinned version",
+			tool:    "zig",
+			version: "0.13.0",
+			want:    true,
+		},
+		{
+			name:    "other version",
+			tool:    "zig",
+			version: "0.12.0",
+		},
+		{
+			name:    "not pinned",
+			tool:    "unknown",
+			version: "1.0.0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok, err := PinnedToolVersion(tc.tool, tc.version)
+			if err != nil {
+				t.Fatalf("PinnedToolVersion(%q, %q) got error: %v", tc.tool, tc.version, err)
+			}
+			if ok != tc.want {
+				t.Errorf("PinnedToolVersion(%q, %q) = %t, want %t", tc.tool, tc.version, ok, tc.want)
+			}
+			if ok && got.Version != tc.version {
+				t.Errorf("PinnedToolVersion(%q, %q).Version = %q, want %q", tc.tool, tc.version, got.Version, tc.version)
+			}
+		})
+	}
+}
+
+func TestToolsManifest(t *testing.T) {
+	var tools map[string]Tool
+	if err := json.Unmarshal(toolsManifest, &tools); err != nil {
+		t.Fatalf("parsing tools manifest: %v", err)
+	}
+	for name, tool := range tools {
+		if tool.Version == "" || !strings.Contains(tool.URL, tool.Version) {
+			t.Errorf("tool %q has version %q and URL %q, want the URL to contain the version", name, tool.Version, tool.URL)
+		}
+		if b, err := hex.DecodeString(tool.SHA256); err != nil || len(b) != sha256.Size {
+			t.Errorf("tool %q has invalid SHA-256 digest %q", name, tool.SHA256)
+		}
+	}
+}
+
+func TestVerifiedFile(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sha256    string
+		wantError bool
+	}{
+		{
+			name:   "matching digest",
+			sha256: testJSONDigest,
+		},
+		{
+			name:      "mismatched digest",
+			sha256:    "d45312e61ebcc48032b77bc4cf7fd6915c11fa16e4aad116b66c9468211230ea",
+			wantError: true,
+		},
+		{
+			name:      "no pinned digest",
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testserver.New(t, testserver.WithFile(testdata.MustGetPath("testdata/test.json")))
+			outPath := filepath.Join(t.TempDir(), "tool")
+			tool := Tool{Name: "test", Version: "1.0.0", URL: server.URL, SHA256: tc.sha256}
+
+			err := VerifiedFile(tool, outPath)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("VerifiedFile(%+v, %q) got error: %v, want error? %v", tool, outPath, err, tc.wantError)
+			}
+			_, statErr := os.Stat(outPath)
+			if exists := statErr == nil; exists == tc.wantError {
+				t.Errorf("VerifiedFile(%+v, %q) file exists = %t, want %t", tool, outPath, exists, !tc.wantError)
+			}
+		})
+	}
+}
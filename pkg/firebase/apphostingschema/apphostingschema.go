@@ -19,18 +19,109 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
 var (
 	validAvailabilityValues = map[string]bool{"BUILD": true, "RUNTIME": true}
+
+	// frameworkRuntimes maps the frameworks which can be pinned in buildConfig.framework to the
+	// runtime they are built with.
+	frameworkRuntimes = map[string]string{
+		"angular":      "nodejs",
+		"astro":        "nodejs",
+		"nextjs":       "nodejs",
+		"nuxt":         "nodejs",
+		"react-router": "nodejs",
+		"remix":        "nodejs",
+		"sveltekit":    "nodejs",
+	}
+
+	// runtimeRegexp matches the runtime names accepted in buildConfig.runtime, such as `nodejs` or
+	// `php83`.
+	runtimeRegexp = regexp.MustCompile(`^[a-z]+[0-9]*$`)
+
+	// sidecarNameRegexp matches the container names accepted in sidecars, which must be DNS labels.
+	sidecarNameRegexp = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+	// maxSidecars is the number of containers a revision can have in addition to the application.
+	maxSidecars = 9
+
+	// validWritablePathTypes are the kinds of storage a writable path can be mounted from.
+	validWritablePathTypes = map[string]bool{WritablePathTmpfs: true, WritablePathVolume: true}
+)
+
+const (
+	// WritablePathTmpfs is an in-memory writable path, which counts against the memory of the
+	// instance and is lost when the instance stops.
+	WritablePathTmpfs = "tmpfs"
+	// WritablePathVolume is a writable path backed by a volume shared by the instances.
+	WritablePathVolume = "volume"
+
+	// appRoot is the directory the application runs from, which relative writable paths are in.
+	appRoot = "/workspace"
 )
 
 // AppHostingSchema is the struct representation of apphosting.yaml.
 type AppHostingSchema struct {
-	RunConfig RunConfig             `yaml:"runConfig,omitempty"`
+	RunConfig   RunConfig             `yaml:"runConfig,omitempty"`
+	Env         []EnvironmentVariable `yaml:"env,omitempty"`
+	BuildConfig BuildConfig           `yaml:"buildConfig,omitempty"`
+	Sidecars    []Sidecar             `yaml:"sidecars,omitempty"`
+	// WritablePaths are the directories the application writes to at runtime, which are mounted
+	// writable since the filesystem of the container can be read-only.
+	WritablePaths []WritablePath `yaml:"writablePaths,omitempty"`
+}
+
+// WritablePath is the struct representation of a directory the application writes to at runtime,
+// for example the cache of incremental static regeneration.
+type WritablePath struct {
+	// Path is the directory, either absolute or relative to the app root, for example `.next/cache`.
+	Path string `yaml:"path"`
+	// Type is the storage the directory is mounted from, WritablePathTmpfs by default.
+	Type string `yaml:"type,omitempty"`
+	// SizeMiB is the size limit of the directory.
+	SizeMiB *int32 `yaml:"sizeMiB,omitempty"`
+}
+
+// AbsPath returns the absolute path of the directory in the container.
+func (wp WritablePath) AbsPath() string {
+	if filepath.IsAbs(wp.Path) {
+		return filepath.Clean(wp.Path)
+	}
+	return filepath.Join(appRoot, wp.Path)
+}
+
+// Contains returns true if the directory rel, relative to the app root, is the writable path or
+// one of its subdirectories.
+func (wp WritablePath) Contains(rel string) bool {
+	p, root := filepath.Join(appRoot, rel), wp.AbsPath()
+	return p == root || strings.HasPrefix(p, root+"/")
+}
+
+// Sidecar is the struct representation of a container which runs next to the application
+// container in the same revision, for example an nginx or proxy container.
+type Sidecar struct {
+	// Name identifies the container within the revision, for example `nginx`.
+	Name string `yaml:"name"`
+	// Image is the container image, for example `us-docker.pkg.dev/my-project/repo/nginx:1.27`.
+	Image string `yaml:"image"`
+	// Ports are the ports the container listens on, which the application reaches on localhost.
+	Ports []int32 `yaml:"ports,omitempty"`
+	// Env are the environment variables of the container. They are only available at runtime.
 	Env       []EnvironmentVariable `yaml:"env,omitempty"`
+	Resources SidecarResources      `yaml:"resources,omitempty"`
+}
+
+// SidecarResources is the struct representation of the resource limits of a sidecar.
+type SidecarResources struct {
+	CPU       *float32 `yaml:"cpu,omitempty"`
+	MemoryMiB *int32   `yaml:"memoryMiB,omitempty"`
 }
 
 // RunConfig is the struct representation of the passed run config.
@@ -41,6 +132,86 @@ type RunConfig struct {
 	Concurrency  *int32   `yaml:"concurrency"`
 	MaxInstances *int32   `yaml:"maxInstances"`
 	MinInstances *int32   `yaml:"minInstances"`
+	// AcceleratorType is the type of GPU attached to each instance, for example `nvidia-l4`.
+	AcceleratorType *string `yaml:"acceleratorType,omitempty"`
+	// AcceleratorCount is the number of GPUs attached to each instance. It defaults to 1 when
+	// AcceleratorType is set.
+	AcceleratorCount *int32 `yaml:"acceleratorCount,omitempty"`
+	// StartupCPUBoost allocates additional CPU while instances start to reduce cold start latency.
+	StartupCPUBoost *bool `yaml:"startupCpuBoost,omitempty"`
+	// SessionAffinity routes requests from the same client to the same instance, which websocket
+	// apps rely on.
+	SessionAffinity *bool `yaml:"sessionAffinity,omitempty"`
+}
+
+const (
+	// acceleratorMinCPU is the minimum number of CPUs of an instance with a GPU attached.
+	acceleratorMinCPU = 4
+	// acceleratorMinMemoryMiB is the minimum memory of an instance with a GPU attached.
+	acceleratorMinMemoryMiB = 16384
+)
+
+// acceleratorMaxCounts maps the supported accelerator types to the maximum number of them that
+// can be attached to an instance.
+var acceleratorMaxCounts = map[string]int32{
+	"nvidia-l4": 1,
+}
+
+// BuildConfig is the struct representation of the passed build config, which steers the Node.js
+// build pipeline for frameworks that are not detected automatically.
+type BuildConfig struct {
+	// BuildCommand replaces the build script, for example `npx vite build`.
+	BuildCommand string `yaml:"buildCommand,omitempty"`
+	// InstallCommand replaces the dependency install command, for example `npm ci --legacy-peer-deps`.
+	InstallCommand string `yaml:"installCommand,omitempty"`
+	// OutputDirectory is the directory of static assets written by the build, relative to the app root.
+	OutputDirectory string `yaml:"outputDirectory,omitempty"`
+	// NodeVersion is the Node.js version constraint, for example `20.x`.
+	NodeVersion string `yaml:"nodeVersion,omitempty"`
+	// Runtime pins the language runtime and skips its detection, for example `php83` for a PHP app
+	// with a package.json used only for tooling.
+	Runtime string `yaml:"runtime,omitempty"`
+	// Framework pins the framework and skips its detection, for example `nextjs`. It implies the
+	// runtime of the framework unless Runtime is set.
+	Framework string `yaml:"framework,omitempty"`
+	// PruneDevDependencies removes devDependencies from node_modules after the build so that build
+	// tools such as typescript and eslint are not included in the image.
+	PruneDevDependencies bool `yaml:"pruneDevDependencies,omitempty"`
+}
+
+// buildConfigEnv maps the build config fields to the env vars read by the Node.js buildpacks.
+var buildConfigEnv = []struct {
+	variable string
+	value    func(bc BuildConfig) string
+}{
+	{"GOOGLE_NODEJS_BUILD_COMMAND", func(bc BuildConfig) string { return bc.BuildCommand }},
+	{"GOOGLE_NODEJS_INSTALL_COMMAND", func(bc BuildConfig) string { return bc.InstallCommand }},
+	{"GOOGLE_NODEJS_OUTPUT_DIR", func(bc BuildConfig) string { return bc.OutputDirectory }},
+	{"GOOGLE_NODEJS_VERSION", func(bc BuildConfig) string { return bc.NodeVersion }},
+	{"GOOGLE_RUNTIME", func(bc BuildConfig) string {
+		if bc.Runtime != "" {
+			return bc.Runtime
+		}
+		return frameworkRuntimes[bc.Framework]
+	}},
+	{"GOOGLE_FRAMEWORK", func(bc BuildConfig) string { return bc.Framework }},
+	{"GOOGLE_NODEJS_PRUNE_DEV_DEPENDENCIES", func(bc BuildConfig) string {
+		if bc.PruneDevDependencies {
+			return "true"
+		}
+		return ""
+	}},
+}
+
+// Env returns the build env vars equivalent to the set build config fields.
+func (bc BuildConfig) Env() map[string]string {
+	env := map[string]string{}
+	for _, e := range buildConfigEnv {
+		if v := strings.TrimSpace(e.value(bc)); v != "" {
+			env[e.variable] = v
+		}
+	}
+	return env
 }
 
 // EnvironmentVariable is the struct representation of the passed environment variables.
@@ -75,6 +246,120 @@ func (ev *EnvironmentVariable) UnmarshalYAML(unmarshal func(any) error) error {
 	return nil
 }
 
+// UnmarshalYAML provides custom validation logic to validate Sidecar
+func (sc *Sidecar) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain Sidecar // Define an alias
+	if err := unmarshal((*plain)(sc)); err != nil {
+		return err
+	}
+
+	if !sidecarNameRegexp.MatchString(sc.Name) {
+		return fmt.Errorf("sidecars.name must be a lowercase DNS label: %q", sc.Name)
+	}
+
+	if sc.Image == "" {
+		return fmt.Errorf("sidecars.image is required for sidecar %s", sc.Name)
+	}
+
+	for _, port := range sc.Ports {
+		if !(1 <= port && port <= 65535) {
+			return fmt.Errorf("sidecars.ports of sidecar %s is not in valid range of [1, 65535]: %d", sc.Name, port)
+		}
+	}
+
+	for _, ev := range sc.Env {
+		for _, val := range ev.Availability {
+			if val != "RUNTIME" {
+				return fmt.Errorf("sidecars.env of sidecar %s can only be available at RUNTIME: %s", sc.Name, ev.Variable)
+			}
+		}
+	}
+
+	if cpu := sc.Resources.CPU; cpu != nil && !(0 < *cpu && *cpu <= 8) {
+		return fmt.Errorf("sidecars.resources.cpu of sidecar %s is not in valid range of (0, 8]", sc.Name)
+	}
+
+	if mem := sc.Resources.MemoryMiB; mem != nil && !(128 <= *mem && *mem <= 32768) {
+		return fmt.Errorf("sidecars.resources.memoryMiB of sidecar %s is not in valid range of [128, 32768]", sc.Name)
+	}
+
+	return nil
+}
+
+// validateSidecars checks the constraints between the sidecars of a revision.
+func validateSidecars(sidecars []Sidecar) error {
+	if len(sidecars) > maxSidecars {
+		return fmt.Errorf("at most %d sidecars can be declared, got %d", maxSidecars, len(sidecars))
+	}
+	names := map[string]bool{}
+	ports := map[int32]string{}
+	for _, sc := range sidecars {
+		if names[sc.Name] {
+			return fmt.Errorf("sidecars.name must be unique: %s", sc.Name)
+		}
+		names[sc.Name] = true
+		for _, port := range sc.Ports {
+			if other, ok := ports[port]; ok {
+				return fmt.Errorf("sidecars %s and %s both listen on port %d", other, sc.Name, port)
+			}
+			ports[port] = sc.Name
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML provides custom validation logic to validate WritablePath
+func (wp *WritablePath) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain WritablePath // Define an alias
+	if err := unmarshal((*plain)(wp)); err != nil {
+		return err
+	}
+
+	if wp.Path == "" {
+		return fmt.Errorf("writablePaths.path is required")
+	}
+	if p := wp.AbsPath(); p == "/" || p == appRoot {
+		return fmt.Errorf("writablePaths.path must be a subdirectory, not %s", wp.Path)
+	}
+	if rel := filepath.Clean(wp.Path); !filepath.IsAbs(wp.Path) && (rel == ".." || strings.HasPrefix(rel, "../")) {
+		return fmt.Errorf("writablePaths.path must be inside the app root unless it is absolute: %s", wp.Path)
+	}
+
+	if wp.Type == "" {
+		wp.Type = WritablePathTmpfs
+	}
+	if !validWritablePathTypes[wp.Type] {
+		return fmt.Errorf("writablePaths.type of %s must be %s or %s: %s", wp.Path, WritablePathTmpfs, WritablePathVolume, wp.Type)
+	}
+
+	if size := wp.SizeMiB; size != nil && !(1 <= *size && *size <= 32768) {
+		return fmt.Errorf("writablePaths.sizeMiB of %s is not in valid range of [1, 32768]", wp.Path)
+	}
+
+	return nil
+}
+
+// validateWritablePaths checks the constraints between the writable paths and the run config.
+func validateWritablePaths(paths []WritablePath, rc RunConfig) error {
+	seen := map[string]bool{}
+	var tmpfsMiB int32
+	for _, wp := range paths {
+		p := wp.AbsPath()
+		if seen[p] {
+			return fmt.Errorf("writablePaths.path must be unique: %s", wp.Path)
+		}
+		seen[p] = true
+		if wp.Type == WritablePathTmpfs && wp.SizeMiB != nil {
+			tmpfsMiB += *wp.SizeMiB
+		}
+	}
+	// In-memory directories share the memory of the instance with the application.
+	if rc.MemoryMiB != nil && tmpfsMiB >= *rc.MemoryMiB {
+		return fmt.Errorf("writablePaths of type %s use %d MiB, which must be less than runConfig.memoryMiB %d", WritablePathTmpfs, tmpfsMiB, *rc.MemoryMiB)
+	}
+	return nil
+}
+
 // UnmarshalYAML provides custom validation logic to validate RunConfig
 func (rc *RunConfig) UnmarshalYAML(unmarshal func(any) error) error {
 	type plain RunConfig // Define an alias
@@ -107,6 +392,69 @@ func (rc *RunConfig) UnmarshalYAML(unmarshal func(any) error) error {
 		return fmt.Errorf("runConfig.minInstances field is not in valid range of [1, 100]")
 	}
 
+	// Validation for 'AcceleratorType' and 'AcceleratorCount'
+	if rc.AcceleratorCount != nil && rc.AcceleratorType == nil {
+		return fmt.Errorf("runConfig.acceleratorCount field requires runConfig.acceleratorType")
+	}
+	if rc.AcceleratorType != nil {
+		maxCount, ok := acceleratorMaxCounts[*rc.AcceleratorType]
+		if !ok {
+			return fmt.Errorf("runConfig.acceleratorType field %q is not one of the supported types %v", *rc.AcceleratorType, supportedAcceleratorTypes())
+		}
+		if rc.AcceleratorCount != nil && !(1 <= *rc.AcceleratorCount && *rc.AcceleratorCount <= maxCount) {
+			return fmt.Errorf("runConfig.acceleratorCount field is not in valid range of [1, %d] for %s", maxCount, *rc.AcceleratorType)
+		}
+		if rc.CPU != nil && *rc.CPU < acceleratorMinCPU {
+			return fmt.Errorf("runConfig.cpu field must be at least %d when an accelerator is attached", acceleratorMinCPU)
+		}
+		if rc.MemoryMiB != nil && *rc.MemoryMiB < acceleratorMinMemoryMiB {
+			return fmt.Errorf("runConfig.memoryMiB field must be at least %d when an accelerator is attached", acceleratorMinMemoryMiB)
+		}
+	}
+
+	return nil
+}
+
+func supportedAcceleratorTypes() []string {
+	var types []string
+	for t := range acceleratorMaxCounts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// UnmarshalYAML provides custom validation logic to validate BuildConfig
+func (bc *BuildConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain BuildConfig // Define an alias
+	if err := unmarshal((*plain)(bc)); err != nil {
+		return err
+	}
+
+	if bc.OutputDirectory != "" {
+		if filepath.IsAbs(bc.OutputDirectory) {
+			return fmt.Errorf("buildConfig.outputDirectory must be relative to the app root: %s", bc.OutputDirectory)
+		}
+		if rel := filepath.Clean(bc.OutputDirectory); rel == ".." || strings.HasPrefix(rel, "../") {
+			return fmt.Errorf("buildConfig.outputDirectory must be inside the app root: %s", bc.OutputDirectory)
+		}
+	}
+
+	if bc.Runtime != "" && !runtimeRegexp.MatchString(bc.Runtime) {
+		return fmt.Errorf("buildConfig.runtime must be a runtime name such as nodejs or php83: %s", bc.Runtime)
+	}
+
+	if bc.Framework != "" {
+		if _, ok := frameworkRuntimes[bc.Framework]; !ok {
+			var frameworks []string
+			for f := range frameworkRuntimes {
+				frameworks = append(frameworks, f)
+			}
+			sort.Strings(frameworks)
+			return fmt.Errorf("buildConfig.framework must be one of %s: %s", strings.Join(frameworks, ", "), bc.Framework)
+		}
+	}
+
 	return nil
 }
 
@@ -125,5 +473,11 @@ func ReadAndValidateAppHostingSchemaFromFile(filePath string) (AppHostingSchema,
 	if err = yaml.Unmarshal(apphostingBuffer, &a); err != nil {
 		return a, fmt.Errorf("unmarshalling apphosting config as YAML: %w", err)
 	}
+	if err := validateSidecars(a.Sidecars); err != nil {
+		return a, fmt.Errorf("validating apphosting config: %w", err)
+	}
+	if err := validateWritablePaths(a.WritablePaths, a.RunConfig); err != nil {
+		return a, fmt.Errorf("validating apphosting config: %w", err)
+	}
 	return a, nil
 }
@@ -19,7 +19,16 @@ func float32Ptr(i int32) *float32 {
 	return v
 }
 
+func stringPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
 func TestReadAndValidateAppHostingSchemaFromFile(t *testing.T) {
+	halfCPU := float32(0.5)
 	testCases := []struct {
 		desc                 string
 		inputAppHostingYAML  string
@@ -74,6 +83,138 @@ func TestReadAndValidateAppHostingSchemaFromFile(t *testing.T) {
 			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidrunconfig.yaml"),
 			wantErr:             true,
 		},
+		{
+			desc:                "Read YAML schema with an accelerator properly",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_accelerator.yaml"),
+			wantAppHostingSchema: AppHostingSchema{
+				RunConfig: RunConfig{
+					CPU:              float32Ptr(4),
+					MemoryMiB:        int32Ptr(16384),
+					MaxInstances:     int32Ptr(2),
+					AcceleratorType:  stringPtr("nvidia-l4"),
+					AcceleratorCount: int32Ptr(1),
+				},
+			},
+		},
+		{
+			desc:                "Throw an error when an accelerator is attached to an instance with too few CPUs",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidaccelerator.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Throw an error when the accelerator type is not supported",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidacceleratortype.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Read YAML schema with startup CPU boost and session affinity properly",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_startup.yaml"),
+			wantAppHostingSchema: AppHostingSchema{
+				RunConfig: RunConfig{
+					MinInstances:    int32Ptr(1),
+					StartupCPUBoost: boolPtr(true),
+					SessionAffinity: boolPtr(false),
+				},
+			},
+		},
+		{
+			desc:                "Throw an error when session affinity is not a boolean",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidsessionaffinity.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Read YAML schema with a build config section properly",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_buildconfig.yaml"),
+			wantAppHostingSchema: AppHostingSchema{
+				BuildConfig: BuildConfig{
+					BuildCommand:         "npx vite build",
+					InstallCommand:       "npm ci --legacy-peer-deps",
+					OutputDirectory:      "dist",
+					NodeVersion:          "20.x",
+					PruneDevDependencies: true,
+				},
+			},
+		},
+		{
+			desc:                "Throw an error when the build config output directory is outside the app root",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidbuildconfig.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Read YAML schema with a pinned runtime and framework properly",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_pinnedbuildconfig.yaml"),
+			wantAppHostingSchema: AppHostingSchema{
+				BuildConfig: BuildConfig{
+					Runtime:   "php83",
+					Framework: "nextjs",
+				},
+			},
+		},
+		{
+			desc:                "Throw an error when the build config framework is unknown",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidframework.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Read YAML schema with sidecars properly",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_sidecars.yaml"),
+			wantAppHostingSchema: AppHostingSchema{
+				Sidecars: []Sidecar{
+					{
+						Name:  "nginx",
+						Image: "us-docker.pkg.dev/my-project/repo/nginx:1.27",
+						Ports: []int32{8081},
+						Env: []EnvironmentVariable{
+							{Variable: "UPSTREAM", Value: "localhost:8080"},
+						},
+						Resources: SidecarResources{
+							CPU:       &halfCPU,
+							MemoryMiB: int32Ptr(256),
+						},
+					},
+					{
+						Name:  "proxy",
+						Image: "gcr.io/cloud-sql-connectors/cloud-sql-proxy:2.11.0",
+					},
+				},
+			},
+		},
+		{
+			desc:                "Throw an error when sidecar names are not unique",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidsidecars.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Throw an error when a sidecar has no image",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidsidecarimage.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Read YAML schema with writable paths properly",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_writablepaths.yaml"),
+			wantAppHostingSchema: AppHostingSchema{
+				RunConfig: RunConfig{MemoryMiB: int32Ptr(1024)},
+				WritablePaths: []WritablePath{
+					{Path: ".next/cache", Type: WritablePathTmpfs, SizeMiB: int32Ptr(256)},
+					{Path: "/data", Type: WritablePathVolume},
+				},
+			},
+		},
+		{
+			desc:                "Throw an error when writable paths are not unique",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidwritablepaths.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Throw an error when a writable path type is invalid",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidwritablepathtype.yaml"),
+			wantErr:             true,
+		},
+		{
+			desc:                "Throw an error when in-memory writable paths use all the memory",
+			inputAppHostingYAML: testdata.MustGetPath("testdata/apphosting_invalidwritablepathsize.yaml"),
+			wantErr:             true,
+		},
 	}
 
 	for _, test := range testCases {
@@ -97,3 +238,76 @@ func TestReadAndValidateAppHostingSchemaFromFile(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildConfigEnv(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		buildConfig BuildConfig
+		want        map[string]string
+	}{
+		{
+			desc:        "empty build config",
+			buildConfig: BuildConfig{},
+			want:        map[string]string{},
+		},
+		{
+			desc: "all fields set",
+			buildConfig: BuildConfig{
+				BuildCommand:         "npx vite build",
+				InstallCommand:       "npm ci",
+				OutputDirectory:      "dist",
+				NodeVersion:          "20.x",
+				PruneDevDependencies: true,
+			},
+			want: map[string]string{
+				"GOOGLE_NODEJS_BUILD_COMMAND":          "npx vite build",
+				"GOOGLE_NODEJS_INSTALL_COMMAND":        "npm ci",
+				"GOOGLE_NODEJS_OUTPUT_DIR":             "dist",
+				"GOOGLE_NODEJS_VERSION":                "20.x",
+				"GOOGLE_NODEJS_PRUNE_DEV_DEPENDENCIES": "true",
+			},
+		},
+		{
+			desc:        "framework implies its runtime",
+			buildConfig: BuildConfig{Framework: "nextjs"},
+			want: map[string]string{
+				"GOOGLE_FRAMEWORK": "nextjs",
+				"GOOGLE_RUNTIME":   "nodejs",
+			},
+		},
+		{
+			desc:        "runtime takes precedence over the framework runtime",
+			buildConfig: BuildConfig{Runtime: "php83", Framework: "nextjs"},
+			want: map[string]string{
+				"GOOGLE_FRAMEWORK": "nextjs",
+				"GOOGLE_RUNTIME":   "php83",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		if diff := cmp.Diff(test.want, test.buildConfig.Env()); diff != "" {
+			t.Errorf("unexpected env for test %q, (+got, -want):\n%v", test.desc, diff)
+		}
+	}
+}
+
+func TestWritablePathContains(t *testing.T) {
+	testCases := []struct {
+		path string
+		rel  string
+		want bool
+	}{
+		{path: ".next/cache", rel: ".next/cache", want: true},
+		{path: ".next", rel: ".next/cache", want: true},
+		{path: "/workspace/.next/cache", rel: ".next/cache", want: true},
+		{path: ".next/cache", rel: "apps/web/.next/cache"},
+		{path: ".next/cache2", rel: ".next/cache"},
+		{path: "/tmp", rel: ".next/cache"},
+	}
+	for _, tc := range testCases {
+		if got := (WritablePath{Path: tc.path}).Contains(tc.rel); got != tc.want {
+			t.Errorf("WritablePath{Path: %q}.Contains(%q) = %t, want %t", tc.path, tc.rel, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envschema provides functionality around generating the manifest of the environment
+// variables an App Hosting image expects, which deployment tooling diffs against the configured
+// environment before a rollout.
+package envschema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
+	apphostingschema "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/apphostingschema"
+)
+
+const (
+	// FileName is the name of the manifest in the output bundle.
+	FileName = "envschema.yaml"
+
+	// SourceAppHostingYAML indicates the variable is declared in apphosting.yaml.
+	SourceAppHostingYAML = "apphosting.yaml"
+	// SourceBuildpack indicates the variable is set by a buildpack in a launch layer.
+	SourceBuildpack = "buildpack"
+)
+
+var (
+	// defaultAvailability is the availability of apphosting.yaml variables which do not declare one.
+	defaultAvailability = []string{"BUILD", "RUNTIME"}
+
+	// envFileSuffixes are the suffixes of the files in a layer env directory which set a variable,
+	// as defined by the buildpacks specification.
+	envFileSuffixes = []string{".default", ".override", ".append", ".prepend"}
+)
+
+// Schema is the manifest of the environment variables an image expects. It never contains values,
+// only the names of the variables and the references of secrets.
+type Schema struct {
+	Variables []Variable `yaml:"variables"`
+}
+
+// Variable is an environment variable expected by the image.
+type Variable struct {
+	Variable string `yaml:"variable"`
+	Source   string `yaml:"source"`
+	// Secret is the Secret Manager reference of the variable, as written in apphosting.yaml.
+	Secret       string   `yaml:"secret,omitempty"`
+	Availability []string `yaml:"availability,omitempty"`
+	// Buildpack and Layer identify the launch layer which sets a buildpack variable.
+	Buildpack string `yaml:"buildpack,omitempty"`
+	Layer     string `yaml:"layer,omitempty"`
+}
+
+// FromAppHostingSchema returns the variables declared in the env section of apphosting.yaml.
+func FromAppHostingSchema(s apphostingschema.AppHostingSchema) []Variable {
+	var vars []Variable
+	for _, ev := range s.Env {
+		availability := ev.Availability
+		if len(availability) == 0 {
+			availability = defaultAvailability
+		}
+		vars = append(vars, Variable{
+			Variable:     ev.Variable,
+			Source:       SourceAppHostingYAML,
+			Secret:       ev.Secret,
+			Availability: availability,
+		})
+	}
+	return vars
+}
+
+// FromLayers returns the variables set by the launch layers in layersRoot, the directory which
+// contains the layers directory of each buildpack.
+func FromLayers(layersRoot string) ([]Variable, error) {
+	bps, err := os.ReadDir(layersRoot)
+	if err != nil {
+		return nil, fmt.Errorf("reading layers directory %s: %w", layersRoot, err)
+	}
+	var vars []Variable
+	for _, bp := range bps {
+		if !bp.IsDir() {
+			continue
+		}
+		layerTOMLs, err := filepath.Glob(filepath.Join(layersRoot, bp.Name(), "*.toml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, layerTOML := range layerTOMLs {
+			layer := strings.TrimSuffix(filepath.Base(layerTOML), ".toml")
+			launch, err := isLaunchLayer(layerTOML)
+			if err != nil {
+				return nil, err
+			}
+			if !launch {
+				continue
+			}
+			layerDir := filepath.Join(layersRoot, bp.Name(), layer)
+			for _, envDir := range []string{"env", "env.launch"} {
+				names, err := envFileNames(filepath.Join(layerDir, envDir))
+				if err != nil {
+					return nil, err
+				}
+				for _, name := range names {
+					vars = append(vars, Variable{
+						Variable:     name,
+						Source:       SourceBuildpack,
+						Availability: []string{"RUNTIME"},
+						Buildpack:    bp.Name(),
+						Layer:        layer,
+					})
+				}
+			}
+		}
+	}
+	return vars, nil
+}
+
+// isLaunchLayer returns true if the layer metadata file marks the layer as available at launch.
+func isLaunchLayer(layerTOML string) (bool, error) {
+	var metadata struct {
+		Types struct {
+			Launch bool `toml:"launch"`
+		} `toml:"types"`
+	}
+	if _, err := toml.DecodeFile(layerTOML, &metadata); err != nil {
+		return false, fmt.Errorf("decoding layer metadata %s: %w", layerTOML, err)
+	}
+	return metadata.Types.Launch, nil
+}
+
+// envFileNames returns the names of the variables set in a layer env directory. Process specific
+// subdirectories are not included.
+func envFileNames(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading env directory %s: %w", dir, err)
+	}
+	var names []string
+	for _, f := range files {
+		if f.IsDir() || strings.HasSuffix(f.Name(), ".delim") {
+			continue
+		}
+		name := f.Name()
+		for _, suffix := range envFileSuffixes {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// New returns the schema of the given variables sorted by name. Each variable is listed once, the
+// first occurrence takes precedence, so apphosting.yaml variables should be passed first.
+func New(vars ...[]Variable) Schema {
+	seen := map[string]bool{}
+	var s Schema
+	for _, vs := range vars {
+		for _, v := range vs {
+			if seen[v.Variable] {
+				continue
+			}
+			seen[v.Variable] = true
+			s.Variables = append(s.Variables, v)
+		}
+	}
+	sort.SliceStable(s.Variables, func(i, j int) bool { return s.Variables[i].Variable < s.Variables[j].Variable })
+	return s
+}
+
+// Write writes the schema to the given path.
+func Write(s Schema, path string) error {
+	data, err := yaml.Marshal(&s)
+	if err != nil {
+		return fmt.Errorf("converting env schema to YAML: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating parent directory of %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing env schema to %s: %w", path, err)
+	}
+	return nil
+}
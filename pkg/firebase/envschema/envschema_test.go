@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envschema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	apphostingschema "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/apphostingschema"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFromAppHostingSchema(t *testing.T) {
+	s := apphostingschema.AppHostingSchema{
+		Env: []apphostingschema.EnvironmentVariable{
+			{Variable: "API_URL", Value: "https://example.com"},
+			{Variable: "API_KEY", Secret: "apiKey", Availability: []string{"RUNTIME"}},
+		},
+	}
+	want := []Variable{
+		{Variable: "API_URL", Source: SourceAppHostingYAML, Availability: []string{"BUILD", "RUNTIME"}},
+		{Variable: "API_KEY", Source: SourceAppHostingYAML, Secret: "apiKey", Availability: []string{"RUNTIME"}},
+	}
+
+	if diff := cmp.Diff(want, FromAppHostingSchema(s)); diff != "" {
+		t.Errorf("FromAppHostingSchema() unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestFromLayers(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"google.nodejs.runtime/node.toml":                        "[types]\nlaunch = true\n",
+		"google.nodejs.runtime/node/env.launch/NODE_ENV.default": "production",
+		"google.nodejs.runtime/node/env/PATH.prepend":            "/layers/node/bin",
+		"google.nodejs.runtime/node/env/PATH.delim":              ":",
+		"google.nodejs.runtime/node/env.launch/web/PROCESS_ONLY": "web",
+		"google.nodejs.npm/npm_modules.toml":                     "[types]\nbuild = true\ncache = true\n",
+		"google.nodejs.npm/npm_modules/env/NODE_PATH.override":   "/layers/npm_modules",
+		"google.config.entrypoint/store.toml":                    "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+	want := []Variable{
+		{Variable: "PATH", Source: SourceBuildpack, Availability: []string{"RUNTIME"}, Buildpack: "google.nodejs.runtime", Layer: "node"},
+		{Variable: "NODE_ENV", Source: SourceBuildpack, Availability: []string{"RUNTIME"}, Buildpack: "google.nodejs.runtime", Layer: "node"},
+	}
+
+	got, err := FromLayers(root)
+	if err != nil {
+		t.Fatalf("FromLayers() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FromLayers() unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestNew(t *testing.T) {
+	appHostingVars := []Variable{
+		{Variable: "NODE_ENV", Source: SourceAppHostingYAML, Availability: []string{"RUNTIME"}},
+		{Variable: "API_KEY", Source: SourceAppHostingYAML, Secret: "apiKey"},
+	}
+	buildpackVars := []Variable{
+		{Variable: "PATH", Source: SourceBuildpack, Buildpack: "google.nodejs.runtime", Layer: "node"},
+		{Variable: "NODE_ENV", Source: SourceBuildpack, Buildpack: "google.nodejs.runtime", Layer: "node"},
+	}
+	want := Schema{
+		Variables: []Variable{
+			{Variable: "API_KEY", Source: SourceAppHostingYAML, Secret: "apiKey"},
+			{Variable: "NODE_ENV", Source: SourceAppHostingYAML, Availability: []string{"RUNTIME"}},
+			{Variable: "PATH", Source: SourceBuildpack, Buildpack: "google.nodejs.runtime", Layer: "node"},
+		},
+	}
+
+	if diff := cmp.Diff(want, New(appHostingVars, buildpackVars)); diff != "" {
+		t.Errorf("New() unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle", FileName)
+	s := Schema{Variables: []Variable{{Variable: "API_KEY", Source: SourceAppHostingYAML, Secret: "apiKey", Availability: []string{"RUNTIME"}}}}
+	want := `variables:
+- variable: API_KEY
+  source: apphosting.yaml
+  secret: apiKey
+  availability:
+  - RUNTIME
+`
+
+	if err := Write(s, path); err != nil {
+		t.Fatalf("Write() got error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Write() unexpected diff (-want +got):\n%s", diff)
+	}
+}
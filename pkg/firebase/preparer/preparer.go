@@ -20,12 +20,14 @@ import (
 	"context"
 	"fmt"
 
+	apphostingschema "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/apphostingschema"
 	env "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/env"
 	secrets "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/secrets"
 )
 
 // Prepare performs pre-build logic for App Hosting backends including:
 // * Reading, sanitizing, and writing user-defined environment variables in apphosting.env to a new file.
+// * Translating the buildConfig section of apphosting.yaml into build environment variables.
 //
 // Prepare will always write a file to disk, even if there are no environment variables to write.
 func Prepare(ctx context.Context, secretClient secrets.SecretManager, apphostingEnvFilePath string, appHostingYAMLPath string, projectID string, envReferencedOutputFilePath string, envDereferencedOutputFilePath string) error {
@@ -58,6 +60,21 @@ func Prepare(ctx context.Context, secretClient secrets.SecretManager, apphosting
 		}
 	}
 
+	// Env vars set explicitly in apphosting.env take precedence over the buildConfig section.
+	if appHostingYAMLPath != "" {
+		appHostingYAML, err := apphostingschema.ReadAndValidateAppHostingSchemaFromFile(appHostingYAMLPath)
+		if err != nil {
+			return fmt.Errorf("reading apphosting.yaml: %w", err)
+		}
+		for k, v := range appHostingYAML.BuildConfig.Env() {
+			if _, ok := referencedEnvMap[k]; ok {
+				continue
+			}
+			referencedEnvMap[k] = v
+			dereferencedEnvMap[k] = v
+		}
+	}
+
 	err := env.WriteEnv(referencedEnvMap, envReferencedOutputFilePath)
 	if err != nil {
 		return fmt.Errorf("writing final referenced environment variables to %v: %w", envReferencedOutputFilePath, err)
@@ -14,6 +14,7 @@ import (
 
 var (
 	appHostingEnvPath    string = testdata.MustGetPath("testdata/apphosting.env")
+	appHostingYAMLPath   string = testdata.MustGetPath("testdata/apphosting_buildconfig.yaml")
 	latestSecretName     string = "projects/test-project/secrets/secretID/versions/12"
 	pinnedSecretName     string = "projects/test-project/secrets/secretID/versions/11"
 	secretString         string = "secretString"
@@ -28,6 +29,7 @@ func TestPrepare(t *testing.T) {
 	testCases := []struct {
 		desc                   string
 		appHostingEnvFilePath  string
+		appHostingYAMLPath     string
 		projectID              string
 		wantEnvMapReferenced   map[string]string
 		wantEnvMapDereferenced map[string]string
@@ -51,6 +53,30 @@ func TestPrepare(t *testing.T) {
 				"API_KEY_PINNED":    secretString,
 			},
 		},
+		{
+			desc:                  "apphosting.yaml buildConfig",
+			appHostingEnvFilePath: appHostingEnvPath,
+			appHostingYAMLPath:    appHostingYAMLPath,
+			projectID:             "test-project",
+			wantEnvMapReferenced: map[string]string{
+				"API_URL":                     "api.service.com",
+				"ENVIRONMENT":                 "staging",
+				"MULTILINE_ENV_VAR":           "line 1\nline 2",
+				"SECRET_API_KEY_LATEST":       latestSecretName,
+				"SECRET_API_KEY_PINNED":       pinnedSecretName,
+				"GOOGLE_NODEJS_BUILD_COMMAND": "npx vite build",
+				"GOOGLE_NODEJS_OUTPUT_DIR":    "dist",
+			},
+			wantEnvMapDereferenced: map[string]string{
+				"API_URL":                     "api.service.com",
+				"ENVIRONMENT":                 "staging",
+				"MULTILINE_ENV_VAR":           "line 1\nline 2",
+				"API_KEY_LATEST":              secretString,
+				"API_KEY_PINNED":              secretString,
+				"GOOGLE_NODEJS_BUILD_COMMAND": "npx vite build",
+				"GOOGLE_NODEJS_OUTPUT_DIR":    "dist",
+			},
+		},
 		{
 			desc:                   "nonexistent apphosting.env",
 			appHostingEnvFilePath:  "",
@@ -90,7 +116,7 @@ func TestPrepare(t *testing.T) {
 
 	// Testing happy paths
 	for _, test := range testCases {
-		if err := Prepare(context.Background(), fakeSecretClient, test.appHostingEnvFilePath, test.appHostingEnvFilePath, test.projectID, outputFilePathReferenced, outputFilePathDereferenced); err != nil {
+		if err := Prepare(context.Background(), fakeSecretClient, test.appHostingEnvFilePath, test.appHostingYAMLPath, test.projectID, outputFilePathReferenced, outputFilePathDereferenced); err != nil {
 			t.Errorf("Error in test '%v'. Error was %v", test.desc, err)
 		}
 
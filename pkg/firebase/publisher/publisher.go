@@ -39,6 +39,9 @@ type outputBundleSchema struct {
 type buildSchema struct {
 	RunConfig *apphostingschema.RunConfig `yaml:"runConfig,omitempty"`
 	Runtime   *runtime                    `yaml:"runtime,omitempty"`
+	Sidecars  []apphostingschema.Sidecar  `yaml:"sidecars,omitempty"`
+	// WritablePaths are mounted writable in the application container.
+	WritablePaths []apphostingschema.WritablePath `yaml:"writablePaths,omitempty"`
 }
 
 // TODO (b/328444933): Migrate this to the new EnvironmentVariable in apphostingschema.go
@@ -53,6 +56,10 @@ var (
 	defaultMaxInstances int32 = 100 // From https://cloud.google.com/run/docs/configuring/max-instances.
 	defaultMinInstances int32 = 0   // From https://cloud.google.com/run/docs/configuring/min-instances.
 
+	defaultAcceleratorCount  int32 = 1     // From https://cloud.google.com/run/docs/configuring/services/gpu.
+	acceleratorDefaultCPU    int32 = 4     // The minimum number of CPUs of an instance with a GPU attached.
+	acceleratorDefaultMemory int32 = 16384 // The minimum memory of an instance with a GPU attached.
+
 	reservedKeys = map[string]bool{
 		"PORT":            true,
 		"K_SERVICE":       true,
@@ -134,6 +141,31 @@ func toBuildSchema(appHostingSchema apphostingschema.AppHostingSchema, bundleSch
 	if b.MinInstances != nil {
 		buildSchema.RunConfig.MinInstances = b.MinInstances
 	}
+	if b.StartupCPUBoost != nil {
+		buildSchema.RunConfig.StartupCPUBoost = b.StartupCPUBoost
+	}
+	if b.SessionAffinity != nil {
+		buildSchema.RunConfig.SessionAffinity = b.SessionAffinity
+	}
+	if b.AcceleratorType != nil {
+		buildSchema.RunConfig.AcceleratorType = b.AcceleratorType
+		buildSchema.RunConfig.AcceleratorCount = &defaultAcceleratorCount
+		if b.AcceleratorCount != nil {
+			buildSchema.RunConfig.AcceleratorCount = b.AcceleratorCount
+		}
+		// Instances with a GPU attached need more resources than the defaults.
+		if b.CPU == nil {
+			cpu := float32(acceleratorDefaultCPU)
+			buildSchema.RunConfig.CPU = &cpu
+		}
+		if b.MemoryMiB == nil {
+			buildSchema.RunConfig.MemoryMiB = &acceleratorDefaultMemory
+		}
+	}
+
+	// Sidecars are passed through as declared in apphosting.yaml.
+	buildSchema.Sidecars = appHostingSchema.Sidecars
+	buildSchema.WritablePaths = appHostingSchema.WritablePaths
 
 	// Copy fields from apphosting.env.
 	if len(appHostingEnvVars) > 0 {
@@ -45,6 +45,14 @@ func float32Ptr(i int32) *float32 {
 	return v
 }
 
+func stringPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
 func toString(buildSchema buildSchema) string {
 	data, _ := json.MarshalIndent(buildSchema, "", "  ")
 	return string(data)
@@ -180,6 +188,86 @@ func TestToBuildSchemaRunConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "AppHostingSchema with sidecars",
+			appHostingSchema: apphostingschema.AppHostingSchema{
+				Sidecars: []apphostingschema.Sidecar{
+					{Name: "nginx", Image: "nginx:1.27", Ports: []int32{8081}},
+				},
+			},
+			expected: buildSchema{
+				RunConfig: &apphostingschema.RunConfig{
+					CPU:          float32Ptr(defaultCPU),
+					MemoryMiB:    &defaultMemory,
+					Concurrency:  &defaultConcurrency,
+					MaxInstances: &defaultMaxInstances,
+					MinInstances: int32Ptr(0),
+				},
+				Sidecars: []apphostingschema.Sidecar{
+					{Name: "nginx", Image: "nginx:1.27", Ports: []int32{8081}},
+				},
+			},
+		},
+		{
+			name: "AppHostingSchema with writable paths",
+			appHostingSchema: apphostingschema.AppHostingSchema{
+				WritablePaths: []apphostingschema.WritablePath{
+					{Path: ".next/cache", Type: apphostingschema.WritablePathTmpfs, SizeMiB: int32Ptr(256)},
+				},
+			},
+			expected: buildSchema{
+				RunConfig: &apphostingschema.RunConfig{
+					CPU:          float32Ptr(defaultCPU),
+					MemoryMiB:    &defaultMemory,
+					Concurrency:  &defaultConcurrency,
+					MaxInstances: &defaultMaxInstances,
+					MinInstances: int32Ptr(0),
+				},
+				WritablePaths: []apphostingschema.WritablePath{
+					{Path: ".next/cache", Type: apphostingschema.WritablePathTmpfs, SizeMiB: int32Ptr(256)},
+				},
+			},
+		},
+		{
+			name: "AppHostingSchema with an accelerator",
+			appHostingSchema: apphostingschema.AppHostingSchema{
+				RunConfig: apphostingschema.RunConfig{
+					MaxInstances:    int32Ptr(2),
+					AcceleratorType: stringPtr("nvidia-l4"),
+				},
+			},
+			expected: buildSchema{
+				RunConfig: &apphostingschema.RunConfig{
+					CPU:              float32Ptr(acceleratorDefaultCPU),
+					MemoryMiB:        &acceleratorDefaultMemory,
+					Concurrency:      &defaultConcurrency,
+					MaxInstances:     int32Ptr(2),
+					MinInstances:     int32Ptr(0),
+					AcceleratorType:  stringPtr("nvidia-l4"),
+					AcceleratorCount: int32Ptr(1),
+				},
+			},
+		},
+		{
+			name: "AppHostingSchema with startup CPU boost and session affinity",
+			appHostingSchema: apphostingschema.AppHostingSchema{
+				RunConfig: apphostingschema.RunConfig{
+					StartupCPUBoost: boolPtr(true),
+					SessionAffinity: boolPtr(true),
+				},
+			},
+			expected: buildSchema{
+				RunConfig: &apphostingschema.RunConfig{
+					CPU:             float32Ptr(defaultCPU),
+					MemoryMiB:       &defaultMemory,
+					Concurrency:     &defaultConcurrency,
+					MaxInstances:    &defaultMaxInstances,
+					MinInstances:    int32Ptr(0),
+					StartupCPUBoost: boolPtr(true),
+					SessionAffinity: boolPtr(true),
+				},
+			},
+		},
 		{
 			name: "Partial AppHostingSchema",
 			appHostingSchema: apphostingschema.AppHostingSchema{
@@ -83,6 +83,8 @@ func (ctx *Context) saveErrorOutput(err error) {
 
 	be.BuildpackID, be.BuildpackVersion = ctx.BuildpackID(), ctx.BuildpackVersion()
 	bo := builderoutput.BuilderOutput{Error: *be}
+	bo.DetectPlan = ctx.detectPlan()
+	recordHTTPMetrics()
 	bm := buildermetrics.GlobalBuilderMetrics()
 	bo.Metrics = *bm
 	data, err := bo.JSON()
@@ -183,7 +185,12 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		UserDurationMs:   ctx.stats.user.Milliseconds(),
 	})
 	bo.Warnings = append(bo.Warnings, ctx.warnings...)
+	bo.Settings = append(bo.Settings, ctx.settings...)
+	if len(bo.DetectPlan) == 0 {
+		bo.DetectPlan = ctx.detectPlan()
+	}
 
+	recordHTTPMetrics()
 	bm := buildermetrics.GlobalBuilderMetrics()
 	bm.ForEachCounter(func(id buildermetrics.MetricID, c *buildermetrics.Counter) {
 		count := bo.Metrics.GetCounter(id)
@@ -202,6 +209,11 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		if len(content) <= maxMessageBytes {
 			break
 		}
+		// The detect plan is informational, drop it before trimming warnings.
+		if len(bo.DetectPlan) > 0 {
+			bo.DetectPlan = nil
+			continue
+		}
 		// This is a defensive check; if there are no warnings, the message should be small enough.
 		// In either case, skip this stat.
 		if len(bo.Warnings) == 0 {
@@ -272,6 +272,7 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 		installedRuntimeVersions []string
 		initial                  *builderoutput.BuilderOutput
 		warnings                 []string
+		settings                 []builderoutput.ConfigSetting
 		want                     builderoutput.BuilderOutput
 	}{
 		{
@@ -448,6 +449,31 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 				CustomImage: false,
 			},
 		},
+		{
+			name: "settings appended",
+			initial: &builderoutput.BuilderOutput{
+				Stats: []builderoutput.BuilderStat{
+					{BuildpackID: "bp1", BuildpackVersion: "v1", DurationMs: 1000, UserDurationMs: 100},
+				},
+				Settings: []builderoutput.ConfigSetting{
+					{Name: "runtime version", Value: "8.3.4", Source: SourceEnv},
+				},
+			},
+			settings: []builderoutput.ConfigSetting{
+				{Name: "document root", Value: "public", Source: SourceAppYAML},
+			},
+			want: builderoutput.BuilderOutput{
+				Metrics: buildermetrics.NewBuilderMetrics(),
+				Stats: []builderoutput.BuilderStat{
+					{BuildpackID: "bp1", BuildpackVersion: "v1", DurationMs: 1000, UserDurationMs: 100},
+					{BuildpackID: buildpackID, BuildpackVersion: buildpackVersion, DurationMs: dur.Milliseconds(), UserDurationMs: userDur.Milliseconds()},
+				},
+				Settings: []builderoutput.ConfigSetting{
+					{Name: "runtime version", Value: "8.3.4", Source: SourceEnv},
+					{Name: "document root", Value: "public", Source: SourceAppYAML},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -480,6 +506,7 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 			}
 			ctx.stats.user = userDur
 			ctx.warnings = tc.warnings
+			ctx.settings = tc.settings
 			for _, version := range tc.installedRuntimeVersions {
 				ctx.AddInstalledRuntimeVersion(version)
 			}
@@ -0,0 +1,224 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// buildResolutionFilename is the file in the parent of the layers directories which records that
+// the build hosts and resolver were written to the system files, so that later buildpacks of the
+// build do not write them again.
+const buildResolutionFilename = "google-build-resolution"
+
+var (
+	// buildHosts are the addresses of the hosts set with env.BuildHosts, by lower case host name.
+	buildHosts map[string][]string
+
+	// Files the build hosts and resolver are written to, if they are writable, so that commands
+	// run by the buildpacks use them too. They can be overridden for testing.
+	hostsFile      = "/etc/hosts"
+	resolvConfFile = "/etc/resolv.conf"
+)
+
+// configureBuildResolution resolves hosts with the env.BuildHosts and env.BuildResolver env vars
+// for the rest of the build. The first buildpack of the build writes them to the system files used
+// by the commands run by the build, and logs a warning if they cannot be written.
+func (ctx *Context) configureBuildResolution() error {
+	hosts, err := parseBuildHosts(os.Getenv(env.BuildHosts))
+	if err != nil {
+		return err
+	}
+	resolver, err := resolverAddress(os.Getenv(env.BuildResolver))
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 && resolver == "" {
+		return nil
+	}
+	if len(hosts) > 0 {
+		buildHosts = hosts
+		ctx.RecordSetting("build hosts", os.Getenv(env.BuildHosts), SourceEnv)
+	}
+	if resolver != "" {
+		lookupHost = resolverLookupHost(resolver)
+		ctx.RecordSetting("build resolver", resolver, SourceEnv)
+	}
+	marker := ctx.buildResolutionPath()
+	if marker != "" {
+		if _, err := os.Stat(marker); err == nil {
+			ctx.Debugf("The build hosts and resolver were written to the system files by a previous buildpack")
+			return nil
+		}
+	}
+	if len(hosts) > 0 {
+		if err := appendHosts(hostsFile, hosts); err != nil {
+			ctx.Warnf("Commands run by the build resolve the hosts of %s with the system resolution, writing %s failed: %v", env.BuildHosts, hostsFile, err)
+		}
+	}
+	if resolver != "" {
+		if err := setNameserver(resolvConfFile, resolver); err != nil {
+			ctx.Warnf("Commands run by the build use the system resolver instead of %s, writing %s failed: %v", env.BuildResolver, resolvConfFile, err)
+		}
+	}
+	if marker != "" {
+		if err := os.WriteFile(marker, nil, 0644); err != nil {
+			ctx.Debugf("Writing %s: %v", marker, err)
+		}
+	}
+	return nil
+}
+
+// buildResolutionPath returns the path of the file which records that the system files were
+// written, or an empty string outside of the build phase.
+func (ctx *Context) buildResolutionPath() string {
+	if ctx.LayersDir() == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(ctx.LayersDir()), buildResolutionFilename)
+}
+
+// parseBuildHosts parses the comma-separated `host=address` entries of env.BuildHosts.
+func parseBuildHosts(s string) (map[string][]string, error) {
+	hosts := make(map[string][]string)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, addr, ok := strings.Cut(entry, "=")
+		host, addr = strings.ToLower(strings.TrimSpace(host)), strings.TrimSpace(addr)
+		if !ok || host == "" || strings.ContainsAny(host, " \t#") || net.ParseIP(addr) == nil {
+			return nil, UserErrorf("invalid %s entry %q, expected host=address", env.BuildHosts, entry)
+		}
+		hosts[host] = append(hosts[host], addr)
+	}
+	return hosts, nil
+}
+
+// resolverAddress returns the host:port of the DNS server set with env.BuildResolver, or an
+// empty string if it is not set.
+func resolverAddress(s string) (string, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return "", nil
+	}
+	if net.ParseIP(s) != nil {
+		return net.JoinHostPort(s, "53"), nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || net.ParseIP(host) == nil || port == "" {
+		return "", UserErrorf("invalid %s %q, expected an IP address with an optional port", env.BuildResolver, s)
+	}
+	return s, nil
+}
+
+// resolverLookupHost returns a host lookup which queries the DNS server at addr.
+func resolverLookupHost(addr string) func(context.Context, string) ([]string, error) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	return r.LookupHost
+}
+
+// lookupBuildHost returns the addresses of host set with env.BuildHosts.
+func lookupBuildHost(host string) ([]string, bool) {
+	addrs, ok := buildHosts[strings.ToLower(host)]
+	return addrs, ok
+}
+
+// appendHosts appends the entries of hosts which are missing from the hosts file at path.
+func appendHosts(path string, hosts map[string][]string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		existing[strings.Join(strings.Fields(line), " ")] = true
+	}
+	var names []string
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, host := range names {
+		for _, addr := range hosts[host] {
+			if line := addr + " " + host; !existing[line] {
+				sb.WriteString(line + "\n")
+			}
+		}
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	lines := sb.String()
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		lines = "\n" + lines
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(lines)
+	return err
+}
+
+// setNameserver replaces the nameservers of the resolv.conf file at path with the DNS server at
+// addr, keeping its other options. The resolv.conf format has no port, so only servers on the
+// default port are written.
+func setNameserver(path, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if port != "53" {
+		return fmt.Errorf("resolv.conf only supports port 53, not %s", port)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := []string{"nameserver " + host}
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "nameserver" {
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_TRUNC|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(lines, "\n") + "\n")
+	return err
+}
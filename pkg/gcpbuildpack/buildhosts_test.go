@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseBuildHosts(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[string][]string{},
+		},
+		{
+			name:  "entries",
+			value: "Registry.Corp.Example=10.0.0.5, registry.corp.example=10.0.0.6,npm.corp.example=fd00::6",
+			want: map[string][]string{
+				"registry.corp.example": {"10.0.0.5", "10.0.0.6"},
+				"npm.corp.example":      {"fd00::6"},
+			},
+		},
+		{
+			name:    "host name as address",
+			value:   "registry.corp.example=proxy.corp.example",
+			wantErr: true,
+		},
+		{
+			name:    "missing address",
+			value:   "registry.corp.example",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseBuildHosts(tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseBuildHosts(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); !tc.wantErr && diff != "" {
+				t.Errorf("parseBuildHosts(%q) mismatch (-want +got):\n%s", tc.value, diff)
+			}
+		})
+	}
+}
+
+func TestResolverAddress(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: "10.0.0.53", want: "10.0.0.53:53"},
+		{value: "10.0.0.53:5353", want: "10.0.0.53:5353"},
+		{value: "[fd00::53]:53", want: "[fd00::53]:53"},
+		{value: "dns.corp.example", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := resolverAddress(tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("resolverAddress(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("resolverAddress(%q) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigureBuildResolution(t *testing.T) {
+	dir := t.TempDir()
+	defer func(hosts, resolvConf string) { hostsFile, resolvConfFile = hosts, resolvConf }(hostsFile, resolvConfFile)
+	defer func(fn func(context.Context, string) ([]string, error)) { lookupHost = fn }(lookupHost)
+	defer func() { buildHosts = nil }()
+	hostsFile = filepath.Join(dir, "hosts")
+	resolvConfFile = filepath.Join(dir, "resolv.conf")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1 localhost\n10.0.0.5 registry.corp.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(resolvConfFile, []byte("search corp.example\nnameserver 8.8.8.8\noptions ndots:1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(env.BuildHosts, "registry.corp.example=10.0.0.5,npm.corp.example=10.0.0.6")
+	t.Setenv(env.BuildResolver, "10.0.0.53")
+
+	ctx := NewContext()
+	if err := ctx.configureBuildResolution(); err != nil {
+		t.Fatalf("configureBuildResolution() got error: %v", err)
+	}
+
+	addrs, err := (&dnsCache{entries: map[string]dnsEntry{}}).lookup(context.Background(), "NPM.corp.example")
+	if err != nil {
+		t.Fatalf("lookup() got error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"10.0.0.6"}, addrs); diff != "" {
+		t.Errorf("lookup() mismatch (-want +got):\n%s", diff)
+	}
+	for path, want := range map[string]string{
+		hostsFile:      "127.0.0.1 localhost\n10.0.0.5 registry.corp.example\n10.0.0.6 npm.corp.example\n",
+		resolvConfFile: "nameserver 10.0.0.53\nsearch corp.example\noptions ndots:1\n",
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("%s mismatch (-want +got):\n%s", filepath.Base(path), diff)
+		}
+	}
+}
+
+func TestConfigureBuildResolutionUnwritableFiles(t *testing.T) {
+	dir := t.TempDir()
+	defer func(hosts, resolvConf string) { hostsFile, resolvConfFile = hosts, resolvConf }(hostsFile, resolvConfFile)
+	defer func(fn func(context.Context, string) ([]string, error)) { lookupHost = fn }(lookupHost)
+	defer func() { buildHosts = nil }()
+	hostsFile = filepath.Join(dir, "missing", "hosts")
+	resolvConfFile = filepath.Join(dir, "missing", "resolv.conf")
+	t.Setenv(env.BuildHosts, "registry.corp.example=10.0.0.5")
+	t.Setenv(env.BuildResolver, "10.0.0.53")
+
+	ctx := NewContext()
+	if err := ctx.configureBuildResolution(); err != nil {
+		t.Fatalf("configureBuildResolution() got error: %v", err)
+	}
+	if got := len(ctx.warnings); got != 2 {
+		t.Errorf("configureBuildResolution() logged %d warnings, want 2: %v", got, ctx.warnings)
+	}
+}
+
+func TestConfigureBuildResolutionOnce(t *testing.T) {
+	dir := t.TempDir()
+	defer func(hosts, resolvConf string) { hostsFile, resolvConfFile = hosts, resolvConf }(hostsFile, resolvConfFile)
+	defer func(fn func(context.Context, string) ([]string, error)) { lookupHost = fn }(lookupHost)
+	defer func() { buildHosts = nil }()
+	hostsFile = filepath.Join(dir, "missing", "hosts")
+	resolvConfFile = filepath.Join(dir, "missing", "resolv.conf")
+	t.Setenv(env.BuildHosts, "registry.corp.example=10.0.0.5")
+	t.Setenv(env.BuildResolver, "10.0.0.53")
+	layersRoot := t.TempDir()
+
+	for i, buildpack := range []string{"google.nodejs.runtime", "google.nodejs.npm"} {
+		buildHosts = nil
+		ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: filepath.Join(layersRoot, buildpack)}}))
+		if err := ctx.configureBuildResolution(); err != nil {
+			t.Fatalf("configureBuildResolution() of %s got error: %v", buildpack, err)
+		}
+		// Only the first buildpack writes the system files and warns.
+		want := 0
+		if i == 0 {
+			want = 2
+		}
+		if got := len(ctx.warnings); got != want {
+			t.Errorf("configureBuildResolution() of %s logged %d warnings, want %d: %v", buildpack, got, want, ctx.warnings)
+		}
+		if addrs, ok := lookupBuildHost("registry.corp.example"); !ok || len(addrs) != 1 {
+			t.Errorf("lookupBuildHost() in %s = %v, %t, want the build host", buildpack, addrs, ok)
+		}
+	}
+}
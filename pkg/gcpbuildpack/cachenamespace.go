@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+// cacheNamespaceKey is the layer metadata key of the hashed cache namespace the layer was written in.
+const cacheNamespaceKey = "cache_namespace"
+
+// cacheNamespaceFile is the file in the platform directory which holds the cache namespace of the
+// build, such as a project or app identifier. It is written by the platform rather than read from
+// an env var because the env vars of a build are set by its users, who could otherwise claim the
+// namespace of another team.
+const cacheNamespaceFile = "cache-namespace"
+
+// cacheNamespace returns the hashed cache namespace of the build, or an empty string if there is
+// none. The namespace is hashed so that it is not readable from the metadata of the layer.
+func (ctx *Context) cacheNamespace() (string, error) {
+	if ctx.buildContext.Platform.Path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(filepath.Join(ctx.buildContext.Platform.Path, cacheNamespaceFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", buildererror.Errorf(buildererror.StatusInternal, "reading cache namespace: %v", err)
+	}
+	ns := strings.TrimSpace(string(b))
+	if ns == "" {
+		return "", nil
+	}
+	h := sha256.Sum256([]byte(ns))
+	return hex.EncodeToString(h[:]), nil
+}
+
+// isolateCache clears the cache layer if it was restored from a build in a different cache
+// namespace, so that builds sharing a builder cannot read or poison each other's caches, and stamps
+// it with the namespace of the build. If env.EnforceCacheIsolation is set, layers without a
+// namespace are cleared too, and caching is disabled for builds without a namespace.
+func (ctx *Context) isolateCache(l *libcnb.Layer) error {
+	enforce, err := env.IsPresentAndTrue(env.EnforceCacheIsolation)
+	if err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, err.Error())
+	}
+	ns, err := ctx.cacheNamespace()
+	if err != nil {
+		return err
+	}
+	prev, _ := l.Metadata[cacheNamespaceKey].(string)
+	restored := len(l.Metadata) > 0
+	if restored && (prev != ns && (prev != "" || enforce) || enforce && ns == "") {
+		ctx.Logf("Clearing layer %q restored from a different cache namespace", l.Name)
+		if err := ctx.ClearLayer(l); err != nil {
+			return buildererror.Errorf(buildererror.StatusInternal, "clearing layer %q: %v", l.Name, err)
+		}
+		delete(l.Metadata, cacheNamespaceKey)
+	}
+	if ns == "" {
+		if enforce {
+			ctx.Warnf("Not caching layer %q: cache isolation is enforced but the platform did not set a cache namespace", l.Name)
+			l.Cache = false
+		}
+		return nil
+	}
+	if l.Metadata == nil {
+		l.Metadata = make(map[string]interface{})
+	}
+	l.Metadata[cacheNamespaceKey] = ns
+	return nil
+}
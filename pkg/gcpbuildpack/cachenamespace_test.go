@@ -0,0 +1,242 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestCacheLayerIsolation(t *testing.T) {
+	nsA, nsB := "team-a", "team-b"
+	testCases := []struct {
+		name      string
+		prevNS    string
+		restored  bool
+		ns        string
+		enforce   bool
+		envs      map[string]string
+		wantClear bool
+		wantCache bool
+		wantNS    string
+	}{
+		{
+			name:      "fresh layer without namespace",
+			wantCache: true,
+		},
+		{
+			name:      "fresh layer with namespace",
+			ns:        nsA,
+			wantCache: true,
+			wantNS:    nsA,
+		},
+		{
+			name:      "same namespace",
+			prevNS:    nsA,
+			restored:  true,
+			ns:        nsA,
+			wantCache: true,
+			wantNS:    nsA,
+		},
+		{
+			name:      "different namespace",
+			prevNS:    nsA,
+			restored:  true,
+			ns:        nsB,
+			wantClear: true,
+			wantCache: true,
+			wantNS:    nsB,
+		},
+		{
+			name:      "project env is not a namespace",
+			prevNS:    nsA,
+			restored:  true,
+			envs:      map[string]string{"GOOGLE_CLOUD_PROJECT": nsA},
+			wantClear: true,
+			wantCache: true,
+		},
+		{
+			name:      "namespaced layer without namespace",
+			prevNS:    nsA,
+			restored:  true,
+			wantClear: true,
+			wantCache: true,
+		},
+		{
+			name:      "layer without namespace is adopted",
+			restored:  true,
+			ns:        nsA,
+			wantCache: true,
+			wantNS:    nsA,
+		},
+		{
+			name:      "enforced layer without namespace",
+			restored:  true,
+			ns:        nsA,
+			enforce:   true,
+			wantClear: true,
+			wantCache: true,
+			wantNS:    nsA,
+		},
+		{
+			name:      "enforced without namespace",
+			restored:  true,
+			enforce:   true,
+			wantClear: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.EnforceCacheIsolation, strconv.FormatBool(tc.enforce))
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			layers := t.TempDir()
+			if tc.restored {
+				metadata := "[metadata]\n  key = \"value\"\n"
+				if tc.prevNS != "" {
+					metadata += "  cache_namespace = \"" + hashNamespace(tc.prevNS) + "\"\n"
+				}
+				if err := os.WriteFile(filepath.Join(layers, "deps.toml"), []byte(metadata), 0644); err != nil {
+					t.Fatalf("writing layer metadata: %v", err)
+				}
+				if err := os.MkdirAll(filepath.Join(layers, "deps"), 0755); err != nil {
+					t.Fatalf("creating layer: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(layers, "deps", "dep.txt"), []byte("dep"), 0644); err != nil {
+					t.Fatalf("writing layer content: %v", err)
+				}
+			}
+			ctx := NewContext(WithBuildContext(libcnb.BuildContext{
+				Layers:   libcnb.Layers{Path: layers},
+				Platform: platformWithNamespace(t, tc.ns),
+			}))
+
+			l, err := ctx.Layer("deps", BuildLayer, CacheLayer)
+			if err != nil {
+				t.Fatalf("Layer() got error: %v", err)
+			}
+			if tc.restored {
+				_, statErr := os.Stat(filepath.Join(l.Path, "dep.txt"))
+				if cleared := os.IsNotExist(statErr); cleared != tc.wantClear {
+					t.Errorf("layer cleared = %t, want %t", cleared, tc.wantClear)
+				}
+				if kept := ctx.GetMetadata(l, "key") == "value"; kept == tc.wantClear {
+					t.Errorf("layer metadata kept = %t, want %t", kept, !tc.wantClear)
+				}
+			}
+			if l.Cache != tc.wantCache {
+				t.Errorf("layer cache = %t, want %t", l.Cache, tc.wantCache)
+			}
+			want := ""
+			if tc.wantNS != "" {
+				want = hashNamespace(tc.wantNS)
+			}
+			if got := ctx.GetMetadata(l, cacheNamespaceKey); got != want {
+				t.Errorf("layer %s = %q, want %q", cacheNamespaceKey, got, want)
+			}
+		})
+	}
+}
+
+func TestCacheLayerReusedInNamespace(t *testing.T) {
+	testCases := []struct {
+		name    string
+		enforce bool
+	}{
+		{
+			name: "not enforced",
+		},
+		{
+			name:    "enforced",
+			enforce: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.EnforceCacheIsolation, strconv.FormatBool(tc.enforce))
+			layers := t.TempDir()
+			platform := platformWithNamespace(t, "team-a")
+
+			// The first build clears the layer, as buildpacks do when its dependencies change.
+			ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}, Platform: platform}))
+			l, err := ctx.Layer("deps", BuildLayer, CacheLayer)
+			if err != nil {
+				t.Fatalf("Layer() got error: %v", err)
+			}
+			if err := ctx.ClearLayer(l); err != nil {
+				t.Fatalf("ClearLayer() got error: %v", err)
+			}
+			ctx.SetMetadata(l, "version", "1.0.0")
+			if err := os.WriteFile(filepath.Join(l.Path, "dep.txt"), []byte("dep"), 0644); err != nil {
+				t.Fatalf("writing layer content: %v", err)
+			}
+			writeLayerMetadata(t, layers, l)
+
+			ctx = NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}, Platform: platform}))
+			l, err = ctx.Layer("deps", BuildLayer, CacheLayer)
+			if err != nil {
+				t.Fatalf("Layer() got error: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(l.Path, "dep.txt")); err != nil {
+				t.Errorf("layer content of the first build not reused: %v", err)
+			}
+			if got := ctx.GetMetadata(l, "version"); got != "1.0.0" {
+				t.Errorf("layer version = %q, want %q", got, "1.0.0")
+			}
+			if !l.Cache {
+				t.Error("layer cache = false, want true")
+			}
+		})
+	}
+}
+
+// platformWithNamespace returns a platform whose cache namespace is ns, if set.
+func platformWithNamespace(t *testing.T, ns string) libcnb.Platform {
+	t.Helper()
+	dir := t.TempDir()
+	if ns != "" {
+		if err := os.WriteFile(filepath.Join(dir, cacheNamespaceFile), []byte(ns+"\n"), 0644); err != nil {
+			t.Fatalf("writing cache namespace: %v", err)
+		}
+	}
+	return libcnb.Platform{Path: dir}
+}
+
+// writeLayerMetadata writes the metadata of layer l as the lifecycle does at the end of a build.
+func writeLayerMetadata(t *testing.T, layers string, l *libcnb.Layer) {
+	t.Helper()
+	var b strings.Builder
+	if err := toml.NewEncoder(&b).Encode(map[string]interface{}{"metadata": l.Metadata}); err != nil {
+		t.Fatalf("encoding layer metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(layers, l.Name+".toml"), []byte(b.String()), 0644); err != nil {
+		t.Fatalf("writing layer metadata: %v", err)
+	}
+}
+
+func hashNamespace(ns string) string {
+	h := sha256.Sum256([]byte(ns))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	debugTarballPrefix = "debug-"
+	redactedContent    = "[REDACTED]\n"
+)
+
+var (
+	// secretFileRegexp matches base names of files whose contents must never be included in a debug
+	// snapshot, such as dotenv files, private keys and package manager credentials.
+	secretFileRegexp = regexp.MustCompile(`(?i)^(\.env(\..*)?|.*\.(pem|key|p12|pfx|jks|keystore)|id_(rsa|dsa|ecdsa|ed25519)|\.npmrc|\.yarnrc\.yml|\.netrc|\.pypirc|auth\.json|credentials(\.json)?|.*service[-_]account.*\.json)$`)
+	// secretEnvRegexp matches env var names written into layer env directories (for example
+	// `env/NPM_TOKEN.override`) whose values may be secrets.
+	secretEnvRegexp = regexp.MustCompile(`(?i)(token|secret|password|passwd|credential|auth|api_?key|private)`)
+)
+
+// maybeSaveDebugTarball writes a debug snapshot if requested by env.DebugTarball. failed reports
+// whether the buildpack is exiting with an error.
+func (ctx *Context) maybeSaveDebugTarball(failed bool) {
+	onFailure, always, err := env.DebugTarballMode()
+	if err != nil {
+		ctx.Warnf("Skipping debug snapshot: %v", err)
+		return
+	}
+	if !always && !(onFailure && failed) {
+		return
+	}
+	// Detect does not have layers or a stable workspace state worth capturing.
+	if ctx.buildContext.Layers.Path == "" {
+		return
+	}
+	outputDir := os.Getenv(builderOutputEnv)
+	if outputDir == "" {
+		ctx.Warnf("Skipping debug snapshot: %s is not set", builderOutputEnv)
+		return
+	}
+	fname := filepath.Join(outputDir, debugTarballPrefix+strings.ReplaceAll(ctx.BuildpackID(), "/", "_")+".tar.gz")
+	if err := ctx.saveDebugTarball(fname); err != nil {
+		ctx.Warnf("Failed to write debug snapshot: %v", err)
+		return
+	}
+	ctx.Logf("Wrote debug snapshot of the workspace and layers to %s", fname)
+}
+
+// saveDebugTarball writes a gzipped tarball of the application root and the layers directory
+// to fname. Files which may contain secrets are included with their contents redacted.
+func (ctx *Context) saveDebugTarball(fname string) error {
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return fmt.Errorf("creating dir %s: %w", filepath.Dir(fname), err)
+	}
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", fname, err)
+	}
+	defer f.Close()
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	roots := map[string]string{
+		"workspace": ctx.ApplicationRoot(),
+		"layers":    ctx.buildContext.Layers.Path,
+	}
+	for prefix, root := range roots {
+		if root == "" {
+			continue
+		}
+		if err := addDirToTar(tw, root, prefix, fname); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar writer: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return fmt.Errorf("closing gzip writer: %w", err)
+	}
+	return nil
+}
+
+// addDirToTar adds every file under root to tw, rooted at prefix. The file at skip, if found, is
+// ignored so that a snapshot written into the workspace does not include itself.
+func addDirToTar(tw *tar.Writer, root, prefix, skip string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files may disappear or be unreadable; a partial snapshot is still useful.
+			return nil
+		}
+		if path == skip {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return nil
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("creating tar header for %s: %w", path, err)
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if !info.Mode().IsRegular() {
+			return tw.WriteHeader(hdr)
+		}
+		if isSecretFile(rel) {
+			hdr.Size = int64(len(redactedContent))
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err := io.WriteString(tw, redactedContent)
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", path, err)
+		}
+		defer src.Close()
+		if _, err := io.CopyN(tw, src, hdr.Size); err != nil {
+			return fmt.Errorf("copying %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// isSecretFile returns true if the file at the given relative path may contain secret material.
+func isSecretFile(rel string) bool {
+	base := filepath.Base(rel)
+	if secretFileRegexp.MatchString(base) {
+		return true
+	}
+	dir := filepath.Base(filepath.Dir(rel))
+	if dir == "env" || strings.HasPrefix(dir, "env.") {
+		name := strings.SplitN(base, ".", 2)[0]
+		return secretEnvRegexp.MatchString(name)
+	}
+	return false
+}
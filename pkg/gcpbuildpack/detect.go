@@ -85,6 +85,22 @@ func OptOutEnvNotSet(env string, opts ...DetectResultOption) DetectResult {
 	return OptOut(fmt.Sprintf("%s not set", env), opts...)
 }
 
+// AddRequires returns a copy of result with the given requirements added to each of its build
+// plans, or to a new build plan if it has none.
+func AddRequires(result DetectResult, requires ...libcnb.BuildPlanRequire) DetectResult {
+	lr := result.Result()
+	plans := lr.Plans
+	if len(plans) == 0 {
+		plans = []libcnb.BuildPlan{{}}
+	}
+	lr.Plans = nil
+	for _, p := range plans {
+		p.Requires = append(append([]libcnb.BuildPlanRequire{}, p.Requires...), requires...)
+		lr.Plans = append(lr.Plans, p)
+	}
+	return &detectResult{result: lr, reason: result.Reason()}
+}
+
 func opt(pass bool, reason string, opts ...DetectResultOption) DetectResult {
 	r := &detectResult{
 		reason: reason,
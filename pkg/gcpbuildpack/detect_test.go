@@ -96,6 +96,52 @@ func TestOpt(t *testing.T) {
 	}
 }
 
+func TestAddRequires(t *testing.T) {
+	require := libcnb.BuildPlanRequire{Name: "assets"}
+	testCases := []struct {
+		name   string
+		result DetectResult
+		want   libcnb.DetectResult
+	}{
+		{
+			name:   "no build plans",
+			result: OptIn("some reason"),
+			want: libcnb.DetectResult{
+				Pass:  true,
+				Plans: []libcnb.BuildPlan{{Requires: []libcnb.BuildPlanRequire{require}}},
+			},
+		},
+		{
+			name: "multiple build plans",
+			result: OptIn("some reason", WithBuildPlans(
+				libcnb.BuildPlan{Provides: []libcnb.BuildPlanProvide{{Name: "some-provide"}}},
+				libcnb.BuildPlan{Requires: []libcnb.BuildPlanRequire{{Name: "some-require"}}},
+			)),
+			want: libcnb.DetectResult{
+				Pass: true,
+				Plans: []libcnb.BuildPlan{
+					{
+						Provides: []libcnb.BuildPlanProvide{{Name: "some-provide"}},
+						Requires: []libcnb.BuildPlanRequire{require},
+					},
+					{Requires: []libcnb.BuildPlanRequire{{Name: "some-require"}, require}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := AddRequires(tc.result, require)
+			if want, got := tc.result.Reason(), result.Reason(); want != got {
+				t.Errorf("result.Reason() = %s, want %s", got, want)
+			}
+			if want, got := tc.want, result.Result(); !reflect.DeepEqual(want, got) {
+				t.Errorf("result.Result() = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
 func TestOptIn(t *testing.T) {
 	result := OptIn("some reason", WithBuildPlans(
 		libcnb.BuildPlan{Provides: []libcnb.BuildPlanProvide{{Name: "some-provide"}}},
@@ -0,0 +1,191 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// detectPlanDefaultDir is the directory under $TMPDIR used when env.DetectPlanDir is not set.
+	detectPlanDefaultDir = "google-detect-plan"
+	// detectPlanLoggedMarker is created by the first buildpack to log the detect plan so that it is
+	// only logged once per build.
+	detectPlanLoggedMarker = ".logged"
+)
+
+// DetectPlanDir returns the directory where buildpacks record the outcome of their detect phase.
+func DetectPlanDir() string {
+	if dir := os.Getenv(env.DetectPlanDir); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), detectPlanDefaultDir)
+}
+
+// newDetectRecord returns the record of a detect outcome. Either result or err is set.
+func newDetectRecord(info libcnb.BuildpackInfo, result DetectResult, err error) builderoutput.DetectRecord {
+	r := builderoutput.DetectRecord{BuildpackID: info.ID, BuildpackVersion: info.Version}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	lr := result.Result()
+	r.Pass = lr.Pass
+	r.Reason = strings.TrimPrefix(strings.TrimPrefix(result.Reason(), "Opting in: "), "Opting out: ")
+	for _, p := range lr.Plans {
+		var item builderoutput.DetectPlanItem
+		for _, pr := range p.Provides {
+			item.Provides = append(item.Provides, pr.Name)
+		}
+		for _, rq := range p.Requires {
+			item.Requires = append(item.Requires, rq.Name)
+		}
+		r.Plans = append(r.Plans, item)
+	}
+	return r
+}
+
+// recordDetect writes the outcome of the detect phase to the detect plan directory. Buildpacks
+// detect in parallel, so each one writes its own file. Failures are only logged because the plan
+// is informational.
+func (ctx *Context) recordDetect(result DetectResult, err error) {
+	r := newDetectRecord(ctx.info, result, err)
+	data, merr := json.Marshal(r)
+	if merr != nil {
+		ctx.Debugf("Failed to marshal detect record: %v", merr)
+		return
+	}
+	dir := DetectPlanDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		ctx.Debugf("Failed to create detect plan dir %s: %v", dir, err)
+		return
+	}
+	fname := filepath.Join(dir, strings.ReplaceAll(r.BuildpackID, "/", "_")+".json")
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		ctx.Debugf("Failed to write detect record %s: %v", fname, err)
+	}
+}
+
+// ReadDetectPlan returns the detect records in dir, passing buildpacks first and otherwise sorted
+// by buildpack ID. It returns nil if the directory does not exist.
+func ReadDetectPlan(dir string) ([]builderoutput.DetectRecord, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var records []builderoutput.DetectRecord
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f, err)
+		}
+		var r builderoutput.DetectRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("unmarshalling %s: %w", f, err)
+		}
+		records = append(records, r)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Pass != records[j].Pass {
+			return records[i].Pass
+		}
+		return records[i].BuildpackID < records[j].BuildpackID
+	})
+	return records, nil
+}
+
+// FormatDetectPlan returns the detect records as an aligned table with a header row. Alternative
+// build plans are separated by `|`.
+func FormatDetectPlan(records []builderoutput.DetectRecord) string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BUILDPACK\tVERSION\tRESULT\tPROVIDES\tREQUIRES\tREASON")
+	for _, r := range records {
+		result, reason := "skip", r.Reason
+		switch {
+		case r.Error != "":
+			result, reason = "error", r.Error
+		case r.Pass:
+			result = "pass"
+		}
+		var provides, requires []string
+		for _, p := range r.Plans {
+			provides = append(provides, orDash(strings.Join(p.Provides, ",")))
+			requires = append(requires, orDash(strings.Join(p.Requires, ",")))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.BuildpackID, r.BuildpackVersion, result,
+			orDash(strings.Join(provides, " | ")), orDash(strings.Join(requires, " | ")), reason)
+	}
+	tw.Flush()
+	return sb.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// detectPlan returns the detect records for the builder output, or nil if they cannot be read.
+func (ctx *Context) detectPlan() []builderoutput.DetectRecord {
+	records, err := ReadDetectPlan(DetectPlanDir())
+	if err != nil {
+		ctx.Debugf("Failed to read the detect plan: %v", err)
+		return nil
+	}
+	return records
+}
+
+// maybeLogDetectPlan logs the detect plan if env.ExplainConfig or debug mode is enabled. Only the
+// first buildpack to build logs it.
+func (ctx *Context) maybeLogDetectPlan() {
+	explain, err := env.IsPresentAndTrue(env.ExplainConfig)
+	if err != nil {
+		ctx.Warnf("Failed to parse %s: %v", env.ExplainConfig, err)
+	}
+	if !explain && !ctx.debug {
+		return
+	}
+	dir := DetectPlanDir()
+	records, err := ReadDetectPlan(dir)
+	if err != nil {
+		ctx.Warnf("Failed to read the detect plan: %v", err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, detectPlanLoggedMarker), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		// Already logged by an earlier buildpack.
+		return
+	}
+	f.Close()
+	ctx.Logf("Detect plan:")
+	for _, line := range strings.Split(strings.TrimSuffix(FormatDetectPlan(records), "\n"), "\n") {
+		ctx.Logf("  %s", line)
+	}
+}
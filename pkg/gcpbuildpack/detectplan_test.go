@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordDetect(t *testing.T) {
+	t.Setenv(env.DetectPlanDir, t.TempDir())
+	info := func(id string) ContextOption {
+		return WithBuildpackInfo(libcnb.BuildpackInfo{ID: id, Version: "1.0.0"})
+	}
+	NewContext(info("google.php.runtime")).recordDetect(OptOutFileNotFound("composer.json"), nil)
+	NewContext(info("google.nodejs.runtime")).recordDetect(OptInFileFound("package.json", WithBuildPlans(
+		libcnb.BuildPlan{
+			Provides: []libcnb.BuildPlanProvide{{Name: "node"}},
+			Requires: []libcnb.BuildPlanRequire{{Name: "node"}},
+		},
+		libcnb.BuildPlan{
+			Provides: []libcnb.BuildPlanProvide{{Name: "node"}},
+		},
+	)), nil)
+	NewContext(info("google.python.runtime")).recordDetect(nil, errors.New("boom"))
+
+	got, err := ReadDetectPlan(DetectPlanDir())
+	if err != nil {
+		t.Fatalf("ReadDetectPlan() got error: %v", err)
+	}
+	want := []builderoutput.DetectRecord{
+		{
+			BuildpackID:      "google.nodejs.runtime",
+			BuildpackVersion: "1.0.0",
+			Pass:             true,
+			Reason:           "found package.json",
+			Plans: []builderoutput.DetectPlanItem{
+				{Provides: []string{"node"}, Requires: []string{"node"}},
+				{Provides: []string{"node"}},
+			},
+		},
+		{
+			BuildpackID:      "google.php.runtime",
+			BuildpackVersion: "1.0.0",
+			Reason:           "composer.json not found",
+		},
+		{
+			BuildpackID:      "google.python.runtime",
+			BuildpackVersion: "1.0.0",
+			Error:            "boom",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadDetectPlan() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadDetectPlanMissingDir(t *testing.T) {
+	got, err := ReadDetectPlan(t.TempDir() + "/missing")
+	if err != nil {
+		t.Fatalf("ReadDetectPlan() got error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("ReadDetectPlan() = %v, want nil", got)
+	}
+}
+
+func TestFormatDetectPlan(t *testing.T) {
+	records := []builderoutput.DetectRecord{
+		{
+			BuildpackID:      "google.nodejs.runtime",
+			BuildpackVersion: "1.0.0",
+			Pass:             true,
+			Reason:           "found package.json",
+			Plans: []builderoutput.DetectPlanItem{
+				{Provides: []string{"node"}, Requires: []string{"node"}},
+				{Provides: []string{"node"}},
+			},
+		},
+		{BuildpackID: "google.php.runtime", BuildpackVersion: "1.0.0", Reason: "composer.json not found"},
+		{BuildpackID: "google.python.runtime", BuildpackVersion: "1.0.0", Error: "boom"},
+	}
+	want := `BUILDPACK              VERSION  RESULT  PROVIDES     REQUIRES  REASON
+google.nodejs.runtime  1.0.0    pass    node | node  node | -  found package.json
+google.php.runtime     1.0.0    skip    -            -         composer.json not found
+google.python.runtime  1.0.0    error   -            -         boom
+`
+	if got := FormatDetectPlan(records); got != want {
+		t.Errorf("FormatDetectPlan() = %q, want %q", got, want)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"golang.org/x/sys/unix"
 )
 
@@ -218,7 +219,7 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	ecmd.Stdout = io.MultiWriter(&outb, &combinedb)
 	ecmd.Stderr = io.MultiWriter(&errb, &combinedb)
 
-	if err := ecmd.Run(); err != nil {
+	if err := ctx.runUntilDeadline(ecmd); err != nil {
 		if ee, ok := err.(*exec.ExitError); ok {
 			// The command returned a non-zero result.
 			exitCode = ee.ExitCode()
@@ -252,6 +253,29 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	return result, nil
 }
 
+// runUntilDeadline runs ecmd, and kills it when the deadline set by env.BuildTimeBudget passes.
+func (ctx *Context) runUntilDeadline(ecmd *exec.Cmd) error {
+	if ctx.buildDeadline.IsZero() {
+		return ecmd.Run()
+	}
+	remaining := time.Until(ctx.buildDeadline)
+	if remaining <= 0 {
+		ctx.buildTimeExceeded.Store(true)
+		return fmt.Errorf("the build time budget set with %s is exhausted", env.BuildTimeBudget)
+	}
+	// Processes started by the command may keep its output open after it is killed.
+	ecmd.WaitDelay = time.Second
+	if err := ecmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(remaining, func() {
+		ctx.buildTimeExceeded.Store(true)
+		ecmd.Process.Kill()
+	})
+	defer timer.Stop()
+	return ecmd.Wait()
+}
+
 type lockingBuffer struct {
 	buf bytes.Buffer
 	sync.Mutex
@@ -35,6 +35,8 @@ func (e defaultExiter) Exit(exitCode int, err error) {
 	}
 
 	if exitCode != 0 {
+		e.ctx.maybeSaveDebugTarball(true)
+		e.ctx.maybePauseOnFailure()
 		e.ctx.Tipf(divider)
 		e.ctx.Tipf(`Sorry your project couldn't be built.`)
 		e.ctx.Tipf(`Our documentation explains ways to configure Buildpacks to better recognise your project:`)
@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// defaultUmask is the umask used when env.NormalizeFileModes is set to true.
+const defaultUmask os.FileMode = 022
+
+// FileModeUmask returns the umask set with env.NormalizeFileModes, and false if file modes are
+// not normalized.
+func FileModeUmask() (os.FileMode, bool, error) {
+	v, ok := os.LookupEnv(env.NormalizeFileModes)
+	if !ok || v == "" {
+		return 0, false, nil
+	}
+	// Values such as 1 and 0 are booleans like in the other env vars, umasks must have a leading
+	// zero.
+	if b, err := strconv.ParseBool(strings.ToLower(v)); err == nil {
+		if !b {
+			return 0, false, nil
+		}
+		return defaultUmask, true, nil
+	}
+	umask, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || !strings.HasPrefix(v, "0") || umask > 0777 {
+		return 0, false, UserErrorf("invalid %s %q, it must be true, false or an octal umask with a leading zero such as 022", env.NormalizeFileModes, v)
+	}
+	// Files writable by anyone would let any process of the container modify the application.
+	if umask&0002 == 0 {
+		return 0, false, UserErrorf("invalid %s %q, the umask must remove the write permission of others, such as 022", env.NormalizeFileModes, v)
+	}
+	return os.FileMode(umask), true, nil
+}
+
+// NormalizeFileModes sets the mode of the directories under root to 0777 and of the files to
+// 0666, or 0777 if they are executable by anyone, with the bits of umask cleared. Setuid, setgid
+// and sticky bits are removed. Symlinks are not followed.
+func (ctx *Context) NormalizeFileModes(root string, umask os.FileMode) error {
+	changed, failed := 0, 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0666)
+		if d.IsDir() || info.Mode()&0111 != 0 {
+			mode = 0777
+		}
+		mode &^= umask
+		if info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky) == mode {
+			return nil
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			// Files owned by another user cannot be changed, they are reported once at the end.
+			failed++
+			ctx.Debugf("Changing the mode of %s: %v", path, err)
+			return nil
+		}
+		changed++
+		return nil
+	})
+	if err != nil {
+		return InternalErrorf("normalizing file modes in %s: %w", root, err)
+	}
+	if changed > 0 {
+		ctx.Logf("Normalized the mode of %d files in %s with umask %03o", changed, root, umask)
+	}
+	if failed > 0 {
+		ctx.Warnf("Could not normalize the mode of %d files in %s", failed, root)
+	}
+	return nil
+}
+
+// normalizeLaunchLayerModes normalizes the file modes of the launch layers of the buildpack when
+// env.NormalizeFileModes is set.
+func (ctx *Context) normalizeLaunchLayerModes() error {
+	umask, ok, err := FileModeUmask()
+	if err != nil || !ok {
+		return err
+	}
+	for _, lc := range ctx.buildResult.Layers {
+		l, ok := lc.(layerContributor)
+		if !ok || !l.l.Launch {
+			continue
+		}
+		if err := ctx.NormalizeFileModes(l.l.Path, umask); err != nil {
+			return fmt.Errorf("layer %s: %w", l.l.Name, err)
+		}
+	}
+	return nil
+}
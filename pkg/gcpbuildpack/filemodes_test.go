@@ -0,0 +1,122 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestFileModeUmask(t *testing.T) {
+	testCases := []struct {
+		value     string
+		wantUmask os.FileMode
+		wantOK    bool
+		wantErr   bool
+	}{
+		{value: ""},
+		{value: "false"},
+		{value: "true", wantUmask: 022, wantOK: true},
+		{value: "TRUE", wantUmask: 022, wantOK: true},
+		{value: "1", wantUmask: 022, wantOK: true},
+		{value: "0"},
+		{value: "027", wantUmask: 027, wantOK: true},
+		{value: "077", wantUmask: 077, wantOK: true},
+		{value: "22", wantErr: true},
+		{value: "000", wantErr: true},
+		{value: "020", wantErr: true},
+		{value: "1777", wantErr: true},
+		{value: "rwxr-xr-x", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(env.NormalizeFileModes, tc.value)
+			umask, ok, err := FileModeUmask()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("FileModeUmask() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if umask != tc.wantUmask || ok != tc.wantOK {
+				t.Errorf("FileModeUmask() = %03o, %t, want %03o, %t", umask, ok, tc.wantUmask, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestNormalizeLaunchLayerModes(t *testing.T) {
+	t.Setenv(env.NormalizeFileModes, "true")
+	ctx := NewContext(WithApplicationRoot(t.TempDir()))
+	launch := &libcnb.Layer{Name: "launch", Path: t.TempDir(), LayerTypes: libcnb.LayerTypes{Launch: true}}
+	build := &libcnb.Layer{Name: "build", Path: t.TempDir(), LayerTypes: libcnb.LayerTypes{Build: true}}
+	ctx.buildResult.Layers = append(ctx.buildResult.Layers, layerContributor{launch}, layerContributor{build})
+
+	files := map[string]os.FileMode{
+		"lib/index.js":  0666,
+		"bin/start":     0775,
+		"bin/setuid":    0755 | os.ModeSetuid,
+		"lib/README.md": 0600,
+	}
+	for _, l := range []*libcnb.Layer{launch, build} {
+		for f, mode := range files {
+			path := filepath.Join(l.Path, f)
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Chmod(filepath.Join(l.Path, "lib"), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("lib/index.js", filepath.Join(l.Path, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ctx.normalizeLaunchLayerModes(); err != nil {
+		t.Fatalf("normalizeLaunchLayerModes() got error: %v", err)
+	}
+
+	want := map[string]os.FileMode{
+		"lib/index.js":  0644,
+		"bin/start":     0755,
+		"bin/setuid":    0755,
+		"lib/README.md": 0644,
+		"lib":           0755 | os.ModeDir,
+	}
+	for f, mode := range want {
+		info, err := os.Stat(filepath.Join(launch.Path, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode() & (os.ModeDir | os.ModePerm | os.ModeSetuid); got != mode {
+			t.Errorf("mode of %s in launch layer = %v, want %v", f, got, mode)
+		}
+	}
+	info, err := os.Stat(filepath.Join(build.Path, "lib/index.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0666 {
+		t.Errorf("mode of lib/index.js in build layer = %v, want unchanged 0666", got)
+	}
+}
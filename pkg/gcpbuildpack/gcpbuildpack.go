@@ -19,15 +19,16 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/buildpacks/libcnb"
 )
@@ -82,6 +83,8 @@ type Context struct {
 	stats                    stats
 	exiter                   Exiter
 	warnings                 []string
+	categoryWarnings         map[WarningCategory]int
+	settings                 []builderoutput.ConfigSetting
 
 	// detect items
 	detectContext libcnb.DetectContext
@@ -89,6 +92,10 @@ type Context struct {
 	// build items
 	buildContext libcnb.BuildContext
 	buildResult  libcnb.BuildResult
+	// buildDeadline is the time commands are stopped at when env.BuildTimeBudget is set, and
+	// buildTimeExceeded is set once a command is stopped.
+	buildDeadline     time.Time
+	buildTimeExceeded atomic.Bool
 
 	execCmd func(name string, arg ...string) *exec.Cmd
 }
@@ -209,6 +216,12 @@ func (ctx *Context) BuildpackRoot() string {
 	return ctx.buildpackRoot
 }
 
+// LayersDir returns the layers directory of the buildpack. Its parent contains the layers
+// directories of all buildpacks in the build. It is only set during the build phase.
+func (ctx *Context) LayersDir() string {
+	return ctx.buildContext.Layers.Path
+}
+
 // StackID returns the stack id.
 func (ctx *Context) StackID() string {
 	return ctx.buildContext.StackID
@@ -249,6 +262,9 @@ func (gcpd gcpdetector) Detect(ldctx libcnb.DetectContext) (libcnb.DetectResult,
 	}(time.Now())
 
 	result, err := gcpd.detectFn(ctx)
+	if err != nil || result != nil {
+		ctx.recordDetect(result, err)
+	}
 	if err != nil {
 		msg := fmt.Sprintf("Failed to run /bin/detect: %v", err)
 		var be *buildererror.Error
@@ -282,13 +298,32 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 	start := time.Now()
 	ctx := newBuildContext(lbctx)
 	ctx.Logf("=== %s (%s@%s) ===", ctx.BuildpackName(), ctx.BuildpackID(), ctx.BuildpackVersion())
+	ctx.maybeLogDetectPlan()
 
 	status := buildererror.StatusInternal
 	defer func(now time.Time) {
 		ctx.Span(fmt.Sprintf("Buildpack Build %s", ctx.BuildpackID()), now, status)
 	}(time.Now())
 
-	if err := gcpb.buildFn(ctx); err != nil {
+	err := ctx.startBuildTimeBudget(start)
+	if err == nil {
+		err = ctx.configureBuildResolution()
+	}
+	if err == nil {
+		err = gcpb.buildFn(ctx)
+	}
+	if err == nil || ctx.buildTimeExceeded.Load() {
+		if budgetErr := ctx.checkBuildTimeBudget(time.Since(start)); budgetErr != nil {
+			err = budgetErr
+		}
+	}
+	if err == nil {
+		err = ctx.checkWarningsBudget()
+	}
+	if err == nil {
+		err = ctx.normalizeLaunchLayerModes()
+	}
+	if err != nil {
 		var be *buildererror.Error
 		if errors.As(err, &be) {
 			status = be.Status
@@ -298,7 +333,9 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 	}
 
 	status = buildererror.StatusOk
+	ctx.maybeLogSettings()
 	ctx.saveSuccessOutput(time.Since(start))
+	ctx.maybeSaveDebugTarball(false)
 	return ctx.buildResult, nil
 }
 
@@ -388,6 +425,17 @@ func (ctx *Context) AddBOMEntry(entry libcnb.BOMEntry) {
 	ctx.buildResult.BOM.Entries = append(ctx.buildResult.BOM.Entries, entry)
 }
 
+// AddSlice adds an application slice. Files in the application directory matching the given paths
+// are exported in their own image layer, so that they can be reused when other files change.
+func (ctx *Context) AddSlice(paths ...string) {
+	ctx.buildResult.Slices = append(ctx.buildResult.Slices, libcnb.Slice{Paths: paths})
+}
+
+// Slices returns the application slices added with AddSlice.
+func (ctx *Context) Slices() []libcnb.Slice {
+	return ctx.buildResult.Slices
+}
+
 // AddWebProcess adds the given command as the web start process, overwriting any previous web start process.
 func (ctx *Context) AddWebProcess(cmd []string) {
 	ctx.AddProcess(WebProcess, cmd, AsDirectProcess(), AsDefaultProcess())
@@ -406,6 +454,12 @@ func AsDefaultProcess() processOption {
 	return func(o *libcnb.Process) { o.Default = true }
 }
 
+// WithWorkingDirectory sets the directory the process is executed in. An empty dir keeps the
+// default, which is the application root.
+func WithWorkingDirectory(dir string) processOption {
+	return func(o *libcnb.Process) { o.WorkingDirectory = dir }
+}
+
 // AddProcess adds the given command as named process, overwriting any previous process with the same name.
 func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption) {
 	current := ctx.buildResult.Processes
@@ -432,10 +486,11 @@ func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption)
 
 // HTTPStatus returns the status code for a url.
 func (ctx *Context) HTTPStatus(url string) (int, error) {
-	res, err := http.Head(url)
+	res, err := HTTPClient().Head(url)
 	if err != nil {
 		return 0, InternalErrorf("getting status code for %s: %v", url, err)
 	}
+	defer res.Body.Close()
 	return res.StatusCode, nil
 }
 
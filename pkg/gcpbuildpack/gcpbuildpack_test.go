@@ -704,6 +704,7 @@ func simpleContext(t *testing.T) (*Context, func()) {
 func setUpDetectEnvironment(t *testing.T) buildpacktestenv.TempDirs {
 	t.Helper()
 	temps := buildpacktestenv.SetUpTempDirs(t, "")
+	t.Setenv(env.DetectPlanDir, t.TempDir())
 	setOSArgs(t, []string{filepath.Join(temps.BuildpackDir, "bin", "detect"), temps.PlatformDir, temps.PlanFile})
 
 	return temps
@@ -714,6 +715,7 @@ func setUpDetectEnvironment(t *testing.T) buildpacktestenv.TempDirs {
 func setUpBuildEnvironment(t *testing.T) buildpacktestenv.TempDirs {
 	t.Helper()
 	temps := buildpacktestenv.SetUpTempDirs(t, "")
+	t.Setenv(env.DetectPlanDir, t.TempDir())
 	setOSArgs(t, []string{filepath.Join(temps.BuildpackDir, "bin", "build"), temps.LayersDir, temps.PlatformDir, temps.PlanFile})
 
 	return temps
@@ -0,0 +1,167 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+)
+
+const (
+	// dnsCacheTTL is how long resolved addresses are reused before the host is looked up again.
+	dnsCacheTTL = 5 * time.Minute
+)
+
+var (
+	httpClientOnce sync.Once
+	httpTransport  http.RoundTripper
+	httpClient     *http.Client
+
+	// httpStats counts the requests made with the shared HTTP client and the connections and DNS
+	// lookups it needed. They are added to the builder metrics when the build output is saved.
+	httpStats struct {
+		requests, connections, dnsLookups, dnsCacheHits atomic.Int64
+	}
+
+	// lookupHost can be overridden for testing.
+	lookupHost = net.DefaultResolver.LookupHost
+)
+
+// HTTPClient returns the HTTP client shared by all downloads of a buildpack. Connections are pooled
+// and reused across requests, negotiate HTTP/2 where the server supports it, and resolved host
+// addresses are cached so builds making hundreds of registry requests only pay for the DNS lookup
+// and TLS handshake once per host.
+func HTTPClient() *http.Client {
+	initHTTPClient()
+	return httpClient
+}
+
+// HTTPTransport returns the transport of the shared HTTP client, for libraries which build their own
+// client such as go-containerregistry.
+func HTTPTransport() http.RoundTripper {
+	initHTTPClient()
+	return httpTransport
+}
+
+func initHTTPClient() {
+	httpClientOnce.Do(func() {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		dns := &dnsCache{entries: map[string]dnsEntry{}}
+		httpTransport = &countingTransport{base: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dns.dial(ctx, dialer, network, addr)
+			},
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   16,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		}}
+		httpClient = &http.Client{Transport: httpTransport}
+	})
+}
+
+// countingTransport counts the requests sent through the shared HTTP client.
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	httpStats.requests.Add(1)
+	return t.base.RoundTrip(req)
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsCache caches the addresses of the hosts dialed by the shared HTTP client.
+type dnsCache struct {
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+// lookup returns the addresses of host set with env.BuildHosts, or from the cache if they were
+// resolved less than dnsCacheTTL ago.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := lookupBuildHost(host); ok {
+		return addrs, nil
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		httpStats.dnsCacheHits.Add(1)
+		return entry.addrs, nil
+	}
+	httpStats.dnsLookups.Add(1)
+	addrs, err := lookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(dnsCacheTTL)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// dial connects to addr using the cached addresses of its host, trying each in turn.
+func (c *dnsCache) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	httpStats.connections.Add(1)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	}
+	var conn net.Conn
+	for _, ip := range addrs {
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// recordHTTPMetrics moves the shared HTTP client statistics into the builder metrics.
+func recordHTTPMetrics() {
+	bm := buildermetrics.GlobalBuilderMetrics()
+	for id, v := range map[buildermetrics.MetricID]*atomic.Int64{
+		buildermetrics.HTTPRequestsCounterID:    &httpStats.requests,
+		buildermetrics.HTTPConnectionsCounterID: &httpStats.connections,
+		buildermetrics.DNSLookupsCounterID:      &httpStats.dnsLookups,
+		buildermetrics.DNSCacheHitsCounterID:    &httpStats.dnsCacheHits,
+	} {
+		if n := v.Swap(0); n > 0 {
+			bm.GetCounter(id).Increment(n)
+		}
+	}
+}
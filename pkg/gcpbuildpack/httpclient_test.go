@@ -0,0 +1,106 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+)
+
+func TestHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parsing server URL: %v", err)
+	}
+
+	defer func(fn func(context.Context, string) ([]string, error)) { lookupHost = fn }(lookupHost)
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if host != "registry.test" {
+			return nil, fmt.Errorf("unexpected host %q", host)
+		}
+		return []string{u.Hostname()}, nil
+	}
+	buildermetrics.Reset()
+	recordHTTPMetrics()
+
+	const requests = 5
+	for i := 0; i < requests; i++ {
+		res, err := HTTPClient().Get(fmt.Sprintf("http://registry.test:%s/pkg/%d", u.Port(), i))
+		if err != nil {
+			t.Fatalf("Get() got error: %v", err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+	recordHTTPMetrics()
+
+	bm := buildermetrics.GlobalBuilderMetrics()
+	want := map[buildermetrics.MetricID]int64{
+		buildermetrics.HTTPRequestsCounterID:    requests,
+		buildermetrics.HTTPConnectionsCounterID: 1,
+		buildermetrics.DNSLookupsCounterID:      1,
+		buildermetrics.DNSCacheHitsCounterID:    0,
+	}
+	for id, n := range want {
+		if got := bm.GetCounter(id).Value(); got != n {
+			t.Errorf("counter %s = %d, want %d", id, got, n)
+		}
+	}
+}
+
+func TestDNSCacheLookup(t *testing.T) {
+	defer func(fn func(context.Context, string) ([]string, error)) { lookupHost = fn }(lookupHost)
+	lookups := 0
+	lookupHost = func(context.Context, string) ([]string, error) {
+		lookups++
+		return []string{"10.0.0.1"}, nil
+	}
+
+	c := &dnsCache{entries: map[string]dnsEntry{}}
+	for i := 0; i < 3; i++ {
+		addrs, err := c.lookup(context.Background(), "registry.test")
+		if err != nil {
+			t.Fatalf("lookup() got error: %v", err)
+		}
+		if len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+			t.Errorf("lookup() = %v, want [10.0.0.1]", addrs)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("lookup() resolved the host %d times, want 1", lookups)
+	}
+
+	// Expired entries are resolved again.
+	entry := c.entries["registry.test"]
+	entry.expires = entry.expires.Add(-2 * dnsCacheTTL)
+	c.entries["registry.test"] = entry
+	if _, err := c.lookup(context.Background(), "registry.test"); err != nil {
+		t.Fatalf("lookup() got error: %v", err)
+	}
+	if lookups != 2 {
+		t.Errorf("lookup() resolved the host %d times after expiry, want 2", lookups)
+	}
+}
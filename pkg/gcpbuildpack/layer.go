@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/buildpacks/libcnb"
 )
@@ -35,10 +36,10 @@ var BuildLayer = func(ctx *Context, l *libcnb.Layer) error {
 	return nil
 }
 
-// CacheLayer specifies a Cache layer.
+// CacheLayer specifies a Cache layer, isolated in the cache namespace of the build.
 var CacheLayer = func(ctx *Context, l *libcnb.Layer) error {
 	l.Cache = true
-	return nil
+	return ctx.isolateCache(l)
 }
 
 // LaunchLayer specifies a Launch layer.
@@ -110,7 +111,8 @@ func (lc layerContributor) Name() string {
 	return lc.l.Name
 }
 
-// ClearLayer erases the existing layer, and re-creates the directory.
+// ClearLayer erases the existing layer, and re-creates the directory. The cache namespace the
+// layer is isolated in is kept, so that the layer is reused by the next build in the namespace.
 func (ctx *Context) ClearLayer(l *libcnb.Layer) error {
 	if err := ctx.RemoveAll(l.Path); err != nil {
 		return err
@@ -118,10 +120,22 @@ func (ctx *Context) ClearLayer(l *libcnb.Layer) error {
 	if err := ctx.MkdirAll(l.Path, layerMode); err != nil {
 		return err
 	}
+	ns, stamped := l.Metadata[cacheNamespaceKey]
 	l.Metadata = make(map[string]interface{})
+	if stamped {
+		l.Metadata[cacheNamespaceKey] = ns
+	}
 	return nil
 }
 
+// CacheCorrupted records that the content restored from the cached layer l is corrupted, as
+// described by reason, as a warning and in the builder metrics. The caller is expected to clear the
+// layer and retry the step which restored it without the cache.
+func (ctx *Context) CacheCorrupted(l *libcnb.Layer, reason string) {
+	buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.CacheCorruptionsCounterID).Increment(1)
+	ctx.Warnf("The cache of layer %q is corrupted, retrying without it: %s", l.Name, reason)
+}
+
 // SetMetadata sets metadata on the layer.
 func (ctx *Context) SetMetadata(l *libcnb.Layer, key, value string) {
 	l.Metadata[key] = value
@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// resumeFileName is created in the temp dir while paused; deleting it ends the pause early.
+	resumeFileName = "buildpacks-paused"
+	// pausePollInterval is how often the resume file is checked while paused.
+	pausePollInterval = time.Second
+)
+
+// pauseSleep can be overridden for testing.
+var pauseSleep = time.Sleep
+
+// maybePauseOnFailure keeps the build container alive after a failure if requested by
+// env.DebugPauseOnFailure, printing instructions to attach to the container.
+func (ctx *Context) maybePauseOnFailure() {
+	val := os.Getenv(env.DebugPauseOnFailure)
+	if val == "" {
+		return
+	}
+	if !env.IsGCP() {
+		ctx.Debugf("Ignoring %s on a hosted platform.", env.DebugPauseOnFailure)
+		return
+	}
+	window, err := time.ParseDuration(val)
+	if err != nil {
+		ctx.Warnf("Not pausing, invalid %s %q: %v", env.DebugPauseOnFailure, val, err)
+		return
+	}
+	if window <= 0 {
+		return
+	}
+	ctx.pause(window, filepath.Join(os.TempDir(), resumeFileName))
+}
+
+// pause blocks for the given window or until resumeFile is deleted, whichever comes first.
+func (ctx *Context) pause(window time.Duration, resumeFile string) {
+	if err := os.WriteFile(resumeFile, nil, 0644); err != nil {
+		ctx.Warnf("Not pausing, failed to create %s: %v", resumeFile, err)
+		return
+	}
+	defer os.Remove(resumeFile)
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "<container-id>"
+	}
+	ctx.Logf(divider)
+	ctx.Logf("Build failed, pausing for %v so the build container can be inspected.", window)
+	ctx.Logf("Attach to the build container with:")
+	ctx.Logf("  docker exec -it %s /bin/bash", host)
+	ctx.Logf("The application is at %s and layers are at %s.", ctx.ApplicationRoot(), ctx.buildContext.Layers.Path)
+	ctx.Logf("To resume immediately, delete %s.", resumeFile)
+	ctx.Logf(divider)
+
+	for deadline := time.Now().Add(window); time.Now().Before(deadline); pauseSleep(pausePollInterval) {
+		if _, err := os.Stat(resumeFile); os.IsNotExist(err) {
+			ctx.Logf("%s deleted, resuming.", resumeFile)
+			return
+		}
+	}
+	ctx.Logf("Pause window of %v elapsed, resuming.", window)
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	testCases := []struct {
+		name       string
+		window     time.Duration
+		deleteFile bool
+		wantLog    string
+	}{
+		{
+			name:    "window elapses",
+			window:  50 * time.Millisecond,
+			wantLog: "Pause window of 50ms elapsed",
+		},
+		{
+			name:       "resume file deleted",
+			window:     time.Hour,
+			deleteFile: true,
+			wantLog:    "deleted, resuming",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resumeFile := filepath.Join(t.TempDir(), resumeFileName)
+			oldSleep := pauseSleep
+			t.Cleanup(func() { pauseSleep = oldSleep })
+			pauseSleep = func(time.Duration) {
+				time.Sleep(10 * time.Millisecond)
+				if tc.deleteFile {
+					os.Remove(resumeFile)
+				}
+			}
+			var buf bytes.Buffer
+			ctx := NewContext(WithLogger(log.New(&buf, "", 0)))
+
+			ctx.pause(tc.window, resumeFile)
+
+			if !strings.Contains(buf.String(), "docker exec -it") {
+				t.Errorf("pause() did not log attach instructions, got:\n%s", buf.String())
+			}
+			if !strings.Contains(buf.String(), tc.wantLog) {
+				t.Errorf("pause() log missing %q, got:\n%s", tc.wantLog, buf.String())
+			}
+			if _, err := os.Stat(resumeFile); !os.IsNotExist(err) {
+				t.Errorf("pause() left %s behind", resumeFile)
+			}
+		})
+	}
+}
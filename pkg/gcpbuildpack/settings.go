@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// Configuration sources recorded with RecordSetting, in order of decreasing precedence.
+const (
+	// SourceEnv indicates the value was set with an environment variable.
+	SourceEnv = "env var"
+	// SourceAppHostingYAML indicates the value was read from apphosting.yaml.
+	SourceAppHostingYAML = "apphosting.yaml"
+	// SourceComposerExtra indicates the value was read from the google-buildpacks composer.json extra.
+	SourceComposerExtra = "composer extra"
+	// SourceAppYAML indicates the value was read from app.yaml.
+	SourceAppYAML = "app.yaml"
+	// SourceComposerJSON indicates the value was read from a standard composer.json field.
+	SourceComposerJSON = "composer.json"
+	// SourcePackageJSON indicates the value was read from package.json.
+	SourcePackageJSON = "package.json"
+	// SourceFramework indicates the value is the default of the framework the application uses.
+	SourceFramework = "framework"
+	// SourceDefault indicates no source provided a value and the buildpack default was used.
+	SourceDefault = "default"
+)
+
+// RecordSetting records the effective value of a build setting and the configuration source it
+// was taken from. Settings are included in the builder output and logged when
+// env.ExplainConfig is enabled. Recording the same name again replaces the earlier entry.
+func (ctx *Context) RecordSetting(name, value, source string) {
+	for i, s := range ctx.settings {
+		if s.Name == name {
+			ctx.settings[i] = builderoutput.ConfigSetting{Name: name, Value: value, Source: source}
+			return
+		}
+	}
+	ctx.settings = append(ctx.settings, builderoutput.ConfigSetting{Name: name, Value: value, Source: source})
+}
+
+// Settings returns the settings recorded with RecordSetting.
+func (ctx *Context) Settings() []builderoutput.ConfigSetting {
+	return ctx.settings
+}
+
+// maybeLogSettings logs a table of the recorded settings if env.ExplainConfig or debug mode is enabled.
+func (ctx *Context) maybeLogSettings() {
+	if len(ctx.settings) == 0 {
+		return
+	}
+	explain, err := env.IsPresentAndTrue(env.ExplainConfig)
+	if err != nil {
+		ctx.Warnf("Failed to parse %s: %v", env.ExplainConfig, err)
+	}
+	if !explain && !ctx.debug {
+		return
+	}
+	ctx.Logf("Effective configuration:")
+	for _, line := range strings.Split(strings.TrimSuffix(formatSettings(ctx.settings), "\n"), "\n") {
+		ctx.Logf("  %s", line)
+	}
+}
+
+// formatSettings returns the settings as an aligned table with a header row.
+func formatSettings(settings []builderoutput.ConfigSetting) string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range settings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, s.Value, s.Source)
+	}
+	tw.Flush()
+	return sb.String()
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordSetting(t *testing.T) {
+	ctx := NewContext()
+	ctx.RecordSetting("document root", "/workspace", SourceDefault)
+	ctx.RecordSetting("runtime version", "8.3.4", SourceComposerExtra)
+	ctx.RecordSetting("document root", "/workspace/public", SourceAppYAML)
+
+	want := []builderoutput.ConfigSetting{
+		{Name: "document root", Value: "/workspace/public", Source: SourceAppYAML},
+		{Name: "runtime version", Value: "8.3.4", Source: SourceComposerExtra},
+	}
+	if diff := cmp.Diff(want, ctx.Settings()); diff != "" {
+		t.Errorf("Settings() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFormatSettings(t *testing.T) {
+	settings := []builderoutput.ConfigSetting{
+		{Name: "document root", Value: "/workspace/public", Source: SourceAppYAML},
+		{Name: "fpm workers", Value: "2", Source: SourceDefault},
+	}
+	want := `SETTING        VALUE              SOURCE
+document root  /workspace/public  app.yaml
+fpm workers    2                  default
+`
+	if got := formatSettings(settings); got != want {
+		t.Errorf("formatSettings() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// buildTimingsFilename is the file in the parent of the layers directories the buildpacks of a
+	// build record their durations in, so that the time budget covers the whole build.
+	buildTimingsFilename = "google-build-timings.json"
+	// slowestSteps is the number of the slowest commands of each buildpack kept for the breakdown.
+	slowestSteps = 3
+)
+
+// buildTiming is the time a buildpack took to build.
+type buildTiming struct {
+	BuildpackID string       `json:"buildpackId"`
+	DurationMs  int64        `json:"durationMs"`
+	Slowest     []stepTiming `json:"slowest,omitempty"`
+}
+
+// stepTiming is the time a command run by a buildpack took.
+type stepTiming struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// startBuildTimeBudget sets the deadline of the commands run by the buildpack to the time left of
+// env.BuildTimeBudget, and returns an error if it is already exhausted.
+func (ctx *Context) startBuildTimeBudget(start time.Time) error {
+	budget, err := buildTimeBudget()
+	if err != nil || budget == 0 {
+		return err
+	}
+	timings, err := ctx.readBuildTimings()
+	if err != nil {
+		return err
+	}
+	used := totalDuration(timings)
+	if used >= budget {
+		return ctx.buildTimeBudgetError(budget, timings)
+	}
+	ctx.buildDeadline = start.Add(budget - used)
+	return nil
+}
+
+// checkBuildTimeBudget records the duration of the buildpack for the following buildpacks, warns if
+// it exceeds its limit in env.BuildPhaseTimeBudgets and returns an error with the timing breakdown
+// if the build exceeded env.BuildTimeBudget.
+func (ctx *Context) checkBuildTimeBudget(duration time.Duration) error {
+	budget, err := buildTimeBudget()
+	if err != nil {
+		return err
+	}
+	limit, err := phaseTimeBudget(ctx.BuildpackID())
+	if err != nil {
+		return err
+	}
+	if budget == 0 && limit == 0 {
+		return nil
+	}
+	if limit > 0 && duration > limit {
+		ctx.Warnf("%s took %v, which exceeds its limit of %v set with %s.", ctx.BuildpackID(), duration.Round(time.Millisecond), limit, env.BuildPhaseTimeBudgets)
+	}
+	timings, err := ctx.readBuildTimings()
+	if err != nil {
+		return err
+	}
+	timings = append(timings, ctx.buildTiming(duration))
+	if err := ctx.writeBuildTimings(timings); err != nil {
+		return err
+	}
+	if budget > 0 && (totalDuration(timings) > budget || ctx.buildTimeExceeded.Load()) {
+		return ctx.buildTimeBudgetError(budget, timings)
+	}
+	return nil
+}
+
+// buildTiming returns the timing of the buildpack with its slowest commands.
+func (ctx *Context) buildTiming(duration time.Duration) buildTiming {
+	var steps []stepTiming
+	for _, s := range ctx.stats.spans {
+		if s == nil || !strings.HasPrefix(s.name, "Exec ") {
+			continue
+		}
+		steps = append(steps, stepTiming{Name: s.name, DurationMs: s.end.Sub(s.start).Milliseconds()})
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].DurationMs > steps[j].DurationMs })
+	if len(steps) > slowestSteps {
+		steps = steps[:slowestSteps]
+	}
+	return buildTiming{BuildpackID: ctx.BuildpackID(), DurationMs: duration.Milliseconds(), Slowest: steps}
+}
+
+// buildTimeBudgetError returns an error with the time taken by each buildpack, and the slowest
+// commands of the build.
+func (ctx *Context) buildTimeBudgetError(budget time.Duration, timings []buildTiming) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "the build took %v, which exceeds the budget of %v set with %s\n", totalDuration(timings), budget, env.BuildTimeBudget)
+	fmt.Fprintf(&b, "Time per buildpack:\n")
+	type slowStep struct {
+		stepTiming
+		buildpackID string
+	}
+	var steps []slowStep
+	for _, t := range timings {
+		d := time.Duration(t.DurationMs) * time.Millisecond
+		line := fmt.Sprintf("  %-40s %v", t.BuildpackID, d)
+		if limit, err := phaseTimeBudget(t.BuildpackID); err == nil && limit > 0 && d > limit {
+			line += fmt.Sprintf(" (limit %v)", limit)
+		}
+		fmt.Fprintln(&b, line)
+		for _, s := range t.Slowest {
+			steps = append(steps, slowStep{s, t.BuildpackID})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].DurationMs > steps[j].DurationMs })
+	if len(steps) > slowestSteps {
+		steps = steps[:slowestSteps]
+	}
+	if len(steps) > 0 {
+		fmt.Fprintf(&b, "Slowest steps:\n")
+		for _, s := range steps {
+			fmt.Fprintf(&b, "  %v %s (%s)\n", time.Duration(s.DurationMs)*time.Millisecond, s.Name, s.buildpackID)
+		}
+	}
+	return UserErrorf("%s", strings.TrimSuffix(b.String(), "\n"))
+}
+
+// readBuildTimings returns the timings recorded by the previous buildpacks of the build.
+func (ctx *Context) readBuildTimings() ([]buildTiming, error) {
+	raw, err := os.ReadFile(ctx.buildTimingsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, InternalErrorf("reading build timings: %w", err)
+	}
+	var timings []buildTiming
+	if err := json.Unmarshal(raw, &timings); err != nil {
+		return nil, InternalErrorf("unmarshalling build timings: %w", err)
+	}
+	return timings, nil
+}
+
+// writeBuildTimings saves the timings of the build for the following buildpacks.
+func (ctx *Context) writeBuildTimings(timings []buildTiming) error {
+	raw, err := json.Marshal(timings)
+	if err != nil {
+		return InternalErrorf("marshalling build timings: %w", err)
+	}
+	if err := os.WriteFile(ctx.buildTimingsPath(), raw, 0644); err != nil {
+		return InternalErrorf("writing build timings: %w", err)
+	}
+	return nil
+}
+
+func (ctx *Context) buildTimingsPath() string {
+	return filepath.Join(filepath.Dir(ctx.LayersDir()), buildTimingsFilename)
+}
+
+// totalDuration returns the sum of the durations of timings.
+func totalDuration(timings []buildTiming) time.Duration {
+	var total int64
+	for _, t := range timings {
+		total += t.DurationMs
+	}
+	return time.Duration(total) * time.Millisecond
+}
+
+// buildTimeBudget returns the budget set with env.BuildTimeBudget, or 0 if it is not set.
+func buildTimeBudget() (time.Duration, error) {
+	return parseTimeBudget(env.BuildTimeBudget, os.Getenv(env.BuildTimeBudget))
+}
+
+// phaseTimeBudget returns the limit of the buildpack set with env.BuildPhaseTimeBudgets, or 0 if
+// it has none.
+func phaseTimeBudget(buildpackID string) (time.Duration, error) {
+	for _, entry := range strings.Split(os.Getenv(env.BuildPhaseTimeBudgets), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return 0, UserErrorf("%s entry %q must be of the form <buildpack id>=<duration>", env.BuildPhaseTimeBudgets, entry)
+		}
+		if strings.TrimSpace(id) == buildpackID {
+			return parseTimeBudget(env.BuildPhaseTimeBudgets, value)
+		}
+	}
+	return 0, nil
+}
+
+// parseTimeBudget parses a duration such as `10m`, or a number of seconds.
+func parseTimeBudget(name, v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, UserErrorf("%s=%q must be a duration such as 10m or a number of seconds", name, v)
+	}
+	return d, nil
+}
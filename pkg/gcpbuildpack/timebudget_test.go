@@ -0,0 +1,186 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestParseTimeBudget(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "600", want: 10 * time.Minute},
+		{value: "10m", want: 10 * time.Minute},
+		{value: " 1h30m ", want: 90 * time.Minute},
+		{value: "-5m", wantErr: true},
+		{value: "ten minutes", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseTimeBudget(env.BuildTimeBudget, tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseTimeBudget(%q) got error: %v, want error: %t", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("parseTimeBudget(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPhaseTimeBudget(t *testing.T) {
+	testCases := []struct {
+		name    string
+		budgets string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:    "set",
+			budgets: "google.nodejs.runtime=1m, google.nodejs.npm=5m",
+			want:    5 * time.Minute,
+		},
+		{
+			name:    "other buildpacks",
+			budgets: "google.python.pip=90",
+		},
+		{
+			name:    "invalid entry",
+			budgets: "google.nodejs.npm",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.BuildPhaseTimeBudgets, tc.budgets)
+			got, err := phaseTimeBudget("google.nodejs.npm")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("phaseTimeBudget() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("phaseTimeBudget() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckBuildTimeBudget(t *testing.T) {
+	previous := `[{"buildpackId":"google.nodejs.runtime","durationMs":60000,"slowest":[{"name":"Exec \"tar\"","durationMs":50000}]}]`
+	testCases := []struct {
+		name        string
+		budget      string
+		phases      string
+		duration    time.Duration
+		wantErr     bool
+		wantRecords bool
+	}{
+		{
+			name:     "unset",
+			duration: time.Hour,
+		},
+		{
+			name:        "within budget",
+			budget:      "10m",
+			duration:    5 * time.Minute,
+			wantRecords: true,
+		},
+		{
+			name:        "exceeds budget with previous buildpacks",
+			budget:      "10m",
+			duration:    9*time.Minute + time.Second,
+			wantErr:     true,
+			wantRecords: true,
+		},
+		{
+			name:        "exceeds only the phase limit",
+			phases:      "google.nodejs.npm=2m",
+			duration:    3 * time.Minute,
+			wantRecords: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.BuildTimeBudget, tc.budget)
+			t.Setenv(env.BuildPhaseTimeBudgets, tc.phases)
+			layersRoot := t.TempDir()
+			if err := os.WriteFile(filepath.Join(layersRoot, buildTimingsFilename), []byte(previous), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx := NewContext(
+				WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.npm"}),
+				WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: filepath.Join(layersRoot, "google.nodejs.npm")}}),
+			)
+
+			err := ctx.checkBuildTimeBudget(tc.duration)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("checkBuildTimeBudget() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "google.nodejs.runtime") {
+				t.Errorf("checkBuildTimeBudget() error %q does not include the timing breakdown", err)
+			}
+			timings, err := ctx.readBuildTimings()
+			if err != nil {
+				t.Fatalf("readBuildTimings() got error: %v", err)
+			}
+			if gotRecords := len(timings) == 2; gotRecords != tc.wantRecords {
+				t.Errorf("checkBuildTimeBudget() recorded timings: %v, want recorded: %t", timings, tc.wantRecords)
+			}
+		})
+	}
+}
+
+func TestStartBuildTimeBudgetExhausted(t *testing.T) {
+	t.Setenv(env.BuildTimeBudget, "30s")
+	layersRoot := t.TempDir()
+	previous := `[{"buildpackId":"google.nodejs.runtime","durationMs":31000}]`
+	if err := os.WriteFile(filepath.Join(layersRoot, buildTimingsFilename), []byte(previous), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: filepath.Join(layersRoot, "google.nodejs.npm")}}))
+
+	if err := ctx.startBuildTimeBudget(time.Now()); err == nil {
+		t.Error("startBuildTimeBudget() got no error, want the budget exhausted error")
+	}
+}
+
+func TestExecStopsAtBuildDeadline(t *testing.T) {
+	ctx := NewContext()
+	ctx.buildDeadline = time.Now().Add(100 * time.Millisecond)
+	start := time.Now()
+
+	if _, err := ctx.Exec([]string{"sleep", "10"}); err == nil {
+		t.Fatal("Exec() got no error, want the command to be stopped")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Exec() returned after %v, want the command stopped at the deadline", elapsed)
+	}
+	if !ctx.buildTimeExceeded.Load() {
+		t.Error("buildTimeExceeded = false, want true")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// WarningCategory classifies warnings which can fail the build with env.FailOnWarnings.
+type WarningCategory string
+
+const (
+	// WarningDeprecation is a warning about deprecated or unsupported versions and features.
+	WarningDeprecation WarningCategory = "deprecation"
+	// WarningSecurity is a warning about potential security issues, such as unverified downloads.
+	WarningSecurity WarningCategory = "security"
+	// WarningSize is a warning about files kept in the image which are not needed to run it.
+	WarningSize WarningCategory = "size"
+
+	// allWarningCategories is the env.FailOnWarnings value which selects every category.
+	allWarningCategories = "all"
+)
+
+var warningCategories = []WarningCategory{WarningDeprecation, WarningSecurity, WarningSize}
+
+// CategoryWarnf emits a structured logging line for a warning of the given category. Warnings of
+// the categories selected with env.FailOnWarnings count against the warnings budget of the
+// buildpack, which fails the build once exceeded.
+func (ctx *Context) CategoryWarnf(category WarningCategory, format string, args ...interface{}) {
+	if ctx.categoryWarnings == nil {
+		ctx.categoryWarnings = map[WarningCategory]int{}
+	}
+	ctx.categoryWarnings[category]++
+	ctx.Warnf(fmt.Sprintf("[%s] %s", category, format), args...)
+}
+
+// checkWarningsBudget returns an error if the buildpack emitted more warnings of the categories
+// selected with env.FailOnWarnings than allowed by env.WarningsBudget.
+func (ctx *Context) checkWarningsBudget() error {
+	categories, err := failOnWarningCategories()
+	if err != nil || len(categories) == 0 {
+		return err
+	}
+	budget, err := warningsBudget()
+	if err != nil {
+		return err
+	}
+	total := 0
+	var counts []string
+	for _, c := range categories {
+		if n := ctx.categoryWarnings[c]; n > 0 {
+			total += n
+			counts = append(counts, fmt.Sprintf("%d %s", n, c))
+		}
+	}
+	if total <= budget {
+		return nil
+	}
+	return UserErrorf("%s emitted %s warnings, which exceeds the budget of %d set with %s; fix the warnings above or unset %s",
+		ctx.BuildpackID(), strings.Join(counts, ", "), budget, env.WarningsBudget, env.FailOnWarnings)
+}
+
+// failOnWarningCategories returns the warning categories selected with env.FailOnWarnings.
+func failOnWarningCategories() ([]WarningCategory, error) {
+	v := strings.TrimSpace(os.Getenv(env.FailOnWarnings))
+	if v == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(v, allWarningCategories) {
+		return warningCategories, nil
+	}
+	var categories []WarningCategory
+	for _, c := range strings.Split(v, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if !isWarningCategory(WarningCategory(c)) {
+			return nil, UserErrorf("invalid %s category %q, must be one of %v or %q", env.FailOnWarnings, c, warningCategories, allWarningCategories)
+		}
+		categories = append(categories, WarningCategory(c))
+	}
+	return categories, nil
+}
+
+func isWarningCategory(category WarningCategory) bool {
+	for _, c := range warningCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// warningsBudget returns the number of warnings allowed by env.WarningsBudget.
+func warningsBudget() (int, error) {
+	v := strings.TrimSpace(os.Getenv(env.WarningsBudget))
+	if v == "" {
+		return 0, nil
+	}
+	budget, err := strconv.Atoi(v)
+	if err != nil || budget < 0 {
+		return 0, UserErrorf("%s=%q must be a non-negative integer", env.WarningsBudget, v)
+	}
+	return budget, nil
+}
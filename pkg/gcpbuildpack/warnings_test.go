@@ -0,0 +1,103 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestCheckWarningsBudget(t *testing.T) {
+	testCases := []struct {
+		name           string
+		failOnWarnings string
+		budget         string
+		warnings       []WarningCategory
+		wantErr        bool
+	}{
+		{
+			name:     "disabled",
+			warnings: []WarningCategory{WarningDeprecation, WarningSecurity},
+		},
+		{
+			name:           "no warnings",
+			failOnWarnings: "all",
+		},
+		{
+			name:           "warning in selected category",
+			failOnWarnings: "deprecation,security",
+			warnings:       []WarningCategory{WarningSecurity},
+			wantErr:        true,
+		},
+		{
+			name:           "warning in other category",
+			failOnWarnings: "deprecation",
+			warnings:       []WarningCategory{WarningSize},
+		},
+		{
+			name:           "all selects every category",
+			failOnWarnings: "ALL",
+			warnings:       []WarningCategory{WarningSize},
+			wantErr:        true,
+		},
+		{
+			name:           "within budget",
+			failOnWarnings: "size",
+			budget:         "2",
+			warnings:       []WarningCategory{WarningSize, WarningSize},
+		},
+		{
+			name:           "exceeds budget",
+			failOnWarnings: "size",
+			budget:         "2",
+			warnings:       []WarningCategory{WarningSize, WarningSize, WarningSize},
+			wantErr:        true,
+		},
+		{
+			name:           "invalid category",
+			failOnWarnings: "style",
+			wantErr:        true,
+		},
+		{
+			name:           "invalid budget",
+			failOnWarnings: "all",
+			budget:         "-1",
+			wantErr:        true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.FailOnWarnings, tc.failOnWarnings)
+			t.Setenv(env.WarningsBudget, tc.budget)
+			ctx := NewContext()
+			for _, c := range tc.warnings {
+				ctx.CategoryWarnf(c, "test warning")
+			}
+			err := ctx.checkWarningsBudget()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkWarningsBudget() got error: %v, want error? %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCategoryWarnfRecordsWarning(t *testing.T) {
+	ctx := NewContext()
+	ctx.CategoryWarnf(WarningDeprecation, "version %s is deprecated", "1.0")
+	if len(ctx.warnings) != 1 || ctx.warnings[0] != "[deprecation] version 1.0 is deprecated" {
+		t.Errorf("warnings = %q, want [%q]", ctx.warnings, "[deprecation] version 1.0 is deprecated")
+	}
+}
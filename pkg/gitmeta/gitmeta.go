@@ -0,0 +1,375 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gitmeta extracts the git metadata of the source being built, for use in image labels,
+// the build metadata and the build report.
+package gitmeta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// SourceGit indicates the metadata was read from the .git directory of the source.
+	SourceGit = "git"
+	// SourceEnv indicates the metadata was read from env vars set by the CI system.
+	SourceEnv = "env var"
+
+	// statusAttempts is the number of times git status is run when another git process holds the
+	// lock of the repository.
+	statusAttempts = 3
+)
+
+var (
+	// CommitEnvs are env vars set to the commit being built, in order of precedence.
+	CommitEnvs = []string{env.BuildCommit, "COMMIT_SHA", "SOURCE_COMMIT", "GIT_COMMIT", "GITHUB_SHA"}
+	// BranchEnvs are env vars set by CI systems to the branch being built, in order of precedence.
+	BranchEnvs = []string{"BRANCH_NAME", "GIT_BRANCH"}
+	// TagEnvs are env vars set by CI systems to the tag being built, in order of precedence.
+	TagEnvs = []string{"TAG_NAME", "GIT_TAG"}
+
+	shaRegexp = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+	// retryDelay is the delay before git status is run again, doubled after each attempt.
+	retryDelay = 100 * time.Millisecond
+	// lookPath is stubbed in tests.
+	lookPath = exec.LookPath
+)
+
+// Metadata is the git metadata of the source being built.
+type Metadata struct {
+	// Commit is the SHA of the commit being built.
+	Commit string `json:"commit,omitempty"`
+	// Branch is the name of the branch checked out, empty if HEAD is detached.
+	Branch string `json:"branch,omitempty"`
+	// Tag is the name of a tag pointing at Commit, the first in lexical order if there are several.
+	Tag string `json:"tag,omitempty"`
+	// Dirty is true if tracked files have uncommitted changes. It is only known when the source has
+	// a .git directory and git is installed.
+	Dirty bool `json:"dirty,omitempty"`
+	// Source is where Commit was read from, SourceGit or SourceEnv, or empty if it is unknown.
+	Source string `json:"source,omitempty"`
+}
+
+// Read returns the git metadata of the source in dir. The metadata is read from the .git directory
+// unless env.BuildCommit is set, and falls back to the env vars set by CI systems for sources
+// uploaded without it, such as tarballs. Fields the .git directory does not provide, such as the
+// branch of a detached HEAD, are also filled in from the env vars.
+func Read(dir string) (Metadata, error) {
+	fromEnv := FromEnv()
+	if os.Getenv(env.BuildCommit) != "" {
+		return fromEnv, nil
+	}
+	gitDir, err := findGitDir(dir)
+	if err != nil || gitDir == "" {
+		return fromEnv, err
+	}
+	m, err := fromGitDir(gitDir)
+	if err != nil {
+		return fromEnv, fmt.Errorf("reading %s: %w", gitDir, err)
+	}
+	if m.Commit == "" {
+		return fromEnv, nil
+	}
+	if m.Branch == "" {
+		m.Branch = fromEnv.Branch
+	}
+	if m.Tag == "" && fromEnv.Commit == m.Commit {
+		m.Tag = fromEnv.Tag
+	}
+	if m.Dirty, err = dirty(dir); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// FromEnv returns the git metadata set in the env vars of CI systems.
+func FromEnv() Metadata {
+	m := Metadata{
+		Commit: firstEnv(CommitEnvs),
+		Branch: firstEnv(BranchEnvs),
+		Tag:    firstEnv(TagEnvs),
+	}
+	if m.Commit != "" {
+		m.Source = SourceEnv
+	}
+	return m
+}
+
+// Labels returns the image labels of the metadata, without the `google.` prefix, omitting the
+// fields which are unknown.
+func (m Metadata) Labels() map[string]string {
+	labels := map[string]string{}
+	if m.Commit == "" {
+		return labels
+	}
+	labels["git-commit"] = m.Commit
+	if m.Branch != "" {
+		labels["git-branch"] = m.Branch
+	}
+	if m.Tag != "" {
+		labels["git-tag"] = m.Tag
+	}
+	if m.Source == SourceGit {
+		labels["git-dirty"] = fmt.Sprintf("%t", m.Dirty)
+	}
+	return labels
+}
+
+// findGitDir returns the git directory of the repository in dir, following the `gitdir:` file of
+// worktrees and submodules, or an empty string if there is none.
+func findGitDir(dir string) (string, error) {
+	path := filepath.Join(dir, ".git")
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return path, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	if !ok {
+		return "", nil
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return gitDir, nil
+}
+
+// repo is a git directory along with the common directory refs and objects are shared in, which
+// differs for worktrees.
+type repo struct {
+	gitDir    string
+	commonDir string
+}
+
+func fromGitDir(gitDir string) (Metadata, error) {
+	r := repo{gitDir: gitDir, commonDir: gitDir}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		r.commonDir = strings.TrimSpace(string(common))
+		if !filepath.IsAbs(r.commonDir) {
+			r.commonDir = filepath.Join(gitDir, r.commonDir)
+		}
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return Metadata{}, err
+	}
+	m := Metadata{Source: SourceGit}
+	ref, symbolic := strings.CutPrefix(strings.TrimSpace(string(head)), "ref:")
+	if symbolic {
+		ref = strings.TrimSpace(ref)
+		m.Branch = strings.TrimPrefix(ref, "refs/heads/")
+		if m.Commit, err = r.resolve(ref); err != nil {
+			return Metadata{}, err
+		}
+	} else if shaRegexp.MatchString(ref) {
+		m.Commit = ref
+	}
+	if m.Commit == "" {
+		// An unborn branch has no commit yet.
+		return Metadata{}, nil
+	}
+	if m.Tag, err = r.tag(m.Commit); err != nil {
+		return Metadata{}, err
+	}
+	return m, nil
+}
+
+// resolve returns the SHA the ref points to, or an empty string if the ref does not exist.
+func (r repo) resolve(ref string) (string, error) {
+	for _, dir := range []string{r.gitDir, r.commonDir} {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err == nil {
+			return strings.TrimSpace(string(content)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	refs, err := r.packedRefs()
+	if err != nil {
+		return "", err
+	}
+	return refs[ref].sha, nil
+}
+
+// packedRef is a ref in the packed-refs file along with the commit an annotated tag points to.
+type packedRef struct {
+	sha    string
+	peeled string
+}
+
+func (r repo) packedRefs() (map[string]packedRef, error) {
+	refs := map[string]packedRef{}
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var last string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^"):
+			if p, ok := refs[last]; ok {
+				p.peeled = strings.TrimPrefix(line, "^")
+				refs[last] = p
+			}
+		default:
+			sha, name, ok := strings.Cut(line, " ")
+			if ok {
+				refs[name] = packedRef{sha: sha}
+				last = name
+			}
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// tag returns the first tag in lexical order which points at commit, or an empty string.
+func (r repo) tag(commit string) (string, error) {
+	var tags []string
+	refs, err := r.packedRefs()
+	if err != nil {
+		return "", err
+	}
+	for name, p := range refs {
+		if tag, ok := strings.CutPrefix(name, "refs/tags/"); ok && (p.sha == commit || p.peeled == commit) {
+			tags = append(tags, tag)
+		}
+	}
+	tagsDir := filepath.Join(r.commonDir, "refs", "tags")
+	err = filepath.WalkDir(tagsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sha := strings.TrimSpace(string(content))
+		if sha != commit && r.peel(sha) != commit {
+			return nil
+		}
+		rel, err := filepath.Rel(tagsDir, path)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(tags) == 0 {
+		return "", nil
+	}
+	sort.Strings(tags)
+	return tags[0], nil
+}
+
+// peel returns the object the annotated tag object sha points to if it is a loose object, or an
+// empty string. Objects in pack files are not read.
+func (r repo) peel(sha string) string {
+	if !shaRegexp.MatchString(sha) {
+		return ""
+	}
+	f, err := os.Open(filepath.Join(r.commonDir, "objects", sha[:2], sha[2:]))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return ""
+	}
+	defer zr.Close()
+	header, err := io.ReadAll(io.LimitReader(zr, 512))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return ""
+	}
+	kind, body, ok := bytes.Cut(header, []byte{0})
+	if !ok || !bytes.HasPrefix(kind, []byte("tag ")) {
+		return ""
+	}
+	object, ok := bytes.CutPrefix(body, []byte("object "))
+	if !ok || len(object) < 40 {
+		return ""
+	}
+	return string(bytes.Fields(object)[0])
+}
+
+// dirty returns true if tracked files in dir have uncommitted changes, or false if git is not
+// installed. git status does not take the lock of the repository, but is retried if another git
+// process holds it while refreshing the index.
+func dirty(dir string) (bool, error) {
+	git, err := lookPath("git")
+	if err != nil {
+		return false, nil
+	}
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		cmd := exec.Command(git, "-c", "safe.directory=*", "status", "--porcelain", "--untracked-files=no")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		if err == nil {
+			return stdout.Len() > 0, nil
+		}
+		if attempt == statusAttempts || !strings.Contains(stderr.String(), ".lock") {
+			return false, fmt.Errorf("running git status: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func firstEnv(names []string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,254 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitmeta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	commitA = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	commitB = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	tagObj  = "0123456789abcdef0123456789abcdef01234567"
+)
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		envs  map[string]string
+		want  Metadata
+	}{
+		{
+			name: "no git directory",
+			want: Metadata{},
+		},
+		{
+			name: "env fallback",
+			envs: map[string]string{"COMMIT_SHA": "aaaa", "BRANCH_NAME": "main", "TAG_NAME": "v1"},
+			want: Metadata{Commit: "aaaa", Branch: "main", Tag: "v1", Source: SourceEnv},
+		},
+		{
+			name: "loose branch ref",
+			files: map[string]string{
+				".git/HEAD":            "ref: refs/heads/main\n",
+				".git/refs/heads/main": commitA + "\n",
+			},
+			want: Metadata{Commit: commitA, Branch: "main", Source: SourceGit},
+		},
+		{
+			name: "packed branch ref and tags",
+			files: map[string]string{
+				".git/HEAD": "ref: refs/heads/feature/x\n",
+				".git/packed-refs": "# pack-refs with: peeled fully-peeled sorted\n" +
+					commitA + " refs/heads/feature/x\n" +
+					commitB + " refs/tags/v0\n" +
+					tagObj + " refs/tags/v2\n^" + commitA + "\n" +
+					commitA + " refs/tags/v1\n",
+			},
+			want: Metadata{Commit: commitA, Branch: "feature/x", Tag: "v1", Source: SourceGit},
+		},
+		{
+			name: "detached head fills branch from env",
+			files: map[string]string{
+				".git/HEAD":          commitA + "\n",
+				".git/refs/tags/v3":  commitA + "\n",
+				".git/refs/tags/old": commitB + "\n",
+			},
+			envs: map[string]string{"BRANCH_NAME": "release", "COMMIT_SHA": commitB, "TAG_NAME": "other"},
+			want: Metadata{Commit: commitA, Branch: "release", Tag: "v3", Source: SourceGit},
+		},
+		{
+			name: "worktree",
+			files: map[string]string{
+				".git":                             "gitdir: repo/.git/worktrees/wt\n",
+				"repo/.git/worktrees/wt/HEAD":      "ref: refs/heads/wt\n",
+				"repo/.git/worktrees/wt/commondir": "../..\n",
+				"repo/.git/refs/heads/wt":          commitB + "\n",
+			},
+			want: Metadata{Commit: commitB, Branch: "wt", Source: SourceGit},
+		},
+		{
+			name: "unborn branch falls back to env",
+			files: map[string]string{
+				".git/HEAD": "ref: refs/heads/main\n",
+			},
+			envs: map[string]string{"GITHUB_SHA": "bbbb"},
+			want: Metadata{Commit: "bbbb", Source: SourceEnv},
+		},
+		{
+			name: "explicit commit takes precedence",
+			files: map[string]string{
+				".git/HEAD":            "ref: refs/heads/main\n",
+				".git/refs/heads/main": commitA + "\n",
+			},
+			envs: map[string]string{env.BuildCommit: "cccc", "COMMIT_SHA": "dddd"},
+			want: Metadata{Commit: "cccc", Source: SourceEnv},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnvs(t)
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			noGit(t)
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := Read(dir)
+			if err != nil {
+				t.Fatalf("Read() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadAnnotatedLooseTag(t *testing.T) {
+	clearEnvs(t)
+	noGit(t)
+	dir := t.TempDir()
+	var obj bytes.Buffer
+	zw := zlib.NewWriter(&obj)
+	body := "object " + commitA + "\ntype commit\ntag v4\ntagger Jane <jane@example.com> 0 +0000\n\nrelease\n"
+	if _, err := fmt.Fprintf(zw, "tag %d\x00%s", len(body), body); err != nil {
+		t.Fatalf("compressing tag object: %v", err)
+	}
+	zw.Close()
+	writeFiles(t, dir, map[string]string{
+		".git/HEAD":         commitA + "\n",
+		".git/refs/tags/v4": tagObj + "\n",
+		".git/objects/" + tagObj[:2] + "/" + tagObj[2:]: obj.String(),
+	})
+
+	got, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read() got error: %v", err)
+	}
+	if got.Tag != "v4" {
+		t.Errorf("Read() tag = %q, want %q", got.Tag, "v4")
+	}
+}
+
+func TestReadDirty(t *testing.T) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	clearEnvs(t)
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command(git, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q", "-b", "main")
+	writeFiles(t, dir, map[string]string{"a.txt": "a"})
+	run("add", "a.txt")
+	run("commit", "-q", "-m", "init")
+	run("tag", "-a", "v1", "-m", "v1")
+
+	got, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read() got error: %v", err)
+	}
+	if got.Dirty || got.Branch != "main" || got.Tag != "v1" || got.Source != SourceGit {
+		t.Errorf("Read() = %+v, want clean main at v1 read from git", got)
+	}
+
+	writeFiles(t, dir, map[string]string{"a.txt": "b"})
+	got, err = Read(dir)
+	if err != nil {
+		t.Fatalf("Read() got error: %v", err)
+	}
+	if !got.Dirty {
+		t.Errorf("Read() dirty = false after modifying a tracked file, want true")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	testCases := []struct {
+		name string
+		m    Metadata
+		want map[string]string
+	}{
+		{
+			name: "unknown",
+			want: map[string]string{},
+		},
+		{
+			name: "from env",
+			m:    Metadata{Commit: "aaaa", Tag: "v1", Source: SourceEnv},
+			want: map[string]string{"git-commit": "aaaa", "git-tag": "v1"},
+		},
+		{
+			name: "from git",
+			m:    Metadata{Commit: "aaaa", Branch: "main", Dirty: true, Source: SourceGit},
+			want: map[string]string{"git-commit": "aaaa", "git-branch": "main", "git-dirty": "true"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.m.Labels()); diff != "" {
+				t.Errorf("Labels() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func clearEnvs(t *testing.T) {
+	t.Helper()
+	for _, names := range [][]string{CommitEnvs, BranchEnvs, TagEnvs} {
+		for _, n := range names {
+			t.Setenv(n, "")
+		}
+	}
+}
+
+func noGit(t *testing.T) {
+	t.Helper()
+	orig := lookPath
+	lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	t.Cleanup(func() { lookPath = orig })
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// zigTool is the name of zig, used as a C toolchain when no C compiler is available, in the
+	// manifest of pinned tools.
+	zigTool = "zig"
+	// zigVersionKey is the metadata key used to store the zig version in the C toolchain layer.
+	zigVersionKey = "zig_version"
+	// goBuildCacheKey is the metadata key used to store the hash the GOCACHE layer is keyed on.
+	goBuildCacheKey = "go-build-cache-sha"
+)
+
+var (
+	// cgoModules are modules which can only be built with cgo enabled.
+	cgoModules = []string{
+		"github.com/mattn/go-sqlite3",
+		"github.com/confluentinc/confluent-kafka-go",
+		"gopkg.in/confluentinc/confluent-kafka-go.v1",
+	}
+
+	// lookPath can be overridden for testing.
+	lookPath = exec.LookPath
+)
+
+// BuildMode describes how cgo and linking are configured for `go build`.
+type BuildMode struct {
+	// CGOEnabled is the value of CGO_ENABLED, or nil to keep the Go default.
+	CGOEnabled *bool
+	// Static builds a fully statically linked binary.
+	Static bool
+}
+
+// Env returns the environment variables for `go build` in this mode.
+func (m BuildMode) Env() []string {
+	if m.CGOEnabled == nil {
+		return nil
+	}
+	if *m.CGOEnabled {
+		return []string{"CGO_ENABLED=1"}
+	}
+	return []string{"CGO_ENABLED=0"}
+}
+
+// NeedsCCompiler returns true if cgo is explicitly enabled.
+func (m BuildMode) NeedsCCompiler() bool {
+	return m.CGOEnabled != nil && *m.CGOEnabled
+}
+
+// ResolveBuildMode determines the cgo and linking configuration from env.GoCGOEnabled,
+// env.GoStatic and the modules required by go.mod. cgo is enabled automatically for modules which
+// require it, such as go-sqlite3 and confluent-kafka-go.
+func ResolveBuildMode(ctx *gcp.Context) (BuildMode, error) {
+	static, err := env.IsPresentAndTrue(env.GoStatic)
+	if err != nil {
+		return BuildMode{}, gcp.UserErrorf("%v", err)
+	}
+	mode := BuildMode{Static: static}
+	if _, ok := os.LookupEnv(env.GoCGOEnabled); ok {
+		enabled, err := env.IsPresentAndTrue(env.GoCGOEnabled)
+		if err != nil {
+			return BuildMode{}, gcp.UserErrorf("%v", err)
+		}
+		mode.CGOEnabled = &enabled
+		return mode, nil
+	}
+
+	modules, err := CGOModules(ctx)
+	if err != nil {
+		return BuildMode{}, err
+	}
+	if len(modules) > 0 {
+		ctx.Logf("Enabling cgo because go.mod requires %v", modules)
+		enabled := true
+		mode.CGOEnabled = &enabled
+		return mode, nil
+	}
+	if static {
+		enabled := false
+		mode.CGOEnabled = &enabled
+	}
+	return mode, nil
+}
+
+// CGOModules returns the modules required by go.mod which can only be built with cgo.
+func CGOModules(ctx *gcp.Context) ([]string, error) {
+	goMod, err := readGoMod(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading go.mod: %w", err)
+	}
+	var modules []string
+	for _, m := range cgoModules {
+		re := regexp.MustCompile(`(?m)^\s*(require\s+)?` + regexp.QuoteMeta(m) + `(/v\d+)?\s+v`)
+		if re.MatchString(goMod) {
+			modules = append(modules, m)
+		}
+	}
+	return modules, nil
+}
+
+// InstallCCToolchain makes a C compiler available for cgo builds. If gcc is already on the PATH
+// nothing is installed and no environment is returned. Otherwise zig is installed in the given
+// layer and the returned environment configures it as the C and C++ compiler.
+func InstallCCToolchain(ctx *gcp.Context, l *libcnb.Layer) ([]string, error) {
+	if _, err := lookPath("gcc"); err == nil {
+		return nil, nil
+	}
+	tool, err := fetch.PinnedTool(zigTool)
+	if err != nil {
+		return nil, err
+	}
+	zig := filepath.Join(l.Path, "zig")
+	if ctx.GetMetadata(l, zigVersionKey) == tool.Version {
+		ctx.CacheHit(l.Name)
+	} else {
+		ctx.CacheMiss(l.Name)
+		if err := ctx.ClearLayer(l); err != nil {
+			return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+		ctx.Logf("No C compiler found, installing zig %s as the C toolchain", tool.Version)
+		if err := downloadZig(ctx, tool, l.Path); err != nil {
+			return nil, gcp.InternalErrorf("installing zig: %w", err)
+		}
+	}
+	ctx.SetMetadata(l, zigVersionKey, tool.Version)
+	return []string{"CC=" + zig + " cc", "CXX=" + zig + " c++"}, nil
+}
+
+// downloadZig downloads zig into dir and verifies its digest. The release is only published as a
+// tar.xz archive so it is extracted with the system tar.
+var downloadZig = func(ctx *gcp.Context, tool fetch.Tool, dir string) error {
+	archive := filepath.Join(dir, "zig.tar.xz")
+	if err := fetch.VerifiedFile(tool, archive); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"tar", "-xJf", archive, "-C", dir, "--strip-components=1"}); err != nil {
+		return err
+	}
+	return os.Remove(archive)
+}
+
+// CheckBuildCache clears the GOCACHE layer if the Go version, go.mod or go.sum changed since it was
+// populated. The Go build cache is content-addressed so stale entries are never used, but without
+// a key it would grow without bound across dependency upgrades.
+func CheckBuildCache(ctx *gcp.Context, l *libcnb.Layer) error {
+	goVersion, err := GoVersion(ctx)
+	if err != nil {
+		return err
+	}
+	opts := []cache.Option{cache.WithStrings(goVersion)}
+	for _, f := range []string{"go.mod", "go.sum"} {
+		path := filepath.Join(ctx.ApplicationRoot(), f)
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			opts = append(opts, cache.WithFiles(path))
+		}
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, goBuildCacheKey, opts...)
+	if err != nil {
+		return err
+	}
+	if cached {
+		return nil
+	}
+	ctx.Debugf("Go version or module hash has changed: clearing GOCACHE layer")
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	cache.Add(ctx, l, goBuildCacheKey, hash)
+	return nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestResolveBuildMode(t *testing.T) {
+	sqliteGoMod := `module example.com/app
+
+go 1.22
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/sync v0.7.0
+)
+`
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		goMod   string
+		wantCGO string
+		want    BuildMode
+	}{
+		{
+			name:    "go default",
+			goMod:   "module example.com/app\n\ngo 1.22\n",
+			wantCGO: "",
+		},
+		{
+			name:    "cgo required by go-sqlite3",
+			goMod:   sqliteGoMod,
+			wantCGO: "CGO_ENABLED=1",
+		},
+		{
+			name:    "cgo required by confluent-kafka-go v2",
+			goMod:   "module example.com/app\n\nrequire github.com/confluentinc/confluent-kafka-go/v2 v2.4.0\n",
+			wantCGO: "CGO_ENABLED=1",
+		},
+		{
+			name:    "explicitly disabled",
+			env:     map[string]string{"GOOGLE_GO_CGO_ENABLED": "false"},
+			goMod:   sqliteGoMod,
+			wantCGO: "CGO_ENABLED=0",
+		},
+		{
+			name:    "static",
+			env:     map[string]string{"GOOGLE_GO_STATIC": "true"},
+			goMod:   "module example.com/app\n",
+			wantCGO: "CGO_ENABLED=0",
+			want:    BuildMode{Static: true},
+		},
+		{
+			name:    "static with cgo required",
+			env:     map[string]string{"GOOGLE_GO_STATIC": "true"},
+			goMod:   sqliteGoMod,
+			wantCGO: "CGO_ENABLED=1",
+			want:    BuildMode{Static: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			mockReadGoMod(t, tc.goMod)
+
+			got, err := ResolveBuildMode(gcp.NewContext())
+			if err != nil {
+				t.Fatalf("ResolveBuildMode() got error: %v", err)
+			}
+			if got.Static != tc.want.Static {
+				t.Errorf("ResolveBuildMode().Static = %t, want %t", got.Static, tc.want.Static)
+			}
+			gotCGO := ""
+			if e := got.Env(); len(e) > 0 {
+				gotCGO = e[0]
+			}
+			if gotCGO != tc.wantCGO {
+				t.Errorf("ResolveBuildMode().Env() = %q, want %q", gotCGO, tc.wantCGO)
+			}
+		})
+	}
+}
+
+func TestInstallCCToolchain(t *testing.T) {
+	zig, err := fetch.PinnedTool(zigTool)
+	if err != nil {
+		t.Fatalf("PinnedTool(%q) got error: %v", zigTool, err)
+	}
+	testCases := []struct {
+		name         string
+		hasGCC       bool
+		metadata     map[string]interface{}
+		wantDownload bool
+		wantEnv      bool
+	}{
+		{
+			name:   "gcc available",
+			hasGCC: true,
+		},
+		{
+			name:         "zig not cached",
+			wantDownload: true,
+			wantEnv:      true,
+		},
+		{
+			name:     "zig cached",
+			metadata: map[string]interface{}{zigVersionKey: zig.Version},
+			wantEnv:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			origLookPath, origDownloadZig := lookPath, downloadZig
+			t.Cleanup(func() {
+				lookPath, downloadZig = origLookPath, origDownloadZig
+			})
+			lookPath = func(file string) (string, error) {
+				if tc.hasGCC {
+					return "/usr/bin/" + file, nil
+				}
+				return "", fmt.Errorf("%s not found", file)
+			}
+			downloaded := false
+			downloadZig = func(*gcp.Context, fetch.Tool, string) error {
+				downloaded = true
+				return nil
+			}
+			metadata := tc.metadata
+			if metadata == nil {
+				metadata = map[string]interface{}{}
+			}
+			l := &libcnb.Layer{Name: "cc", Path: t.TempDir(), Metadata: metadata}
+
+			gotEnv, err := InstallCCToolchain(gcp.NewContext(), l)
+			if err != nil {
+				t.Fatalf("InstallCCToolchain() got error: %v", err)
+			}
+			if downloaded != tc.wantDownload {
+				t.Errorf("InstallCCToolchain() downloaded zig = %t, want %t", downloaded, tc.wantDownload)
+			}
+			var wantEnv []string
+			if tc.wantEnv {
+				zig := filepath.Join(l.Path, "zig")
+				wantEnv = []string{"CC=" + zig + " cc", "CXX=" + zig + " c++"}
+			}
+			if !reflect.DeepEqual(gotEnv, wantEnv) {
+				t.Errorf("InstallCCToolchain() = %v, want %v", gotEnv, wantEnv)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package imagediff compares the layers of two images built by the buildpacks, to explain why an
+// image grew or shrank between builds without external tooling.
+package imagediff
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// lifecycleMetadataLabel is the label the lifecycle writes the layers of the image to.
+const lifecycleMetadataLabel = "io.buildpacks.lifecycle.metadata"
+
+// Layer is the content of an image layer.
+type Layer struct {
+	// DiffID is the digest of the uncompressed layer.
+	DiffID string `json:"diffId"`
+	// Name identifies the layer across builds, such as `google.nodejs.npm:npm_modules` for a
+	// buildpack layer. Layers of the run image are named by their position.
+	Name string `json:"name"`
+	// Size is the total size of the regular files in the layer.
+	Size int64 `json:"size"`
+	// Files maps the paths of the regular files in the layer to their sizes.
+	Files map[string]int64 `json:"-"`
+}
+
+// FileChange is a file which was added, removed or changed size in a layer.
+type FileChange struct {
+	Path string `json:"path"`
+	// OldSize is the size in the old layer, or -1 if the file was added.
+	OldSize int64 `json:"oldSize"`
+	// NewSize is the size in the new layer, or -1 if the file was removed.
+	NewSize int64 `json:"newSize"`
+}
+
+// LayerChange is a layer which was added, removed or changed between the images.
+type LayerChange struct {
+	Name      string       `json:"name"`
+	OldDiffID string       `json:"oldDiffId,omitempty"`
+	NewDiffID string       `json:"newDiffId,omitempty"`
+	OldSize   int64        `json:"oldSize"`
+	NewSize   int64        `json:"newSize"`
+	Files     []FileChange `json:"files,omitempty"`
+}
+
+// Report is the difference between two images.
+type Report struct {
+	// OldSize and NewSize are the uncompressed sizes of the files in the images.
+	OldSize int64 `json:"oldSize"`
+	NewSize int64 `json:"newSize"`
+	// Layers are the changed layers, largest change first.
+	Layers []LayerChange `json:"layers"`
+	// Unchanged is the number of layers which are identical in both images.
+	Unchanged int `json:"unchanged"`
+}
+
+// lifecycleMetadata is the part of the lifecycle metadata label which identifies the layers.
+type lifecycleMetadata struct {
+	App          []layerRef `json:"app"`
+	Config       layerRef   `json:"config"`
+	Launcher     layerRef   `json:"launcher"`
+	ProcessTypes layerRef   `json:"process-types"`
+	Buildpacks   []struct {
+		Key    string              `json:"key"`
+		Layers map[string]layerRef `json:"layers"`
+	} `json:"buildpacks"`
+}
+
+type layerRef struct {
+	SHA string `json:"sha"`
+}
+
+// ReadLayers reads the files of the layers of img, named from the lifecycle metadata label.
+func ReadLayers(img v1.Image) ([]Layer, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("reading image config: %w", err)
+	}
+	names, err := layerNames(cfg.Config.Labels[lifecycleMetadataLabel])
+	if err != nil {
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("reading image layers: %w", err)
+	}
+	var out []Layer
+	base := 0
+	for _, l := range layers {
+		diffID, err := l.DiffID()
+		if err != nil {
+			return nil, fmt.Errorf("reading layer diff ID: %w", err)
+		}
+		name, ok := names[diffID.String()]
+		if !ok {
+			base++
+			name = fmt.Sprintf("run image #%d", base)
+		}
+		files, size, err := readFiles(l)
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %s: %w", name, err)
+		}
+		out = append(out, Layer{DiffID: diffID.String(), Name: name, Size: size, Files: files})
+	}
+	return out, nil
+}
+
+// layerNames returns the names of the layers listed in the lifecycle metadata label by diff ID.
+func layerNames(label string) (map[string]string, error) {
+	names := make(map[string]string)
+	if label == "" {
+		return names, nil
+	}
+	var md lifecycleMetadata
+	if err := json.Unmarshal([]byte(label), &md); err != nil {
+		return nil, fmt.Errorf("parsing %s label: %w", lifecycleMetadataLabel, err)
+	}
+	add := func(ref layerRef, name string) {
+		if ref.SHA != "" {
+			names[ref.SHA] = name
+		}
+	}
+	for i, ref := range md.App {
+		name := "app"
+		if len(md.App) > 1 {
+			name = fmt.Sprintf("app #%d", i+1)
+		}
+		add(ref, name)
+	}
+	add(md.Config, "config")
+	add(md.Launcher, "launcher")
+	add(md.ProcessTypes, "process-types")
+	for _, bp := range md.Buildpacks {
+		for name, ref := range bp.Layers {
+			add(ref, bp.Key+":"+name)
+		}
+	}
+	return names, nil
+}
+
+// readFiles returns the sizes of the regular files in the layer and their total.
+func readFiles(l v1.Layer) (map[string]int64, int64, error) {
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+	files := make(map[string]int64)
+	var total int64
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, total, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		files[path.Clean("/"+hdr.Name)] = hdr.Size
+		total += hdr.Size
+	}
+}
+
+// Compare returns the layers which changed between the old and new images. Layers are matched by
+// name, so that a rebuilt layer is compared file by file with the layer it replaces.
+func Compare(oldLayers, newLayers []Layer) Report {
+	var r Report
+	old := make(map[string]Layer)
+	for _, l := range oldLayers {
+		old[l.Name] = l
+		r.OldSize += l.Size
+	}
+	for _, l := range newLayers {
+		r.NewSize += l.Size
+		prev, ok := old[l.Name]
+		delete(old, l.Name)
+		if ok && prev.DiffID == l.DiffID {
+			r.Unchanged++
+			continue
+		}
+		c := LayerChange{Name: l.Name, NewDiffID: l.DiffID, NewSize: l.Size}
+		if ok {
+			c.OldDiffID, c.OldSize = prev.DiffID, prev.Size
+		}
+		c.Files = compareFiles(prev.Files, l.Files)
+		r.Layers = append(r.Layers, c)
+	}
+	for _, l := range old {
+		r.Layers = append(r.Layers, LayerChange{Name: l.Name, OldDiffID: l.DiffID, OldSize: l.Size, Files: compareFiles(l.Files, nil)})
+	}
+	sort.Slice(r.Layers, func(i, j int) bool {
+		di, dj := abs(r.Layers[i].NewSize-r.Layers[i].OldSize), abs(r.Layers[j].NewSize-r.Layers[j].OldSize)
+		if di != dj {
+			return di > dj
+		}
+		return r.Layers[i].Name < r.Layers[j].Name
+	})
+	return r
+}
+
+// compareFiles returns the files which were added, removed or changed size, largest change first.
+func compareFiles(oldFiles, newFiles map[string]int64) []FileChange {
+	var changes []FileChange
+	for p, size := range newFiles {
+		prev, ok := oldFiles[p]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: p, OldSize: -1, NewSize: size})
+		case prev != size:
+			changes = append(changes, FileChange{Path: p, OldSize: prev, NewSize: size})
+		}
+	}
+	for p, size := range oldFiles {
+		if _, ok := newFiles[p]; !ok {
+			changes = append(changes, FileChange{Path: p, OldSize: size, NewSize: -1})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		di, dj := abs(changes[i].delta()), abs(changes[j].delta())
+		if di != dj {
+			return di > dj
+		}
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func (c FileChange) delta() int64 {
+	return max(c.NewSize, 0) - max(c.OldSize, 0)
+}
+
+// Format returns the report as a table of the changed layers, each followed by at most top of its
+// changed files.
+func (r Report) Format(top int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Image size: %s -> %s (%s), uncompressed\n\n", formatBytes(r.OldSize), formatBytes(r.NewSize), formatDelta(r.NewSize-r.OldSize))
+	if len(r.Layers) == 0 {
+		fmt.Fprintf(&sb, "All %d layers are unchanged\n", r.Unchanged)
+		return sb.String()
+	}
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAYER\tOLD\tNEW\tCHANGE")
+	for _, l := range r.Layers {
+		oldSize, newSize := "-", "-"
+		if l.OldDiffID != "" {
+			oldSize = formatBytes(l.OldSize)
+		}
+		if l.NewDiffID != "" {
+			newSize = formatBytes(l.NewSize)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", l.Name, oldSize, newSize, formatDelta(l.NewSize-l.OldSize))
+		for i, f := range l.Files {
+			if i == top {
+				fmt.Fprintf(tw, "  ... %d more files\t\t\t\n", len(l.Files)-top)
+				break
+			}
+			op := "~"
+			switch {
+			case f.OldSize < 0:
+				op = "+"
+			case f.NewSize < 0:
+				op = "-"
+			}
+			fmt.Fprintf(tw, "  %s %s\t\t\t%s\n", op, f.Path, formatDelta(f.delta()))
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(&sb, "\n%d layers unchanged\n", r.Unchanged)
+	return sb.String()
+}
+
+func formatDelta(d int64) string {
+	if d < 0 {
+		return "-" + formatBytes(-d)
+	}
+	return "+" + formatBytes(d)
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGT"[exp])
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagediff
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+func TestReadLayersAndCompare(t *testing.T) {
+	base := testLayer(t, map[string]int{"etc/os-release": 10})
+	runtime := testLayer(t, map[string]int{"bin/node": 1000})
+	oldModules := testLayer(t, map[string]int{"node_modules/a.js": 100, "node_modules/b.js": 50})
+	newModules := testLayer(t, map[string]int{"node_modules/a.js": 120, "node_modules/big.bin": 4000})
+	oldApp := testLayer(t, map[string]int{"workspace/index.js": 5})
+	newApp := testLayer(t, map[string]int{"workspace/index.js": 5})
+
+	oldImg := testImage(t, []v1.Layer{base, runtime, oldModules, oldApp}, runtime, oldModules, oldApp)
+	newImg := testImage(t, []v1.Layer{base, runtime, newModules, newApp}, runtime, newModules, newApp)
+
+	oldLayers, err := ReadLayers(oldImg)
+	if err != nil {
+		t.Fatalf("ReadLayers(old) got error: %v", err)
+	}
+	newLayers, err := ReadLayers(newImg)
+	if err != nil {
+		t.Fatalf("ReadLayers(new) got error: %v", err)
+	}
+	var names []string
+	for _, l := range newLayers {
+		names = append(names, l.Name)
+	}
+	if diff := cmp.Diff([]string{"run image #1", "google.nodejs.runtime:node", "google.nodejs.npm:npm_modules", "app"}, names); diff != "" {
+		t.Errorf("ReadLayers() names mismatch (-want +got):\n%s", diff)
+	}
+
+	got := Compare(oldLayers, newLayers)
+	if got.OldSize != 1165 || got.NewSize != 5135 || got.Unchanged != 3 {
+		t.Errorf("Compare() = sizes %d -> %d, %d unchanged, want 1165 -> 5135, 3 unchanged", got.OldSize, got.NewSize, got.Unchanged)
+	}
+	want := []LayerChange{{
+		Name:      "google.nodejs.npm:npm_modules",
+		OldDiffID: diffID(t, oldModules),
+		NewDiffID: diffID(t, newModules),
+		OldSize:   150,
+		NewSize:   4120,
+		Files: []FileChange{
+			{Path: "/node_modules/big.bin", OldSize: -1, NewSize: 4000},
+			{Path: "/node_modules/b.js", OldSize: 50, NewSize: -1},
+			{Path: "/node_modules/a.js", OldSize: 100, NewSize: 120},
+		},
+	}}
+	if diff := cmp.Diff(want, got.Layers); diff != "" {
+		t.Errorf("Compare() layers mismatch (-want +got):\n%s", diff)
+	}
+
+	out := got.Format(2)
+	for _, s := range []string{
+		"Image size: 1.1 KiB -> 5.0 KiB (+3.9 KiB), uncompressed",
+		"google.nodejs.npm:npm_modules  150 B",
+		"+ /node_modules/big.bin",
+		"- /node_modules/b.js",
+		"... 1 more files",
+		"3 layers unchanged",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Format() does not contain %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestCompareAddedAndRemovedLayers(t *testing.T) {
+	oldLayers := []Layer{{DiffID: "sha256:a", Name: "google.php.composer:vendor", Size: 10, Files: map[string]int64{"/vendor/a.php": 10}}}
+	newLayers := []Layer{{DiffID: "sha256:b", Name: "google.php.composer:cache", Size: 30, Files: map[string]int64{"/cache/b": 30}}}
+	want := Report{
+		OldSize: 10,
+		NewSize: 30,
+		Layers: []LayerChange{
+			{Name: "google.php.composer:cache", NewDiffID: "sha256:b", NewSize: 30, Files: []FileChange{{Path: "/cache/b", OldSize: -1, NewSize: 30}}},
+			{Name: "google.php.composer:vendor", OldDiffID: "sha256:a", OldSize: 10, Files: []FileChange{{Path: "/vendor/a.php", OldSize: 10, NewSize: -1}}},
+		},
+	}
+	if diff := cmp.Diff(want, Compare(oldLayers, newLayers)); diff != "" {
+		t.Errorf("Compare() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// testImage returns an image of the layers with a lifecycle metadata label naming the runtime,
+// modules and app layers.
+func testImage(t *testing.T, layers []v1.Layer, runtime, modules, app v1.Layer) v1.Image {
+	t.Helper()
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.Config.Labels = map[string]string{lifecycleMetadataLabel: fmt.Sprintf(`{
+		"app": [{"sha": %q}],
+		"buildpacks": [
+			{"key": "google.nodejs.runtime", "layers": {"node": {"sha": %q}}},
+			{"key": "google.nodejs.npm", "layers": {"npm_modules": {"sha": %q}}}
+		]
+	}`, diffID(t, app), diffID(t, runtime), diffID(t, modules))}
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// testLayer returns a layer with files of the given sizes.
+func testLayer(t *testing.T, files map[string]int) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		size := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(size)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(bytes.Repeat([]byte("x"), size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content := buf.Bytes()
+	l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func diffID(t *testing.T, l v1.Layer) string {
+	t.Helper()
+	h, err := l.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.String()
+}
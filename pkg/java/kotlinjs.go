@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// kotlinJSMultiplatformTask is the Gradle task which compiles the production Node.js executable
+	// of the js target of a Kotlin Multiplatform project.
+	kotlinJSMultiplatformTask = "jsProductionExecutableCompileSync"
+	// kotlinJSTask is the Gradle task which compiles the production Node.js executable of a project
+	// using the Kotlin/JS plugin.
+	kotlinJSTask = "productionExecutableCompileSync"
+
+	// kotlinJSPackagesDir is where the Kotlin Gradle plugin generates an npm package for each
+	// JS module, alongside the node_modules it installs.
+	kotlinJSPackagesDir = "build/js/packages"
+)
+
+var (
+	kotlinMultiplatformPluginRegexp = regexp.MustCompile(`kotlin\(\s*"multiplatform"\s*\)|org\.jetbrains\.kotlin\.multiplatform"|libs\.plugins\.kotlin\.multiplatform\)|libs\.plugins\.kotlinMultiplatform\)`)
+	kotlinJSPluginRegexp            = regexp.MustCompile(`kotlin\(\s*"js"\s*\)|org\.jetbrains\.kotlin\.js"|libs\.plugins\.kotlin\.js\)|libs\.plugins\.kotlinJs\)`)
+	kotlinNodeJSRegexp              = regexp.MustCompile(`\bnodejs\s*(\(\s*\)|\{)`)
+	kotlinExecutableRegexp          = regexp.MustCompile(`\bbinaries\.executable\(\s*\)`)
+)
+
+// KotlinJSTask returns the Gradle task which builds the Node.js executable of a Kotlin/JS or
+// Kotlin Multiplatform project, or an empty string if the project does not build one. Such
+// projects are run with Node.js rather than on the JVM.
+func KotlinJSTask(ctx *gcp.Context) (string, error) {
+	for _, f := range []string{"build.gradle.kts", "build.gradle"} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), f))
+		if err != nil {
+			return "", err
+		}
+		if !kotlinNodeJSRegexp.Match(content) || !kotlinExecutableRegexp.Match(content) {
+			return "", nil
+		}
+		switch {
+		case kotlinMultiplatformPluginRegexp.Match(content):
+			return kotlinJSMultiplatformTask, nil
+		case kotlinJSPluginRegexp.Match(content):
+			return kotlinJSTask, nil
+		}
+		return "", nil
+	}
+	return "", nil
+}
+
+// KotlinJSMain returns the path of the Node.js entrypoint built by the Kotlin Gradle plugin,
+// relative to the application root. It is the main script of the single non-test package in
+// build/js/packages.
+func KotlinJSMain(ctx *gcp.Context) (string, error) {
+	pkgs, err := ctx.Glob(filepath.Join(ctx.ApplicationRoot(), kotlinJSPackagesDir, "*", "package.json"))
+	if err != nil {
+		return "", err
+	}
+	var mains []string
+	for _, p := range pkgs {
+		dir := filepath.Dir(p)
+		if strings.HasSuffix(filepath.Base(dir), "-test") {
+			continue
+		}
+		raw, err := ctx.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		var pjs struct {
+			Main string `json:"main"`
+		}
+		if err := json.Unmarshal(raw, &pjs); err != nil {
+			return "", gcp.InternalErrorf("unmarshalling %s: %w", p, err)
+		}
+		if pjs.Main == "" {
+			continue
+		}
+		rel, err := filepath.Rel(ctx.ApplicationRoot(), filepath.Join(dir, pjs.Main))
+		if err != nil {
+			return "", gcp.InternalErrorf("resolving %s: %w", pjs.Main, err)
+		}
+		mains = append(mains, rel)
+	}
+	if len(mains) != 1 {
+		return "", gcp.UserErrorf("expected exactly one Kotlin/JS executable in %s, found %v, set GOOGLE_ENTRYPOINT to select the script to run", kotlinJSPackagesDir, mains)
+	}
+	return mains[0], nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestKotlinJSTask(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "multiplatform node executable",
+			files: map[string]string{
+				"build.gradle.kts": `plugins {
+    kotlin("multiplatform") version "2.0.0"
+}
+
+kotlin {
+    jvm()
+    js {
+        nodejs()
+        binaries.executable()
+    }
+}`,
+			},
+			want: "jsProductionExecutableCompileSync",
+		},
+		{
+			name: "multiplatform from version catalog",
+			files: map[string]string{
+				"build.gradle.kts": `plugins {
+    alias(libs.plugins.kotlinMultiplatform)
+}
+
+kotlin {
+    js(IR) {
+        nodejs {
+            testTask { enabled = false }
+        }
+        binaries.executable()
+    }
+}`,
+			},
+			want: "jsProductionExecutableCompileSync",
+		},
+		{
+			name: "kotlin js plugin groovy",
+			files: map[string]string{
+				"build.gradle": `plugins {
+    id "org.jetbrains.kotlin.js" version "1.9.24"
+}
+
+kotlin {
+    js {
+        nodejs()
+        binaries.executable()
+    }
+}`,
+			},
+			want: "productionExecutableCompileSync",
+		},
+		{
+			name: "browser target",
+			files: map[string]string{
+				"build.gradle.kts": `plugins {
+    kotlin("multiplatform") version "2.0.0"
+}
+
+kotlin {
+    js {
+        browser()
+        binaries.executable()
+    }
+}`,
+			},
+		},
+		{
+			name: "node library",
+			files: map[string]string{
+				"build.gradle.kts": `plugins {
+    kotlin("multiplatform") version "2.0.0"
+}
+
+kotlin {
+    js {
+        nodejs()
+    }
+}`,
+			},
+		},
+		{
+			name: "jvm application",
+			files: map[string]string{
+				"build.gradle.kts": `plugins {
+    kotlin("jvm") version "2.0.0"
+    application
+}`,
+			},
+		},
+		{
+			name: "no build file",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKotlinJSTestFiles(t, dir, tc.files)
+			got, err := KotlinJSTask(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("KotlinJSTask() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("KotlinJSTask() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKotlinJSMain(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "single package",
+			files: map[string]string{
+				"build/js/packages/server/package.json":      `{"name": "server", "main": "kotlin/server.js"}`,
+				"build/js/packages/server-test/package.json": `{"name": "server-test", "main": "kotlin/server-test.js"}`,
+			},
+			want: "build/js/packages/server/kotlin/server.js",
+		},
+		{
+			name:    "not built",
+			wantErr: true,
+		},
+		{
+			name: "several packages",
+			files: map[string]string{
+				"build/js/packages/app-server/package.json": `{"name": "app-server", "main": "kotlin/app-server.js"}`,
+				"build/js/packages/app-worker/package.json": `{"name": "app-worker", "main": "kotlin/app-worker.js"}`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKotlinJSTestFiles(t, dir, tc.files)
+			got, err := KotlinJSMain(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("KotlinJSMain() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("KotlinJSMain() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func writeKotlinJSTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for f, content := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", f, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// SpringBootLayersEnv is an env var used to disable splitting layered Spring Boot jars into
+	// separate image layers.
+	// Example: `false` runs the jar with `java -jar` instead.
+	SpringBootLayersEnv = "GOOGLE_JAVA_SPRING_BOOT_LAYERS"
+
+	// springBootLayersIndexPath is the path of the layer index inside a layered Spring Boot jar.
+	springBootLayersIndexPath = "BOOT-INF/layers.idx"
+)
+
+// SpringBootLayer is a layer listed in the layers.idx of a Spring Boot jar.
+type SpringBootLayer struct {
+	// Name of the layer, for example `dependencies`.
+	Name string
+	// Paths are the jar entries in the layer. Paths ending in a slash are directories.
+	Paths []string
+}
+
+// SpringBootLayers returns the layers listed in the layers.idx of the given jar, ordered from the
+// least to the most frequently changing. It returns nil if the jar is not a layered Spring Boot jar.
+func SpringBootLayers(jar string) ([]SpringBootLayer, error) {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return nil, gcp.UserErrorf("unzipping jar %s: %v", jar, err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != springBootLayersIndexPath {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening %s in jar %s: %w", f.Name, jar, err)
+		}
+		defer rc.Close()
+		return parseSpringBootLayersIndex(rc)
+	}
+	return nil, nil
+}
+
+// parseSpringBootLayersIndex parses a layers.idx file. Each layer is listed as `- "name":`,
+// followed by its paths, each listed as `  - "path"`.
+func parseSpringBootLayersIndex(r io.Reader) ([]SpringBootLayer, error) {
+	var layers []SpringBootLayer
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "- ") {
+			name := strings.Trim(strings.TrimSuffix(strings.TrimPrefix(line, "- "), ":"), `"`)
+			layers = append(layers, SpringBootLayer{Name: name})
+			continue
+		}
+		if !strings.HasPrefix(line, "  - ") || len(layers) == 0 {
+			return nil, gcp.UserErrorf("invalid line in %s: %q", springBootLayersIndexPath, line)
+		}
+		path := strings.Trim(strings.TrimPrefix(line, "  - "), `"`)
+		layers[len(layers)-1].Paths = append(layers[len(layers)-1].Paths, path)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", springBootLayersIndexPath, err)
+	}
+	return layers, nil
+}
+
+// ExplodeJar extracts the contents of the jar into dir.
+func ExplodeJar(jar, dir string) error {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return gcp.UserErrorf("unzipping jar %s: %v", jar, err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		dest := filepath.Join(dir, f.Name)
+		if !strings.HasPrefix(dest, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return gcp.UserErrorf("jar %s contains an entry outside of the archive root: %s", jar, f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractZipFile(f, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", dest, err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, rc); err != nil {
+		return fmt.Errorf("extracting %s: %w", f.Name, err)
+	}
+	return nil
+}
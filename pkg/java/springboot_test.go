@@ -0,0 +1,131 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testLayersIndex = `- "dependencies":
+  - "BOOT-INF/lib/"
+- "spring-boot-loader":
+  - "org/"
+- "snapshot-dependencies":
+- "application":
+  - "BOOT-INF/classes/"
+  - "BOOT-INF/classpath.idx"
+  - "BOOT-INF/layers.idx"
+  - "META-INF/"
+`
+
+func TestSpringBootLayers(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []SpringBootLayer
+	}{
+		{
+			name:  "not layered",
+			files: map[string]string{"META-INF/MANIFEST.MF": "Main-Class: com.example.Main\n"},
+		},
+		{
+			name: "layered",
+			files: map[string]string{
+				"META-INF/MANIFEST.MF": "Main-Class: org.springframework.boot.loader.launch.JarLauncher\n",
+				"BOOT-INF/layers.idx":  testLayersIndex,
+			},
+			want: []SpringBootLayer{
+				{Name: "dependencies", Paths: []string{"BOOT-INF/lib/"}},
+				{Name: "spring-boot-loader", Paths: []string{"org/"}},
+				{Name: "snapshot-dependencies"},
+				{Name: "application", Paths: []string{"BOOT-INF/classes/", "BOOT-INF/classpath.idx", "BOOT-INF/layers.idx", "META-INF/"}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jar := writeTestJar(t, tc.files)
+
+			got, err := SpringBootLayers(jar)
+			if err != nil {
+				t.Fatalf("SpringBootLayers() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SpringBootLayers() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExplodeJar(t *testing.T) {
+	files := map[string]string{
+		"META-INF/MANIFEST.MF":                 "Main-Class: org.springframework.boot.loader.launch.JarLauncher\n",
+		"BOOT-INF/lib/dep.jar":                 "dep",
+		"BOOT-INF/classes/com/example/A.class": "class",
+	}
+	jar := writeTestJar(t, files)
+	dir := t.TempDir()
+
+	if err := ExplodeJar(jar, dir); err != nil {
+		t.Fatalf("ExplodeJar() got error: %v", err)
+	}
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("ExplodeJar() %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExplodeJarRejectsEntriesOutsideRoot(t *testing.T) {
+	jar := writeTestJar(t, map[string]string{"../evil": "x"})
+
+	if err := ExplodeJar(jar, t.TempDir()); err == nil {
+		t.Error("ExplodeJar() got no error, want error")
+	}
+}
+
+func writeTestJar(t *testing.T, files map[string]string) string {
+	t.Helper()
+	jar := filepath.Join(t.TempDir(), "app.jar")
+	f, err := os.Create(jar)
+	if err != nil {
+		t.Fatalf("creating %s: %v", jar, err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		zf, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("creating zip entry %s: %v", name, err)
+		}
+		if _, err := zf.Write([]byte(content)); err != nil {
+			t.Fatalf("writing zip entry %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing zip writer: %v", err)
+	}
+	return jar
+}
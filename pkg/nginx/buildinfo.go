@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gitmeta"
+)
+
+const (
+	// BuildInfoPath is the URL path at which nginx serves the build metadata.
+	BuildInfoPath = "/__build.json"
+	// buildInfoFile is the name of the file the build metadata is written to.
+	buildInfoFile = "__build.json"
+)
+
+var (
+	// buildIDEnvs are env vars set by CI systems to the ID of the build, in order of precedence.
+	buildIDEnvs = []string{"BUILD_ID"}
+
+	// unsafeHeaderRegexp matches characters which are not allowed in the X-Build-Id header value.
+	unsafeHeaderRegexp = regexp.MustCompile(`[^A-Za-z0-9._:+-]`)
+)
+
+// BuildInfo is the build metadata served by nginx when env.BuildInfo is enabled.
+type BuildInfo struct {
+	BuildID        string `json:"build_id"`
+	Commit         string `json:"commit,omitempty"`
+	Branch         string `json:"branch,omitempty"`
+	Tag            string `json:"tag,omitempty"`
+	Dirty          bool   `json:"dirty,omitempty"`
+	BuildTime      string `json:"build_time"`
+	AdapterVersion string `json:"adapter_version,omitempty"`
+}
+
+// NewBuildInfo returns the metadata of the current build of the source with the git metadata. The
+// build ID is the ID set by the CI system, or the commit if there is none, or else the build time.
+func NewBuildInfo(git gitmeta.Metadata, now time.Time) BuildInfo {
+	info := BuildInfo{
+		Commit:         git.Commit,
+		Branch:         git.Branch,
+		Tag:            git.Tag,
+		Dirty:          git.Dirty,
+		BuildTime:      now.UTC().Format(time.RFC3339),
+		AdapterVersion: os.Getenv(env.BuildAdapterVersion),
+	}
+	info.BuildID = firstEnv(buildIDEnvs)
+	if info.BuildID == "" {
+		info.BuildID = info.Commit
+	}
+	if info.BuildID == "" {
+		info.BuildID = now.UTC().Format("20060102T150405Z")
+	}
+	info.BuildID = unsafeHeaderRegexp.ReplaceAllString(info.BuildID, "_")
+	return info
+}
+
+// BuildInfoConfig writes the build metadata of the source in appDir to dir if enabled with
+// env.BuildInfo, and returns the path of the file and the build ID, or empty strings if it is not
+// enabled.
+func BuildInfoConfig(dir, appDir string, now time.Time) (string, string, error) {
+	enabled, err := env.IsPresentAndTrue(env.BuildInfo)
+	if err != nil || !enabled {
+		return "", "", err
+	}
+	git, err := gitmeta.Read(appDir)
+	if err != nil {
+		return "", "", fmt.Errorf("reading git metadata: %w", err)
+	}
+	info := NewBuildInfo(git, now)
+	content, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", "", fmt.Errorf("marshalling build info: %w", err)
+	}
+	path := filepath.Join(dir, buildInfoFile)
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return "", "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return path, info.BuildID, nil
+}
+
+func firstEnv(names []string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
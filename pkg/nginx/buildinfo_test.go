@@ -0,0 +1,136 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gitmeta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewBuildInfo(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		name string
+		envs map[string]string
+		want BuildInfo
+	}{
+		{
+			name: "no metadata",
+			want: BuildInfo{BuildID: "20240501T123000Z", BuildTime: "2024-05-01T12:30:00Z"},
+		},
+		{
+			name: "build id and commit",
+			envs: map[string]string{"BUILD_ID": "1234-abcd", "COMMIT_SHA": "4b825dc6"},
+			want: BuildInfo{BuildID: "1234-abcd", Commit: "4b825dc6", BuildTime: "2024-05-01T12:30:00Z"},
+		},
+		{
+			name: "commit only",
+			envs: map[string]string{"SOURCE_COMMIT": "4b825dc6"},
+			want: BuildInfo{BuildID: "4b825dc6", Commit: "4b825dc6", BuildTime: "2024-05-01T12:30:00Z"},
+		},
+		{
+			name: "explicit commit takes precedence",
+			envs: map[string]string{env.BuildCommit: "aaaa", "COMMIT_SHA": "bbbb"},
+			want: BuildInfo{BuildID: "aaaa", Commit: "aaaa", BuildTime: "2024-05-01T12:30:00Z"},
+		},
+		{
+			name: "adapter version",
+			envs: map[string]string{"BUILD_ID": "b1", env.BuildAdapterVersion: "14.0.1"},
+			want: BuildInfo{BuildID: "b1", BuildTime: "2024-05-01T12:30:00Z", AdapterVersion: "14.0.1"},
+		},
+		{
+			name: "unsafe build id",
+			envs: map[string]string{"BUILD_ID": `b1"; add_header X 1`},
+			want: BuildInfo{BuildID: "b1___add_header_X_1", BuildTime: "2024-05-01T12:30:00Z"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, e := range append(gitmeta.CommitEnvs, append(buildIDEnvs, env.BuildAdapterVersion)...) {
+				t.Setenv(e, "")
+			}
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			if diff := cmp.Diff(tc.want, NewBuildInfo(gitmeta.FromEnv(), now)); diff != "" {
+				t.Errorf("NewBuildInfo() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildInfoConfig(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	t.Setenv("BUILD_ID", "b1")
+	t.Setenv(env.BuildInfo, "true")
+	dir := t.TempDir()
+
+	path, id, err := BuildInfoConfig(dir, t.TempDir(), now)
+	if err != nil {
+		t.Fatalf("BuildInfoConfig() got error: %v", err)
+	}
+	if want := filepath.Join(dir, "__build.json"); path != want {
+		t.Errorf("BuildInfoConfig() path = %q, want %q", path, want)
+	}
+	if id != "b1" {
+		t.Errorf("BuildInfoConfig() id = %q, want %q", id, "b1")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	var got BuildInfo
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("unmarshalling %s: %v", path, err)
+	}
+	if got.BuildID != "b1" {
+		t.Errorf("%s build_id = %q, want %q", path, got.BuildID, "b1")
+	}
+
+	conf, err := WriteProxyConfigToPath(dir, ProxyConfig{Port: "8080", AppListenAddress: "unix:/tmp/app.sock", BuildInfoFile: path, BuildID: id})
+	if err != nil {
+		t.Fatalf("WriteProxyConfigToPath() got error: %v", err)
+	}
+	conf.Close()
+	content, err := os.ReadFile(conf.Name())
+	if err != nil {
+		t.Fatalf("reading %s: %v", conf.Name(), err)
+	}
+	for _, want := range []string{`add_header	X-Build-Id	"b1" always;`, "location = /__build.json {", "alias	" + path + ";"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("nginx config does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestBuildInfoConfigDisabled(t *testing.T) {
+	t.Setenv(env.BuildInfo, "false")
+	dir := t.TempDir()
+	path, id, err := BuildInfoConfig(dir, t.TempDir(), time.Now())
+	if err != nil {
+		t.Fatalf("BuildInfoConfig() got error: %v", err)
+	}
+	if path != "" || id != "" {
+		t.Errorf("BuildInfoConfig() = %q, %q, want empty", path, id)
+	}
+}
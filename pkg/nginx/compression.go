@@ -0,0 +1,94 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+const (
+	// brotliMainConf is the name of the main nginx config which loads the brotli modules.
+	brotliMainConf = "nginx-brotli.conf"
+	// brotliLoader is the name of the nginx wrapper which starts nginx with brotliMainConf.
+	brotliLoader = "nginx-brotli"
+)
+
+// BrotliModules are the dynamic modules of ngx_brotli, installed in the modules directory of the
+// nginx layer.
+var BrotliModules = []string{"ngx_http_brotli_filter_module.so", "ngx_http_brotli_static_module.so"}
+
+// brotliMainConfTemplate is a template that produces the main nginx config which loads the brotli
+// modules, as load_module is only valid in the main context, and includes the config generated by
+// the pid1 program.
+var brotliMainConfTemplate = template.Must(template.New("brotliconf").Parse(`{{range .Modules -}}
+load_module	{{.}};
+{{end -}}
+include	{{.Conf}};
+`))
+
+// brotliLoaderTemplate is a template that produces a wrapper of the nginx binary which replaces the
+// config passed by the pid1 program with the main config which loads the brotli modules.
+var brotliLoaderTemplate = template.Must(template.New("brotliloader").Parse(`#!/bin/sh
+for arg; do
+	shift
+	if [ "$arg" = "{{.Conf}}" ]; then
+		arg="{{.MainConf}}"
+	fi
+	set -- "$@" "$arg"
+done
+exec {{.Binary}} "$@"
+`))
+
+// WriteBrotliLoader writes to dir an nginx wrapper which loads the brotli modules from modulesDir
+// before the config at conf, which must be in dir so that its relative paths are unchanged, and
+// returns the path of the wrapper to start instead of the nginx binary.
+func WriteBrotliLoader(dir, binary, modulesDir, conf string) (string, error) {
+	if filepath.Dir(conf) != filepath.Clean(dir) {
+		return "", fmt.Errorf("nginx config %s is not in %s", conf, dir)
+	}
+	var modules []string
+	for _, m := range BrotliModules {
+		modules = append(modules, filepath.Join(modulesDir, m))
+	}
+	mainConf := filepath.Join(dir, brotliMainConf)
+	if err := writeTemplate(mainConf, 0644, brotliMainConfTemplate, struct {
+		Modules []string
+		Conf    string
+	}{modules, conf}); err != nil {
+		return "", err
+	}
+	loader := filepath.Join(dir, brotliLoader)
+	if err := writeTemplate(loader, 0755, brotliLoaderTemplate, struct {
+		Binary, Conf, MainConf string
+	}{binary, conf, mainConf}); err != nil {
+		return "", err
+	}
+	return loader, nil
+}
+
+func writeTemplate(path string, mode os.FileMode, t *template.Template, data any) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := t.Execute(f, data); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteNginxConfigToPathCompression(t *testing.T) {
+	testCases := []struct {
+		name       string
+		conf       Config
+		want       []string
+		wantAbsent []string
+	}{
+		{
+			name:       "disabled",
+			conf:       Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php"},
+			wantAbsent: []string{"gzip", "brotli"},
+		},
+		{
+			name: "gzip",
+			conf: Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php", Gzip: true},
+			want: []string{
+				"gzip	on;",
+				"gzip_types	text/plain text/css text/javascript text/xml application/javascript application/json",
+			},
+			wantAbsent: []string{"brotli", "text/html"},
+		},
+		{
+			name: "gzip and brotli",
+			conf: Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php", Gzip: true, Brotli: true},
+			want: []string{
+				"gzip	on;",
+				"brotli	on;",
+				"brotli_static	on;",
+				"brotli_types	text/plain text/css text/javascript text/xml application/javascript application/json",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := WriteNginxConfigToPath(dir, tc.conf)
+			if err != nil {
+				t.Fatalf("WriteNginxConfigToPath() got error: %v", err)
+			}
+			f.Close()
+			got, err := os.ReadFile(filepath.Join(dir, nginxServerConf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("config does not contain %q:\n%s", want, got)
+				}
+			}
+			for _, absent := range tc.wantAbsent {
+				if strings.Contains(string(got), absent) {
+					t.Errorf("config contains %q:\n%s", absent, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteBrotliLoader(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(t.TempDir(), "nginx")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "nginx.conf")
+
+	loader, err := WriteBrotliLoader(dir, binary, "/layers/nginx/modules", conf)
+	if err != nil {
+		t.Fatalf("WriteBrotliLoader() got error: %v", err)
+	}
+	mainConf := filepath.Join(dir, brotliMainConf)
+	got, err := os.ReadFile(mainConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "load_module	/layers/nginx/modules/ngx_http_brotli_filter_module.so;\n" +
+		"load_module	/layers/nginx/modules/ngx_http_brotli_static_module.so;\n" +
+		"include	" + conf + ";\n"
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", mainConf, got, want)
+	}
+
+	out, err := exec.Command(loader, "-e", "stderr", "-c", conf, "-g", "daemon off;").Output()
+	if err != nil {
+		t.Fatalf("running %s: %v", loader, err)
+	}
+	if want := "-e stderr -c " + mainConf + " -g daemon off;\n"; string(out) != want {
+		t.Errorf("%s passed %q to nginx, want %q", loader, out, want)
+	}
+}
+
+func TestWriteBrotliLoaderConfOutsideDir(t *testing.T) {
+	if _, err := WriteBrotliLoader(t.TempDir(), "nginx", "/modules", "/etc/nginx/nginx.conf"); err == nil {
+		t.Error("WriteBrotliLoader() got no error for a config outside of the directory, want error")
+	}
+}
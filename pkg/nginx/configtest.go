@@ -0,0 +1,48 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// nginxTestErrorRegexp matches an error of `nginx -t`, such as
+	// `nginx: [emerg] unknown directive "foo" in /workspace/nginx.conf:12`.
+	nginxTestErrorRegexp = regexp.MustCompile(`\[(?:emerg|alert|crit|error)\] (.*)`)
+	// fpmTestErrorRegexp matches an error of `php-fpm -t`, such as
+	// `[16-Oct-2026 10:00:00] ERROR: [/workspace/php-fpm.conf:3] unknown entry 'foo'`.
+	fpmTestErrorRegexp = regexp.MustCompile(`(?:ERROR|ALERT): (.*)`)
+)
+
+// NginxTestError returns the first error in the output of `nginx -t`, which names the file and
+// line of the offending directive, or the whole output if it has no recognized error.
+func NginxTestError(output string) string {
+	return firstTestError(nginxTestErrorRegexp, output)
+}
+
+// FPMTestError returns the first error in the output of `php-fpm -t`, which names the file and
+// line of the offending entry, or the whole output if it has no recognized error.
+func FPMTestError(output string) string {
+	return firstTestError(fpmTestErrorRegexp, output)
+}
+
+func firstTestError(re *regexp.Regexp, output string) string {
+	if m := re.FindStringSubmatch(output); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return strings.TrimSpace(output)
+}
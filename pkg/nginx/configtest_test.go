@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import "testing"
+
+func TestNginxTestError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "unknown directive",
+			output: "nginx: [emerg] unknown directive \"lisen\" in /workspace/nginx.conf:12\nnginx: configuration file /workspace/nginx.conf test failed\n",
+			want:   "unknown directive \"lisen\" in /workspace/nginx.conf:12",
+		},
+		{
+			name:   "warning before the error",
+			output: "nginx: [warn] the \"user\" directive makes sense only if the master process runs with super-user privileges, ignored in /workspace/nginx.conf:1\nnginx: [emerg] unexpected end of file, expecting \"}\" in /workspace/nginx.conf:40\n",
+			want:   "unexpected end of file, expecting \"}\" in /workspace/nginx.conf:40",
+		},
+		{
+			name:   "unrecognized output",
+			output: "nginx: configuration file /workspace/nginx.conf test failed\n",
+			want:   "nginx: configuration file /workspace/nginx.conf test failed",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NginxTestError(tc.output); got != tc.want {
+				t.Errorf("NginxTestError() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFPMTestError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "unknown entry",
+			output: "[16-Oct-2026 10:00:00] ERROR: [/workspace/php-fpm.conf:3] unknown entry 'pm.max_childs'\n[16-Oct-2026 10:00:00] ERROR: failed to load configuration file '/layers/webconfig/php-fpm.conf'\n[16-Oct-2026 10:00:00] ERROR: FPM initialization failed\n",
+			want:   "[/workspace/php-fpm.conf:3] unknown entry 'pm.max_childs'",
+		},
+		{
+			name:   "unrecognized output",
+			output: "Segmentation fault\n",
+			want:   "Segmentation fault",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := FPMTestError(tc.output); got != tc.want {
+				t.Errorf("FPMTestError() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// FPMWorkersExecD is the name of the exec.d executable which sizes the php-fpm workers at launch.
+	FPMWorkersExecD = "php-fpm-workers"
+	// FPMMaxChildrenEnv is the env var pm.max_children is read from when the workers are sized at
+	// launch. Setting it at deploy time overrides the computed value.
+	FPMMaxChildrenEnv = "PHP_FPM_MAX_CHILDREN"
+	// FPMWorkerMemoryEnv is the env var with the estimated memory of a php-fpm worker in MiB.
+	FPMWorkerMemoryEnv = "PHP_FPM_WORKER_MEMORY"
+	// DefaultFPMWorkerMemory is the estimated memory of a php-fpm worker in MiB.
+	DefaultFPMWorkerMemory = 64
+	// fpmReservedMemory is the memory in MiB kept for nginx, the php-fpm master and the OPcache.
+	fpmReservedMemory = 128
+	// fpmWorkersPerCPU limits the workers per CPU, so that workers of apps with a small footprint
+	// do not compete for the CPU.
+	fpmWorkersPerCPU = 16
+	// minFPMWorkers is the lower bound of the workers.
+	minFPMWorkers = 2
+)
+
+// fpmWorkersExecDTemplate is an exec.d executable which sets FPMMaxChildrenEnv to the number of
+// php-fpm workers which fit in the memory limit of the container, given the memory of a worker,
+// bounded by the CPU quota. Without a memory limit the workers are derived from the CPUs.
+const fpmWorkersExecDTemplate = `#!/bin/sh
+if [ -n "$%[2]s" ]; then
+  exit 0
+fi
+cgroup=%[1]s
+` + cgroupLimitsScript + `
+worker=${%[3]s:-%[4]d}
+case "$worker" in
+  ''|0|*[!0-9]*) worker=%[4]d ;;
+esac
+max=$(( cpus * %[6]d ))
+children=$max
+if [ "$memory_mib" -gt 0 ] && [ "$memory_mib" -lt 4194304 ]; then
+  children=$(( (memory_mib - %[5]d) / worker ))
+fi
+if [ "$children" -gt "$max" ]; then
+  children=$max
+fi
+if [ "$children" -lt %[7]d ]; then
+  children=%[7]d
+fi
+echo "%[2]s = \"$children\"" >&3
+`
+
+// WriteFPMWorkersExecD writes the exec.d executable which sizes the php-fpm workers to the memory
+// and CPU of the container at launch to the given exec.d directory of a launch layer.
+func WriteFPMWorkersExecD(execDir string) error {
+	if err := os.MkdirAll(execDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(execDir, FPMWorkersExecD)
+	if err := os.WriteFile(path, []byte(fpmWorkersScript(cgroupRoot)), 0755); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// fpmWorkersScript returns the exec.d executable sizing the workers from the limits in the cgroup
+// filesystem mounted at cgroup.
+func fpmWorkersScript(cgroup string) string {
+	return fmt.Sprintf(fpmWorkersExecDTemplate, cgroup, FPMMaxChildrenEnv, FPMWorkerMemoryEnv,
+		DefaultFPMWorkerMemory, fpmReservedMemory, fpmWorkersPerCPU, minFPMWorkers)
+}
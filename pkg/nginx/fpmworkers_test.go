@@ -0,0 +1,117 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFPMWorkersScript(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cgroup map[string]string
+		env    []string
+		want   string
+	}{
+		{
+			name: "memory bound",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "1073741824\n",
+			},
+			want: "PHP_FPM_MAX_CHILDREN = \"14\"\n",
+		},
+		{
+			name: "worker memory set",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "1073741824\n",
+			},
+			env:  []string{"PHP_FPM_WORKER_MEMORY=100"},
+			want: "PHP_FPM_MAX_CHILDREN = \"8\"\n",
+		},
+		{
+			name: "cpu bound",
+			cgroup: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "100000\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "8589934592\n",
+			},
+			want: "PHP_FPM_MAX_CHILDREN = \"16\"\n",
+		},
+		{
+			name: "small memory",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "134217728\n",
+			},
+			want: "PHP_FPM_MAX_CHILDREN = \"2\"\n",
+		},
+		{
+			name: "no memory limit",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "max\n",
+			},
+			want: "PHP_FPM_MAX_CHILDREN = \"16\"\n",
+		},
+		{
+			name: "set at deploy time",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "1073741824\n",
+			},
+			env:  []string{"PHP_FPM_MAX_CHILDREN=5"},
+			want: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cgroup := t.TempDir()
+			for name, content := range tc.cgroup {
+				path := filepath.Join(cgroup, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// exec.d executables write the env vars they set to file descriptor 3.
+			out, err := os.Create(filepath.Join(t.TempDir(), "env.toml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+			cmd := exec.Command("sh", "-c", fpmWorkersScript(cgroup))
+			cmd.Env = append([]string{"PATH=" + os.Getenv("PATH")}, tc.env...)
+			cmd.ExtraFiles = []*os.File{out}
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("running the workers script: %v\n%s", err, output)
+			}
+
+			got, err := os.ReadFile(out.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("workers script wrote %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"text/template"
 )
 
@@ -48,14 +49,14 @@ pm = dynamic
 ; The number of child processes to be created
 pm.start_servers = 1
 pm.min_spare_servers = 1
-pm.max_spare_servers = {{.NumWorkers}}
-pm.max_children = {{.NumWorkers}}
+pm.max_spare_servers = {{.MaxChildren}}
+pm.max_children = {{.MaxChildren}}
 {{else}}
 ; Create child processes with a static policy.
 pm = static
 
 ; The number of child processes to be created
-pm.max_children = {{.NumWorkers}}
+pm.max_children = {{.MaxChildren}}
 {{end}}
 
 ; Keep the environment variables of the parent.
@@ -65,6 +66,14 @@ catch_workers_output = yes
 {{if .AddNoDecorateWorkers}}
 decorate_workers_output = no
 {{end}}
+{{- if .SlowlogTimeout}}
+; Log the stack trace of requests running longer than the timeout.
+slowlog = {{.Slowlog}}
+request_slowlog_timeout = {{.SlowlogTimeout}}
+{{- if .SlowlogTraceDepth}}
+request_slowlog_trace_depth = {{.SlowlogTraceDepth}}
+{{- end}}
+{{- end}}
 
 {{- if .ConfOverride}}
 include = {{.ConfOverride}}
@@ -85,21 +94,106 @@ upstream fast_cgi_app {
 }
 
 server {
-	listen	{{.Port}} default_server;
-	listen	[::]:{{.Port}} default_server;
+	listen	{{.Port}}{{if .HTTP2}} http2{{end}} default_server;
+	listen	[::]:{{.Port}}{{if .HTTP2}} http2{{end}} default_server;
+	{{- if .TLSPort}}
+	listen	{{.TLSPort}} ssl{{if .HTTP2}} http2{{end}} default_server;
+	listen	[::]:{{.TLSPort}} ssl{{if .HTTP2}} http2{{end}} default_server;
+	# Written at launch by the nginx-tls exec.d executable.
+	include	/tmp/nginx_tls/tls.conf;
+	ssl_protocols	TLSv1.2 TLSv1.3;
+	ssl_session_cache	shared:SSL:10m;
+	{{- end}}
 	server_name	"";
 	root	{{.Root}};
+	{{- if .KeepaliveTimeout}}
+	keepalive_timeout	{{.KeepaliveTimeout}};
+	{{- end}}
+	{{- if .KeepaliveRequests}}
+	keepalive_requests	{{.KeepaliveRequests}};
+	{{- end}}
+	{{- if .ClientMaxBodySize}}
+	client_max_body_size	{{.ClientMaxBodySize}};
+	{{- end}}
+	{{- if .Gzip}}
+
+	gzip	on;
+	gzip_vary	on;
+	gzip_proxied	any;
+	gzip_comp_level	5;
+	gzip_min_length	256;
+	gzip_types	{{template "compression_types"}};
+	{{- end}}
+	{{- if .Brotli}}
 
-	{{if .ServesStaticFiles}}
+	brotli	on;
+	brotli_static	on;
+	brotli_comp_level	5;
+	brotli_min_length	256;
+	brotli_types	{{template "compression_types"}};
+	{{- end}}
+	{{- if .BuildInfoFile}}
+	add_header	X-Build-Id	"{{.BuildID}}" always;
+
+	location = /__build.json {
+		alias	{{.BuildInfoFile}};
+		default_type	application/json;
+		access_log	off;
+		add_header	Cache-Control	"no-store" always;
+		add_header	X-Build-Id	"{{.BuildID}}" always;
+	}
+	{{- end}}
+
+	{{if .WordPress}}
+	{{- template "wordpress" .}}
+	{{else if .ServesStaticFiles}}
 	location / {
 		try_files $uri /{{.FrontControllerScript}}$uri;
 	}
+	{{else if .Routes}}
+	location / {
+		rewrite	^/(.*)$	/{{.FrontControllerScript}}$uri	last;
+	}
+	{{else if .BuildInfoFile}}
+	rewrite	^/(?!__build\.json$)(.*)$	/{{.FrontControllerScript}}$uri;
 	{{else}}
 	rewrite	^/(.*)$	/{{.FrontControllerScript}}$uri;
 	{{end}}
+	{{- if not .WordPress}}
 
 	location	~	^/{{.FrontControllerScript}}	{
 		error_log stderr;
+		{{- template "fastcgi" .}}
+	}
+	{{- end}}
+	{{- range .Routes}}
+
+	location ^~ {{.Path}}{{if and (eq .Type "static") .Dir}}/{{end}} {
+		{{- range $name, $value := .Headers}}
+		add_header	{{$name}}	"{{$value}}" always;
+		{{- end}}
+		{{- if and .Headers $.BuildInfoFile}}
+		add_header	X-Build-Id	"{{$.BuildID}}" always;
+		{{- end}}
+		{{- if eq .Type "php"}}
+		error_log stderr;
+		{{- template "fastcgi" $}}
+		{{- else if eq .Type "static"}}
+		{{- if .Dir}}
+		alias	{{.Dir}}/;
+		{{- end}}
+		try_files	$uri =404;
+		{{- else if eq .Type "proxy"}}
+		{{- template "proxy" .}}
+		{{- end}}
+	}
+	{{- end}}
+
+	{{- if .NginxConfInclude}}
+	include {{.NginxConfInclude}};
+	{{- end}}
+}
+{{- define "fastcgi"}}
 
 		fastcgi_pass	fast_cgi_app;
 		fastcgi_buffering	off;
@@ -117,7 +211,7 @@ server {
 		fastcgi_param	CONTENT_LENGTH	$content_length;
 
 		fastcgi_param	SCRIPT_NAME	$fastcgi_script_name;
-		fastcgi_param	SCRIPT_FILENAME	$document_root/{{.FrontControllerScript}};
+		fastcgi_param	SCRIPT_FILENAME	$document_root{{if .WordPress}}$fastcgi_script_name{{else}}/{{.FrontControllerScript}}{{end}};
 		fastcgi_param	PATH_INFO	$fastcgi_path_info;
 		fastcgi_param	REQUEST_URI	$request_uri;
 		fastcgi_param	DOCUMENT_URI	$fastcgi_script_name;
@@ -141,12 +235,160 @@ server {
 		fastcgi_param X_FORWARDED_HOST $http_x_forwarded_host;
 		fastcgi_param X_FORWARDED_PROTO $http_x_forwarded_proto;
 		fastcgi_param FORWARDED $http_forwarded;
+{{- end}}
+{{- define "wordpress"}}
+
+	index	index.php;
+
+	location = /xmlrpc.php {
+		deny	all;
+	}
+
+	location = /wp-config.php {
+		deny	all;
+	}
+
+	location ~* ^/wp-content/uploads/.*\.php$ {
+		deny	all;
+	}
+
+	# Pretty permalinks.
+	location / {
+		try_files	$uri $uri/ /{{.FrontControllerScript}}?$args;
+	}
+
+	location ~ \.php$ {
+		try_files	$uri =404;
+		error_log stderr;
+		{{- template "fastcgi" .}}
+	}
+{{- end}}
+{{- define "compression_types" -}}
+text/plain text/css text/javascript text/xml application/javascript application/json application/manifest+json application/xml application/rss+xml application/atom+xml application/wasm image/svg+xml font/ttf font/otf
+{{- end}}
+{{- define "proxy"}}
+		proxy_pass	{{.Target}};
+		proxy_http_version	1.1;
+		proxy_set_header	X-Forwarded-For $proxy_add_x_forwarded_for;
+		proxy_set_header	X-Forwarded-Host $http_host;
+		proxy_set_header	X-Forwarded-Proto $http_x_forwarded_proto;
+		proxy_redirect	off;
+		proxy_read_timeout	24h;
+{{- end}}
+`))
+
+// ProxyTemplate is a template that produces a snippet of nginx config that serves static files
+// from the given locations and proxies all other requests to an HTTP application server. It is
+// included in the http{} section of the config by the pid1 program.
+var ProxyTemplate = template.Must(template.New("proxy").Parse(`
+upstream app_server {
+	server         {{.AppListenAddress}} fail_timeout=0;
+}
+
+server {
+	listen	{{.Port}} default_server;
+	listen	[::]:{{.Port}} default_server;
+	server_name	"";
+	{{- if .BuildInfoFile}}
+	add_header	X-Build-Id	"{{.BuildID}}" always;
+
+	location = /__build.json {
+		alias	{{.BuildInfoFile}};
+		default_type	application/json;
+		access_log	off;
+		add_header	Cache-Control	"no-store" always;
+		add_header	X-Build-Id	"{{.BuildID}}" always;
+	}
+	{{- end}}
+	{{range .StaticLocations}}
+	location {{.Prefix}}/ {
+		alias	{{.Dir}}/;
+		access_log	off;
+		expires	{{$.StaticExpires}};
+		try_files	$uri =404;
+	}
+	{{end}}
+	location / {
+		proxy_pass	http://app_server;
+		proxy_http_version	1.1;
+		proxy_set_header	Host $http_host;
+		proxy_set_header	X-Forwarded-For $proxy_add_x_forwarded_for;
+		proxy_set_header	X-Forwarded-Proto $http_x_forwarded_proto;
+		proxy_set_header	Upgrade $http_upgrade;
+		proxy_set_header	Connection $connection_upgrade;
+		proxy_redirect	off;
+		proxy_buffering	off;
+		proxy_read_timeout	24h;
 	}
 
 	{{- if .NginxConfInclude}}
 	include {{.NginxConfInclude}};
 	{{- end}}
 }
+
+map $http_upgrade $connection_upgrade {
+	default	upgrade;
+	''	close;
+}
+`))
+
+// StaticSiteTemplate is a template that produces a complete nginx config that serves a static
+// site from a single directory. Unlike the other templates it is not included by pid1, nginx is
+// started directly with it as the only process of the container. The workers, connections and
+// keepalive timeout are included from the files written by the TuningExecD executable at launch.
+var StaticSiteTemplate = template.Must(template.New("static").Parse(`
+daemon off;
+# Written at launch by the nginx-tuning exec.d executable.
+include	/tmp/nginx_tuning/main/*.conf;
+pid /tmp/nginx.pid;
+error_log stderr;
+
+events {
+	include	/tmp/nginx_tuning/events/*.conf;
+}
+
+http {
+	include	{{.MimeTypesPath}};
+	default_type	application/octet-stream;
+	sendfile	on;
+	access_log	off;
+
+	client_body_temp_path	/tmp/nginx_client_body;
+	proxy_temp_path	/tmp/nginx_proxy;
+	fastcgi_temp_path	/tmp/nginx_fastcgi;
+	uwsgi_temp_path	/tmp/nginx_uwsgi;
+	scgi_temp_path	/tmp/nginx_scgi;
+
+	server {
+		listen	{{.Port}} default_server;
+		listen	[::]:{{.Port}} default_server;
+		server_name	"";
+		root	{{.Root}};
+		absolute_redirect	off;
+		include	/tmp/nginx_tuning/server/*.conf;
+		{{if .BasePath}}
+		location = {{.BasePath}} {
+			try_files	/index.html =404;
+		}
+
+		location {{.BasePath}}/ {
+			rewrite	^{{.BasePath}}(/.*)$	$1	break;
+			try_files	$uri $uri.html $uri/ =404;
+		}
+		{{else}}
+		location / {
+			try_files	$uri $uri.html $uri/ =404;
+		}
+		{{end}}
+		{{- if .AssetPrefix}}
+		location {{.AssetPrefix}}/_next/ {
+			rewrite	^{{.AssetPrefix}}(/.*)$	$1	break;
+			try_files	$uri =404;
+		}
+		{{end}}
+		error_page	404 {{.BasePath}}/404.html;
+	}
+}
 `))
 
 // FPMConfig represents the content values of a php-fpm config file.
@@ -158,6 +400,23 @@ type FPMConfig struct {
 	Username             string
 	AddNoDecorateWorkers bool
 	ConfOverride         string
+	// SlowlogTimeout is the request_slowlog_timeout, or empty to disable the slow log.
+	SlowlogTimeout string
+	// Slowlog is the path of the slow log.
+	Slowlog string
+	// SlowlogTraceDepth is the request_slowlog_trace_depth, or 0 to keep the php-fpm default.
+	SlowlogTraceDepth int
+	// WorkersEnv is the env var the number of workers is read from at launch instead of NumWorkers,
+	// if set.
+	WorkersEnv string
+}
+
+// MaxChildren returns the pm.max_children value of the config.
+func (c FPMConfig) MaxChildren() string {
+	if c.WorkersEnv != "" {
+		return "${" + c.WorkersEnv + "}"
+	}
+	return strconv.Itoa(c.NumWorkers)
 }
 
 // Config represents the content values of a nginx config file.
@@ -168,6 +427,93 @@ type Config struct {
 	FrontControllerScript string
 	NginxConfInclude      string
 	ServesStaticFiles     bool
+	// BuildInfoFile is the path of the build metadata served at /__build.json, if any.
+	BuildInfoFile string
+	// BuildID is the value of the X-Build-Id header added when BuildInfoFile is set.
+	BuildID string
+	// Routes are the URL path prefixes with location blocks of their own.
+	Routes []Route
+	// Gzip enables the gzip compression of text responses, text/html is always compressed.
+	Gzip bool
+	// Brotli enables the brotli compression of text responses. The modules must be loaded with the
+	// config written by WriteBrotliLoader.
+	Brotli bool
+	// HTTP2 serves cleartext HTTP/2 (h2c) on Port, as sent by Cloud Run with end-to-end HTTP/2.
+	HTTP2 bool
+	// KeepaliveTimeout is the keepalive_timeout, or empty for the default.
+	KeepaliveTimeout string
+	// KeepaliveRequests is the keepalive_requests, or 0 for the default.
+	KeepaliveRequests int
+	// TLSPort is the port nginx terminates TLS on, or 0 to disable TLS. The certificate is included
+	// from the config written by the TLSExecD executable at launch.
+	TLSPort int
+	// ClientMaxBodySize is the client_max_body_size, or empty for the default.
+	ClientMaxBodySize string
+	// WordPress runs the PHP scripts requested by their path instead of FrontControllerScript, routes
+	// the other requests which match no file to FrontControllerScript for the permalinks, and denies
+	// the requests to xmlrpc.php, wp-config.php and the PHP scripts in wp-content/uploads.
+	WordPress bool
+}
+
+// Route types.
+const (
+	// RoutePHP routes the requests to the front controller, even if they match a static file.
+	RoutePHP = "php"
+	// RouteStatic serves the requests from files and responds 404 if no file matches.
+	RouteStatic = "static"
+	// RouteProxy forwards the requests to an HTTP server.
+	RouteProxy = "proxy"
+)
+
+// Route is a URL path prefix of the application which is handled by a location block of its own.
+type Route struct {
+	// Path is the URL path prefix without a trailing slash, for example `/api`.
+	Path string `json:"path" yaml:"path"`
+	// Type is how the requests are handled, one of RoutePHP, RouteStatic or RouteProxy.
+	Type string `json:"type" yaml:"type"`
+	// Dir is the directory static routes are served from instead of the document root.
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	// Target is the URL proxy routes forward the requests to, for example `http://127.0.0.1:9000`.
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	// Headers are added to the responses.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// StaticLocation is a URL path prefix served by nginx from a directory.
+type StaticLocation struct {
+	// Prefix is the URL path prefix without a trailing slash, for example `/static`.
+	Prefix string
+	// Dir is the absolute path of the directory the files are served from.
+	Dir string
+}
+
+// ProxyConfig represents the content values of a nginx config file for a proxied application.
+type ProxyConfig struct {
+	// Port is the port nginx listens on, a number or a placeholder expanded at launch.
+	Port             string
+	AppListenAddress string
+	StaticLocations  []StaticLocation
+	StaticExpires    string
+	NginxConfInclude string
+	// BuildInfoFile is the path of the build metadata served at /__build.json, if any.
+	BuildInfoFile string
+	// BuildID is the value of the X-Build-Id header added when BuildInfoFile is set.
+	BuildID string
+}
+
+// StaticSiteConfig represents the content values of a nginx config file for a static site.
+type StaticSiteConfig struct {
+	Port int
+	// Root is the absolute path of the directory the site is served from.
+	Root string
+	// MimeTypesPath is the absolute path of the mime.types file shipped with nginx.
+	MimeTypesPath string
+	// BasePath is the URL path prefix the site is served under, without a trailing slash, or empty
+	// to serve it at /.
+	BasePath string
+	// AssetPrefix is the URL path prefix of the assets of the site when they are not requested under
+	// BasePath, without a trailing slash.
+	AssetPrefix string
 }
 
 const (
@@ -191,6 +537,35 @@ func WriteNginxConfigToPath(path string, conf Config) (*os.File, error) {
 	return nginxConfFile, nil
 }
 
+// WriteProxyConfigToPath writes the configuration for the nginx proxy server to the given path.
+func WriteProxyConfigToPath(path string, conf ProxyConfig) (*os.File, error) {
+	nginxConfFilePath := filepath.Join(path, nginxServerConf)
+	nginxConfFile, err := os.Create(nginxConfFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ProxyTemplate.Execute(nginxConfFile, conf); err != nil {
+		return nil, fmt.Errorf("writing nginx config file: %w", err)
+	}
+	return nginxConfFile, nil
+}
+
+// WriteStaticSiteConfigToPath writes the configuration for the nginx static site server to the
+// given path.
+func WriteStaticSiteConfigToPath(path string, conf StaticSiteConfig) (*os.File, error) {
+	nginxConfFilePath := filepath.Join(path, nginxServerConf)
+	nginxConfFile, err := os.Create(nginxConfFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := StaticSiteTemplate.Execute(nginxConfFile, conf); err != nil {
+		return nil, fmt.Errorf("writing nginx config file: %w", err)
+	}
+	return nginxConfFile, nil
+}
+
 // WriteFpmConfigToPath writes the fpm configuration file to the given path.
 func WriteFpmConfigToPath(path string, conf FPMConfig) (*os.File, error) {
 	fpmConfFilePath := filepath.Join(path, phpFpmConf)
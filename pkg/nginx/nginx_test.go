@@ -0,0 +1,232 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteStaticSiteConfigToPath(t *testing.T) {
+	testCases := []struct {
+		name       string
+		conf       StaticSiteConfig
+		want       []string
+		wantAbsent []string
+	}{
+		{
+			name: "served at root",
+			conf: StaticSiteConfig{
+				Port:          8080,
+				Root:          "/workspace/out",
+				MimeTypesPath: "/layers/nginx/conf/mime.types",
+			},
+			want: []string{
+				"daemon off;",
+				"include	/tmp/nginx_tuning/main/*.conf;",
+				"include	/tmp/nginx_tuning/events/*.conf;",
+				"include	/tmp/nginx_tuning/server/*.conf;",
+				"listen	8080 default_server;",
+				"root	/workspace/out;",
+				"include	/layers/nginx/conf/mime.types;",
+				"location / {",
+				"try_files	$uri $uri.html $uri/ =404;",
+				"error_page	404 /404.html;",
+			},
+			wantAbsent: []string{"rewrite"},
+		},
+		{
+			name: "base path and asset prefix",
+			conf: StaticSiteConfig{
+				Port:          8080,
+				Root:          "/workspace/out",
+				MimeTypesPath: "/layers/nginx/conf/mime.types",
+				BasePath:      "/docs",
+				AssetPrefix:   "/assets",
+			},
+			want: []string{
+				"location = /docs {",
+				"location /docs/ {",
+				"rewrite	^/docs(/.*)$	$1	break;",
+				"location /assets/_next/ {",
+				"rewrite	^/assets(/.*)$	$1	break;",
+				"error_page	404 /docs/404.html;",
+			},
+			wantAbsent: []string{"location / {"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := WriteStaticSiteConfigToPath(dir, tc.conf)
+			if err != nil {
+				t.Fatalf("WriteStaticSiteConfigToPath() got error: %v", err)
+			}
+			f.Close()
+			got, err := os.ReadFile(filepath.Join(dir, nginxServerConf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("config does not contain %q:\n%s", want, got)
+				}
+			}
+			for _, absent := range tc.wantAbsent {
+				if strings.Contains(string(got), absent) {
+					t.Errorf("config contains %q:\n%s", absent, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteNginxConfigToPathRoutes(t *testing.T) {
+	testCases := []struct {
+		name       string
+		conf       Config
+		want       []string
+		wantAbsent []string
+	}{
+		{
+			name: "no routes",
+			conf: Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php"},
+			want: []string{
+				"rewrite	^/(.*)$	/index.php$uri;",
+			},
+			wantAbsent: []string{"location ^~", "location / {"},
+		},
+		{
+			name: "routes",
+			conf: Config{
+				Port:                  8080,
+				Root:                  "/workspace/public",
+				FrontControllerScript: "index.php",
+				Routes: []Route{
+					{Path: "/api", Type: RoutePHP, Headers: map[string]string{"Cache-Control": "no-store"}},
+					{Path: "/build", Type: RouteStatic, Headers: map[string]string{"Cache-Control": "public, max-age=31536000, immutable"}},
+					{Path: "/media", Type: RouteStatic, Dir: "/workspace/storage/app/public"},
+					{Path: "/socket", Type: RouteProxy, Target: "http://127.0.0.1:6001"},
+				},
+			},
+			want: []string{
+				"location / {\n\t\trewrite	^/(.*)$	/index.php$uri	last;\n\t}",
+				"location ^~ /api {\n\t\tadd_header	Cache-Control	\"no-store\" always;\n\t\terror_log stderr;\n\n\t\tfastcgi_pass	fast_cgi_app;",
+				"location ^~ /build {\n\t\tadd_header	Cache-Control	\"public, max-age=31536000, immutable\" always;\n\t\ttry_files	$uri =404;\n\t}",
+				"location ^~ /media/ {\n\t\talias	/workspace/storage/app/public/;\n\t\ttry_files	$uri =404;\n\t}",
+				"location ^~ /socket {\n\t\tproxy_pass	http://127.0.0.1:6001;",
+			},
+			wantAbsent: []string{"rewrite	^/(.*)$	/index.php$uri;"},
+		},
+		{
+			name: "routes with build info",
+			conf: Config{
+				Port:                  8080,
+				Root:                  "/workspace",
+				FrontControllerScript: "index.php",
+				BuildInfoFile:         "/layers/webconfig/build.json",
+				BuildID:               "abc",
+				Routes:                []Route{{Path: "/assets", Type: RouteStatic, Headers: map[string]string{"X-Frame-Options": "DENY"}}},
+			},
+			want: []string{
+				"location ^~ /assets {\n\t\tadd_header	X-Frame-Options	\"DENY\" always;\n\t\tadd_header	X-Build-Id	\"abc\" always;",
+			},
+		},
+		{
+			name: "connection tuning",
+			conf: Config{
+				Port:                  8080,
+				Root:                  "/workspace",
+				FrontControllerScript: "index.php",
+				HTTP2:                 true,
+				KeepaliveTimeout:      "620s",
+				KeepaliveRequests:     10000,
+			},
+			want: []string{
+				"listen	8080 http2 default_server;",
+				"listen	[::]:8080 http2 default_server;",
+				"keepalive_timeout	620s;",
+				"keepalive_requests	10000;",
+			},
+		},
+		{
+			name: "tls",
+			conf: Config{
+				Port:                  8080,
+				Root:                  "/workspace",
+				FrontControllerScript: "index.php",
+				HTTP2:                 true,
+				TLSPort:               8443,
+			},
+			want: []string{
+				"listen	8443 ssl http2 default_server;",
+				"listen	[::]:8443 ssl http2 default_server;",
+				"include	/tmp/nginx_tls/tls.conf;",
+			},
+		},
+		{
+			name: "wordpress",
+			conf: Config{
+				Port:                  8080,
+				Root:                  "/workspace",
+				FrontControllerScript: "index.php",
+				ClientMaxBodySize:     "64M",
+				WordPress:             true,
+			},
+			want: []string{
+				"client_max_body_size	64M;",
+				"location = /xmlrpc.php {\n\t\tdeny	all;\n\t}",
+				"location = /wp-config.php {\n\t\tdeny	all;\n\t}",
+				"location ~* ^/wp-content/uploads/.*\\.php$ {\n\t\tdeny	all;\n\t}",
+				"location / {\n\t\ttry_files	$uri $uri/ /index.php?$args;\n\t}",
+				"location ~ \\.php$ {\n\t\ttry_files	$uri =404;",
+				"fastcgi_param	SCRIPT_FILENAME	$document_root$fastcgi_script_name;",
+			},
+			wantAbsent: []string{"rewrite", "location	~	^/index.php", "$document_root/index.php"},
+		},
+		{
+			name:       "default connection tuning",
+			conf:       Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php"},
+			want:       []string{"listen	8080 default_server;", "fastcgi_param	SCRIPT_FILENAME	$document_root/index.php;"},
+			wantAbsent: []string{"http2", "keepalive_", "ssl", "client_max_body_size", "xmlrpc"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := WriteNginxConfigToPath(dir, tc.conf)
+			if err != nil {
+				t.Fatalf("WriteNginxConfigToPath() got error: %v", err)
+			}
+			f.Close()
+			got, err := os.ReadFile(filepath.Join(dir, nginxServerConf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("config does not contain %q:\n%s", want, got)
+				}
+			}
+			for _, absent := range tc.wantAbsent {
+				if strings.Contains(string(got), absent) {
+					t.Errorf("config contains %q:\n%s", absent, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// TLSExecD is the name of the exec.d executable which writes the nginx TLS config at launch.
+	TLSExecD = "nginx-tls"
+	// TLSCertificateEnv is the env var with the path of the TLS certificate at launch.
+	TLSCertificateEnv = "NGINX_TLS_CERTIFICATE"
+	// TLSCertificateKeyEnv is the env var with the path of the TLS certificate key at launch.
+	TLSCertificateKeyEnv = "NGINX_TLS_CERTIFICATE_KEY"
+	// tlsDir is the directory the TLS config is written to at launch. NginxTemplate includes
+	// tls.conf from it when a TLS port is set.
+	tlsDir = "/tmp/nginx_tls"
+)
+
+// tlsExecDTemplate is an exec.d executable which writes the ssl_certificate directives from the
+// env vars, so that the certificate can be mounted from a secret at a path set at deploy time. It
+// fails the launch if the files cannot be read, instead of starting nginx without TLS.
+const tlsExecDTemplate = `#!/bin/sh
+cert="$%[2]s"
+key="$%[3]s"
+case "$cert$key" in
+  *'"'*|*'\'*|*'$'*|*'
+'*)
+    echo "%[2]s and %[3]s must not contain quotes, backslashes, dollar signs or newlines" >&2
+    exit 1
+    ;;
+esac
+for f in "$cert" "$key"; do
+  if [ -z "$f" ] || [ ! -r "$f" ]; then
+    echo "Cannot read the TLS certificate or key '$f', set %[2]s and %[3]s to readable files" >&2
+    exit 1
+  fi
+done
+dir=%[1]s
+mkdir -p "$dir" || exit 1
+printf 'ssl_certificate "%%s";\nssl_certificate_key "%%s";\n' "$cert" "$key" > "$dir/tls.conf"
+`
+
+// WriteTLSExecD writes the exec.d executable which writes the TLS config of nginx at launch to the
+// given exec.d directory of a launch layer.
+func WriteTLSExecD(execDir string) error {
+	if err := os.MkdirAll(execDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(execDir, TLSExecD)
+	if err := os.WriteFile(path, []byte(tlsScript(tlsDir)), 0755); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// tlsScript returns the exec.d executable writing the TLS config to dir.
+func tlsScript(dir string) string {
+	return fmt.Sprintf(tlsExecDTemplate, dir, TLSCertificateEnv, TLSCertificateKeyEnv)
+}
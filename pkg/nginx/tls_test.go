@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestTLSScript(t *testing.T) {
+	certs := t.TempDir()
+	cert := filepath.Join(certs, "tls.crt")
+	key := filepath.Join(certs, "tls.key")
+	for _, f := range []string{cert, key} {
+		if err := os.WriteFile(f, []byte("pem"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testCases := []struct {
+		name    string
+		cert    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "readable files",
+			cert: cert,
+			key:  key,
+			want: "ssl_certificate \"" + cert + "\";\nssl_certificate_key \"" + key + "\";\n",
+		},
+		{
+			name:    "missing key",
+			cert:    cert,
+			key:     filepath.Join(certs, "missing.key"),
+			wantErr: true,
+		},
+		{
+			name:    "unset",
+			wantErr: true,
+		},
+		{
+			name:    "quote",
+			cert:    cert + `"`,
+			key:     key,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "tls")
+			script := filepath.Join(t.TempDir(), TLSExecD)
+			if err := os.WriteFile(script, []byte(tlsScript(dir)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("sh", script)
+			cmd.Env = append(os.Environ(), TLSCertificateEnv+"="+tc.cert, TLSCertificateKeyEnv+"="+tc.key)
+			out, err := cmd.CombinedOutput()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("running %s got error: %v, want error: %t\n%s", TLSExecD, err, tc.wantErr, out)
+			}
+			if tc.wantErr {
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(dir, "tls.conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("tls.conf = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
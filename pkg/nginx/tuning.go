@@ -0,0 +1,114 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// TuningExecD is the name of the exec.d executable which writes the nginx tuning config at launch.
+	TuningExecD = "nginx-tuning"
+	// tuningDir is the directory the tuning config is written to at launch. StaticSiteTemplate
+	// includes the files in its main/, events/ and server/ subdirectories.
+	tuningDir = "/tmp/nginx_tuning"
+	// cgroupRoot is the mount point of the cgroup filesystem the container limits are read from.
+	cgroupRoot = "/sys/fs/cgroup"
+)
+
+// cgroupLimitsScript sets $cpus to the CPU quota of the container and $memory_mib to its memory
+// limit in MiB, or 0 if there is none, from the cgroup filesystem mounted at $cgroup. It supports
+// cgroup v2 and v1 and falls back to the CPUs available to the process when there is no quota.
+const cgroupLimitsScript = `cpus=$(nproc 2>/dev/null || echo 1)
+quota=
+if [ -r "$cgroup/cpu.max" ]; then
+  read -r quota period < "$cgroup/cpu.max"
+elif [ -r "$cgroup/cpu/cpu.cfs_quota_us" ]; then
+  quota=$(cat "$cgroup/cpu/cpu.cfs_quota_us")
+  period=$(cat "$cgroup/cpu/cpu.cfs_period_us")
+fi
+case "$quota" in
+  ''|max|-*|*[!0-9]*) ;;
+  *)
+    limit=$(( (quota + period - 1) / period ))
+    if [ "$limit" -ge 1 ] && [ "$limit" -lt "$cpus" ]; then
+      cpus=$limit
+    fi
+    ;;
+esac
+
+memory=
+if [ -r "$cgroup/memory.max" ]; then
+  memory=$(cat "$cgroup/memory.max")
+elif [ -r "$cgroup/memory/memory.limit_in_bytes" ]; then
+  memory=$(cat "$cgroup/memory/memory.limit_in_bytes")
+fi
+case "$memory" in
+  ''|max|*[!0-9]*) memory_mib=0 ;;
+  *) memory_mib=$(( memory / 1048576 )) ;;
+esac
+`
+
+// tuningExecDTemplate is an exec.d executable which derives the number of nginx workers from the
+// CPU quota of the container, and the connections per worker and keepalive timeout from its memory
+// limit, so that the same image is sized for the instance it runs on. Failures leave the nginx
+// defaults in place instead of preventing the container from starting.
+const tuningExecDTemplate = `#!/bin/sh
+cgroup=%[2]s
+` + cgroupLimitsScript + `
+# About 4 connections per MiB of memory, shared by the workers.
+connections=1024
+keepalive=620s
+if [ "$memory_mib" -gt 0 ] && [ "$memory_mib" -lt 4194304 ]; then
+  connections=$(( memory_mib * 4 / cpus ))
+  # Idle connections hold buffers, release them sooner on small instances.
+  if [ "$memory_mib" -lt 512 ]; then
+    keepalive=65s
+  fi
+fi
+if [ "$connections" -lt 512 ]; then
+  connections=512
+elif [ "$connections" -gt 8192 ]; then
+  connections=8192
+fi
+
+dir=%[1]s
+mkdir -p "$dir/main" "$dir/events" "$dir/server" || exit 0
+echo "worker_processes $cpus;
+worker_rlimit_nofile $(( connections * 2 ));" > "$dir/main/workers.conf"
+echo "worker_connections $connections;" > "$dir/events/connections.conf"
+echo "keepalive_timeout $keepalive;" > "$dir/server/keepalive.conf"
+`
+
+// WriteTuningExecD writes the exec.d executable which tunes nginx to the CPU and memory of the
+// container at launch to the given exec.d directory of a launch layer.
+func WriteTuningExecD(execDir string) error {
+	if err := os.MkdirAll(execDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(execDir, TuningExecD)
+	if err := os.WriteFile(path, []byte(tuningScript(tuningDir, cgroupRoot)), 0755); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// tuningScript returns the exec.d executable writing the tuning config to dir from the limits in
+// the cgroup filesystem mounted at cgroup.
+func tuningScript(dir, cgroup string) string {
+	return fmt.Sprintf(tuningExecDTemplate, dir, cgroup)
+}
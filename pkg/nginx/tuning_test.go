@@ -0,0 +1,127 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTuningScript(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cgroup map[string]string
+		want   map[string]string
+	}{
+		{
+			name: "cgroup v2",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "1073741824\n",
+			},
+			want: map[string]string{
+				"main/workers.conf":       "worker_processes 1;\nworker_rlimit_nofile 8192;\n",
+				"events/connections.conf": "worker_connections 4096;\n",
+				"server/keepalive.conf":   "keepalive_timeout 620s;\n",
+			},
+		},
+		{
+			name: "cgroup v1 with small memory",
+			cgroup: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "50000\n",
+				"cpu/cpu.cfs_period_us":        "100000\n",
+				"memory/memory.limit_in_bytes": "268435456\n",
+			},
+			want: map[string]string{
+				"main/workers.conf":       "worker_processes 1;\nworker_rlimit_nofile 2048;\n",
+				"events/connections.conf": "worker_connections 1024;\n",
+				"server/keepalive.conf":   "keepalive_timeout 65s;\n",
+			},
+		},
+		{
+			name: "connections capped",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "34359738368\n",
+			},
+			want: map[string]string{
+				"main/workers.conf":       "worker_processes 1;\nworker_rlimit_nofile 16384;\n",
+				"events/connections.conf": "worker_connections 8192;\n",
+				"server/keepalive.conf":   "keepalive_timeout 620s;\n",
+			},
+		},
+		{
+			name: "no memory limit",
+			cgroup: map[string]string{
+				"cpu.max":    "100000 100000\n",
+				"memory.max": "max\n",
+			},
+			want: map[string]string{
+				"main/workers.conf":       "worker_processes 1;\nworker_rlimit_nofile 2048;\n",
+				"events/connections.conf": "worker_connections 1024;\n",
+				"server/keepalive.conf":   "keepalive_timeout 620s;\n",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cgroup := t.TempDir()
+			for name, content := range tc.cgroup {
+				path := filepath.Join(cgroup, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dir := filepath.Join(t.TempDir(), "nginx_tuning")
+
+			if out, err := exec.Command("sh", "-c", tuningScript(dir, cgroup)).CombinedOutput(); err != nil {
+				t.Fatalf("running the tuning script: %v\n%s", err, out)
+			}
+
+			got := map[string]string{}
+			for name := range tc.want {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[name] = string(content)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("tuning config mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteTuningExecD(t *testing.T) {
+	execDir := filepath.Join(t.TempDir(), "exec.d")
+	if err := WriteTuningExecD(execDir); err != nil {
+		t.Fatalf("WriteTuningExecD() got error: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(execDir, TuningExecD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("%s has mode %v, want an executable", TuningExecD, info.Mode())
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+	"github.com/buildpacks/libcnb"
+	"github.com/Masterminds/semver"
+)
+
+var (
+	// adaptorVersionKey is the metadata key used to store the build adaptor version in the adaptor layer.
+	adaptorVersionKey = "version"
+	// adaptorPackageKey is the metadata key used to store the build adaptor package in the adaptor layer.
+	adaptorPackageKey = "adaptor"
+
+	// frameworkAdapters are the registered framework adapters, in detection order.
+	frameworkAdapters []FrameworkAdapter
+)
+
+// FrameworkAdapter integrates a framework with its App Hosting build adaptor.
+type FrameworkAdapter interface {
+	// Name returns the name of the framework, for example `nuxt`.
+	Name() string
+	// Detect returns true if the application uses the framework.
+	Detect(ctx *gcp.Context, pjs *PackageJSON) (bool, error)
+	// ResolveVersion returns the installed version of the framework. It returns a user error if the
+	// version is not supported.
+	ResolveVersion(ctx *gcp.Context, pjs *PackageJSON) (string, error)
+	// Install installs the build adaptor for the given framework version in the layer.
+	Install(ctx *gcp.Context, l *libcnb.Layer, version string) error
+	// OverrideBuild replaces the build script of the application by the adaptor build script if
+	// the application uses the default build script of the framework. It returns false if the
+	// build script of the application is kept, in which case the adaptor is not installed.
+	OverrideBuild(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON) (bool, error)
+}
+
+// RegisterFrameworkAdapter adds a framework adapter to the registry. It panics if an adapter with
+// the same name is already registered.
+func RegisterFrameworkAdapter(a FrameworkAdapter) {
+	if _, ok := FrameworkAdapterByName(a.Name()); ok {
+		panic(fmt.Sprintf("framework adapter %q registered twice", a.Name()))
+	}
+	frameworkAdapters = append(frameworkAdapters, a)
+}
+
+// FrameworkAdapters returns the registered framework adapters, in registration order.
+func FrameworkAdapters() []FrameworkAdapter {
+	return append([]FrameworkAdapter(nil), frameworkAdapters...)
+}
+
+// FrameworkAdapterByName returns the registered framework adapter with the given name.
+func FrameworkAdapterByName(name string) (FrameworkAdapter, bool) {
+	for _, a := range frameworkAdapters {
+		if a.Name() == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// DetectFrameworkAdapter returns the first registered framework adapter with one of the given
+// names, or of any name if none is given, which is detected for the application. A framework
+// pinned with env.Framework is returned without detection. It returns nil if the application
+// does not use any of the frameworks.
+func DetectFrameworkAdapter(ctx *gcp.Context, pjs *PackageJSON, names ...string) (FrameworkAdapter, error) {
+	pinned := strings.ToLower(strings.TrimSpace(os.Getenv(env.Framework)))
+	for _, a := range frameworkAdapters {
+		if len(names) > 0 && !slices.Contains(names, a.Name()) {
+			continue
+		}
+		if a.Name() == pinned {
+			return a, nil
+		}
+	}
+	for _, a := range frameworkAdapters {
+		if len(names) > 0 && !slices.Contains(names, a.Name()) {
+			continue
+		}
+		ok, err := a.Detect(ctx, pjs)
+		if err != nil {
+			return nil, fmt.Errorf("detecting %s: %w", a.Name(), err)
+		}
+		if ok {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+// ApplyFrameworkAdapter resolves the framework version of the application and, if the application
+// uses the default build script of the framework, installs the build adaptor in the npm_modules
+// layer and overrides the build script.
+func ApplyFrameworkAdapter(ctx *gcp.Context, a FrameworkAdapter, pjs *PackageJSON) error {
+	version, err := a.ResolveVersion(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	l, err := ctx.Layer("npm_modules", gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return err
+	}
+	override, err := a.OverrideBuild(ctx, l, pjs)
+	if err != nil || !override {
+		return err
+	}
+	return a.Install(ctx, l, version)
+}
+
+// NpmFrameworkAdapter is a FrameworkAdapter for frameworks whose build adaptor is an npm package
+// versioned after the major and minor version of the framework.
+type NpmFrameworkAdapter struct {
+	// Framework is the name of the framework.
+	Framework string
+	// DetectPackages are the packages whose presence in package.json identifies the framework.
+	DetectPackages []string
+	// ConfigFiles are the framework config files which identify the framework.
+	ConfigFiles []string
+	// VersionPackage is the package the framework version is read from.
+	VersionPackage string
+	// MinVersion is the lowest supported version of the framework.
+	MinVersion *semver.Version
+	// AdaptorPackage is the npm package of the build adaptor.
+	AdaptorPackage string
+	// AdaptorBuildScript is the executable of the build adaptor package which builds the application.
+	AdaptorBuildScript string
+	// BuildScripts are the default build scripts of the framework, which are replaced by the
+	// adaptor build script.
+	BuildScripts []string
+}
+
+// Name returns the name of the framework.
+func (a *NpmFrameworkAdapter) Name() string {
+	return a.Framework
+}
+
+// Detect returns true if one of the config files exists or one of the detect packages is a
+// dependency of the application.
+func (a *NpmFrameworkAdapter) Detect(ctx *gcp.Context, pjs *PackageJSON) (bool, error) {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range a.ConfigFiles {
+		exists, err := ctx.FileExists(appDir, f)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	if pjs == nil {
+		return false, nil
+	}
+	for _, pkg := range a.DetectPackages {
+		if hasDependency(pjs, pkg) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ResolveVersion returns the installed version of the version package.
+func (a *NpmFrameworkAdapter) ResolveVersion(ctx *gcp.Context, pjs *PackageJSON) (string, error) {
+	if pjs == nil {
+		return "", gcp.UserErrorf("package.json not found, a %s application must declare %s as a dependency", a.Framework, a.VersionPackage)
+	}
+	depVersion, err := Version(ctx, pjs, a.VersionPackage)
+	if err != nil {
+		return "", err
+	}
+	version, err := semver.NewVersion(depVersion)
+	if err != nil {
+		return "", gcp.InternalErrorf("parsing %s version: %v", a.Framework, err)
+	}
+	if a.MinVersion != nil && version.LessThan(a.MinVersion) {
+		ctx.Warnf("Unsupported version of %s: %s", a.Framework, depVersion)
+		ctx.Warnf("Update the %s dependencies to >=%s", a.VersionPackage, a.MinVersion.String())
+		return "", gcp.UserErrorf("unsupported version of %s %s", a.Framework, depVersion)
+	}
+	return depVersion, nil
+}
+
+// Install installs the build adaptor in the given layer if it is not already cached.
+func (a *NpmFrameworkAdapter) Install(ctx *gcp.Context, l *libcnb.Layer, frameworkVersion string) error {
+	layerName := l.Name
+	version, err := resolveAdaptorVersion(ctx, a.Framework, frameworkVersion, AdaptorVersion)
+	if err != nil {
+		return err
+	}
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	metaVersion := ctx.GetMetadata(l, adaptorVersionKey)
+	if version == metaVersion && a.AdaptorPackage == ctx.GetMetadata(l, adaptorPackageKey) && cachedAdaptorValid(ctx, l, a.AdaptorPackage) {
+		ctx.CacheHit(layerName)
+		ctx.Logf("%s adaptor cache hit: %q, %q, skipping installation.", a.Framework, version, metaVersion)
+	} else {
+		ctx.CacheMiss(layerName)
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", layerName, err)
+		}
+		// Download and install the adaptor in layer.
+		ctx.Logf("Installing %s adaptor %s", a.Framework, version)
+		if err := a.download(ctx, l.Path, version); err != nil {
+			return gcp.InternalErrorf("downloading %s adapter: %w", a.Framework, err)
+		}
+	}
+	if err := recordAdaptorIntegrity(ctx, l, a.AdaptorPackage); err != nil {
+		return err
+	}
+
+	// Store layer flags and metadata.
+	ctx.SetMetadata(l, adaptorVersionKey, version)
+	ctx.SetMetadata(l, adaptorPackageKey, a.AdaptorPackage)
+	return nil
+}
+
+// download downloads the build adaptor into the provided directory.
+func (a *NpmFrameworkAdapter) download(ctx *gcp.Context, dirPath, version string) error {
+	return installAdaptor(ctx, dirPath, a.Framework, a.AdaptorPackage, version)
+}
+
+// OverrideBuild overrides the build script to be the adaptor build script if the application uses
+// one of the default build scripts of the framework.
+func (a *NpmFrameworkAdapter) OverrideBuild(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON) (bool, error) {
+	buildScript, exists := pjs.Scripts["build"]
+	if !exists {
+		return false, nil
+	}
+	for _, s := range a.BuildScripts {
+		if buildScript == s {
+			a.overrideBuildScript(l)
+			return true, nil
+		}
+	}
+	if buildScript != a.AdaptorBuildScript {
+		ctx.Warnf("*** You are using a custom build command (your build command is NOT '%s'), we will accept it as is but some features will not be enabled ***", a.BuildScripts[0])
+	}
+	return false, nil
+}
+
+// overrideBuildScript sets the build script of the application to the adaptor build script.
+func (a *NpmFrameworkAdapter) overrideBuildScript(l *libcnb.Layer) {
+	// This env var indicates to the package manager buildpack that a different command needs to be run
+	l.BuildEnvironment.Override(AppHostingBuildEnv, fmt.Sprintf("npm exec --prefix %s %s", l.Path, a.AdaptorBuildScript))
+}
+
+// AdaptorVersion determines the version of a build adaptor which is versioned after the major and
+// minor version of its framework.
+func AdaptorVersion(frameworkVersion string) (string, error) {
+	// match major + minor versions with the framework version
+	adapterVersion, err := version.MajorMinor(frameworkVersion)
+	if err != nil {
+		return "", gcp.InternalErrorf("parsing framework version: %w", err)
+	}
+	return adapterVersion, nil
+}
+
+func hasDependency(pjs *PackageJSON, pkg string) bool {
+	if _, ok := pjs.Dependencies[pkg]; ok {
+		return true
+	}
+	_, ok := pjs.DevDependencies[pkg]
+	return ok
+}
@@ -0,0 +1,487 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFrameworkAdapters(t *testing.T) {
+	var got []string
+	for _, a := range FrameworkAdapters() {
+		got = append(got, a.Name())
+	}
+	want := []string{"angular", "astro", "nextjs", "nuxt", "remix", "react-router", "sveltekit"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FrameworkAdapters() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegisterFrameworkAdapterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterFrameworkAdapter() did not panic for a duplicate name")
+		}
+	}()
+	RegisterFrameworkAdapter(&NpmFrameworkAdapter{Framework: "nuxt"})
+}
+
+func TestDetectFrameworkAdapter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		pjs   *PackageJSON
+		names []string
+		env   string
+		want  string
+	}{
+		{
+			name:  "nuxt config",
+			files: map[string]string{"nuxt.config.ts": ""},
+			want:  "nuxt",
+		},
+		{
+			name: "sveltekit dev dependency",
+			pjs:  &PackageJSON{DevDependencies: map[string]string{"@sveltejs/kit": "^2.5.0"}},
+			want: "sveltekit",
+		},
+		{
+			name: "remix",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"@remix-run/node": "^2.8.1", "@remix-run/react": "^2.8.1"}},
+			want: "remix",
+		},
+		{
+			name: "react router framework mode",
+			pjs: &PackageJSON{
+				Dependencies:    map[string]string{"react-router": "^7.1.0"},
+				DevDependencies: map[string]string{"@react-router/dev": "^7.1.0"},
+			},
+			want: "react-router",
+		},
+		{
+			name: "react router library",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"react-router": "^7.1.0"}},
+		},
+		{
+			name:  "angular config",
+			files: map[string]string{"angular.json": "{}"},
+			want:  "angular",
+		},
+		{
+			name:  "next config",
+			files: map[string]string{"next.config.mjs": ""},
+			want:  "nextjs",
+		},
+		{
+			name:  "astro config",
+			files: map[string]string{"astro.config.mjs": ""},
+			want:  "astro",
+		},
+		{
+			name:  "filtered by name",
+			files: map[string]string{"nuxt.config.ts": ""},
+			pjs:   &PackageJSON{Dependencies: map[string]string{"@remix-run/node": "^2.8.1"}},
+			names: []string{"remix", "react-router"},
+			want:  "remix",
+		},
+		{
+			name:  "other framework not detected",
+			files: map[string]string{"angular.json": "{}"},
+			names: []string{"nextjs"},
+		},
+		{
+			name:  "pinned framework",
+			pjs:   &PackageJSON{Dependencies: map[string]string{"react-router": "^7.1.0"}},
+			names: []string{"remix", "react-router"},
+			env:   "React-Router",
+			want:  "react-router",
+		},
+		{
+			name:  "pinned framework of another buildpack",
+			files: map[string]string{"nuxt.config.ts": ""},
+			names: []string{"nuxt"},
+			env:   "nextjs",
+			want:  "nuxt",
+		},
+		{
+			name: "no package.json",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.Framework, tc.env)
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			a, err := DetectFrameworkAdapter(ctx, tc.pjs, tc.names...)
+			if err != nil {
+				t.Fatalf("DetectFrameworkAdapter() got error: %v", err)
+			}
+			var got string
+			if a != nil {
+				got = a.Name()
+			}
+			if got != tc.want {
+				t.Errorf("DetectFrameworkAdapter() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNpmFrameworkAdapterResolveVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		framework   string
+		packageLock string
+		pjs         *PackageJSON
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:      "supported version",
+			framework: "remix",
+			packageLock: `{
+				"packages": {
+					"node_modules/@remix-run/node": {
+						"version": "2.8.1"
+					}
+				}
+			}`,
+			pjs:  &PackageJSON{Dependencies: map[string]string{"@remix-run/node": "^2.8.0"}},
+			want: "2.8.1",
+		},
+		{
+			name:      "unsupported version",
+			framework: "remix",
+			packageLock: `{
+				"packages": {
+					"node_modules/@remix-run/node": {
+						"version": "1.19.3"
+					}
+				}
+			}`,
+			pjs:     &PackageJSON{Dependencies: map[string]string{"@remix-run/node": "1.19.3"}},
+			wantErr: true,
+		},
+		{
+			name:      "missing package.json",
+			framework: "nuxt",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.packageLock != "" {
+				if err := os.WriteFile(filepath.Join(dir, "package-lock.json"), []byte(tc.packageLock), 0644); err != nil {
+					t.Fatalf("writing package-lock.json: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			a, _ := FrameworkAdapterByName(tc.framework)
+
+			got, err := a.ResolveVersion(ctx, tc.pjs)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ResolveVersion() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNpmFrameworkAdapterInstall(t *testing.T) {
+	testCases := []struct {
+		name             string
+		framework        string
+		layerMetadata    map[string]any
+		frameworkVersion string
+		env              map[string]string
+		mocks            []*mockprocess.Mock
+		wantMetadata     string
+		wantErr          bool
+	}{
+		{
+			name:             "download nuxt v3.11 adaptor succeeds",
+			framework:        "nuxt",
+			frameworkVersion: "3.11.2",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nuxt@3.11`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nuxt-3.11.0.tgz","version":"3.11.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{},
+			wantMetadata:  "3.11",
+		},
+		{
+			name:             "download react router v7.1 adaptor succeeds",
+			framework:        "react-router",
+			frameworkVersion: "7.1.3",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-react-router@7.1`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-react-router-7.1.0.tgz","version":"7.1.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{},
+			wantMetadata:  "7.1",
+		},
+		{
+			name:             "download adaptor not needed since it is cached",
+			framework:        "sveltekit",
+			frameworkVersion: "2.5.0",
+			layerMetadata:    map[string]any{"version": "2.5", "adaptor": "@apphosting/adapter-sveltekit"},
+			wantMetadata:     "2.5",
+		},
+		{
+			name:             "switching framework reinstalls adaptor",
+			framework:        "react-router",
+			frameworkVersion: "7.0.2",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-react-router@7.0`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-react-router-7.0.0.tgz","version":"7.0.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{"version": "7.0", "adaptor": "@apphosting/adapter-remix"},
+			wantMetadata:  "7.0",
+		},
+		{
+			name:             "download invalid adaptor falls back to latest",
+			framework:        "sveltekit",
+			frameworkVersion: "9.0.0",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-sveltekit@9.0`, mockprocess.WithStderr("installed adapter failed"), mockprocess.WithExitCode(1)),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-sveltekit@latest`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-sveltekit-2.5.0.tgz","version":"2.5.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{"version": "2.5", "adaptor": "@apphosting/adapter-sveltekit"},
+			wantMetadata:  "9.0",
+		},
+		{
+			name:             "download angular v17.2 adaptor succeeds",
+			framework:        "angular",
+			frameworkVersion: "17.2.0",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-angular@17.2`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-angular-17.2.0.tgz","version":"17.2.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{},
+			wantMetadata:  "17.2",
+		},
+		{
+			name:             "download astro v4.5 adaptor succeeds",
+			framework:        "astro",
+			frameworkVersion: "4.5.9",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-astro@4.5`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-astro-4.5.0.tgz","version":"4.5.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{},
+			wantMetadata:  "4.5",
+		},
+		{
+			name:             "download nextjs v13.0 adaptor succeeds",
+			framework:        "nextjs",
+			frameworkVersion: "13.0.1-canary",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@13.0`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-13.0.0.tgz","version":"13.0.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{},
+			wantMetadata:  "13.0",
+		},
+		{
+			name:             "pinned adaptor version bypasses the derived version",
+			framework:        "nextjs",
+			frameworkVersion: "15.1.0",
+			env:              map[string]string{"GOOGLE_NEXTJS_ADAPTER_VERSION": "14.0.7"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@14.0.7`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-14.0.7.tgz","version":"14.0.7","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{"version": "15.1", "adaptor": "@apphosting/adapter-nextjs"},
+			wantMetadata:  "14.0.7",
+		},
+		{
+			name:             "pinned adaptor version does not fall back to latest",
+			framework:        "nextjs",
+			frameworkVersion: "15.1.0",
+			env:              map[string]string{"GOOGLE_NEXTJS_ADAPTER_VERSION": "14.0.99"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@14.0.99`, mockprocess.WithStderr("No matching version"), mockprocess.WithExitCode(1)),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@latest`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-15.0.0.tgz","version":"15.0.0","integrity":"sha512-adaptor"}]`)),
+			},
+			layerMetadata: map[string]any{},
+			wantErr:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			ctx := gcp.NewContext(append(getContextOpts(t, tc.mocks), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))...)
+			layer := &libcnb.Layer{
+				Name:     "npm_modules",
+				Path:     t.TempDir(),
+				Metadata: tc.layerMetadata,
+			}
+			a, _ := FrameworkAdapterByName(tc.framework)
+			err := a.Install(ctx, layer, tc.frameworkVersion)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Install() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got := ctx.GetMetadata(layer, "version"); got != tc.wantMetadata {
+				t.Errorf("Install() layer version = %q, want %q", got, tc.wantMetadata)
+			}
+			if got, want := ctx.GetMetadata(layer, "adaptor"), npmAdapter(t, a).AdaptorPackage; got != want {
+				t.Errorf("Install() layer adaptor = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNpmFrameworkAdapterOverrideBuild(t *testing.T) {
+	testCases := []struct {
+		name        string
+		framework   string
+		files       map[string]string
+		buildScript string
+		want        string
+	}{
+		{
+			name:        "default build script",
+			framework:   "nuxt",
+			buildScript: "nuxi build",
+			want:        "npm exec --prefix /layers/npm_modules apphosting-adapter-nuxt-build",
+		},
+		{
+			name:        "second default build script",
+			framework:   "remix",
+			buildScript: "remix build",
+			want:        "npm exec --prefix /layers/npm_modules apphosting-adapter-remix-build",
+		},
+		{
+			name:        "custom build script",
+			framework:   "sveltekit",
+			buildScript: "npm run css && vite build",
+		},
+		{
+			name:      "no build script",
+			framework: "sveltekit",
+		},
+		{
+			name:        "angular custom build script",
+			framework:   "angular",
+			buildScript: "ng build --configuration production",
+			want:        "npm exec --prefix /layers/npm_modules apphosting-adapter-angular-build",
+		},
+		{
+			name:        "astro server output",
+			framework:   "astro",
+			files:       map[string]string{"astro.config.mjs": `export default defineConfig({ output: 'server' });`},
+			buildScript: "astro build",
+			want:        "npm exec --prefix /layers/npm_modules apphosting-adapter-astro-build",
+		},
+		{
+			name:        "astro static output",
+			framework:   "astro",
+			files:       map[string]string{"astro.config.mjs": `export default defineConfig({});`},
+			buildScript: "astro build",
+		},
+		{
+			name:        "nextjs server",
+			framework:   "nextjs",
+			files:       map[string]string{"next.config.js": `module.exports = { output: 'standalone' }`},
+			buildScript: "next build",
+			want:        "npm exec --prefix /layers/npm_modules apphosting-adapter-nextjs-build",
+		},
+		{
+			name:        "nextjs static export",
+			framework:   "nextjs",
+			files:       map[string]string{"next.config.js": `module.exports = { output: 'export' }`},
+			buildScript: "next build",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			pjs := &PackageJSON{Scripts: map[string]string{}}
+			if tc.buildScript != "" {
+				pjs.Scripts["build"] = tc.buildScript
+			}
+			l := &libcnb.Layer{Path: "/layers/npm_modules", Metadata: map[string]any{}, BuildEnvironment: libcnb.Environment{}}
+			a, _ := FrameworkAdapterByName(tc.framework)
+
+			overridden, err := a.OverrideBuild(gcp.NewContext(gcp.WithApplicationRoot(dir)), l, pjs)
+			if err != nil {
+				t.Fatalf("OverrideBuild() got error: %v", err)
+			}
+			if want := tc.want != ""; overridden != want {
+				t.Errorf("OverrideBuild() = %t, want %t", overridden, want)
+			}
+			if got := l.BuildEnvironment[AppHostingBuildEnv+".override"]; got != tc.want {
+				t.Errorf("OverrideBuild() build env = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// npmAdapter returns the NpmFrameworkAdapter a is, or which a framework specific adapter embeds.
+func npmAdapter(t *testing.T, a FrameworkAdapter) *NpmFrameworkAdapter {
+	t.Helper()
+	switch a := a.(type) {
+	case *NpmFrameworkAdapter:
+		return a
+	case *angularAdapter:
+		return a.NpmFrameworkAdapter
+	case *astroAdapter:
+		return a.NpmFrameworkAdapter
+	case *nextjsAdapter:
+		return a.NpmFrameworkAdapter
+	}
+	t.Fatalf("unexpected framework adapter %T", a)
+	return nil
+}
+
+func TestAdaptorVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{version: "3.11.2", want: "3.11"},
+		{version: "1.0.0", want: "1.0"},
+		{version: "^2.0.0", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			got, err := AdaptorVersion(tc.version)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AdaptorVersion(%q) got error: %v, want error: %v", tc.version, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("AdaptorVersion(%q) = %q, want %q", tc.version, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+// AdaptorDirEnv is an env var pointing to a local directory containing build adaptor tarballs, as
+// generated by `npm pack`. Adaptors found there are installed from disk instead of the npm
+// registry, which allows building without network access. The dependencies of an adaptor are
+// installed from the tarballs in the directory named after its tarball, unless the adaptor
+// bundles them with bundleDependencies.
+// Example: `/workspace/vendor/adapters` containing `apphosting-adapter-nextjs-14.0.3.tgz` and the
+// dependency tarballs in `apphosting-adapter-nextjs-14.0.3/`.
+const AdaptorDirEnv = "GOOGLE_NODEJS_ADAPTER_DIR"
+
+// adaptorVersionSetting is the name of the setting recorded when an adaptor version is pinned.
+const adaptorVersionSetting = "adaptor_version"
+
+// npmrc is the npm config file of the application. It is not read by npm when installing into a
+// layer with --prefix, so it is passed explicitly to allow installing adaptors from a private
+// registry or mirror, such as Artifact Registry.
+const npmrc = ".npmrc"
+
+// bundledAdaptorDir is the directory of adaptor tarballs bundled in the builder image. It is
+// searched after AdaptorDirEnv.
+var bundledAdaptorDir = "/usr/local/share/google/buildpacks/adapters"
+
+// fallbackAdaptorDir is the directory of the buildpack containing the last known good adaptor
+// tarball of each supported major version. They are only installed when the npm registry fails.
+const fallbackAdaptorDir = "adapters"
+
+// AdaptorVersionEnv returns the env var which pins the version of the build adaptor of the given
+// framework, bypassing the version derived from the framework version. It accepts any version or
+// tag understood by npm and is an escape hatch for regressions in a newly published adaptor.
+// Example: `GOOGLE_NEXTJS_ADAPTER_VERSION=14.0.7` or `GOOGLE_REACT_ROUTER_ADAPTER_VERSION=7.0.1`.
+func AdaptorVersionEnv(framework string) string {
+	return "GOOGLE_" + strings.ToUpper(strings.ReplaceAll(framework, "-", "_")) + "_ADAPTER_VERSION"
+}
+
+// pinnedAdaptorVersion returns the adaptor version of the framework pinned with AdaptorVersionEnv.
+func pinnedAdaptorVersion(ctx *gcp.Context, framework string) (string, bool) {
+	name := AdaptorVersionEnv(framework)
+	version := strings.TrimSpace(os.Getenv(name))
+	if version == "" {
+		return "", false
+	}
+	ctx.Logf("Using %s adaptor version %s set in %s", framework, version, name)
+	ctx.RecordSetting(adaptorVersionSetting, version, gcp.SourceEnv)
+	return version, true
+}
+
+// resolveAdaptorVersion returns the adaptor version of the framework pinned with AdaptorVersionEnv,
+// or the one derived from the framework version otherwise.
+func resolveAdaptorVersion(ctx *gcp.Context, framework, frameworkVersion string, derive func(string) (string, error)) (string, error) {
+	if version, ok := pinnedAdaptorVersion(ctx, framework); ok {
+		return version, nil
+	}
+	return derive(frameworkVersion)
+}
+
+// installAdaptor installs version of the adaptor package pkg into dirPath. A tarball of the adaptor
+// from AdaptorDirEnv or the builder image is preferred, the npm registry is only used as a
+// fallback, first for the requested version and then for the latest one unless the version is
+// pinned with AdaptorVersionEnv. The tarball bundled in the buildpack is the last resort. The
+// digest of the adaptor is verified before it is installed, so that the lifecycle scripts of a
+// tampered adaptor never run.
+func installAdaptor(ctx *gcp.Context, dirPath, framework, pkg, version string) error {
+	npmEnv, err := adaptorNpmEnv(ctx)
+	if err != nil {
+		return err
+	}
+	npmEnv = append(npmEnv, PackageManagerConfigEnv(ctx, "npm")...)
+	if registry := os.Getenv("NPM_CONFIG_REGISTRY"); registry != "" {
+		ctx.Logf("Using npm registry %s set in NPM_CONFIG_REGISTRY", registry)
+	}
+	tarball, err := localAdaptorTarball(ctx, pkg, version)
+	if err != nil {
+		return err
+	}
+	if tarball != "" {
+		if err := verifyAdaptorTarball(ctx, pkg, tarball); err != nil {
+			return err
+		}
+		ctx.Logf("Installing %s adaptor from %s", framework, tarball)
+		err := installAdaptorTarball(ctx, dirPath, tarball, npmEnv)
+		if err == nil {
+			return nil
+		}
+		ctx.Warnf("Failed to install %s adaptor from %s, falling back to the npm registry: %v", framework, tarball, err)
+	}
+	packed, err := packAdaptor(ctx, pkg, version, npmEnv)
+	if err != nil {
+		// A pinned version must never be silently replaced by the latest one.
+		if name := AdaptorVersionEnv(framework); os.Getenv(name) != "" {
+			return gcp.UserErrorf("installing %s adaptor version %s set in %s: %w", framework, version, name, err)
+		}
+		ctx.Logf("Failed to install %s adaptor version: %s. Falling back to latest", framework, version)
+		packed, err = packAdaptor(ctx, pkg, "latest", npmEnv)
+		if err != nil {
+			if installed, fallbackErr := installFallbackAdaptor(ctx, dirPath, framework, pkg, version, npmEnv); installed || fallbackErr != nil {
+				return fallbackErr
+			}
+			return gcp.InternalErrorf("installing %s adaptor, if the npm registry is not reachable configure a mirror in %s or NPM_CONFIG_REGISTRY, or provide the adaptor in %s: %w", framework, npmrc, AdaptorDirEnv, err)
+		}
+	}
+	if err := checkAdaptorIntegrity(ctx, pkg, packed.Version, packed.Integrity, "the npm registry"); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"npm", "install", "--prefix", dirPath, "--no-audit", "--no-fund", packed.Path}, gcp.WithEnv(npmEnv...)); err != nil {
+		return gcp.InternalErrorf("installing %s adaptor from %s: %w", framework, packed.Path, err)
+	}
+	return nil
+}
+
+// packedAdaptor is an adaptor tarball downloaded from the npm registry with `npm pack`.
+type packedAdaptor struct {
+	// Path is the path of the tarball.
+	Path string
+	// Version is the version of the adaptor.
+	Version string
+	// Integrity is the digest of the tarball, in the format of the integrity field of package-lock.json.
+	Integrity string
+}
+
+// packAdaptor downloads the tarball of version of the adaptor package pkg from the npm registry
+// without installing it.
+func packAdaptor(ctx *gcp.Context, pkg, version string, npmEnv []string) (*packedAdaptor, error) {
+	dir, err := ctx.TempDir("adaptor-pack")
+	if err != nil {
+		return nil, err
+	}
+	result, err := ctx.Exec([]string{"npm", "pack", "--json", "--pack-destination", dir, pkg + "@" + version}, gcp.WithEnv(npmEnv...))
+	if err != nil {
+		return nil, err
+	}
+	var packed []struct {
+		Filename  string `json:"filename"`
+		Version   string `json:"version"`
+		Integrity string `json:"integrity"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &packed); err != nil || len(packed) != 1 {
+		return nil, fmt.Errorf("parsing the output of npm pack %s@%s: %q", pkg, version, result.Stdout)
+	}
+	return &packedAdaptor{
+		Path:      filepath.Join(dir, packed[0].Filename),
+		Version:   packed[0].Version,
+		Integrity: packed[0].Integrity,
+	}, nil
+}
+
+// installFallbackAdaptor installs the last known good tarball of the adaptor bundled in the
+// buildpack, so that builds survive registry outages. It returns false if there is none.
+func installFallbackAdaptor(ctx *gcp.Context, dirPath, framework, pkg, version string, npmEnv []string) (bool, error) {
+	tarball, err := fallbackAdaptorTarball(ctx, pkg, version)
+	if err != nil || tarball == "" {
+		return false, err
+	}
+	if err := verifyAdaptorTarball(ctx, pkg, tarball); err != nil {
+		return false, err
+	}
+	ctx.Warnf("The npm registry is not reachable, installing the last known good %s adaptor %s bundled with the buildpack.", framework, filepath.Base(tarball))
+	if err := installAdaptorTarball(ctx, dirPath, tarball, npmEnv); err != nil {
+		return false, gcp.InternalErrorf("installing %s adaptor from %s: %w", framework, tarball, err)
+	}
+	return true, nil
+}
+
+// installAdaptorTarball installs the adaptor tarball into dirPath without network access. The
+// dependency tarballs vendored next to it are installed with it, so that npm does not need the
+// registry to resolve the dependencies of the adaptor.
+func installAdaptorTarball(ctx *gcp.Context, dirPath, tarball string, npmEnv []string) error {
+	deps, err := filepath.Glob(filepath.Join(strings.TrimSuffix(tarball, ".tgz"), "*.tgz"))
+	if err != nil {
+		return gcp.InternalErrorf("listing the dependency tarballs of %s: %w", tarball, err)
+	}
+	cmd := append([]string{"npm", "install", "--prefix", dirPath, "--offline", "--no-audit", "--no-fund", tarball}, deps...)
+	_, err = ctx.Exec(cmd, gcp.WithEnv(npmEnv...))
+	return err
+}
+
+// adaptorNpmEnv returns the environment used to install adaptors. The .npmrc of the application
+// is used as the user config unless one is set explicitly, so that registries, scoped registries
+// and auth tokens configured for the application also apply to the adaptor. Environment variables
+// referenced in .npmrc, such as ${NPM_TOKEN}, are expanded by npm.
+func adaptorNpmEnv(ctx *gcp.Context) ([]string, error) {
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(strings.ToUpper(e), "NPM_CONFIG_USERCONFIG=") {
+			return nil, nil
+		}
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), npmrc)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return []string{"NPM_CONFIG_USERCONFIG=" + filepath.Join(ctx.ApplicationRoot(), npmrc)}, nil
+}
+
+// localAdaptorTarball returns the path of the newest tarball of pkg matching the major and minor
+// version, or the exact version if it has a patch version, in the local adaptor directories, or an
+// empty string if there is none. Tags such as `latest` and ranges such as `^14` pinned with
+// AdaptorVersionEnv are only resolved by the npm registry, so no tarball is returned for them.
+func localAdaptorTarball(ctx *gcp.Context, pkg, version string) (string, error) {
+	want, err := semver.StrictNewVersion(version)
+	exact := err == nil
+	if !exact {
+		want, err = semver.StrictNewVersion(version + ".0")
+	}
+	if err != nil {
+		ctx.Debugf("Adaptor version %q is a tag or a range, skipping the local %s tarballs", version, pkg)
+		return "", nil
+	}
+	var dirs []string
+	if dir := os.Getenv(AdaptorDirEnv); dir != "" {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(ctx.ApplicationRoot(), dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, bundledAdaptorDir)
+
+	for _, dir := range dirs {
+		best, err := newestAdaptorTarball(dir, pkg, func(v *semver.Version) bool {
+			if exact {
+				return v.Equal(want)
+			}
+			return v.Major() == want.Major() && v.Minor() == want.Minor()
+		})
+		if err != nil || best != "" {
+			return best, err
+		}
+		if dir != bundledAdaptorDir {
+			ctx.Debugf("No %s %s tarball found in %s", pkg, version, dir)
+		}
+	}
+	return "", nil
+}
+
+// fallbackAdaptorTarball returns the path of the last known good tarball of pkg with the same major
+// version bundled in the buildpack, or an empty string if there is none.
+func fallbackAdaptorTarball(ctx *gcp.Context, pkg, version string) (string, error) {
+	want, err := semver.NewVersion(version)
+	if err != nil {
+		// The version is a tag such as `latest`, any bundled tarball is better than failing.
+		want = nil
+	}
+	return newestAdaptorTarball(filepath.Join(ctx.BuildpackRoot(), fallbackAdaptorDir), pkg, func(v *semver.Version) bool {
+		return want == nil || v.Major() == want.Major()
+	})
+}
+
+// newestAdaptorTarball returns the path of the tarball of pkg in dir with the highest version
+// accepted by match, or an empty string if there is none.
+func newestAdaptorTarball(dir, pkg string, match func(*semver.Version) bool) (string, error) {
+	prefix := adaptorTarballPrefix(pkg)
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*.tgz"))
+	if err != nil {
+		return "", gcp.InternalErrorf("listing adaptor tarballs in %s: %w", dir, err)
+	}
+	var best string
+	var bestVersion *semver.Version
+	for _, m := range matches {
+		v, err := semver.StrictNewVersion(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), prefix), ".tgz"))
+		if err != nil || !match(v) {
+			continue
+		}
+		if bestVersion == nil || v.GreaterThan(bestVersion) {
+			best, bestVersion = m, v
+		}
+	}
+	return best, nil
+}
+
+// adaptorTarballPrefix returns the prefix of the tarball names of pkg. `npm pack` names the tarball
+// of @scope/name as scope-name-<version>.tgz.
+func adaptorTarballPrefix(pkg string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(pkg, "@"), "/", "-") + "-"
+}
+
+// verifyAdaptorTarball checks the digest of a local adaptor tarball against the pinned manifest
+// before it is installed.
+func verifyAdaptorTarball(ctx *gcp.Context, pkg, tarball string) error {
+	integrity, err := tarballIntegrity(tarball)
+	if err != nil {
+		return gcp.InternalErrorf("verifying %s: %w", tarball, err)
+	}
+	version := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(tarball), adaptorTarballPrefix(pkg)), ".tgz")
+	return checkAdaptorIntegrity(ctx, pkg, version, integrity, tarball)
+}
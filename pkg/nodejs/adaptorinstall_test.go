@@ -0,0 +1,382 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocalAdaptorTarball(t *testing.T) {
+	testCases := []struct {
+		name     string
+		local    []string
+		bundled  []string
+		relative bool
+		version  string
+		want     string
+	}{
+		{
+			name:    "no tarballs",
+			version: "14.0",
+		},
+		{
+			name:    "newest matching patch version",
+			local:   []string{"apphosting-adapter-nextjs-14.0.1.tgz", "apphosting-adapter-nextjs-14.0.12.tgz", "apphosting-adapter-nextjs-14.1.0.tgz"},
+			version: "14.0",
+			want:    "local/apphosting-adapter-nextjs-14.0.12.tgz",
+		},
+		{
+			name:     "directory relative to the application",
+			local:    []string{"apphosting-adapter-nextjs-14.0.1.tgz"},
+			relative: true,
+			version:  "14.0",
+			want:     "local/apphosting-adapter-nextjs-14.0.1.tgz",
+		},
+		{
+			name:    "local directory preferred over bundled",
+			local:   []string{"apphosting-adapter-nextjs-14.0.1.tgz"},
+			bundled: []string{"apphosting-adapter-nextjs-14.0.3.tgz"},
+			version: "14.0",
+			want:    "local/apphosting-adapter-nextjs-14.0.1.tgz",
+		},
+		{
+			name:    "bundled fallback",
+			local:   []string{"apphosting-adapter-nextjs-13.5.0.tgz"},
+			bundled: []string{"apphosting-adapter-nextjs-14.0.3.tgz"},
+			version: "14.0",
+			want:    "bundled/apphosting-adapter-nextjs-14.0.3.tgz",
+		},
+		{
+			name:    "exact version",
+			local:   []string{"apphosting-adapter-nextjs-14.0.1.tgz", "apphosting-adapter-nextjs-14.0.12.tgz"},
+			version: "14.0.1",
+			want:    "local/apphosting-adapter-nextjs-14.0.1.tgz",
+		},
+		{
+			name:    "exact version not found",
+			local:   []string{"apphosting-adapter-nextjs-14.0.12.tgz"},
+			version: "14.0.7",
+		},
+		{
+			name:    "tag uses the registry",
+			local:   []string{"apphosting-adapter-nextjs-14.0.1.tgz"},
+			version: "latest",
+		},
+		{
+			name:    "range uses the registry",
+			local:   []string{"apphosting-adapter-nextjs-14.0.1.tgz"},
+			version: "^14",
+		},
+		{
+			name:    "major version uses the registry",
+			local:   []string{"apphosting-adapter-nextjs-14.0.1.tgz"},
+			version: "14",
+		},
+		{
+			name:    "other adaptors ignored",
+			local:   []string{"apphosting-adapter-nextjs-canary-14.0.1.tgz", "apphosting-adapter-nuxt-14.0.1.tgz"},
+			version: "14.0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for dir, files := range map[string][]string{"local": tc.local, "bundled": tc.bundled} {
+				if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+					t.Fatalf("creating %s: %v", dir, err)
+				}
+				for _, f := range files {
+					if err := os.WriteFile(filepath.Join(root, dir, f), nil, 0644); err != nil {
+						t.Fatalf("writing %s: %v", f, err)
+					}
+				}
+			}
+			if tc.relative {
+				t.Setenv(AdaptorDirEnv, "local")
+			} else {
+				t.Setenv(AdaptorDirEnv, filepath.Join(root, "local"))
+			}
+			defer func(dir string) { bundledAdaptorDir = dir }(bundledAdaptorDir)
+			bundledAdaptorDir = filepath.Join(root, "bundled")
+
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+			got, err := localAdaptorTarball(ctx, "@apphosting/adapter-nextjs", tc.version)
+			if err != nil {
+				t.Fatalf("localAdaptorTarball() got error: %v", err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(root, tc.want)
+			}
+			if got != want {
+				t.Errorf("localAdaptorTarball() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAdaptorVersionEnv(t *testing.T) {
+	testCases := []struct {
+		framework string
+		want      string
+	}{
+		{framework: "nextjs", want: "GOOGLE_NEXTJS_ADAPTER_VERSION"},
+		{framework: "angular", want: "GOOGLE_ANGULAR_ADAPTER_VERSION"},
+		{framework: "react-router", want: "GOOGLE_REACT_ROUTER_ADAPTER_VERSION"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.framework, func(t *testing.T) {
+			if got := AdaptorVersionEnv(tc.framework); got != tc.want {
+				t.Errorf("AdaptorVersionEnv(%q) = %q, want %q", tc.framework, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstallAdaptorFromDisk(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version string
+		deps    []string
+		mocks   []*mockprocess.Mock
+	}{
+		{
+			name:    "installed from tarball",
+			version: "14.0",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm install --prefix npm_modules --offline --no-audit --no-fund .*apphosting-adapter-nextjs-14.0.3.tgz$`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@`, mockprocess.WithExitCode(1)),
+			},
+		},
+		{
+			name:    "installed with vendored dependencies",
+			version: "14.0",
+			deps:    []string{"tslib-2.6.2.tgz", "fs-extra-11.2.0.tgz"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm install --prefix npm_modules --offline --no-audit --no-fund .*/apphosting-adapter-nextjs-14.0.3.tgz .*/apphosting-adapter-nextjs-14.0.3/fs-extra-11.2.0.tgz .*/apphosting-adapter-nextjs-14.0.3/tslib-2.6.2.tgz$`, mockprocess.WithStdout("installed adaptor")),
+				mockprocess.New(`npm install --prefix npm_modules --offline --no-audit --no-fund .*/apphosting-adapter-nextjs-14.0.3.tgz$`, mockprocess.WithExitCode(1)),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@`, mockprocess.WithExitCode(1)),
+			},
+		},
+		{
+			name:    "registry fallback",
+			version: "14.0",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm install --prefix npm_modules --offline --no-audit --no-fund .*apphosting-adapter-nextjs-14.0.3.tgz$`, mockprocess.WithStderr("corrupt tarball"), mockprocess.WithExitCode(1)),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@14.0`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-14.0.0.tgz","version":"14.0.0","integrity":"sha512-adaptor"}]`)),
+			},
+		},
+		{
+			name:    "tag installed from the registry",
+			version: "latest",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@latest`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-15.0.0.tgz","version":"15.0.0","integrity":"sha512-adaptor"}]`)),
+			},
+		},
+		{
+			name:    "range installed from the registry",
+			version: "^14",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@\^14`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-14.2.0.tgz","version":"14.2.0","integrity":"sha512-adaptor"}]`)),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "apphosting-adapter-nextjs-14.0.3.tgz"), nil, 0644); err != nil {
+				t.Fatalf("writing tarball: %v", err)
+			}
+			for _, dep := range tc.deps {
+				depDir := filepath.Join(dir, "apphosting-adapter-nextjs-14.0.3")
+				if err := os.MkdirAll(depDir, 0755); err != nil {
+					t.Fatalf("creating %s: %v", depDir, err)
+				}
+				if err := os.WriteFile(filepath.Join(depDir, dep), nil, 0644); err != nil {
+					t.Fatalf("writing dependency tarball: %v", err)
+				}
+			}
+			t.Setenv(AdaptorDirEnv, dir)
+
+			ctx := gcp.NewContext(append(getContextOpts(t, tc.mocks), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))...)
+			if err := installAdaptor(ctx, "npm_modules", "nextjs", "@apphosting/adapter-nextjs", tc.version); err != nil {
+				t.Errorf("installAdaptor() got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestInstallAdaptorFromRegistry(t *testing.T) {
+	packed := mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@14.0`, mockprocess.WithStdout(`[{"filename":"apphosting-adapter-nextjs-14.0.3.tgz","version":"14.0.3","integrity":"sha512-abc"}]`))
+	testCases := []struct {
+		name     string
+		manifest string
+		mocks    []*mockprocess.Mock
+		wantErr  bool
+	}{
+		{
+			name:     "digest matches the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.3": "sha512-abc"}`,
+			mocks: []*mockprocess.Mock{
+				packed,
+				mockprocess.New(`npm install --prefix npm_modules --no-audit --no-fund .*/adaptor-pack/apphosting-adapter-nextjs-14.0.3.tgz`, mockprocess.WithStdout("installed adaptor")),
+			},
+		},
+		{
+			name:     "digest does not match the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.3": "sha512-def"}`,
+			mocks: []*mockprocess.Mock{
+				packed,
+				mockprocess.New(`npm install --prefix npm_modules --no-audit --no-fund .*/adaptor-pack/apphosting-adapter-nextjs-14.0.3.tgz`, mockprocess.WithStdout("installed adaptor")),
+			},
+			wantErr: true,
+		},
+		{
+			name: "no manifest",
+			mocks: []*mockprocess.Mock{
+				packed,
+				mockprocess.New(`npm install --prefix npm_modules --no-audit --no-fund .*/adaptor-pack/apphosting-adapter-nextjs-14.0.3.tgz`, mockprocess.WithStdout("installed adaptor")),
+			},
+		},
+		{
+			name: "unexpected npm pack output",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@14.0`, mockprocess.WithStdout("[]")),
+				mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@latest`, mockprocess.WithStdout("[]")),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAdaptorIntegrityManifest(t, tc.manifest)
+			t.Setenv(AdaptorDirEnv, t.TempDir())
+
+			ctx := gcp.NewContext(append(getContextOpts(t, tc.mocks), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))...)
+			err := installAdaptor(ctx, "npm_modules", "nextjs", "@apphosting/adapter-nextjs", "14.0")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("installAdaptor() got error: %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstallFallbackAdaptor(t *testing.T) {
+	registryDown := []*mockprocess.Mock{
+		mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@14.0$`, mockprocess.WithStderr("ETIMEDOUT"), mockprocess.WithExitCode(1)),
+		mockprocess.New(`npm pack --json --pack-destination .* @apphosting/adapter-nextjs@latest`, mockprocess.WithStderr("ETIMEDOUT"), mockprocess.WithExitCode(1)),
+	}
+	testCases := []struct {
+		name     string
+		tarballs []string
+		mocks    []*mockprocess.Mock
+		wantErr  bool
+	}{
+		{
+			name:     "newest tarball of the major version",
+			tarballs: []string{"apphosting-adapter-nextjs-14.0.7.tgz", "apphosting-adapter-nextjs-14.1.2.tgz", "apphosting-adapter-nextjs-15.0.1.tgz"},
+			mocks: append(registryDown,
+				mockprocess.New(`npm install --prefix npm_modules --offline --no-audit --no-fund .*/adapters/apphosting-adapter-nextjs-14.1.2.tgz`, mockprocess.WithStdout("installed adaptor"))),
+		},
+		{
+			name:     "no tarball of the major version",
+			tarballs: []string{"apphosting-adapter-nextjs-15.0.1.tgz"},
+			mocks:    registryDown,
+			wantErr:  true,
+		},
+		{
+			name:    "no bundled tarballs",
+			mocks:   registryDown,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(dir string) { bundledAdaptorDir = dir }(bundledAdaptorDir)
+			bundledAdaptorDir = t.TempDir()
+			bpRoot := t.TempDir()
+			for _, tarball := range tc.tarballs {
+				writeFiles(t, filepath.Join(bpRoot, fallbackAdaptorDir), map[string]string{tarball: ""})
+			}
+
+			ctx := gcp.NewContext(append(getContextOpts(t, tc.mocks), gcp.WithBuildpackRoot(bpRoot), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))...)
+			err := installAdaptor(ctx, "npm_modules", "nextjs", "@apphosting/adapter-nextjs", "14.0")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("installAdaptor() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdaptorNpmEnv(t *testing.T) {
+	testCases := []struct {
+		name  string
+		npmrc bool
+		env   map[string]string
+		want  []string
+	}{
+		{
+			name: "no npmrc",
+		},
+		{
+			name:  "application npmrc",
+			npmrc: true,
+			want:  []string{"NPM_CONFIG_USERCONFIG=%s/.npmrc"},
+		},
+		{
+			name:  "user config set",
+			npmrc: true,
+			env:   map[string]string{"NPM_CONFIG_USERCONFIG": "/etc/npmrc"},
+		},
+		{
+			name:  "lower case user config set",
+			npmrc: true,
+			env:   map[string]string{"npm_config_userconfig": "/etc/npmrc"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.npmrc {
+				content := "@apphosting:registry=https://us-npm.pkg.dev/my-project/npm/\n//us-npm.pkg.dev/my-project/npm/:_authToken=${NPM_TOKEN}\n"
+				if err := os.WriteFile(filepath.Join(dir, ".npmrc"), []byte(content), 0644); err != nil {
+					t.Fatalf("writing .npmrc: %v", err)
+				}
+			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			got, err := adaptorNpmEnv(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("adaptorNpmEnv() got error: %v", err)
+			}
+			var want []string
+			for _, w := range tc.want {
+				want = append(want, fmt.Sprintf(w, dir))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("adaptorNpmEnv() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
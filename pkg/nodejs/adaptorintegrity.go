@@ -0,0 +1,143 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"crypto/sha512"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// adaptorIntegrityKey is the metadata key used to store the verified digest of the build adaptor
+// in the adaptor layer.
+const adaptorIntegrityKey = "integrity"
+
+// adaptorIntegrityManifest pins the digests of the build adaptor versions the buildpacks install.
+// It maps `<package>@<version>` to the Subresource Integrity digest of the package tarball, as
+// recorded by npm in package-lock.json. Releasing a new adaptor version requires adding its digest.
+// Example: `{"@apphosting/adapter-nextjs@14.0.3": "sha512-..."}`.
+//
+//go:embed adaptor_integrity.json
+var adaptorIntegrityManifest []byte
+
+// pinnedAdaptorIntegrity returns the digest pinned in adaptorIntegrityManifest for the given
+// version of pkg, or an empty string if the manifest does not list it.
+func pinnedAdaptorIntegrity(pkg, version string) (string, error) {
+	var manifest map[string]string
+	if err := json.Unmarshal(adaptorIntegrityManifest, &manifest); err != nil {
+		return "", gcp.InternalErrorf("parsing adaptor integrity manifest: %w", err)
+	}
+	return manifest[pkg+"@"+version], nil
+}
+
+// checkAdaptorIntegrity returns an error if integrity does not match the digest pinned for the
+// given version of pkg. Versions which are not pinned, such as adaptor releases newer than the
+// buildpack, are installed with a warning. The tarballs bundled with the buildpacks are always
+// pinned, see tools/update-fallback-adapters.sh.
+func checkAdaptorIntegrity(ctx *gcp.Context, pkg, version, integrity, source string) error {
+	pinned, err := pinnedAdaptorIntegrity(pkg, version)
+	if err != nil {
+		return err
+	}
+	if pinned == "" {
+		ctx.Warnf("%s@%s is not pinned in the adaptor integrity manifest, its digest %s from %s cannot be verified", pkg, version, integrity, source)
+		return nil
+	}
+	if integrity != pinned {
+		return gcp.UserErrorf("integrity check of %s@%s from %s failed: got digest %q, want %q", pkg, version, source, integrity, pinned)
+	}
+	ctx.Logf("Verified %s@%s digest %s", pkg, version, integrity)
+	return nil
+}
+
+// tarballIntegrity returns the Subresource Integrity SHA-512 digest of the file at path, in the
+// same format npm uses in package-lock.json.
+func tarballIntegrity(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+	h := sha512.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return "sha512-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// installedAdaptor returns the version and digest of pkg installed in dirPath, as recorded by npm
+// in the package-lock.json of the directory. Empty strings are returned if there is no lock file.
+func installedAdaptor(dirPath, pkg string) (string, string, error) {
+	raw, err := os.ReadFile(filepath.Join(dirPath, "package-lock.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	var lockfile NpmLockfile
+	if err := json.Unmarshal(raw, &lockfile); err != nil {
+		return "", "", gcp.InternalErrorf("parsing adaptor package-lock.json in %s: %w", dirPath, err)
+	}
+	entry := lockfile.Packages["node_modules/"+pkg]
+	return entry.Version, entry.Integrity, nil
+}
+
+// recordAdaptorIntegrity verifies the digest of pkg installed in the layer against the pinned
+// manifest and stores it in the layer metadata, so that later cache hits can be validated. Versions
+// which are not pinned were already reported by checkAdaptorIntegrity when they were installed.
+func recordAdaptorIntegrity(ctx *gcp.Context, l *libcnb.Layer, pkg string) error {
+	version, integrity, err := installedAdaptor(l.Path, pkg)
+	if err != nil {
+		return err
+	}
+	if integrity == "" {
+		ctx.Debugf("No digest recorded for %s in %s", pkg, l.Path)
+		return nil
+	}
+	pinned, err := pinnedAdaptorIntegrity(pkg, version)
+	if err != nil {
+		return err
+	}
+	if pinned != "" && integrity != pinned {
+		return gcp.UserErrorf("integrity check of %s@%s in layer %s failed: got digest %q, want %q", pkg, version, l.Name, integrity, pinned)
+	}
+	ctx.SetMetadata(l, adaptorIntegrityKey, integrity)
+	return nil
+}
+
+// cachedAdaptorValid returns true if the digest of pkg installed in the layer is still the one
+// recorded when it was installed.
+func cachedAdaptorValid(ctx *gcp.Context, l *libcnb.Layer, pkg string) bool {
+	_, integrity, err := installedAdaptor(l.Path, pkg)
+	if err != nil {
+		ctx.Debugf("Reading cached %s digest: %v", pkg, err)
+		return false
+	}
+	if want := ctx.GetMetadata(l, adaptorIntegrityKey); integrity != want {
+		ctx.CategoryWarnf(gcp.WarningSecurity, "Cached %s digest %q does not match the recorded digest %q, reinstalling", pkg, integrity, want)
+		return false
+	}
+	return true
+}
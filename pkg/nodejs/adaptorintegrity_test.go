@@ -0,0 +1,260 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const testAdaptorLock = `{
+  "packages": {
+    "node_modules/@apphosting/adapter-nextjs": {
+      "version": "14.0.3",
+      "integrity": "sha512-abc"
+    }
+  }
+}`
+
+func setAdaptorIntegrityManifest(t *testing.T, manifest string) {
+	t.Helper()
+	orig := adaptorIntegrityManifest
+	t.Cleanup(func() { adaptorIntegrityManifest = orig })
+	adaptorIntegrityManifest = []byte(manifest)
+}
+
+func TestAdaptorIntegrityManifest(t *testing.T) {
+	var manifest map[string]string
+	if err := json.Unmarshal(adaptorIntegrityManifest, &manifest); err != nil {
+		t.Fatalf("parsing the embedded adaptor integrity manifest: %v", err)
+	}
+	for key, integrity := range manifest {
+		if !strings.HasPrefix(key, "@apphosting/") || !strings.Contains(strings.TrimPrefix(key, "@"), "@") {
+			t.Errorf("manifest key %q, want @apphosting/<adaptor>@<version>", key)
+		}
+		if !strings.HasPrefix(integrity, "sha512-") {
+			t.Errorf("manifest digest of %s %q, want a sha512- Subresource Integrity digest", key, integrity)
+		}
+	}
+}
+
+// TestBundledAdaptorsPinned checks that the last known good adaptor tarballs bundled with the
+// buildpacks, which are the default adaptors when the npm registry is unreachable, are pinned in
+// the manifest with their digest.
+func TestBundledAdaptorsPinned(t *testing.T) {
+	tarballs, err := filepath.Glob(filepath.Join(repoRoot(t), "cmd", "nodejs", "firebase*", "adapters", "*.tgz"))
+	if err != nil {
+		t.Fatalf("listing bundled adaptor tarballs: %v", err)
+	}
+	tarballName := regexp.MustCompile(`^apphosting-(.+?)-(\d+\.\d+\.\d+.*)\.tgz$`)
+	for _, tarball := range tarballs {
+		m := tarballName.FindStringSubmatch(filepath.Base(tarball))
+		if m == nil {
+			t.Errorf("bundled adaptor tarball %s, want apphosting-<adaptor>-<version>.tgz", tarball)
+			continue
+		}
+		pkg, version := "@apphosting/"+m[1], m[2]
+		pinned, err := pinnedAdaptorIntegrity(pkg, version)
+		if err != nil {
+			t.Fatalf("pinnedAdaptorIntegrity(%q, %q) got error: %v", pkg, version, err)
+		}
+		if pinned == "" {
+			t.Errorf("%s@%s bundled in %s is not pinned in adaptor_integrity.json, run tools/update-fallback-adapters.sh", pkg, version, tarball)
+			continue
+		}
+		integrity, err := tarballIntegrity(tarball)
+		if err != nil {
+			t.Fatalf("tarballIntegrity(%q) got error: %v", tarball, err)
+		}
+		if integrity != pinned {
+			t.Errorf("digest of %s = %q, want %q pinned in adaptor_integrity.json", tarball, integrity, pinned)
+		}
+	}
+}
+
+// repoRoot returns the root of the repository, which is the working directory of the test under
+// Bazel and an ancestor of it under go test.
+func repoRoot(t *testing.T) string {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting the working directory: %v", err)
+	}
+	for d := dir; d != filepath.Dir(d); d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "cmd", "nodejs")); err == nil {
+			return d
+		}
+	}
+	return dir
+}
+
+func TestRecordAdaptorIntegrity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		lock     string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "digest matches the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.3": "sha512-abc"}`,
+			lock:     testAdaptorLock,
+			want:     "sha512-abc",
+		},
+		{
+			name:     "digest does not match the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.3": "sha512-def"}`,
+			lock:     testAdaptorLock,
+			wantErr:  true,
+		},
+		{
+			name:     "version not in the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.2": "sha512-def"}`,
+			lock:     testAdaptorLock,
+			want:     "sha512-abc",
+		},
+		{
+			name:     "empty manifest",
+			manifest: `{}`,
+			lock:     testAdaptorLock,
+			want:     "sha512-abc",
+		},
+		{
+			name:     "no lock file",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.3": "sha512-abc"}`,
+		},
+		{
+			name:     "invalid manifest",
+			manifest: `{`,
+			lock:     testAdaptorLock,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAdaptorIntegrityManifest(t, tc.manifest)
+			l := &libcnb.Layer{Name: "nextjs", Path: t.TempDir(), Metadata: map[string]any{}}
+			if tc.lock != "" {
+				if err := os.WriteFile(filepath.Join(l.Path, "package-lock.json"), []byte(tc.lock), 0644); err != nil {
+					t.Fatalf("writing package-lock.json: %v", err)
+				}
+			}
+			ctx := gcp.NewContext()
+
+			err := recordAdaptorIntegrity(ctx, l, "@apphosting/adapter-nextjs")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("recordAdaptorIntegrity() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got := ctx.GetMetadata(l, adaptorIntegrityKey); got != tc.want {
+				t.Errorf("recordAdaptorIntegrity() recorded %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCachedAdaptorValid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		metadata map[string]any
+		lock     string
+		want     bool
+	}{
+		{
+			name:     "digest unchanged",
+			metadata: map[string]any{adaptorIntegrityKey: "sha512-abc"},
+			lock:     testAdaptorLock,
+			want:     true,
+		},
+		{
+			name:     "digest changed",
+			metadata: map[string]any{adaptorIntegrityKey: "sha512-def"},
+			lock:     testAdaptorLock,
+		},
+		{
+			name:     "adaptor removed",
+			metadata: map[string]any{adaptorIntegrityKey: "sha512-abc"},
+		},
+		{
+			name:     "no digest recorded",
+			metadata: map[string]any{},
+			want:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &libcnb.Layer{Name: "nextjs", Path: t.TempDir(), Metadata: tc.metadata}
+			if tc.lock != "" {
+				if err := os.WriteFile(filepath.Join(l.Path, "package-lock.json"), []byte(tc.lock), 0644); err != nil {
+					t.Fatalf("writing package-lock.json: %v", err)
+				}
+			}
+
+			if got := cachedAdaptorValid(gcp.NewContext(), l, "@apphosting/adapter-nextjs"); got != tc.want {
+				t.Errorf("cachedAdaptorValid() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAdaptorTarball(t *testing.T) {
+	// The SHA-512 digest of an empty file.
+	emptyIntegrity := "sha512-z4PhNX7vuL3xVChQ1m2AB9Yg5AULVxXcg/SpIdNs6c5H0NE8XYXysP+DGNKHfuwvY7kxvUdBeoGlODJ6+SfaPg=="
+	testCases := []struct {
+		name     string
+		manifest string
+		wantErr  bool
+	}{
+		{
+			name:     "digest matches the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.3": "` + emptyIntegrity + `"}`,
+		},
+		{
+			name:     "digest does not match the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.3": "sha512-abc"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "version not in the manifest",
+			manifest: `{"@apphosting/adapter-nextjs@14.0.2": "` + emptyIntegrity + `"}`,
+		},
+		{
+			name:     "invalid manifest",
+			manifest: `{`,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setAdaptorIntegrityManifest(t, tc.manifest)
+			tarball := filepath.Join(t.TempDir(), "apphosting-adapter-nextjs-14.0.3.tgz")
+			if err := os.WriteFile(tarball, nil, 0644); err != nil {
+				t.Fatalf("writing tarball: %v", err)
+			}
+
+			err := verifyAdaptorTarball(gcp.NewContext(), "@apphosting/adapter-nextjs", tarball)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("verifyAdaptorTarball() got error: %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
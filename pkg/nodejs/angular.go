@@ -15,8 +15,6 @@
 package nodejs
 
 import (
-	"fmt"
-	"strconv"
 	"strings"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -24,67 +22,32 @@ import (
 	"github.com/Masterminds/semver"
 )
 
-var (
-	// angularVersionKey is the metadata key used to store the angular build adaptor version in the angular layer.
-	angularVersionKey = "version"
-)
-
-// InstallAngularBuildAdaptor installs the angular build adaptor in the given layer if it is not already cached.
-func InstallAngularBuildAdaptor(ctx *gcp.Context, al *libcnb.Layer, version string) error {
-	layerName := al.Name
-	version, err := AngularAdaptorVersion(version)
-	if err != nil {
-		return err
-	}
-
-	// Check the metadata in the cache layer to determine if we need to proceed.
-	metaVersion := ctx.GetMetadata(al, angularVersionKey)
-	if version == metaVersion {
-		ctx.CacheHit(layerName)
-		ctx.Logf("angular adaptor cache hit: %q, %q, skipping installation.", version, metaVersion)
-	} else {
-		ctx.CacheMiss(layerName)
-		if err := ctx.ClearLayer(al); err != nil {
-			return fmt.Errorf("clearing layer %q: %w", layerName, err)
-		}
-		// Download and install angular adaptor in layer.
-		ctx.Logf("Installing angular adaptor %s", version)
-		if err := downloadAngularAdaptor(ctx, al.Path, version); err != nil {
-			return gcp.InternalErrorf("downloading angular adapter: %w", err)
-		}
-	}
-
-	// Store layer flags and metadata.
-	ctx.SetMetadata(al, angularVersionKey, version)
-	return nil
+func init() {
+	RegisterFrameworkAdapter(&angularAdapter{&NpmFrameworkAdapter{
+		Framework:          "angular",
+		ConfigFiles:        []string{"angular.json"},
+		VersionPackage:     "@angular/core",
+		MinVersion:         semver.MustParse("17.2.0"),
+		AdaptorPackage:     "@apphosting/adapter-angular",
+		AdaptorBuildScript: "apphosting-adapter-angular-build",
+		BuildScripts:       []string{"ng build"},
+	}})
 }
 
-// AngularAdaptorVersion determines the version of Angular that is needed by an Angular project
-func AngularAdaptorVersion(version string) (string, error) {
-	parsedVersion, err := semver.StrictNewVersion(version)
-	if err != nil {
-		return "", gcp.InternalErrorf("parsing angular version: %w", err)
-	}
-	// match major + minor versions with the Angular version
-	adapterVersion := strconv.FormatUint(parsedVersion.Major(), 10) + "." + strconv.FormatUint(parsedVersion.Minor(), 10)
-	return adapterVersion, nil
+// angularAdapter is the FrameworkAdapter of Angular.
+type angularAdapter struct {
+	*NpmFrameworkAdapter
 }
 
-// downloadAngularAdaptor downloads the Angular build adaptor into the provided directory.
-func downloadAngularAdaptor(ctx *gcp.Context, dirPath, version string) error {
-	// TODO(b/323280044) account for different versions
-	if _, err := ctx.Exec([]string{"npm", "install", "--prefix", dirPath, "@apphosting/adapter-angular@" + version}); err != nil {
-		ctx.Logf("Failed to install angular adaptor version: %s. Falling back to latest", version)
-		if _, err := ctx.Exec([]string{"npm", "install", "--prefix", dirPath, "@apphosting/adapter-angular@latest"}); err != nil {
-			return gcp.InternalErrorf("installing angular adaptor: %w", err)
-		}
+// OverrideBuild always overrides the build script to be the adaptor build script, which runs the
+// build script of the application and validates its output.
+func (a *angularAdapter) OverrideBuild(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON) (bool, error) {
+	buildScript, exists := pjs.Scripts["build"]
+	if exists && buildScript != a.BuildScripts[0] && buildScript != a.AdaptorBuildScript {
+		ctx.Warnf("*** You are using a custom build command (your build command is NOT '%s'), we will accept it as is but will error if output structure is not as expected ***", a.BuildScripts[0])
 	}
-	return nil
-}
-
-// OverrideAngularBuildScript overrides the build script to be the Angular build script
-func OverrideAngularBuildScript(njsl *libcnb.Layer) {
-	njsl.BuildEnvironment.Override(AppHostingBuildEnv, fmt.Sprintf("npm exec --prefix %s apphosting-adapter-angular-build", njsl.Path))
+	a.overrideBuildScript(l)
+	return true, nil
 }
 
 // ExtractAngularStartCommand inspects the given package.json file for an idiomatic `serve:ssr:APP_NAME`
@@ -17,59 +17,9 @@ import (
 	"encoding/json"
 	"testing"
 
-	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
-	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
-	"github.com/buildpacks/libcnb"
 	"github.com/google/go-cmp/cmp"
 )
 
-func TestInstallAngularBuildAdaptor(t *testing.T) {
-	testCases := []struct {
-		name           string
-		layerMetadata  map[string]any
-		angularVersion string
-		mocks          []*mockprocess.Mock
-	}{
-		{
-			name:           "download v17.2 adaptor succeeds",
-			angularVersion: "17.2.0",
-			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@17.2`, mockprocess.WithStdout("installed adaptor")),
-			},
-			layerMetadata: map[string]any{},
-		},
-		{
-			name:           "download adaptor not needed since it is cached",
-			angularVersion: "17.2.0",
-			layerMetadata:  map[string]any{"version": "17.2"},
-		},
-		{
-			name:           "download invalid adaptor falls back to latest",
-			angularVersion: "20.0.0",
-			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@20.0`, mockprocess.WithStderr("installed adapter failed")),
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-angular@latest`, mockprocess.WithStderr("installed adapter")),
-			},
-			layerMetadata: map[string]any{},
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			ctx := gcp.NewContext(getContextOpts(t, tc.mocks)...)
-			layer := &libcnb.Layer{
-				Name:     "njsl",
-				Path:     t.TempDir(),
-				Metadata: tc.layerMetadata,
-			}
-			err := InstallAngularBuildAdaptor(ctx, layer, tc.angularVersion)
-			if err != nil {
-				t.Fatalf("InstallAngularBuildAdaptor() got error: %v", err)
-			}
-		})
-	}
-}
-
 func TestExtractAngularStartCommand(t *testing.T) {
 	testsCases := []struct {
 		name string
@@ -0,0 +1,152 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"path/filepath"
+	"regexp"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/Masterminds/semver"
+)
+
+const (
+	// AstroOutputEnv is the build env var set by the firebaseastro buildpack to the output mode of
+	// the Astro project, so that later buildpacks can tell whether a Node server is needed.
+	AstroOutputEnv = "GOOGLE_ASTRO_OUTPUT"
+	// AstroOutputStatic is the output mode of Astro projects which are prerendered at build time.
+	AstroOutputStatic = "static"
+	// AstroOutputServer is the output mode of Astro projects which are rendered on demand.
+	AstroOutputServer = "server"
+	// AstroOutputHybrid is the output mode of Astro projects which are prerendered by default but
+	// may render some routes on demand.
+	AstroOutputHybrid = "hybrid"
+
+	// astroServerEntry is the server entrypoint built by Astro for on demand rendering.
+	astroServerEntry = "dist/server/entry.mjs"
+)
+
+var (
+	// astroOutputKey is the metadata key used to store the astro output mode in the astro layer.
+	astroOutputKey = "output"
+
+	// astroConfigFiles are the names of the Astro config files, in the order Astro looks them up.
+	astroConfigFiles = []string{"astro.config.mjs", "astro.config.js", "astro.config.ts", "astro.config.mts", "astro.config.cjs", "astro.config.cts"}
+
+	// astroOutputRegexp matches the output option of the Astro config.
+	astroOutputRegexp = regexp.MustCompile(`(?m)^[^/\n]*\boutput\s*:\s*['"](static|server|hybrid)['"]`)
+)
+
+// AstroConfigFile returns the name of the Astro config file of the application, or an empty string
+// if there is none.
+func AstroConfigFile(ctx *gcp.Context) (string, error) {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range astroConfigFiles {
+		exists, err := ctx.FileExists(appDir, f)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return f, nil
+		}
+	}
+	return "", nil
+}
+
+// AstroOutputMode returns the output mode set in the Astro config of the application. Astro
+// defaults to the static output mode if none is set.
+func AstroOutputMode(ctx *gcp.Context) (string, error) {
+	config, err := AstroConfigFile(ctx)
+	if err != nil || config == "" {
+		return AstroOutputStatic, err
+	}
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	raw, err := ctx.ReadFile(filepath.Join(appDir, config))
+	if err != nil {
+		return "", err
+	}
+	if m := astroOutputRegexp.FindSubmatch(raw); m != nil {
+		return string(m[1]), nil
+	}
+	return AstroOutputStatic, nil
+}
+
+// AstroNeedsServer returns true if an Astro project with the given output mode renders routes on
+// demand and must be run with a Node server.
+func AstroNeedsServer(output string) bool {
+	return output == AstroOutputServer || output == AstroOutputHybrid
+}
+
+func init() {
+	RegisterFrameworkAdapter(&astroAdapter{&NpmFrameworkAdapter{
+		Framework:          "astro",
+		ConfigFiles:        astroConfigFiles,
+		VersionPackage:     "astro",
+		MinVersion:         semver.MustParse("3.0.0"),
+		AdaptorPackage:     "@apphosting/adapter-astro",
+		AdaptorBuildScript: "apphosting-adapter-astro-build",
+		BuildScripts:       []string{"astro build"},
+	}})
+}
+
+// astroAdapter is the FrameworkAdapter of Astro.
+type astroAdapter struct {
+	*NpmFrameworkAdapter
+}
+
+// OverrideBuild records the output mode of the project in the layer and in AstroOutputEnv, and
+// overrides the build script to be the adaptor build script if the project is rendered on demand.
+// Projects with the static output mode are served as files and do not need an adaptor.
+func (a *astroAdapter) OverrideBuild(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON) (bool, error) {
+	output, err := AstroOutputMode(ctx)
+	if err != nil {
+		return false, err
+	}
+	ctx.Logf("Astro output mode: %s", output)
+	ctx.SetMetadata(l, astroOutputKey, output)
+	l.BuildEnvironment.Override(AstroOutputEnv, output)
+	if !AstroNeedsServer(output) {
+		return false, nil
+	}
+	return a.NpmFrameworkAdapter.OverrideBuild(ctx, l, pjs)
+}
+
+// AstroStartCommand determines if this is an Astro application rendered on demand and returns the
+// command to start its server. If it is not, it returns nil.
+func AstroStartCommand(ctx *gcp.Context) ([]string, error) {
+	config, err := AstroConfigFile(ctx)
+	if err != nil || config == "" {
+		return nil, err
+	}
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serverExists, err := ctx.FileExists(appDir, astroServerEntry)
+	if err != nil {
+		return nil, err
+	}
+	if serverExists {
+		return []string{"node", astroServerEntry}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAstroOutputMode(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "no config",
+			want: "static",
+		},
+		{
+			name: "no output option",
+			files: map[string]string{
+				"astro.config.mjs": `import { defineConfig } from 'astro/config';
+export default defineConfig({});`,
+			},
+			want: "static",
+		},
+		{
+			name: "server output",
+			files: map[string]string{
+				"astro.config.mjs": `import { defineConfig } from 'astro/config';
+import node from '@astrojs/node';
+
+export default defineConfig({
+  output: 'server',
+  adapter: node({ mode: 'standalone' }),
+});`,
+			},
+			want: "server",
+		},
+		{
+			name: "hybrid output in typescript config",
+			files: map[string]string{
+				"astro.config.ts": `export default defineConfig({ output: "hybrid" });`,
+			},
+			want: "hybrid",
+		},
+		{
+			name: "commented out output",
+			files: map[string]string{
+				"astro.config.mjs": `export default defineConfig({
+  // output: 'server',
+});`,
+			},
+			want: "static",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := AstroOutputMode(ctx)
+			if err != nil {
+				t.Fatalf("AstroOutputMode() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("AstroOutputMode() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAstroStartCommand(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "no config",
+			files: []string{"dist/server/entry.mjs"},
+		},
+		{
+			name:  "static build",
+			files: []string{"astro.config.mjs", "dist/index.html"},
+		},
+		{
+			name:  "server build",
+			files: []string{"astro.config.mjs", "dist/server/entry.mjs"},
+			want:  []string{"node", "dist/server/entry.mjs"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir: %v", err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := AstroStartCommand(ctx)
+			if err != nil {
+				t.Fatalf("AstroStartCommand() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("AstroStartCommand() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
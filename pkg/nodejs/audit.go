@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// AuditLevelEnv is an env var used to fail the build when the production dependencies of the
+// application have known vulnerabilities at or above the given severity, as reported by
+// `npm audit` or `pnpm audit`. Dependencies are not audited when it is not set.
+// Example: `high`.
+const AuditLevelEnv = "GOOGLE_NODEJS_AUDIT_LEVEL"
+
+// auditSeverities are the severities reported by npm and pnpm, from the lowest to the highest.
+var auditSeverities = []string{"low", "moderate", "high", "critical"}
+
+// auditReport is the JSON output of `npm audit` (v7 and newer) and `pnpm audit`.
+type auditReport struct {
+	Metadata struct {
+		Vulnerabilities map[string]int `json:"vulnerabilities"`
+	} `json:"metadata"`
+	// Vulnerabilities is keyed by package name in the npm report.
+	Vulnerabilities map[string]struct {
+		Severity string `json:"severity"`
+	} `json:"vulnerabilities"`
+	// Advisories is keyed by advisory ID in the pnpm report.
+	Advisories map[string]struct {
+		ModuleName string `json:"module_name"`
+		Severity   string `json:"severity"`
+	} `json:"advisories"`
+	Error *struct {
+		Summary string `json:"summary"`
+	} `json:"error"`
+}
+
+// AuditLevel returns the minimum severity of the vulnerabilities which fail the build, or an empty
+// string if dependencies are not audited.
+func AuditLevel() (string, error) {
+	level := strings.ToLower(strings.TrimSpace(os.Getenv(AuditLevelEnv)))
+	if level == "" {
+		return "", nil
+	}
+	for _, s := range auditSeverities {
+		if s == level {
+			return level, nil
+		}
+	}
+	return "", gcp.UserErrorf("invalid %s %q, it must be one of %s", AuditLevelEnv, level, strings.Join(auditSeverities, ", "))
+}
+
+// Audit checks the production dependencies installed by pkgTool for known vulnerabilities when
+// AuditLevelEnv is set, and returns a user error listing the vulnerable packages if any are found
+// at or above the configured severity. Only npm and pnpm are supported.
+func Audit(ctx *gcp.Context, pkgTool string) error {
+	level, err := AuditLevel()
+	if err != nil || level == "" {
+		return err
+	}
+	cmd := auditCommand(pkgTool)
+	if cmd == nil {
+		ctx.Warnf("Skipping the dependency audit requested with %s, it is only supported with npm and pnpm.", AuditLevelEnv)
+		return nil
+	}
+	ctx.Logf("Auditing production dependencies for vulnerabilities of %s severity or higher.", level)
+	// The audit command exits with an error when vulnerabilities are found, the report is checked
+	// against the configured level instead.
+	result, execErr := ctx.Exec(append(cmd, "--json"), gcp.WithLogOutput(false), gcp.WithEnv(PackageManagerConfigEnv(ctx, pkgTool)...))
+	if result == nil {
+		return gcp.UserErrorf("running %s audit: %v", pkgTool, execErr)
+	}
+	var report auditReport
+	if err := json.Unmarshal([]byte(result.Stdout), &report); err != nil {
+		return gcp.UserErrorf("running %s audit: %v\n%s", pkgTool, execErr, result.Combined)
+	}
+	if report.Error != nil {
+		return gcp.UserErrorf("running %s audit: %s", pkgTool, report.Error.Summary)
+	}
+	return checkAuditReport(report, pkgTool, level)
+}
+
+// auditCommand returns the command auditing the production dependencies installed by pkgTool, or
+// nil if pkgTool is not supported.
+func auditCommand(pkgTool string) []string {
+	switch pkgTool {
+	case "npm":
+		return []string{"npm", "audit", "--omit=dev"}
+	case "pnpm":
+		return []string{"pnpm", "audit", "--prod"}
+	}
+	return nil
+}
+
+// checkAuditReport returns a user error if the report has vulnerabilities at or above level.
+func checkAuditReport(report auditReport, pkgTool, level string) error {
+	minimum := slices.Index(auditSeverities, level)
+	failing := map[string]bool{}
+	for i, s := range auditSeverities {
+		failing[s] = i >= minimum
+	}
+	var counts []string
+	total := 0
+	for i := len(auditSeverities) - 1; i >= 0; i-- {
+		s := auditSeverities[i]
+		if n := report.Metadata.Vulnerabilities[s]; failing[s] && n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, s))
+			total += n
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	pkgs := map[string]bool{}
+	for name, v := range report.Vulnerabilities {
+		if failing[v.Severity] {
+			pkgs[name] = true
+		}
+	}
+	for _, a := range report.Advisories {
+		if failing[a.Severity] {
+			pkgs[a.ModuleName] = true
+		}
+	}
+	var names []string
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	noun := "vulnerabilities"
+	if total == 1 {
+		noun = "vulnerability"
+	}
+	msg := fmt.Sprintf("found %d %s of %s severity or higher in production dependencies (%s)", total, noun, level, strings.Join(counts, ", "))
+	if len(names) > 0 {
+		msg += ": " + strings.Join(names, ", ")
+	}
+	return gcp.UserErrorf("%s. Run `%s` for details and upgrade the affected packages, or change %s to allow them", msg, strings.Join(auditCommand(pkgTool), " "), AuditLevelEnv)
+}
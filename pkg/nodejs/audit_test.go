@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAuditLevel(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: "high", want: "high"},
+		{value: " Critical ", want: "critical"},
+		{value: "severe", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(AuditLevelEnv, tc.value)
+			got, err := AuditLevel()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AuditLevel() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("AuditLevel() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckAuditReport(t *testing.T) {
+	npmReport := `{
+		"vulnerabilities": {
+			"lodash": {"name": "lodash", "severity": "critical"},
+			"semver": {"name": "semver", "severity": "moderate"},
+			"minimist": {"name": "minimist", "severity": "high"}
+		},
+		"metadata": {"vulnerabilities": {"info": 0, "low": 0, "moderate": 1, "high": 1, "critical": 1, "total": 3}}
+	}`
+	pnpmReport := `{
+		"advisories": {
+			"1096366": {"module_name": "axios", "severity": "moderate"},
+			"1096727": {"module_name": "ws", "severity": "high"}
+		},
+		"metadata": {"vulnerabilities": {"info": 0, "low": 0, "moderate": 1, "high": 1, "critical": 0}}
+	}`
+	testCases := []struct {
+		name       string
+		report     string
+		pkgTool    string
+		level      string
+		wantErrMsg string
+	}{
+		{
+			name:       "npm vulnerabilities at level",
+			report:     npmReport,
+			pkgTool:    "npm",
+			level:      "high",
+			wantErrMsg: "found 2 vulnerabilities of high severity or higher in production dependencies (1 critical, 1 high): lodash, minimist. Run `npm audit --omit=dev`",
+		},
+		{
+			name:       "npm vulnerabilities above level",
+			report:     npmReport,
+			pkgTool:    "npm",
+			level:      "low",
+			wantErrMsg: "found 3 vulnerabilities",
+		},
+		{
+			name:    "npm vulnerabilities below level",
+			report:  `{"metadata": {"vulnerabilities": {"low": 4, "moderate": 2}}}`,
+			pkgTool: "npm",
+			level:   "high",
+		},
+		{
+			name:       "pnpm vulnerabilities",
+			report:     pnpmReport,
+			pkgTool:    "pnpm",
+			level:      "moderate",
+			wantErrMsg: "(1 high, 1 moderate): axios, ws. Run `pnpm audit --prod`",
+		},
+		{
+			name:    "no vulnerabilities",
+			report:  `{"metadata": {"vulnerabilities": {}}}`,
+			pkgTool: "pnpm",
+			level:   "low",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var report auditReport
+			if err := json.Unmarshal([]byte(tc.report), &report); err != nil {
+				t.Fatal(err)
+			}
+			err := checkAuditReport(report, tc.pkgTool, tc.level)
+			if tc.wantErrMsg == "" {
+				if err != nil {
+					t.Errorf("checkAuditReport() got error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErrMsg) {
+				t.Errorf("checkAuditReport() got error: %v, want error containing %q", err, tc.wantErrMsg)
+			}
+		})
+	}
+}
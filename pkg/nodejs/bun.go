@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// BunLock is the name of the text-based lock file generated by bun 1.2 and newer.
+	BunLock = "bun.lock"
+	// BunLockb is the name of the binary lock file generated by older versions of bun.
+	BunLockb = "bun.lockb"
+)
+
+// lookPath finds bun on the PATH. It can be overridden for testing.
+var lookPath = exec.LookPath
+
+// bunTrailingCommaRegexp matches the trailing commas allowed in bun.lock, which is JSONC.
+var bunTrailingCommaRegexp = regexp.MustCompile(`,(\s*[}\]])`)
+
+// bunLockfile represents the contents of a bun.lock file. Each package entry is an array whose
+// first element is the resolved identifier, for example `next@14.2.3`.
+type bunLockfile struct {
+	Packages map[string][]json.RawMessage `json:"packages"`
+}
+
+// versionFromBunLock returns the version of pkg in a bun.lock. The lock file is JSONC, which the
+// JSON decoder cannot stream, so it is read as a whole.
+func versionFromBunLock(r io.Reader, pkg string) (string, error) {
+	rawPackageLock, err := io.ReadAll(r)
+	if err != nil {
+		return "", gcp.InternalErrorf("reading %s: %w", BunLock, err)
+	}
+	var lockfile bunLockfile
+	if err := json.Unmarshal(bunTrailingCommaRegexp.ReplaceAll(rawPackageLock, []byte("$1")), &lockfile); err != nil {
+		return "", gcp.InternalErrorf("parsing %s: %w", BunLock, err)
+	}
+	entry, ok := lockfile.Packages[pkg]
+	if !ok || len(entry) == 0 {
+		return "", nil
+	}
+	var id string
+	if err := json.Unmarshal(entry[0], &id); err != nil {
+		return "", gcp.InternalErrorf("parsing %s entry for %s: %w", BunLock, pkg, err)
+	}
+	if !strings.HasPrefix(id, pkg+"@") {
+		return "", gcp.InternalErrorf("unexpected %s entry for %s: %q", BunLock, pkg, id)
+	}
+	return strings.TrimPrefix(id, pkg+"@"), nil
+}
+
+// versionFromBunLockb reads the binary bun.lockb using bun itself, which prints the lock file in
+// the yarn.lock v1 format when executed. The builders do not install bun, so if it is not on the
+// PATH no version is returned and the version installed in node_modules is used instead.
+func versionFromBunLockb(ctx *gcp.Context, path string, pjs *PackageJSON, pkg string) (string, error) {
+	if _, err := lookPath("bun"); err != nil {
+		ctx.Warnf("Reading %s requires bun, which is not installed; using the version of %s installed in node_modules. Run `bun install --save-text-lockfile` to generate a %s instead.", BunLockb, pkg, BunLock)
+		return "", nil
+	}
+	result, err := ctx.Exec([]string{"bun", path})
+	if err != nil {
+		return "", gcp.UserErrorf("reading %s requires bun, run `bun install --save-text-lockfile` to generate a %s instead: %v", BunLockb, BunLock, err)
+	}
+	return versionFromYarnLock(strings.NewReader(result.Stdout), pjs, pkg)
+}
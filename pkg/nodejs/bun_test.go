@@ -0,0 +1,52 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersionFromBunLock(t *testing.T) {
+	lock := `{
+  "lockfileVersion": 1,
+  "packages": {
+    "@angular/core": ["@angular/core@17.1.2", "", { "peerDependencies": { "rxjs": "^6.5.3 || ^7.4.0" } }, "sha512-a"],
+    "next": ["next@14.2.3", "", {}, "sha512-b"],
+    "next/@swc/helpers": ["@swc/helpers@0.5.5", "", {}, "sha512-c"],
+  },
+}`
+	testCases := []struct {
+		name    string
+		pkg     string
+		want    string
+		wantErr bool
+	}{
+		{name: "unscoped package", pkg: "next", want: "14.2.3"},
+		{name: "scoped package", pkg: "@angular/core", want: "17.1.2"},
+		{name: "missing package", pkg: "nuxt", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := versionFromBunLock(strings.NewReader(lock), tc.pkg)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("versionFromBunLock(_, %q) got error: %v, want error: %t", tc.pkg, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("versionFromBunLock(_, %q) = %q, want %q", tc.pkg, got, tc.want)
+			}
+		})
+	}
+}
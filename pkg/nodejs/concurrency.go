@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// maxNetworkConcurrency bounds the number of concurrent package downloads.
+	maxNetworkConcurrency = 16
+	// networkConcurrencyPerCPU is the number of concurrent package downloads per available CPU.
+	// Downloads are mostly IO bound, but unpacking them is not.
+	networkConcurrencyPerCPU = 4
+)
+
+var (
+	// cgroupCPUMaxFile is the file the CPU quota and period of the container are read from with
+	// cgroup v2, for example `200000 100000` or `max 100000`.
+	cgroupCPUMaxFile = "/sys/fs/cgroup/cpu.max"
+	// cgroupCPUQuotaFile and cgroupCPUPeriodFile are the files the CPU quota and period of the
+	// container are read from with cgroup v1. The quota is -1 if the container is not limited.
+	cgroupCPUQuotaFile  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupCPUPeriodFile = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+
+	// numCPU can be overridden for testing.
+	numCPU = runtime.NumCPU
+)
+
+// AvailableCPUs returns the number of CPUs available to the build, which is the number of CPUs of
+// the machine unless the container is limited by a cgroup CPU quota.
+func AvailableCPUs() int {
+	cpus := numCPU()
+	if quota, ok := cgroupCPUQuota(); ok && quota < float64(cpus) {
+		cpus = int(math.Max(1, math.Ceil(quota)))
+	}
+	return cpus
+}
+
+// cgroupCPUQuota returns the number of CPUs the container is limited to, or false if it is not
+// limited or the limit cannot be read.
+func cgroupCPUQuota() (float64, bool) {
+	if raw, err := os.ReadFile(cgroupCPUMaxFile); err == nil {
+		fields := strings.Fields(string(raw))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+		return cpuQuota(fields[0], fields[1])
+	}
+	quota, err := os.ReadFile(cgroupCPUQuotaFile)
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile(cgroupCPUPeriodFile)
+	if err != nil {
+		return 0, false
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// ConcurrencyEnv returns the environment variables which size the network and child process
+// concurrency of the given package manager (npm, yarn or pnpm), and the parallelism of native
+// addon builds, to the CPUs available to the build. The defaults of the package managers assume a
+// developer machine and thrash on small builders. Variables already set by the user are kept.
+func ConcurrencyEnv(ctx *gcp.Context, pkgTool string) []string {
+	cpus := AvailableCPUs()
+	network := strconv.Itoa(min(maxNetworkConcurrency, cpus*networkConcurrencyPerCPU))
+	jobs := strconv.Itoa(cpus)
+
+	vars := [][2]string{
+		// node-gyp reads the number of parallel compile jobs from JOBS, make from MAKEFLAGS.
+		{"JOBS", jobs},
+		{"MAKEFLAGS", "-j" + jobs},
+	}
+	switch pkgTool {
+	case "npm":
+		vars = append(vars, [2]string{"npm_config_maxsockets", network})
+	case "yarn":
+		vars = append(vars, [2]string{"YARN_NETWORK_CONCURRENCY", network})
+		// Child concurrency is a Yarn 1 setting which Yarn Berry does not recognize.
+		if yarn2, err := IsYarn2(ctx.ApplicationRoot()); err == nil && !yarn2 {
+			vars = append(vars, [2]string{"YARN_CHILD_CONCURRENCY", jobs})
+		}
+	case "pnpm":
+		vars = append(vars, [2]string{"npm_config_network_concurrency", network}, [2]string{"npm_config_child_concurrency", jobs})
+	}
+
+	var env []string
+	for _, v := range vars {
+		if _, ok := os.LookupEnv(v[0]); ok {
+			continue
+		}
+		env = append(env, fmt.Sprintf("%s=%s", v[0], v[1]))
+	}
+	ctx.Debugf("Sizing %s concurrency to %d CPUs: %v", pkgTool, cpus, env)
+	return env
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAvailableCPUs(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cpuMax string
+		quota  string
+		period string
+		want   int
+	}{
+		{
+			name: "no cgroup files",
+			want: 8,
+		},
+		{
+			name:   "cgroup v2 unlimited",
+			cpuMax: "max 100000",
+			want:   8,
+		},
+		{
+			name:   "cgroup v2 limited",
+			cpuMax: "200000 100000",
+			want:   2,
+		},
+		{
+			name:   "cgroup v2 fractional",
+			cpuMax: "50000 100000",
+			want:   1,
+		},
+		{
+			name:   "cgroup v2 above machine",
+			cpuMax: "1600000 100000",
+			want:   8,
+		},
+		{
+			name:   "cgroup v1 unlimited",
+			quota:  "-1",
+			period: "100000",
+			want:   8,
+		},
+		{
+			name:   "cgroup v1 limited",
+			quota:  "150000",
+			period: "100000",
+			want:   2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setUpCgroup(t, 8, tc.cpuMax, tc.quota, tc.period)
+			if got := AvailableCPUs(); got != tc.want {
+				t.Errorf("AvailableCPUs() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConcurrencyEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pkgTool string
+		files   map[string]string
+		envs    map[string]string
+		want    []string
+	}{
+		{
+			name:    "npm",
+			pkgTool: "npm",
+			want:    []string{"JOBS=2", "MAKEFLAGS=-j2", "npm_config_maxsockets=8"},
+		},
+		{
+			name:    "yarn 1",
+			pkgTool: "yarn",
+			files:   map[string]string{YarnLock: "# yarn lockfile v1\n\nnext@^14.0.0:\n  version \"14.0.0\"\n"},
+			want:    []string{"JOBS=2", "MAKEFLAGS=-j2", "YARN_NETWORK_CONCURRENCY=8", "YARN_CHILD_CONCURRENCY=2"},
+		},
+		{
+			name:    "yarn berry",
+			pkgTool: "yarn",
+			files:   map[string]string{YarnLock: "__metadata:\n  version: 8\n"},
+			want:    []string{"JOBS=2", "MAKEFLAGS=-j2", "YARN_NETWORK_CONCURRENCY=8"},
+		},
+		{
+			name:    "pnpm",
+			pkgTool: "pnpm",
+			want:    []string{"JOBS=2", "MAKEFLAGS=-j2", "npm_config_network_concurrency=8", "npm_config_child_concurrency=2"},
+		},
+		{
+			name:    "user values are kept",
+			pkgTool: "npm",
+			envs:    map[string]string{"MAKEFLAGS": "-j1", "npm_config_maxsockets": "50"},
+			want:    []string{"JOBS=2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setUpCgroup(t, 8, "200000 100000", "", "")
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			if diff := cmp.Diff(tc.want, ConcurrencyEnv(ctx, tc.pkgTool)); diff != "" {
+				t.Errorf("ConcurrencyEnv() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// setUpCgroup points the cgroup CPU files at temporary files with the given contents, which are
+// not created if empty, and sets the number of CPUs of the machine.
+func setUpCgroup(t *testing.T, cpus int, cpuMax, quota, period string) {
+	t.Helper()
+	dir := t.TempDir()
+	oldMax, oldQuota, oldPeriod, oldNumCPU := cgroupCPUMaxFile, cgroupCPUQuotaFile, cgroupCPUPeriodFile, numCPU
+	t.Cleanup(func() {
+		cgroupCPUMaxFile, cgroupCPUQuotaFile, cgroupCPUPeriodFile, numCPU = oldMax, oldQuota, oldPeriod, oldNumCPU
+	})
+	numCPU = func() int { return cpus }
+	for _, f := range []struct {
+		path    *string
+		content string
+	}{{&cgroupCPUMaxFile, cpuMax}, {&cgroupCPUQuotaFile, quota}, {&cgroupCPUPeriodFile, period}} {
+		*f.path = filepath.Join(dir, filepath.Base(*f.path))
+		if f.content == "" {
+			continue
+		}
+		if err := os.WriteFile(*f.path, []byte(f.content+"\n"), 0644); err != nil {
+			t.Fatalf("writing %s: %v", *f.path, err)
+		}
+	}
+}
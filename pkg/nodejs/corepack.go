@@ -0,0 +1,117 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// corepackMetadataPrefix prefixes the version stored in the layer metadata when the package
+	// manager was installed with corepack, so that switching to or from corepack clears the layer.
+	corepackMetadataPrefix = "corepack:"
+)
+
+// PackageManagerSpec returns the name and the version of the package manager declared in the
+// packageManager field of package.json, for example `pnpm` and `9.1.0` for
+// `pnpm@9.1.0+sha512.abc`. It returns empty strings if no package manager is declared.
+func PackageManagerSpec(pjs *PackageJSON) (string, string) {
+	if pjs == nil || pjs.PackageManager == "" {
+		return "", ""
+	}
+	name, version, _ := strings.Cut(strings.TrimSpace(pjs.PackageManager), "@")
+	version, _, _ = strings.Cut(version, "+")
+	return name, version
+}
+
+// installWithCorepack provisions the exact package manager version declared in the packageManager
+// field of package.json with corepack, in the given layer. It returns false if package.json does
+// not declare a version of pm, in which case the package manager must be installed otherwise.
+func installWithCorepack(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON, pm string) (bool, error) {
+	name, version := PackageManagerSpec(pjs)
+	if name == "" {
+		return false, nil
+	}
+	if name != pm {
+		ctx.Warnf("Ignoring packageManager %q in package.json because the application is built with %s", pjs.PackageManager, pm)
+		return false, nil
+	}
+	if version == "" {
+		return false, gcp.UserErrorf("packageManager %q in package.json must specify an exact version, for example %s@1.2.3", pjs.PackageManager, pm)
+	}
+
+	home := filepath.Join(l.Path, "corepack")
+	bin := filepath.Join(l.Path, "bin")
+	corepackEnv := []string{"COREPACK_HOME=" + home, "COREPACK_ENABLE_DOWNLOAD_PROMPT=0"}
+	meta := corepackMetadataPrefix + pjs.PackageManager
+	if ctx.GetMetadata(l, versionKey) == meta {
+		ctx.CacheHit(l.Name)
+		ctx.Logf("%s cache hit: %q, skipping installation.", pm, pjs.PackageManager)
+	} else {
+		ctx.CacheMiss(l.Name)
+		if err := ctx.ClearLayer(l); err != nil {
+			return false, gcp.InternalErrorf("clearing layer %q: %w", l.Name, err)
+		}
+		if err := ctx.MkdirAll(bin, 0755); err != nil {
+			return false, err
+		}
+		corepack, err := corepackBinary(ctx, l)
+		if err != nil {
+			return false, err
+		}
+		ctx.Logf("Installing %s v%s with corepack", pm, version)
+		if _, err := ctx.Exec([]string{corepack, "enable", "--install-directory", bin, pm}, gcp.WithEnv(corepackEnv...), gcp.WithUserAttribution); err != nil {
+			return false, err
+		}
+		if _, err := ctx.Exec([]string{corepack, "prepare", pm + "@" + version, "--activate"}, gcp.WithEnv(corepackEnv...), gcp.WithUserAttribution); err != nil {
+			return false, gcp.UserErrorf("installing %s with corepack: %w", pjs.PackageManager, err)
+		}
+	}
+	ctx.SetMetadata(l, versionKey, meta)
+
+	// The shims in the bin directory of the layer are added to the PATH at launch, and use the
+	// package manager provisioned in COREPACK_HOME without downloading it again.
+	l.SharedEnvironment.Override("COREPACK_HOME", home)
+	l.SharedEnvironment.Override("COREPACK_ENABLE_DOWNLOAD_PROMPT", "0")
+	l.LaunchEnvironment.Default("COREPACK_ENABLE_NETWORK", "0")
+	for _, e := range corepackEnv {
+		k, v, _ := strings.Cut(e, "=")
+		if err := ctx.Setenv(k, v); err != nil {
+			return false, err
+		}
+	}
+	if err := ctx.Setenv("PATH", bin+":"+os.Getenv("PATH")); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// corepackBinary returns the corepack executable. Corepack is bundled with Node.js from v16.9 to
+// v24, and is installed from the npm registry into the layer for other versions.
+func corepackBinary(ctx *gcp.Context, l *libcnb.Layer) (string, error) {
+	if _, err := ctx.Exec([]string{"corepack", "--version"}); err == nil {
+		return "corepack", nil
+	}
+	ctx.Logf("corepack is not bundled with this Node.js version, installing it")
+	if _, err := ctx.Exec([]string{"npm", "install", "--global", "--prefix", l.Path, "corepack"}, gcp.WithUserAttribution); err != nil {
+		return "", gcp.UserErrorf("installing corepack: %w", err)
+	}
+	return filepath.Join(l.Path, "bin", "corepack"), nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestPackageManagerSpec(t *testing.T) {
+	testCases := []struct {
+		packageManager string
+		wantName       string
+		wantVersion    string
+	}{
+		{packageManager: ""},
+		{packageManager: "pnpm@9.1.0", wantName: "pnpm", wantVersion: "9.1.0"},
+		{packageManager: "yarn@4.2.2+sha512.0123abcd", wantName: "yarn", wantVersion: "4.2.2"},
+		{packageManager: "yarn", wantName: "yarn"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.packageManager, func(t *testing.T) {
+			name, version := PackageManagerSpec(&PackageJSON{PackageManager: tc.packageManager})
+			if name != tc.wantName || version != tc.wantVersion {
+				t.Errorf("PackageManagerSpec(%q) = %q, %q, want %q, %q", tc.packageManager, name, version, tc.wantName, tc.wantVersion)
+			}
+		})
+	}
+}
+
+func TestInstallWithCorepack(t *testing.T) {
+	testCases := []struct {
+		name           string
+		packageManager string
+		pm             string
+		metadata       string
+		mocks          []*mockprocess.Mock
+		want           bool
+		wantErr        bool
+	}{
+		{
+			name: "no packageManager",
+			pm:   "pnpm",
+		},
+		{
+			name:           "other package manager",
+			packageManager: "yarn@4.2.2",
+			pm:             "pnpm",
+		},
+		{
+			name:           "install",
+			packageManager: "pnpm@9.1.0+sha512.abc",
+			pm:             "pnpm",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^corepack --version$`, mockprocess.WithStdout("0.28.0")),
+				mockprocess.New(`^corepack enable --install-directory .*/bin pnpm$`),
+				mockprocess.New(`^corepack prepare pnpm@9.1.0 --activate$`),
+			},
+			want: true,
+		},
+		{
+			name:           "install corepack",
+			packageManager: "yarn@4.2.2",
+			pm:             "yarn",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^corepack --version$`, mockprocess.WithExitCode(127)),
+				mockprocess.New(`^npm install --global --prefix .* corepack$`),
+				mockprocess.New(`/bin/corepack enable --install-directory .*/bin yarn$`),
+				mockprocess.New(`/bin/corepack prepare yarn@4.2.2 --activate$`),
+			},
+			want: true,
+		},
+		{
+			name:           "cached",
+			packageManager: "pnpm@9.1.0",
+			pm:             "pnpm",
+			metadata:       "corepack:pnpm@9.1.0",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`corepack`, mockprocess.WithExitCode(1)),
+			},
+			want: true,
+		},
+		{
+			name:           "no version",
+			packageManager: "pnpm",
+			pm:             "pnpm",
+			wantErr:        true,
+		},
+		{
+			name:           "prepare fails",
+			packageManager: "pnpm@99.0.0",
+			pm:             "pnpm",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^corepack --version$`, mockprocess.WithStdout("0.28.0")),
+				mockprocess.New(`^corepack enable`),
+				mockprocess.New(`^corepack prepare`, mockprocess.WithExitCode(1)),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("COREPACK_HOME", "")
+			t.Setenv("COREPACK_ENABLE_DOWNLOAD_PROMPT", "")
+			t.Setenv("PATH", "/usr/bin")
+			ctx := gcp.NewContext(getContextOpts(t, tc.mocks)...)
+			l := &libcnb.Layer{
+				Name:              tc.pm,
+				Path:              t.TempDir(),
+				Metadata:          map[string]any{},
+				SharedEnvironment: libcnb.Environment{},
+				LaunchEnvironment: libcnb.Environment{},
+			}
+			if tc.metadata != "" {
+				l.Metadata[versionKey] = tc.metadata
+			}
+
+			got, err := installWithCorepack(ctx, l, &PackageJSON{PackageManager: tc.packageManager}, tc.pm)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("installWithCorepack() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("installWithCorepack() = %v, want %v", got, tc.want)
+			}
+			if got {
+				if want := "corepack:" + tc.packageManager; l.Metadata[versionKey] != want {
+					t.Errorf("layer metadata %s = %v, want %q", versionKey, l.Metadata[versionKey], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// lockfileHashKey is the metadata key used to store the dependency cache key in the
+	// dependencies layer.
+	lockfileHashKey = "lockfile_hash"
+	// nodeModulesDirsKey is the metadata key used to store the node_modules directories cached in
+	// the dependencies layer, relative to the application root.
+	nodeModulesDirsKey = "node_modules_dirs"
+)
+
+// InstallDependencies installs the dependencies of the application by calling install and caches
+// the resulting node_modules directories in the given layer. The cache is keyed on the SHA-256 of
+// the lockfile, the package.json files of the application and its workspace packages, the patches
+// applied to the dependencies, the major version of Node.js, the NODE_ENV the dependencies are
+// installed with and the InstallCommandEnv command, so builds with unchanged dependencies restore
+// node_modules without running install. The lifecycle scripts of the root package, such as
+// postinstall, are still run with pkgTool on restored node_modules, as install would run them.
+// patch-package patches are applied after install so that the cached node_modules are patched. If
+// the restored node_modules have executables whose targets are missing, the cache is considered
+// corrupted and the dependencies are installed again.
+func InstallDependencies(ctx *gcp.Context, l *libcnb.Layer, pkgTool, lockfile, nodeEnv string, install func() error) error {
+	major, err := nodeMajorVersion(ctx)
+	if err != nil {
+		return err
+	}
+	sparseDirs, err := SparseWorkspaceDirs(ctx)
+	if err != nil {
+		return err
+	}
+	pjs, err := ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	patches, err := PatchFiles(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	packageJSONs, err := packageJSONFiles(ctx.ApplicationRoot())
+	if err != nil {
+		return gcp.InternalErrorf("finding package.json files: %w", err)
+	}
+	opts := []cache.Option{
+		cache.WithFiles(filepath.Join(ctx.ApplicationRoot(), lockfile)),
+		cache.WithStrings(major, nodeEnv),
+		// A custom install command may install different dependencies from the same lockfile.
+		cache.WithStrings(os.Getenv(InstallCommandEnv)),
+	}
+	for _, f := range packageJSONs {
+		// Overrides, workspaces and dependencies missing from a stale lockfile are only declared in
+		// the package.json files. Their paths are hashed too, as moving a workspace package changes
+		// where its dependencies are installed.
+		opts = append(opts, cache.WithStrings(f), cache.WithFiles(filepath.Join(ctx.ApplicationRoot(), f)))
+	}
+	for _, patch := range patches {
+		// The names of the patches are hashed too, as patch-package reads the package version from them.
+		opts = append(opts, cache.WithStrings(patch), cache.WithFiles(filepath.Join(ctx.ApplicationRoot(), patch)))
+	}
+	if sparseDirs != nil {
+		// A different package of the same monorepo caches different node_modules directories.
+		ctx.Logf("Sparse workspace, only caching node_modules of %s and the application root.", strings.Join(sparseDirs, ", "))
+		opts = append(opts, cache.WithStrings(sparseDirs...))
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, lockfileHashKey, opts...)
+	if err != nil {
+		return err
+	}
+	if dirs := ctx.GetMetadata(l, nodeModulesDirsKey); cached && dirs != "" {
+		ctx.Logf("%s is unchanged, restoring node_modules from the cache.", lockfile)
+		restored := strings.Split(dirs, ",")
+		for _, dir := range restored {
+			if err := copyDir(ctx, filepath.Join(l.Path, dir), filepath.Join(ctx.ApplicationRoot(), dir)); err != nil {
+				return err
+			}
+		}
+		link, err := brokenBinLink(ctx.ApplicationRoot(), restored)
+		if err != nil {
+			return gcp.InternalErrorf("checking the restored node_modules: %w", err)
+		}
+		if link == "" {
+			return runRootLifecycleScripts(ctx, pkgTool, pjs, nodeEnv)
+		}
+		ctx.CacheCorrupted(l, fmt.Sprintf("%s points to a missing file", link))
+		for _, dir := range restored {
+			if err := ctx.RemoveAll(ctx.ApplicationRoot(), dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	if err := install(); err != nil {
+		return err
+	}
+	if err := ApplyPatchPackage(ctx, pjs); err != nil {
+		return err
+	}
+	dirs, err := nodeModulesDirs(ctx.ApplicationRoot(), sparseDirs)
+	if err != nil {
+		return gcp.InternalErrorf("finding node_modules directories: %w", err)
+	}
+	for _, dir := range dirs {
+		if err := copyDir(ctx, filepath.Join(ctx.ApplicationRoot(), dir), filepath.Join(l.Path, dir)); err != nil {
+			return err
+		}
+	}
+	cache.Add(ctx, l, lockfileHashKey, hash)
+	ctx.SetMetadata(l, nodeModulesDirsKey, strings.Join(dirs, ","))
+	return nil
+}
+
+// rootLifecycleScripts are the scripts of the root package run by the package managers when they
+// install the dependencies, in the order they run them.
+var rootLifecycleScripts = []string{"preinstall", "install", "postinstall", "prepare"}
+
+// runRootLifecycleScripts runs the install lifecycle scripts declared in pjs with pkgTool.
+func runRootLifecycleScripts(ctx *gcp.Context, pkgTool string, pjs *PackageJSON, nodeEnv string) error {
+	if pjs == nil {
+		return nil
+	}
+	for _, script := range rootLifecycleScripts {
+		if _, ok := pjs.Scripts[script]; !ok {
+			continue
+		}
+		ctx.Logf("Running the %s script of the application on the restored node_modules.", script)
+		if _, err := ctx.Exec([]string{pkgTool, "run", script}, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithEnv(PackageManagerConfigEnv(ctx, pkgTool)...), gcp.WithUserAttribution); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packageJSONFiles returns the package.json files in root, relative to it and sorted, which are
+// the manifests of the application and of its workspace packages. Dependency and VCS directories
+// are not searched.
+func packageJSONFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != root && (name == "node_modules" || name == ".git") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "package.json" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// nodeMajorVersion returns the major version of the installed Node.js, or the full version if it
+// cannot be parsed.
+func nodeMajorVersion(ctx *gcp.Context) (string, error) {
+	nodeVer, err := nodeVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	version, err := semver.NewVersion(strings.TrimSpace(nodeVer))
+	if err != nil {
+		ctx.Debugf("Parsing Node.js version %q: %v", nodeVer, err)
+		return nodeVer, nil
+	}
+	return fmt.Sprint(version.Major()), nil
+}
+
+// nodeModulesDirs returns the node_modules directories of the application and its workspace
+// packages, relative to root. If sparseDirs is not nil only the node_modules directory of root and
+// those in sparseDirs are returned, without walking the rest of the tree.
+func nodeModulesDirs(root string, sparseDirs []string) ([]string, error) {
+	if sparseDirs == nil {
+		dirs, err := walkNodeModulesDirs(root, root)
+		sort.Strings(dirs)
+		return dirs, err
+	}
+	var dirs []string
+	if fi, err := os.Lstat(filepath.Join(root, "node_modules")); err == nil && fi.IsDir() {
+		dirs = append(dirs, "node_modules")
+	}
+	for _, dir := range sparseDirs {
+		found, err := walkNodeModulesDirs(root, filepath.Join(root, dir))
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, found...)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// walkNodeModulesDirs returns the node_modules directories in dir, relative to root.
+func walkNodeModulesDirs(root, dir string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		switch d.Name() {
+		case ".git", ".next":
+			return filepath.SkipDir
+		case "node_modules":
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, rel)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// copyDir replaces dest with a copy of the directory src, preserving the symlinks created by pnpm
+// and workspaces in node_modules.
+func copyDir(ctx *gcp.Context, src, dest string) error {
+	if err := ctx.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err := ctx.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	_, err := ctx.Exec([]string{"cp", "--archive", src, dest}, gcp.WithUserTimingAttribution)
+	return err
+}
+
+// brokenBinLink returns the first executable link in the .bin directory of the given node_modules
+// directories, relative to root, whose target does not exist, or "" if there is none. Such links
+// fail the scripts running the executable with ENOENT.
+func brokenBinLink(root string, dirs []string) (string, error) {
+	for _, dir := range dirs {
+		bin := filepath.Join(dir, ".bin")
+		entries, err := os.ReadDir(filepath.Join(root, bin))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			if e.Type()&fs.ModeSymlink == 0 {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(root, bin, e.Name())); errors.Is(err, fs.ErrNotExist) {
+				return filepath.Join(bin, e.Name()), nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,301 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInstallDependencies(t *testing.T) {
+	testCases := []struct {
+		name        string
+		nodeVersion string
+		lockfile    string
+		nodeEnv     string
+		// installCommand is the InstallCommandEnv value of the second build.
+		installCommand string
+		files          map[string]string
+		// deleted are the files of the first build which are removed from the second build.
+		deleted []string
+		// brokenLink is a link to a missing file added to the cached layer after the first build.
+		brokenLink  string
+		wantInstall bool
+	}{
+		{
+			name:        "unchanged",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+		},
+		{
+			name:        "node patch upgrade",
+			nodeVersion: "v20.12.0",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+		},
+		{
+			name:        "node major upgrade",
+			nodeVersion: "v22.1.0",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+			wantInstall: true,
+		},
+		{
+			name:        "lockfile changed",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'\n# changed",
+			nodeEnv:     EnvProduction,
+			wantInstall: true,
+		},
+		{
+			name:        "node env changed",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvDevelopment,
+			wantInstall: true,
+		},
+		{
+			name:           "install command changed",
+			nodeVersion:    "v20.11.1",
+			lockfile:       "lockfileVersion: '9.0'",
+			nodeEnv:        EnvProduction,
+			installCommand: "pnpm install --filter web",
+			wantInstall:    true,
+		},
+		{
+			name:        "package.json changed",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+			files:       map[string]string{"package.json": `{"dependencies": {"left-pad": "1.3.0"}, "overrides": {"left-pad": "1.2.0"}}`},
+			wantInstall: true,
+		},
+		{
+			name:        "workspace package.json changed",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+			files:       map[string]string{"packages/web/package.json": `{"dependencies": {"next": "14.2.3"}}`},
+			wantInstall: true,
+		},
+		{
+			name:        "workspace package moved",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+			files:       map[string]string{"packages/site/package.json": `{"dependencies": {"next": "14.2.0"}}`},
+			deleted:     []string{"packages/web/package.json"},
+			wantInstall: true,
+		},
+		{
+			name:        "patch-package patch added",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+			files:       map[string]string{"patches/left-pad+1.3.0.patch": "diff --git a/node_modules/left-pad/index.js"},
+			wantInstall: true,
+		},
+		{
+			name:        "pnpm patched dependency added",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+			files: map[string]string{
+				"package.json":         `{"pnpm": {"patchedDependencies": {"left-pad@1.3.0": "fixes/left-pad.patch"}}}`,
+				"fixes/left-pad.patch": "diff --git a/index.js b/index.js",
+			},
+			wantInstall: true,
+		},
+		{
+			name:        "corrupted executable link",
+			nodeVersion: "v20.11.1",
+			lockfile:    "lockfileVersion: '9.0'",
+			nodeEnv:     EnvProduction,
+			brokenLink:  "packages/web/node_modules/.bin/next",
+			wantInstall: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func(*gcp.Context) (string, error)) { nodeVersion = fn }(nodeVersion)
+			l := &libcnb.Layer{Name: "pnpm_modules", Path: t.TempDir(), Metadata: map[string]any{}}
+			wantDirs := []string{"node_modules", "packages/web/node_modules"}
+			install := func(root string) func() error {
+				return func() error {
+					for _, dir := range wantDirs {
+						writeFiles(t, root, map[string]string{filepath.Join(dir, "next/package.json"): "{}"})
+					}
+					return nil
+				}
+			}
+
+			// Populate the cache with a first build.
+			nodeVersion = func(*gcp.Context) (string, error) { return "v20.11.1", nil }
+			appFiles := map[string]string{
+				"package.json":              `{"dependencies": {"left-pad": "1.3.0"}}`,
+				"packages/web/package.json": `{"dependencies": {"next": "14.2.0"}}`,
+			}
+			first := t.TempDir()
+			writeFiles(t, first, map[string]string{PNPMLock: "lockfileVersion: '9.0'"})
+			writeFiles(t, first, appFiles)
+			if err := InstallDependencies(gcp.NewContext(gcp.WithApplicationRoot(first)), l, "pnpm", PNPMLock, EnvProduction, install(first)); err != nil {
+				t.Fatalf("InstallDependencies() got error: %v", err)
+			}
+
+			if tc.brokenLink != "" {
+				link := filepath.Join(l.Path, tc.brokenLink)
+				if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink("../next/dist/bin/next", link); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			nodeVersion = func(*gcp.Context) (string, error) { return tc.nodeVersion, nil }
+			if tc.installCommand != "" {
+				t.Setenv(InstallCommandEnv, tc.installCommand)
+			}
+			second := t.TempDir()
+			writeFiles(t, second, map[string]string{PNPMLock: tc.lockfile})
+			writeFiles(t, second, appFiles)
+			writeFiles(t, second, tc.files)
+			for _, f := range tc.deleted {
+				if err := os.Remove(filepath.Join(second, f)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			installed := false
+			err := InstallDependencies(gcp.NewContext(gcp.WithApplicationRoot(second)), l, "pnpm", PNPMLock, tc.nodeEnv, func() error {
+				installed = true
+				return install(second)()
+			})
+			if err != nil {
+				t.Fatalf("InstallDependencies() got error: %v", err)
+			}
+			if installed != tc.wantInstall {
+				t.Errorf("InstallDependencies() ran install = %t, want %t", installed, tc.wantInstall)
+			}
+			for _, dir := range wantDirs {
+				if _, err := os.Stat(filepath.Join(second, dir, "next/package.json")); err != nil {
+					t.Errorf("%s was not installed or restored: %v", dir, err)
+				}
+			}
+			if tc.brokenLink != "" {
+				if _, err := os.Lstat(filepath.Join(second, tc.brokenLink)); err == nil {
+					t.Errorf("%s was restored from the corrupted cache", tc.brokenLink)
+				}
+			}
+		})
+	}
+}
+
+func TestNodeModulesDirs(t *testing.T) {
+	testCases := []struct {
+		name       string
+		sparseDirs []string
+		want       []string
+	}{
+		{
+			name: "whole tree",
+			want: []string{"apps/api/node_modules", "apps/web/node_modules", "node_modules", "packages/ui/node_modules"},
+		},
+		{
+			name:       "sparse workspace",
+			sparseDirs: []string{"apps/web", "packages/ui"},
+			want:       []string{"apps/web/node_modules", "node_modules", "packages/ui/node_modules"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				"node_modules/next/node_modules/react/package.json": "{}",
+				"apps/web/node_modules/.bin/next":                   "",
+				"apps/web/.next/cache/node_modules/x.js":            "",
+				"apps/api/node_modules/.bin/tsc":                    "",
+				"packages/ui/node_modules/.bin/tsc":                 "",
+			})
+			got, err := nodeModulesDirs(root, tc.sparseDirs)
+			if err != nil {
+				t.Fatalf("nodeModulesDirs() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("nodeModulesDirs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPackageJSONFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"package.json":                   "{}",
+		"packages/web/package.json":      "{}",
+		"packages/web/src/index.js":      "",
+		"node_modules/next/package.json": "{}",
+		".git/package.json":              "{}",
+	})
+
+	got, err := packageJSONFiles(root)
+	if err != nil {
+		t.Fatalf("packageJSONFiles() got error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"package.json", "packages/web/package.json"}, got); diff != "" {
+		t.Errorf("packageJSONFiles() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunRootLifecycleScripts(t *testing.T) {
+	testCases := []struct {
+		name    string
+		scripts map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "postinstall runs",
+			scripts: map[string]string{"postinstall": "node setup.js"},
+			wantErr: true,
+		},
+		{
+			name:    "other scripts do not run",
+			scripts: map[string]string{"build": "next build"},
+		},
+		{
+			name: "no scripts",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The scripts fail, so that an error means they ran.
+			mocks := []*mockprocess.Mock{
+				mockprocess.New(`^pnpm run`, mockprocess.WithStderr("script failed"), mockprocess.WithExitCode(1)),
+			}
+			ctx := gcp.NewContext(append(getContextOpts(t, mocks), gcp.WithApplicationRoot(t.TempDir()))...)
+
+			err := runRootLifecycleScripts(ctx, "pnpm", &PackageJSON{Scripts: tc.scripts}, EnvProduction)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("runRootLifecycleScripts() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
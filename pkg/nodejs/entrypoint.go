@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// frameworkServers are the servers written by framework builds, which start the application when
+// there is no start script. An empty dependency matches every application.
+var frameworkServers = []struct {
+	dependency string
+	server     string
+}{
+	// Nitro, used by SolidStart, Analog and TanStack Start.
+	{server: ".output/server/index.mjs"},
+	// The Node.js adapter of SvelteKit.
+	{dependency: "@sveltejs/adapter-node", server: "build/index.js"},
+}
+
+// exportsConditions are the conditions of an exports map used to find the entrypoint, in the order
+// Node.js prefers them when the application is imported as an ES module.
+var exportsConditions = []string{"node", "import", "default", "require"}
+
+// indexFiles are the entrypoints Node.js applications fall back to, in order of preference.
+var indexFiles = []string{"index.js", "index.mjs", "index.cjs"}
+
+// frameworkServer returns the path of the server built by a framework in appDir, relative to it,
+// or an empty string if there is none.
+func frameworkServer(ctx *gcp.Context, appDir string, pjs *PackageJSON) (string, error) {
+	for _, fs := range frameworkServers {
+		if fs.dependency != "" && !hasDependency(pjs, fs.dependency) {
+			continue
+		}
+		exists, err := ctx.FileExists(appDir, fs.server)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return fs.server, nil
+		}
+	}
+	return "", nil
+}
+
+// exportsEntry returns the file of the main entry of an exports map of package.json, or an empty
+// string if it has none. Exports may be a path, a map of subpaths such as "." or a map of
+// conditions, which may be nested.
+// Example: `{".": {"import": "./dist/index.mjs", "require": "./dist/index.cjs"}}`.
+func exportsEntry(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var path string
+	if err := json.Unmarshal(raw, &path); err == nil {
+		return cleanExportsPath(path)
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return ""
+	}
+	if main, ok := entries["."]; ok {
+		return exportsEntry(main)
+	}
+	for key := range entries {
+		// Subpaths other than "." are not entrypoints of the application.
+		if strings.HasPrefix(key, ".") {
+			return ""
+		}
+	}
+	for _, condition := range exportsConditions {
+		if entry := exportsEntry(entries[condition]); entry != "" {
+			return entry
+		}
+	}
+	return ""
+}
+
+// cleanExportsPath returns the path of an exports target relative to the package, or an empty
+// string for patterns.
+func cleanExportsPath(path string) string {
+	if path == "" || strings.Contains(path, "*") {
+		return ""
+	}
+	return filepath.Clean(path)
+}
+
+// indexStartCommand returns the command starting the index file of the application, preferring
+// index.js. The type of the module is determined by node from its extension and the "type" field
+// of package.json.
+func indexStartCommand(ctx *gcp.Context, appDir string) ([]string, error) {
+	for _, index := range indexFiles {
+		exists, err := ctx.FileExists(appDir, index)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return []string{"node", index}, nil
+		}
+	}
+	return []string{"node", "index.js"}, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExportsEntry(t *testing.T) {
+	testCases := []struct {
+		name    string
+		exports string
+		want    string
+	}{
+		{
+			name: "no exports",
+		},
+		{
+			name:    "path",
+			exports: `"./dist/index.mjs"`,
+			want:    "dist/index.mjs",
+		},
+		{
+			name:    "conditions",
+			exports: `{"require": "./dist/index.cjs", "import": "./dist/index.mjs"}`,
+			want:    "dist/index.mjs",
+		},
+		{
+			name:    "node condition is preferred",
+			exports: `{"import": "./dist/index.mjs", "node": "./dist/server.mjs"}`,
+			want:    "dist/server.mjs",
+		},
+		{
+			name:    "subpaths",
+			exports: `{".": {"types": "./dist/index.d.ts", "default": "./dist/index.js"}, "./utils": "./dist/utils.js"}`,
+			want:    "dist/index.js",
+		},
+		{
+			name:    "nested conditions",
+			exports: `{".": {"import": {"types": "./dist/index.d.mts", "default": "./dist/index.mjs"}}}`,
+			want:    "dist/index.mjs",
+		},
+		{
+			name:    "subpaths without main entry",
+			exports: `{"./utils": "./dist/utils.js"}`,
+		},
+		{
+			name:    "pattern",
+			exports: `{".": "./dist/*.js"}`,
+		},
+		{
+			name:    "fallback array",
+			exports: `["./dist/index.mjs"]`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exportsEntry(json.RawMessage(tc.exports)); got != tc.want {
+				t.Errorf("exportsEntry(%s) = %q, want %q", tc.exports, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// heapSizeExecD is the name of the exec.d executable which sets the V8 heap size at launch.
+	heapSizeExecD = "max-old-space-size"
+	// heapSizePercent is the share of the memory of the instance given to the V8 old space. The rest
+	// is left for the new space, code, native buffers and the Node.js runtime itself.
+	heapSizePercent = 75
+	// minHeapSizeMiB is the lowest heap size set, below which Node.js fails to start most apps.
+	minHeapSizeMiB = 128
+)
+
+// heapSizeExecDTemplate is an exec.d executable which appends --max-old-space-size to NODE_OPTIONS
+// unless it already sets the heap size. It runs at launch so that NODE_OPTIONS set at runtime are
+// preserved. Values which would need escaping in the TOML output are left untouched.
+const heapSizeExecDTemplate = `#!/bin/sh
+case "$NODE_OPTIONS" in
+  *--max-old-space-size*|*\"*|*\\*) exit 0 ;;
+esac
+echo "NODE_OPTIONS = \"${NODE_OPTIONS:+$NODE_OPTIONS }--max-old-space-size=%d\"" >&3
+`
+
+// HeapSizeMiB returns the V8 heap size of an instance with the given memory, or 0 if the memory is
+// too small to set one.
+func HeapSizeMiB(memoryMiB int32) int32 {
+	size := memoryMiB * heapSizePercent / 100
+	if size < minHeapSizeMiB {
+		return 0
+	}
+	return size
+}
+
+// WriteHeapSizeExecD writes an exec.d executable to the given launch layer which sizes the V8 heap
+// to the memory of the instance, as the Node.js default does not take the container memory limit
+// into account and apps are OOM killed before the garbage collector runs.
+func WriteHeapSizeExecD(ctx *gcp.Context, l *libcnb.Layer, memoryMiB int32) error {
+	size := HeapSizeMiB(memoryMiB)
+	if size == 0 {
+		return nil
+	}
+	if err := ctx.MkdirAll(l.Exec.Path, 0755); err != nil {
+		return err
+	}
+	ctx.Logf("Setting the Node.js heap size to %d MiB for instances with %d MiB of memory", size, memoryMiB)
+	return ctx.WriteFile(l.Exec.FilePath(heapSizeExecD), []byte(fmt.Sprintf(heapSizeExecDTemplate, size)), 0755)
+}
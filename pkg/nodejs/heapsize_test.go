@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestHeapSizeMiB(t *testing.T) {
+	testCases := []struct {
+		memoryMiB int32
+		want      int32
+	}{
+		{memoryMiB: 128, want: 0},
+		{memoryMiB: 512, want: 384},
+		{memoryMiB: 2048, want: 1536},
+		{memoryMiB: 32768, want: 24576},
+	}
+	for _, tc := range testCases {
+		if got := HeapSizeMiB(tc.memoryMiB); got != tc.want {
+			t.Errorf("HeapSizeMiB(%d) = %d, want %d", tc.memoryMiB, got, tc.want)
+		}
+	}
+}
+
+func TestWriteHeapSizeExecD(t *testing.T) {
+	testCases := []struct {
+		name        string
+		nodeOptions string
+		want        string
+	}{
+		{
+			name: "no NODE_OPTIONS",
+			want: "NODE_OPTIONS = \"--max-old-space-size=1536\"\n",
+		},
+		{
+			name:        "appends to NODE_OPTIONS",
+			nodeOptions: "--enable-source-maps",
+			want:        "NODE_OPTIONS = \"--enable-source-maps --max-old-space-size=1536\"\n",
+		},
+		{
+			name:        "keeps the heap size set in NODE_OPTIONS",
+			nodeOptions: "--max-old-space-size=4096",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+			l, err := ctx.Layer("heap", gcp.LaunchLayer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteHeapSizeExecD(ctx, l, 2048); err != nil {
+				t.Fatalf("WriteHeapSizeExecD() got error: %v", err)
+			}
+			cmd := exec.Command("sh", "-c", l.Exec.FilePath(heapSizeExecD)+" 3>&1")
+			cmd.Env = append(os.Environ(), "NODE_OPTIONS="+tc.nodeOptions)
+			got, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("running exec.d executable: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("exec.d output = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteHeapSizeExecDSmallMemory(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	l, err := ctx.Layer("heap", gcp.LaunchLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteHeapSizeExecD(ctx, l, 128); err != nil {
+		t.Fatalf("WriteHeapSizeExecD() got error: %v", err)
+	}
+	if _, err := os.Stat(l.Exec.FilePath(heapSizeExecD)); !os.IsNotExist(err) {
+		t.Errorf("exec.d executable was written for 128 MiB of memory, want none")
+	}
+}
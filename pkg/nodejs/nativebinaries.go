@@ -0,0 +1,162 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// nativeBinariesLayer is the name of the cache layer of the native binary packages.
+	nativeBinariesLayer = "native_binaries"
+	// nextSWCPathEnv is the env var Next.js downloads the SWC binary to when the platform package
+	// is not installed.
+	nextSWCPathEnv = "NEXT_SWC_PATH"
+)
+
+// nativeBinaryParents are the packages which install their platform-specific native binaries,
+// such as @next/swc-linux-x64-gnu or @esbuild/linux-x64, as optionalDependencies.
+var nativeBinaryParents = []string{"next", "esbuild", "@swc/core"}
+
+// nativePackageJSON is the subset of package.json used to find and select native binary packages.
+type nativePackageJSON struct {
+	Version              string            `json:"version"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	OS                   []string          `json:"os"`
+	CPU                  []string          `json:"cpu"`
+	Libc                 []string          `json:"libc"`
+}
+
+// WithNativeBinaryCache runs install with the native binaries of Next.js, SWC and esbuild cached
+// in a dedicated layer. The platform packages are cached by name and version after install and
+// restored into node_modules when the package manager skipped them, which happens with lock files
+// generated on another platform, so that the build does not download them again. The SWC binary
+// Next.js downloads itself is kept in the layer too.
+func WithNativeBinaryCache(ctx *gcp.Context, install func() error) error {
+	l, err := ctx.Layer(nativeBinariesLayer, gcp.CacheLayer)
+	if err != nil {
+		return gcp.InternalErrorf("creating %v layer: %w", nativeBinariesLayer, err)
+	}
+	if _, ok := os.LookupEnv(nextSWCPathEnv); !ok {
+		if err := ctx.Setenv(nextSWCPathEnv, filepath.Join(l.Path, "next-swc")); err != nil {
+			return err
+		}
+	}
+	if err := install(); err != nil {
+		return err
+	}
+	return syncNativeBinaries(ctx, filepath.Join(l.Path, "packages"), filepath.Join(ctx.ApplicationRoot(), "node_modules"))
+}
+
+// syncNativeBinaries copies the native binary packages installed in nodeModules to cacheDir, and
+// the cached packages for this platform which are missing from nodeModules back. Cached packages
+// no longer depended on are removed.
+func syncNativeBinaries(ctx *gcp.Context, cacheDir, nodeModules string) error {
+	used := map[string]bool{}
+	saved, restored := 0, 0
+	for _, parent := range nativeBinaryParents {
+		pjs, err := readNativePackageJSON(filepath.Join(nodeModules, parent))
+		if err != nil {
+			return err
+		}
+		if pjs == nil {
+			continue
+		}
+		for name, version := range pjs.OptionalDependencies {
+			cached := filepath.Join(cacheDir, strings.ReplaceAll(name, "/", "+")+"@"+version)
+			installed := filepath.Join(nodeModules, name)
+			installedPjs, err := readNativePackageJSON(installed)
+			if err != nil {
+				return err
+			}
+			cachedPjs, err := readNativePackageJSON(cached)
+			if err != nil {
+				return err
+			}
+			switch {
+			case installedPjs != nil && installedPjs.Version == version:
+				used[filepath.Base(cached)] = true
+				if cachedPjs != nil {
+					continue
+				}
+				if err := copyDir(ctx, installed, cached); err != nil {
+					return err
+				}
+				saved++
+			case installedPjs == nil && cachedPjs != nil && cachedPjs.matchesPlatform():
+				used[filepath.Base(cached)] = true
+				if err := copyDir(ctx, cached, installed); err != nil {
+					return err
+				}
+				restored++
+			}
+		}
+	}
+	if restored > 0 {
+		ctx.Logf("Restored %d native binary packages skipped by the package manager from the cache.", restored)
+	}
+	if saved > 0 {
+		ctx.Logf("Cached %d native binary packages for the next build.", saved)
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		return gcp.InternalErrorf("reading %s: %w", cacheDir, err)
+	}
+	for _, e := range entries {
+		if !used[e.Name()] {
+			if err := ctx.RemoveAll(cacheDir, e.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readNativePackageJSON returns the package.json of the package in dir, or nil if the package is
+// not installed.
+func readNativePackageJSON(dir string) (*nativePackageJSON, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+	var pjs nativePackageJSON
+	if err := json.Unmarshal(raw, &pjs); err != nil {
+		return nil, gcp.UserErrorf("unmarshalling %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+	return &pjs, nil
+}
+
+// matchesPlatform returns true if the package can be installed on the platform of the build, a
+// linux system with glibc.
+func (pjs *nativePackageJSON) matchesPlatform() bool {
+	cpu := runtime.GOARCH
+	if cpu == "amd64" {
+		cpu = "x64"
+	}
+	return (len(pjs.OS) == 0 || slices.Contains(pjs.OS, runtime.GOOS)) &&
+		(len(pjs.CPU) == 0 || slices.Contains(pjs.CPU, cpu)) &&
+		(len(pjs.Libc) == 0 || slices.Contains(pjs.Libc, "glibc"))
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSyncNativeBinaries(t *testing.T) {
+	next := `{"version": "15.0.3", "optionalDependencies": {"@next/swc-linux-x64-gnu": "15.0.3", "@next/swc-linux-x64-musl": "15.0.3"}}`
+	gnu := `{"version": "15.0.3", "os": ["linux"], "libc": ["glibc"]}`
+	musl := `{"version": "15.0.3", "os": ["linux"], "libc": ["musl"]}`
+	testCases := []struct {
+		name         string
+		cached       map[string]string
+		installed    map[string]string
+		wantCached   []string
+		wantRestored []string
+	}{
+		{
+			name:      "no native binaries",
+			installed: map[string]string{"express/package.json": `{"version": "4.21.0"}`},
+		},
+		{
+			name: "saves installed packages",
+			installed: map[string]string{
+				"next/package.json":                    next,
+				"@next/swc-linux-x64-gnu/package.json": gnu,
+			},
+			wantCached: []string{"@next+swc-linux-x64-gnu@15.0.3"},
+		},
+		{
+			name: "restores skipped packages for the platform",
+			cached: map[string]string{
+				"@next+swc-linux-x64-gnu@15.0.3/package.json":  gnu,
+				"@next+swc-linux-x64-musl@15.0.3/package.json": musl,
+			},
+			installed:    map[string]string{"next/package.json": next},
+			wantCached:   []string{"@next+swc-linux-x64-gnu@15.0.3"},
+			wantRestored: []string{"@next/swc-linux-x64-gnu"},
+		},
+		{
+			name:   "removes other versions",
+			cached: map[string]string{"@next+swc-linux-x64-gnu@14.2.0/package.json": `{"version": "14.2.0"}`},
+			installed: map[string]string{
+				"next/package.json":                    next,
+				"@next/swc-linux-x64-gnu/package.json": gnu,
+			},
+			wantCached: []string{"@next+swc-linux-x64-gnu@15.0.3"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			cacheDir := filepath.Join(t.TempDir(), "packages")
+			nodeModules := filepath.Join(root, "node_modules")
+			writeFiles(t, cacheDir, tc.cached)
+			writeFiles(t, nodeModules, tc.installed)
+
+			if err := syncNativeBinaries(gcp.NewContext(gcp.WithApplicationRoot(root)), cacheDir, nodeModules); err != nil {
+				t.Fatalf("syncNativeBinaries() got error: %v", err)
+			}
+
+			var gotCached []string
+			entries, err := os.ReadDir(cacheDir)
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				gotCached = append(gotCached, e.Name())
+			}
+			if diff := cmp.Diff(tc.wantCached, gotCached); diff != "" {
+				t.Errorf("cached packages mismatch (-want +got):\n%s", diff)
+			}
+			for _, pkg := range tc.wantRestored {
+				if _, err := os.Stat(filepath.Join(nodeModules, pkg, "package.json")); err != nil {
+					t.Errorf("%s was not restored: %v", pkg, err)
+				}
+			}
+		})
+	}
+}
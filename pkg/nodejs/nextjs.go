@@ -16,72 +16,380 @@ package nodejs
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 	"github.com/Masterminds/semver"
 )
 
+const (
+	// NextjsStandaloneEnv is an env var used to disable copying only the standalone server of Next.js
+	// apps built with `output: 'standalone'` into the image.
+	// Example: `false` keeps the full application and node_modules in the image.
+	NextjsStandaloneEnv = "GOOGLE_NEXTJS_STANDALONE"
+
+	// NextjsExportDir is the directory `next build` writes the static site to for apps built with
+	// `output: 'export'`.
+	NextjsExportDir = "out"
+
+	// nextjsCacheLayer is the name of the cache-only layer .next/cache is persisted in.
+	nextjsCacheLayer = "nextjs_cache"
+	// nextjsCacheKey is the metadata key used to store the hash the .next/cache layer is keyed on.
+	nextjsCacheKey = "nextjs_cache_sha"
+
+	// NextjsRoutingLayer is the name of the layer the basePath and assetPrefix of a Next.js app are
+	// stored in as metadata, for the buildpacks generating the web server config.
+	NextjsRoutingLayer = "nextjs_routing"
+	// nextjsBasePathKey is the metadata key used to store the basePath of a Next.js app.
+	nextjsBasePathKey = "base_path"
+	// nextjsAssetPrefixKey is the metadata key used to store the assetPrefix of a Next.js app.
+	nextjsAssetPrefixKey = "asset_prefix"
+)
+
 var (
-	// nextJsVersionKey is the metadata key used to store the nextjs build adaptor version in the nextjs layer.
-	nextJsVersionKey = "version"
+	// nextjsAdaptorPackage is the npm package of the nextjs build adaptor.
+	nextjsAdaptorPackage = "@apphosting/adapter-nextjs"
+
+	// nextConfigFiles are the names of the Next.js config file, in the order Next.js looks them up.
+	nextConfigFiles = []string{"next.config.js", "next.config.mjs", "next.config.ts"}
+
+	// nextStandaloneRegexp matches the standalone output option in a Next.js config file.
+	nextStandaloneRegexp = regexp.MustCompile(`output\s*:\s*["'\x60]standalone["'\x60]`)
+
+	// nextExportRegexp matches the static export output option in a Next.js config file.
+	nextExportRegexp = regexp.MustCompile(`output\s*:\s*["'\x60]export["'\x60]`)
+
+	// nextBasePathRegexp matches the basePath option in a Next.js config file.
+	nextBasePathRegexp = regexp.MustCompile(`basePath\s*:\s*["'\x60]([^"'\x60]*)["'\x60]`)
+
+	// nextAssetPrefixRegexp matches the assetPrefix option in a Next.js config file.
+	nextAssetPrefixRegexp = regexp.MustCompile(`assetPrefix\s*:\s*["'\x60]([^"'\x60]*)["'\x60]`)
+
+	// urlPathRegexp matches the URL paths which can be used in the generated nginx config as is.
+	urlPathRegexp = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
 )
 
-// InstallNextJsBuildAdaptor installs the nextjs build adaptor in the given layer if it is not already cached.
-func InstallNextJsBuildAdaptor(ctx *gcp.Context, njsl *libcnb.Layer, njsVersion string) error {
-	layerName := njsl.Name
-	version, err := detectNextjsAdaptorVersion(njsVersion)
+func init() {
+	RegisterFrameworkAdapter(&nextjsAdapter{&NpmFrameworkAdapter{
+		Framework:          "nextjs",
+		ConfigFiles:        []string{"next.config.js", "next.config.mjs"},
+		VersionPackage:     "next",
+		MinVersion:         semver.MustParse("13.0.0"),
+		AdaptorPackage:     nextjsAdaptorPackage,
+		AdaptorBuildScript: "apphosting-adapter-nextjs-build",
+		BuildScripts:       []string{"next build"},
+	}})
+}
+
+// nextjsAdapter is the FrameworkAdapter of Next.js.
+type nextjsAdapter struct {
+	*NpmFrameworkAdapter
+}
 
+// OverrideBuild overrides the build script to be the adaptor build script unless the app is a
+// static export. Static exports are served by nginx, so the server bundle the adaptor produces is
+// not needed.
+func (a *nextjsAdapter) OverrideBuild(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON) (bool, error) {
+	appDir, err := AppDir(ctx)
 	if err != nil {
-		return err
+		return false, err
+	}
+	staticExport, err := NextjsStaticExport(ctx, appDir)
+	if err != nil {
+		return false, err
+	}
+	if staticExport {
+		ctx.Logf("Next.js static export detected, skipping the Next.js build adaptor")
+		return false, nil
+	}
+	return a.NpmFrameworkAdapter.OverrideBuild(ctx, l, pjs)
+}
+
+// NextjsStandaloneOutput returns true if the Next.js config file in appDir sets
+// `output: 'standalone'`.
+func NextjsStandaloneOutput(ctx *gcp.Context, appDir string) (bool, error) {
+	return nextConfigMatches(ctx, appDir, nextStandaloneRegexp)
+}
+
+// NextjsStaticExport returns true if the Next.js config file in appDir sets `output: 'export'`,
+// in which case `next build` writes a fully static site to NextjsExportDir.
+func NextjsStaticExport(ctx *gcp.Context, appDir string) (bool, error) {
+	return nextConfigMatches(ctx, appDir, nextExportRegexp)
+}
+
+// nextConfigMatches returns true if the first Next.js config file found in appDir matches re.
+func nextConfigMatches(ctx *gcp.Context, appDir string, re *regexp.Regexp) (bool, error) {
+	raw, err := readNextConfig(ctx, appDir)
+	if err != nil {
+		return false, err
 	}
+	return re.Match(raw), nil
+}
 
-	// Check the metadata in the cache layer to determine if we need to proceed.
-	metaVersion := ctx.GetMetadata(njsl, nextJsVersionKey)
-	if version == metaVersion {
-		ctx.CacheHit(layerName)
-		ctx.Logf("nextjs adaptor cache hit: %q, %q, skipping installation.", version, metaVersion)
-	} else {
-		ctx.CacheMiss(layerName)
-		if err := ctx.ClearLayer(njsl); err != nil {
-			return fmt.Errorf("clearing layer %q: %w", layerName, err)
+// readNextConfig returns the contents of the first Next.js config file found in appDir, or nil if
+// there is none.
+func readNextConfig(ctx *gcp.Context, appDir string) ([]byte, error) {
+	for _, f := range nextConfigFiles {
+		exists, err := ctx.FileExists(appDir, f)
+		if err != nil {
+			return nil, err
 		}
-		// Download and install nextjs adaptor in layer.
-		ctx.Logf("Installing nextjs adaptor %s", version)
-		if err := downloadNextJsAdaptor(ctx, njsl.Path, version); err != nil {
-			return gcp.InternalErrorf("downloading nextjs adapter: %w", err)
+		if !exists {
+			continue
 		}
+		return ctx.ReadFile(filepath.Join(appDir, f))
 	}
+	return nil, nil
+}
 
-	// Store layer flags and metadata.
-	ctx.SetMetadata(njsl, nextJsVersionKey, version)
-	return nil
+// NextjsRouting is the URL layout of a Next.js app which is not mounted at /.
+type NextjsRouting struct {
+	// BasePath is the path prefix the app is served under, for example `/docs`, or empty if the app
+	// is served at /.
+	BasePath string
+	// AssetPrefix is the path or URL prefix of the assets of the app, or empty if the assets are
+	// served under BasePath.
+	AssetPrefix string
+}
+
+// LocalAssetPrefix returns the path prefix the assets of the app are requested with from the app
+// itself, or an empty string if they are requested under BasePath or from another origin.
+func (r NextjsRouting) LocalAssetPrefix() string {
+	if r.AssetPrefix == r.BasePath || !strings.HasPrefix(r.AssetPrefix, "/") || strings.HasPrefix(r.AssetPrefix, "//") {
+		return ""
+	}
+	return r.AssetPrefix
+}
+
+// ReadNextjsRouting returns the basePath and assetPrefix set in the Next.js config file in appDir.
+// Values computed at build time, for example from env vars, cannot be read and are left empty.
+func ReadNextjsRouting(ctx *gcp.Context, appDir string) (NextjsRouting, error) {
+	raw, err := readNextConfig(ctx, appDir)
+	if err != nil {
+		return NextjsRouting{}, err
+	}
+	var r NextjsRouting
+	if m := nextBasePathRegexp.FindSubmatch(raw); m != nil {
+		r.BasePath = string(m[1])
+	}
+	if m := nextAssetPrefixRegexp.FindSubmatch(raw); m != nil {
+		r.AssetPrefix = strings.TrimSuffix(string(m[1]), "/")
+	}
+	if r.BasePath != "" && !urlPathRegexp.MatchString(r.BasePath) {
+		return NextjsRouting{}, gcp.UserErrorf("invalid Next.js basePath %q, it must start with / and must not end with /", r.BasePath)
+	}
+	if p := r.LocalAssetPrefix(); p != "" && !urlPathRegexp.MatchString(p) {
+		return NextjsRouting{}, gcp.UserErrorf("invalid Next.js assetPrefix %q", r.AssetPrefix)
+	}
+	return r, nil
+}
+
+// WriteNextjsRoutingMetadata stores r as metadata of l, which is read back by
+// NextjsRoutingFromLayers in the buildpacks that run later.
+func WriteNextjsRoutingMetadata(ctx *gcp.Context, l *libcnb.Layer, r NextjsRouting) {
+	ctx.SetMetadata(l, nextjsBasePathKey, r.BasePath)
+	ctx.SetMetadata(l, nextjsAssetPrefixKey, r.AssetPrefix)
 }
 
-// detectNextjsAdaptorVersion determines the version of Nextjs that is needed by a nextjs project
-func detectNextjsAdaptorVersion(njsVersion string) (string, error) {
-	version, err := semver.StrictNewVersion(njsVersion)
+// NextjsRoutingFromLayers returns the routing stored by WriteNextjsRoutingMetadata in the
+// NextjsRoutingLayer of any buildpack in layersRoot, or an empty routing if no buildpack stored
+// one.
+func NextjsRoutingFromLayers(layersRoot string) (NextjsRouting, error) {
+	paths, err := filepath.Glob(filepath.Join(layersRoot, "*", NextjsRoutingLayer+".toml"))
 	if err != nil {
-		return "", gcp.InternalErrorf("parsing nextjs version: %w", err)
+		return NextjsRouting{}, gcp.InternalErrorf("finding %s layers: %w", NextjsRoutingLayer, err)
 	}
-	// match major + minor versions with the Nextjs version
-	adapterVersion := strconv.FormatUint(version.Major(), 10) + "." + strconv.FormatUint(version.Minor(), 10)
-	return adapterVersion, nil
+	for _, path := range paths {
+		var layer struct {
+			Metadata map[string]string `toml:"metadata"`
+		}
+		if _, err := toml.DecodeFile(path, &layer); err != nil {
+			return NextjsRouting{}, gcp.InternalErrorf("decoding %s: %w", path, err)
+		}
+		return NextjsRouting{BasePath: layer.Metadata[nextjsBasePathKey], AssetPrefix: layer.Metadata[nextjsAssetPrefixKey]}, nil
+	}
+	return NextjsRouting{}, nil
 }
 
-// downloadNextJsAdaptor downloads the Nextjs build adaptor into the provided directory.
-func downloadNextJsAdaptor(ctx *gcp.Context, dirPath string, version string) error {
-	if _, err := ctx.Exec([]string{"npm", "install", "--prefix", dirPath, "@apphosting/adapter-nextjs@" + version}); err != nil {
-		ctx.Logf("Failed to install nextjs adaptor version: %s. Falling back to latest", version)
-		if _, err := ctx.Exec([]string{"npm", "install", "--prefix", dirPath, "@apphosting/adapter-nextjs@latest"}); err != nil {
-			return gcp.InternalErrorf("installing nextjs adaptor: %w", err)
+// UseNextjsStandalone returns true if the Next.js app in appDir is built with
+// `output: 'standalone'` and NextjsStandaloneEnv does not disable copying only its standalone
+// server into the image.
+func UseNextjsStandalone(ctx *gcp.Context, appDir string) (bool, error) {
+	if v, ok := os.LookupEnv(NextjsStandaloneEnv); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, gcp.UserErrorf("parsing %s: %v", NextjsStandaloneEnv, err)
+		}
+		if !enabled {
+			return false, nil
 		}
 	}
+	return NextjsStandaloneOutput(ctx, appDir)
+}
+
+// CopyNextjsStandalone copies the standalone server of a Next.js app built with
+// `output: 'standalone'` into the given launch layer along with the .next/static and public
+// assets, which Next.js does not include in the standalone output. The full node_modules tree is
+// then removed from the application since the standalone server only needs the dependencies traced
+// into .next/standalone. It returns the directory containing server.js, or an empty string if the
+// app was not built with standalone output.
+func CopyNextjsStandalone(ctx *gcp.Context, l *libcnb.Layer, appDir string) (string, error) {
+	standalone, err := UseNextjsStandalone(ctx, appDir)
+	if err != nil || !standalone {
+		return "", err
+	}
+	standaloneDir := filepath.Join(appDir, ".next", "standalone")
+	// In monorepos the standalone output mirrors the workspace layout from the tracing root.
+	rel, err := filepath.Rel(ctx.ApplicationRoot(), appDir)
+	if err != nil {
+		return "", gcp.InternalErrorf("resolving %s relative to the application root: %w", appDir, err)
+	}
+	serverRel := ""
+	for _, dir := range []string{".", rel} {
+		exists, err := ctx.FileExists(standaloneDir, dir, "server.js")
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			serverRel = dir
+			break
+		}
+	}
+	if serverRel == "" {
+		ctx.CategoryWarnf(gcp.WarningSize, "output: 'standalone' is set but %s does not contain server.js, keeping the full application", standaloneDir)
+		return "", nil
+	}
+
+	ctx.Logf("Copying the Next.js standalone output into the image")
+	if err := ctx.ClearLayer(l); err != nil {
+		return "", fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	if _, err := ctx.Exec([]string{"cp", "--archive", standaloneDir + "/.", l.Path}, gcp.WithUserTimingAttribution); err != nil {
+		return "", err
+	}
+	serverDir := filepath.Join(l.Path, serverRel)
+	assets := map[string]string{
+		filepath.Join(appDir, ".next", "static"): filepath.Join(serverDir, ".next", "static"),
+		filepath.Join(appDir, "public"):          filepath.Join(serverDir, "public"),
+	}
+	for src, dest := range assets {
+		exists, err := ctx.FileExists(src)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			continue
+		}
+		if err := ctx.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return "", err
+		}
+		if _, err := ctx.Exec([]string{"cp", "--archive", src, dest}, gcp.WithUserTimingAttribution); err != nil {
+			return "", err
+		}
+	}
+
+	for _, dir := range []string{filepath.Join(ctx.ApplicationRoot(), "node_modules"), filepath.Join(appDir, "node_modules"), standaloneDir} {
+		if err := ctx.RemoveAll(dir); err != nil {
+			return "", err
+		}
+	}
+	// The standalone server binds to $HOSTNAME, which is set to the container name at runtime.
+	l.LaunchEnvironment.Override("HOSTNAME", "0.0.0.0")
+	return serverDir, nil
+}
+
+// WithNextjsBuildCache runs build with the .next/cache directory of a Next.js app restored from a
+// cache-only layer, and saves it back into the layer once build succeeds so incremental
+// compilation carries over between builds. The cache is cleared when the Next.js or the major
+// Node.js version changes. build is run as is for apps which do not use Next.js.
+func WithNextjsBuildCache(ctx *gcp.Context, pjs *PackageJSON, build func() error) error {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return err
+	}
+	nextjs, err := isNextjsApp(ctx, pjs, appDir)
+	if err != nil {
+		return err
+	}
+	if !nextjs {
+		return build()
+	}
+	l, err := ctx.Layer(nextjsCacheLayer, gcp.CacheLayer)
+	if err != nil {
+		return gcp.InternalErrorf("creating layer: %w", err)
+	}
+	return withNextjsCacheLayer(ctx, l, pjs, appDir, build)
+}
+
+func withNextjsCacheLayer(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON, appDir string, build func() error) error {
+	major, err := nodeMajorVersion(ctx)
+	if err != nil {
+		return err
+	}
+	nextVersion, err := Version(ctx, pjs, "next")
+	if err != nil {
+		ctx.Debugf("Resolving the Next.js version for the .next/cache key: %v", err)
+		nextVersion = dependencySpecifier(pjs, "next")
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, nextjsCacheKey, cache.WithStrings(nextVersion, major))
+	if err != nil {
+		return err
+	}
+	layerCache := filepath.Join(l.Path, "cache")
+	appCache := filepath.Join(appDir, ".next", "cache")
+	if cached {
+		restore, err := ctx.FileExists(layerCache)
+		if err != nil {
+			return err
+		}
+		// Never overwrite a .next/cache directory uploaded with the source.
+		if exists, err := ctx.FileExists(appCache); err != nil {
+			return err
+		} else if exists {
+			restore = false
+		}
+		if restore {
+			ctx.Logf("Restoring .next/cache from the previous build.")
+			if err := copyDir(ctx, layerCache, appCache); err != nil {
+				return err
+			}
+		}
+	} else if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+
+	if err := build(); err != nil {
+		return err
+	}
+
+	exists, err := ctx.FileExists(appCache)
+	if err != nil || !exists {
+		return err
+	}
+	if err := copyDir(ctx, appCache, layerCache); err != nil {
+		return err
+	}
+	cache.Add(ctx, l, nextjsCacheKey, hash)
 	return nil
 }
 
-// OverrideNextjsBuildScript overrides the build script to be the Nextjs build script
-func OverrideNextjsBuildScript(njsl *libcnb.Layer) {
-	njsl.BuildEnvironment.Override(AppHostingBuildEnv, fmt.Sprintf("npm exec --prefix %s apphosting-adapter-nextjs-build", njsl.Path))
+// isNextjsApp returns true if the app in appDir has a Next.js config file or depends on next.
+func isNextjsApp(ctx *gcp.Context, pjs *PackageJSON, appDir string) (bool, error) {
+	if dependencySpecifier(pjs, "next") != "" {
+		return true, nil
+	}
+	for _, f := range nextConfigFiles {
+		exists, err := ctx.FileExists(appDir, f)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
 }
@@ -14,88 +14,461 @@
 package nodejs
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
 )
 
-func TestInstallNextJsBuildAdaptor(t *testing.T) {
+func TestNextjsStandaloneOutput(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "no config",
+			files: map[string]string{},
+		},
+		{
+			name:  "default output",
+			files: map[string]string{"next.config.js": `module.exports = { reactStrictMode: true }`},
+		},
+		{
+			name:  "standalone js",
+			files: map[string]string{"next.config.js": `module.exports = { output: 'standalone' }`},
+			want:  true,
+		},
+		{
+			name: "standalone mjs",
+			files: map[string]string{"next.config.mjs": `export default {
+  output: "standalone",
+}`},
+			want: true,
+		},
+		{
+			name:  "standalone ts",
+			files: map[string]string{"next.config.ts": "const nextConfig: NextConfig = { output: `standalone` };"},
+			want:  true,
+		},
+		{
+			name:  "static export",
+			files: map[string]string{"next.config.js": `module.exports = { output: 'export' }`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := NextjsStandaloneOutput(ctx, dir)
+			if err != nil {
+				t.Fatalf("NextjsStandaloneOutput() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("NextjsStandaloneOutput() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUseNextjsStandalone(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		env     string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "standalone",
+			config: `module.exports = { output: 'standalone' }`,
+			want:   true,
+		},
+		{
+			name:   "not standalone",
+			config: `module.exports = {}`,
+		},
+		{
+			name:   "standalone disabled",
+			config: `module.exports = { output: 'standalone' }`,
+			env:    "false",
+		},
+		{
+			name:    "invalid env",
+			config:  `module.exports = { output: 'standalone' }`,
+			env:     "sometimes",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv(NextjsStandaloneEnv, tc.env)
+			}
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"next.config.js": tc.config})
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := UseNextjsStandalone(ctx, dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("UseNextjsStandalone() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("UseNextjsStandalone() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNextjsStaticExport(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "no config",
+			files: map[string]string{},
+		},
+		{
+			name:  "standalone output",
+			files: map[string]string{"next.config.js": `module.exports = { output: 'standalone' }`},
+		},
+		{
+			name:  "export js",
+			files: map[string]string{"next.config.js": `module.exports = { output: 'export' }`},
+			want:  true,
+		},
+		{
+			name: "export mjs",
+			files: map[string]string{"next.config.mjs": `export default {
+  output: "export",
+  trailingSlash: true,
+}`},
+			want: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := NextjsStaticExport(ctx, dir)
+			if err != nil {
+				t.Fatalf("NextjsStaticExport() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("NextjsStaticExport() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadNextjsRouting(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    NextjsRouting
+		wantErr bool
+	}{
+		{
+			name:  "no config",
+			files: map[string]string{},
+		},
+		{
+			name:  "served at root",
+			files: map[string]string{"next.config.js": `module.exports = { output: 'export' }`},
+		},
+		{
+			name: "base path and asset prefix",
+			files: map[string]string{"next.config.mjs": `export default {
+  basePath: "/docs",
+  assetPrefix: "/assets/",
+}`},
+			want: NextjsRouting{BasePath: "/docs", AssetPrefix: "/assets"},
+		},
+		{
+			name:  "asset prefix url",
+			files: map[string]string{"next.config.js": `module.exports = { assetPrefix: 'https://cdn.example.com' }`},
+			want:  NextjsRouting{AssetPrefix: "https://cdn.example.com"},
+		},
+		{
+			name:    "base path with trailing slash",
+			files:   map[string]string{"next.config.js": `module.exports = { basePath: '/docs/' }`},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := ReadNextjsRouting(ctx, dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ReadNextjsRouting() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ReadNextjsRouting() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNextjsRoutingLocalAssetPrefix(t *testing.T) {
+	testCases := []struct {
+		routing NextjsRouting
+		want    string
+	}{
+		{routing: NextjsRouting{}},
+		{routing: NextjsRouting{BasePath: "/docs", AssetPrefix: "/docs"}},
+		{routing: NextjsRouting{BasePath: "/docs", AssetPrefix: "/assets"}, want: "/assets"},
+		{routing: NextjsRouting{AssetPrefix: "https://cdn.example.com"}},
+		{routing: NextjsRouting{AssetPrefix: "//cdn.example.com"}},
+	}
+	for _, tc := range testCases {
+		if got := tc.routing.LocalAssetPrefix(); got != tc.want {
+			t.Errorf("%+v.LocalAssetPrefix() = %q, want %q", tc.routing, got, tc.want)
+		}
+	}
+}
+
+func TestNextjsRoutingFromLayers(t *testing.T) {
+	layersRoot := t.TempDir()
+	got, err := NextjsRoutingFromLayers(layersRoot)
+	if err != nil {
+		t.Fatalf("NextjsRoutingFromLayers() got error: %v", err)
+	}
+	if got != (NextjsRouting{}) {
+		t.Errorf("NextjsRoutingFromLayers() = %+v without a routing layer, want an empty routing", got)
+	}
+
+	writeFiles(t, layersRoot, map[string]string{
+		filepath.Join("google.nodejs.firebasenextjs", NextjsRoutingLayer+".toml"): `[types]
+  build = true
+
+[metadata]
+  asset_prefix = "/assets"
+  base_path = "/docs"
+`,
+	})
+	got, err = NextjsRoutingFromLayers(layersRoot)
+	if err != nil {
+		t.Fatalf("NextjsRoutingFromLayers() got error: %v", err)
+	}
+	if want := (NextjsRouting{BasePath: "/docs", AssetPrefix: "/assets"}); got != want {
+		t.Errorf("NextjsRoutingFromLayers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCopyNextjsStandalone(t *testing.T) {
 	testCases := []struct {
 		name          string
-		nextjsVersion string
-		layerMetadata map[string]any
-		mocks         []*mockprocess.Mock
+		appDir        string
+		files         map[string]string
+		env           string
+		wantServerDir string
+		wantFiles     []string
+		wantApp       []string
 	}{
 		{
-			name:          "download v13.0 adaptor succeeds",
-			nextjsVersion: "13.0.0",
-			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-nextjs@13.0`, mockprocess.WithStdout("installed adaptor")),
+			name: "not standalone",
+			files: map[string]string{
+				"next.config.js":                `module.exports = {}`,
+				"node_modules/next/index.js":    "",
+				".next/static/chunks/app.js":    "",
+				".next/standalone/server.js":    "",
+				".next/standalone/package.json": "",
 			},
-			layerMetadata: map[string]any{},
+			wantApp: []string{".next/standalone/package.json", ".next/standalone/server.js", ".next/static/chunks/app.js", "next.config.js", "node_modules/next/index.js"},
 		},
 		{
-			name:          "download invalid adaptor falls back to latest",
-			nextjsVersion: "15.0.0",
-			mocks: []*mockprocess.Mock{
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-nextjs@15.0`, mockprocess.WithStderr("installed adapter failed")),
-				mockprocess.New(`npm install --prefix npm_modules @apphosting/adapter-nextjs@latest`, mockprocess.WithStderr("installed adapter")),
+			name: "standalone",
+			files: map[string]string{
+				"next.config.js":                              `module.exports = { output: 'standalone' }`,
+				"node_modules/next/index.js":                  "",
+				"public/favicon.ico":                          "",
+				".next/static/chunks/app.js":                  "",
+				".next/standalone/server.js":                  "",
+				".next/standalone/node_modules/next/index.js": "",
 			},
-			layerMetadata: map[string]any{},
+			wantServerDir: ".",
+			wantFiles:     []string{".next/static/chunks/app.js", "node_modules/next/index.js", "public/favicon.ico", "server.js"},
+			wantApp:       []string{".next/static/chunks/app.js", "next.config.js", "public/favicon.ico"},
 		},
 		{
-			name:          "download adaptor not needed since it is cached",
-			nextjsVersion: "13.0.0",
-			layerMetadata: map[string]any{"version": "13.0"},
+			name:   "standalone workspace package",
+			appDir: "apps/web",
+			files: map[string]string{
+				"node_modules/next/index.js":                           "",
+				"apps/web/next.config.mjs":                             `export default { output: "standalone" }`,
+				"apps/web/.next/static/chunks/app.js":                  "",
+				"apps/web/.next/standalone/apps/web/server.js":         "",
+				"apps/web/.next/standalone/node_modules/next/index.js": "",
+			},
+			wantServerDir: "apps/web",
+			wantFiles:     []string{"apps/web/.next/static/chunks/app.js", "apps/web/server.js", "node_modules/next/index.js"},
+			wantApp:       []string{"apps/web/.next/static/chunks/app.js", "apps/web/next.config.mjs"},
+		},
+		{
+			name: "standalone disabled",
+			env:  "false",
+			files: map[string]string{
+				"next.config.js":             `module.exports = { output: 'standalone' }`,
+				".next/standalone/server.js": "",
+			},
+			wantApp: []string{".next/standalone/server.js", "next.config.js"},
 		},
 	}
-
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := gcp.NewContext(getContextOpts(t, tc.mocks)...)
-			layer := &libcnb.Layer{
-				Name:     "njsl",
-				Path:     t.TempDir(),
-				Metadata: tc.layerMetadata,
+			if tc.env != "" {
+				t.Setenv(NextjsStandaloneEnv, tc.env)
 			}
-			err := InstallNextJsBuildAdaptor(ctx, layer, tc.nextjsVersion)
+			root := t.TempDir()
+			writeFiles(t, root, tc.files)
+			l := &libcnb.Layer{Name: "nextjs_standalone", Path: t.TempDir(), LaunchEnvironment: libcnb.Environment{}}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			serverDir, err := CopyNextjsStandalone(ctx, l, filepath.Join(root, tc.appDir))
 			if err != nil {
-				t.Fatalf("InstallNextJsBuildAdaptor() got error: %v", err)
+				t.Fatalf("CopyNextjsStandalone() got error: %v", err)
+			}
+			wantServerDir := ""
+			if tc.wantServerDir != "" {
+				wantServerDir = filepath.Join(l.Path, tc.wantServerDir)
+			}
+			if serverDir != wantServerDir {
+				t.Errorf("CopyNextjsStandalone() = %q, want %q", serverDir, wantServerDir)
+			}
+			if diff := cmp.Diff(tc.wantFiles, listFiles(t, l.Path)); diff != "" {
+				t.Errorf("layer files mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantApp, listFiles(t, root)); diff != "" {
+				t.Errorf("application files mismatch (-want +got):\n%s", diff)
 			}
 		})
 	}
 }
-func TestDetectNextjsAdaptorVersion(t *testing.T) {
+
+func TestWithNextjsBuildCache(t *testing.T) {
+	lockfile := func(next string) string {
+		return `{"packages": {"node_modules/next": {"version": "` + next + `"}}}`
+	}
 	testCases := []struct {
-		name           string
-		version        string
-		expectedOutput string
-		mocks          []*mockprocess.Mock
+		name        string
+		nodeVersion string
+		lockfile    string
+		files       map[string]string
+		wantCache   string
 	}{
 		{
-			name:           "concrete version",
-			version:        "13.0.0",
-			expectedOutput: "13.0",
+			name:        "unchanged",
+			nodeVersion: "v20.11.1",
+			lockfile:    lockfile("14.2.3"),
+			wantCache:   "first",
 		},
 		{
-			name:           "handles prereleases",
-			version:        "13.0.1-canary",
-			expectedOutput: "13.0",
+			name:        "next upgrade",
+			nodeVersion: "v20.11.1",
+			lockfile:    lockfile("14.2.4"),
+		},
+		{
+			name:        "node major upgrade",
+			nodeVersion: "v22.1.0",
+			lockfile:    lockfile("14.2.3"),
+		},
+		{
+			name:        "cache uploaded with the source",
+			nodeVersion: "v20.11.1",
+			lockfile:    lockfile("14.2.3"),
+			files:       map[string]string{".next/cache/webpack/pack": "source"},
+			wantCache:   "source",
 		},
 	}
-
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			output, _ := detectNextjsAdaptorVersion(tc.version)
-			if output != tc.expectedOutput {
-				t.Fatalf("detectNextjsAdaptorVersion(%s) output: %s doesn't match expected output %s", tc.version, output, tc.expectedOutput)
+			defer func(fn func(*gcp.Context) (string, error)) { nodeVersion = fn }(nodeVersion)
+			l := &libcnb.Layer{Name: nextjsCacheLayer, Path: t.TempDir(), Metadata: map[string]any{}}
+			pjs := &PackageJSON{Dependencies: map[string]string{"next": "^14.2.0"}}
+			build := func(dir, content string) func() error {
+				return func() error {
+					writeFiles(t, dir, map[string]string{".next/cache/webpack/pack": content})
+					return nil
+				}
+			}
+
+			// Populate the cache with a first build.
+			nodeVersion = func(*gcp.Context) (string, error) { return "v20.11.1", nil }
+			first := t.TempDir()
+			writeFiles(t, first, map[string]string{"package-lock.json": lockfile("14.2.3")})
+			if err := withNextjsCacheLayer(gcp.NewContext(gcp.WithApplicationRoot(first)), l, pjs, first, build(first, "first")); err != nil {
+				t.Fatalf("withNextjsCacheLayer() got error: %v", err)
+			}
+
+			nodeVersion = func(*gcp.Context) (string, error) { return tc.nodeVersion, nil }
+			second := t.TempDir()
+			writeFiles(t, second, map[string]string{"package-lock.json": tc.lockfile})
+			writeFiles(t, second, tc.files)
+			var gotCache string
+			err := withNextjsCacheLayer(gcp.NewContext(gcp.WithApplicationRoot(second)), l, pjs, second, func() error {
+				if raw, err := os.ReadFile(filepath.Join(second, ".next/cache/webpack/pack")); err == nil {
+					gotCache = string(raw)
+				}
+				return build(second, "second")()
+			})
+			if err != nil {
+				t.Fatalf("withNextjsCacheLayer() got error: %v", err)
+			}
+			if gotCache != tc.wantCache {
+				t.Errorf("withNextjsCacheLayer() restored .next/cache with %q, want %q", gotCache, tc.wantCache)
+			}
+			saved, err := os.ReadFile(filepath.Join(l.Path, "cache/webpack/pack"))
+			if err != nil {
+				t.Fatalf("reading saved .next/cache: %v", err)
+			}
+			if string(saved) != "second" {
+				t.Errorf("withNextjsCacheLayer() saved .next/cache with %q, want %q", saved, "second")
 			}
 		})
 	}
 }
 
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for f, content := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", f, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, rel)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("listing files in %s: %v", dir, err)
+	}
+	sort.Strings(files)
+	return files
+}
+
 func getContextOpts(t *testing.T, mocks []*mockprocess.Mock) []gcp.ContextOption {
 	t.Helper()
 	opts := []gcp.ContextOption{}
@@ -18,17 +18,18 @@ package nodejs
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appenv"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 	"github.com/Masterminds/semver"
-	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -43,6 +44,9 @@ const (
 
 	nodeVersionKey    = "node_version"
 	dependencyHashKey = "dependency_hash"
+
+	// runtimeVersionSetting is the name used to record the requested runtime version.
+	runtimeVersionSetting = "runtime version"
 )
 
 // semVer11 is the smallest possible semantic version with major version 11.
@@ -51,7 +55,7 @@ var semVer11 = semver.MustParse("11.0.0")
 var (
 	cachedPackageJSONs = map[string]*PackageJSON{}
 )
-var possibleLockfileFilenames = []string{"pnpm-lock.yaml", "yarn.lock", "npm-shrinkwrap.json", "package-lock.json"}
+var possibleLockfileFilenames = []string{"pnpm-lock.yaml", "yarn.lock", "npm-shrinkwrap.json", "package-lock.json", BunLock, BunLockb}
 
 type packageEnginesJSON struct {
 	Node string `json:"node"`
@@ -60,6 +64,38 @@ type packageEnginesJSON struct {
 	PNPM string `json:"pnpm"`
 }
 
+// packageVoltaJSON is the volta config of package.json, which pins the versions of tools.
+type packageVoltaJSON struct {
+	Node string `json:"node"`
+}
+
+// packagePrismaJSON is the prisma config of package.json.
+type packagePrismaJSON struct {
+	Schema string `json:"schema"`
+}
+
+// packagePnpmJSON is the pnpm config of package.json.
+type packagePnpmJSON struct {
+	// PatchedDependencies maps packages, such as `left-pad@1.3.0`, to the patch files pnpm applies
+	// to them, relative to the application.
+	PatchedDependencies map[string]string `json:"patchedDependencies"`
+}
+
+// packageGoogleBuildpacksJSON is the buildpacks config in the "googleBuildpacks" section of
+// package.json.
+//
+// Example:
+//
+//	"googleBuildpacks": {
+//	  "buildCommand": "npm run build:apphosting"
+//	}
+type packageGoogleBuildpacksJSON struct {
+	// BuildCommand replaces the build script and the build command of the framework adapter. The
+	// adapter command remains available to it in the APPHOSTING_BUILD env var, so that a script like
+	// `node prebuild.js && $APPHOSTING_BUILD && node postbuild.js` can wrap the adapter build.
+	BuildCommand string `json:"buildCommand"`
+}
+
 const (
 	// ScriptBuild is the name of npm build scripts.
 	ScriptBuild = "build"
@@ -69,27 +105,45 @@ const (
 
 // PackageJSON represents the contents of a package.json file.
 type PackageJSON struct {
-	Main            string             `json:"main"`
-	Type            string             `json:"type"`
-	Version         string             `json:"version"`
-	Engines         packageEnginesJSON `json:"engines"`
-	Scripts         map[string]string  `json:"scripts"`
-	Dependencies    map[string]string  `json:"dependencies"`
-	DevDependencies map[string]string  `json:"devDependencies"`
+	Main             string                      `json:"main"`
+	Type             string                      `json:"type"`
+	Exports          json.RawMessage             `json:"exports"`
+	Workspaces       json.RawMessage             `json:"workspaces"`
+	Version          string                      `json:"version"`
+	Engines          packageEnginesJSON          `json:"engines"`
+	Volta            packageVoltaJSON            `json:"volta"`
+	Prisma           packagePrismaJSON           `json:"prisma"`
+	Pnpm             packagePnpmJSON             `json:"pnpm"`
+	GoogleBuildpacks packageGoogleBuildpacksJSON `json:"googleBuildpacks"`
+	PackageManager   string                      `json:"packageManager"`
+	Scripts          map[string]string           `json:"scripts"`
+	Dependencies     map[string]string           `json:"dependencies"`
+	DevDependencies  map[string]string           `json:"devDependencies"`
 }
 
 // NpmLockfile represents the contents of a lock file generated with npm.
 type NpmLockfile struct {
 	Packages map[string]struct {
-		Version string `json:"version"`
+		Version   string `json:"version"`
+		Integrity string `json:"integrity"`
 	} `json:"packages"`
 }
 
 // PnpmLockfile represents the contents of a lock file generated with pnpm.
 type PnpmLockfile struct {
-	Dependencies map[string]struct {
-		Version string `yaml:"version"`
-	} `yaml:"dependencies"`
+	Dependencies    map[string]PnpmDependency `yaml:"dependencies"`
+	DevDependencies map[string]PnpmDependency `yaml:"devDependencies"`
+	// Importers maps the directories of a workspace, relative to the lock file, to their
+	// dependencies. Lock files from pnpm v9 list the dependencies of the root under the `.` importer
+	// instead of the top level dependencies.
+	Importers map[string]PnpmImporter `yaml:"importers"`
+	// Catalogs maps catalog names to the resolved versions of the packages they define.
+	Catalogs map[string]map[string]struct {
+		Specifier string `yaml:"specifier"`
+		Version   string `yaml:"version"`
+	} `yaml:"catalogs"`
+	// Packages is keyed by package identifiers such as `next@14.2.3` or `/next@14.2.3`.
+	Packages map[string]interface{} `yaml:"packages"`
 }
 
 // ReadPackageJSONIfExists returns deserialized package.json from the given dir. If the provided dir
@@ -134,19 +188,39 @@ func HasDevDependencies(p *PackageJSON) bool {
 }
 
 // RequestedNodejsVersion returns any customer provided Node.js version constraint by inspecting the
-// environment and the package.json.
+// environment, the .nvmrc and .node-version files, and the volta and engines fields of package.json,
+// in that order of precedence.
 func RequestedNodejsVersion(ctx *gcp.Context, pjs *PackageJSON) (string, error) {
 	if version := os.Getenv(EnvNodeVersion); version != "" {
 		ctx.Logf("Using runtime version from %s: %s", EnvNodeVersion, version)
+		ctx.RecordSetting(runtimeVersionSetting, version, gcp.SourceEnv)
 		return version, nil
 	}
 	if version := os.Getenv(env.RuntimeVersion); version != "" {
 		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
+		ctx.RecordSetting(runtimeVersionSetting, version, gcp.SourceEnv)
 		return version, nil
 	}
-	if pjs == nil {
+	version, source, err := pinnedNodejsVersion(ctx, pjs)
+	if err != nil {
+		return "", err
+	}
+	if source != "" {
+		if version == "" {
+			ctx.Logf("Using the latest runtime version as requested by %s", source)
+			ctx.RecordSetting(runtimeVersionSetting, "latest", source)
+			return "", nil
+		}
+		ctx.Logf("Using runtime version from %s: %s", source, version)
+		ctx.RecordSetting(runtimeVersionSetting, version, source)
+		return version, nil
+	}
+	if pjs == nil || pjs.Engines.Node == "" {
+		ctx.RecordSetting(runtimeVersionSetting, "latest", gcp.SourceDefault)
 		return "", nil
 	}
+	ctx.Logf("Using runtime version from package.json engines.node: %s", pjs.Engines.Node)
+	ctx.RecordSetting(runtimeVersionSetting, pjs.Engines.Node, gcp.SourcePackageJSON)
 	return pjs.Engines.Node, nil
 }
 
@@ -176,11 +250,7 @@ func isPreNode11(ctx *gcp.Context) (bool, error) {
 
 // NodeEnv returns the value of NODE_ENV or `production`.
 func NodeEnv() string {
-	nodeEnv := os.Getenv(EnvNodeEnv)
-	if nodeEnv == "" {
-		nodeEnv = EnvProduction
-	}
-	return nodeEnv
+	return appenv.BuildValue(appenv.NodeEnv)
 }
 
 // CheckOrClearCache checks whether cached dependencies exist and match. If they do not match, the
@@ -238,55 +308,288 @@ func IsNodeJS8Runtime() bool {
 	return os.Getenv(env.Runtime) == "nodejs8"
 }
 
-func versionFromPnpmLock(rawPackageLock []byte, pkg string) (string, error) {
-	var lockfile PnpmLockfile
-	if err := yaml.Unmarshal(rawPackageLock, &lockfile); err != nil {
-		return "", gcp.InternalErrorf("parsing pnpm lock file: %w", err)
+// versionFromPnpmLock returns the version of pkg in a pnpm lock file located in dir, using the
+// dependencies of the given importer for pnpm workspaces.
+func versionFromPnpmLock(ctx *gcp.Context, r io.Reader, pjs *PackageJSON, pkg, dir, importer string) (string, error) {
+	lockfile, err := readPnpmLock(r, pkg, importer)
+	if err != nil {
+		return "", err
+	}
+	if v := lockfile.Dependencies[pkg].Version; v != "" {
+		return trimPnpmPeerSuffix(v), nil
+	}
+	if v := lockfile.DevDependencies[pkg].Version; v != "" {
+		return trimPnpmPeerSuffix(v), nil
+	}
+	if v := lockfile.importerVersion(importer, pkg); v != "" {
+		return trimPnpmPeerSuffix(v), nil
+	}
+	catalog, ok := catalogName(dependencySpecifier(pjs, pkg))
+	if !ok {
+		return "", nil
+	}
+	return versionFromPnpmCatalog(ctx, lockfile, dir, catalog, pkg)
+}
+
+func versionFromYarnLock(r io.Reader, pjs *PackageJSON, pkg string) (string, error) {
+	version, ok, err := lookupYarnLock(r, pkg, dependencySpecifier(pjs, pkg))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", gcp.InternalErrorf("parsing yarn file: %s not found", pkg)
 	}
-	return strings.Split(lockfile.Dependencies[pkg].Version, "(")[0], nil
+	return version, nil
 }
 
-func versionFromYarnLock(rawPackageLock []byte, pjs *PackageJSON, pkg string) (string, error) {
-	// yarn requires custom parsing since it has a custom format
-	// this logic works for both yarn classic and berry
-	for _, dependency := range strings.Split(string(rawPackageLock[:]), "\n\n") {
-		if strings.Contains(dependency, pkg+"@") && strings.Contains(dependency, pjs.Dependencies[pkg]) {
-			for _, line := range strings.Split(dependency, "\n") {
-				if strings.Contains(line, "version") {
-					return strings.Trim(strings.Fields(line)[1], `"`), nil
-				}
+// versionFromNpmLock returns the version of pkg in an npm lock file. Packages of an npm workspace
+// which are not hoisted are installed in the node_modules directory of the importer. The lock file
+// is decoded one package at a time and reading stops once the version is found.
+func versionFromNpmLock(r io.Reader, pkg, importer string) (string, error) {
+	hoisted, nested := "node_modules/"+pkg, ""
+	if importer != "." {
+		nested = importer + "/node_modules/" + pkg
+	}
+	dec := json.NewDecoder(r)
+	version := ""
+	// deeper are the versions of pkg installed in the node_modules of other packages or workspaces,
+	// which are used when pkg is neither hoisted nor installed for the importer.
+	deeper := map[string]bool{}
+	err := decodeJSONObject(dec, func(key string) (bool, error) {
+		if key != "packages" {
+			return true, skipJSONValue(dec)
+		}
+		return false, decodeJSONObject(dec, func(key string) (bool, error) {
+			if key != hoisted && !strings.HasSuffix(key, "/"+hoisted) {
+				var entry json.RawMessage
+				return true, dec.Decode(&entry)
+			}
+			var entry struct {
+				Version string `json:"version"`
 			}
+			if err := dec.Decode(&entry); err != nil {
+				return false, err
+			}
+			switch {
+			case key == nested && entry.Version != "":
+				version = entry.Version
+				return false, nil
+			case key == hoisted:
+				version = entry.Version
+				return nested != "", nil
+			case entry.Version != "":
+				deeper[entry.Version] = true
+			}
+			return true, nil
+		})
+	})
+	if err != nil {
+		return "", gcp.InternalErrorf("parsing lock file: %w", err)
+	}
+	if version == "" && len(deeper) == 1 {
+		for v := range deeper {
+			version = v
 		}
 	}
-	return "", gcp.InternalErrorf("parsing yarn file")
+	return version, nil
 }
 
-func versionFromNpmLock(rawPackageLock []byte, pkg string) (string, error) {
-	var lockfile NpmLockfile
-	if err := json.Unmarshal(rawPackageLock, &lockfile); err != nil {
-		return "", gcp.InternalErrorf("parsing lock file: %w", err)
+// decodeJSONObject reads the opening delimiter of a JSON object and calls visit with each of its
+// keys until visit returns false. visit must consume the value of the key if it returns true.
+func decodeJSONObject(dec *json.Decoder, visit func(key string) (bool, error)) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("expected an object, got %v", t)
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("expected an object key, got %v", t)
+		}
+		next, err := visit(key)
+		if err != nil || !next {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipJSONValue reads the next value from dec without keeping it in memory.
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
 	}
-	return lockfile.Packages["node_modules/"+pkg].Version, nil
+}
+
+// dependencySpecifier returns the version specifier of pkg in the dependencies or devDependencies
+// of package.json.
+func dependencySpecifier(pjs *PackageJSON, pkg string) string {
+	if pjs == nil {
+		return ""
+	}
+	if s, ok := pjs.Dependencies[pkg]; ok {
+		return s
+	}
+	return pjs.DevDependencies[pkg]
 }
 
 // Version tries to get the concrete package version used based on lock file,
-// returns error if no lock file is found or is misshapen
+// returns error if no lock file is found or is misshapen. Packages hoisted to the root of an npm,
+// yarn or pnpm workspace are looked up in the lock file of the workspace, and packages missing
+// from the lock files in the version installed in node_modules.
 func Version(ctx *gcp.Context, pjs *PackageJSON, pkg string) (string, error) {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	version, lockFound, err := versionFromLockfileIn(ctx, appDir, ".", pjs, pkg)
+	if version != "" || err != nil {
+		return version, err
+	}
+
+	// Workspace packages selected with AppDirEnv use the lock file at the application root.
+	if appDir != ctx.ApplicationRoot() {
+		importer, err := appDirRel(ctx)
+		if err != nil {
+			return "", err
+		}
+		version, found, err := versionFromLockfileIn(ctx, ctx.ApplicationRoot(), importer, pjs, pkg)
+		if version != "" || err != nil {
+			return version, err
+		}
+		lockFound = lockFound || found
+	}
+
+	// Applications in a pnpm workspace use the lock file at the root of the workspace.
+	if root, ok := pnpmWorkspaceRoot(ctx, appDir); ok {
+		f, err := os.Open(filepath.Join(root, PNPMLock))
+		if err != nil {
+			return "", gcp.InternalErrorf("reading %s: %w", PNPMLock, err)
+		}
+		defer f.Close()
+		importer, err := filepath.Rel(root, appDir)
+		if err != nil {
+			return "", gcp.InternalErrorf("finding pnpm importer: %w", err)
+		}
+		ctx.Logf("Using %s of the pnpm workspace in %s", PNPMLock, root)
+		version, err := versionFromPnpmLock(ctx, f, pjs, pkg, root, filepath.ToSlash(importer))
+		if version != "" || err != nil {
+			return version, err
+		}
+		lockFound = true
+	}
+
+	// Applications in an npm, yarn or bun workspace use the lock file at the root of the workspace.
+	if root, ok := npmWorkspaceRoot(ctx, appDir); ok {
+		importer, err := filepath.Rel(root, appDir)
+		if err != nil {
+			return "", gcp.InternalErrorf("finding workspace importer: %w", err)
+		}
+		ctx.Logf("Using the lock file of the workspace in %s", root)
+		version, found, err := versionFromLockfileIn(ctx, root, filepath.ToSlash(importer), pjs, pkg)
+		if version != "" || err != nil {
+			return version, err
+		}
+		lockFound = lockFound || found
+	}
+
+	version, err = installedVersion(ctx, appDir, pkg)
+	if version != "" || err != nil || lockFound {
+		return version, err
+	}
+	return "", gcp.UserErrorf("No lock file found, please run npm install to generate one")
+}
+
+// npmWorkspaceRoot returns the closest parent directory of the application package in appDir
+// declaring workspaces in its package.json and containing a lock file other than pnpm's.
+func npmWorkspaceRoot(ctx *gcp.Context, appDir string) (string, bool) {
+	dir := filepath.Clean(appDir)
+	for parent := filepath.Dir(dir); parent != dir; dir, parent = parent, filepath.Dir(parent) {
+		pjs, err := ReadPackageJSONIfExists(parent)
+		if err != nil || pjs == nil || len(pjs.Workspaces) == 0 {
+			continue
+		}
+		for _, filename := range possibleLockfileFilenames {
+			if filename == PNPMLock {
+				continue
+			}
+			if lock, err := ctx.FileExists(parent, filename); err == nil && lock {
+				return parent, true
+			}
+		}
+	}
+	return "", false
+}
+
+// installedVersion returns the version of pkg installed in the node_modules directory Node.js
+// resolves it from for the package in appDir, looking up the parent directories up to the
+// application root and the hoisted layout of the pnpm virtual store. It returns an empty string if
+// pkg is not installed.
+func installedVersion(ctx *gcp.Context, appDir, pkg string) (string, error) {
+	root := filepath.Clean(ctx.ApplicationRoot())
+	for dir := filepath.Clean(appDir); ; dir = filepath.Dir(dir) {
+		for _, pkgDir := range []string{
+			filepath.Join(dir, "node_modules", pkg),
+			filepath.Join(dir, "node_modules", ".pnpm", "node_modules", pkg),
+		} {
+			pjs, err := ReadPackageJSONIfExists(pkgDir)
+			if err != nil {
+				return "", err
+			}
+			if pjs != nil && pjs.Version != "" {
+				ctx.Debugf("Using the version of %s installed in %s", pkg, pkgDir)
+				return pjs.Version, nil
+			}
+		}
+		if dir == root || !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return "", nil
+		}
+	}
+}
+
+// versionFromLockfileIn returns the version of pkg in the first lock file found in dir, using the
+// dependencies of the given importer for workspaces. It returns false if dir has no lock file.
+func versionFromLockfileIn(ctx *gcp.Context, dir, importer string, pjs *PackageJSON, pkg string) (string, bool, error) {
 	for _, filename := range possibleLockfileFilenames {
-		filePath := filepath.Join(ctx.ApplicationRoot(), filename)
-		rawPackageLock, err := os.ReadFile(filePath)
+		f, err := os.Open(filepath.Join(dir, filename))
 		if err != nil {
 			continue
 		}
+		defer f.Close()
+		var version string
 		switch filename {
 		case "pnpm-lock.yaml":
-			return versionFromPnpmLock(rawPackageLock, pkg)
+			version, err = versionFromPnpmLock(ctx, f, pjs, pkg, dir, importer)
 		case "yarn.lock":
-			return versionFromYarnLock(rawPackageLock, pjs, pkg)
+			version, err = versionFromYarnLock(f, pjs, pkg)
 		case "npm-shrinkwrap.json", "package-lock.json":
-			return versionFromNpmLock(rawPackageLock, pkg)
+			version, err = versionFromNpmLock(f, pkg, importer)
+		case BunLock:
+			version, err = versionFromBunLock(f, pkg)
+		case BunLockb:
+			version, err = versionFromBunLockb(ctx, filepath.Join(dir, BunLockb), pjs, pkg)
 		}
+		return version, true, err
 	}
-
-	return "", gcp.UserErrorf("No lock file found, please run npm install to generate one")
+	return "", false, nil
 }
@@ -16,10 +16,13 @@ package nodejs
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
@@ -226,6 +229,7 @@ func TestRequestedNodejsVersion(t *testing.T) {
 		nodeEnv     string
 		runtimeEnv  string
 		packageJSON string
+		files       map[string]string
 		want        string
 		wantErr     bool
 	}{
@@ -260,6 +264,45 @@ func TestRequestedNodejsVersion(t *testing.T) {
 			runtimeEnv:  "3.3.3",
 			want:        "3.3.3",
 		},
+		{
+			name:        ".nvmrc",
+			packageJSON: `{"engines": {"node": "2.2.2"}}`,
+			files:       map[string]string{".nvmrc": "v20.11.1\n"},
+			want:        "20.11.1",
+		},
+		{
+			name:        ".nvmrc before .node-version and volta",
+			packageJSON: `{"volta": {"node": "18.20.0"}}`,
+			files:       map[string]string{".nvmrc": "lts/iron", ".node-version": "22.1.0"},
+			want:        "20",
+		},
+		{
+			name:        ".node-version before volta",
+			packageJSON: `{"volta": {"node": "18.20.0"}}`,
+			files:       map[string]string{".node-version": "22.1.0"},
+			want:        "22.1.0",
+		},
+		{
+			name:        "volta.node before engines.node",
+			packageJSON: `{"volta": {"node": "18.20.0"}, "engines": {"node": ">=16"}}`,
+			want:        "18.20.0",
+		},
+		{
+			name:    "env before .nvmrc",
+			nodeEnv: "1.2.3",
+			files:   map[string]string{".nvmrc": "20"},
+			want:    "1.2.3",
+		},
+		{
+			name:  ".nvmrc latest lts",
+			files: map[string]string{".nvmrc": "lts/*"},
+			want:  "",
+		},
+		{
+			name:    "invalid .nvmrc",
+			files:   map[string]string{".nvmrc": "lts/unknown"},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -279,7 +322,13 @@ func TestRequestedNodejsVersion(t *testing.T) {
 				t.Setenv("GOOGLE_RUNTIME_VERSION", tc.runtimeEnv)
 			}
 
-			ctx := gcp.NewContext()
+			for f, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
 			got, err := RequestedNodejsVersion(ctx, pjs)
 			if tc.wantErr == (err == nil) {
 				t.Errorf("RequestedNodejsVersion(ctx, %q) got error: %v, want err? %t", dir, err, tc.wantErr)
@@ -334,6 +383,7 @@ func TestVersion(t *testing.T) {
 		mocks           []*mockprocess.Mock
 		expectedError   bool
 		pkg             string
+		bunMissing      bool
 	}{
 		{
 			name: "Errors out when no package-lock is included nextjs",
@@ -433,16 +483,240 @@ dependencies:
 			},
 			expectedVersion: "13.5.6",
 		},
+		{
+			name: "Parses pnpm-lock v9 root importer version nextjs",
+			pkg:  "next",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "^14.2.0",
+				},
+			},
+			files: map[string]string{
+				"pnpm-lock.yaml": `
+lockfileVersion: '9.0'
+importers:
+  .:
+    dependencies:
+      next:
+        specifier: ^14.2.0
+        version: 14.2.3(react-dom@18.3.1(react@18.3.1))(react@18.3.1)
+packages:
+  next@14.2.3:
+    resolution: {integrity: sha512-a}
+`,
+			},
+			expectedVersion: "14.2.3",
+		},
+		{
+			name: "Parses pnpm-lock v5 importers version nextjs",
+			pkg:  "next",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "^13.1.0",
+				},
+			},
+			files: map[string]string{
+				"pnpm-lock.yaml": `
+lockfileVersion: 5.4
+importers:
+  .:
+    specifiers:
+      next: ^13.1.0
+    dependencies:
+      next: 13.5.6_react@18.2.0
+`,
+			},
+			expectedVersion: "13.5.6",
+		},
+		{
+			name: "Parses pnpm-lock devDependencies version sveltekit",
+			pkg:  "@sveltejs/kit",
+			pjs: PackageJSON{
+				DevDependencies: map[string]string{
+					"@sveltejs/kit": "^2.0.0",
+				},
+			},
+			files: map[string]string{
+				"pnpm-lock.yaml": `
+devDependencies:
+  '@sveltejs/kit':
+    version: 2.5.4(@sveltejs/vite-plugin-svelte@3.0.2)(svelte@4.2.12)(vite@5.1.4)
+`,
+			},
+			expectedVersion: "2.5.4",
+		},
+		{
+			name: "Parses yarn.lock devDependencies version sveltekit",
+			pkg:  "@sveltejs/kit",
+			pjs: PackageJSON{
+				DevDependencies: map[string]string{
+					"@sveltejs/kit": "^2.0.0",
+				},
+			},
+			files: map[string]string{
+				"yarn.lock": `
+"@sveltejs/kit@npm:^2.0.0":
+	version: 2.5.4`,
+			},
+			expectedVersion: "2.5.4",
+		},
+		{
+			name: "Parses pnpm-lock catalogs version nextjs",
+			pkg:  "next",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "catalog:",
+				},
+			},
+			files: map[string]string{
+				"pnpm-lock.yaml": `
+lockfileVersion: '9.0'
+catalogs:
+  default:
+    next:
+      specifier: ^14.2.0
+      version: 14.2.3
+importers:
+  .:
+    dependencies:
+      next:
+        specifier: 'catalog:'
+        version: 14.2.3(react@18.3.1)
+`,
+			},
+			expectedVersion: "14.2.3",
+		},
+		{
+			name: "Resolves pnpm named catalog from pnpm-workspace.yaml angular",
+			pkg:  "@angular/core",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"@angular/core": "catalog:angular17",
+				},
+			},
+			files: map[string]string{
+				"pnpm-workspace.yaml": `
+packages:
+  - apps/*
+catalogs:
+  angular17:
+    '@angular/core': ~17.1.0
+`,
+				"pnpm-lock.yaml": `
+lockfileVersion: '9.0'
+packages:
+  '@angular/core@16.2.0':
+    resolution: {integrity: sha512-a}
+  '@angular/core@17.1.2':
+    resolution: {integrity: sha512-b}
+  '@angular/core@17.3.0':
+    resolution: {integrity: sha512-c}
+`,
+			},
+			expectedVersion: "17.1.2",
+		},
+		{
+			name: "Errors out when pnpm catalog does not define the package",
+			pkg:  "next",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "catalog:",
+				},
+			},
+			files: map[string]string{
+				"pnpm-workspace.yaml": `
+catalog:
+  react: ^18.2.0
+`,
+				"pnpm-lock.yaml": `
+lockfileVersion: '9.0'
+`,
+			},
+			expectedError: true,
+		},
+		{
+			name: "Parses bun.lock version nextjs",
+			pkg:  "next",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "^14.2.0",
+				},
+			},
+			files: map[string]string{
+				"bun.lock": `{
+  "lockfileVersion": 1,
+  "workspaces": {
+    "": {
+      "name": "app",
+      "dependencies": {
+        "next": "^14.2.0",
+      },
+    },
+  },
+  "packages": {
+    "next": ["next@14.2.3", "", { "dependencies": { "@next/env": "14.2.3" } }, "sha512-abc"],
+  },
+}`,
+			},
+			expectedVersion: "14.2.3",
+		},
+		{
+			name: "Parses bun.lockb version nextjs",
+			pkg:  "next",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "^14.2.0",
+				},
+			},
+			files: map[string]string{
+				"bun.lockb": "\x00binary",
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bun .*bun.lockb$`, mockprocess.WithStdout(`# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+# bun ./bun.lockb --hash: 0123456789ABCDEF
+
+
+next@^14.2.0:
+  version "14.2.3"
+  resolved "https://registry.npmjs.org/next/-/next-14.2.3.tgz"
+`)),
+			},
+			expectedVersion: "14.2.3",
+		},
+		{
+			name: "Uses the installed version for bun.lockb without bun",
+			pkg:  "next",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "^14.2.0",
+				},
+			},
+			files: map[string]string{
+				"bun.lockb":                      "\x00binary",
+				"node_modules/next/package.json": `{"name": "next", "version": "14.2.4"}`,
+			},
+			bunMissing:      true,
+			expectedVersion: "14.2.4",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			tmpDir := os.TempDir()
+			tmpDir := t.TempDir()
+			defer func(fn func(string) (string, error)) { lookPath = fn }(lookPath)
+			lookPath = func(file string) (string, error) {
+				if tc.bunMissing {
+					return "", exec.ErrNotFound
+				}
+				return "/usr/local/bin/" + file, nil
+			}
 
 			ctx := gcp.NewContext(append(getContextOpts(t, tc.mocks), gcp.WithApplicationRoot(tmpDir))...)
 
 			for file, content := range tc.files {
 				fn := filepath.Join(ctx.ApplicationRoot(), file)
+				os.MkdirAll(filepath.Dir(fn), 0755)
 				ioutil.WriteFile(fn, []byte(content), 0644)
 			}
 
@@ -458,6 +732,238 @@ dependencies:
 	}
 }
 
+func TestVersionStopsAtFirstMatch(t *testing.T) {
+	testCases := []struct {
+		name  string
+		file  string
+		lock  string
+		want  string
+		pjs   PackageJSON
+		after string
+	}{
+		{
+			name: "package-lock.json",
+			file: "package-lock.json",
+			lock: `{
+				"name": "app",
+				"dependencies": {"next": {"version": "1.0.0"}},
+				"packages": {
+					"": {"dependencies": {"next": "^14.2.0"}},
+					"node_modules/react": {"version": "18.2.0"},
+					"node_modules/next": {"version": "14.2.3"},
+					"node_modules/zod": `,
+			want: "14.2.3",
+		},
+		{
+			name: "yarn.lock",
+			file: "yarn.lock",
+			pjs:  PackageJSON{Dependencies: map[string]string{"next": "^14.2.0"}},
+			lock: `next@^13.0.0:
+  version "13.5.6"
+
+next@^14.2.0:
+  version "14.2.3"
+
+not a yarn.lock entry
+`,
+			want: "14.2.3",
+		},
+		{
+			name: "pnpm-lock.yaml",
+			file: "pnpm-lock.yaml",
+			lock: `lockfileVersion: '9.0'
+
+importers:
+
+  .:
+    dependencies:
+      next:
+        specifier: ^14.2.0
+        version: 14.2.3(react@18.2.0)
+      react:
+        specifier: ^18.2.0
+        version: 18.2.0
+
+packages: [
+`,
+			want: "14.2.3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tc.file), []byte(tc.lock), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := Version(ctx, &tc.pjs, "next")
+			if err != nil {
+				t.Fatalf("Version() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Version() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVersionHoistingLayouts(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		appRoot string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "nested under another package",
+			files: map[string]string{
+				"package-lock.json": `{"packages": {
+					"": {"dependencies": {"@acme/ui": "^1.0.0"}},
+					"node_modules/@acme/ui": {"version": "1.0.0"},
+					"node_modules/@acme/ui/node_modules/next": {"version": "14.2.3"}
+				}}`,
+			},
+			want: "14.2.3",
+		},
+		{
+			name: "nested with several versions",
+			files: map[string]string{
+				"package-lock.json": `{"packages": {
+					"node_modules/a/node_modules/next": {"version": "13.5.6"},
+					"node_modules/b/node_modules/next": {"version": "14.2.3"}
+				}}`,
+			},
+			want: "",
+		},
+		{
+			name: "hoisted to the npm workspace root",
+			files: map[string]string{
+				"package.json":      `{"workspaces": ["apps/*"]}`,
+				"package-lock.json": `{"packages": {"node_modules/next": {"version": "14.2.3"}}}`,
+			},
+			appRoot: "apps/web",
+			want:    "14.2.3",
+		},
+		{
+			name: "hoisted to the yarn workspace root",
+			files: map[string]string{
+				"package.json": `{"workspaces": {"packages": ["apps/*"]}}`,
+				"yarn.lock": `next@^14.2.0:
+  version "14.2.3"
+`,
+			},
+			appRoot: "apps/web",
+			want:    "14.2.3",
+		},
+		{
+			name: "installed for the workspace package",
+			files: map[string]string{
+				"package.json":      `{"workspaces": ["apps/*"]}`,
+				"package-lock.json": `{"packages": {"node_modules/react": {"version": "18.2.0"}}}`,
+				"apps/web/node_modules/next/package.json": `{"name": "next", "version": "14.2.3"}`,
+			},
+			appRoot: "apps/web",
+			want:    "14.2.3",
+		},
+		{
+			name: "hoisted in the pnpm virtual store",
+			files: map[string]string{
+				"package-lock.json": `{"packages": {}}`,
+				"node_modules/.pnpm/node_modules/next/package.json": `{"name": "next", "version": "14.2.3"}`,
+			},
+			want: "14.2.3",
+		},
+		{
+			name: "parent without workspaces",
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": `{"packages": {"node_modules/next": {"version": "14.2.3"}}}`,
+			},
+			appRoot: "apps/web",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			appRoot := filepath.Join(dir, tc.appRoot)
+			if err := os.MkdirAll(appRoot, 0755); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appRoot))
+			got, err := Version(ctx, &PackageJSON{}, "next")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Version() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Version() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// benchmarkLockfilePackages is the number of packages in the lock files generated for benchmarks,
+// which is in line with the lock files of large applications.
+const benchmarkLockfilePackages = 50000
+
+func BenchmarkVersion(b *testing.B) {
+	testCases := []struct {
+		file string
+		lock func(w *strings.Builder, name string, i int)
+		head string
+		tail string
+	}{
+		{
+			file: "package-lock.json",
+			head: `{"name": "app", "lockfileVersion": 3, "packages": {"": {}`,
+			lock: func(w *strings.Builder, name string, i int) {
+				fmt.Fprintf(w, `, "node_modules/%s": {"version": "1.0.%d", "integrity": "sha512-%064d"}`, name, i, i)
+			},
+			tail: "}}\n",
+		},
+		{
+			file: "yarn.lock",
+			lock: func(w *strings.Builder, name string, i int) {
+				fmt.Fprintf(w, "%s@^1.0.0:\n  version \"1.0.%d\"\n  integrity sha512-%064d\n  dependencies:\n    dep \"^1.0.0\"\n\n", name, i, i)
+			},
+		},
+		{
+			file: "pnpm-lock.yaml",
+			head: "lockfileVersion: '9.0'\n\nimporters:\n\n  .:\n    dependencies:\n      target:\n        specifier: ^1.0.0\n        version: 1.0.0\n\npackages:\n\n",
+			lock: func(w *strings.Builder, name string, i int) {
+				fmt.Fprintf(w, "  %s@1.0.%d:\n    resolution: {integrity: sha512-%064d}\n\n", name, i, i)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		b.Run(tc.file, func(b *testing.B) {
+			var w strings.Builder
+			w.WriteString(tc.head)
+			for i := 0; i < benchmarkLockfilePackages; i++ {
+				tc.lock(&w, fmt.Sprintf("pkg-%d", i), i)
+			}
+			tc.lock(&w, "target", 0)
+			w.WriteString(tc.tail)
+			dir := b.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tc.file), []byte(w.String()), 0644); err != nil {
+				b.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			pjs := &PackageJSON{Dependencies: map[string]string{"target": "^1.0.0"}}
+			b.SetBytes(int64(w.Len()))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := Version(ctx, pjs, "target"); err != nil {
+					b.Fatalf("Version() got error: %v", err)
+				}
+			}
+		})
+	}
+}
+
 func setGoogleRuntime(t *testing.T, value string) {
 	googleRuntimeEnv := "GOOGLE_RUNTIME"
 	t.Cleanup(func() {
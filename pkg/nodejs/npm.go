@@ -39,6 +39,17 @@ const (
 	VendorNpmDeps = "GOOGLE_VENDOR_NPM_DEPENDENCIES"
 	// AppHostingBuildEnv is the env var that contains the build script to run for nextjs apps
 	AppHostingBuildEnv = "APPHOSTING_BUILD"
+	// BuildCommandEnv is the env var that contains a command to run instead of the build scripts. It
+	// is set from the buildConfig.buildCommand field of apphosting.yaml. The command is split on
+	// spaces and is not run in a shell.
+	BuildCommandEnv = "GOOGLE_NODEJS_BUILD_COMMAND"
+	// InstallCommandEnv is the env var that contains a command to run instead of the package
+	// manager's install command. It is set from the buildConfig.installCommand field of apphosting.yaml.
+	InstallCommandEnv = "GOOGLE_NODEJS_INSTALL_COMMAND"
+	// OutputDirEnv is the env var that contains the directory of static assets written by the build,
+	// relative to the application. It is set from the buildConfig.outputDirectory field of
+	// apphosting.yaml and defaults to `public`.
+	OutputDirEnv = "GOOGLE_NODEJS_OUTPUT_DIR"
 )
 
 var (
@@ -141,15 +152,17 @@ func SupportsNPMPrune(ctx *gcp.Context) (bool, error) {
 // and a bool representing whether this is a "custom build" (user-specified build scripts)
 // or a system build step (default build behavior).
 //
-// Users can specify npm scripts to run in three ways, with the following order of precedence:
-// 1. APPHOSTING_BUILD env var
-// 2. GOOGLE_NODE_RUN_SCRIPTS env var
-// 3. "gcp-build" script in package.json
-// 4. "build" script in package.json
+// Users can specify npm scripts to run in several ways, with the following order of precedence:
+// 1. GOOGLE_NODEJS_BUILD_COMMAND env var
+// 2. "googleBuildpacks.buildCommand" in package.json
+// 3. APPHOSTING_BUILD env var
+// 4. GOOGLE_NODEJS_NX_PROJECT env var
+// 5. GOOGLE_NODE_RUN_SCRIPTS env var
+// 6. "gcp-build" script in package.json
+// 7. "build" script in package.json
 func DetermineBuildCommands(pjs *PackageJSON, pkgTool string) (cmds []string, isCustomBuild bool) {
-	appHostingBuildScript, appHostingBuildScriptPresent := os.LookupEnv(AppHostingBuildEnv)
-	if appHostingBuildScriptPresent {
-		return []string{appHostingBuildScript}, true
+	if buildCommand, ok := BuildCommand(pjs); ok {
+		return []string{buildCommand}, true
 	}
 
 	envScript, envScriptPresent := os.LookupEnv(GoogleNodeRunScriptsEnv)
@@ -188,6 +201,32 @@ func DetermineBuildCommands(pjs *PackageJSON, pkgTool string) (cmds []string, is
 	return []string{}, false
 }
 
+// BuildCommand returns the command that replaces the build scripts, which is either the
+// BuildCommandEnv or the googleBuildpacks.buildCommand of package.json set by the user, the
+// AppHostingBuildEnv set by a framework adapter buildpack, or `nx build` of the project selected
+// with NxProjectEnv.
+func BuildCommand(pjs *PackageJSON) (string, bool) {
+	if cmd := strings.Fields(os.Getenv(BuildCommandEnv)); len(cmd) > 0 {
+		return strings.Join(cmd, " "), true
+	}
+	if pjs != nil {
+		if cmd := strings.Fields(pjs.GoogleBuildpacks.BuildCommand); len(cmd) > 0 {
+			return strings.Join(cmd, " "), true
+		}
+	}
+	if cmd, ok := os.LookupEnv(AppHostingBuildEnv); ok {
+		return cmd, true
+	}
+	return nxBuildCommand()
+}
+
+// InstallCommand returns the command set with InstallCommandEnv to run instead of the package
+// manager's install command.
+func InstallCommand() ([]string, bool) {
+	cmd := strings.Fields(os.Getenv(InstallCommandEnv))
+	return cmd, len(cmd) > 0
+}
+
 // IsUsingVendoredDependencies returns true if the builder should be using the vendored dependencies.
 func IsUsingVendoredDependencies() bool {
 	val, _ := env.IsPresentAndTrue(VendorNpmDeps)
@@ -202,31 +241,61 @@ func runCommand(pkgTool, command string) string {
 // web process if the user has not explicitly configured one. The algorithm follows the conventions
 // of Nodejs package.json files: https://docs.npmjs.com/cli/v10/configuring-npm/package-json#main
 // 1. if script.start is specified return `npm run start`
-// 2. if the project contains server.js `npm run start`
-// 3. if main is specified `node ${pjs.main}`
-// 4. otherwise `node index.js“
+// 2. if a framework server was built, such as .output/server/index.mjs, `node ${server}`
+// 3. if the project contains server.js `npm run start`, or server.mjs `node server.mjs`
+// 4. if main is specified `node ${pjs.main}`
+// 5. if exports is specified `node ${exports["."]}`, using the import conditions of ES modules
+// 6. otherwise `node index.js`, or index.mjs or index.cjs if only those exist
 func DefaultStartCommand(ctx *gcp.Context, pjs *PackageJSON) ([]string, error) {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if pjs == nil {
-		return []string{"node", "index.js"}, nil
+		return indexStartCommand(ctx, appDir)
 	}
 	if angularStart := ExtractAngularStartCommand(pjs); angularStart != "" {
 		return strings.Fields(angularStart), nil
 	}
+	// The start script of Astro projects runs the development server.
+	if astro, err := AstroStartCommand(ctx); err != nil || astro != nil {
+		return astro, err
+	}
 	if _, ok := pjs.Scripts["start"]; ok {
 		return []string{"npm", "run", "start"}, nil
 	}
 	if nuxt, err := NuxtStartCommand(ctx); err != nil || nuxt != nil {
 		return nuxt, err
 	}
-	exists, err := ctx.FileExists(ctx.ApplicationRoot(), "server.js")
+	if server, err := frameworkServer(ctx, appDir, pjs); err != nil || server != "" {
+		return []string{"node", server}, err
+	}
+	exists, err := ctx.FileExists(appDir, "server.js")
 	if err != nil {
 		return nil, err
 	}
 	if exists {
 		return []string{"npm", "run", "start"}, nil
 	}
+	// npm only defaults the start script to server.js, ES modules need to be run with node.
+	exists, err = ctx.FileExists(appDir, "server.mjs")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return []string{"node", "server.mjs"}, nil
+	}
 	if pjs.Main != "" {
 		return []string{"node", pjs.Main}, nil
 	}
-	return []string{"node", "index.js"}, nil
+	if entry := exportsEntry(pjs.Exports); entry != "" {
+		exists, err := ctx.FileExists(appDir, entry)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return []string{"node", entry}, nil
+		}
+	}
+	return indexStartCommand(ctx, appDir)
 }
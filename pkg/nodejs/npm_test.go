@@ -163,6 +163,8 @@ func TestDetermineBuildCommands(t *testing.T) {
 		appHostingBuildScriptValue string // ignored if `nextJsBuildScriptSet == false`
 		nodeRunScriptSet           bool
 		nodeRunScriptValue         string // ignored if `nodeRunScriptSet == false`
+		buildCommand               string
+		nxProject                  string
 		targetPlatformSet          bool
 		want                       []string
 		wantIsCustomBuild          bool
@@ -228,7 +230,50 @@ func TestDetermineBuildCommands(t *testing.T) {
 			wantIsCustomBuild: true,
 		},
 		{
-			name: "APPHOSTING_BUILD highest precedence",
+			name: "GOOGLE_NODEJS_BUILD_COMMAND highest precedence",
+			pjs: `{
+				"scripts": {
+					"build": "tsc --build --clean",
+					"gcp-build": "tsc --build"
+				}
+			}`,
+			appHostingBuildScriptSet:   true,
+			appHostingBuildScriptValue: "next-js build",
+			nodeRunScriptSet:           true,
+			nodeRunScriptValue:         "from-env",
+			buildCommand:               "  npx   vite build ",
+			want:                       []string{"npx vite build"},
+			wantIsCustomBuild:          true,
+		},
+		{
+			name: "package.json googleBuildpacks.buildCommand second precedence",
+			pjs: `{
+				"scripts": {
+					"build": "next build",
+					"build:apphosting": "node prebuild.js && $APPHOSTING_BUILD"
+				},
+				"googleBuildpacks": {
+					"buildCommand": "npm run build:apphosting"
+				}
+			}`,
+			appHostingBuildScriptSet:   true,
+			appHostingBuildScriptValue: "next-js build",
+			want:                       []string{"npm run build:apphosting"},
+			wantIsCustomBuild:          true,
+		},
+		{
+			name: "GOOGLE_NODEJS_BUILD_COMMAND takes precedence over package.json googleBuildpacks.buildCommand",
+			pjs: `{
+				"googleBuildpacks": {
+					"buildCommand": "npm run build:apphosting"
+				}
+			}`,
+			buildCommand:      "npx vite build",
+			want:              []string{"npx vite build"},
+			wantIsCustomBuild: true,
+		},
+		{
+			name: "APPHOSTING_BUILD third precedence",
 			pjs: `{
 				"scripts": {
 					"build": "tsc --build --clean",
@@ -243,7 +288,7 @@ func TestDetermineBuildCommands(t *testing.T) {
 			wantIsCustomBuild:          true,
 		},
 		{
-			name: "GOOGLE_NODE_RUN_SCRIPTS second precedence",
+			name: "GOOGLE_NODE_RUN_SCRIPTS fourth precedence",
 			pjs: `{
 				"scripts": {
 					"build": "tsc --build --clean",
@@ -287,6 +332,27 @@ func TestDetermineBuildCommands(t *testing.T) {
 			want:              []string{},
 			wantIsCustomBuild: false,
 		},
+		{
+			name: "Nx project higher precedence than GOOGLE_NODE_RUN_SCRIPTS and gcp-build",
+			pjs: `{
+				"scripts": {
+					"gcp-build": "tsc --build"
+				}
+			}`,
+			nodeRunScriptSet:   true,
+			nodeRunScriptValue: "lint",
+			nxProject:          "web",
+			want:               []string{"npx nx build web"},
+			wantIsCustomBuild:  true,
+		},
+		{
+			name:                       "APPHOSTING_BUILD higher precedence than Nx project",
+			appHostingBuildScriptSet:   true,
+			appHostingBuildScriptValue: "apphosting-adapter-nextjs-build",
+			nxProject:                  "web",
+			want:                       []string{"apphosting-adapter-nextjs-build"},
+			wantIsCustomBuild:          true,
+		},
 		{
 			name: "setting empty GOOGLE_NODE_RUN_SCRIPTS runs nothing",
 			pjs: `{
@@ -309,6 +375,12 @@ func TestDetermineBuildCommands(t *testing.T) {
 			if tc.nodeRunScriptSet {
 				t.Setenv(GoogleNodeRunScriptsEnv, tc.nodeRunScriptValue)
 			}
+			if tc.buildCommand != "" {
+				t.Setenv(BuildCommandEnv, tc.buildCommand)
+			}
+			if tc.nxProject != "" {
+				t.Setenv(NxProjectEnv, tc.nxProject)
+			}
 
 			if tc.targetPlatformSet {
 				t.Setenv(env.XGoogleTargetPlatform, env.TargetPlatformAppEngine)
@@ -337,6 +409,7 @@ func TestDefaultStartCommand(t *testing.T) {
 		name        string
 		pjs         string
 		hasServerJs bool
+		files       []string
 		want        []string
 	}{
 		{
@@ -398,6 +471,58 @@ func TestDefaultStartCommand(t *testing.T) {
 				}`,
 			want: []string{"node", "dist/my-angular-app/server/server.mjs"},
 		},
+		{
+			name:  "no package.json with index.mjs",
+			files: []string{"index.mjs"},
+			want:  []string{"node", "index.mjs"},
+		},
+		{
+			name:  "index.js is preferred",
+			pjs:   `{"type": "module"}`,
+			files: []string{"index.mjs", "index.js"},
+			want:  []string{"node", "index.js"},
+		},
+		{
+			name: "main module mjs",
+			pjs:  `{"type": "module", "main": "src/app.mjs"}`,
+			want: []string{"node", "src/app.mjs"},
+		},
+		{
+			name:  "exports map",
+			pjs:   `{"type": "module", "exports": {".": {"import": "./dist/server.js", "types": "./dist/server.d.ts"}}}`,
+			files: []string{"dist/server.js"},
+			want:  []string{"node", "dist/server.js"},
+		},
+		{
+			name:  "exports map with missing file",
+			pjs:   `{"exports": "./dist/server.js"}`,
+			files: []string{"index.cjs"},
+			want:  []string{"node", "index.cjs"},
+		},
+		{
+			name:  "server.mjs",
+			pjs:   `{"type": "module"}`,
+			files: []string{"server.mjs"},
+			want:  []string{"node", "server.mjs"},
+		},
+		{
+			name:  "nitro server",
+			pjs:   `{"dependencies": {"@solidjs/start": "1.0.0"}}`,
+			files: []string{".output/server/index.mjs", "server.js"},
+			want:  []string{"node", ".output/server/index.mjs"},
+		},
+		{
+			name:  "sveltekit node adapter",
+			pjs:   `{"devDependencies": {"@sveltejs/adapter-node": "5.0.0"}}`,
+			files: []string{"build/index.js"},
+			want:  []string{"node", "build/index.js"},
+		},
+		{
+			name:  "build directory without the sveltekit node adapter",
+			pjs:   `{"main": "main.js"}`,
+			files: []string{"build/index.js"},
+			want:  []string{"node", "main.js"},
+		},
 	}
 	for _, tc := range testsCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -414,6 +539,9 @@ func TestDefaultStartCommand(t *testing.T) {
 					t.Fatalf("failed to create server.js: %v", err)
 				}
 			}
+			for _, f := range tc.files {
+				writeFiles(t, home, map[string]string{f: ""})
+			}
 			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(home))
 
 			got, err := DefaultStartCommand(ctx, pjs)
@@ -2,16 +2,34 @@ package nodejs
 
 import (
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
 )
 
+func init() {
+	RegisterFrameworkAdapter(&NpmFrameworkAdapter{
+		Framework:          "nuxt",
+		DetectPackages:     []string{"nuxt"},
+		ConfigFiles:        []string{"nuxt.config.ts", "nuxt.config.js", "nuxt.config.mjs"},
+		VersionPackage:     "nuxt",
+		MinVersion:         semver.MustParse("3.0.0"),
+		AdaptorPackage:     "@apphosting/adapter-nuxt",
+		AdaptorBuildScript: "apphosting-adapter-nuxt-build",
+		BuildScripts:       []string{"nuxt build", "nuxi build"},
+	})
+}
+
 // NuxtStartCommand determines if this is a Nuxt application and returns the command to start the
 // nuxt server. If not it is not a Nuxt application it returns nil.
 func NuxtStartCommand(ctx *gcp.Context) ([]string, error) {
-	configExists, err := ctx.FileExists(ctx.ApplicationRoot(), "nuxt.config.ts")
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configExists, err := ctx.FileExists(appDir, "nuxt.config.ts")
 	if err != nil {
 		return nil, err
 	}
-	serverExists, err := ctx.FileExists(ctx.ApplicationRoot(), ".output/server/index.mjs")
+	serverExists, err := ctx.FileExists(appDir, ".output/server/index.mjs")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,238 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// NxProjectEnv is an env var used to select the project of an Nx workspace to build. The project
+	// is built with `nx build`, and if it has its own package.json the framework, build script and
+	// start command come from it as if it was selected with AppDirEnv.
+	// Example: `web`.
+	NxProjectEnv = "GOOGLE_NODEJS_NX_PROJECT"
+
+	// nxJSON is the configuration file at the root of an Nx workspace.
+	nxJSON = "nx.json"
+	// nxProjectJSON is the configuration file of a project in an Nx workspace.
+	nxProjectJSON = "project.json"
+	// nxDefaultCacheDir is the computation cache directory used when nx.json does not set one.
+	nxDefaultCacheDir = ".nx/cache"
+	// nxCacheLayer is the name of the cache-only layer the Nx computation cache is persisted in.
+	nxCacheLayer = "nx_cache"
+	// nxCacheKey is the metadata key used to store the hash the Nx cache layer is keyed on.
+	nxCacheKey = "nx_cache_sha"
+)
+
+// nxSkippedDirs are the directories not searched for Nx projects.
+var nxSkippedDirs = map[string]bool{"node_modules": true, ".git": true, ".nx": true, "dist": true}
+
+// NxProject returns the Nx project selected with NxProjectEnv, or an empty string if none is
+// selected. It returns an error if a project is selected in an app which is not an Nx workspace.
+func NxProject(ctx *gcp.Context) (string, error) {
+	project := strings.TrimSpace(os.Getenv(NxProjectEnv))
+	if project == "" {
+		return "", nil
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), nxJSON)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", gcp.UserErrorf("%s=%q is set but %s was not found", NxProjectEnv, project, nxJSON)
+	}
+	return project, nil
+}
+
+// nxBuildCommand returns the command which builds the Nx project selected with NxProjectEnv.
+func nxBuildCommand() (string, bool) {
+	project := strings.TrimSpace(os.Getenv(NxProjectEnv))
+	if project == "" {
+		return "", false
+	}
+	return "npx nx build " + project, true
+}
+
+// nxProjectDir returns the directory of the Nx project selected with NxProjectEnv relative to the
+// application root, or an empty string if no project is selected or the project does not have its
+// own package.json. A project is either a directory with a project.json, or a package.json,
+// whose name is the project.
+func nxProjectDir(ctx *gcp.Context) (string, error) {
+	project, err := NxProject(ctx)
+	if err != nil || project == "" {
+		return "", err
+	}
+	root := ctx.ApplicationRoot()
+	var projectDir string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if nxSkippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != nxProjectJSON && d.Name() != "package.json" {
+			return nil
+		}
+		raw, err := ctx.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var config struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			ctx.Debugf("Skipping %s while looking for Nx project %q: %v", path, project, err)
+			return nil
+		}
+		if config.Name == project {
+			projectDir = filepath.Dir(path)
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", gcp.InternalErrorf("looking for Nx project %q: %w", project, err)
+	}
+	if projectDir == "" {
+		return "", gcp.UserErrorf("%s=%q does not match the name of any project.json or package.json", NxProjectEnv, project)
+	}
+	exists, err := ctx.FileExists(projectDir, "package.json")
+	if err != nil || !exists || projectDir == root {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, projectDir)
+	if err != nil {
+		return "", gcp.InternalErrorf("finding Nx project %q relative to %s: %w", project, root, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// WithNxCache runs build with the computation cache of an Nx workspace restored from a cache-only
+// layer, and saves it back into the layer once build succeeds so that unchanged tasks are not
+// run again. The cache is cleared when the installed Nx version changes. build is run as is for
+// apps which are not Nx workspaces.
+func WithNxCache(ctx *gcp.Context, build func() error) error {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), nxJSON)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return build()
+	}
+	l, err := ctx.Layer(nxCacheLayer, gcp.CacheLayer)
+	if err != nil {
+		return gcp.InternalErrorf("creating layer: %w", err)
+	}
+	return withNxCacheLayer(ctx, l, build)
+}
+
+// WithBuildCaches runs build with both the Nx computation cache and the Next.js build cache
+// restored, see WithNxCache and WithNextjsBuildCache.
+func WithBuildCaches(ctx *gcp.Context, pjs *PackageJSON, build func() error) error {
+	return WithNxCache(ctx, func() error {
+		return WithNextjsBuildCache(ctx, pjs, build)
+	})
+}
+
+func withNxCacheLayer(ctx *gcp.Context, l *libcnb.Layer, build func() error) error {
+	appCache, err := nxCacheDir(ctx)
+	if err != nil {
+		return err
+	}
+	nxPjs, err := ReadPackageJSONIfExists(filepath.Join(ctx.ApplicationRoot(), "node_modules", "nx"))
+	if err != nil {
+		return err
+	}
+	var nxVersion string
+	if nxPjs != nil {
+		nxVersion = nxPjs.Version
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, nxCacheKey, cache.WithStrings(nxVersion))
+	if err != nil {
+		return err
+	}
+	layerCache := filepath.Join(l.Path, "cache")
+	if cached {
+		restore, err := ctx.FileExists(layerCache)
+		if err != nil {
+			return err
+		}
+		// Never overwrite a cache directory uploaded with the source.
+		if exists, err := ctx.FileExists(appCache); err != nil {
+			return err
+		} else if exists {
+			restore = false
+		}
+		if restore {
+			ctx.Logf("Restoring the Nx cache from the previous build.")
+			if err := copyDir(ctx, layerCache, appCache); err != nil {
+				return err
+			}
+		}
+	} else if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+
+	if err := build(); err != nil {
+		return err
+	}
+
+	exists, err := ctx.FileExists(appCache)
+	if err != nil || !exists {
+		return err
+	}
+	if err := copyDir(ctx, appCache, layerCache); err != nil {
+		return err
+	}
+	cache.Add(ctx, l, nxCacheKey, hash)
+	return nil
+}
+
+// nxCacheDir returns the absolute path of the computation cache directory, which is set by the
+// cacheDirectory field of nx.json.
+func nxCacheDir(ctx *gcp.Context) (string, error) {
+	raw, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), nxJSON))
+	if err != nil {
+		return "", err
+	}
+	var config struct {
+		CacheDirectory string `json:"cacheDirectory"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return "", gcp.UserErrorf("unmarshalling %s: %v", nxJSON, err)
+	}
+	dir := config.CacheDirectory
+	if dir == "" {
+		dir = nxDefaultCacheDir
+	}
+	if filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	return filepath.Join(ctx.ApplicationRoot(), dir), nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestNxProjectAppDir(t *testing.T) {
+	testCases := []struct {
+		name      string
+		nxProject string
+		files     map[string]string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:  "not set",
+			files: map[string]string{"nx.json": "{}"},
+			want:  ".",
+		},
+		{
+			name:      "project with package.json",
+			nxProject: "web",
+			files: map[string]string{
+				"nx.json":               "{}",
+				"package.json":          `{"name": "monorepo"}`,
+				"apps/web/project.json": `{"name": "web"}`,
+				"apps/web/package.json": `{"name": "@acme/web"}`,
+				"apps/api/project.json": `{"name": "api"}`,
+			},
+			want: "apps/web",
+		},
+		{
+			name:      "project inferred from package.json",
+			nxProject: "@acme/web",
+			files: map[string]string{
+				"nx.json":               "{}",
+				"apps/web/package.json": `{"name": "@acme/web"}`,
+			},
+			want: "apps/web",
+		},
+		{
+			name:      "project without package.json",
+			nxProject: "web",
+			files: map[string]string{
+				"nx.json":               "{}",
+				"package.json":          `{"name": "monorepo"}`,
+				"apps/web/project.json": `{"name": "web"}`,
+			},
+			want: ".",
+		},
+		{
+			name:      "projects in node_modules are ignored",
+			nxProject: "web",
+			files: map[string]string{
+				"nx.json":                       "{}",
+				"node_modules/web/package.json": `{"name": "web"}`,
+			},
+			wantErr: true,
+		},
+		{
+			name:      "not an Nx workspace",
+			nxProject: "web",
+			files: map[string]string{
+				"apps/web/project.json": `{"name": "web"}`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tc.files)
+			t.Setenv(NxProjectEnv, tc.nxProject)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := AppDir(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AppDir() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if want := filepath.Join(root, tc.want); got != want {
+				t.Errorf("AppDir() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithNxCache(t *testing.T) {
+	testCases := []struct {
+		name      string
+		nxJSON    string
+		nxVersion string
+		cacheDir  string
+		files     map[string]string
+		wantCache string
+	}{
+		{
+			name:      "unchanged",
+			nxJSON:    "{}",
+			nxVersion: "19.1.0",
+			wantCache: "first",
+		},
+		{
+			name:      "nx upgrade",
+			nxJSON:    "{}",
+			nxVersion: "19.2.0",
+		},
+		{
+			name:      "custom cache directory",
+			nxJSON:    `{"cacheDirectory": "tmp/nx-cache"}`,
+			nxVersion: "19.1.0",
+			cacheDir:  "tmp/nx-cache",
+			wantCache: "first",
+		},
+		{
+			name:      "cache uploaded with the source",
+			nxJSON:    "{}",
+			nxVersion: "19.1.0",
+			files:     map[string]string{".nx/cache/abc/terminalOutput": "source"},
+			wantCache: "source",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &libcnb.Layer{Name: nxCacheLayer, Path: t.TempDir(), Metadata: map[string]any{}}
+			cacheDir := tc.cacheDir
+			if cacheDir == "" {
+				cacheDir = nxDefaultCacheDir
+			}
+			build := func(dir, content string) func() error {
+				return func() error {
+					writeFiles(t, dir, map[string]string{filepath.Join(cacheDir, "abc/terminalOutput"): content})
+					return nil
+				}
+			}
+
+			first := t.TempDir()
+			writeFiles(t, first, map[string]string{
+				"nx.json":                      tc.nxJSON,
+				"node_modules/nx/package.json": `{"version": "19.1.0"}`,
+			})
+			if err := withNxCacheLayer(gcp.NewContext(gcp.WithApplicationRoot(first)), l, build(first, "first")); err != nil {
+				t.Fatalf("withNxCacheLayer() got error: %v", err)
+			}
+
+			second := t.TempDir()
+			writeFiles(t, second, map[string]string{
+				"nx.json":                      tc.nxJSON,
+				"node_modules/nx/package.json": `{"version": "` + tc.nxVersion + `"}`,
+			})
+			writeFiles(t, second, tc.files)
+			var gotCache string
+			err := withNxCacheLayer(gcp.NewContext(gcp.WithApplicationRoot(second)), l, func() error {
+				if raw, err := os.ReadFile(filepath.Join(second, cacheDir, "abc/terminalOutput")); err == nil {
+					gotCache = string(raw)
+				}
+				return build(second, "second")()
+			})
+			if err != nil {
+				t.Fatalf("withNxCacheLayer() got error: %v", err)
+			}
+			if gotCache != tc.wantCache {
+				t.Errorf("withNxCacheLayer() restored the Nx cache with %q, want %q", gotCache, tc.wantCache)
+			}
+			saved, err := os.ReadFile(filepath.Join(l.Path, "cache/abc/terminalOutput"))
+			if err != nil {
+				t.Fatalf("reading saved Nx cache: %v", err)
+			}
+			if string(saved) != "second" {
+				t.Errorf("withNxCacheLayer() saved the Nx cache with %q, want %q", saved, "second")
+			}
+		})
+	}
+}
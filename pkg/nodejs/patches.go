@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// patchPackageDir is the directory patch-package reads the patches of the dependencies from.
+const patchPackageDir = "patches"
+
+// PatchFiles returns the paths, relative to the application, of the patches applied to the dependencies of the application: the
+// patch-package patches in the patches directory and the patchedDependencies of pnpm in
+// package.json. Patches which do not exist are left for the package manager to report.
+func PatchFiles(ctx *gcp.Context, pjs *PackageJSON) ([]string, error) {
+	files, err := patchPackageFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pjs != nil {
+		for _, patch := range pjs.Pnpm.PatchedDependencies {
+			exists, err := ctx.FileExists(ctx.ApplicationRoot(), patch)
+			if err != nil {
+				return nil, err
+			}
+			// pnpm patches may also be in the patches directory.
+			if path := filepath.Clean(patch); exists && !slices.Contains(files, path) {
+				files = append(files, path)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// patchPackageFiles returns the paths of the patch-package patches, relative to the application.
+func patchPackageFiles(ctx *gcp.Context) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(ctx.ApplicationRoot(), patchPackageDir, "*.patch"))
+	if err != nil {
+		return nil, gcp.InternalErrorf("finding patches in %s: %w", patchPackageDir, err)
+	}
+	var files []string
+	for _, path := range paths {
+		files = append(files, filepath.Join(patchPackageDir, filepath.Base(path)))
+	}
+	return files, nil
+}
+
+// ApplyPatchPackage applies the patch-package patches of the application to the installed
+// dependencies, unless the postinstall script already runs patch-package. pnpm applies its
+// patchedDependencies itself during the install.
+func ApplyPatchPackage(ctx *gcp.Context, pjs *PackageJSON) error {
+	patches, err := patchPackageFiles(ctx)
+	if err != nil || len(patches) == 0 {
+		return err
+	}
+	if pjs != nil && strings.Contains(pjs.Scripts["postinstall"], "patch-package") {
+		ctx.Logf("Patches in %s are applied by the postinstall script.", patchPackageDir)
+		return nil
+	}
+	bin := filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin", "patch-package")
+	if _, err := os.Stat(bin); err != nil {
+		ctx.Warnf("Found %d patches in %s, but patch-package is not installed. Add patch-package to the dependencies to apply them.", len(patches), patchPackageDir)
+		return nil
+	}
+	ctx.Logf("Applying %d patches in %s with patch-package.", len(patches), patchPackageDir)
+	if _, err := ctx.Exec([]string{bin}, gcp.WithUserAttribution); err != nil {
+		return gcp.UserErrorf("applying patches with patch-package: %w", err)
+	}
+	return nil
+}
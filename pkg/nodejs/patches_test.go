@@ -0,0 +1,69 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPatchFiles(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		pjs   *PackageJSON
+		want  []string
+	}{
+		{
+			name: "no patches",
+		},
+		{
+			name: "patch-package",
+			files: map[string]string{
+				"patches/left-pad+1.3.0.patch":     "",
+				"patches/@scope+pkg+2.0.0.patch":   "",
+				"patches/README.md":                "",
+				"patches/nested/ignored+1.0.patch": "",
+			},
+			want: []string{"patches/@scope+pkg+2.0.0.patch", "patches/left-pad+1.3.0.patch"},
+		},
+		{
+			name: "pnpm patchedDependencies",
+			files: map[string]string{
+				"patches/left-pad@1.3.0.patch": "",
+			},
+			pjs: &PackageJSON{Pnpm: packagePnpmJSON{PatchedDependencies: map[string]string{
+				"left-pad@1.3.0": "./patches/left-pad@1.3.0.patch",
+				"is-odd@3.0.1":   "patches/missing.patch",
+			}}},
+			want: []string{"patches/left-pad@1.3.0.patch"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tc.files)
+			got, err := PatchFiles(gcp.NewContext(gcp.WithApplicationRoot(root)), tc.pjs)
+			if err != nil {
+				t.Fatalf("PatchFiles() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("PatchFiles() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	npmConfigPrefix  = "NPM_CONFIG_"
+	yarnConfigPrefix = "YARN_"
+	pnpmConfigPrefix = "PNPM_"
+)
+
+// npmYarnConfigs maps npm config keys, normalized to lowercase with underscores, to the equivalent
+// Yarn Berry environment variables. Yarn Berry ignores npm config entirely, while npm, pnpm and
+// Yarn 1 ignore the Yarn Berry variables.
+var npmYarnConfigs = []struct{ npm, yarn string }{
+	{"registry", "YARN_NPM_REGISTRY_SERVER"},
+	{"proxy", "YARN_HTTP_PROXY"},
+	{"https_proxy", "YARN_HTTPS_PROXY"},
+	{"strict_ssl", "YARN_ENABLE_STRICT_SSL"},
+	{"cafile", "YARN_CA_FILE_PATH"},
+	{"fetch_retries", "YARN_HTTP_RETRY"},
+	{"fetch_timeout", "YARN_HTTP_TIMEOUT"},
+}
+
+// PackageManagerConfigEnv returns the NPM_CONFIG_*, YARN_* and PNPM_* settings of the user, along
+// with their equivalents for the given package manager (npm, yarn or pnpm) where the user only
+// configured another one. For example NPM_CONFIG_REGISTRY is also passed to Yarn Berry as
+// YARN_NPM_REGISTRY_SERVER, so proxy, strict-ssl and fetch-retry settings take effect whichever
+// package manager installs the application or a framework adaptor. Pass the result last to
+// gcp.WithEnv so the settings of the user take precedence over the defaults of the buildpack.
+func PackageManagerConfigEnv(ctx *gcp.Context, pkgTool string) []string {
+	user := map[string]string{}
+	npmConfig := map[string]string{}
+	for _, e := range os.Environ() {
+		k, v, _ := strings.Cut(e, "=")
+		upper := strings.ToUpper(k)
+		switch {
+		case strings.HasPrefix(upper, npmConfigPrefix):
+			npmConfig[strings.ToLower(strings.ReplaceAll(upper[len(npmConfigPrefix):], "-", "_"))] = v
+		case strings.HasPrefix(upper, yarnConfigPrefix), strings.HasPrefix(upper, pnpmConfigPrefix):
+		default:
+			continue
+		}
+		user[k] = v
+	}
+
+	yarn2 := false
+	if pkgTool == "yarn" {
+		var err error
+		if yarn2, err = IsYarn2(ctx.ApplicationRoot()); err != nil {
+			ctx.Debugf("Determining the Yarn version: %v", err)
+		}
+	}
+	env := map[string]string{}
+	for _, c := range npmYarnConfigs {
+		npmValue, npmOK := npmConfig[c.npm]
+		yarnValue, yarnOK := user[c.yarn]
+		switch {
+		case yarn2 && npmOK && !yarnOK:
+			env[c.yarn] = npmValue
+		case !yarn2 && yarnOK && !npmOK:
+			env["npm_config_"+c.npm] = yarnValue
+		}
+	}
+
+	var translated []string
+	for k := range env {
+		translated = append(translated, k)
+	}
+	sort.Strings(translated)
+	if len(translated) > 0 {
+		ctx.Debugf("Passing package manager settings to %s as %s", pkgTool, strings.Join(translated, ", "))
+	}
+	for k, v := range user {
+		env[k] = v
+	}
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return vars
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPackageManagerConfigEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pkgTool string
+		files   map[string]string
+		env     map[string]string
+		want    []string
+	}{
+		{
+			name:    "no settings",
+			pkgTool: "npm",
+		},
+		{
+			name:    "npm settings for npm",
+			pkgTool: "npm",
+			env:     map[string]string{"NPM_CONFIG_REGISTRY": "https://mirror.test/", "npm_config_fetch_retries": "5"},
+			want:    []string{"NPM_CONFIG_REGISTRY=https://mirror.test/", "npm_config_fetch_retries=5"},
+		},
+		{
+			name:    "npm settings for yarn berry",
+			pkgTool: "yarn",
+			files:   map[string]string{YarnLock: "__metadata:\n  version: 6\n"},
+			env: map[string]string{
+				"NPM_CONFIG_REGISTRY":   "https://mirror.test/",
+				"npm_config_strict-ssl": "false",
+				"NPM_CONFIG_LOGLEVEL":   "verbose",
+			},
+			want: []string{
+				"NPM_CONFIG_LOGLEVEL=verbose",
+				"NPM_CONFIG_REGISTRY=https://mirror.test/",
+				"YARN_ENABLE_STRICT_SSL=false",
+				"YARN_NPM_REGISTRY_SERVER=https://mirror.test/",
+				"npm_config_strict-ssl=false",
+			},
+		},
+		{
+			name:    "yarn berry settings take precedence",
+			pkgTool: "yarn",
+			files:   map[string]string{YarnLock: "__metadata:\n  version: 6\n"},
+			env: map[string]string{
+				"NPM_CONFIG_REGISTRY":      "https://mirror.test/",
+				"YARN_NPM_REGISTRY_SERVER": "https://yarn-mirror.test/",
+			},
+			want: []string{"NPM_CONFIG_REGISTRY=https://mirror.test/", "YARN_NPM_REGISTRY_SERVER=https://yarn-mirror.test/"},
+		},
+		{
+			name:    "npm settings for yarn 1",
+			pkgTool: "yarn",
+			files:   map[string]string{YarnLock: "# yarn lockfile v1\n"},
+			env:     map[string]string{"NPM_CONFIG_HTTPS_PROXY": "http://proxy.test:3128"},
+			want:    []string{"NPM_CONFIG_HTTPS_PROXY=http://proxy.test:3128"},
+		},
+		{
+			name:    "yarn berry settings for adaptor install",
+			pkgTool: "npm",
+			env:     map[string]string{"YARN_NPM_REGISTRY_SERVER": "https://mirror.test/", "YARN_HTTP_RETRY": "4"},
+			want: []string{
+				"YARN_HTTP_RETRY=4",
+				"YARN_NPM_REGISTRY_SERVER=https://mirror.test/",
+				"npm_config_fetch_retries=4",
+				"npm_config_registry=https://mirror.test/",
+			},
+		},
+		{
+			name:    "pnpm settings",
+			pkgTool: "pnpm",
+			env:     map[string]string{"PNPM_HOME": "/pnpm", "npm_config_proxy": "http://proxy.test:3128"},
+			want:    []string{"PNPM_HOME=/pnpm", "npm_config_proxy=http://proxy.test:3128"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			unsetPackageManagerConfig(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got := PackageManagerConfigEnv(ctx, tc.pkgTool)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("PackageManagerConfigEnv(%q) mismatch (-want +got):\n%s", tc.pkgTool, diff)
+			}
+		})
+	}
+}
+
+// unsetPackageManagerConfig removes the package manager settings of the test environment for the
+// duration of the test.
+func unsetPackageManagerConfig(t *testing.T) {
+	t.Helper()
+	for _, e := range os.Environ() {
+		k, v, _ := strings.Cut(e, "=")
+		upper := strings.ToUpper(k)
+		if strings.HasPrefix(upper, npmConfigPrefix) || strings.HasPrefix(upper, yarnConfigPrefix) || strings.HasPrefix(upper, pnpmConfigPrefix) {
+			os.Unsetenv(k)
+			t.Cleanup(func() { os.Setenv(k, v) })
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package nodejs
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
 	"github.com/buildpacks/libcnb"
+	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -15,12 +21,18 @@ var (
 	PNPMLock = "pnpm-lock.yaml"
 	// pnpmDownloadURL is the template used to generate a pnpm download URL.
 	pnpmDownloadURL = "https://github.com/pnpm/pnpm/releases/download/v%s/pnpm-linux-x64"
+	// PNPMWorkspace is the name of the pnpm workspace file.
+	PNPMWorkspace = "pnpm-workspace.yaml"
 	// pnpmVersionKey is the metadata key used to store the pnpm version in the pnpn layer.
 	pnpmVersionKey = "version"
 )
 
-// InstallPNPM installs pnpm in the given layer if it is not already cached.
+// InstallPNPM installs pnpm in the given layer if it is not already cached. The exact version
+// declared in the packageManager field of package.json is installed with corepack.
 func InstallPNPM(ctx *gcp.Context, pnpmLayer *libcnb.Layer, pjs *PackageJSON) error {
+	if corepack, err := installWithCorepack(ctx, pnpmLayer, pjs, "pnpm"); err != nil || corepack {
+		return err
+	}
 	layerName := pnpmLayer.Name
 	installDir := filepath.Join(pnpmLayer.Path, "bin")
 	version, err := detectPNPMVersion(pjs)
@@ -87,3 +99,308 @@ func detectPNPMVersion(pjs *PackageJSON) (string, error) {
 	}
 	return version, nil
 }
+
+const (
+	// catalogProtocol is the prefix of package.json specifiers that refer to a pnpm catalog.
+	catalogProtocol = "catalog:"
+	// defaultCatalog is the name of the catalog referred to by a bare `catalog:` specifier.
+	defaultCatalog = "default"
+)
+
+// pnpmWorkspaceFile represents the catalogs defined in a pnpm-workspace.yaml file.
+type pnpmWorkspaceFile struct {
+	// Catalog is an alias of the default catalog.
+	Catalog  map[string]string            `yaml:"catalog"`
+	Catalogs map[string]map[string]string `yaml:"catalogs"`
+}
+
+// catalogName returns the name of the pnpm catalog referred to by a `catalog:` specifier, and
+// false if the specifier does not use the catalog protocol.
+func catalogName(specifier string) (string, bool) {
+	if !strings.HasPrefix(specifier, catalogProtocol) {
+		return "", false
+	}
+	name := strings.TrimSpace(strings.TrimPrefix(specifier, catalogProtocol))
+	if name == "" {
+		name = defaultCatalog
+	}
+	return name, true
+}
+
+// versionFromPnpmCatalog returns the concrete version of a package declared with the catalog
+// protocol. The catalogs section of the lock file is used when present. Otherwise the version range
+// from pnpm-workspace.yaml is matched against the packages in the lock file.
+func versionFromPnpmCatalog(ctx *gcp.Context, lockfile *PnpmLockfile, dir, catalog, pkg string) (string, error) {
+	if v := lockfile.Catalogs[catalog][pkg].Version; v != "" {
+		return trimPnpmPeerSuffix(v), nil
+	}
+	specifier, err := catalogSpecifier(ctx, dir, catalog, pkg)
+	if err != nil {
+		return "", err
+	}
+	if specifier == "" {
+		return "", gcp.UserErrorf("%s is declared with the %q protocol but catalog %q in %s does not define it", pkg, catalogProtocol, catalog, PNPMWorkspace)
+	}
+	c, err := version.Intersect(specifier)
+	if err != nil {
+		return "", gcp.UserErrorf("parsing version %q of %s in catalog %q: %v", specifier, pkg, catalog, err)
+	}
+	var versions []string
+	for id := range lockfile.Packages {
+		if name, v := splitPnpmPackageID(id); name == pkg {
+			versions = append(versions, v)
+		}
+	}
+	best, err := version.HighestSatisfying(c, versions, version.SkipInvalidVersions, version.WithoutSanitization)
+	if err != nil {
+		return "", gcp.UserErrorf("no version of %s matching %q from catalog %q found in %s, please run pnpm install to update it", pkg, specifier, catalog, PNPMLock)
+	}
+	return best, nil
+}
+
+// catalogSpecifier returns the version range of pkg in the given catalog of pnpm-workspace.yaml, or
+// an empty string if it is not defined.
+func catalogSpecifier(ctx *gcp.Context, dir, catalog, pkg string) (string, error) {
+	path := filepath.Join(dir, PNPMWorkspace)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	raw, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var ws pnpmWorkspaceFile
+	if err := yaml.Unmarshal(raw, &ws); err != nil {
+		return "", gcp.UserErrorf("parsing %s: %v", PNPMWorkspace, err)
+	}
+	if catalog == defaultCatalog {
+		if s, ok := ws.Catalog[pkg]; ok {
+			return s, nil
+		}
+	}
+	return ws.Catalogs[catalog][pkg], nil
+}
+
+// PnpmDependency is a dependency in a pnpm lock file.
+type PnpmDependency struct {
+	Version string `yaml:"version"`
+}
+
+// UnmarshalYAML accepts both the `{specifier, version}` object used since lock file v6 and the
+// plain version string used by older lock files.
+func (d *PnpmDependency) UnmarshalYAML(unmarshal func(any) error) error {
+	var version string
+	if err := unmarshal(&version); err == nil {
+		d.Version = version
+		return nil
+	}
+	var dep struct {
+		Version string `yaml:"version"`
+	}
+	if err := unmarshal(&dep); err != nil {
+		return err
+	}
+	d.Version = dep.Version
+	return nil
+}
+
+// PnpmImporter lists the dependencies of a directory of a pnpm workspace.
+type PnpmImporter struct {
+	Dependencies    map[string]PnpmDependency `yaml:"dependencies"`
+	DevDependencies map[string]PnpmDependency `yaml:"devDependencies"`
+}
+
+func (i PnpmImporter) version(pkg string) string {
+	if v := i.Dependencies[pkg].Version; v != "" {
+		return v
+	}
+	return i.DevDependencies[pkg].Version
+}
+
+// importerVersion returns the version of pkg in the given importer. If the importer does not
+// depend on pkg, the version is used if all the importers depending on pkg agree on it, which
+// covers applications built from the root of a workspace whose framework is a dependency of a
+// single package.
+func (l *PnpmLockfile) importerVersion(importer, pkg string) string {
+	if i, ok := l.Importers[importer]; ok {
+		if v := i.version(pkg); v != "" {
+			return v
+		}
+	}
+	version := ""
+	for _, i := range l.Importers {
+		v := i.version(pkg)
+		if v == "" {
+			continue
+		}
+		if version != "" && trimPnpmPeerSuffix(v) != trimPnpmPeerSuffix(version) {
+			return ""
+		}
+		version = v
+	}
+	return version
+}
+
+// pnpmWorkspaceRoot returns the closest parent directory of the application package in appDir
+// containing a pnpm workspace and its lock file.
+func pnpmWorkspaceRoot(ctx *gcp.Context, appDir string) (string, bool) {
+	dir := filepath.Clean(appDir)
+	for parent := filepath.Dir(dir); parent != dir; dir, parent = parent, filepath.Dir(parent) {
+		workspace, err := ctx.FileExists(parent, PNPMWorkspace)
+		if err != nil || !workspace {
+			continue
+		}
+		lock, err := ctx.FileExists(parent, PNPMLock)
+		if err == nil && lock {
+			return parent, true
+		}
+	}
+	return "", false
+}
+
+// splitPnpmPackageID splits a pnpm lock file package identifier into the package name and version.
+// Identifiers look like `next@14.2.3` (v9), `/next@14.2.3(react@18.2.0)` (v6) or `/next/14.2.3` (v5).
+func splitPnpmPackageID(id string) (string, string) {
+	id = trimPnpmPeerSuffix(strings.TrimPrefix(id, "/"))
+	sep := "@"
+	if i := strings.LastIndex(id, "@"); i <= 0 {
+		sep = "/"
+	}
+	i := strings.LastIndex(id, sep)
+	if i <= 0 {
+		return id, ""
+	}
+	return id[:i], id[i+1:]
+}
+
+// trimPnpmPeerSuffix removes the peer dependency suffix pnpm appends to versions, for example
+// `13.5.6(@babel/core@7.23.9)`, or `13.5.6_react@18.2.0` in lock files older than v6.
+func trimPnpmPeerSuffix(version string) string {
+	if i := strings.IndexAny(version, "(_"); i != -1 {
+		return version[:i]
+	}
+	return version
+}
+
+// pnpmLockKey is a key of a pnpm lock file along with its indentation.
+type pnpmLockKey struct {
+	indent int
+	key    string
+}
+
+// readPnpmLock reads the parts of a pnpm lock file needed to find the version of pkg: its entries
+// in the dependencies of the lock file, of each importer and of each catalog, and its entries in
+// the packages section. The lock file is read line by line and only the kept lines are decoded, so
+// the memory used does not grow with the size of the lock file. Reading stops once the version of
+// pkg in the top level dependencies or in the given importer is found.
+func readPnpmLock(r io.Reader, pkg, importer string) (*PnpmLockfile, error) {
+	var kept bytes.Buffer
+	var path []pnpmLockKey
+	skipIndent, blockIndent := -1, -1
+	final, found := false, false
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line == "---" {
+			// Only the first document of the lock file is read.
+			if len(path) > 0 {
+				break
+			}
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		if skipIndent != -1 && indent > skipIndent {
+			continue
+		}
+		skipIndent = -1
+		if blockIndent != -1 {
+			if indent > blockIndent {
+				kept.WriteString(line + "\n")
+				found = found || (final && strings.HasPrefix(trimmed, "version:"))
+				continue
+			}
+			if found {
+				break
+			}
+			blockIndent, final = -1, false
+		}
+		for len(path) > 0 && path[len(path)-1].indent >= indent {
+			path = path[:len(path)-1]
+		}
+		key, value := splitPnpmLockLine(trimmed)
+		path = append(path, pnpmLockKey{indent: indent, key: key})
+		keep, block := pnpmLockPathWanted(path, pkg, importer)
+		if !keep {
+			skipIndent = indent
+			continue
+		}
+		kept.WriteString(line + "\n")
+		if block {
+			blockIndent = indent
+			final = path[0].key == "dependencies" || path[0].key == "devDependencies" || path[1].key == importer && len(path) == 4
+			found = final && value != ""
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %w", PNPMLock, err)
+	}
+	var lockfile PnpmLockfile
+	if err := yaml.Unmarshal(kept.Bytes(), &lockfile); err != nil {
+		return nil, gcp.InternalErrorf("parsing pnpm lock file: %w", err)
+	}
+	return &lockfile, nil
+}
+
+// pnpmLockPathWanted returns whether the key at the end of path is kept by readPnpmLock, and
+// whether all the lines nested under it are kept as well.
+func pnpmLockPathWanted(path []pnpmLockKey, pkg, importer string) (keep, block bool) {
+	section := path[0].key
+	switch len(path) {
+	case 1:
+		switch section {
+		case "dependencies", "devDependencies", "importers", "catalogs", "packages":
+			return true, false
+		}
+	case 2:
+		switch section {
+		case "dependencies", "devDependencies":
+			return path[1].key == pkg, true
+		case "importers", "catalogs":
+			return true, false
+		case "packages":
+			name, _ := splitPnpmPackageID(path[1].key)
+			return name == pkg, true
+		}
+	case 3:
+		switch section {
+		case "importers":
+			return path[2].key == "dependencies" || path[2].key == "devDependencies", false
+		case "catalogs":
+			return path[2].key == pkg, true
+		}
+	case 4:
+		return section == "importers" && path[3].key == pkg, true
+	}
+	return false, false
+}
+
+// splitPnpmLockLine splits a line of a pnpm lock file into its key, which may be quoted, and its
+// inline value.
+func splitPnpmLockLine(line string) (string, string) {
+	if q := line[0]; q == '\'' || q == '"' {
+		if i := strings.Index(line[1:], string(q)+":"); i != -1 {
+			return line[1 : i+1], strings.TrimSpace(line[i+3:])
+		}
+	}
+	if strings.HasSuffix(line, ":") {
+		return strings.TrimSuffix(line, ":"), ""
+	}
+	key, value, _ := strings.Cut(line, ": ")
+	return key, strings.TrimSpace(value)
+}
@@ -127,3 +127,117 @@ func TestInstallPNPM(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitPnpmPackageID(t *testing.T) {
+	testCases := []struct {
+		id          string
+		wantName    string
+		wantVersion string
+	}{
+		{id: "next@14.2.3", wantName: "next", wantVersion: "14.2.3"},
+		{id: "@angular/core@17.1.2", wantName: "@angular/core", wantVersion: "17.1.2"},
+		{id: "/next@13.5.6(@babel/core@7.23.9)", wantName: "next", wantVersion: "13.5.6"},
+		{id: "/@angular/core/17.1.2", wantName: "@angular/core", wantVersion: "17.1.2"},
+		{id: "/next/13.5.6", wantName: "next", wantVersion: "13.5.6"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			gotName, gotVersion := splitPnpmPackageID(tc.id)
+			if gotName != tc.wantName || gotVersion != tc.wantVersion {
+				t.Errorf("splitPnpmPackageID(%q) = (%q, %q), want (%q, %q)", tc.id, gotName, gotVersion, tc.wantName, tc.wantVersion)
+			}
+		})
+	}
+}
+
+func TestVersionPnpmWorkspace(t *testing.T) {
+	lock := `
+lockfileVersion: '9.0'
+importers:
+  .:
+    devDependencies:
+      turbo:
+        specifier: ^2.0.0
+        version: 2.0.4
+  apps/docs:
+    dependencies:
+      next:
+        specifier: ^13.5.0
+        version: 13.5.6(react@18.3.1)
+  apps/web:
+    dependencies:
+      next:
+        specifier: ^14.2.0
+        version: 14.2.3(react@18.3.1)
+`
+	testCases := []struct {
+		name    string
+		appDir  string
+		files   map[string]string
+		pkg     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "app directory importer",
+			appDir: "apps/web",
+			files: map[string]string{
+				"pnpm-workspace.yaml": "packages:\n  - apps/*\n",
+				"pnpm-lock.yaml":      lock,
+			},
+			pkg:  "next",
+			want: "14.2.3",
+		},
+		{
+			name:   "other app directory importer",
+			appDir: "apps/docs",
+			files: map[string]string{
+				"pnpm-workspace.yaml": "packages:\n  - apps/*\n",
+				"pnpm-lock.yaml":      lock,
+			},
+			pkg:  "next",
+			want: "13.5.6",
+		},
+		{
+			name:   "workspace root with ambiguous versions",
+			appDir: ".",
+			files: map[string]string{
+				"pnpm-workspace.yaml": "packages:\n  - apps/*\n",
+				"pnpm-lock.yaml":      lock,
+			},
+			pkg:  "next",
+			want: "",
+		},
+		{
+			name:   "no workspace file",
+			appDir: "apps/web",
+			files: map[string]string{
+				"pnpm-lock.yaml": lock,
+			},
+			pkg:     "next",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			appDir := filepath.Join(root, tc.appDir)
+			if err := os.MkdirAll(appDir, 0755); err != nil {
+				t.Fatalf("creating %s: %v", appDir, err)
+			}
+			for f, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, f), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(appDir))
+			got, err := Version(ctx, &PackageJSON{}, tc.pkg)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Version() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Version() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
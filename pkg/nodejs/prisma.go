@@ -0,0 +1,205 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// prismaEnginesLayer is the name of the cache-only layer the Prisma engines are persisted in.
+	prismaEnginesLayer = "prisma_engines"
+	// prismaEnginesKey is the metadata key used to store the hash the Prisma engines layer is keyed on.
+	prismaEnginesKey = "prisma_engines_sha"
+)
+
+var (
+	// prismaSchemaFiles are the locations where the Prisma CLI looks up the schema by default.
+	prismaSchemaFiles = []string{filepath.Join("prisma", "schema.prisma"), "schema.prisma"}
+
+	// prismaClientDirs are the patterns, relative to a directory with node_modules, of the
+	// directories where `prisma generate` writes the client by default.
+	prismaClientDirs = []string{
+		filepath.Join("node_modules", ".prisma"),
+		filepath.Join("node_modules", ".pnpm", "*", "node_modules", ".prisma"),
+	}
+)
+
+// prismaGenerateCommands are the commands used to run the Prisma CLI installed with each package
+// manager.
+var prismaGenerateCommands = map[string][]string{
+	"npm":  {"npm", "exec", "--no", "--", "prisma", "generate"},
+	"pnpm": {"pnpm", "exec", "prisma", "generate"},
+	"yarn": {"yarn", "prisma", "generate"},
+}
+
+// prismaSchema returns the path of the Prisma schema of the application in appDir, or an empty
+// string if the application does not use the Prisma client.
+func prismaSchema(ctx *gcp.Context, pjs *PackageJSON, appDir string) (string, error) {
+	if pjs == nil || !hasDependency(pjs, "@prisma/client") {
+		return "", nil
+	}
+	files := prismaSchemaFiles
+	if pjs.Prisma.Schema != "" {
+		files = []string{pjs.Prisma.Schema}
+	}
+	for _, f := range files {
+		exists, err := ctx.FileExists(appDir, f)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return filepath.Join(appDir, f), nil
+		}
+	}
+	return "", nil
+}
+
+// GeneratePrismaClient runs `prisma generate` if the application depends on @prisma/client and has
+// a Prisma schema, so that builds do not need a postinstall script to generate the client. It
+// runs on every build because the client depends on the schema, which the cached node_modules are
+// not keyed on. The query engines downloaded by the CLI are cached in a dedicated layer.
+func GeneratePrismaClient(ctx *gcp.Context, pjs *PackageJSON, pkgTool string) error {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return err
+	}
+	schema, err := prismaSchema(ctx, pjs, appDir)
+	if err != nil || schema == "" {
+		return err
+	}
+	cli, err := hasPrismaCLI(ctx, pjs, appDir)
+	if err != nil {
+		return err
+	}
+	if !cli {
+		ctx.Warnf("Found %s but the prisma package is not installed, skipping prisma generate. Add prisma to the devDependencies in package.json to generate the Prisma client during the build.", schema)
+		return nil
+	}
+	l, err := ctx.Layer(prismaEnginesLayer, gcp.CacheLayer)
+	if err != nil {
+		return gcp.InternalErrorf("creating layer: %w", err)
+	}
+	if err := checkPrismaEnginesLayer(ctx, l, pjs); err != nil {
+		return err
+	}
+	ctx.Logf("Generating the Prisma client from %s", schema)
+	cmd, ok := prismaGenerateCommands[pkgTool]
+	if !ok {
+		return gcp.InternalErrorf("unsupported package manager %q for prisma generate", pkgTool)
+	}
+	// The Prisma CLI caches the engines it downloads in $XDG_CACHE_HOME/prisma.
+	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithWorkDir(appDir), gcp.WithEnv("XDG_CACHE_HOME="+l.Path)); err != nil {
+		return gcp.UserErrorf("generating the Prisma client: %w", err)
+	}
+	return nil
+}
+
+// hasPrismaCLI returns true if the Prisma CLI is installed in the application or workspace root.
+func hasPrismaCLI(ctx *gcp.Context, pjs *PackageJSON, appDir string) (bool, error) {
+	if hasDependency(pjs, "prisma") {
+		return true, nil
+	}
+	for _, dir := range []string{appDir, ctx.ApplicationRoot()} {
+		exists, err := ctx.FileExists(dir, "node_modules", ".bin", "prisma")
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+// checkPrismaEnginesLayer clears the Prisma engines layer when the Prisma version changes, so that
+// engines of old versions do not accumulate.
+func checkPrismaEnginesLayer(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON) error {
+	version, err := Version(ctx, pjs, "prisma")
+	if err != nil {
+		ctx.Debugf("Resolving the Prisma version for the engines cache key: %v", err)
+		version = dependencySpecifier(pjs, "prisma")
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, prismaEnginesKey, cache.WithStrings(version))
+	if err != nil {
+		return err
+	}
+	if cached {
+		return nil
+	}
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	cache.Add(ctx, l, prismaEnginesKey, hash)
+	return nil
+}
+
+// WithPrismaClient calls prune and restores the generated Prisma clients which it deleted from
+// node_modules, so that the client generated during the build survives the pruning of
+// devDependencies.
+func WithPrismaClient(ctx *gcp.Context, prune func() error) error {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return err
+	}
+	dirs := []string{ctx.ApplicationRoot()}
+	if appDir != ctx.ApplicationRoot() {
+		dirs = append(dirs, appDir)
+	}
+	var clients []string
+	for _, dir := range dirs {
+		for _, pattern := range prismaClientDirs {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return gcp.InternalErrorf("listing Prisma clients: %w", err)
+			}
+			clients = append(clients, matches...)
+		}
+	}
+	if len(clients) == 0 {
+		return prune()
+	}
+
+	backup, err := os.MkdirTemp("", "prisma")
+	if err != nil {
+		return gcp.InternalErrorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(backup)
+	for i, client := range clients {
+		if err := copyDir(ctx, client, filepath.Join(backup, fmt.Sprint(i))); err != nil {
+			return err
+		}
+	}
+	if err := prune(); err != nil {
+		return err
+	}
+	for i, client := range clients {
+		exists, err := ctx.FileExists(client)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		ctx.Logf("Restoring the generated Prisma client %s", client)
+		if err := copyDir(ctx, filepath.Join(backup, fmt.Sprint(i)), client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
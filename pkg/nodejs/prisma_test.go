@@ -0,0 +1,179 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestPrismaSchema(t *testing.T) {
+	testCases := []struct {
+		name  string
+		pjs   *PackageJSON
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "default schema",
+			pjs:   &PackageJSON{Dependencies: map[string]string{"@prisma/client": "^5.0.0"}},
+			files: map[string]string{"prisma/schema.prisma": ""},
+			want:  "prisma/schema.prisma",
+		},
+		{
+			name:  "schema at the root",
+			pjs:   &PackageJSON{Dependencies: map[string]string{"@prisma/client": "^5.0.0"}},
+			files: map[string]string{"schema.prisma": ""},
+			want:  "schema.prisma",
+		},
+		{
+			name: "schema set in package.json",
+			pjs: &PackageJSON{
+				Dependencies: map[string]string{"@prisma/client": "^5.0.0"},
+				Prisma:       packagePrismaJSON{Schema: "db/schema.prisma"},
+			},
+			files: map[string]string{"db/schema.prisma": "", "prisma/schema.prisma": ""},
+			want:  "db/schema.prisma",
+		},
+		{
+			name:  "no schema",
+			pjs:   &PackageJSON{Dependencies: map[string]string{"@prisma/client": "^5.0.0"}},
+			files: map[string]string{"index.js": ""},
+		},
+		{
+			name:  "no prisma client",
+			pjs:   &PackageJSON{DevDependencies: map[string]string{"prisma": "^5.0.0"}},
+			files: map[string]string{"prisma/schema.prisma": ""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := prismaSchema(gcp.NewContext(gcp.WithApplicationRoot(dir)), tc.pjs, dir)
+			if err != nil {
+				t.Fatalf("prismaSchema() got error: %v", err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(dir, tc.want)
+			}
+			if got != want {
+				t.Errorf("prismaSchema() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGeneratePrismaClient(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pjs     *PackageJSON
+		pkgTool string
+		mocks   []*mockprocess.Mock
+		wantErr bool
+	}{
+		{
+			name: "npm",
+			pjs: &PackageJSON{
+				Dependencies:    map[string]string{"@prisma/client": "^5.0.0"},
+				DevDependencies: map[string]string{"prisma": "^5.0.0"},
+			},
+			pkgTool: "npm",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm exec --no -- prisma generate$`, mockprocess.WithStdout("Generated Prisma Client")),
+			},
+		},
+		{
+			name: "pnpm failure",
+			pjs: &PackageJSON{
+				Dependencies:    map[string]string{"@prisma/client": "^5.0.0"},
+				DevDependencies: map[string]string{"prisma": "^5.0.0"},
+			},
+			pkgTool: "pnpm",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^pnpm exec prisma generate$`, mockprocess.WithStderr("schema error"), mockprocess.WithExitCode(1)),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no prisma cli",
+			pjs:     &PackageJSON{Dependencies: map[string]string{"@prisma/client": "^5.0.0"}},
+			pkgTool: "yarn",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`prisma generate`, mockprocess.WithExitCode(1)),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"prisma/schema.prisma": ""})
+			opts := append(getContextOpts(t, tc.mocks),
+				gcp.WithApplicationRoot(dir),
+				gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+			err := GeneratePrismaClient(gcp.NewContext(opts...), tc.pjs, tc.pkgTool)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("GeneratePrismaClient() got error: %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithPrismaClient(t *testing.T) {
+	testCases := []struct {
+		name   string
+		client string
+	}{
+		{
+			name:   "npm",
+			client: "node_modules/.prisma/client/index.js",
+		},
+		{
+			name:   "pnpm",
+			client: "node_modules/.pnpm/@prisma+client@5.0.0_prisma@5.0.0/node_modules/.prisma/client/index.js",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{tc.client: "generated", "node_modules/typescript/index.js": ""})
+
+			err := WithPrismaClient(gcp.NewContext(gcp.WithApplicationRoot(dir)), func() error {
+				return os.RemoveAll(filepath.Join(dir, "node_modules"))
+			})
+			if err != nil {
+				t.Fatalf("WithPrismaClient() got error: %v", err)
+			}
+			got, err := os.ReadFile(filepath.Join(dir, tc.client))
+			if err != nil {
+				t.Fatalf("reading the Prisma client: %v", err)
+			}
+			if string(got) != "generated" {
+				t.Errorf("WithPrismaClient() restored %q, want %q", got, "generated")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "node_modules/typescript")); !os.IsNotExist(err) {
+				t.Errorf("WithPrismaClient() restored pruned package typescript, got stat error: %v", err)
+			}
+		})
+	}
+}
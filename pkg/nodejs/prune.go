@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// PruneDevDependenciesEnv is an env var that removes devDependencies from node_modules after the
+// build, even when NODE_ENV is not production. It is set from the buildConfig.pruneDevDependencies
+// field of apphosting.yaml.
+const PruneDevDependenciesEnv = "GOOGLE_NODEJS_PRUNE_DEV_DEPENDENCIES"
+
+// PruneDevDependencies returns true if devDependencies should be pruned after the build.
+func PruneDevDependencies() (bool, error) {
+	prune, err := env.IsPresentAndTrue(PruneDevDependenciesEnv)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	return prune, nil
+}
+
+// DevDependenciesNeededAtRuntime returns the reasons pruning the devDependencies of the
+// application in appDir would break it at runtime, or nil if it is safe to prune. Pruning is
+// unsafe if the start script runs a binary provided by a devDependency, or if the Next.js
+// standalone output links into a node_modules directory outside of it, as it does when
+// dependencies are installed by pnpm.
+func DevDependenciesNeededAtRuntime(ctx *gcp.Context, pjs *PackageJSON, appDir string) ([]string, error) {
+	var reasons []string
+	if bin := startBinary(pjs); bin != "" {
+		devDeps := make([]string, 0, len(pjs.DevDependencies))
+		for dep := range pjs.DevDependencies {
+			devDeps = append(devDeps, dep)
+		}
+		sort.Strings(devDeps)
+		for _, dep := range devDeps {
+			bins, err := packageBinaries(ctx, appDir, dep)
+			if err != nil {
+				return nil, err
+			}
+			if bins[bin] {
+				reasons = append(reasons, fmt.Sprintf("the start script runs %q from devDependency %q", bin, dep))
+			}
+		}
+	}
+
+	standalone := filepath.Join(appDir, ".next", "standalone")
+	exists, err := ctx.FileExists(standalone)
+	if err != nil || !exists {
+		return reasons, err
+	}
+	err = filepath.WalkDir(standalone, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if !strings.HasPrefix(target, standalone+string(os.PathSeparator)) && strings.Contains(target, "/node_modules/") {
+			rel, _ := filepath.Rel(appDir, path)
+			reasons = append(reasons, fmt.Sprintf("the Next.js standalone output links %s into node_modules", rel))
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading Next.js standalone output: %w", err)
+	}
+	return reasons, nil
+}
+
+// startBinary returns the name of the binary run by the start script, skipping any leading env
+// var assignments, or an empty string if there is no start script.
+func startBinary(pjs *PackageJSON) string {
+	if pjs == nil {
+		return ""
+	}
+	for _, field := range strings.Fields(pjs.Scripts["start"]) {
+		if !strings.Contains(field, "=") {
+			return field
+		}
+	}
+	return ""
+}
+
+// packageBinaries returns the binaries installed by the given package in the node_modules of
+// appDir. A package which is not installed is assumed to provide a binary with its own name.
+func packageBinaries(ctx *gcp.Context, appDir, pkg string) (map[string]bool, error) {
+	unscoped := pkg[strings.LastIndex(pkg, "/")+1:]
+	path := filepath.Join(appDir, "node_modules", pkg, "package.json")
+	exists, err := ctx.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[string]bool{unscoped: true}, nil
+	}
+	raw, err := ctx.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pkgJSON struct {
+		Bin json.RawMessage `json:"bin"`
+	}
+	if err := json.Unmarshal(raw, &pkgJSON); err != nil {
+		return nil, gcp.UserErrorf("unmarshalling %s: %v", path, err)
+	}
+	bins := map[string]bool{}
+	var single string
+	var multiple map[string]string
+	if err := json.Unmarshal(pkgJSON.Bin, &single); err == nil && single != "" {
+		bins[unscoped] = true
+	} else if err := json.Unmarshal(pkgJSON.Bin, &multiple); err == nil {
+		for name := range multiple {
+			bins[name] = true
+		}
+	}
+	return bins, nil
+}
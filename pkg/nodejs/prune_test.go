@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDevDependenciesNeededAtRuntime(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pjs      *PackageJSON
+		files    map[string]string
+		symlinks map[string]string
+		want     []string
+	}{
+		{
+			name: "no start script",
+			pjs:  &PackageJSON{DevDependencies: map[string]string{"typescript": "^5.0.0"}},
+		},
+		{
+			name: "start script runs a dependency",
+			pjs: &PackageJSON{
+				Scripts:         map[string]string{"start": "next start"},
+				Dependencies:    map[string]string{"next": "14.2.3"},
+				DevDependencies: map[string]string{"typescript": "^5.0.0"},
+			},
+			files: map[string]string{
+				"node_modules/typescript/package.json": `{"bin": {"tsc": "./bin/tsc", "tsserver": "./bin/tsserver"}}`,
+			},
+		},
+		{
+			name: "start script runs a devDependency binary",
+			pjs: &PackageJSON{
+				Scripts:         map[string]string{"start": "PORT=8080 ts-node server.ts"},
+				DevDependencies: map[string]string{"ts-node": "^10.0.0", "typescript": "^5.0.0"},
+			},
+			files: map[string]string{
+				"node_modules/ts-node/package.json":    `{"bin": {"ts-node": "dist/bin.js", "ts-node-esm": "dist/bin-esm.js"}}`,
+				"node_modules/typescript/package.json": `{"bin": {"tsc": "./bin/tsc"}}`,
+			},
+			want: []string{`the start script runs "ts-node" from devDependency "ts-node"`},
+		},
+		{
+			name: "start script runs a scoped devDependency with a single binary",
+			pjs: &PackageJSON{
+				Scripts:         map[string]string{"start": "serve dist"},
+				DevDependencies: map[string]string{"@acme/serve": "^1.0.0"},
+			},
+			files: map[string]string{
+				"node_modules/@acme/serve/package.json": `{"bin": "cli.js"}`,
+			},
+			want: []string{`the start script runs "serve" from devDependency "@acme/serve"`},
+		},
+		{
+			name: "devDependency not installed",
+			pjs: &PackageJSON{
+				Scripts:         map[string]string{"start": "nodemon index.js"},
+				DevDependencies: map[string]string{"nodemon": "^3.0.0"},
+			},
+			want: []string{`the start script runs "nodemon" from devDependency "nodemon"`},
+		},
+		{
+			name: "standalone output with copied node_modules",
+			pjs:  &PackageJSON{Scripts: map[string]string{"start": "next start"}},
+			files: map[string]string{
+				".next/standalone/server.js":                      "",
+				".next/standalone/node_modules/next/package.json": "{}",
+			},
+		},
+		{
+			name: "standalone output linking into node_modules",
+			pjs:  &PackageJSON{Scripts: map[string]string{"start": "next start"}},
+			files: map[string]string{
+				".next/standalone/server.js":                         "",
+				"node_modules/.pnpm/next@14.2.3/node_modules/next/a": "",
+			},
+			symlinks: map[string]string{
+				".next/standalone/node_modules/next": "../../../node_modules/.pnpm/next@14.2.3/node_modules/next",
+			},
+			want: []string{"the Next.js standalone output links .next/standalone/node_modules/next into node_modules"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			for link, target := range tc.symlinks {
+				path := filepath.Join(dir, link)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", link, err)
+				}
+				if err := os.Symlink(target, path); err != nil {
+					t.Fatalf("creating symlink %s: %v", link, err)
+				}
+			}
+
+			got, err := DevDependenciesNeededAtRuntime(gcp.NewContext(), tc.pjs, dir)
+			if err != nil {
+				t.Fatalf("DevDependenciesNeededAtRuntime() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("DevDependenciesNeededAtRuntime() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
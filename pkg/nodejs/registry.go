@@ -20,6 +20,7 @@ import (
 	"io"
 	"net/http"
 
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
 	"github.com/hashicorp/go-retryablehttp"
 )
@@ -161,5 +162,6 @@ func sendRequest(url string, header http.Header) ([]byte, error) {
 func newRetryableHTTPClient() *http.Client {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
+	retryClient.HTTPClient = gcp.HTTPClient()
 	return retryClient.StandardClient()
 }
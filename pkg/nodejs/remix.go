@@ -0,0 +1,42 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"github.com/Masterminds/semver"
+)
+
+func init() {
+	RegisterFrameworkAdapter(&NpmFrameworkAdapter{
+		Framework:          "remix",
+		DetectPackages:     []string{"@remix-run/node"},
+		VersionPackage:     "@remix-run/node",
+		MinVersion:         semver.MustParse("2.0.0"),
+		AdaptorPackage:     "@apphosting/adapter-remix",
+		AdaptorBuildScript: "apphosting-adapter-remix-build",
+		BuildScripts:       []string{"remix vite:build", "remix build"},
+	})
+	// React Router framework mode is the successor of Remix. @react-router/dev is only used by
+	// framework mode, it distinguishes it from applications which use React Router as a library.
+	RegisterFrameworkAdapter(&NpmFrameworkAdapter{
+		Framework:          "react-router",
+		DetectPackages:     []string{"@react-router/dev"},
+		VersionPackage:     "react-router",
+		MinVersion:         semver.MustParse("7.0.0"),
+		AdaptorPackage:     "@apphosting/adapter-react-router",
+		AdaptorBuildScript: "apphosting-adapter-react-router-build",
+		BuildScripts:       []string{"react-router build"},
+	})
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// RuntimeLayer is the layer the Node.js runtime is installed in by the runtime buildpack.
+	RuntimeLayer = "node"
+	// runtimeBuildpackID is the ID of the buildpack which installs the Node.js runtime.
+	runtimeBuildpackID = "google.nodejs.runtime"
+)
+
+// ExcludeRuntimeFromLaunch marks the RuntimeLayer of the runtime buildpack in layersRoot as a
+// build and cache only layer, so that images which run no Node.js process do not include the
+// runtime. The lifecycle reads the layer types when it exports the image, after all buildpacks
+// ran. It does nothing if the runtime buildpack did not contribute the layer.
+func ExcludeRuntimeFromLaunch(ctx *gcp.Context, layersRoot string) error {
+	path := filepath.Join(layersRoot, runtimeBuildpackID, RuntimeLayer+".toml")
+	var layer map[string]interface{}
+	if _, err := toml.DecodeFile(path, &layer); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return gcp.InternalErrorf("decoding %s: %w", path, err)
+	}
+	types, ok := layer["types"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if launch, _ := types["launch"].(bool); !launch {
+		return nil
+	}
+	types["launch"] = false
+	var b bytes.Buffer
+	if err := toml.NewEncoder(&b).Encode(layer); err != nil {
+		return gcp.InternalErrorf("encoding %s: %w", path, err)
+	}
+	if err := ctx.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	ctx.Logf("Excluding the Node.js runtime from the image, it runs no Node.js process")
+	return nil
+}
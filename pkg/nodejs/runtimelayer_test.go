@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExcludeRuntimeFromLaunch(t *testing.T) {
+	testCases := []struct {
+		name  string
+		layer string
+		want  libcnb.LayerTypes
+	}{
+		{
+			name:  "launch layer",
+			layer: "[types]\n  build = true\n  cache = true\n  launch = true\n\n[metadata]\n  version = \"20.11.0\"\n",
+			want:  libcnb.LayerTypes{Build: true, Cache: true},
+		},
+		{
+			name:  "build layer",
+			layer: "[types]\n  build = true\n  cache = true\n\n[metadata]\n  version = \"20.11.0\"\n",
+			want:  libcnb.LayerTypes{Build: true, Cache: true},
+		},
+		{
+			name: "no runtime layer",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layersRoot := t.TempDir()
+			path := filepath.Join(layersRoot, runtimeBuildpackID, RuntimeLayer+".toml")
+			if tc.layer != "" {
+				writeFiles(t, layersRoot, map[string]string{filepath.Join(runtimeBuildpackID, RuntimeLayer+".toml"): tc.layer})
+			}
+
+			if err := ExcludeRuntimeFromLaunch(gcp.NewContext(), layersRoot); err != nil {
+				t.Fatalf("ExcludeRuntimeFromLaunch() got error: %v", err)
+			}
+			if tc.layer == "" {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("ExcludeRuntimeFromLaunch() created %s", path)
+				}
+				return
+			}
+			var got struct {
+				Types    libcnb.LayerTypes `toml:"types"`
+				Metadata map[string]string `toml:"metadata"`
+			}
+			if _, err := toml.DecodeFile(path, &got); err != nil {
+				t.Fatalf("decoding %s: %v", path, err)
+			}
+			if diff := cmp.Diff(tc.want, got.Types); diff != "" {
+				t.Errorf("ExcludeRuntimeFromLaunch() layer types mismatch (-want +got):\n%s", diff)
+			}
+			if got.Metadata["version"] != "20.11.0" {
+				t.Errorf("ExcludeRuntimeFromLaunch() dropped the layer metadata, got %v", got.Metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// NodeModulesSliceThresholdEnv is an env var used to set the size in MiB of node_modules above
+	// which it is exported in several image layers instead of as part of the application layer.
+	// Example: `512`, or `0` to always export node_modules with the application.
+	NodeModulesSliceThresholdEnv = "GOOGLE_NODEJS_NODE_MODULES_SLICE_THRESHOLD_MB"
+
+	// defaultNodeModulesSliceThresholdMB is the size of node_modules above which it is split.
+	defaultNodeModulesSliceThresholdMB = 256
+	// nodeModulesSliceBuckets is the number of layers node_modules is split into. It must not
+	// change between builds so that packages stay in the same layer and unchanged layers are reused.
+	nodeModulesSliceBuckets = 8
+	// pnpmVirtualStore is the directory in node_modules where pnpm installs packages.
+	pnpmVirtualStore = ".pnpm"
+)
+
+// SliceNodeModules splits a large node_modules directory of the application into several image
+// layers, so that they are pushed in parallel and layers whose packages did not change are reused.
+// Packages are assigned to a layer by the hash of their name, which keeps the assignment stable
+// when packages are added or removed. node_modules directories which are symlinks into a layer
+// are already exported separately and are left alone.
+func SliceNodeModules(ctx *gcp.Context) error {
+	threshold, err := nodeModulesSliceThreshold()
+	if err != nil || threshold == 0 {
+		return err
+	}
+	nm := filepath.Join(ctx.ApplicationRoot(), "node_modules")
+	fi, err := os.Lstat(nm)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return gcp.InternalErrorf("reading %s: %w", nm, err)
+	}
+	if !fi.IsDir() {
+		return nil
+	}
+	large, err := dirLargerThan(nm, threshold)
+	if err != nil || !large {
+		return err
+	}
+
+	packages, err := nodeModulesPackages(nm)
+	if err != nil {
+		return err
+	}
+	buckets := make([][]string, nodeModulesSliceBuckets)
+	for _, p := range packages {
+		h := fnv.New32a()
+		h.Write([]byte(p))
+		b := h.Sum32() % nodeModulesSliceBuckets
+		buckets[b] = append(buckets[b], filepath.Join("node_modules", p))
+	}
+	ctx.Logf("Splitting node_modules larger than %d MiB into %d layers", threshold>>20, nodeModulesSliceBuckets)
+	for _, paths := range buckets {
+		if len(paths) == 0 {
+			continue
+		}
+		sort.Strings(paths)
+		ctx.AddSlice(paths...)
+	}
+	return nil
+}
+
+// nodeModulesSliceThreshold returns the size of node_modules in bytes above which it is split, or
+// 0 if it must not be split.
+func nodeModulesSliceThreshold() (int64, error) {
+	v := os.Getenv(NodeModulesSliceThresholdEnv)
+	if v == "" {
+		return defaultNodeModulesSliceThresholdMB << 20, nil
+	}
+	mb, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || mb < 0 {
+		return 0, gcp.UserErrorf("%s=%q must be a non-negative number of MiB", NodeModulesSliceThresholdEnv, v)
+	}
+	return mb << 20, nil
+}
+
+// nodeModulesPackages returns the package directories in node_modules relative to it. Scoped
+// packages are listed individually, as are the packages in the pnpm virtual store.
+func nodeModulesPackages(nm string) ([]string, error) {
+	var packages []string
+	for _, dir := range []string{"", pnpmVirtualStore} {
+		entries, err := os.ReadDir(filepath.Join(nm, dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, gcp.InternalErrorf("reading %s: %w", filepath.Join(nm, dir), err)
+		}
+		for _, e := range entries {
+			name := filepath.Join(dir, e.Name())
+			switch {
+			case name == pnpmVirtualStore:
+				continue
+			case strings.HasPrefix(e.Name(), "@") && e.IsDir():
+				scoped, err := os.ReadDir(filepath.Join(nm, name))
+				if err != nil {
+					return nil, gcp.InternalErrorf("reading %s: %w", filepath.Join(nm, name), err)
+				}
+				for _, s := range scoped {
+					packages = append(packages, filepath.Join(name, s.Name()))
+				}
+			case strings.HasPrefix(e.Name(), "."):
+				// Metadata such as .bin and .package-lock.json stays with the application.
+				continue
+			default:
+				packages = append(packages, name)
+			}
+		}
+	}
+	return packages, nil
+}
+
+// dirLargerThan returns true if the files in dir are larger than size bytes in total. Symlinks
+// are not followed.
+func dirLargerThan(dir string, size int64) (bool, error) {
+	errLarge := fmt.Errorf("larger than %d bytes", size)
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if total += fi.Size(); total > size {
+			return errLarge
+		}
+		return nil
+	})
+	if err == errLarge {
+		return true, nil
+	}
+	if err != nil {
+		return false, gcp.InternalErrorf("measuring %s: %w", dir, err)
+	}
+	return false, nil
+}
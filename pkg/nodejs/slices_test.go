@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSliceNodeModules(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold string
+		files     map[string]int64
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "below threshold",
+			threshold: "1",
+			files: map[string]int64{
+				"node_modules/next/package.json": 1024,
+			},
+		},
+		{
+			name:      "above threshold",
+			threshold: "1",
+			files: map[string]int64{
+				"node_modules/next/dist/server.js":     2 << 20,
+				"node_modules/react/index.js":          1024,
+				"node_modules/@next/env/index.js":      1024,
+				"node_modules/.bin/next":               16,
+				"node_modules/.package-lock.json":      16,
+				"node_modules/.pnpm/sharp@0.33.4/x.js": 16,
+			},
+			want: []string{"node_modules/.pnpm/sharp@0.33.4", "node_modules/@next/env", "node_modules/next", "node_modules/react"},
+		},
+		{
+			name:      "disabled",
+			threshold: "0",
+			files: map[string]int64{
+				"node_modules/next/dist/server.js": 2 << 20,
+			},
+		},
+		{
+			name:      "no node_modules",
+			threshold: "1",
+		},
+		{
+			name:      "invalid threshold",
+			threshold: "lots",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for f, size := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+				if err := os.Truncate(path, size); err != nil {
+					t.Fatalf("resizing %s: %v", path, err)
+				}
+			}
+			t.Setenv(NodeModulesSliceThresholdEnv, tc.threshold)
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(root))
+
+			err := SliceNodeModules(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SliceNodeModules() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			var got []string
+			for _, s := range ctx.Slices() {
+				got = append(got, s.Paths...)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SliceNodeModules() slice paths mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
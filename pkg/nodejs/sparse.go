@@ -0,0 +1,158 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// SparseWorkspaceEnv is an env var used to restrict the node_modules directories cached and copied
+// by the buildpack to the package selected with AppDirEnv, the directories of a git sparse checkout
+// and the application root. It is enabled automatically for git sparse checkouts.
+// Example: `true` for a monorepo checked out partially without git metadata, or `false` to always
+// use the whole application root.
+const SparseWorkspaceEnv = "GOOGLE_NODEJS_SPARSE_WORKSPACE"
+
+var (
+	// sparseCheckoutFile is the file listing the patterns of a git sparse checkout.
+	sparseCheckoutFile = filepath.Join(".git", "info", "sparse-checkout")
+	// gitConfigFiles are the git config files which can enable a sparse checkout.
+	gitConfigFiles = []string{filepath.Join(".git", "config"), filepath.Join(".git", "config.worktree")}
+	// sparseCheckoutConfigRegexp matches the git config option which enables a sparse checkout.
+	sparseCheckoutConfigRegexp = regexp.MustCompile(`(?im)^\s*sparsecheckout\s*=\s*true\s*$`)
+)
+
+// SparseWorkspaceDirs returns the directories, relative to the application root, which node_modules
+// are cached and copied from when building one package of a sparse monorepo: the package selected
+// with AppDirEnv and the directories of the git sparse checkout. It returns nil if the whole
+// application root is used, which is the case unless a workspace package is selected and the
+// source is a sparse checkout or SparseWorkspaceEnv is true.
+func SparseWorkspaceDirs(ctx *gcp.Context) ([]string, error) {
+	rel, err := appDirRel(ctx)
+	if err != nil || rel == "." {
+		return nil, err
+	}
+	sparse, err := isSparseWorkspace(ctx)
+	if err != nil || !sparse {
+		return nil, err
+	}
+	dirs := []string{rel}
+	cone, err := sparseCheckoutDirs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range cone {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), dir)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			dirs = append(dirs, dir)
+		}
+	}
+	return outermostDirs(dirs), nil
+}
+
+// isSparseWorkspace returns true if SparseWorkspaceEnv is true, or if it is not set and the
+// application root is a git sparse checkout.
+func isSparseWorkspace(ctx *gcp.Context) (bool, error) {
+	if _, ok := os.LookupEnv(SparseWorkspaceEnv); ok {
+		sparse, err := env.IsPresentAndTrue(SparseWorkspaceEnv)
+		if err != nil {
+			return false, gcp.UserErrorf("%v", err)
+		}
+		return sparse, nil
+	}
+	for _, f := range gitConfigFiles {
+		raw, err := os.ReadFile(filepath.Join(ctx.ApplicationRoot(), f))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, gcp.InternalErrorf("reading %s: %w", f, err)
+		}
+		if sparseCheckoutConfigRegexp.Match(raw) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sparseCheckoutDirs returns the directories included recursively by a cone mode sparse checkout,
+// such as `apps/web` for the pattern `/apps/web/`. Parents of included directories, which are
+// followed by a negated `/parent/*/` pattern, and non-cone patterns are ignored.
+func sparseCheckoutDirs(ctx *gcp.Context) ([]string, error) {
+	raw, err := os.ReadFile(filepath.Join(ctx.ApplicationRoot(), sparseCheckoutFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %w", sparseCheckoutFile, err)
+	}
+	included := map[string]bool{}
+	s := bufio.NewScanner(bytes.NewReader(raw))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "!"):
+			// `!/apps/*/` excludes the subdirectories of a parent of an included directory.
+			if dir, ok := strings.CutSuffix(strings.Trim(line[1:], "/"), "/*"); ok {
+				included[dir] = false
+			}
+		case !strings.Contains(line, "*"):
+			if dir := strings.Trim(line, "/"); dir != "" {
+				if _, ok := included[dir]; !ok {
+					included[dir] = true
+				}
+			}
+		}
+	}
+	var dirs []string
+	for dir, recursive := range included {
+		if recursive {
+			dirs = append(dirs, filepath.FromSlash(dir))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// outermostDirs returns the sorted directories which are not nested in another one of dirs.
+func outermostDirs(dirs []string) []string {
+	sorted := append([]string(nil), dirs...)
+	sort.Strings(sorted)
+	var out []string
+	for _, dir := range sorted {
+		if len(out) > 0 {
+			last := out[len(out)-1]
+			if dir == last || strings.HasPrefix(dir, last+string(filepath.Separator)) {
+				continue
+			}
+		}
+		out = append(out, dir)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSparseWorkspaceDirs(t *testing.T) {
+	coneCheckout := "/*\n!/*/\n/apps/\n!/apps/*/\n/apps/web/\n/packages/ui/\n"
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		env     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not a sparse checkout",
+			files: map[string]string{
+				".git/config": "[core]\n\tbare = false\n",
+			},
+			env: map[string]string{AppDirEnv: "apps/web"},
+		},
+		{
+			name: "sparse checkout without workspace package",
+			files: map[string]string{
+				".git/config":               "[core]\n\tsparseCheckout = true\n",
+				".git/info/sparse-checkout": coneCheckout,
+			},
+		},
+		{
+			name: "cone mode sparse checkout",
+			files: map[string]string{
+				".git/config":               "[core]\n\tsparseCheckout = true\n",
+				".git/info/sparse-checkout": coneCheckout,
+				"packages/ui/package.json":  "{}",
+			},
+			env:  map[string]string{AppDirEnv: "apps/web"},
+			want: []string{"apps/web", "packages/ui"},
+		},
+		{
+			name: "sparse checkout enabled in worktree config",
+			files: map[string]string{
+				".git/config.worktree":      "[core]\n\tsparsecheckout = true\n",
+				".git/info/sparse-checkout": "/apps/web/\n/packages/missing/\n",
+			},
+			env:  map[string]string{AppDirEnv: "apps/web"},
+			want: []string{"apps/web"},
+		},
+		{
+			name: "enabled with env var",
+			env:  map[string]string{AppDirEnv: "apps/web", SparseWorkspaceEnv: "true"},
+			want: []string{"apps/web"},
+		},
+		{
+			name: "disabled with env var",
+			files: map[string]string{
+				".git/config":               "[core]\n\tsparseCheckout = true\n",
+				".git/info/sparse-checkout": coneCheckout,
+			},
+			env: map[string]string{AppDirEnv: "apps/web", SparseWorkspaceEnv: "false"},
+		},
+		{
+			name:    "invalid env var",
+			env:     map[string]string{AppDirEnv: "apps/web", SparseWorkspaceEnv: "sometimes"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"apps/web/package.json": "{}"})
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := SparseWorkspaceDirs(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SparseWorkspaceDirs() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SparseWorkspaceDirs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"github.com/Masterminds/semver"
+)
+
+func init() {
+	// svelte.config.js is also used by Svelte projects which do not use SvelteKit, so only the
+	// dependency is used to detect SvelteKit.
+	RegisterFrameworkAdapter(&NpmFrameworkAdapter{
+		Framework:          "sveltekit",
+		DetectPackages:     []string{"@sveltejs/kit"},
+		VersionPackage:     "@sveltejs/kit",
+		MinVersion:         semver.MustParse("1.0.0"),
+		AdaptorPackage:     "@apphosting/adapter-sveltekit",
+		AdaptorBuildScript: "apphosting-adapter-sveltekit-build",
+		BuildScripts:       []string{"vite build"},
+	})
+}
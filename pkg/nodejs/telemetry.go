@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// telemetryOptOut is an env var which disables the telemetry of a framework or CLI.
+type telemetryOptOut struct {
+	tool  string
+	name  string
+	value string
+}
+
+// telemetryOptOuts are the opt-outs of the telemetry sent by the frameworks, adapters and CLIs
+// commonly run during the build or at launch.
+var telemetryOptOuts = []telemetryOptOut{
+	{tool: "Next.js", name: "NEXT_TELEMETRY_DISABLED", value: "1"},
+	{tool: "Astro", name: "ASTRO_TELEMETRY_DISABLED", value: "1"},
+	{tool: "Nuxt", name: "NUXT_TELEMETRY_DISABLED", value: "1"},
+	{tool: "Angular CLI", name: "NG_CLI_ANALYTICS", value: "false"},
+	{tool: "Gatsby", name: "GATSBY_TELEMETRY_DISABLED", value: "1"},
+	{tool: "Turborepo", name: "TURBO_TELEMETRY_DISABLED", value: "1"},
+	{tool: "Storybook", name: "STORYBOOK_DISABLE_TELEMETRY", value: "1"},
+	{tool: "Expo", name: "EXPO_NO_TELEMETRY", value: "1"},
+	{tool: "Prisma", name: "CHECKPOINT_DISABLE", value: "1"},
+	{tool: "Strapi", name: "STRAPI_TELEMETRY_DISABLED", value: "true"},
+}
+
+// DisableTelemetry sets the env vars which opt out of the telemetry of the frameworks and CLIs in
+// the given layer, for the following buildpacks and at launch, unless env.DisableTelemetry is
+// false. Opt-outs set by the user are kept. Each toggle is recorded in the build settings.
+func DisableTelemetry(ctx *gcp.Context, l *libcnb.Layer) error {
+	disable := true
+	if v, ok := os.LookupEnv(env.DisableTelemetry); ok {
+		var err error
+		if disable, err = env.IsPresentAndTrue(env.DisableTelemetry); err != nil {
+			return gcp.UserErrorf("%s must be true or false, got %q: %w", env.DisableTelemetry, v, err)
+		}
+	}
+	if !disable {
+		ctx.Logf("Keeping the telemetry of frameworks enabled as %s is false.", env.DisableTelemetry)
+		ctx.RecordSetting("telemetry opt-out", "false", gcp.SourceEnv)
+		return nil
+	}
+	var applied int
+	for _, o := range telemetryOptOuts {
+		if v, ok := os.LookupEnv(o.name); ok {
+			ctx.RecordSetting(o.tool+" telemetry ("+o.name+")", v, gcp.SourceEnv)
+			continue
+		}
+		l.BuildEnvironment.Default(o.name, o.value)
+		l.LaunchEnvironment.Default(o.name, o.value)
+		ctx.RecordSetting(o.tool+" telemetry ("+o.name+")", o.value, gcp.SourceDefault)
+		applied++
+	}
+	if applied > 0 {
+		ctx.Logf("Disabling the telemetry of %d frameworks and CLIs, set %s=false to keep it enabled.", applied, env.DisableTelemetry)
+		buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.TelemetryOptOutsCounterID).Increment(int64(applied))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDisableTelemetry(t *testing.T) {
+	testCases := []struct {
+		name      string
+		env       map[string]string
+		wantBuild map[string]string
+		wantErr   bool
+	}{
+		{
+			name: "disabled by default",
+			wantBuild: map[string]string{
+				"NEXT_TELEMETRY_DISABLED.default":     "1",
+				"ASTRO_TELEMETRY_DISABLED.default":    "1",
+				"NUXT_TELEMETRY_DISABLED.default":     "1",
+				"NG_CLI_ANALYTICS.default":            "false",
+				"GATSBY_TELEMETRY_DISABLED.default":   "1",
+				"TURBO_TELEMETRY_DISABLED.default":    "1",
+				"STORYBOOK_DISABLE_TELEMETRY.default": "1",
+				"EXPO_NO_TELEMETRY.default":           "1",
+				"CHECKPOINT_DISABLE.default":          "1",
+				"STRAPI_TELEMETRY_DISABLED.default":   "true",
+			},
+		},
+		{
+			name: "keeps the opt-outs set by the user",
+			env:  map[string]string{"NEXT_TELEMETRY_DISABLED": "0", "NG_CLI_ANALYTICS": "ci"},
+			wantBuild: map[string]string{
+				"ASTRO_TELEMETRY_DISABLED.default":    "1",
+				"NUXT_TELEMETRY_DISABLED.default":     "1",
+				"GATSBY_TELEMETRY_DISABLED.default":   "1",
+				"TURBO_TELEMETRY_DISABLED.default":    "1",
+				"STORYBOOK_DISABLE_TELEMETRY.default": "1",
+				"EXPO_NO_TELEMETRY.default":           "1",
+				"CHECKPOINT_DISABLE.default":          "1",
+				"STRAPI_TELEMETRY_DISABLED.default":   "true",
+			},
+		},
+		{
+			name:      "kept enabled",
+			env:       map[string]string{env.DisableTelemetry: "false"},
+			wantBuild: map[string]string{},
+		},
+		{
+			name:    "invalid switch",
+			env:     map[string]string{env.DisableTelemetry: "sometimes"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+			l, err := ctx.Layer("telemetry", gcp.BuildLayer, gcp.LaunchLayer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = DisableTelemetry(ctx, l)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("DisableTelemetry() got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DisableTelemetry() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantBuild, map[string]string(l.BuildEnvironment)); diff != "" {
+				t.Errorf("DisableTelemetry() build env mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantBuild, map[string]string(l.LaunchEnvironment)); diff != "" {
+				t.Errorf("DisableTelemetry() launch env mismatch (-want +got):\n%s", diff)
+			}
+			settings := map[string]string{}
+			for _, s := range ctx.Settings() {
+				settings[s.Name] = s.Value
+			}
+			if v, ok := tc.env["NEXT_TELEMETRY_DISABLED"]; ok && settings["Next.js telemetry (NEXT_TELEMETRY_DISABLED)"] != v {
+				t.Errorf("DisableTelemetry() recorded %q for the Next.js telemetry, want %q", settings["Next.js telemetry (NEXT_TELEMETRY_DISABLED)"], v)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// NvmrcFile is the name of the file used by nvm to pin the Node.js version.
+	NvmrcFile = ".nvmrc"
+	// NodeVersionFile is the name of the file used by nodenv, fnm and asdf to pin the Node.js version.
+	NodeVersionFile = ".node-version"
+	// voltaSource is the configuration source recorded for the volta.node field of package.json.
+	voltaSource = "package.json volta.node"
+)
+
+// ltsCodenames maps the codenames of Node.js LTS release lines, which nvm accepts as `lts/iron`,
+// to their major version.
+var ltsCodenames = map[string]string{
+	"argon":    "4",
+	"boron":    "6",
+	"carbon":   "8",
+	"dubnium":  "10",
+	"erbium":   "12",
+	"fermium":  "14",
+	"gallium":  "16",
+	"hydrogen": "18",
+	"iron":     "20",
+	"jod":      "22",
+}
+
+// pinnedNodejsVersion returns the Node.js version pinned in .nvmrc, .node-version or the volta
+// config of package.json, in that order, along with the source it was read from. Version files
+// are read from the application package first and then from the workspace root. An empty source
+// means no version is pinned, while an empty version with a source means the latest version was
+// requested, for example with `lts/*`.
+func pinnedNodejsVersion(ctx *gcp.Context, pjs *PackageJSON) (string, string, error) {
+	appDir, err := AppDir(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	dirs := []string{appDir}
+	if appDir != ctx.ApplicationRoot() {
+		dirs = append(dirs, ctx.ApplicationRoot())
+	}
+	for _, f := range []string{NvmrcFile, NodeVersionFile} {
+		for _, dir := range dirs {
+			path := filepath.Join(dir, f)
+			exists, err := ctx.FileExists(path)
+			if err != nil {
+				return "", "", err
+			}
+			if !exists {
+				continue
+			}
+			raw, err := ctx.ReadFile(path)
+			if err != nil {
+				return "", "", err
+			}
+			version, err := parseNodeVersionFile(string(raw))
+			if err != nil {
+				return "", "", gcp.UserErrorf("parsing %s: %v", path, err)
+			}
+			return version, f, nil
+		}
+	}
+	if pjs != nil && pjs.Volta.Node != "" {
+		return pjs.Volta.Node, voltaSource, nil
+	}
+	return "", "", nil
+}
+
+// parseNodeVersionFile returns the Node.js version in the contents of a .nvmrc or .node-version
+// file. The first line which is not a comment holds the version, with an optional `v` prefix.
+// nvm aliases for the latest release, such as `node` and `lts/*`, return an empty version.
+func parseNodeVersionFile(content string) (string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		switch {
+		case line == "node" || line == "stable" || line == "lts/*":
+			return "", nil
+		case strings.HasPrefix(line, "lts/"):
+			codename := strings.ToLower(strings.TrimPrefix(line, "lts/"))
+			major, ok := ltsCodenames[codename]
+			if !ok {
+				return "", gcp.UserErrorf("unknown Node.js LTS release %q", line)
+			}
+			return major, nil
+		}
+		return strings.TrimPrefix(line, "v"), nil
+	}
+	return "", gcp.UserErrorf("no version found")
+}
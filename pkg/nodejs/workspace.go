@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// AppDirEnv is an env var used to select the package of a monorepo to build, such as a Turborepo,
+// Nx, npm, yarn or pnpm workspace. Dependencies are installed for the whole workspace, while the
+// framework, build script and start command come from the package in this directory.
+// It can also be set in apphosting.yaml with BUILD and RUNTIME availability.
+// Example: `apps/web`.
+const AppDirEnv = "GOOGLE_NODEJS_APP_DIR"
+
+// AppDir returns the absolute path of the package to build, which is the application root unless
+// a workspace package is selected with AppDirEnv or NxProjectEnv.
+func AppDir(ctx *gcp.Context) (string, error) {
+	rel, err := appDirRel(ctx)
+	if err != nil || rel == "." {
+		return ctx.ApplicationRoot(), err
+	}
+	return filepath.Join(ctx.ApplicationRoot(), rel), nil
+}
+
+// IsWorkspacePackage returns true if a workspace package other than the application root is
+// selected with AppDirEnv or NxProjectEnv.
+func IsWorkspacePackage(ctx *gcp.Context) (bool, error) {
+	rel, err := appDirRel(ctx)
+	return rel != ".", err
+}
+
+// appDirRel returns the directory selected with AppDirEnv, or of the Nx project selected with
+// NxProjectEnv, relative to the application root, in slash-separated form, or `.` if none is
+// selected.
+func appDirRel(ctx *gcp.Context) (string, error) {
+	dir := strings.TrimSpace(os.Getenv(AppDirEnv))
+	if dir == "" {
+		nxDir, err := nxProjectDir(ctx)
+		if err != nil || nxDir == "" {
+			return ".", err
+		}
+		return nxDir, nil
+	}
+	if filepath.IsAbs(dir) {
+		rel, err := filepath.Rel(ctx.ApplicationRoot(), dir)
+		if err != nil {
+			return "", gcp.UserErrorf("%s=%q must be inside the application root %s", AppDirEnv, dir, ctx.ApplicationRoot())
+		}
+		dir = rel
+	}
+	dir = filepath.Clean(dir)
+	if dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return "", gcp.UserErrorf("%s=%q must be inside the application root %s", AppDirEnv, os.Getenv(AppDirEnv), ctx.ApplicationRoot())
+	}
+	if dir == "." {
+		return dir, nil
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), dir, "package.json")
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", gcp.UserErrorf("%s=%q does not contain a package.json", AppDirEnv, os.Getenv(AppDirEnv))
+	}
+	return filepath.ToSlash(dir), nil
+}
+
+// ReadAppPackageJSON returns the deserialized package.json of the package selected with AppDirEnv,
+// or of the application root if none is selected.
+func ReadAppPackageJSON(ctx *gcp.Context) (*PackageJSON, error) {
+	dir, err := AppDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPackageJSONIfExists(dir)
+}
+
+// WorkspacePackageDir returns the absolute path of the workspace package selected with AppDirEnv,
+// or an empty string if none is selected. It is used as the working directory of build scripts and
+// application processes, where an empty string keeps the application root.
+func WorkspacePackageDir(ctx *gcp.Context) (string, error) {
+	workspace, err := IsWorkspacePackage(ctx)
+	if err != nil || !workspace {
+		return "", err
+	}
+	return AppDir(ctx)
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestAppDir(t *testing.T) {
+	testCases := []struct {
+		name    string
+		appDir  string
+		files   []string
+		want    string
+		wantPkg string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: ".",
+		},
+		{
+			name:    "workspace package",
+			appDir:  "apps/web",
+			files:   []string{"apps/web/package.json"},
+			want:    "apps/web",
+			wantPkg: "apps/web",
+		},
+		{
+			name:    "unclean path",
+			appDir:  "./apps/web/",
+			files:   []string{"apps/web/package.json"},
+			want:    "apps/web",
+			wantPkg: "apps/web",
+		},
+		{
+			name:   "application root",
+			appDir: ".",
+			want:   ".",
+		},
+		{
+			name:    "no package.json",
+			appDir:  "apps/web",
+			files:   []string{"apps/web/index.js"},
+			wantErr: true,
+		},
+		{
+			name:    "outside of the application root",
+			appDir:  "../web",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			t.Setenv(AppDirEnv, tc.appDir)
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(root))
+
+			got, err := AppDir(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AppDir() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if want := filepath.Join(root, tc.want); got != want {
+				t.Errorf("AppDir() = %q, want %q", got, want)
+			}
+			gotPkg, err := WorkspacePackageDir(ctx)
+			if err != nil {
+				t.Fatalf("WorkspacePackageDir() got error: %v", err)
+			}
+			wantPkg := ""
+			if tc.wantPkg != "" {
+				wantPkg = filepath.Join(root, tc.wantPkg)
+			}
+			if gotPkg != wantPkg {
+				t.Errorf("WorkspacePackageDir() = %q, want %q", gotPkg, wantPkg)
+			}
+		})
+	}
+}
+
+func TestVersionWorkspacePackage(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "npm workspace hoisted",
+			files: map[string]string{
+				"package-lock.json": `{"packages": {"node_modules/next": {"version": "14.2.3"}}}`,
+			},
+			want: "14.2.3",
+		},
+		{
+			name: "npm workspace nested",
+			files: map[string]string{
+				"package-lock.json": `{"packages": {
+					"node_modules/next": {"version": "13.5.6"},
+					"apps/web/node_modules/next": {"version": "14.2.3"}
+				}}`,
+			},
+			want: "14.2.3",
+		},
+		{
+			name: "pnpm workspace",
+			files: map[string]string{
+				"pnpm-lock.yaml": `
+lockfileVersion: '9.0'
+importers:
+  apps/docs:
+    dependencies:
+      next:
+        specifier: ^13.5.0
+        version: 13.5.6
+  apps/web:
+    dependencies:
+      next:
+        specifier: ^14.2.0
+        version: 14.2.3(react@18.3.1)
+`,
+			},
+			want: "14.2.3",
+		},
+		{
+			name: "lock file in the package",
+			files: map[string]string{
+				"package-lock.json":          `{"packages": {"node_modules/next": {"version": "13.5.6"}}}`,
+				"apps/web/package-lock.json": `{"packages": {"node_modules/next": {"version": "14.2.3"}}}`,
+			},
+			want: "14.2.3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(root, "apps", "web"), 0755); err != nil {
+				t.Fatalf("creating app dir: %v", err)
+			}
+			tc.files["apps/web/package.json"] = `{"dependencies": {"next": "^14.2.0"}}`
+			for f, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, f), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			t.Setenv(AppDirEnv, "apps/web")
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(root))
+
+			got, err := LookupLockedVersion(ctx, "next")
+			if err != nil {
+				t.Fatalf("LookupLockedVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("LookupLockedVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"path/filepath"
+	"regexp"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	nuxtConfigFiles = []string{"nuxt.config.ts", "nuxt.config.js", "nuxt.config.mjs"}
+
+	// nuxtCachedRouteRegexp matches the routeRules of a nuxt config which cache the rendered pages.
+	nuxtCachedRouteRegexp = regexp.MustCompile(`\b(isr|swr|cache)\s*:`)
+)
+
+// RuntimeWritablePaths returns the directories, relative to appDir, the framework of the app is
+// known to write to while serving requests. Writes to these directories fail when the filesystem
+// of the container is read-only.
+func RuntimeWritablePaths(ctx *gcp.Context, pjs *PackageJSON, appDir string) ([]string, error) {
+	var paths []string
+	nextjs, err := isNextjsApp(ctx, pjs, appDir)
+	if err != nil {
+		return nil, err
+	}
+	if nextjs {
+		staticExport, err := NextjsStaticExport(ctx, appDir)
+		if err != nil {
+			return nil, err
+		}
+		// Incremental static regeneration and image optimization write to .next/cache.
+		if !staticExport {
+			paths = append(paths, filepath.Join(".next", "cache"))
+		}
+	}
+	if dependencySpecifier(pjs, "nuxt") != "" {
+		cached, err := nuxtCachesRoutes(ctx, appDir)
+		if err != nil {
+			return nil, err
+		}
+		// The nitro server persists the cached routes to .nitro/cache.
+		if cached {
+			paths = append(paths, filepath.Join(".nitro", "cache"))
+		}
+	}
+	return paths, nil
+}
+
+// nuxtCachesRoutes returns true if the nuxt config of the app in appDir caches rendered routes.
+func nuxtCachesRoutes(ctx *gcp.Context, appDir string) (bool, error) {
+	for _, f := range nuxtConfigFiles {
+		exists, err := ctx.FileExists(appDir, f)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(filepath.Join(appDir, f))
+		if err != nil {
+			return false, err
+		}
+		return nuxtCachedRouteRegexp.Match(content), nil
+	}
+	return false, nil
+}
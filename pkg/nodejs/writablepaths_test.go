@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRuntimeWritablePaths(t *testing.T) {
+	testCases := []struct {
+		name  string
+		pjs   *PackageJSON
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "no framework",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"express": "^4.0.0"}},
+		},
+		{
+			name: "nextjs server",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"next": "^14.0.0"}},
+			want: []string{".next/cache"},
+		},
+		{
+			name:  "nextjs static export",
+			pjs:   &PackageJSON{Dependencies: map[string]string{"next": "^14.0.0"}},
+			files: map[string]string{"next.config.js": `module.exports = { output: 'export' }`},
+		},
+		{
+			name:  "nuxt without cached routes",
+			pjs:   &PackageJSON{Dependencies: map[string]string{"nuxt": "^3.0.0"}},
+			files: map[string]string{"nuxt.config.ts": `export default defineNuxtConfig({ ssr: true })`},
+		},
+		{
+			name: "nuxt with isr route rules",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"nuxt": "^3.0.0"}},
+			files: map[string]string{"nuxt.config.ts": `export default defineNuxtConfig({
+  routeRules: {
+    '/blog/**': { isr: 3600 },
+  },
+})`},
+			want: []string{".nitro/cache"},
+		},
+		{
+			name:  "nuxt with swr route rules",
+			pjs:   &PackageJSON{Dependencies: map[string]string{"nuxt": "^3.0.0"}},
+			files: map[string]string{"nuxt.config.mjs": `export default { routeRules: { '/**': { swr: true } } }`},
+			want:  []string{".nitro/cache"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := RuntimeWritablePaths(ctx, tc.pjs, dir)
+			if err != nil {
+				t.Fatalf("RuntimeWritablePaths() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("RuntimeWritablePaths() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
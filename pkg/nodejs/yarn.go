@@ -42,7 +42,8 @@ const (
 
 type yarn2Lock struct {
 	Metadata struct {
-		Version string `yaml:"version"`
+		Version  string `yaml:"version"`
+		CacheKey string `yaml:"cacheKey"`
 	} `yaml:"__metadata"`
 }
 
@@ -100,8 +101,12 @@ func detectYarnVersion(pjs *PackageJSON) (string, error) {
 	return version, nil
 }
 
-// InstallYarnLayer installs Yarn in the given layer if it is not already cached.
+// InstallYarnLayer installs Yarn in the given layer if it is not already cached. The exact version
+// declared in the packageManager field of package.json is installed with corepack.
 func InstallYarnLayer(ctx *gcp.Context, yarnLayer *libcnb.Layer, pjs *PackageJSON) error {
+	if corepack, err := installWithCorepack(ctx, yarnLayer, pjs, "yarn"); err != nil || corepack {
+		return err
+	}
 	layerName := yarnLayer.Name
 	version, err := detectYarnVersion(pjs)
 	if err != nil {
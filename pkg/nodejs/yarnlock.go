@@ -0,0 +1,216 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// YarnLockEntry is a package resolved in a yarn.lock file.
+type YarnLockEntry struct {
+	// Descriptors are the dependency descriptors resolved to this entry, for example
+	// `next@^14.2.0` in yarn classic or `next@npm:^14.2.0` in yarn berry.
+	Descriptors []string
+	// Version is the resolved version of the package.
+	Version string
+}
+
+// YarnLockfile is a parsed yarn.lock file, in either the yarn classic (v1) format or the YAML
+// format used by yarn berry (v2 and newer).
+type YarnLockfile struct {
+	// Berry is true if the lock file contains the __metadata entry written by yarn berry.
+	Berry   bool
+	Entries []YarnLockEntry
+}
+
+// ParseYarnLock parses the contents of a yarn.lock file. Only the top level fields of each entry
+// are read, so the nested dependencies of an entry are not mistaken for its own version.
+// Indentation is relative to the first entry, so lock files which were re-indented as a whole,
+// for example when embedded in other files, are still read.
+func ParseYarnLock(raw []byte) (*YarnLockfile, error) {
+	lockfile := &YarnLockfile{}
+	berry, err := scanYarnLock(bytes.NewReader(raw), func(e YarnLockEntry) bool {
+		lockfile.Entries = append(lockfile.Entries, e)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	lockfile.Berry = berry
+	return lockfile, nil
+}
+
+// lookupYarnLock returns the version pkg is locked to in the yarn.lock read from r, as Lookup
+// does. Only the entries of pkg are kept in memory and reading stops at the entry resolving
+// specifier.
+func lookupYarnLock(r io.Reader, pkg, specifier string) (string, bool, error) {
+	specifier = strings.TrimPrefix(specifier, "npm:")
+	lockfile := &YarnLockfile{}
+	version := ""
+	_, err := scanYarnLock(r, func(e YarnLockEntry) bool {
+		for _, d := range e.Descriptors {
+			name, versionRange := splitYarnDescriptor(d)
+			if name != pkg {
+				continue
+			}
+			if specifier != "" && strings.TrimPrefix(versionRange, "npm:") == specifier {
+				version = e.Version
+				return false
+			}
+			lockfile.Entries = append(lockfile.Entries, e)
+			break
+		}
+		return true
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if version != "" {
+		return version, true, nil
+	}
+	version, ok := lockfile.Lookup(pkg, specifier)
+	return version, ok, nil
+}
+
+// scanYarnLock calls visit with each entry of the yarn.lock read from r until visit returns false.
+// It returns true if the lock file was written by yarn berry.
+func scanYarnLock(r io.Reader, visit func(YarnLockEntry) bool) (bool, error) {
+	berry := false
+	var entry *YarnLockEntry
+	flush := func() bool {
+		return entry == nil || entry.Version == "" || visit(*entry)
+	}
+	entryIndent, fieldIndent := -1, -1
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		if entryIndent == -1 {
+			entryIndent = indent
+		}
+		if indent <= entryIndent && strings.HasSuffix(trimmed, ":") {
+			if !flush() {
+				return berry, nil
+			}
+			entry, fieldIndent = nil, -1
+			header := strings.TrimSuffix(trimmed, ":")
+			if header == "__metadata" {
+				berry = true
+				continue
+			}
+			entry = &YarnLockEntry{Descriptors: parseYarnDescriptors(header)}
+			continue
+		}
+		if entry == nil {
+			if indent <= entryIndent {
+				return false, gcp.InternalErrorf("parsing %s: unexpected line %d: %q", YarnLock, lineNum, line)
+			}
+			continue
+		}
+		if fieldIndent == -1 {
+			fieldIndent = indent
+		}
+		if indent != fieldIndent {
+			continue
+		}
+		key, value, _ := strings.Cut(trimmed, " ")
+		if strings.TrimSuffix(key, ":") == "version" {
+			entry.Version = unquoteYarnValue(strings.TrimSpace(value))
+		}
+	}
+	if err := s.Err(); err != nil {
+		return false, gcp.InternalErrorf("parsing %s: %w", YarnLock, err)
+	}
+	flush()
+	return berry, nil
+}
+
+// Lookup returns the version pkg is locked to. If specifier is set, only the entry resolving that
+// version range of pkg is considered, otherwise pkg must be locked to a single version. Aliased
+// dependencies, such as `next13@npm:next@13`, are only matched by their alias.
+func (l *YarnLockfile) Lookup(pkg, specifier string) (string, bool) {
+	specifier = strings.TrimPrefix(specifier, "npm:")
+	versions := map[string]bool{}
+	for _, e := range l.Entries {
+		for _, d := range e.Descriptors {
+			name, versionRange := splitYarnDescriptor(d)
+			if name != pkg {
+				continue
+			}
+			if specifier != "" && strings.TrimPrefix(versionRange, "npm:") == specifier {
+				return e.Version, true
+			}
+			versions[e.Version] = true
+		}
+	}
+	if len(versions) != 1 {
+		return "", false
+	}
+	for v := range versions {
+		return v, true
+	}
+	return "", false
+}
+
+// parseYarnDescriptors splits the header of a yarn.lock entry, for example
+// `"next@^14.0.0", "next@^14.2.0"`, into its descriptors.
+func parseYarnDescriptors(header string) []string {
+	var descriptors []string
+	for _, d := range strings.Split(header, ",") {
+		if d = unquoteYarnValue(strings.TrimSpace(d)); d != "" {
+			descriptors = append(descriptors, d)
+		}
+	}
+	return descriptors
+}
+
+// splitYarnDescriptor splits a descriptor into the package name and the version range. The name
+// ends at the first @ which does not start a scope.
+func splitYarnDescriptor(d string) (string, string) {
+	i := strings.Index(d[1:], "@")
+	if i == -1 {
+		return d, ""
+	}
+	return d[:i+1], d[i+2:]
+}
+
+func unquoteYarnValue(v string) string {
+	if u, err := strconv.Unquote(v); err == nil {
+		return u
+	}
+	return strings.Trim(v, `"'`)
+}
+
+// LookupLockedVersion returns the version of pkg locked in the lock file of the application,
+// using the version range declared in package.json to disambiguate packages locked to several
+// versions.
+func LookupLockedVersion(ctx *gcp.Context, pkg string) (string, error) {
+	pjs, err := ReadAppPackageJSON(ctx)
+	if err != nil {
+		return "", err
+	}
+	return Version(ctx, pjs, pkg)
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const yarnClassicLock = `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@next/env@14.2.3":
+  version "14.2.3"
+  resolved "https://registry.yarnpkg.com/@next/env/-/env-14.2.3.tgz"
+
+next-13@npm:next@13:
+  version "13.5.6"
+  resolved "https://registry.yarnpkg.com/next/-/next-13.5.6.tgz"
+
+next@^14.0.0, next@^14.2.0:
+  version "14.2.3"
+  resolved "https://registry.yarnpkg.com/next/-/next-14.2.3.tgz"
+  dependencies:
+    "@next/env" "14.2.3"
+    styled-jsx "5.1.1"
+
+next@~12.3.0:
+  version "12.3.4"
+  resolved "https://registry.yarnpkg.com/next/-/next-12.3.4.tgz"
+
+styled-jsx@5.1.1:
+  version "5.1.1"
+`
+
+const yarnBerryLock = `# This file is generated by running "yarn install" inside your project.
+# Manual changes might be lost - proceed with caution!
+
+__metadata:
+  version: 8
+  cachekey: 10c0
+
+"@sveltejs/kit@npm:^2.0.0":
+  version: 2.5.4
+  resolution: "@sveltejs/kit@npm:2.5.4"
+  dependencies:
+    "@types/cookie": "npm:^0.6.0"
+  checksum: 10c0/abc
+  languageName: node
+  linkType: hard
+
+"next@npm:^14.0.0, next@npm:^14.2.0":
+  version: 14.2.3
+  resolution: "next@npm:14.2.3"
+  peerDependencies:
+    react: ^18.2.0
+  languageName: node
+  linkType: hard
+
+"next@npm:~12.3.0":
+  version: 12.3.4
+  resolution: "next@npm:12.3.4"
+  languageName: node
+  linkType: hard
+`
+
+func TestParseYarnLock(t *testing.T) {
+	testCases := []struct {
+		name      string
+		lock      string
+		wantBerry bool
+		wantCount int
+	}{
+		{
+			name:      "classic",
+			lock:      yarnClassicLock,
+			wantCount: 5,
+		},
+		{
+			name:      "berry",
+			lock:      yarnBerryLock,
+			wantBerry: true,
+			wantCount: 3,
+		},
+		{
+			name:      "re-indented",
+			lock:      "\n\t\t\t\tnext@^14.2.0:\n\tversion \"14.2.3\"\n\t\t\t\tstyled-jsx@5.1.1:\n\tversion \"5.1.1\"\n",
+			wantCount: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseYarnLock([]byte(tc.lock))
+			if err != nil {
+				t.Fatalf("ParseYarnLock() got error: %v", err)
+			}
+			if got.Berry != tc.wantBerry {
+				t.Errorf("ParseYarnLock() Berry = %v, want %v", got.Berry, tc.wantBerry)
+			}
+			if len(got.Entries) != tc.wantCount {
+				t.Errorf("ParseYarnLock() got %d entries, want %d: %v", len(got.Entries), tc.wantCount, got.Entries)
+			}
+		})
+	}
+}
+
+func TestParseYarnLockInvalid(t *testing.T) {
+	if _, err := ParseYarnLock([]byte("next@^14.0.0\n  version \"14.2.3\"\n")); err == nil {
+		t.Error("ParseYarnLock() got no error, want error")
+	}
+}
+
+func TestYarnLockfileLookup(t *testing.T) {
+	testCases := []struct {
+		name      string
+		lock      string
+		pkg       string
+		specifier string
+		want      string
+		wantOK    bool
+	}{
+		{
+			name:      "classic specifier among several versions",
+			lock:      yarnClassicLock,
+			pkg:       "next",
+			specifier: "^14.2.0",
+			want:      "14.2.3",
+			wantOK:    true,
+		},
+		{
+			name:      "classic older version",
+			lock:      yarnClassicLock,
+			pkg:       "next",
+			specifier: "~12.3.0",
+			want:      "12.3.4",
+			wantOK:    true,
+		},
+		{
+			name:   "classic ambiguous without specifier",
+			lock:   yarnClassicLock,
+			pkg:    "next",
+			wantOK: false,
+		},
+		{
+			name:   "classic scoped package",
+			lock:   yarnClassicLock,
+			pkg:    "@next/env",
+			want:   "14.2.3",
+			wantOK: true,
+		},
+		{
+			name:      "classic alias",
+			lock:      yarnClassicLock,
+			pkg:       "next-13",
+			specifier: "npm:next@13",
+			want:      "13.5.6",
+			wantOK:    true,
+		},
+		{
+			name:      "berry specifier",
+			lock:      yarnBerryLock,
+			pkg:       "next",
+			specifier: "^14.0.0",
+			want:      "14.2.3",
+			wantOK:    true,
+		},
+		{
+			name:      "berry scoped package with unmatched specifier",
+			lock:      yarnBerryLock,
+			pkg:       "@sveltejs/kit",
+			specifier: "^2.5.0",
+			want:      "2.5.4",
+			wantOK:    true,
+		},
+		{
+			name:   "nested dependency versions ignored",
+			lock:   yarnBerryLock,
+			pkg:    "react",
+			wantOK: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := ParseYarnLock([]byte(tc.lock))
+			if err != nil {
+				t.Fatalf("ParseYarnLock() got error: %v", err)
+			}
+			got, ok := l.Lookup(tc.pkg, tc.specifier)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("Lookup(%q, %q) = (%q, %v), want (%q, %v)", tc.pkg, tc.specifier, got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestLookupLockedVersion(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"package.json": `{"dependencies": {"next": "~12.3.0"}}`,
+		"yarn.lock":    yarnClassicLock,
+	}
+	for f, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	got, err := LookupLockedVersion(gcp.NewContext(gcp.WithApplicationRoot(dir)), "next")
+	if err != nil {
+		t.Fatalf("LookupLockedVersion() got error: %v", err)
+	}
+	if want := "12.3.4"; got != want {
+		t.Errorf("LookupLockedVersion() = %q, want %q", got, want)
+	}
+}
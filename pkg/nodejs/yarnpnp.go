@@ -0,0 +1,193 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// yarnrc is the name of the Yarn Berry config file.
+	yarnrc = ".yarnrc.yml"
+	// yarnCacheLayer is the name of the cache-only layer the Yarn cache is persisted in.
+	yarnCacheLayer = "yarn_cache"
+	// yarnCacheKey is the metadata key used to store the hash the Yarn cache layer is keyed on.
+	yarnCacheKey = "yarn_cache_sha"
+)
+
+var (
+	// pnpFiles are the loaders Yarn generates in Plug'n'Play mode.
+	pnpFiles = []string{".pnp.cjs", ".pnp.js"}
+
+	// defaultYarnCacheFolder is the Yarn cache folder, relative to the project, used when the
+	// global cache is disabled.
+	defaultYarnCacheFolder = filepath.Join(".yarn", "cache")
+)
+
+// yarnrcYML represents the contents of a .yarnrc.yml file.
+type yarnrcYML struct {
+	NodeLinker  string `yaml:"nodeLinker"`
+	CacheFolder string `yaml:"cacheFolder"`
+}
+
+func readYarnrc(ctx *gcp.Context) (yarnrcYML, error) {
+	var rc yarnrcYML
+	path := filepath.Join(ctx.ApplicationRoot(), yarnrc)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return rc, err
+	}
+	raw, err := ctx.ReadFile(path)
+	if err != nil {
+		return rc, err
+	}
+	if err := yaml.Unmarshal(raw, &rc); err != nil {
+		return rc, gcp.UserErrorf("parsing %s: %v", yarnrc, err)
+	}
+	return rc, nil
+}
+
+// IsYarnPnP returns true if the Yarn Berry project at the application root installs its
+// dependencies in Plug'n'Play mode (https://yarnpkg.com/features/pnp), in which case there is no
+// node_modules directory. It is the default unless nodeLinker is set to another linker.
+func IsYarnPnP(ctx *gcp.Context) (bool, error) {
+	for _, f := range pnpFiles {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	linker := os.Getenv("YARN_NODE_LINKER")
+	if linker == "" {
+		rc, err := readYarnrc(ctx)
+		if err != nil {
+			return false, err
+		}
+		linker = rc.NodeLinker
+	}
+	return linker == "" || linker == "pnp", nil
+}
+
+// YarnPnPEnv returns the environment used to install dependencies in Plug'n'Play mode. The global
+// cache is disabled because .pnp.cjs references the zip archives in the cache, which must be part
+// of the application image.
+func YarnPnPEnv() []string {
+	return []string{"YARN_ENABLE_GLOBAL_CACHE=false"}
+}
+
+// YarnPnPCommand returns cmd wrapped to run with the Plug'n'Play loader, so that node scripts
+// which are not run with `yarn run` can resolve the dependencies of the application.
+func YarnPnPCommand(cmd []string) []string {
+	if len(cmd) == 0 || cmd[0] == "yarn" {
+		return cmd
+	}
+	if cmd[0] == "node" {
+		return append([]string{"yarn", "node"}, cmd[1:]...)
+	}
+	return append([]string{"yarn", "exec"}, cmd...)
+}
+
+// yarnCacheDir returns the absolute path of the Yarn cache folder of the project.
+func yarnCacheDir(ctx *gcp.Context) (string, error) {
+	dir := os.Getenv("YARN_CACHE_FOLDER")
+	if dir == "" {
+		rc, err := readYarnrc(ctx)
+		if err != nil {
+			return "", err
+		}
+		dir = rc.CacheFolder
+	}
+	if dir == "" {
+		dir = defaultYarnCacheFolder
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(ctx.ApplicationRoot(), dir)
+	}
+	return dir, nil
+}
+
+// WithYarnCache calls install with the Yarn cache of the previous build restored and persists the
+// cache in a dedicated layer afterwards. Nothing is cached if the cache is committed with the
+// source, as with zero-installs.
+func WithYarnCache(ctx *gcp.Context, install func() error) error {
+	dir, err := yarnCacheDir(ctx)
+	if err != nil {
+		return err
+	}
+	committed, err := ctx.FileExists(dir)
+	if err != nil {
+		return err
+	}
+	if committed {
+		ctx.Debugf("Using the Yarn cache committed in %s", dir)
+		return install()
+	}
+	l, err := ctx.Layer(yarnCacheLayer, gcp.CacheLayer)
+	if err != nil {
+		return gcp.InternalErrorf("creating layer: %w", err)
+	}
+	return withYarnCacheLayer(ctx, l, dir, install)
+}
+
+func withYarnCacheLayer(ctx *gcp.Context, l *libcnb.Layer, dir string, install func() error) error {
+	// The archives in the cache depend on the cache key of the lock file, which changes with the
+	// Yarn version that wrote them.
+	var lock yarn2Lock
+	if raw, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), YarnLock)); err == nil {
+		if err := yaml.Unmarshal(raw, &lock); err != nil {
+			ctx.Debugf("Parsing %s: %v", YarnLock, err)
+		}
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, yarnCacheKey, cache.WithStrings(lock.Metadata.CacheKey))
+	if err != nil {
+		return err
+	}
+	layerCache := filepath.Join(l.Path, "cache")
+	if cached {
+		exists, err := ctx.FileExists(layerCache)
+		if err != nil {
+			return err
+		}
+		if exists {
+			ctx.Logf("Restoring the Yarn cache from the previous build.")
+			if err := copyDir(ctx, layerCache, dir); err != nil {
+				return err
+			}
+		}
+	} else if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+
+	if err := install(); err != nil {
+		return err
+	}
+
+	exists, err := ctx.FileExists(dir)
+	if err != nil || !exists {
+		return err
+	}
+	if err := copyDir(ctx, dir, layerCache); err != nil {
+		return err
+	}
+	cache.Add(ctx, l, yarnCacheKey, hash)
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsYarnPnP(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   map[string]string
+		want  bool
+	}{
+		{
+			name: "default linker",
+			want: true,
+		},
+		{
+			name:  "pnp loader",
+			files: map[string]string{".pnp.cjs": "", ".yarnrc.yml": "nodeLinker: node-modules"},
+			want:  true,
+		},
+		{
+			name:  "pnp linker",
+			files: map[string]string{".yarnrc.yml": "nodeLinker: pnp"},
+			want:  true,
+		},
+		{
+			name:  "node-modules linker",
+			files: map[string]string{".yarnrc.yml": "nodeLinker: node-modules"},
+		},
+		{
+			name:  "pnpm linker",
+			files: map[string]string{".yarnrc.yml": "nodeLinker: pnpm"},
+		},
+		{
+			name:  "linker set in env",
+			files: map[string]string{".yarnrc.yml": "nodeLinker: pnp"},
+			env:   map[string]string{"YARN_NODE_LINKER": "node-modules"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := IsYarnPnP(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("IsYarnPnP() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("IsYarnPnP() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestYarnPnPCommand(t *testing.T) {
+	testCases := []struct {
+		cmd  []string
+		want []string
+	}{
+		{
+			cmd:  []string{"node", "build.js"},
+			want: []string{"yarn", "node", "build.js"},
+		},
+		{
+			cmd:  []string{"npx", "vite", "build"},
+			want: []string{"yarn", "exec", "npx", "vite", "build"},
+		},
+		{
+			cmd:  []string{"yarn", "run", "build"},
+			want: []string{"yarn", "run", "build"},
+		},
+	}
+	for _, tc := range testCases {
+		if diff := cmp.Diff(tc.want, YarnPnPCommand(tc.cmd)); diff != "" {
+			t.Errorf("YarnPnPCommand(%v) unexpected diff (-want +got):\n%s", tc.cmd, diff)
+		}
+	}
+}
+
+func TestYarnCacheDir(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   map[string]string
+		want  string
+	}{
+		{
+			name: "default",
+			want: ".yarn/cache",
+		},
+		{
+			name:  "cacheFolder",
+			files: map[string]string{".yarnrc.yml": "cacheFolder: ./deps"},
+			want:  "deps",
+		},
+		{
+			name:  "env",
+			files: map[string]string{".yarnrc.yml": "cacheFolder: ./deps"},
+			env:   map[string]string{"YARN_CACHE_FOLDER": "yarn-cache"},
+			want:  "yarn-cache",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := yarnCacheDir(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("yarnCacheDir() got error: %v", err)
+			}
+			if want := filepath.Join(dir, tc.want); got != want {
+				t.Errorf("yarnCacheDir() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithYarnCacheLayer(t *testing.T) {
+	lockfile := func(cacheKey string) string {
+		return "__metadata:\n  version: 8\n  cacheKey: " + cacheKey + "\n"
+	}
+	testCases := []struct {
+		name      string
+		lockfile  string
+		wantCache string
+	}{
+		{
+			name:      "unchanged",
+			lockfile:  lockfile("10c0"),
+			wantCache: "first",
+		},
+		{
+			name:     "cache key changed",
+			lockfile: lockfile("10"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &libcnb.Layer{Name: yarnCacheLayer, Path: t.TempDir(), Metadata: map[string]any{}}
+			install := func(dir, content string) func() error {
+				return func() error {
+					writeFiles(t, dir, map[string]string{".yarn/cache/next-npm-14.2.3.zip": content})
+					return nil
+				}
+			}
+
+			// Populate the cache with a first install.
+			first := t.TempDir()
+			writeFiles(t, first, map[string]string{YarnLock: lockfile("10c0")})
+			if err := withYarnCacheLayer(gcp.NewContext(gcp.WithApplicationRoot(first)), l, filepath.Join(first, ".yarn/cache"), install(first, "first")); err != nil {
+				t.Fatalf("withYarnCacheLayer() got error: %v", err)
+			}
+
+			second := t.TempDir()
+			writeFiles(t, second, map[string]string{YarnLock: tc.lockfile})
+			var gotCache string
+			err := withYarnCacheLayer(gcp.NewContext(gcp.WithApplicationRoot(second)), l, filepath.Join(second, ".yarn/cache"), func() error {
+				if raw, err := os.ReadFile(filepath.Join(second, ".yarn/cache/next-npm-14.2.3.zip")); err == nil {
+					gotCache = string(raw)
+				}
+				return install(second, "second")()
+			})
+			if err != nil {
+				t.Fatalf("withYarnCacheLayer() got error: %v", err)
+			}
+			if gotCache != tc.wantCache {
+				t.Errorf("withYarnCacheLayer() restored the cache with %q, want %q", gotCache, tc.wantCache)
+			}
+			saved, err := os.ReadFile(filepath.Join(l.Path, "cache/next-npm-14.2.3.zip"))
+			if err != nil {
+				t.Fatalf("reading saved cache: %v", err)
+			}
+			if string(saved) != "second" {
+				t.Errorf("withYarnCacheLayer() saved the cache with %q, want %q", saved, "second")
+			}
+		})
+	}
+}
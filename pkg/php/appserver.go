@@ -0,0 +1,242 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"text/template"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// ServerNginx runs the app with php-fpm behind nginx, which is the default.
+	ServerNginx = "nginx"
+	// ServerFrankenPHP runs the app under FrankenPHP, the PHP app server built on Caddy. FrankenPHP
+	// embeds its own PHP, so the extensions of the installed runtime are not available to it.
+	ServerFrankenPHP = "frankenphp"
+	// ServerRoadRunner runs the app with the RoadRunner app server, which keeps PHP workers of the
+	// installed runtime running between requests. It requires a worker script.
+	ServerRoadRunner = "roadrunner"
+
+	// CaddyfileName is the name of the FrankenPHP config.
+	CaddyfileName = "Caddyfile"
+	// RoadRunnerConfigName is the name of the RoadRunner config.
+	RoadRunnerConfigName = ".rr.yaml"
+
+	// octaneFrankenPHPWorker is the worker script published by `php artisan octane:install
+	// --server=frankenphp`, relative to the application root.
+	octaneFrankenPHPWorker = "public/frankenphp-worker.php"
+
+	appServerVersionKey = "version"
+)
+
+// AppServers are the servers the app can run under, set with the `server` composer extra or
+// app.yaml runtime_config.
+var AppServers = []string{ServerNginx, ServerFrankenPHP, ServerRoadRunner}
+
+// AppServerConfig is the config of FrankenPHP or RoadRunner.
+type AppServerConfig struct {
+	// Root is the document root.
+	Root string
+	// FrontController is the script requests which do not match a file are routed to, relative to Root.
+	FrontController string
+	// Worker is the absolute path of the script kept running to handle requests, or empty to run
+	// a new PHP request for each request.
+	Worker string
+	// Octane is true if Worker is the worker script of Laravel Octane.
+	Octane bool
+}
+
+// caddyfileTemplate is the FrankenPHP config. The route is the expanded php_server directive with
+// the front controller of the app as the fallback. Worker scripts handle the requests routed to
+// them, so the worker is also the front controller in worker mode.
+var caddyfileTemplate = template.Must(template.New("Caddyfile").Parse(`{
+	admin off
+	auto_https off
+	frankenphp {
+{{- if .Worker}}
+		worker {
+			file {{.Worker}}
+{{- if .Octane}}
+			env APP_BASE_PATH /workspace
+			env APP_PUBLIC_PATH {{.Root}}
+			env LARAVEL_OCTANE 1
+{{- end}}
+		}
+{{- end}}
+	}
+}
+
+:{$PORT:8080} {
+	root * {{.Root}}
+	encode zstd br gzip
+	route {
+		@canonicalPath {
+			file {path}/index.php
+			not path */
+		}
+		redir @canonicalPath {path}/ 308
+		@indexFiles file {
+			try_files {path} {path}/index.php /{{.FrontController}}
+			split_path .php
+		}
+		rewrite @indexFiles {http.matchers.file.relative}
+		@phpFiles path *.php
+		php @phpFiles
+		file_server
+	}
+}
+`))
+
+// roadRunnerTemplate is the RoadRunner config. Files in the document root are served by the static
+// middleware and all other requests are handled by the workers.
+var roadRunnerTemplate = template.Must(template.New(".rr.yaml").Parse(`version: "3"
+
+server:
+  command: "php {{.Worker}}"
+{{- if .Octane}}
+  env:
+    - APP_BASE_PATH: /workspace
+    - LARAVEL_OCTANE: "1"
+{{- end}}
+
+http:
+  address: "0.0.0.0:${PORT:-8080}"
+  middleware: ["static", "gzip"]
+  static:
+    dir: "{{.Root}}"
+    forbid: [".php", ".htaccess"]
+
+logs:
+  mode: production
+  output: stderr
+`))
+
+// AppServerConfigFile returns the name and content of the config of the given server.
+func AppServerConfigFile(server string, cfg AppServerConfig) (string, []byte, error) {
+	name, tmpl := CaddyfileName, caddyfileTemplate
+	if server == ServerRoadRunner {
+		if cfg.Worker == "" {
+			return "", nil, gcp.UserErrorf("the %s server requires a worker_script", ServerRoadRunner)
+		}
+		name, tmpl = RoadRunnerConfigName, roadRunnerTemplate
+	}
+	if cfg.Worker != "" && server == ServerFrankenPHP {
+		rel, err := filepath.Rel(cfg.Root, cfg.Worker)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			return "", nil, gcp.UserErrorf("the worker_script %s must be in the document root %s", cfg.Worker, cfg.Root)
+		}
+		cfg.FrontController = rel
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, cfg); err != nil {
+		return "", nil, gcp.InternalErrorf("executing %s template: %w", name, err)
+	}
+	return name, buf.Bytes(), nil
+}
+
+// DefaultWorker returns the worker script the given server runs when none is set, which is the
+// FrankenPHP worker of Laravel Octane if it is published, or an empty string.
+func DefaultWorker(ctx *gcp.Context, server string) (string, error) {
+	if server != ServerFrankenPHP {
+		return "", nil
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), octaneFrankenPHPWorker)
+	if err != nil || !exists {
+		return "", err
+	}
+	return filepath.Join(ctx.ApplicationRoot(), octaneFrankenPHPWorker), nil
+}
+
+// IsOctaneWorker returns true if worker is the FrankenPHP worker of Laravel Octane.
+func IsOctaneWorker(ctx *gcp.Context, worker string) bool {
+	return worker == filepath.Join(ctx.ApplicationRoot(), octaneFrankenPHPWorker)
+}
+
+// InstallAppServer installs the binary of FrankenPHP or RoadRunner in the given layer and returns
+// its path. The release is verified against the digest pinned in the tools manifest.
+func InstallAppServer(ctx *gcp.Context, l *libcnb.Layer, server string) (string, error) {
+	toolName, binary := "frankenphp-linux-"+frankenPHPArch(), "frankenphp"
+	if server == ServerRoadRunner {
+		toolName, binary = "roadrunner-linux-"+goruntime.GOARCH, "rr"
+	}
+	tool, err := fetch.PinnedTool(toolName)
+	if err != nil {
+		return "", err
+	}
+	binPath := filepath.Join(l.Path, "bin", binary)
+	if server == ServerFrankenPHP {
+		// Caddy writes its state to the XDG directories, which must be writable at runtime.
+		l.LaunchEnvironment.Default("XDG_CONFIG_HOME", "/tmp")
+		l.LaunchEnvironment.Default("XDG_DATA_HOME", "/tmp")
+	}
+	if ctx.GetMetadata(l, appServerVersionKey) == tool.Version {
+		ctx.CacheHit(l.Name)
+		return binPath, nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return "", fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	if err := ctx.MkdirAll(filepath.Dir(binPath), 0755); err != nil {
+		return "", err
+	}
+	ctx.Logf("Installing %s v%s", server, tool.Version)
+	if server == ServerRoadRunner {
+		archive := filepath.Join(l.Path, "roadrunner.tar.gz")
+		if err := fetch.VerifiedFile(tool, archive); err != nil {
+			return "", err
+		}
+		if _, err := ctx.Exec([]string{"tar", "-xzf", archive, "-C", filepath.Dir(binPath), "--strip-components=1"}); err != nil {
+			return "", gcp.InternalErrorf("extracting %s: %w", tool.URL, err)
+		}
+		if err := os.Remove(archive); err != nil {
+			return "", err
+		}
+	} else {
+		if err := fetch.VerifiedFile(tool, binPath); err != nil {
+			return "", err
+		}
+		if err := os.Chmod(binPath, 0755); err != nil {
+			return "", gcp.InternalErrorf("making %s executable: %w", binPath, err)
+		}
+	}
+	ctx.SetMetadata(l, appServerVersionKey, tool.Version)
+	return binPath, nil
+}
+
+// AppServerCommand returns the command which runs the given server with the config at confPath.
+func AppServerCommand(server, binPath, confPath string) []string {
+	if server == ServerRoadRunner {
+		return []string{binPath, "serve", "-c", confPath, "-w", "/workspace"}
+	}
+	return []string{binPath, "run", "--config", confPath, "--adapter", "caddyfile"}
+}
+
+// frankenPHPArch returns the architecture in the names of the FrankenPHP release binaries.
+func frankenPHPArch() string {
+	if goruntime.GOARCH == "arm64" {
+		return "aarch64"
+	}
+	return "x86_64"
+}
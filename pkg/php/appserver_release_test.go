@@ -0,0 +1,47 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+)
+
+// TestAppServerReleases downloads the pinned release of every app server and verifies it against
+// its digest in the tools manifest. It needs network access and is skipped with -short.
+func TestAppServerReleases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("downloading the app server releases requires network access")
+	}
+	for _, name := range []string{
+		"frankenphp-linux-x86_64",
+		"frankenphp-linux-aarch64",
+		"roadrunner-linux-amd64",
+		"roadrunner-linux-arm64",
+	} {
+		t.Run(name, func(t *testing.T) {
+			tool, err := fetch.PinnedTool(name)
+			if err != nil {
+				t.Fatalf("PinnedTool(%q) got error: %v", name, err)
+			}
+			outPath := filepath.Join(t.TempDir(), name)
+			if err := fetch.VerifiedFile(tool, outPath); err != nil {
+				t.Errorf("VerifiedFile(%+v, %q) got error: %v", tool, outPath, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestAppServersPinned(t *testing.T) {
+	for _, name := range []string{
+		"frankenphp-linux-x86_64",
+		"frankenphp-linux-aarch64",
+		"roadrunner-linux-amd64",
+		"roadrunner-linux-arm64",
+	} {
+		if _, err := fetch.PinnedTool(name); err != nil {
+			t.Errorf("PinnedTool(%q) got error: %v", name, err)
+		}
+	}
+}
+
+func TestAppServerConfigFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		server   string
+		cfg      AppServerConfig
+		wantName string
+		want     []string
+		dontWant []string
+		wantErr  bool
+	}{
+		{
+			name:     "frankenphp",
+			server:   ServerFrankenPHP,
+			cfg:      AppServerConfig{Root: "/workspace/public", FrontController: "index.php"},
+			wantName: CaddyfileName,
+			want: []string{
+				"root * /workspace/public",
+				"try_files {path} {path}/index.php /index.php",
+				"php @phpFiles",
+			},
+			dontWant: []string{"worker"},
+		},
+		{
+			name:     "frankenphp worker mode",
+			server:   ServerFrankenPHP,
+			cfg:      AppServerConfig{Root: "/workspace/public", FrontController: "index.php", Worker: "/workspace/public/worker.php"},
+			wantName: CaddyfileName,
+			want: []string{
+				"file /workspace/public/worker.php",
+				"try_files {path} {path}/index.php /worker.php",
+			},
+			dontWant: []string{"LARAVEL_OCTANE"},
+		},
+		{
+			name:     "frankenphp laravel octane",
+			server:   ServerFrankenPHP,
+			cfg:      AppServerConfig{Root: "/workspace/public", Worker: "/workspace/public/frankenphp-worker.php", Octane: true},
+			wantName: CaddyfileName,
+			want: []string{
+				"env APP_PUBLIC_PATH /workspace/public",
+				"env LARAVEL_OCTANE 1",
+			},
+		},
+		{
+			name:    "frankenphp worker outside the document root",
+			server:  ServerFrankenPHP,
+			cfg:     AppServerConfig{Root: "/workspace/public", Worker: "/workspace/worker.php"},
+			wantErr: true,
+		},
+		{
+			name:     "roadrunner",
+			server:   ServerRoadRunner,
+			cfg:      AppServerConfig{Root: "/workspace/public", Worker: "/workspace/worker.php"},
+			wantName: RoadRunnerConfigName,
+			want: []string{
+				`command: "php /workspace/worker.php"`,
+				`dir: "/workspace/public"`,
+				`address: "0.0.0.0:${PORT:-8080}"`,
+			},
+		},
+		{
+			name:    "roadrunner without worker",
+			server:  ServerRoadRunner,
+			cfg:     AppServerConfig{Root: "/workspace"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, content, err := AppServerConfigFile(tc.server, tc.cfg)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("AppServerConfigFile(%q) got no error, want error", tc.server)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AppServerConfigFile(%q) got error: %v", tc.server, err)
+			}
+			if name != tc.wantName {
+				t.Errorf("AppServerConfigFile(%q) name = %q, want %q", tc.server, name, tc.wantName)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(string(content), w) {
+					t.Errorf("AppServerConfigFile(%q) content does not contain %q:\n%s", tc.server, w, content)
+				}
+			}
+			for _, w := range tc.dontWant {
+				if strings.Contains(string(content), w) {
+					t.Errorf("AppServerConfigFile(%q) content contains %q:\n%s", tc.server, w, content)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultWorker(t *testing.T) {
+	testCases := []struct {
+		name   string
+		server string
+		files  []string
+		want   string
+	}{
+		{
+			name:   "no worker",
+			server: ServerFrankenPHP,
+		},
+		{
+			name:   "laravel octane",
+			server: ServerFrankenPHP,
+			files:  []string{"public/frankenphp-worker.php"},
+			want:   "public/frankenphp-worker.php",
+		},
+		{
+			name:   "roadrunner",
+			server: ServerRoadRunner,
+			files:  []string{"public/frankenphp-worker.php"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := DefaultWorker(ctx, tc.server)
+			if err != nil {
+				t.Fatalf("DefaultWorker(%q) got error: %v", tc.server, err)
+			}
+			want := tc.want
+			if want != "" {
+				want = filepath.Join(dir, want)
+			}
+			if got != want {
+				t.Errorf("DefaultWorker(%q) = %q, want %q", tc.server, got, want)
+			}
+			if want != "" && !IsOctaneWorker(ctx, got) {
+				t.Errorf("IsOctaneWorker(%q) = false, want true", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+)
+
+// ComposerExtraKey is the key of the buildpacks configuration in the composer.json "extra" section.
+const ComposerExtraKey = "google-buildpacks"
+
+// ComposerExtra is the buildpacks configuration in the "google-buildpacks" section of the
+// composer.json "extra" object. Values take precedence over the app.yaml runtime_config.
+//
+// Example:
+//
+//	"extra": {
+//	  "google-buildpacks": {
+//	    "document_root": "public"
+//	  }
+//	}
+type ComposerExtra struct {
+	DocumentRoot         string `json:"document_root"`
+	FrontControllerFile  string `json:"front_controller_file"`
+	NginxConfOverride    string `json:"nginx_conf_override"`
+	NginxConfInclude     string `json:"nginx_conf_include"`
+	NginxConfHTTPInclude string `json:"nginx_conf_http_include"`
+	PHPFPMConfOverride   string `json:"php_fpm_conf_override"`
+	PHPIniOverride       string `json:"php_ini_override"`
+	// PHPFPMSlowlogTimeout is the request_slowlog_timeout of php-fpm, such as `5s`.
+	PHPFPMSlowlogTimeout string `json:"php_fpm_slowlog_timeout"`
+	// PHPFPMSlowlog is the file the stack traces of slow requests are written to, stderr by default.
+	PHPFPMSlowlog string `json:"php_fpm_slowlog"`
+	// PHPFPMSlowlogTraceDepth is the request_slowlog_trace_depth of php-fpm.
+	PHPFPMSlowlogTraceDepth string `json:"php_fpm_slowlog_trace_depth"`
+	// PHPFPMWorkers is the pm.max_children of php-fpm, sized to the container at launch by default.
+	PHPFPMWorkers string `json:"php_fpm_workers"`
+	// PHPFPMWorkerMemory is the estimated memory of a php-fpm worker in MiB, which the workers are
+	// sized with when PHPFPMWorkers is unset.
+	PHPFPMWorkerMemory string `json:"php_fpm_worker_memory"`
+	// Server is the server the app runs under, nginx with php-fpm by default. See AppServers.
+	Server string `json:"server"`
+	// WorkerScript is the script the server keeps running to handle requests in worker mode.
+	WorkerScript string `json:"worker_script"`
+	// Routes are URL path prefixes handled by nginx location blocks of their own.
+	Routes []nginx.Route `json:"routes"`
+	// Gzip enables the gzip compression of text responses by nginx.
+	Gzip bool `json:"gzip"`
+	// Brotli enables the brotli compression of text responses by nginx, installing its module.
+	Brotli bool `json:"brotli"`
+	// HTTP2 serves cleartext HTTP/2 (h2c), for Cloud Run services with end-to-end HTTP/2.
+	HTTP2 bool `json:"http2"`
+	// KeepaliveTimeout is the keepalive_timeout of nginx, such as `75s`.
+	KeepaliveTimeout string `json:"keepalive_timeout"`
+	// KeepaliveRequests is the keepalive_requests of nginx.
+	KeepaliveRequests string `json:"keepalive_requests"`
+	// TLSCertificate is the path of the PEM certificate nginx terminates TLS with, relative to the
+	// application root unless it is absolute, such as the mount path of a secret.
+	TLSCertificate string `json:"tls_certificate"`
+	// TLSCertificateKey is the path of the PEM key of TLSCertificate.
+	TLSCertificateKey string `json:"tls_certificate_key"`
+	// TLSPort is the port nginx terminates TLS on, 8443 by default.
+	TLSPort string `json:"tls_port"`
+	// OPcachePreload is the path of the opcache.preload script, relative to the application root,
+	// or `classmap` to generate it from the Composer classmap.
+	OPcachePreload string `json:"opcache_preload"`
+	// WordPressUploadsDir is the absolute path of a mounted volume wp-content/uploads of WordPress
+	// applications is linked to, so that uploads persist across instances.
+	WordPressUploadsDir string `json:"wordpress_uploads_dir"`
+	// PHPIni are php.ini directives which take precedence over the php.ini of the runtime.
+	PHPIni IniDirectives `json:"php_ini"`
+	// DevMode installs Xdebug, shows the errors in the responses and revalidates the OPcache on
+	// every request. It is meant for preview and staging deployments, not for production.
+	DevMode bool `json:"dev_mode"`
+	// Extensions are PHP extensions the application requires in addition to the `ext-*` packages
+	// of composer.json, which are installed during the build if the runtime does not load them.
+	Extensions []string `json:"extensions"`
+}
+
+// ReadComposerExtra returns the google-buildpacks composer extra of the application along with
+// any keys in it which are not recognized, in sorted order. An empty ComposerExtra is returned if
+// composer.json or the section does not exist.
+func ReadComposerExtra(ctx *gcp.Context) (ComposerExtra, []string, error) {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), composerJSON)
+	if err != nil || !exists {
+		return ComposerExtra{}, nil, err
+	}
+	raw, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), composerJSON))
+	if err != nil {
+		return ComposerExtra{}, nil, err
+	}
+	var cjs struct {
+		Extra map[string]json.RawMessage `json:"extra"`
+	}
+	if err := json.Unmarshal(raw, &cjs); err != nil {
+		return ComposerExtra{}, nil, gcp.UserErrorf("unmarshalling %s: %v", composerJSON, err)
+	}
+	section, ok := cjs.Extra[ComposerExtraKey]
+	if !ok {
+		return ComposerExtra{}, nil, nil
+	}
+
+	var extra ComposerExtra
+	if err := json.Unmarshal(section, &extra); err != nil {
+		return ComposerExtra{}, nil, gcp.UserErrorf("unmarshalling %q extra in %s: %v", ComposerExtraKey, composerJSON, err)
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(section, &keys); err != nil {
+		return ComposerExtra{}, nil, gcp.UserErrorf("unmarshalling %q extra in %s: %v", ComposerExtraKey, composerJSON, err)
+	}
+	known := map[string]bool{}
+	for _, k := range ComposerExtraKeys() {
+		known[k] = true
+	}
+	var unknown []string
+	for k := range keys {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return extra, unknown, nil
+}
+
+// ComposerExtraKeys returns the keys recognized in the google-buildpacks composer extra.
+func ComposerExtraKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(ComposerExtra{})
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("json"))
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadComposerExtra(t *testing.T) {
+	testCases := []struct {
+		name         string
+		composerJSON string
+		want         ComposerExtra
+		wantUnknown  []string
+		wantErr      bool
+	}{
+		{
+			name: "no composer.json",
+		},
+		{
+			name:         "no extra",
+			composerJSON: `{"require": {"php": "8.3.*"}}`,
+		},
+		{
+			name:         "other extra",
+			composerJSON: `{"extra": {"laravel": {"dont-discover": []}}}`,
+		},
+		{
+			name:         "google-buildpacks extra",
+			composerJSON: `{"extra": {"google-buildpacks": {"document_root": "public", "front_controller_file": "app.php"}}}`,
+			want:         ComposerExtra{DocumentRoot: "public", FrontControllerFile: "app.php"},
+		},
+		{
+			name:         "unknown keys",
+			composerJSON: `{"extra": {"google-buildpacks": {"docment_root": "public", "nginx_conf_include": "nginx-app.conf", "a": 1}}}`,
+			want:         ComposerExtra{NginxConfInclude: "nginx-app.conf"},
+			wantUnknown:  []string{"a", "docment_root"},
+		},
+		{
+			name:         "routes",
+			composerJSON: `{"extra": {"google-buildpacks": {"routes": [{"path": "/build", "type": "static", "headers": {"Cache-Control": "immutable"}}, {"path": "/socket", "type": "proxy", "target": "http://127.0.0.1:6001"}]}}}`,
+			want: ComposerExtra{Routes: []nginx.Route{
+				{Path: "/build", Type: nginx.RouteStatic, Headers: map[string]string{"Cache-Control": "immutable"}},
+				{Path: "/socket", Type: nginx.RouteProxy, Target: "http://127.0.0.1:6001"},
+			}},
+		},
+		{
+			name:         "invalid value",
+			composerJSON: `{"extra": {"google-buildpacks": {"document_root": 1}}}`,
+			wantErr:      true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.composerJSON != "" {
+				if err := os.WriteFile(filepath.Join(dir, composerJSON), []byte(tc.composerJSON), 0644); err != nil {
+					t.Fatalf("writing composer.json: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, gotUnknown, err := ReadComposerExtra(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ReadComposerExtra() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ReadComposerExtra() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantUnknown, gotUnknown); diff != "" {
+				t.Errorf("ReadComposerExtra() unknown keys mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// extensionPackagePrefix is the prefix of the platform packages composer.json requires PHP
+	// extensions with, such as `ext-intl`.
+	extensionPackagePrefix = "ext-"
+	// extensionsPHPVersionKey is the metadata key of the PHP version the extensions in the layer
+	// were built for.
+	extensionsPHPVersionKey = "php_version"
+	// peclExtDir is the directory of the layer the extensions built from PECL are installed to.
+	peclExtDir = "ext"
+)
+
+var (
+	// extensionModules are the names of the shared modules of extensions which Composer names
+	// differently.
+	extensionModules = map[string]string{"zend-opcache": "opcache"}
+	// zendExtensions are the modules which are loaded with zend_extension rather than extension.
+	zendExtensions = map[string]bool{"opcache": true, "xdebug": true}
+)
+
+// RequiredExtensions returns the sorted names of the PHP extensions the application requires, as
+// `ext-*` packages in the require section of its composer.json or in the `extensions` composer
+// extra. The names are lower case, with spaces replaced by dashes as in the Composer packages.
+func RequiredExtensions(ctx *gcp.Context) ([]string, error) {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), composerJSON)
+	if err != nil || !exists {
+		return nil, err
+	}
+	cjs, err := ReadComposerJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	extra, _, err := ReadComposerExtra(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for pkg := range cjs.Require {
+		if name, ok := strings.CutPrefix(strings.ToLower(pkg), extensionPackagePrefix); ok {
+			names[name] = true
+		}
+	}
+	for _, name := range extra.Extensions {
+		names[extensionName(strings.TrimPrefix(name, extensionPackagePrefix))] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// InstallExtensions installs the extensions which PHP does not load into l and returns the ini
+// directives which load them. The shared modules of the runtime are loaded as they are, the other
+// extensions are built from PECL and cached in l for the PHP version. The extensions are checked to
+// load with the directives written to iniPath, which fails the build rather than the application
+// when one cannot be installed.
+func InstallExtensions(ctx *gcp.Context, l *libcnb.Layer, names []string, iniPath string) (string, error) {
+	info, err := phpExtensionInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	if ctx.GetMetadata(l, extensionsPHPVersionKey) != info.version {
+		if err := ctx.ClearLayer(l); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+	}
+	var sb strings.Builder
+	for _, name := range names {
+		if info.loaded[name] {
+			continue
+		}
+		module := extensionModule(name)
+		directive := "extension"
+		if zendExtensions[module] {
+			directive = "zend_extension"
+		}
+		shared, err := ctx.FileExists(info.extensionDir, module+".so")
+		if err != nil {
+			return "", err
+		}
+		if shared {
+			ctx.Logf("Enabling the %s extension of the PHP runtime", name)
+			fmt.Fprintf(&sb, "%s = %s\n", directive, module)
+			continue
+		}
+		path, err := peclInstall(ctx, l, module)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s = %s\n", directive, path)
+	}
+	ctx.SetMetadata(l, extensionsPHPVersionKey, info.version)
+	if sb.Len() == 0 {
+		return "", ctx.RemoveAll(iniPath)
+	}
+	content := "; Generated by the PHP buildpack.\n" + sb.String()
+	if err := ctx.MkdirAll(filepath.Dir(iniPath), 0755); err != nil {
+		return "", err
+	}
+	if err := ctx.WriteFile(iniPath, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	loaded, err := phpExtensionInfo(ctx, gcp.WithEnv(IniScanDirEnv+"="+string(filepath.ListSeparator)+filepath.Dir(iniPath)))
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if !loaded.loaded[name] {
+			return "", gcp.UserErrorf("PHP extension %s does not load after installing it, check the build log for the startup errors of PHP", name)
+		}
+	}
+	return content, nil
+}
+
+// peclInstall builds the extension module from PECL into the layer, unless it is cached, and
+// returns the path of its shared module.
+func peclInstall(ctx *gcp.Context, l *libcnb.Layer, module string) (string, error) {
+	dir := filepath.Join(l.Path, peclExtDir)
+	path := filepath.Join(dir, module+".so")
+	exists, err := ctx.FileExists(path)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		ctx.CacheHit(l.Name + ":" + module)
+		return path, nil
+	}
+	ctx.CacheMiss(l.Name + ":" + module)
+	if err := ctx.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	ctx.Logf("Installing the %s extension from PECL", module)
+	if _, err := ctx.Exec([]string{"pecl", "-d", "ext_dir=" + dir, "install", module}, gcp.WithUserAttribution, gcp.WithMessageProducer(func(r *gcp.ExecResult) string {
+		return fmt.Sprintf("PHP extension %s is not available in the PHP runtime and could not be installed from PECL: %s", module, strings.TrimSpace(r.Combined))
+	})); err != nil {
+		return "", err
+	}
+	if exists, err := ctx.FileExists(path); err != nil || !exists {
+		if err == nil {
+			err = gcp.UserErrorf("installing PHP extension %s from PECL did not produce %s", module, path)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// extensionInfo is the PHP version, extension directory and loaded extensions of the PHP runtime.
+type extensionInfo struct {
+	version      string
+	extensionDir string
+	loaded       map[string]bool
+}
+
+// phpExtensionInfo returns the extensionInfo of the PHP runtime, running php with opts.
+func phpExtensionInfo(ctx *gcp.Context, opts ...gcp.ExecOption) (extensionInfo, error) {
+	script := `echo PHP_VERSION, "\n", ini_get("extension_dir"), "\n", implode("\n", array_merge(get_loaded_extensions(), get_loaded_extensions(true)));`
+	result, err := ctx.Exec([]string{"php", "-r", script}, opts...)
+	if err != nil {
+		return extensionInfo{}, err
+	}
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	if len(lines) < 2 {
+		return extensionInfo{}, gcp.InternalErrorf("unexpected output of php: %q", result.Stdout)
+	}
+	info := extensionInfo{version: lines[0], extensionDir: lines[1], loaded: map[string]bool{}}
+	for _, ext := range lines[2:] {
+		name := extensionName(ext)
+		info.loaded[name] = true
+		info.loaded[extensionModule(name)] = true
+	}
+	return info, nil
+}
+
+// extensionName returns the Composer name of the extension named ext by PHP, such as
+// `zend-opcache` for `Zend OPcache`.
+func extensionName(ext string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(ext)), " ", "-")
+}
+
+// extensionModule returns the name of the shared module of the extension with the Composer name.
+func extensionModule(name string) string {
+	if module, ok := extensionModules[name]; ok {
+		return module
+	}
+	return name
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequiredExtensions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		composer string
+		want     []string
+	}{
+		{
+			name: "require and extra",
+			composer: `{
+				"require": {"php": "^8.2", "ext-intl": "*", "ext-PDO_PGSQL": "*", "guzzlehttp/guzzle": "^7.0"},
+				"extra": {"google-buildpacks": {"extensions": ["redis", "ext-gd", "Zend OPcache", "intl"]}}
+			}`,
+			want: []string{"gd", "intl", "pdo_pgsql", "redis", "zend-opcache"},
+		},
+		{
+			name:     "no extensions",
+			composer: `{"require": {"php": "^8.2"}}`,
+		},
+		{
+			name: "no composer.json",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.composer != "" {
+				if err := os.WriteFile(filepath.Join(dir, composerJSON), []byte(tc.composer), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := RequiredExtensions(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("RequiredExtensions() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("RequiredExtensions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakePHP prints the PHP version, the extension directory and the loaded extensions, including the
+// modules loaded by the ini files of PHP_INI_SCAN_DIR.
+const fakePHP = `#!/bin/sh
+echo 8.3.0
+echo "$EXT_DIR"
+echo Core
+echo PDO
+echo "Zend OPcache"
+if [ -n "$PHP_INI_SCAN_DIR" ]; then
+	cat "${PHP_INI_SCAN_DIR#:}"/*.ini | sed -n 's/^[a-z_]* = //p' | while read m; do basename "$m" .so; done
+fi
+`
+
+// fakePECL builds the module named by its last argument into the ext_dir of its -d argument,
+// unless the module is named broken.
+const fakePECL = `#!/bin/sh
+echo "$*" >> "$PECL_LOG"
+for m; do :; done
+[ "$m" = broken ] && exit 1
+touch "${2#ext_dir=}/$m.so"
+`
+
+func TestInstallExtensions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		names     []string
+		shared    []string
+		cached    []string
+		version   string
+		want      []string
+		wantPECL  []string
+		wantErr   bool
+		wantNoIni bool
+	}{
+		{
+			name:      "loaded",
+			names:     []string{"pdo", "zend-opcache"},
+			wantNoIni: true,
+		},
+		{
+			name:   "shared module",
+			names:  []string{"gd", "pdo"},
+			shared: []string{"gd"},
+			want:   []string{"extension = gd"},
+		},
+		{
+			name:     "pecl",
+			names:    []string{"redis", "xdebug"},
+			want:     []string{"extension = LAYER/ext/redis.so", "zend_extension = LAYER/ext/xdebug.so"},
+			wantPECL: []string{"-d ext_dir=LAYER/ext install redis", "-d ext_dir=LAYER/ext install xdebug"},
+		},
+		{
+			name:    "cached",
+			names:   []string{"redis"},
+			cached:  []string{"redis"},
+			version: "8.3.0",
+			want:    []string{"extension = LAYER/ext/redis.so"},
+		},
+		{
+			name:     "cached for another php version",
+			names:    []string{"redis"},
+			cached:   []string{"redis"},
+			version:  "8.2.0",
+			want:     []string{"extension = LAYER/ext/redis.so"},
+			wantPECL: []string{"-d ext_dir=LAYER/ext install redis"},
+		},
+		{
+			name:     "pecl failure",
+			names:    []string{"broken"},
+			wantPECL: []string{"-d ext_dir=LAYER/ext install broken"},
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bin := t.TempDir()
+			for name, script := range map[string]string{"php": fakePHP, "pecl": fakePECL} {
+				if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			extDir := t.TempDir()
+			for _, m := range tc.shared {
+				if err := os.WriteFile(filepath.Join(extDir, m+".so"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			l := &libcnb.Layer{Name: "php-extensions", Path: t.TempDir(), Metadata: map[string]interface{}{}}
+			if tc.version != "" {
+				l.Metadata[extensionsPHPVersionKey] = tc.version
+			}
+			for _, m := range tc.cached {
+				path := filepath.Join(l.Path, peclExtDir, m+".so")
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			peclLog := filepath.Join(t.TempDir(), "pecl.log")
+			t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+			t.Setenv("EXT_DIR", extDir)
+			t.Setenv("PECL_LOG", peclLog)
+			t.Setenv(IniScanDirEnv, "")
+			iniPath := filepath.Join(l.Path, "php.d", "extensions.ini")
+
+			got, err := InstallExtensions(gcp.NewContext(), l, tc.names, iniPath)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("InstallExtensions() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			var pecl []string
+			if out, err := os.ReadFile(peclLog); err == nil {
+				pecl = strings.Split(strings.ReplaceAll(strings.TrimSpace(string(out)), l.Path, "LAYER"), "\n")
+			}
+			if diff := cmp.Diff(tc.wantPECL, pecl); diff != "" {
+				t.Errorf("InstallExtensions() pecl commands mismatch (-want +got):\n%s", diff)
+			}
+			if tc.wantErr {
+				return
+			}
+			for _, want := range tc.want {
+				if want = strings.ReplaceAll(want, "LAYER", l.Path); !strings.Contains(got, want) {
+					t.Errorf("InstallExtensions() = %q, want it to contain %q", got, want)
+				}
+			}
+			ini, err := os.ReadFile(iniPath)
+			if tc.wantNoIni {
+				if got != "" || err == nil {
+					t.Errorf("InstallExtensions() = %q and wrote %s, want no directives", got, iniPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reading %s: %v", iniPath, err)
+			}
+			if string(ini) != got {
+				t.Errorf("InstallExtensions() wrote %q to %s, want %q", ini, iniPath, got)
+			}
+			if v := l.Metadata[extensionsPHPVersionKey]; v != "8.3.0" {
+				t.Errorf("InstallExtensions() set the php version metadata to %v, want 8.3.0", v)
+			}
+		})
+	}
+}
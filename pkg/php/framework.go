@@ -0,0 +1,219 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// LaravelOptimizeEnv disables the artisan caches of Laravel applications built with composer
+	// when set to false.
+	LaravelOptimizeEnv = "GOOGLE_LARAVEL_OPTIMIZE"
+	// SymfonyAssetsInstallEnv enables `bin/console assets:install` for Symfony applications built
+	// with composer when set to true.
+	SymfonyAssetsInstallEnv = "GOOGLE_SYMFONY_ASSETS_INSTALL"
+
+	// laravelPublicStorage is the link `artisan storage:link` creates to storage/app/public.
+	laravelPublicStorage = "public/storage"
+	// symfonyConsole is the console of Symfony applications.
+	symfonyConsole = "bin/console"
+	// symfonyFlex is the Composer plugin which provides `composer dump-env`.
+	symfonyFlex = "symfony/flex"
+	// symfonyDefaultEnv is the APP_ENV Symfony applications are built for unless it is set.
+	symfonyDefaultEnv = "prod"
+)
+
+// Framework is a PHP framework the buildpacks adapt the build and web server config to.
+type Framework struct {
+	// Name is the display name of the framework.
+	Name string
+	// Package is the Composer package which identifies applications of the framework.
+	Package string
+	// Files are the files or directories, relative to the application root, any of which
+	// identifies applications of the framework which are not installed with Composer.
+	Files []string
+	// DocumentRoot is the document root of the applications, relative to the application root, or
+	// empty to serve the application root.
+	DocumentRoot string
+}
+
+var (
+	// Laravel is the Laravel framework.
+	Laravel = Framework{Name: "Laravel", Package: "laravel/framework", DocumentRoot: "public"}
+	// Symfony is the Symfony framework.
+	Symfony = Framework{Name: "Symfony", Package: "symfony/framework-bundle", DocumentRoot: "public"}
+	// WordPress is WordPress, which is usually deployed with its files rather than with Composer.
+	WordPress = Framework{Name: "WordPress", Files: []string{"wp-config.php", "wp-includes"}}
+
+	// frameworks are detected in order.
+	frameworks = []Framework{Laravel, Symfony, WordPress}
+
+	// laravelCacheCommands are the artisan commands which cache the config, routes, views and
+	// events of a Laravel application.
+	laravelCacheCommands = []string{"config:cache", "route:cache", "view:cache", "event:cache"}
+)
+
+// composerLockJSON is the part of composer.lock which lists the installed packages.
+type composerLockJSON struct {
+	Packages []struct {
+		Name string `json:"name"`
+	} `json:"packages"`
+}
+
+// DetectFramework returns the framework of the application, as found in the packages of its
+// composer.lock or in its files, or nil if the application uses no known framework.
+func DetectFramework(ctx *gcp.Context) (*Framework, error) {
+	packages, err := lockedPackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range frameworks {
+		if f.Package != "" && packages[f.Package] {
+			return &f, nil
+		}
+		for _, file := range f.Files {
+			exists, err := ctx.FileExists(ctx.ApplicationRoot(), file)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				return &f, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// lockedPackages returns the names of the packages installed from the composer.lock of the
+// application, excluding the dev packages.
+func lockedPackages(ctx *gcp.Context) (map[string]bool, error) {
+	content, err := os.ReadFile(filepath.Join(ctx.ApplicationRoot(), composerLock))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", composerLock, err)
+	}
+	var lock composerLockJSON
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return nil, gcp.UserErrorf("unmarshalling %s: %v", composerLock, err)
+	}
+	packages := map[string]bool{}
+	for _, p := range lock.Packages {
+		packages[p.Name] = true
+	}
+	return packages, nil
+}
+
+// boolSetting returns the value of the boolean env var name, or def if it is not set, and records
+// it as the setting.
+func boolSetting(ctx *gcp.Context, name, setting string, def bool) (bool, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		ctx.RecordSetting(setting, strconv.FormatBool(def), gcp.SourceDefault)
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, gcp.UserErrorf("invalid %s %q, it must be true or false", name, v)
+	}
+	ctx.RecordSetting(setting, strconv.FormatBool(b), gcp.SourceEnv)
+	return b, nil
+}
+
+// LaravelOptimize caches the config, routes, views and events of a Laravel application with
+// artisan and links the public storage directory, unless disabled with LaravelOptimizeEnv. The
+// config is cached with the env vars of the build, so applications which read env vars only set at
+// launch in their config files must disable it.
+func LaravelOptimize(ctx *gcp.Context) error {
+	enabled, err := boolSetting(ctx, LaravelOptimizeEnv, "laravel optimize", true)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		ctx.Logf("Skipping the Laravel caches, %s is false.", LaravelOptimizeEnv)
+		return nil
+	}
+	artisan, err := ctx.FileExists(ctx.ApplicationRoot(), "artisan")
+	if err != nil || !artisan {
+		return err
+	}
+	ctx.Logf("Caching the Laravel config, routes, views and events, set %s=false to disable.", LaravelOptimizeEnv)
+	for _, c := range laravelCacheCommands {
+		if _, err := ctx.Exec([]string{"php", "artisan", c}, gcp.WithWorkDir(ctx.ApplicationRoot()), gcp.WithUserAttribution); err != nil {
+			return fmt.Errorf("php artisan %s: %w", c, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(ctx.ApplicationRoot(), laravelPublicStorage)); err == nil {
+		return nil
+	}
+	if _, err := ctx.Exec([]string{"php", "artisan", "storage:link"}, gcp.WithWorkDir(ctx.ApplicationRoot()), gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("php artisan storage:link: %w", err)
+	}
+	return nil
+}
+
+// SymfonyBuild dumps the .env files of a Symfony application into .env.local.php with
+// `composer dump-env` if Symfony Flex is installed, and warms up its cache with `bin/console`, for
+// the APP_ENV of the build or prod. The public assets of the bundles are installed too if enabled
+// with SymfonyAssetsInstallEnv, which Symfony Flex usually does when the dependencies are installed.
+func SymfonyBuild(ctx *gcp.Context) error {
+	assets, err := boolSetting(ctx, SymfonyAssetsInstallEnv, "symfony assets install", false)
+	if err != nil {
+		return err
+	}
+	appEnv := os.Getenv("APP_ENV")
+	if appEnv == "" {
+		appEnv = symfonyDefaultEnv
+	}
+	packages, err := lockedPackages(ctx)
+	if err != nil {
+		return err
+	}
+	opts := []gcp.ExecOption{
+		gcp.WithWorkDir(ctx.ApplicationRoot()),
+		gcp.WithEnv("APP_ENV=" + appEnv),
+		gcp.WithUserAttribution,
+	}
+	if packages[symfonyFlex] {
+		if _, err := ctx.Exec([]string{"composer", "dump-env", appEnv}, opts...); err != nil {
+			return fmt.Errorf("composer dump-env: %w", err)
+		}
+	} else {
+		ctx.Logf("Not dumping the .env files, %s is not installed.", symfonyFlex)
+	}
+	console, err := ctx.FileExists(ctx.ApplicationRoot(), symfonyConsole)
+	if err != nil || !console {
+		return err
+	}
+	ctx.Logf("Warming up the Symfony cache for APP_ENV=%s.", appEnv)
+	if _, err := ctx.Exec([]string{"php", symfonyConsole, "cache:warmup", "--no-debug"}, opts...); err != nil {
+		return fmt.Errorf("%s cache:warmup: %w", symfonyConsole, err)
+	}
+	if !assets {
+		return nil
+	}
+	if _, err := ctx.Exec([]string{"php", symfonyConsole, "assets:install", "public", "--no-debug"}, opts...); err != nil {
+		return fmt.Errorf("%s assets:install: %w", symfonyConsole, err)
+	}
+	return nil
+}
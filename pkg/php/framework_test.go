@@ -0,0 +1,258 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetectFramework(t *testing.T) {
+	testCases := []struct {
+		name  string
+		lock  string
+		files []string
+		want  *Framework
+	}{
+		{
+			name: "laravel",
+			lock: `{"packages": [{"name": "guzzlehttp/guzzle"}, {"name": "laravel/framework"}]}`,
+			want: &Laravel,
+		},
+		{
+			name: "symfony",
+			lock: `{"packages": [{"name": "symfony/flex"}, {"name": "symfony/framework-bundle"}]}`,
+			want: &Symfony,
+		},
+		{
+			name:  "wordpress config",
+			files: []string{"wp-config.php"},
+			want:  &WordPress,
+		},
+		{
+			name:  "wordpress includes",
+			lock:  `{"packages": [{"name": "guzzlehttp/guzzle"}]}`,
+			files: []string{"wp-includes/version.php"},
+			want:  &WordPress,
+		},
+		{
+			name: "laravel only in dev packages",
+			lock: `{"packages": [{"name": "guzzlehttp/guzzle"}], "packages-dev": [{"name": "laravel/framework"}]}`,
+		},
+		{
+			name: "no framework",
+			lock: `{"packages": [{"name": "guzzlehttp/guzzle"}]}`,
+		},
+		{
+			name: "no composer.lock",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.lock != "" {
+				if err := os.WriteFile(filepath.Join(dir, composerLock), []byte(tc.lock), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := DetectFramework(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("DetectFramework() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("DetectFramework() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLaravelOptimize(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		files   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "caches and links the storage",
+			files: []string{"artisan"},
+			want:  []string{"artisan config:cache", "artisan route:cache", "artisan view:cache", "artisan event:cache", "artisan storage:link"},
+		},
+		{
+			name:  "storage already linked",
+			files: []string{"artisan", "public/storage"},
+			want:  []string{"artisan config:cache", "artisan route:cache", "artisan view:cache", "artisan event:cache"},
+		},
+		{
+			name:  "disabled",
+			env:   "false",
+			files: []string{"artisan"},
+		},
+		{
+			name: "no artisan",
+		},
+		{
+			name:    "invalid env",
+			env:     "sometimes",
+			files:   []string{"artisan"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(app, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// php records the commands it runs.
+			bin := t.TempDir()
+			log := filepath.Join(t.TempDir(), "php.log")
+			if err := os.WriteFile(filepath.Join(bin, "php"), []byte("#!/bin/sh\necho \"$*\" >> "+log+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+			if tc.env != "" {
+				t.Setenv(LaravelOptimizeEnv, tc.env)
+			}
+
+			err := LaravelOptimize(gcp.NewContext(gcp.WithApplicationRoot(app)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("LaravelOptimize() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			var got []string
+			if out, err := os.ReadFile(log); err == nil {
+				got = strings.Split(strings.TrimSpace(string(out)), "\n")
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("LaravelOptimize() ran commands mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSymfonyBuild(t *testing.T) {
+	testCases := []struct {
+		name    string
+		lock    string
+		appEnv  string
+		assets  string
+		files   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "dumps env and warms up cache",
+			lock:  `{"packages": [{"name": "symfony/flex"}, {"name": "symfony/framework-bundle"}]}`,
+			files: []string{"bin/console"},
+			want:  []string{"prod composer dump-env prod", "prod php bin/console cache:warmup --no-debug"},
+		},
+		{
+			name:   "app env of the build",
+			lock:   `{"packages": [{"name": "symfony/flex"}, {"name": "symfony/framework-bundle"}]}`,
+			appEnv: "staging",
+			files:  []string{"bin/console"},
+			want:   []string{"staging composer dump-env staging", "staging php bin/console cache:warmup --no-debug"},
+		},
+		{
+			name:  "without flex",
+			lock:  `{"packages": [{"name": "symfony/framework-bundle"}]}`,
+			files: []string{"bin/console"},
+			want:  []string{"prod php bin/console cache:warmup --no-debug"},
+		},
+		{
+			name:   "installs assets",
+			lock:   `{"packages": [{"name": "symfony/framework-bundle"}]}`,
+			assets: "true",
+			files:  []string{"bin/console"},
+			want:   []string{"prod php bin/console cache:warmup --no-debug", "prod php bin/console assets:install public --no-debug"},
+		},
+		{
+			name: "no console",
+			lock: `{"packages": [{"name": "symfony/framework-bundle"}]}`,
+		},
+		{
+			name:    "invalid assets env",
+			lock:    `{"packages": [{"name": "symfony/framework-bundle"}]}`,
+			assets:  "always",
+			files:   []string{"bin/console"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := t.TempDir()
+			files := map[string]string{composerLock: tc.lock}
+			for _, f := range tc.files {
+				files[f] = ""
+			}
+			for f, content := range files {
+				path := filepath.Join(app, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// php and composer record the commands they run with the APP_ENV they see.
+			bin := t.TempDir()
+			log := filepath.Join(t.TempDir(), "commands.log")
+			for _, name := range []string{"php", "composer"} {
+				script := "#!/bin/sh\necho \"$APP_ENV " + name + " $*\" >> " + log + "\n"
+				if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+			t.Setenv("APP_ENV", tc.appEnv)
+			if tc.assets != "" {
+				t.Setenv(SymfonyAssetsInstallEnv, tc.assets)
+			}
+
+			err := SymfonyBuild(gcp.NewContext(gcp.WithApplicationRoot(app)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SymfonyBuild() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			var got []string
+			if out, err := os.ReadFile(log); err == nil {
+				got = strings.Split(strings.TrimSpace(string(out)), "\n")
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SymfonyBuild() ran commands mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Xdebug is the extension installed in development mode.
+const Xdebug = "xdebug"
+
+// DevModeIniDirectives are the php.ini directives of the development mode. They show the errors in
+// the responses, revalidate the scripts cached by OPcache on every request and let Xdebug debug the
+// requests which set its trigger.
+var DevModeIniDirectives = map[string]string{
+	"display_errors":              "On",
+	"display_startup_errors":      "On",
+	"opcache.validate_timestamps": "1",
+	"opcache.revalidate_freq":     "0",
+	"xdebug.mode":                 "develop,debug",
+	"xdebug.start_with_request":   "trigger",
+}
+
+// IniDirectives are php.ini directives set with the `php_ini` composer extra or app.yaml
+// runtime_config, such as `memory_limit`. They are written to an ini file which PHP reads after
+// the php.ini of the runtime, so that they take precedence over it.
+type IniDirectives map[string]string
+
+// UnmarshalJSON accepts numbers and booleans as well as strings, so that `"memory_limit": 512`
+// and `"display_errors": false` work as they do in php.ini.
+func (d *IniDirectives) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	directives := make(IniDirectives, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case string:
+			directives[name] = v
+		case json.Number:
+			directives[name] = v.String()
+		case bool:
+			directives[name] = "Off"
+			if v {
+				directives[name] = "On"
+			}
+		default:
+			return fmt.Errorf("php_ini directive %q must be a string, number or boolean", name)
+		}
+	}
+	*d = directives
+	return nil
+}
+
+// IniContent returns the directives as the content of an ini file, sorted by name.
+func IniContent(directives map[string]string) string {
+	var names []string
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString("; Generated by the PHP buildpack from the php_ini directives.\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%s = %s\n", name, directives[name])
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIniDirectivesUnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name    string
+		json    string
+		want    IniDirectives
+		wantErr bool
+	}{
+		{
+			name: "scalars",
+			json: `{"memory_limit": "512M", "max_input_vars": 5000, "display_errors": false, "short_open_tag": true}`,
+			want: IniDirectives{"memory_limit": "512M", "max_input_vars": "5000", "display_errors": "Off", "short_open_tag": "On"},
+		},
+		{
+			name:    "array",
+			json:    `{"extension": ["redis"]}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got IniDirectives
+			err := json.Unmarshal([]byte(tc.json), &got)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("json.Unmarshal() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("json.Unmarshal() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIniContent(t *testing.T) {
+	got := IniContent(map[string]string{"upload_max_filesize": "64M", "memory_limit": "512M"})
+	want := "; Generated by the PHP buildpack from the php_ini directives.\nmemory_limit = 512M\nupload_max_filesize = 64M\n"
+	if got != want {
+		t.Errorf("IniContent() = %q, want %q", got, want)
+	}
+}
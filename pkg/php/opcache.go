@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PreloadClassmap is the value of the `opcache_preload` composer extra or app.yaml
+	// runtime_config which generates the preload script from the Composer classmap. Other values
+	// are the path of a preload script of the application.
+	PreloadClassmap = "classmap"
+
+	// ComposerClassmap is the classmap written by `composer install --optimize-autoloader`,
+	// relative to the application root.
+	ComposerClassmap = "vendor/composer/autoload_classmap.php"
+
+	// IniScanDirEnv is the env var PHP reads additional ini files from. A leading separator keeps
+	// the directory PHP was compiled with.
+	IniScanDirEnv = "PHP_INI_SCAN_DIR"
+)
+
+// classmapPreloadTemplate compiles every file of the Composer classmap into OPcache without
+// running it, so that the order of the classes does not matter. Files which fail to compile, such
+// as files of optional integrations, are skipped.
+const classmapPreloadTemplate = `<?php
+// Generated by the PHP buildpack from the Composer classmap.
+foreach (require %q as $file) {
+    try {
+        opcache_compile_file($file);
+    } catch (\Throwable $e) {
+    }
+}
+`
+
+// ClassmapPreloadScript returns a preload script which compiles the classes of the Composer
+// classmap of the application in appDir.
+func ClassmapPreloadScript(appDir string) string {
+	return fmt.Sprintf(classmapPreloadTemplate, filepath.Join(appDir, ComposerClassmap))
+}
+
+// PreloadIni returns the ini directives which preload script when PHP starts. The OPcache
+// extension is loaded if loadExtension is set, and preloading runs as user when it is not empty,
+// which is required when PHP runs as root.
+func PreloadIni(script, user string, loadExtension bool) string {
+	var sb strings.Builder
+	sb.WriteString("; Generated by the PHP buildpack.\n")
+	if loadExtension {
+		sb.WriteString("zend_extension = opcache\n")
+	}
+	sb.WriteString("opcache.enable = 1\n")
+	fmt.Fprintf(&sb, "opcache.preload = %q\n", script)
+	if user != "" {
+		fmt.Fprintf(&sb, "opcache.preload_user = %q\n", user)
+	}
+	return sb.String()
+}
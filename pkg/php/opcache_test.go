@@ -0,0 +1,54 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassmapPreloadScript(t *testing.T) {
+	got := ClassmapPreloadScript("/workspace")
+	if want := `foreach (require "/workspace/vendor/composer/autoload_classmap.php" as $file) {`; !strings.Contains(got, want) {
+		t.Errorf("ClassmapPreloadScript() = %q, want it to contain %q", got, want)
+	}
+}
+
+func TestPreloadIni(t *testing.T) {
+	testCases := []struct {
+		name          string
+		user          string
+		loadExtension bool
+		want          string
+	}{
+		{
+			name: "extension loaded",
+			user: "www-data",
+			want: "; Generated by the PHP buildpack.\nopcache.enable = 1\nopcache.preload = \"/workspace/preload.php\"\nopcache.preload_user = \"www-data\"\n",
+		},
+		{
+			name:          "extension not loaded without user",
+			loadExtension: true,
+			want:          "; Generated by the PHP buildpack.\nzend_extension = opcache\nopcache.enable = 1\nopcache.preload = \"/workspace/preload.php\"\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := PreloadIni("/workspace/preload.php", tc.user, tc.loadExtension); got != tc.want {
+				t.Errorf("PreloadIni() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
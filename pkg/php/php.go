@@ -17,10 +17,12 @@ package php
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
@@ -28,6 +30,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/Masterminds/semver"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -44,6 +47,9 @@ const (
 
 	composerVersionKey = "php"
 
+	// runtimeVersionSetting is the name used to record the requested runtime version.
+	runtimeVersionSetting = "runtime version"
+
 	// PHPIni is the content of the php.ini config file
 	PHPIni = `
 ; Copyright 2022 Google Inc.
@@ -93,16 +99,38 @@ post_max_size = 32M
 
 	// NginxServesStaticFiles is an environment variable to configure Nginx to serve static files.
 	NginxServesStaticFiles = "NGINX_SERVES_STATIC_FILES"
+
+	// DevModeEnv is an environment variable to enable the development mode, or to disable it
+	// regardless of the `dev_mode` composer extra or app.yaml runtime_config.
+	DevModeEnv = "GOOGLE_PHP_DEV_MODE"
+)
+
+// autoloaderInitRegexp matches the class vendor/autoload.php initializes the Composer autoloader
+// with, which is named after a hash of the installed packages.
+var autoloaderInitRegexp = regexp.MustCompile(`(ComposerAutoloaderInit[0-9a-f]+)::getLoader`)
+
+var (
+	// stabilityFlagRegexp matches the stability flags of Composer constraints, such as `@dev`.
+	stabilityFlagRegexp = regexp.MustCompile(`@[a-zA-Z]+`)
+	// unionRegexp matches the unions of Composer constraints, which accepts `|` besides `||`.
+	unionRegexp = regexp.MustCompile(`\s*\|\|?\s*`)
 )
 
 type composerScriptsJSON struct {
 	GCPBuild string `json:"gcp-build"`
 }
 
+type composerConfigJSON struct {
+	// Platform are the versions of the platform packages Composer resolves the dependencies for
+	// instead of the installed ones.
+	Platform map[string]string `json:"platform"`
+}
+
 // ComposerJSON represents the contents of a composer.json file.
 type ComposerJSON struct {
 	Require map[string]string   `json:"require"`
 	Scripts composerScriptsJSON `json:"scripts"`
+	Config  composerConfigJSON  `json:"config"`
 }
 
 // SupportsAppEngineApis is a function that returns true if App Engine API access is enabled
@@ -201,31 +229,72 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 		if _, err := ctx.Exec([]string{"cp", "--archive", layerVendor, Vendor}, gcp.WithUserTimingAttribution); err != nil {
 			return nil, err
 		}
-	} else {
-		ctx.Logf("Installing application dependencies.")
-		// Clear layer so we don't end up with outdated dependencies (e.g. something was removed from composer.json).
-		if err := ctx.ClearLayer(l); err != nil {
-			return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		mismatch, err := autoloadMismatch(filepath.Join(ctx.ApplicationRoot(), Vendor))
+		if err != nil {
+			return nil, gcp.InternalErrorf("checking the restored %s directory: %w", Vendor, err)
 		}
-		if err := composerInstall(ctx, flags); err != nil {
+		if mismatch == "" {
+			return l, nil
+		}
+		// Install the dependencies again, as a restored autoloader which does not match the installed
+		// packages fails the application with missing classes.
+		ctx.CacheCorrupted(l, mismatch)
+		if err := ctx.RemoveAll(Vendor); err != nil {
 			return nil, err
 		}
+	}
+
+	ctx.Logf("Installing application dependencies.")
+	// Clear layer so we don't end up with outdated dependencies (e.g. something was removed from composer.json).
+	if err := ctx.ClearLayer(l); err != nil {
+		return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	if err := composerInstall(ctx, flags); err != nil {
+		return nil, err
+	}
 
-		// Update the layer metadata.
-		cache.Add(ctx, l, dependencyHashKey, hash)
+	// Update the layer metadata.
+	cache.Add(ctx, l, dependencyHashKey, hash)
 
-		// Ensure vendor exists even if no dependencies were installed.
-		if err := ctx.MkdirAll(Vendor, 0755); err != nil {
-			return nil, err
-		}
-		if _, err := ctx.Exec([]string{"cp", "--archive", Vendor, layerVendor}, gcp.WithUserTimingAttribution); err != nil {
-			return nil, err
-		}
+	// Ensure vendor exists even if no dependencies were installed.
+	if err := ctx.MkdirAll(Vendor, 0755); err != nil {
+		return nil, err
+	}
+	if _, err := ctx.Exec([]string{"cp", "--archive", Vendor, layerVendor}, gcp.WithUserTimingAttribution); err != nil {
+		return nil, err
 	}
 
 	return l, nil
 }
 
+// autoloadMismatch returns why the Composer autoloader in the given vendor directory does not match
+// the class it initializes, or "" if it matches or there is no autoloader.
+func autoloadMismatch(vendor string) (string, error) {
+	autoload, err := os.ReadFile(filepath.Join(vendor, "autoload.php"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	m := autoloaderInitRegexp.FindSubmatch(autoload)
+	if m == nil {
+		return "", nil
+	}
+	class := string(m[1])
+	autoloadReal, err := os.ReadFile(filepath.Join(vendor, "composer", "autoload_real.php"))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Sprintf("%s/composer/autoload_real.php is missing", Vendor), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !regexp.MustCompile(`class\s+` + class + `\b`).Match(autoloadReal) {
+		return fmt.Sprintf("%s/autoload.php initializes %s, which is not defined in %s/composer/autoload_real.php", Vendor, class, Vendor), nil
+	}
+	return "", nil
+}
+
 // ComposerRequire runs `composer require` with the given packages. It expects packages to
 // be specified as `composer require` would expect them on the command line, for example
 // "myorg/mypackage:^0.7". It does no caching.
@@ -247,6 +316,7 @@ func ExtractVersion(ctx *gcp.Context) (string, error) {
 	// get the runtime version from env.RuntimeVersion
 	if v := os.Getenv(env.RuntimeVersion); v != "" {
 		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, v)
+		ctx.RecordSetting(runtimeVersionSetting, v, gcp.SourceEnv)
 		return v, nil
 	}
 
@@ -262,16 +332,19 @@ func ExtractVersion(ctx *gcp.Context) (string, error) {
 			return "", err
 		}
 		if v != "" {
-			ctx.Logf("Using php version from %s %s: %s", composerJSON, composerVersionKey, v)
+			ctx.RecordSetting(runtimeVersionSetting, v, gcp.SourceComposerJSON)
 			return v, nil
 		}
 	}
 
+	ctx.RecordSetting(runtimeVersionSetting, "latest", gcp.SourceDefault)
 	return "", nil
 }
 
-// composerFileVersion extracts the version number from composer.json. returns an error in
-// case the version cannot be read.
+// composerFileVersion extracts the version constraint of php from composer.json. returns an error
+// in case the version cannot be read. The version of config.platform.php, which the dependencies
+// are resolved for, selects the latest patch of its minor version, otherwise the constraint of
+// require.php is used.
 func composerFileVersion(ctx *gcp.Context) (string, error) {
 	cjs, err := ReadComposerJSON(ctx.ApplicationRoot())
 	if err != nil {
@@ -279,11 +352,52 @@ func composerFileVersion(ctx *gcp.Context) (string, error) {
 	}
 
 	// check if composer json has specified php version.
-	v, ok := cjs.Require[composerVersionKey]
-	if !ok {
+	require, hasRequire := cjs.Require[composerVersionKey]
+	platform, hasPlatform := cjs.Config.Platform[composerVersionKey]
+	if !hasRequire && !hasPlatform {
 		ctx.Logf("composer.json exists but does not specify a php version")
 		return "", nil
 	}
 
-	return v, nil
+	var constraint *semver.Constraints
+	if hasRequire {
+		if require, err = semverConstraint(require); err != nil {
+			return "", gcp.UserErrorf("invalid php version constraint %q in %s require.php: %v", cjs.Require[composerVersionKey], composerJSON, err)
+		}
+		if !hasPlatform {
+			ctx.Logf("Using php version %s from %s require.php", require, composerJSON)
+			return require, nil
+		}
+		// Checked by semverConstraint.
+		constraint, _ = semver.NewConstraint(require)
+	}
+
+	v, err := semver.NewVersion(platform)
+	if err != nil {
+		return "", gcp.UserErrorf("invalid php version %q in %s config.platform.php: %v", platform, composerJSON, err)
+	}
+	if constraint != nil && !constraint.Check(v) {
+		return "", gcp.UserErrorf("php version %s in %s config.platform.php does not satisfy require.php %s", platform, composerJSON, require)
+	}
+	resolved := fmt.Sprintf("%d.%d.x", v.Major(), v.Minor())
+	if hasRequire {
+		ctx.Logf("Using php version %s from %s config.platform.php %s, which satisfies require.php %s", resolved, composerJSON, platform, require)
+	} else {
+		ctx.Logf("Using php version %s from %s config.platform.php %s", resolved, composerJSON, platform)
+	}
+	return resolved, nil
+}
+
+// semverConstraint returns the Composer version constraint c as a semver constraint, without the
+// stability flags and with `||` unions.
+func semverConstraint(c string) (string, error) {
+	if _, err := semver.NewConstraint(c); err == nil {
+		return c, nil
+	}
+	translated := stabilityFlagRegexp.ReplaceAllString(c, "")
+	translated = strings.TrimSpace(unionRegexp.ReplaceAllString(translated, " || "))
+	if _, err := semver.NewConstraint(translated); err != nil {
+		return "", err
+	}
+	return translated, nil
 }
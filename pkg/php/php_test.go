@@ -152,6 +152,36 @@ func TestExtractVersion(t *testing.T) {
 `),
 			want: ">= 7.1.3, < 7.4.4",
 		},
+		{
+			name:         "composer.json with single pipe union and stability flag",
+			composerJSON: `{"require": {"php": "^7.4|^8.0@stable"}}`,
+			want:         "^7.4 || ^8.0",
+		},
+		{
+			name:         "composer.json with invalid version constraint",
+			composerJSON: `{"require": {"php": "latest"}}`,
+			wantErr:      true,
+		},
+		{
+			name:         "composer.json with platform version",
+			composerJSON: `{"config": {"platform": {"php": "8.2.12"}}}`,
+			want:         "8.2.x",
+		},
+		{
+			name:         "composer.json with platform version satisfying the constraint",
+			composerJSON: `{"require": {"php": "^8.1"}, "config": {"platform": {"php": "8.1"}}}`,
+			want:         "8.1.x",
+		},
+		{
+			name:         "composer.json with platform version not satisfying the constraint",
+			composerJSON: `{"require": {"php": "^8.2"}, "config": {"platform": {"php": "8.1.27"}}}`,
+			wantErr:      true,
+		},
+		{
+			name:         "composer.json with invalid platform version",
+			composerJSON: `{"config": {"platform": {"php": "eight"}}}`,
+			wantErr:      true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -187,3 +217,57 @@ func TestExtractVersion(t *testing.T) {
 	}
 
 }
+
+func TestAutoloadMismatch(t *testing.T) {
+	const autoload = "<?php\nrequire_once __DIR__ . '/composer/autoload_real.php';\nreturn ComposerAutoloaderInit7f3a1c::getLoader();\n"
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantMismatch bool
+	}{
+		{
+			name: "matching",
+			files: map[string]string{
+				"autoload.php":               autoload,
+				"composer/autoload_real.php": "<?php\nclass ComposerAutoloaderInit7f3a1c\n{\n}\n",
+			},
+		},
+		{
+			name: "no autoloader",
+		},
+		{
+			name: "different class",
+			files: map[string]string{
+				"autoload.php":               autoload,
+				"composer/autoload_real.php": "<?php\nclass ComposerAutoloaderInit7f3a1c2\n{\n}\n",
+			},
+			wantMismatch: true,
+		},
+		{
+			name:         "missing autoload_real.php",
+			files:        map[string]string{"autoload.php": autoload},
+			wantMismatch: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vendor := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(vendor, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := autoloadMismatch(vendor)
+			if err != nil {
+				t.Fatalf("autoloadMismatch() got error: %v", err)
+			}
+			if gotMismatch := got != ""; gotMismatch != tc.wantMismatch {
+				t.Errorf("autoloadMismatch() = %q, want mismatch: %t", got, tc.wantMismatch)
+			}
+		})
+	}
+}
@@ -136,7 +136,10 @@ func versionFromFile(ctx *gcp.Context, dir string) (string, error) {
 // PYTHONPATH. However, this caused issues with some packages as it would allow users to
 // accidentally override some builtin stdlib modules, e.g. typing, enum, etc., and cause both
 // build-time and run-time failures.
-func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) error {
+//
+// If wheels is not nil, it is used as the pip cache so that wheels built from source distributions
+// are reused across builds, see PrepareWheelCache.
+func InstallRequirements(ctx *gcp.Context, l, wheels *libcnb.Layer, reqs ...string) error {
 	// Defensive check, this should not happen in practice.
 	if len(reqs) == 0 {
 		ctx.Debugf("No requirements.txt to install, clearing layer.")
@@ -237,7 +240,11 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 			"--force-reinstall",           // Some dependencies may be in the build image but not run image. Later requirements.txt should override earlier.
 			"--no-compile",                // Prevent default timestamp-based bytecode compilation. Deterministic pycs are generated in a second step below.
 			"--disable-pip-version-check", // If we were going to upgrade pip, we would have done it already in the runtime buildpack.
-			"--no-cache-dir",              // We used to save this to a layer, but it made builds slower because it includes http caching of pypi requests.
+		}
+		if wheels != nil {
+			cmd = append(cmd, "--cache-dir", wheels.Path) // The http cache is removed below, only built wheels are kept.
+		} else {
+			cmd = append(cmd, "--no-cache-dir") // We used to save this to a layer, but it made builds slower because it includes http caching of pypi requests.
 		}
 		vendorDir, isVendored := os.LookupEnv(VendorPipDepsEnv)
 		if isVendored {
@@ -247,8 +254,18 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 		if !virtualEnv {
 			cmd = append(cmd, "--user") // Install into user site-packages directory.
 		}
-		if _, err := ctx.Exec(cmd,
+		if result, err := ctx.Exec(cmd,
 			gcp.WithUserAttribution); err != nil {
+			if result != nil {
+				if merr := missingSystemPackagesError(result.Combined); merr != nil {
+					return merr
+				}
+			}
+			return err
+		}
+	}
+	if wheels != nil {
+		if err := pruneWheelCache(ctx, wheels.Path); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,105 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// StaticPathsEnv is an env var used to serve static files from nginx in front of gunicorn. It is
+	// a comma-separated list of URL path prefixes and the application directories they are served
+	// from, or `true` to serve `/static` and `/media` from the directories of the same name.
+	// Example: `/static=staticfiles,/media=uploads`.
+	StaticPathsEnv = "GOOGLE_PYTHON_STATIC_PATHS"
+
+	// AppModuleEnv is an env var used to set the WSGI or ASGI application served by gunicorn when
+	// static files are served from nginx.
+	// Example: `mysite.wsgi:application`.
+	AppModuleEnv = "GOOGLE_PYTHON_APP_MODULE"
+
+	// DefaultAppModule is the application served by gunicorn if AppModuleEnv is not set.
+	DefaultAppModule = "main:app"
+)
+
+// defaultStaticPaths are the static paths served when StaticPathsEnv is `true`.
+var defaultStaticPaths = []StaticPath{
+	{Prefix: "/static", Dir: "static"},
+	{Prefix: "/media", Dir: "media"},
+}
+
+// StaticPath is a URL path prefix served from a directory of the application.
+type StaticPath struct {
+	// Prefix is the URL path prefix without a trailing slash, for example `/static`.
+	Prefix string
+	// Dir is the directory relative to the application root, for example `static`.
+	Dir string
+}
+
+// ParseStaticPaths parses the value of StaticPathsEnv.
+func ParseStaticPaths(value string) ([]StaticPath, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "true") {
+		return defaultStaticPaths, nil
+	}
+	var paths []StaticPath
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, dir, ok := strings.Cut(entry, "=")
+		prefix, dir = strings.TrimSpace(prefix), strings.TrimSpace(dir)
+		if !ok || prefix == "" || dir == "" {
+			return nil, gcp.UserErrorf("invalid entry %q in %s, expected <url prefix>=<directory>", entry, StaticPathsEnv)
+		}
+		if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, " ;{}") {
+			return nil, gcp.UserErrorf("invalid URL prefix %q in %s, it must start with /", prefix, StaticPathsEnv)
+		}
+		prefix = strings.TrimRight(prefix, "/")
+		if prefix == "" {
+			return nil, gcp.UserErrorf("invalid URL prefix / in %s, the root path is always served by the application", StaticPathsEnv)
+		}
+		dir = filepath.Clean(dir)
+		if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") || strings.ContainsAny(dir, " ;{}") {
+			return nil, gcp.UserErrorf("invalid directory %q in %s, it must be relative to the application root", dir, StaticPathsEnv)
+		}
+		paths = append(paths, StaticPath{Prefix: prefix, Dir: dir})
+	}
+	if len(paths) == 0 {
+		return nil, gcp.UserErrorf("%s does not list any static paths", StaticPathsEnv)
+	}
+	return paths, nil
+}
+
+// UsesUvicorn returns true if uvicorn is listed in the given requirements files, in which case the
+// application is assumed to be an ASGI application.
+func UsesUvicorn(ctx *gcp.Context, reqs ...string) (bool, error) {
+	for _, req := range reqs {
+		content, err := ctx.ReadFile(req)
+		if err != nil {
+			return false, err
+		}
+		for _, name := range requirementNames(string(content)) {
+			if name == "uvicorn" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseStaticPaths(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    []StaticPath
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			value: "true",
+			want:  []StaticPath{{Prefix: "/static", Dir: "static"}, {Prefix: "/media", Dir: "media"}},
+		},
+		{
+			name:  "custom paths",
+			value: "/static/=staticfiles, /uploads=var/uploads/",
+			want:  []StaticPath{{Prefix: "/static", Dir: "staticfiles"}, {Prefix: "/uploads", Dir: "var/uploads"}},
+		},
+		{
+			name:    "missing directory",
+			value:   "/static",
+			wantErr: true,
+		},
+		{
+			name:    "relative prefix",
+			value:   "static=static",
+			wantErr: true,
+		},
+		{
+			name:    "root prefix",
+			value:   "/=static",
+			wantErr: true,
+		},
+		{
+			name:    "directory outside of the application",
+			value:   "/static=../static",
+			wantErr: true,
+		},
+		{
+			name:    "absolute directory",
+			value:   "/static=/etc",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			value:   " , ",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseStaticPaths(tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseStaticPaths(%q) got error: %v, want error: %v", tc.value, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseStaticPaths(%q) mismatch (-want +got):\n%s", tc.value, diff)
+			}
+		})
+	}
+}
+
+func TestUsesUvicorn(t *testing.T) {
+	testCases := []struct {
+		name         string
+		requirements string
+		want         bool
+	}{
+		{
+			name:         "wsgi",
+			requirements: "django==5.0\ngunicorn\n",
+		},
+		{
+			name:         "uvicorn",
+			requirements: "fastapi\nuvicorn[standard]>=0.29\n",
+			want:         true,
+		},
+		{
+			name:         "uvicorn worker package",
+			requirements: "uvicorn-worker\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := filepath.Join(t.TempDir(), "requirements.txt")
+			if err := os.WriteFile(req, []byte(tc.requirements), 0644); err != nil {
+				t.Fatalf("writing requirements: %v", err)
+			}
+
+			got, err := UsesUvicorn(gcp.NewContext(), req)
+			if err != nil {
+				t.Fatalf("UsesUvicorn() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("UsesUvicorn() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
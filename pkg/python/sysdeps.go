@@ -0,0 +1,346 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// SystemPackagesEnv is an environment variable containing a space-separated list of additional
+	// system packages which provide headers and libraries needed to compile dependencies.
+	// Example: `libffi-dev libxml2-dev`
+	SystemPackagesEnv = "GOOGLE_PYTHON_SYSTEM_PACKAGES"
+
+	// systemPackagesKey is the metadata key used to store the installed system packages.
+	systemPackagesKey = "system_packages"
+)
+
+var (
+	// multiarchLibDir is the directory system packages install their libraries to.
+	multiarchLibDir = filepath.Join("usr/lib", debianMultiarch(runtime.GOARCH))
+
+	// sourceOnlyPackages maps Python packages which are only distributed as source, and are always
+	// compiled, to the system packages needed to compile them.
+	sourceOnlyPackages = map[string][]string{
+		"psycopg2":    {"libpq-dev"},
+		"mysqlclient": {"default-libmysqlclient-dev"},
+	}
+
+	// missingHeaderPackages maps headers and tools which are commonly missing when compiling
+	// dependencies to the system packages which provide them.
+	missingHeaderPackages = map[string]string{
+		"ffi.h":                "libffi-dev",
+		"libpq-fe.h":           "libpq-dev",
+		"pg_config":            "libpq-dev",
+		"mysql.h":              "default-libmysqlclient-dev",
+		"mysql_config":         "default-libmysqlclient-dev",
+		"libxml/xmlversion.h":  "libxml2-dev",
+		"libxslt/xsltconfig.h": "libxslt1-dev",
+		"jpeglib.h":            "libjpeg-dev",
+		"zlib.h":               "zlib1g-dev",
+		"openssl/opensslv.h":   "libssl-dev",
+		"yaml.h":               "libyaml-dev",
+	}
+
+	missingHeaderRegexp = regexp.MustCompile(`fatal error: ([\w./+-]+): No such file or directory`)
+	missingToolRegexp   = regexp.MustCompile(`(pg_config|mysql_config)[^\n]*not found`)
+	requirementRegexp   = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)`)
+)
+
+// SystemPackages returns the system packages needed to compile the dependencies listed in the
+// given requirements files, along with those listed in GOOGLE_PYTHON_SYSTEM_PACKAGES.
+func SystemPackages(ctx *gcp.Context, reqs ...string) ([]string, error) {
+	pkgs := map[string]bool{}
+	for _, p := range strings.Fields(os.Getenv(SystemPackagesEnv)) {
+		pkgs[p] = true
+	}
+	for _, req := range reqs {
+		raw, err := ctx.ReadFile(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range requirementNames(string(raw)) {
+			for _, p := range sourceOnlyPackages[name] {
+				ctx.Debugf("Dependency %s requires system package %s", name, p)
+				pkgs[p] = true
+			}
+		}
+	}
+	var result []string
+	for p := range pkgs {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// requirementNames returns the normalized names of the packages listed in a requirements file.
+func requirementNames(requirements string) []string {
+	var names []string
+	for _, line := range strings.Split(requirements, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		// Options and URLs of version control or archive requirements do not name a package.
+		if line == "" || strings.HasPrefix(line, "-") || strings.Contains(strings.Fields(line)[0], "://") {
+			continue
+		}
+		m := requirementRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		names = append(names, strings.ReplaceAll(strings.ToLower(m[1]), "_", "-"))
+	}
+	return names
+}
+
+// InstallSystemPackages installs the given system packages, with the dependencies which are not
+// installed in the build image, in the build-only layer l, so that dependencies which must be
+// compiled can find their headers and libraries. The runtime libraries the packages link against,
+// such as libpq5 for libpq-dev, are also installed in the launch layer rl, as they are not
+// necessarily installed in the run image. rl is not launched if there are none.
+func InstallSystemPackages(ctx *gcp.Context, l, rl *libcnb.Layer, pkgs []string) error {
+	key := strings.Join(pkgs, " ")
+	if ctx.GetMetadata(l, systemPackagesKey) == key && ctx.GetMetadata(rl, systemPackagesKey) == key {
+		ctx.CacheHit(l.Name)
+	} else {
+		ctx.CacheMiss(l.Name)
+		for _, layer := range []*libcnb.Layer{l, rl} {
+			if err := ctx.ClearLayer(layer); err != nil {
+				return fmt.Errorf("clearing layer %q: %w", layer.Name, err)
+			}
+		}
+		if err := installSystemPackages(ctx, l, rl, pkgs); err != nil {
+			return err
+		}
+	}
+	ctx.SetMetadata(l, systemPackagesKey, key)
+	ctx.SetMetadata(rl, systemPackagesKey, key)
+
+	libDir := filepath.Join(l.Path, multiarchLibDir)
+	paths := map[string]string{
+		"CPATH":           filepath.Join(l.Path, "usr", "include"),
+		"LIBRARY_PATH":    libDir,
+		"LD_LIBRARY_PATH": libDir,
+		"PKG_CONFIG_PATH": filepath.Join(libDir, "pkgconfig"),
+		// Tools such as pg_config are installed to usr/bin of the layer.
+		"PATH": filepath.Join(l.Path, "usr", "bin"),
+	}
+	for name, path := range paths {
+		l.BuildEnvironment.Prepend(name, string(os.PathListSeparator), path)
+		// The packages are also needed by pip in this buildpack, which does not see the layer env.
+		value := path
+		if current := os.Getenv(name); current != "" {
+			value += string(os.PathListSeparator) + current
+		}
+		if err := ctx.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+
+	libs, err := ctx.Glob(filepath.Join(rl.Path, multiarchLibDir, "*"))
+	if err != nil {
+		return err
+	}
+	rl.Launch = len(libs) > 0
+	if rl.Launch {
+		rl.LaunchEnvironment.Prepend("LD_LIBRARY_PATH", string(os.PathListSeparator), filepath.Join(rl.Path, multiarchLibDir))
+	}
+	return nil
+}
+
+// installSystemPackages downloads the packages with their dependency closure and extracts them in
+// the build layer l, and the runtime libraries among them in the launch layer rl.
+func installSystemPackages(ctx *gcp.Context, l, rl *libcnb.Layer, pkgs []string) error {
+	key := strings.Join(pkgs, " ")
+	deps, err := ctx.Exec(append([]string{"apt-cache", "depends", "--recurse", "--no-recommends", "--no-suggests", "--no-conflicts", "--no-breaks", "--no-replaces", "--no-enhances"}, pkgs...), gcp.WithUserAttribution)
+	if err != nil {
+		return gcp.UserErrorf("resolving the dependencies of system packages %s: %v", key, err)
+	}
+	installed, err := ctx.Exec([]string{"dpkg-query", "--show", "--showformat", "${Package}\\n"})
+	if err != nil {
+		return gcp.InternalErrorf("listing the installed system packages: %w", err)
+	}
+	build, launch := systemPackageClosure(parseAptDepends(deps.Stdout), pkgs, strings.Fields(installed.Stdout))
+	ctx.Logf("Installing system packages needed to compile dependencies: %s", strings.Join(build, " "))
+	if len(launch) > 0 {
+		ctx.Logf("Installing runtime libraries of the system packages: %s", strings.Join(launch, " "))
+	}
+
+	debDir, err := ctx.TempDir("system-packages")
+	if err != nil {
+		return err
+	}
+	// apt-get download does not require root, the packages are extracted in the layers instead.
+	if _, err := ctx.Exec(append([]string{"apt-get", "download"}, build...), gcp.WithWorkDir(debDir), gcp.WithUserAttribution); err != nil {
+		return gcp.UserErrorf("downloading system packages %s: %v", key, err)
+	}
+	debs, err := ctx.Glob(filepath.Join(debDir, "*.deb"))
+	if err != nil {
+		return err
+	}
+	for _, deb := range debs {
+		dirs := []string{l.Path}
+		// Debian package files are named <package>_<version>_<architecture>.deb.
+		if slices.Contains(launch, strings.SplitN(filepath.Base(deb), "_", 2)[0]) {
+			dirs = append(dirs, rl.Path)
+		}
+		for _, dir := range dirs {
+			if _, err := ctx.Exec([]string{"dpkg", "-x", deb, dir}); err != nil {
+				return gcp.InternalErrorf("extracting %s: %w", deb, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseAptDepends returns the dependencies of each package listed in the output of
+// `apt-cache depends`. Only the first package of alternative dependencies is kept, and virtual
+// packages, which are printed in angle brackets, are ignored.
+func parseAptDepends(output string) map[string][]string {
+	graph := map[string][]string{}
+	var pkg string
+	alternative := false
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			pkg = strings.TrimSpace(line)
+			alternative = false
+			continue
+		}
+		field := strings.TrimSpace(line)
+		isDepends := strings.HasPrefix(field, "Depends:") || strings.HasPrefix(field, "PreDepends:")
+		isAlternative := strings.HasPrefix(field, "|Depends:") || strings.HasPrefix(field, "|PreDepends:")
+		if !isDepends && !isAlternative {
+			continue
+		}
+		// The previous line was the first package of the alternatives this one belongs to.
+		skip := alternative
+		alternative = isAlternative
+		if skip || strings.HasPrefix(pkg, "<") {
+			continue
+		}
+		dep := strings.TrimSpace(field[strings.Index(field, ":")+1:])
+		if strings.HasPrefix(dep, "<") {
+			continue
+		}
+		graph[pkg] = append(graph[pkg], dep)
+	}
+	return graph
+}
+
+// systemPackageClosure returns the packages which must be installed to build with pkgs, which are
+// pkgs and their dependencies which are not installed, and the runtime libraries among them.
+// The runtime libraries are the packages development packages depend on which are not development
+// packages themselves, they are installed for the build as well, so that the links of the
+// development packages resolve in the layer.
+func systemPackageClosure(graph map[string][]string, pkgs, installed []string) (build, launch []string) {
+	buildSet, launchSet, devSet := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	var visit func(pkg string)
+	visit = func(pkg string) {
+		if buildSet[pkg] || slices.Contains(installed, pkg) {
+			return
+		}
+		buildSet[pkg] = true
+		for _, dep := range graph[pkg] {
+			visit(dep)
+		}
+	}
+	var visitDev func(pkg string)
+	visitDev = func(pkg string) {
+		if devSet[pkg] {
+			return
+		}
+		devSet[pkg] = true
+		for _, dep := range graph[pkg] {
+			if isDevPackage(dep) {
+				visitDev(dep)
+			} else if !launchSet[dep] {
+				launchSet[dep] = true
+				buildSet[dep] = true
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+		if isDevPackage(pkg) {
+			visitDev(pkg)
+		}
+	}
+	for pkg := range buildSet {
+		build = append(build, pkg)
+	}
+	for pkg := range launchSet {
+		launch = append(launch, pkg)
+	}
+	sort.Strings(build)
+	sort.Strings(launch)
+	return build, launch
+}
+
+// isDevPackage returns true if pkg provides headers and development files, such as libpq-dev or
+// the default-libmysqlclient-dev metapackage.
+func isDevPackage(pkg string) bool {
+	return strings.HasSuffix(pkg, "-dev")
+}
+
+// missingSystemPackagesError returns an error listing the system packages which are missing
+// according to the output of a failed pip install, or nil if none are recognized.
+func missingSystemPackagesError(output string) error {
+	missing := map[string]bool{}
+	for _, m := range missingHeaderRegexp.FindAllStringSubmatch(output, -1) {
+		if p, ok := missingHeaderPackages[m[1]]; ok {
+			missing[p] = true
+		}
+	}
+	for _, m := range missingToolRegexp.FindAllStringSubmatch(output, -1) {
+		missing[missingHeaderPackages[m[1]]] = true
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	var pkgs []string
+	for p := range missing {
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+	return gcp.UserErrorf("compiling dependencies failed because system packages are missing: %s. Set %s=%q to install them during the build",
+		strings.Join(pkgs, ", "), SystemPackagesEnv, strings.Join(pkgs, " "))
+}
+
+// debianMultiarch returns the Debian multiarch triplet of a Go architecture, which names the
+// directory Debian packages install their libraries to.
+func debianMultiarch(goarch string) string {
+	switch goarch {
+	case "arm64":
+		return "aarch64-linux-gnu"
+	default:
+		return "x86_64-linux-gnu"
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequirementNames(t *testing.T) {
+	requirements := `# comment
+Flask==3.0.2
+psycopg2>=2.9 ; python_version >= "3.8"
+mysqlclient[extra]
+Django_Extensions~=3.2  # inline comment
+-r other.txt
+--index-url https://example.com/simple
+git+https://github.com/example/repo.git
+`
+	want := []string{"flask", "psycopg2", "mysqlclient", "django-extensions"}
+	if diff := cmp.Diff(want, requirementNames(requirements)); diff != "" {
+		t.Errorf("requirementNames() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSystemPackages(t *testing.T) {
+	testCases := []struct {
+		name         string
+		requirements string
+		env          string
+		want         []string
+	}{
+		{
+			name:         "no source only packages",
+			requirements: "flask\npsycopg2-binary\n",
+		},
+		{
+			name:         "psycopg2",
+			requirements: "flask\npsycopg2==2.9.9\n",
+			want:         []string{"libpq-dev"},
+		},
+		{
+			name:         "packages from env",
+			requirements: "mysqlclient\n",
+			env:          "libffi-dev libpq-dev",
+			want:         []string{"default-libmysqlclient-dev", "libffi-dev", "libpq-dev"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(SystemPackagesEnv, tc.env)
+			req := filepath.Join(t.TempDir(), "requirements.txt")
+			if err := os.WriteFile(req, []byte(tc.requirements), 0644); err != nil {
+				t.Fatalf("writing requirements: %v", err)
+			}
+
+			got, err := SystemPackages(gcp.NewContext(), req)
+			if err != nil {
+				t.Fatalf("SystemPackages() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SystemPackages() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseAptDepends(t *testing.T) {
+	output := `libpq-dev
+  Depends: libpq5
+  Depends: libssl-dev
+libpq5
+  Depends: libc6
+ |Depends: libgssapi-krb5-2
+  Depends: libheimdal
+  Depends: <libldap2>
+libssl-dev
+  Depends: libssl3
+<libldap2>
+  libldap-2.5-0
+libc6
+libssl3
+  Depends: libc6
+`
+	want := map[string][]string{
+		"libpq-dev":  {"libpq5", "libssl-dev"},
+		"libpq5":     {"libc6", "libgssapi-krb5-2"},
+		"libssl-dev": {"libssl3"},
+		"libssl3":    {"libc6"},
+	}
+	if diff := cmp.Diff(want, parseAptDepends(output)); diff != "" {
+		t.Errorf("parseAptDepends() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDebianMultiarch(t *testing.T) {
+	testCases := map[string]string{
+		"amd64": "x86_64-linux-gnu",
+		"arm64": "aarch64-linux-gnu",
+	}
+	for goarch, want := range testCases {
+		if got := debianMultiarch(goarch); got != want {
+			t.Errorf("debianMultiarch(%q) = %q, want %q", goarch, got, want)
+		}
+	}
+}
+
+func TestSystemPackageClosure(t *testing.T) {
+	graph := map[string][]string{
+		"libpq-dev":                  {"libpq5", "libssl-dev"},
+		"libpq5":                     {"libc6", "libgssapi-krb5-2"},
+		"libssl-dev":                 {"libssl3"},
+		"libssl3":                    {"libc6"},
+		"default-libmysqlclient-dev": {"libmysqlclient-dev"},
+		"libmysqlclient-dev":         {"libmysqlclient21", "zlib1g-dev"},
+		"libmysqlclient21":           {"libc6"},
+		"zlib1g-dev":                 {"zlib1g"},
+	}
+	testCases := []struct {
+		name       string
+		pkgs       []string
+		installed  []string
+		wantBuild  []string
+		wantLaunch []string
+	}{
+		{
+			name:       "nothing installed",
+			pkgs:       []string{"libpq-dev"},
+			wantBuild:  []string{"libc6", "libgssapi-krb5-2", "libpq-dev", "libpq5", "libssl-dev", "libssl3"},
+			wantLaunch: []string{"libpq5", "libssl3"},
+		},
+		{
+			name:       "runtime libraries installed in the build image",
+			pkgs:       []string{"libpq-dev"},
+			installed:  []string{"libc6", "libgssapi-krb5-2", "libpq5", "libssl-dev", "libssl3"},
+			wantBuild:  []string{"libpq-dev", "libpq5", "libssl3"},
+			wantLaunch: []string{"libpq5", "libssl3"},
+		},
+		{
+			name:       "metapackage",
+			pkgs:       []string{"default-libmysqlclient-dev"},
+			installed:  []string{"libc6", "zlib1g", "zlib1g-dev"},
+			wantBuild:  []string{"default-libmysqlclient-dev", "libmysqlclient-dev", "libmysqlclient21", "zlib1g"},
+			wantLaunch: []string{"libmysqlclient21", "zlib1g"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			build, launch := systemPackageClosure(graph, tc.pkgs, tc.installed)
+			if diff := cmp.Diff(tc.wantBuild, build); diff != "" {
+				t.Errorf("systemPackageClosure() build packages mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantLaunch, launch); diff != "" {
+				t.Errorf("systemPackageClosure() launch packages mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstallSystemPackages(t *testing.T) {
+	testCases := []struct {
+		name          string
+		layerMetadata map[string]any
+		libraries     []string
+		mocks         []*mockprocess.Mock
+		wantLaunch    bool
+		wantErr       bool
+	}{
+		{
+			name:          "cached",
+			layerMetadata: map[string]any{"system_packages": "libpq-dev"},
+		},
+		{
+			name:          "cached runtime libraries",
+			layerMetadata: map[string]any{"system_packages": "libpq-dev"},
+			libraries:     []string{"libpq.so.5"},
+			wantLaunch:    true,
+		},
+		{
+			name:          "download fails",
+			layerMetadata: map[string]any{},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`apt-get download libpq-dev`, mockprocess.WithStderr("E: Unable to locate package"), mockprocess.WithExitCode(100)),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CPATH", "")
+			opts := []gcp.ContextOption{}
+			if len(tc.mocks) > 0 {
+				eCmd, err := mockprocess.NewExecCmd(tc.mocks...)
+				if err != nil {
+					t.Fatalf("error creating mock exec command: %v", err)
+				}
+				opts = append(opts, gcp.WithExecCmd(eCmd))
+			}
+			ctx := gcp.NewContext(opts...)
+			l := &libcnb.Layer{Name: "system-packages", Path: t.TempDir(), Metadata: tc.layerMetadata, BuildEnvironment: libcnb.Environment{}}
+			rl := &libcnb.Layer{Name: "system-libraries", Path: t.TempDir(), Metadata: map[string]any{}, LaunchEnvironment: libcnb.Environment{}}
+			for k, v := range tc.layerMetadata {
+				rl.Metadata[k] = v
+			}
+			for _, lib := range tc.libraries {
+				dir := filepath.Join(rl.Path, multiarchLibDir)
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatalf("creating %s: %v", dir, err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, lib), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", lib, err)
+				}
+			}
+
+			err := InstallSystemPackages(ctx, l, rl, []string{"libpq-dev"})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("InstallSystemPackages() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			wantInclude := filepath.Join(l.Path, "usr", "include")
+			if got := l.BuildEnvironment["CPATH.prepend"]; got != wantInclude {
+				t.Errorf("InstallSystemPackages() CPATH layer env = %q, want %q", got, wantInclude)
+			}
+			if got := os.Getenv("CPATH"); got != wantInclude {
+				t.Errorf("InstallSystemPackages() CPATH = %q, want %q", got, wantInclude)
+			}
+			if rl.Launch != tc.wantLaunch {
+				t.Errorf("InstallSystemPackages() runtime libraries layer launch = %t, want %t", rl.Launch, tc.wantLaunch)
+			}
+			wantLibPath := ""
+			if tc.wantLaunch {
+				wantLibPath = filepath.Join(rl.Path, multiarchLibDir)
+			}
+			if got := rl.LaunchEnvironment["LD_LIBRARY_PATH.prepend"]; got != wantLibPath {
+				t.Errorf("InstallSystemPackages() LD_LIBRARY_PATH launch env = %q, want %q", got, wantLibPath)
+			}
+		})
+	}
+}
+
+func TestMissingSystemPackagesError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		output  string
+		wantErr string
+	}{
+		{
+			name:   "unrelated failure",
+			output: "ERROR: No matching distribution found for flask==99",
+		},
+		{
+			name: "missing headers",
+			output: `  build/temp.linux-x86_64-cpython-312/_cffi_backend.c:15:10: fatal error: ffi.h: No such file or directory
+  compilation terminated.
+  src/lxml/includes/etree_defs.h:14:10: fatal error: libxml/xmlversion.h: No such file or directory`,
+			wantErr: "libffi-dev, libxml2-dev",
+		},
+		{
+			name: "missing pg_config",
+			output: `  Error: pg_config executable not found.
+  pg_config is required to build psycopg2 from source.`,
+			wantErr: "libpq-dev",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := missingSystemPackagesError(tc.output)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("missingSystemPackagesError() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) || !strings.Contains(err.Error(), SystemPackagesEnv) {
+				t.Errorf("missingSystemPackagesError() = %v, want error containing %q and %q", err, tc.wantErr, SystemPackagesEnv)
+			}
+		})
+	}
+}
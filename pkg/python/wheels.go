@@ -0,0 +1,71 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// wheelCacheHashKey is the metadata key used to store the hash the wheel cache layer is keyed on.
+	wheelCacheHashKey = "wheel_cache_hash"
+)
+
+var (
+	// pipHTTPCacheDirs are the directories of the pip cache which hold HTTP responses rather than
+	// built wheels. Persisting them makes builds slower, so they are removed after installation.
+	pipHTTPCacheDirs = []string{"http", "http-v2"}
+)
+
+// PrepareWheelCache readies the given layer to be used as the pip cache, which holds the wheels
+// pip builds from source distributions. The layer is cleared if the requirements files or the
+// Python version changed since it was populated.
+func PrepareWheelCache(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) error {
+	pythonVersion, err := Version(ctx)
+	if err != nil {
+		return err
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, wheelCacheHashKey,
+		cache.WithFiles(reqs...),
+		cache.WithStrings(pythonVersion))
+	if err != nil {
+		return err
+	}
+	if cached {
+		ctx.CacheHit(l.Name)
+		return nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	cache.Add(ctx, l, wheelCacheHashKey, hash)
+	return nil
+}
+
+// pruneWheelCache removes everything but the built wheels from the pip cache in dir.
+func pruneWheelCache(ctx *gcp.Context, dir string) error {
+	for _, d := range pipHTTPCacheDirs {
+		if err := ctx.RemoveAll(filepath.Join(dir, d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestPrepareWheelCache(t *testing.T) {
+	req := filepath.Join(t.TempDir(), "requirements.txt")
+	if err := os.WriteFile(req, []byte("psycopg2==2.9.9\n"), 0644); err != nil {
+		t.Fatalf("writing requirements: %v", err)
+	}
+	l := &libcnb.Layer{Name: "pip-wheels", Path: t.TempDir(), Metadata: map[string]any{}}
+	wheel := filepath.Join(l.Path, "wheels", "psycopg2-2.9.9-cp312-cp312-linux_x86_64.whl")
+
+	prepare := func(pythonVersion string) {
+		t.Helper()
+		eCmd, err := mockprocess.NewExecCmd(mockprocess.New(`python3 --version`, mockprocess.WithStdout(pythonVersion)))
+		if err != nil {
+			t.Fatalf("error creating mock exec command: %v", err)
+		}
+		if err := PrepareWheelCache(gcp.NewContext(gcp.WithExecCmd(eCmd)), l, req); err != nil {
+			t.Fatalf("PrepareWheelCache() got error: %v", err)
+		}
+	}
+	writeWheel := func() {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(wheel), 0755); err != nil {
+			t.Fatalf("creating wheels dir: %v", err)
+		}
+		if err := os.WriteFile(wheel, nil, 0644); err != nil {
+			t.Fatalf("writing wheel: %v", err)
+		}
+	}
+
+	prepare("Python 3.12.2")
+	writeWheel()
+	prepare("Python 3.12.2")
+	if _, err := os.Stat(wheel); err != nil {
+		t.Errorf("PrepareWheelCache() with unchanged inputs removed the cached wheel: %v", err)
+	}
+	prepare("Python 3.12.3")
+	if _, err := os.Stat(wheel); !os.IsNotExist(err) {
+		t.Errorf("PrepareWheelCache() with a new Python version kept the cached wheel, stat error: %v", err)
+	}
+}
+
+func TestPruneWheelCache(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"http-v2/a", "http/b", "wheels/c"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatalf("creating %s: %v", d, err)
+		}
+	}
+	if err := pruneWheelCache(gcp.NewContext(), dir); err != nil {
+		t.Fatalf("pruneWheelCache() got error: %v", err)
+	}
+	for _, d := range []string{"http-v2", "http"} {
+		if _, err := os.Stat(filepath.Join(dir, d)); !os.IsNotExist(err) {
+			t.Errorf("pruneWheelCache() kept %s, stat error: %v", d, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wheels")); err != nil {
+		t.Errorf("pruneWheelCache() removed wheels: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruby
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// SidekiqConcurrencyEnv is an env var used to set the number of Sidekiq threads of the worker
+	// process. It defaults to a value derived from the memory limit of the container.
+	SidekiqConcurrencyEnv = "SIDEKIQ_CONCURRENCY"
+
+	// RedisURLEnv is the env var used by Sidekiq and Rails to connect to Redis.
+	RedisURLEnv = "REDIS_URL"
+	// redisProviderEnv is the env var Sidekiq reads the name of the Redis URL env var from.
+	redisProviderEnv = "REDIS_PROVIDER"
+
+	// sidekiqDefaultConcurrency is the concurrency used when the memory limit is unknown. It matches
+	// the Sidekiq default.
+	sidekiqDefaultConcurrency = 5
+	// sidekiqMinConcurrency and sidekiqMaxConcurrency bound the concurrency derived from memory.
+	sidekiqMinConcurrency = 2
+	sidekiqMaxConcurrency = 25
+	// sidekiqMemoryPerThread is the memory in MiB budgeted for each Sidekiq thread.
+	sidekiqMemoryPerThread = 128
+	// sidekiqUnlimitedMemory is the memory limit in MiB above which the container is considered not
+	// limited, cgroup v1 reports a very large number instead of `max`.
+	sidekiqUnlimitedMemory = 1 << 20
+)
+
+var (
+	// sidekiqLockRegexp matches sidekiq in the specs of Gemfile.lock or gems.locked.
+	sidekiqLockRegexp = regexp.MustCompile(`(?m)^\s+sidekiq \(`)
+	// sidekiqGemfileRegexp matches a sidekiq gem declaration in Gemfile or gems.rb.
+	sidekiqGemfileRegexp = regexp.MustCompile(`(?m)^\s*gem\s+["']sidekiq["']`)
+
+	// cgroupMemoryFiles are the files the memory limit of the container is read from, for cgroup v2
+	// and v1 respectively.
+	cgroupMemoryFiles = []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"}
+)
+
+// UsesSidekiq returns true if the application depends on the sidekiq gem.
+func UsesSidekiq(ctx *gcp.Context) (bool, error) {
+	for _, f := range []string{bundleIndicator, bundle2Indicator, "Gemfile", "gems.rb"} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), f))
+		if err != nil {
+			return false, err
+		}
+		re := sidekiqGemfileRegexp
+		if f == bundleIndicator || f == bundle2Indicator {
+			re = sidekiqLockRegexp
+		}
+		if re.Match(content) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ValidateRedisURL checks the Redis URL used by Sidekiq if it is set at build time. The URL is
+// read from the env var named by REDIS_PROVIDER, or REDIS_URL.
+func ValidateRedisURL(ctx *gcp.Context) error {
+	name := RedisURLEnv
+	if provider := os.Getenv(redisProviderEnv); provider != "" {
+		name = provider
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		ctx.Warnf("%s is not set, it must be set at runtime for both the web and worker processes to share the same Redis", name)
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return gcp.UserErrorf("parsing %s: %v", name, err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" && u.Scheme != "unix" {
+		return gcp.UserErrorf("invalid %s scheme %q, must be one of redis, rediss or unix", name, u.Scheme)
+	}
+	if u.Scheme != "unix" && u.Host == "" {
+		return gcp.UserErrorf("invalid %s, missing host", name)
+	}
+	return nil
+}
+
+// SidekiqConcurrency returns the number of Sidekiq threads for a container with the given memory
+// limit in MiB. A limit of zero or less, or above 1TiB, means the container is not limited.
+func SidekiqConcurrency(memoryMiB int64) int {
+	if memoryMiB <= 0 || memoryMiB > sidekiqUnlimitedMemory {
+		return sidekiqDefaultConcurrency
+	}
+	c := int(memoryMiB / sidekiqMemoryPerThread)
+	if c < sidekiqMinConcurrency {
+		return sidekiqMinConcurrency
+	}
+	if c > sidekiqMaxConcurrency {
+		return sidekiqMaxConcurrency
+	}
+	return c
+}
+
+// sidekiqConcurrencyScript is the template of the profile.d script implementing
+// SidekiqConcurrency in shell.
+const sidekiqConcurrencyScript = `if [ -z "${%[1]s:-}" ]; then
+  mem=""
+  for f in %[2]s; do
+    if [ -r "$f" ]; then mem="$(cat "$f")"; break; fi
+  done
+  case "$mem" in
+    ''|*[!0-9]*) mib=0 ;;
+    *) mib=$((mem / 1048576)) ;;
+  esac
+  if [ "$mib" -le 0 ] || [ "$mib" -gt %[3]d ]; then
+    c=%[4]d
+  else
+    c=$((mib / %[5]d))
+    [ "$c" -lt %[6]d ] && c=%[6]d
+    [ "$c" -gt %[7]d ] && c=%[7]d
+  fi
+  export %[1]s="$c"
+fi
+export RAILS_MAX_THREADS="${RAILS_MAX_THREADS:-$%[1]s}"
+`
+
+// SidekiqConcurrencyScript returns a profile.d script which sets SIDEKIQ_CONCURRENCY from the
+// memory limit of the container when it is not set, following SidekiqConcurrency. The database
+// pool size is defaulted to the concurrency so that every thread can hold a connection.
+func SidekiqConcurrencyScript() string {
+	return fmt.Sprintf(sidekiqConcurrencyScript, SidekiqConcurrencyEnv, strings.Join(cgroupMemoryFiles, " "),
+		sidekiqUnlimitedMemory, sidekiqDefaultConcurrency, sidekiqMemoryPerThread, sidekiqMinConcurrency, sidekiqMaxConcurrency)
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruby
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestUsesSidekiq(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name: "Gemfile.lock with sidekiq",
+			files: map[string]string{
+				"Gemfile.lock": "GEM\n  specs:\n    rails (7.1.3)\n    sidekiq (7.2.2)\n      redis-client (>= 0.19.0)\n",
+			},
+			want: true,
+		},
+		{
+			name: "Gemfile.lock with sidekiq-cron only",
+			files: map[string]string{
+				"Gemfile.lock": "GEM\n  specs:\n    sidekiq-cron (1.12.0)\n",
+			},
+		},
+		{
+			name: "Gemfile with sidekiq",
+			files: map[string]string{
+				"Gemfile": "source 'https://rubygems.org'\ngem \"rails\"\ngem 'sidekiq', '~> 7.2'\n",
+			},
+			want: true,
+		},
+		{
+			name: "no sidekiq",
+			files: map[string]string{
+				"Gemfile": "gem 'rails'\n",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			got, err := UsesSidekiq(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("UsesSidekiq() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("UsesSidekiq() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateRedisURL(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "redis",
+			env:  map[string]string{"REDIS_URL": "redis://10.0.0.3:6379/0"},
+		},
+		{
+			name: "tls",
+			env:  map[string]string{"REDIS_URL": "rediss://:secret@redis.example.com:6380"},
+		},
+		{
+			name:    "http scheme",
+			env:     map[string]string{"REDIS_URL": "http://10.0.0.3:6379"},
+			wantErr: true,
+		},
+		{
+			name:    "missing host",
+			env:     map[string]string{"REDIS_URL": "redis:///0"},
+			wantErr: true,
+		},
+		{
+			name:    "provider",
+			env:     map[string]string{"REDIS_PROVIDER": "MEMORYSTORE_URL", "MEMORYSTORE_URL": "10.0.0.3:6379"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"REDIS_URL", "REDIS_PROVIDER"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			err := ValidateRedisURL(gcp.NewContext())
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateRedisURL() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSidekiqConcurrency(t *testing.T) {
+	testCases := []struct {
+		name      string
+		memory    string
+		memoryMiB int64
+		want      int
+	}{
+		{name: "unknown", want: 5},
+		{name: "cgroup v2 unlimited", memory: "max", want: 5},
+		{name: "cgroup v1 unlimited", memory: "9223372036854771712", memoryMiB: 9223372036854771712 / 1048576, want: 5},
+		{name: "256MiB", memory: "268435456", memoryMiB: 256, want: 2},
+		{name: "1GiB", memory: "1073741824", memoryMiB: 1024, want: 8},
+		{name: "2GiB", memory: "2147483648", memoryMiB: 2048, want: 16},
+		{name: "8GiB", memory: "8589934592", memoryMiB: 8192, want: 25},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SidekiqConcurrency(tc.memoryMiB); got != tc.want {
+				t.Errorf("SidekiqConcurrency(%d) = %d, want %d", tc.memoryMiB, got, tc.want)
+			}
+
+			memFile := filepath.Join(t.TempDir(), "memory.max")
+			if tc.memory != "" {
+				if err := os.WriteFile(memFile, []byte(tc.memory+"\n"), 0644); err != nil {
+					t.Fatalf("writing %s: %v", memFile, err)
+				}
+			}
+			orig := cgroupMemoryFiles
+			cgroupMemoryFiles = []string{memFile}
+			defer func() { cgroupMemoryFiles = orig }()
+
+			cmd := exec.Command("sh", "-c", SidekiqConcurrencyScript()+`echo "$SIDEKIQ_CONCURRENCY"`)
+			cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("running SidekiqConcurrencyScript() got error: %v", err)
+			}
+			if got := strings.TrimSpace(string(out)); got != strconv.Itoa(tc.want) {
+				t.Errorf("SidekiqConcurrencyScript() set %s=%s, want %d", SidekiqConcurrencyEnv, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// assetsManifests maps the runtimes which can build the assets of an application served by
+// another runtime to the file their build is configured in.
+var assetsManifests = map[string]string{
+	"nodejs": "package.json",
+}
+
+// AssetsRuntime returns the runtime selected with env.AssetsRuntime to build the static assets of
+// an application served by another runtime, or an empty string if none is selected.
+func AssetsRuntime() (string, error) {
+	runtime := strings.ToLower(strings.TrimSpace(os.Getenv(env.AssetsRuntime)))
+	if runtime == "" {
+		return "", nil
+	}
+	if _, ok := assetsManifests[runtime]; !ok {
+		return "", gcp.UserErrorf("%s=%q is not supported, it must be one of: nodejs", env.AssetsRuntime, runtime)
+	}
+	return runtime, nil
+}
+
+// AssetsPlanName returns the name of the build plan entry provided by the buildpack which builds
+// the assets with the given runtime, for example `nodejs-assets`.
+func AssetsPlanName(runtime string) string {
+	return runtime + "-assets"
+}
+
+// HybridAssets returns the build plan entry of the assets built by the runtime selected with
+// env.AssetsRuntime, or an empty string if none is selected or the file its build is configured in
+// does not exist.
+func HybridAssets(ctx *gcp.Context) (string, error) {
+	runtime, err := AssetsRuntime()
+	if err != nil || runtime == "" {
+		return "", err
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), assetsManifests[runtime])
+	if err != nil || !exists {
+		return "", err
+	}
+	return AssetsPlanName(runtime), nil
+}
+
+// RequireAssets adds a requirement on the assets built by the runtime selected with
+// env.AssetsRuntime to the passing detect result of a server runtime, so that the server is only
+// built by an order group which also builds the assets.
+func RequireAssets(ctx *gcp.Context, result gcp.DetectResult) (gcp.DetectResult, error) {
+	if result == nil || !result.Result().Pass {
+		return result, nil
+	}
+	assets, err := HybridAssets(ctx)
+	if err != nil || assets == "" {
+		return result, err
+	}
+	return gcp.AddRequires(result, libcnb.BuildPlanRequire{Name: assets}), nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestRequireAssets(t *testing.T) {
+	testCases := []struct {
+		name          string
+		assetsRuntime string
+		files         []string
+		result        gcp.DetectResult
+		wantPlans     []libcnb.BuildPlan
+		wantErr       bool
+	}{
+		{
+			name:   "not set",
+			files:  []string{"package.json"},
+			result: gcp.OptIn("found composer.json"),
+		},
+		{
+			name:          "nodejs assets",
+			assetsRuntime: "nodejs",
+			files:         []string{"package.json"},
+			result:        gcp.OptIn("found composer.json"),
+			wantPlans:     []libcnb.BuildPlan{{Requires: []libcnb.BuildPlanRequire{{Name: "nodejs-assets"}}}},
+		},
+		{
+			name:          "case insensitive",
+			assetsRuntime: " NodeJS ",
+			files:         []string{"package.json"},
+			result:        gcp.OptIn("found composer.json"),
+			wantPlans:     []libcnb.BuildPlan{{Requires: []libcnb.BuildPlanRequire{{Name: "nodejs-assets"}}}},
+		},
+		{
+			name:          "no package.json",
+			assetsRuntime: "nodejs",
+			result:        gcp.OptIn("found composer.json"),
+		},
+		{
+			name:          "server opts out",
+			assetsRuntime: "nodejs",
+			files:         []string{"package.json"},
+			result:        gcp.OptOut("composer.json not found"),
+		},
+		{
+			name:          "unsupported runtime",
+			assetsRuntime: "ruby",
+			files:         []string{"Gemfile"},
+			result:        gcp.OptIn("found composer.json"),
+			wantErr:       true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, f), []byte("{}"), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			t.Setenv(env.AssetsRuntime, tc.assetsRuntime)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := RequireAssets(ctx, tc.result)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequireAssets() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got.Result().Pass != tc.result.Result().Pass {
+				t.Errorf("RequireAssets() pass = %t, want %t", got.Result().Pass, tc.result.Result().Pass)
+			}
+			if !reflect.DeepEqual(got.Result().Plans, tc.wantPlans) {
+				t.Errorf("RequireAssets() plans = %#v, want %#v", got.Result().Plans, tc.wantPlans)
+			}
+		})
+	}
+}
@@ -53,6 +53,7 @@ const (
 	Python       InstallableRuntime = "python"
 	Ruby         InstallableRuntime = "ruby"
 	Nginx        InstallableRuntime = "nginx"
+	NginxBrotli  InstallableRuntime = "nginx-brotli"
 	Pid1         InstallableRuntime = "pid1"
 	DotnetSDK    InstallableRuntime = "dotnetsdk"
 	AspNetCore   InstallableRuntime = "aspnetcore"
@@ -66,14 +67,15 @@ const (
 
 // User friendly display name of all runtime (e.g. for use in error message).
 var runtimeNames = map[InstallableRuntime]string{
-	Nodejs:    "Node.js",
-	PHP:       "PHP Runtime",
-	Python:    "Python",
-	Ruby:      "Ruby Runtime",
-	Nginx:     "Nginx Web Server",
-	Pid1:      "Pid1",
-	DotnetSDK: ".NET SDK",
-	Go:        "Go",
+	Nodejs:      "Node.js",
+	PHP:         "PHP Runtime",
+	Python:      "Python",
+	Ruby:        "Ruby Runtime",
+	Nginx:       "Nginx Web Server",
+	NginxBrotli: "Nginx Brotli Module",
+	Pid1:        "Pid1",
+	DotnetSDK:   ".NET SDK",
+	Go:          "Go",
 }
 
 // stackToOS contains the mapping of Stack to OS.
@@ -210,6 +212,20 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 	return false, nil
 }
 
+// InstallModuleTarball installs the tarball of the runtime at exactly the given version into dir
+// without clearing it, for modules which are built for a version of the runtime installed in the
+// layer of dir, such as nginx modules.
+func InstallModuleTarball(ctx *gcp.Context, runtime InstallableRuntime, version, dir string) error {
+	runtimeName := runtimeNames[runtime]
+	osName := OSForStack(ctx)
+	ctx.Logf("Installing %s v%s.", runtimeName, version)
+	if err := fetch.Tarball(tarballDownloadURL(runtime, osName, version), dir, 0); err != nil {
+		ctx.Warnf("Failed to download %s version %s osName %s from lorry.", runtimeName, version, osName)
+		return err
+	}
+	return nil
+}
+
 func runtimeImageURL(runtime InstallableRuntime, osName, version, region string) string {
 	return fmt.Sprintf(runtimeImageARURL, region, osName, runtime, version)
 }
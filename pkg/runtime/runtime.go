@@ -55,6 +55,23 @@ func CheckOverride(wantRuntime string) gcp.DetectResult {
 	return gcp.OptOut(fmt.Sprintf("%s does not match to %q", env.Runtime, wantRuntime))
 }
 
+// CheckFrameworkOverride returns a Detect result or nil based on the GOOGLE_FRAMEWORK environment
+// variable value. It returns nil if the variable is not set, an OptIn result if it is set to one of
+// wantFrameworks and an OptOut result otherwise, which skips the autodetection of framework
+// buildpacks in polyglot repositories.
+func CheckFrameworkOverride(wantFrameworks ...string) gcp.DetectResult {
+	envFramework := strings.ToLower(strings.TrimSpace(os.Getenv(env.Framework)))
+	if envFramework == "" {
+		return nil
+	}
+	for _, f := range wantFrameworks {
+		if envFramework == f {
+			return gcp.OptIn(fmt.Sprintf("%s matches %q", env.Framework, f))
+		}
+	}
+	return gcp.OptOut(fmt.Sprintf("%s does not match %q", env.Framework, strings.Join(wantFrameworks, `", "`)))
+}
+
 // FormatName takes in a language name and version and returns the GCF / GAE runtime name.
 //
 // For example, FormatRuntime("go", "1.16.0") returns "go116".
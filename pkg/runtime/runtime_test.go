@@ -69,6 +69,54 @@ func TestCheckOverride(t *testing.T) {
 	}
 }
 
+func TestCheckFrameworkOverride(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envFramework string
+		wantNil      bool
+		wantPass     bool
+	}{
+		{
+			name:    "unset returns nil",
+			wantNil: true,
+		},
+		{
+			name:         "match opts in",
+			envFramework: "react-router",
+			wantPass:     true,
+		},
+		{
+			name:         "match is case insensitive",
+			envFramework: " Remix ",
+			wantPass:     true,
+		},
+		{
+			name:         "mismatch opts out",
+			envFramework: "nextjs",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.envFramework != "" {
+				t.Setenv(env.Framework, tc.envFramework)
+			}
+			got := CheckFrameworkOverride("remix", "react-router")
+			if got == nil {
+				if !tc.wantNil {
+					t.Fatalf("CheckFrameworkOverride() with %s=%q = nil, want a result", env.Framework, tc.envFramework)
+				}
+				return
+			}
+			if tc.wantNil {
+				t.Fatalf("CheckFrameworkOverride() with %s=%q = %v, want nil", env.Framework, tc.envFramework, got)
+			}
+			if got.Result().Pass != tc.wantPass {
+				t.Errorf("CheckFrameworkOverride() with %s=%q pass = %t, want %t", env.Framework, tc.envFramework, got.Result().Pass, tc.wantPass)
+			}
+		})
+	}
+}
+
 func TestRuntimeVersion(t *testing.T) {
 	testCases := []struct {
 		name           string
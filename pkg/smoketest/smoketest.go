@@ -0,0 +1,409 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package smoketest starts the application in the build container after it is built to check it
+// listens on PORT, which catches applications crashing at startup before an image is exported.
+package smoketest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// webProcess is the type of the process started when no process is marked as the default.
+	webProcess = "web"
+	// defaultPort is the PORT the application listens on unless PORT is set for the build.
+	defaultPort = "8080"
+	// defaultTimeout is how long the application has to listen on PORT.
+	defaultTimeout = 30 * time.Second
+	// pollInterval is the interval between attempts to connect to the application.
+	pollInterval = 250 * time.Millisecond
+	// requestTimeout is how long the request to the smoke test path may take.
+	requestTimeout = 10 * time.Second
+	// stopTimeout is how long the application has to exit after SIGTERM before it is killed.
+	stopTimeout = 5 * time.Second
+	// outputLimit is the number of bytes of the application output included in errors.
+	outputLimit = 4096
+)
+
+// Process is a process of the application image.
+type Process struct {
+	Type             string
+	Command          []string
+	Direct           bool
+	WorkingDirectory string
+}
+
+// Options configures the smoke test.
+type Options struct {
+	// Port is the value of PORT the application is started with.
+	Port string
+	// Path is requested once the application listens on PORT, if set.
+	Path string
+	// Timeout is how long the application has to listen on PORT.
+	Timeout time.Duration
+}
+
+// OptionsFromEnv returns the smoke test options set with env.SmokeTestPath and
+// env.SmokeTestTimeout.
+func OptionsFromEnv() (Options, error) {
+	opts := Options{Port: os.Getenv("PORT"), Path: os.Getenv(env.SmokeTestPath), Timeout: defaultTimeout}
+	if opts.Port == "" {
+		opts.Port = defaultPort
+	}
+	if opts.Path != "" && !strings.HasPrefix(opts.Path, "/") {
+		opts.Path = "/" + opts.Path
+	}
+	if t := os.Getenv(env.SmokeTestTimeout); t != "" {
+		timeout, err := time.ParseDuration(t)
+		if err != nil || timeout <= 0 {
+			return Options{}, gcp.UserErrorf("invalid %s %q, it must be a positive duration such as 30s", env.SmokeTestTimeout, t)
+		}
+		opts.Timeout = timeout
+	}
+	return opts, nil
+}
+
+type launchTOML struct {
+	Processes []struct {
+		Type             string   `toml:"type"`
+		Command          any      `toml:"command"`
+		Args             []string `toml:"args"`
+		Direct           bool     `toml:"direct"`
+		Default          bool     `toml:"default"`
+		WorkingDirectory string   `toml:"working-directory"`
+	} `toml:"processes"`
+}
+
+// DefaultProcess returns the process the application image starts by default, from the launch.toml
+// written by each buildpack in layersRoot, the directory which contains the layers directory of
+// each buildpack. Processes set by later buildpacks override those of the same type set earlier.
+// It returns nil if no default or web process is set.
+func DefaultProcess(layersRoot string) (*Process, error) {
+	dirs, err := buildpackDirs(layersRoot)
+	if err != nil {
+		return nil, err
+	}
+	processes := map[string]*Process{}
+	defaultType := webProcess
+	for _, dir := range dirs {
+		path := filepath.Join(dir, "launch.toml")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		var launch launchTOML
+		if _, err := toml.DecodeFile(path, &launch); err != nil {
+			return nil, gcp.InternalErrorf("decoding %s: %w", path, err)
+		}
+		for _, p := range launch.Processes {
+			var cmd []string
+			switch c := p.Command.(type) {
+			case string:
+				cmd = []string{c}
+			case []any:
+				for _, arg := range c {
+					cmd = append(cmd, fmt.Sprint(arg))
+				}
+			}
+			if len(cmd) == 0 {
+				return nil, gcp.InternalErrorf("process %q in %s has no command", p.Type, path)
+			}
+			processes[p.Type] = &Process{
+				Type:             p.Type,
+				Command:          append(cmd, p.Args...),
+				Direct:           p.Direct,
+				WorkingDirectory: p.WorkingDirectory,
+			}
+			if p.Default {
+				defaultType = p.Type
+			}
+		}
+	}
+	return processes[defaultType], nil
+}
+
+// buildpackDirs returns the layers directories of the buildpacks in layersRoot in the order the
+// buildpacks ran, which is read from group.toml. Directories missing from it are sorted by name.
+func buildpackDirs(layersRoot string) ([]string, error) {
+	entries, err := os.ReadDir(layersRoot)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading layers directory %s: %w", layersRoot, err)
+	}
+	order := map[string]int{}
+	var group struct {
+		Group []struct {
+			ID string `toml:"id"`
+		} `toml:"group"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(layersRoot, "group.toml"), &group); err != nil && !os.IsNotExist(err) {
+		return nil, gcp.InternalErrorf("decoding group.toml: %w", err)
+	}
+	for i, bp := range group.Group {
+		order[strings.ReplaceAll(bp.ID, "/", "_")] = i + 1
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		oi, oj := order[names[i]], order[names[j]]
+		if oi == 0 || oj == 0 {
+			if oi == oj {
+				return names[i] < names[j]
+			}
+			return oi != 0
+		}
+		return oi < oj
+	})
+	var dirs []string
+	for _, n := range names {
+		dirs = append(dirs, filepath.Join(layersRoot, n))
+	}
+	return dirs, nil
+}
+
+// LaunchEnv returns environ with the environment of the launch layers in layersRoot applied, as
+// the launcher does when it starts the given process type. The environment of layers which are
+// also build layers is already set in the build container, so only their launch specific
+// environment is applied.
+func LaunchEnv(layersRoot, processType string, environ []string) ([]string, error) {
+	vars := map[string]string{}
+	for _, e := range environ {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			vars[k] = v
+		}
+	}
+	dirs, err := buildpackDirs(layersRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		layerTOMLs, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(layerTOMLs)
+		for _, layerTOML := range layerTOMLs {
+			var layer struct {
+				Types struct {
+					Build  bool `toml:"build"`
+					Launch bool `toml:"launch"`
+				} `toml:"types"`
+			}
+			if _, err := toml.DecodeFile(layerTOML, &layer); err != nil {
+				return nil, gcp.InternalErrorf("decoding layer metadata %s: %w", layerTOML, err)
+			}
+			if !layer.Types.Launch {
+				continue
+			}
+			layerDir := strings.TrimSuffix(layerTOML, ".toml")
+			envDirs := []string{"env.launch", filepath.Join("env.launch", processType)}
+			if !layer.Types.Build {
+				prependDir(vars, "PATH", filepath.Join(layerDir, "bin"))
+				prependDir(vars, "LD_LIBRARY_PATH", filepath.Join(layerDir, "lib"))
+				envDirs = append([]string{"env"}, envDirs...)
+			}
+			for _, d := range envDirs {
+				if err := applyEnvDir(vars, filepath.Join(layerDir, d)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	var result []string
+	for k, v := range vars {
+		result = append(result, k+"="+v)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func prependDir(vars map[string]string, name, dir string) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return
+	}
+	if vars[name] == "" {
+		vars[name] = dir
+		return
+	}
+	vars[name] = dir + string(os.PathListSeparator) + vars[name]
+}
+
+// applyEnvDir applies the variables of a layer env directory. Files without a suffix override the
+// variable, as with the .override suffix.
+func applyEnvDir(vars map[string]string, dir string) error {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return gcp.InternalErrorf("reading env directory %s: %w", dir, err)
+	}
+	for _, f := range files {
+		if f.IsDir() || strings.HasSuffix(f.Name(), ".delim") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return gcp.InternalErrorf("reading %s: %w", f.Name(), err)
+		}
+		value := string(raw)
+		name, suffix, _ := strings.Cut(f.Name(), ".")
+		delim, _ := os.ReadFile(filepath.Join(dir, name+".delim"))
+		current, set := vars[name]
+		switch suffix {
+		case "default":
+			if !set {
+				vars[name] = value
+			}
+		case "append":
+			if set && current != "" {
+				value = current + string(delim) + value
+			}
+			vars[name] = value
+		case "prepend":
+			if set && current != "" {
+				value = value + string(delim) + current
+			}
+			vars[name] = value
+		default:
+			vars[name] = value
+		}
+	}
+	return nil
+}
+
+// Run starts the process with the given environment and waits for it to listen on the port set in
+// opts, then requests the path set in opts if any. The process is stopped before Run returns. It
+// returns an error if the process exits, does not listen on the port before the timeout, or the
+// response status is 400 or higher.
+func Run(ctx *gcp.Context, p *Process, environ []string, opts Options) error {
+	args := p.Command
+	if !p.Direct {
+		args = []string{"bash", "-c", strings.Join(p.Command, " ")}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = ctx.ApplicationRoot()
+	if p.WorkingDirectory != "" {
+		cmd.Dir = p.WorkingDirectory
+	}
+	cmd.Env = append(environ, "PORT="+opts.Port)
+	out := &tailWriter{limit: outputLimit}
+	cmd.Stdout, cmd.Stderr = out, out
+	// Do not wait for the output of processes started in the background once the process exits.
+	cmd.WaitDelay = time.Second
+	// Start the process in its own process group so that the processes it starts are stopped too.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	ctx.Logf("Starting the %s process to smoke test it on port %s: %s", p.Type, opts.Port, strings.Join(p.Command, " "))
+	if err := cmd.Start(); err != nil {
+		return gcp.UserErrorf("starting the %s process for the smoke test: %v", p.Type, err)
+	}
+	exited := make(chan struct{})
+	var waitErr error
+	go func() {
+		waitErr = cmd.Wait()
+		close(exited)
+	}()
+	defer stop(cmd, exited)
+
+	addr := net.JoinHostPort("127.0.0.1", opts.Port)
+	deadline := time.After(opts.Timeout)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for listening := false; !listening; {
+		select {
+		case <-exited:
+			return gcp.UserErrorf("the %s process exited during the smoke test (%v), output:\n%s", p.Type, waitErr, out)
+		case <-deadline:
+			return gcp.UserErrorf("the %s process did not listen on port %s within %v, output:\n%s", p.Type, opts.Port, opts.Timeout, out)
+		case <-ticker.C:
+		}
+		if conn, err := net.DialTimeout("tcp", addr, pollInterval); err == nil {
+			conn.Close()
+			listening = true
+		}
+	}
+
+	if opts.Path != "" {
+		reqCtx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, "http://"+addr+opts.Path, nil)
+		if err != nil {
+			return gcp.UserErrorf("invalid %s %q: %v", env.SmokeTestPath, opts.Path, err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return gcp.UserErrorf("requesting %s during the smoke test: %v, output:\n%s", opts.Path, err, out)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return gcp.UserErrorf("requesting %s during the smoke test returned status %d, output:\n%s", opts.Path, resp.StatusCode, out)
+		}
+		ctx.Logf("Smoke test request to %s returned status %d", opts.Path, resp.StatusCode)
+	}
+	ctx.Logf("Smoke test passed, the %s process is listening on port %s", p.Type, opts.Port)
+	return nil
+}
+
+// stop terminates the process group of cmd and kills it if it does not exit in time.
+func stop(cmd *exec.Cmd, exited <-chan struct{}) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(stopTimeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-exited
+	}
+}
+
+// tailWriter keeps the last bytes written to it, up to limit.
+type tailWriter struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.limit {
+		w.buf = w.buf[len(w.buf)-w.limit:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.buf)
+}
@@ -0,0 +1,280 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smoketest
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+// helperEnv selects the behavior of the test binary when it is started as the application.
+const helperEnv = "SMOKETEST_HELPER"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "serve":
+		http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		http.ListenAndServe(":"+os.Getenv("PORT"), nil)
+	case "crash":
+		os.Stderr.WriteString("cannot connect to the database\n")
+		os.Exit(1)
+	case "sleep":
+		time.Sleep(time.Minute)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDefaultProcess(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  *Process
+	}{
+		{
+			name: "no processes",
+			files: map[string]string{
+				"google.nodejs.runtime/node.toml": "",
+			},
+		},
+		{
+			name: "web process",
+			files: map[string]string{
+				"google.nodejs.npm/launch.toml": `[[processes]]
+type = "web"
+command = "npm"
+args = ["start"]
+direct = true
+`,
+			},
+			want: &Process{Type: "web", Command: []string{"npm", "start"}, Direct: true},
+		},
+		{
+			name: "later buildpacks override processes",
+			files: map[string]string{
+				"group.toml": `[[group]]
+id = "google.utils.z-first"
+version = "0.0.1"
+
+[[group]]
+id = "google.config.entrypoint"
+version = "0.0.1"
+`,
+				"google.utils.z-first/launch.toml": `[[processes]]
+type = "web"
+command = "first"
+`,
+				"google.config.entrypoint/launch.toml": `[[processes]]
+type = "web"
+command = "gunicorn -b :$PORT main:app"
+working-directory = "/workspace/app"
+`,
+			},
+			want: &Process{Type: "web", Command: []string{"gunicorn -b :$PORT main:app"}, WorkingDirectory: "/workspace/app"},
+		},
+		{
+			name: "default process",
+			files: map[string]string{
+				"google.php.webconfig/launch.toml": `[[processes]]
+type = "web"
+command = "nginx"
+
+[[processes]]
+type = "serve"
+command = ["php", "artisan", "serve"]
+default = true
+`,
+			},
+			want: &Process{Type: "serve", Command: []string{"php", "artisan", "serve"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			got, err := DefaultProcess(dir)
+			if err != nil {
+				t.Fatalf("DefaultProcess() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("DefaultProcess() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLaunchEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"google.python.pip/deps.toml":                        "[types]\nlaunch = true\n",
+		"google.python.pip/deps/bin/gunicorn":                "",
+		"google.python.pip/deps/env/PYTHONPATH.prepend":      "/layers/deps",
+		"google.python.pip/deps/env/PYTHONPATH.delim":        ":",
+		"google.python.pip/deps/env.launch/WORKERS.default":  "4",
+		"google.python.pip/deps/env.launch/web/MODE":         "web",
+		"google.python.pip/deps/env.launch/worker/MODE":      "worker",
+		"google.python.runtime/python.toml":                  "[types]\nbuild = true\nlaunch = true\n",
+		"google.python.runtime/python/env/PYTHONHOME":        "/layers/python",
+		"google.python.runtime/python/env.launch/LANG":       "C.UTF-8",
+		"google.python.runtime/cache.toml":                   "[types]\ncache = true\n",
+		"google.python.runtime/cache/env.launch/CACHE":       "true",
+		"google.python.webserver/server.toml":                "[types]\nlaunch = true\n",
+		"google.python.webserver/server/env/WORKERS.default": "8",
+	})
+	got, err := LaunchEnv(dir, "web", []string{"PATH=/usr/bin", "PYTHONPATH=/workspace"})
+	if err != nil {
+		t.Fatalf("LaunchEnv() got error: %v", err)
+	}
+	want := []string{
+		"LANG=C.UTF-8",
+		"MODE=web",
+		"PATH=" + filepath.Join(dir, "google.python.pip/deps/bin") + ":/usr/bin",
+		"PYTHONPATH=/layers/deps:/workspace",
+		"WORKERS=4",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LaunchEnv() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    Options
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: Options{Port: "8080", Timeout: 30 * time.Second},
+		},
+		{
+			name: "all set",
+			env:  map[string]string{"PORT": "3000", "GOOGLE_SMOKE_TEST_PATH": "healthz", "GOOGLE_SMOKE_TEST_TIMEOUT": "2m"},
+			want: Options{Port: "3000", Path: "/healthz", Timeout: 2 * time.Minute},
+		},
+		{
+			name:    "invalid timeout",
+			env:     map[string]string{"GOOGLE_SMOKE_TEST_TIMEOUT": "30"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("PORT", "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			got, err := OptionsFromEnv()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("OptionsFromEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("OptionsFromEnv() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name       string
+		helper     string
+		path       string
+		wantErrMsg string
+	}{
+		{
+			name:   "listens on port",
+			helper: "serve",
+		},
+		{
+			name:   "path succeeds",
+			helper: "serve",
+			path:   "/healthz",
+		},
+		{
+			name:       "path fails",
+			helper:     "serve",
+			path:       "/",
+			wantErrMsg: "returned status 500",
+		},
+		{
+			name:       "crashes at startup",
+			helper:     "crash",
+			wantErrMsg: "cannot connect to the database",
+		},
+		{
+			name:       "does not listen",
+			helper:     "sleep",
+			wantErrMsg: "did not listen on port",
+		},
+	}
+	helper, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+			p := &Process{Type: "web", Command: []string{helper}, Direct: true}
+			opts := Options{Port: freePort(t), Path: tc.path, Timeout: 2 * time.Second}
+			err := Run(ctx, p, append(os.Environ(), helperEnv+"="+tc.helper), opts)
+			if tc.wantErrMsg == "" {
+				if err != nil {
+					t.Fatalf("Run() got error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErrMsg) {
+				t.Errorf("Run() got error: %v, want error containing %q", err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
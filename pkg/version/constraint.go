@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// PrereleasePolicy controls whether prerelease versions, such as 1.2.0-rc.1, are selected.
+type PrereleasePolicy int
+
+const (
+	// PrereleasesIfRequested selects prerelease versions only if a constraint includes a prerelease,
+	// for example `>=1.2.0-0`. This is the default.
+	PrereleasesIfRequested PrereleasePolicy = iota
+	// IncludePrereleases selects prerelease versions like any other version, comparing them as the
+	// release they precede.
+	IncludePrereleases
+	// ExcludePrereleases never selects prerelease versions.
+	ExcludePrereleases
+)
+
+// WithPrereleasePolicy sets the policy used to select prerelease versions.
+func WithPrereleasePolicy(p PrereleasePolicy) ResolveVersionOption {
+	return func(o *resolveParams) {
+		o.prereleases = p
+	}
+}
+
+// SkipInvalidVersions indicates versions which are not valid semantic versions are ignored instead
+// of returning an error.
+var SkipInvalidVersions = func(o *resolveParams) {
+	o.skipInvalid = true
+}
+
+// Constraint is the intersection of one or more version constraints. A version satisfies it if it
+// satisfies all of them.
+type Constraint struct {
+	raw         []string
+	constraints []*semver.Constraints
+}
+
+// Intersect parses the given constraints into a Constraint satisfied by versions which satisfy all
+// of them. Each constraint can itself be a union, for example `^14.0.0 || ^15.0.0`, which cannot
+// be expressed as a single constraint string once combined with another one. Empty constraints are
+// ignored, and a Constraint with no constraints is satisfied by any version.
+func Intersect(constraints ...string) (*Constraint, error) {
+	c := &Constraint{}
+	for _, s := range constraints {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		parsed, err := semver.NewConstraint(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing version constraint %q: %w", s, err)
+		}
+		c.raw = append(c.raw, s)
+		c.constraints = append(c.constraints, parsed)
+	}
+	return c, nil
+}
+
+// Check returns true if the version satisfies all the constraints.
+func (c *Constraint) Check(v *semver.Version) bool {
+	for _, cs := range c.constraints {
+		if !cs.Check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the constraints joined by " and ".
+func (c *Constraint) String() string {
+	if len(c.raw) == 0 {
+		return "*"
+	}
+	return strings.Join(c.raw, " and ")
+}
+
+// HighestSatisfying returns the highest version in versions satisfying the constraint. Prerelease
+// versions are selected according to WithPrereleasePolicy.
+func HighestSatisfying(c *Constraint, versions []string, opts ...ResolveVersionOption) (string, error) {
+	params := resolveParams{}
+	for _, o := range opts {
+		o(&params)
+	}
+	var semvers []*semver.Version
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			if params.skipInvalid {
+				continue
+			}
+			return "", err
+		}
+		semvers = append(semvers, v)
+	}
+
+	// Sort in descending order so that the first version in the list to satisfy a constraint will be
+	// the highest possible version.
+	sort.Sort(sort.Reverse(semver.Collection(semvers)))
+	for _, v := range semvers {
+		if !satisfies(c, v, params.prereleases) {
+			continue
+		}
+		if params.noSanitize {
+			return v.Original(), nil
+		}
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("failed to resolve version matching: %v", c)
+}
+
+func satisfies(c *Constraint, v *semver.Version, policy PrereleasePolicy) bool {
+	if v.Prerelease() == "" {
+		return c.Check(v)
+	}
+	switch policy {
+	case ExcludePrereleases:
+		return false
+	case IncludePrereleases:
+		release, err := v.SetPrerelease("")
+		if err != nil {
+			return false
+		}
+		return c.Check(&release)
+	}
+	// Like `*`, an empty constraint does not request prereleases.
+	return len(c.constraints) > 0 && c.Check(v)
+}
+
+// MajorMinor returns the major and minor version of an exact semantic version, for example 14.2
+// for 14.2.3. Build adaptors are versioned after the major and minor version of their framework.
+func MajorMinor(version string) (string, error) {
+	v, err := semver.StrictNewVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%d", v.Major(), v.Minor()), nil
+}
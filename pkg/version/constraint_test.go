@@ -0,0 +1,191 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"testing"
+)
+
+func TestIntersect(t *testing.T) {
+	testCases := []struct {
+		name        string
+		constraints []string
+		version     string
+		want        bool
+	}{
+		{
+			name:        "satisfies all",
+			constraints: []string{"^14.0.0", ">=14.1.0"},
+			version:     "14.2.3",
+			want:        true,
+		},
+		{
+			name:        "fails one",
+			constraints: []string{"^14.0.0", ">=14.1.0"},
+			version:     "14.0.5",
+			want:        false,
+		},
+		{
+			name:        "union intersected with range",
+			constraints: []string{"^13.0.0 || ^14.0.0", "<14.1.0"},
+			version:     "13.5.6",
+			want:        true,
+		},
+		{
+			name:        "union intersected with range excludes newer",
+			constraints: []string{"^13.0.0 || ^14.0.0", "<14.1.0"},
+			version:     "14.2.0",
+			want:        false,
+		},
+		{
+			name:        "empty constraints ignored",
+			constraints: []string{"", "^14.0.0"},
+			version:     "14.2.3",
+			want:        true,
+		},
+		{
+			name:    "no constraints",
+			version: "1.0.0",
+			want:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Intersect(tc.constraints...)
+			if err != nil {
+				t.Fatalf("Intersect(%v) got error: %v", tc.constraints, err)
+			}
+			got, err := HighestSatisfying(c, []string{tc.version})
+			if gotOK := err == nil; gotOK != tc.want {
+				t.Errorf("HighestSatisfying(%v, %q) = %q, %v, want match: %v", c, tc.version, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestIntersectInvalid(t *testing.T) {
+	if _, err := Intersect("^14.0.0", "not a version"); err == nil {
+		t.Error("Intersect() got no error, want error")
+	}
+}
+
+func TestHighestSatisfying(t *testing.T) {
+	versions := []string{"14.1.0", "14.2.0-canary.3", "14.2.0", "15.0.0-rc.1", "v14.1.4", "latest"}
+	testCases := []struct {
+		name        string
+		constraints []string
+		opts        []ResolveVersionOption
+		want        string
+		wantError   bool
+	}{
+		{
+			name:        "invalid versions",
+			constraints: []string{"^14.0.0"},
+			wantError:   true,
+		},
+		{
+			name:        "skip invalid versions",
+			constraints: []string{"^14.0.0"},
+			opts:        []ResolveVersionOption{SkipInvalidVersions},
+			want:        "14.2.0",
+		},
+		{
+			name:        "prerelease requested by constraint",
+			constraints: []string{">=15.0.0-0"},
+			opts:        []ResolveVersionOption{SkipInvalidVersions},
+			want:        "15.0.0-rc.1",
+		},
+		{
+			name:        "prerelease not requested",
+			constraints: []string{">=15.0.0"},
+			opts:        []ResolveVersionOption{SkipInvalidVersions},
+			wantError:   true,
+		},
+		{
+			name:        "include prereleases",
+			constraints: []string{"^15.0.0"},
+			opts:        []ResolveVersionOption{SkipInvalidVersions, WithPrereleasePolicy(IncludePrereleases)},
+			want:        "15.0.0-rc.1",
+		},
+		{
+			name:        "exclude prereleases",
+			constraints: []string{">=15.0.0-0"},
+			opts:        []ResolveVersionOption{SkipInvalidVersions, WithPrereleasePolicy(ExcludePrereleases)},
+			wantError:   true,
+		},
+		{
+			name:        "intersection without sanitization",
+			constraints: []string{"^14.0.0", "<14.2.0"},
+			opts:        []ResolveVersionOption{SkipInvalidVersions, WithoutSanitization},
+			want:        "v14.1.4",
+		},
+		{
+			name: "no constraints excludes prereleases",
+			opts: []ResolveVersionOption{SkipInvalidVersions},
+			want: "14.2.0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Intersect(tc.constraints...)
+			if err != nil {
+				t.Fatalf("Intersect(%v) got error: %v", tc.constraints, err)
+			}
+			got, err := HighestSatisfying(c, versions, tc.opts...)
+			if tc.wantError != (err != nil) {
+				t.Errorf("HighestSatisfying(%v) got error: %v, want error?: %v", c, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("HighestSatisfying(%v) = %q, want %q", c, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMajorMinor(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{
+			version: "14.2.3",
+			want:    "14.2",
+		},
+		{
+			version: "15.0.0-canary.12",
+			want:    "15.0",
+		},
+		{
+			version: "14.2",
+			wantErr: true,
+		},
+		{
+			version: "^14.2.3",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			got, err := MajorMinor(tc.version)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("MajorMinor(%q) got error: %v, want error: %v", tc.version, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("MajorMinor(%q) = %q, want %q", tc.version, got, tc.want)
+			}
+		})
+	}
+}
@@ -16,16 +16,16 @@
 package version
 
 import (
-	"fmt"
 	"regexp"
-	"sort"
 	"strings"
 
 	"github.com/Masterminds/semver"
 )
 
 type resolveParams struct {
-	noSanitize bool
+	noSanitize  bool
+	skipInvalid bool
+	prereleases PrereleasePolicy
 }
 
 // ResolveVersionOption configures ResolveVersion.
@@ -39,41 +39,12 @@ var WithoutSanitization = func(o *resolveParams) {
 // ResolveVersion finds the largest version in a list of semantic versions that satisfies the
 // provided constraint. If no version in the list satisfies the constraint it returns an error.
 func ResolveVersion(constraint string, versions []string, opts ...ResolveVersionOption) (string, error) {
-	params := resolveParams{}
-	for _, o := range opts {
-		o(&params)
-	}
-	if constraint == "" {
-		// use the latest version if no constraint was provided
-		constraint = "*"
-	}
-	c, err := semver.NewConstraint(constraint)
+	// An empty constraint resolves to the latest version.
+	c, err := Intersect(constraint)
 	if err != nil {
 		return "", err
 	}
-
-	semvers := make([]*semver.Version, len(versions))
-	for i, version := range versions {
-		s, err := semver.NewVersion(version)
-		if err != nil {
-			return "", err
-		}
-		semvers[i] = s
-	}
-
-	// Sort in descending order so that the first version in the list to satisfy a constraint will be
-	// the highest possible version.
-	sort.Sort(sort.Reverse(semver.Collection(semvers)))
-	for _, s := range semvers {
-		if c.Check(s) {
-			if params.noSanitize {
-				return s.Original(), nil
-			}
-			return s.String(), nil
-		}
-	}
-
-	return "", fmt.Errorf("failed to resolve version matching: %v", c)
+	return HighestSatisfying(c, versions, opts...)
 }
 
 // IsExactSemver returns true if a given string is valid semantic version.
@@ -0,0 +1,304 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package warmup runs warm-up actions against an application when its container starts, once the
+// application listens.
+//
+// By default the application listens on PORT and the warm-up runs against it, so the instance may
+// be reported ready and receive requests before the warm-up completes. With env.WarmupProxy the
+// application listens on an internal port instead and PORT only accepts connections after the
+// warm-up, at the cost of a userspace TCP proxy in front of every connection for the lifetime of
+// the container: it adds latency and memory per connection, and the application sees all
+// connections coming from the loopback address.
+package warmup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	defaultPort    = "8080"
+	defaultTimeout = 60 * time.Second
+	pollInterval   = 100 * time.Millisecond
+)
+
+// Config is the warm-up configuration of the application.
+type Config struct {
+	// URLs are the paths requested once the application listens.
+	URLs []string
+	// Command is a shell command run once the application listens.
+	Command string
+	// Timeout is how long the application has to listen and the warm-up actions have to complete.
+	Timeout time.Duration
+	// Proxy is true if PORT only accepts connections after the warm-up, through a proxy to the
+	// application.
+	Proxy bool
+}
+
+// Enabled returns true if the configuration has any warm-up action.
+func (c Config) Enabled() bool {
+	return len(c.URLs) > 0 || c.Command != ""
+}
+
+// ConfigFromEnv returns the configuration set with env.WarmupURLs, env.WarmupCommand,
+// env.WarmupTimeout and env.WarmupProxy.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Command: strings.TrimSpace(os.Getenv(env.WarmupCommand)), Timeout: defaultTimeout}
+	for _, u := range strings.Split(os.Getenv(env.WarmupURLs), ",") {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		if !strings.HasPrefix(u, "/") {
+			u = "/" + u
+		}
+		cfg.URLs = append(cfg.URLs, u)
+	}
+	if t := os.Getenv(env.WarmupTimeout); t != "" {
+		timeout, err := time.ParseDuration(t)
+		if err != nil || timeout <= 0 {
+			return Config{}, gcp.UserErrorf("invalid %s %q, it must be a positive duration such as 60s", env.WarmupTimeout, t)
+		}
+		cfg.Timeout = timeout
+	}
+	if p := os.Getenv(env.WarmupProxy); p != "" {
+		proxy, err := strconv.ParseBool(p)
+		if err != nil {
+			return Config{}, gcp.UserErrorf("invalid %s %q, it must be true or false", env.WarmupProxy, p)
+		}
+		cfg.Proxy = proxy
+	}
+	return cfg, nil
+}
+
+// Main runs the command in os.Args after `--` with Serve and exits with its exit code.
+func Main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		log.Fatalf("Usage: %s -- <command> [args...]", os.Args[0])
+	}
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = defaultPort
+	}
+	code, err := Serve(cfg, args, port)
+	if err != nil {
+		log.Print(err)
+	}
+	os.Exit(code)
+}
+
+// Serve starts the application and runs the warm-up actions once it listens on port. With
+// cfg.Proxy, PORT is set to an internal port for the application instead and the connections to
+// port are proxied to it after the warm-up, so that the instance is only reported ready after the
+// warm-up. Applications which ignore PORT and listen on port themselves, such as nginx with a
+// fixed port, are then left to serve it without the warm-up. Failed warm-up actions are logged and
+// do not stop the application. It returns the exit code of the application.
+func Serve(cfg Config, args []string, port string) (int, error) {
+	internal := port
+	if cfg.Proxy {
+		var err error
+		if internal, err = freePort(); err != nil {
+			return 1, fmt.Errorf("finding a port for the application: %w", err)
+		}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "PORT="+internal)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 1, fmt.Errorf("starting %s: %w", args[0], err)
+	}
+	exited := make(chan struct{})
+	var waitErr error
+	go func() {
+		waitErr = cmd.Wait()
+		close(exited)
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		for s := range signals {
+			cmd.Process.Signal(s)
+		}
+	}()
+
+	addr := net.JoinHostPort("127.0.0.1", internal)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	err := waitForPort(ctx, addr, net.JoinHostPort("127.0.0.1", port), exited)
+	switch {
+	case err == nil:
+		warm(ctx, cfg, "http://"+addr)
+	case errors.Is(err, errPortInUse):
+		log.Printf("Skipping the warm-up: the application listens on port %s itself instead of PORT", port)
+	case !errors.Is(err, errExited):
+		log.Printf("Skipping the warm-up: %v", err)
+	}
+	cancel()
+
+	if !cfg.Proxy {
+		<-exited
+		return exitCode(waitErr), waitErr
+	}
+	select {
+	case <-exited:
+		return exitCode(waitErr), waitErr
+	default:
+	}
+	l, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		// The application most likely listens on the port itself, keep serving it without the proxy.
+		log.Printf("Not proxying port %s to the application: %v", port, err)
+		<-exited
+		return exitCode(waitErr), waitErr
+	}
+	defer l.Close()
+	go proxy(l, addr)
+	<-exited
+	return exitCode(waitErr), waitErr
+}
+
+var (
+	errExited    = errors.New("the application exited")
+	errPortInUse = errors.New("the port is in use")
+)
+
+// waitForPort waits until addr accepts connections. It returns errPortInUse if publicAddr accepts
+// connections first, which means that the application ignores PORT.
+func waitForPort(ctx context.Context, addr, publicAddr string, exited <-chan struct{}) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if conn, err := net.DialTimeout("tcp", addr, pollInterval); err == nil {
+			conn.Close()
+			return nil
+		}
+		if conn, err := net.DialTimeout("tcp", publicAddr, pollInterval); err == nil {
+			conn.Close()
+			return errPortInUse
+		}
+		select {
+		case <-exited:
+			return errExited
+		case <-ctx.Done():
+			return fmt.Errorf("the application did not listen within the warm-up timeout")
+		case <-ticker.C:
+		}
+	}
+}
+
+// warm requests the warm-up URLs and runs the warm-up command against baseURL.
+func warm(ctx context.Context, cfg Config, baseURL string) {
+	start := time.Now()
+	for _, u := range cfg.URLs {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+u, nil)
+		if err != nil {
+			log.Printf("Invalid warm-up URL %q: %v", u, err)
+			continue
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Printf("Warm-up request to %s failed: %v", u, err)
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		log.Printf("Warm-up request to %s returned status %d", u, resp.StatusCode)
+	}
+	if cfg.Command != "" {
+		cmd := exec.CommandContext(ctx, "bash", "-c", cfg.Command)
+		cmd.Env = append(os.Environ(), "WARMUP_URL="+baseURL)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("Warm-up command failed: %v", err)
+		}
+	}
+	log.Printf("Warm-up completed in %v", time.Since(start).Round(time.Millisecond))
+}
+
+// proxy forwards the connections accepted by l to addr until l is closed.
+func proxy(l net.Listener, addr string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			upstream, err := net.Dial("tcp", addr)
+			if err != nil {
+				log.Printf("Connecting to the application: %v", err)
+				return
+			}
+			defer upstream.Close()
+			go func() {
+				io.Copy(upstream, conn)
+				// Propagate the end of the request so that the application finishes the response.
+				if c, ok := upstream.(*net.TCPConn); ok {
+					c.CloseWrite()
+				}
+			}()
+			// Both connections are closed once the application closes its side.
+			io.Copy(conn, upstream)
+		}()
+	}
+}
+
+// freePort returns a port of the loopback interface which is not in use.
+func freePort() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	return port, err
+}
+
+// exitCode returns the exit code of a process from the error returned by Wait.
+func exitCode(err error) int {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		if code := ee.ExitCode(); code >= 0 {
+			return code
+		}
+		return 1
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,315 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warmup
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/google/go-cmp/cmp"
+)
+
+// helperEnv selects the behavior of the test binary when it is started as the application.
+const helperEnv = "WARMUP_HELPER"
+
+// requestLogEnv is the file the application started by the test binary logs requests to.
+const requestLogEnv = "WARMUP_REQUEST_LOG"
+
+// fixedPortEnv is the port the application started by the test binary listens on instead of PORT.
+const fixedPortEnv = "WARMUP_FIXED_PORT"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "serve":
+		logRequest := func(line string) {
+			f, err := os.OpenFile(os.Getenv(requestLogEnv), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				os.Exit(2)
+			}
+			f.WriteString(line + "\n")
+			f.Close()
+		}
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			logRequest(r.URL.Path)
+		})
+		http.HandleFunc("/quit", func(w http.ResponseWriter, r *http.Request) {
+			logRequest(r.URL.Path)
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				os.Exit(3)
+			}()
+		})
+		port := os.Getenv("PORT")
+		if p := os.Getenv(fixedPortEnv); p != "" {
+			port = p
+		}
+		http.ListenAndServe(":"+port, nil)
+	case "crash":
+		os.Exit(4)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "unset",
+			want: Config{Timeout: defaultTimeout},
+		},
+		{
+			name: "urls and command",
+			env: map[string]string{
+				env.WarmupURLs:    "/, api/health ,",
+				env.WarmupCommand: "curl -s $WARMUP_URL/graphql",
+				env.WarmupTimeout: "2m",
+			},
+			want: Config{URLs: []string{"/", "/api/health"}, Command: "curl -s $WARMUP_URL/graphql", Timeout: 2 * time.Minute},
+		},
+		{
+			name: "proxy",
+			env: map[string]string{
+				env.WarmupURLs:  "/",
+				env.WarmupProxy: "true",
+			},
+			want: Config{URLs: []string{"/"}, Timeout: defaultTimeout, Proxy: true},
+		},
+		{
+			name:    "invalid timeout",
+			env:     map[string]string{env.WarmupTimeout: "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid proxy",
+			env:     map[string]string{env.WarmupProxy: "sometimes"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{env.WarmupURLs, env.WarmupCommand, env.WarmupTimeout, env.WarmupProxy} {
+				t.Setenv(name, tc.env[name])
+			}
+			got, err := ConfigFromEnv()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ConfigFromEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ConfigFromEnv() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestLog := filepath.Join(t.TempDir(), "requests.log")
+	t.Setenv(helperEnv, "serve")
+	t.Setenv(requestLogEnv, requestLog)
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		URLs:    []string{"/", "/warm"},
+		Command: `curl -s "$WARMUP_URL/command"`,
+		Timeout: 30 * time.Second,
+		Proxy:   true,
+	}
+
+	type result struct {
+		code int
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		code, err := Serve(cfg, []string{exe}, port)
+		done <- result{code, err}
+	}()
+
+	addr := net.JoinHostPort("127.0.0.1", port)
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Serve() did not listen on port %s", port)
+		}
+		time.Sleep(pollInterval)
+	}
+	// The warm-up is complete before the port accepts connections.
+	raw, err := os.ReadFile(requestLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"/", "/warm", "/command"}, strings.Fields(string(raw))); diff != "" {
+		t.Errorf("warm-up requests mismatch (-want +got):\n%s", diff)
+	}
+
+	resp, err := http.Get("http://" + addr + "/quit")
+	if err != nil {
+		t.Fatalf("requesting the application through the proxy: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("request through the proxy returned status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	select {
+	case r := <-done:
+		if r.code != 3 {
+			t.Errorf("Serve() = %d, %v, want exit code 3", r.code, r.err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Serve() did not return after the application exited")
+	}
+}
+
+func TestServeWithoutProxy(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestLog := filepath.Join(t.TempDir(), "requests.log")
+	t.Setenv(helperEnv, "serve")
+	t.Setenv(requestLogEnv, requestLog)
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		code int
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		code, err := Serve(Config{URLs: []string{"/warm"}, Timeout: 30 * time.Second}, []string{exe}, port)
+		done <- result{code, err}
+	}()
+
+	// The application listens on port itself and is warmed up there.
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		raw, err := os.ReadFile(requestLog)
+		if err == nil && strings.Contains(string(raw), "/warm") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the application was not warmed up on port %s", port)
+		}
+		time.Sleep(pollInterval)
+	}
+	resp, err := http.Get("http://" + net.JoinHostPort("127.0.0.1", port) + "/quit")
+	if err != nil {
+		t.Fatalf("requesting the application: %v", err)
+	}
+	resp.Body.Close()
+	select {
+	case r := <-done:
+		if r.code != 3 {
+			t.Errorf("Serve() = %d, %v, want exit code 3", r.code, r.err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Serve() did not return after the application exited")
+	}
+}
+
+func TestServeApplicationExits(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(helperEnv, "crash")
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := Serve(Config{URLs: []string{"/"}, Timeout: 30 * time.Second}, []string{exe}, port)
+	if code != 4 {
+		t.Errorf("Serve() = %d, want the exit code 4 of the application", code)
+	}
+}
+
+func TestServeApplicationIgnoresPort(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestLog := filepath.Join(t.TempDir(), "requests.log")
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(helperEnv, "serve")
+	t.Setenv(requestLogEnv, requestLog)
+	t.Setenv(fixedPortEnv, port)
+
+	type result struct {
+		code int
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		code, err := Serve(Config{URLs: []string{"/warm"}, Timeout: 30 * time.Second, Proxy: true}, []string{exe}, port)
+		done <- result{code, err}
+	}()
+
+	addr := net.JoinHostPort("127.0.0.1", port)
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		resp, err := http.Get("http://" + addr + "/quit")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the application did not listen on port %s", port)
+		}
+		time.Sleep(pollInterval)
+	}
+	select {
+	case r := <-done:
+		if r.code != 3 {
+			t.Errorf("Serve() = %d, %v, want exit code 3 of the application", r.code, r.err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Serve() did not return after the application exited")
+	}
+	raw, err := os.ReadFile(requestLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"/quit"}, strings.Fields(string(raw))); diff != "" {
+		t.Errorf("requests mismatch, want no warm-up requests (-want +got):\n%s", diff)
+	}
+}
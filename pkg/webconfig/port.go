@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PortExecD is the name of the exec.d executable which expands the PORT placeholder of the
+	// user-provided config files at launch.
+	PortExecD = "php-overrides-port"
+	// DefaultPort is the port the PORT placeholder is expanded to when PORT is not set.
+	DefaultPort = "8080"
+)
+
+// LaunchOverridesDir is the directory the PortExecD executable writes the config files with the
+// PORT placeholder expanded to. It is only writable at launch, the layers may not be.
+var LaunchOverridesDir = "/tmp/php_overrides"
+
+// portExecDTemplate is an exec.d executable which fails the launch if PORT is not a number, as
+// nginx would otherwise fail with a less helpful error.
+const portExecDTemplate = `#!/bin/sh
+port="${PORT:-%[1]s}"
+case "$port" in
+  ''|*[!0-9]*)
+    echo "PORT must be a number, got '$port'" >&2
+    exit 1
+    ;;
+esac
+dir='%[2]s'
+mkdir -p "$dir" || exit 1
+`
+
+// WritePortExecD writes the exec.d executable which writes the templates to LaunchOverridesDir at
+// launch, with the PORT placeholder expanded to the PORT env var, to the given exec.d directory of
+// a launch layer. Each template is written to the file of the same name.
+func WritePortExecD(execDir string, templates []string) error {
+	return WriteNamedPortExecD(execDir, PortExecD, LaunchOverridesDir, templates)
+}
+
+// WriteNamedPortExecD is like WritePortExecD for the exec.d executable called name, which writes
+// the templates to launchDir. It lets the config files generated by other buildpacks listen on
+// PORT too.
+func WriteNamedPortExecD(execDir, name, launchDir string, templates []string) error {
+	if err := os.MkdirAll(execDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(execDir, name)
+	if err := os.WriteFile(path, []byte(portScript(launchDir, templates)), 0755); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// portScript returns the exec.d executable writing the templates to dir.
+func portScript(dir string, templates []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, portExecDTemplate, DefaultPort, dir)
+	for _, t := range templates {
+		// The paths are in the layers, which do not contain quotes.
+		fmt.Fprintf(&b, "sed \"s/[$]{%s}/$port/g\" '%s' > \"$dir/%s\" || exit 1\n", PlaceholderPort, t, filepath.Base(t))
+	}
+	return b.String()
+}
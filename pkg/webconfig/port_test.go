@@ -0,0 +1,75 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconfig
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPortScript(t *testing.T) {
+	testCases := []struct {
+		name    string
+		port    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "port set",
+			port: "9090",
+			want: "listen 9090;\nlisten [::]:9090;\nset $name ${NAME};\n",
+		},
+		{
+			name: "port unset",
+			want: "listen 8080;\nlisten [::]:8080;\nset $name ${NAME};\n",
+		},
+		{
+			name:    "invalid port",
+			port:    "80; root /",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := filepath.Join(t.TempDir(), "nginx-app.conf")
+			if err := os.WriteFile(tmpl, []byte("listen ${PORT};\nlisten [::]:${PORT};\nset $name ${NAME};\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			dir := filepath.Join(t.TempDir(), "overrides")
+			script := filepath.Join(t.TempDir(), PortExecD)
+			if err := os.WriteFile(script, []byte(portScript(dir, []string{tmpl})), 0755); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("sh", script)
+			cmd.Env = append(os.Environ(), "PORT="+tc.port)
+			out, err := cmd.CombinedOutput()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("running %s got error: %v, want error: %t\n%s", PortExecD, err, tc.wantErr, out)
+			}
+			if tc.wantErr {
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(dir, "nginx-app.conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("nginx-app.conf = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
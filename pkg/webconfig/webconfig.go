@@ -2,10 +2,17 @@
 package webconfig
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 	"github.com/buildpacks/libcnb"
 )
@@ -24,6 +31,36 @@ const (
 	defaultPHPIni = "php.ini"
 )
 
+// Placeholders expanded in the user-provided nginx and php-fpm config files.
+const (
+	// PlaceholderPort is the port set in the PORT env var at launch, DefaultPort if it is not set.
+	PlaceholderPort = "PORT"
+	// PlaceholderDocumentRoot is the absolute path of the document root.
+	PlaceholderDocumentRoot = "DOCUMENT_ROOT"
+	// PlaceholderPHPFPMSocket is the address php-fpm listens on, the path of a unix socket except on
+	// flex, where it is a host and port.
+	PlaceholderPHPFPMSocket = "PHP_FPM_SOCKET"
+)
+
+var (
+	// routePathRegexp matches a URL path prefix which is safe to use in a location block.
+	routePathRegexp = regexp.MustCompile(`^/[^\s"'{};\\]*$`)
+	// routeTargetRegexp matches an HTTP URL which is safe to use in proxy_pass.
+	routeTargetRegexp = regexp.MustCompile(`^https?://[^\s"'{};\\]+$`)
+	// routeValueRegexp matches a value which is safe to use in a quoted nginx string.
+	routeValueRegexp = regexp.MustCompile(`^[^"\\\r\n$]*$`)
+	// nginxTimeRegexp matches an nginx time value, such as `75s`.
+	nginxTimeRegexp = regexp.MustCompile(`^[0-9]+(ms|s|m|h|d)?$`)
+	// tlsFileRegexp matches a file path which is safe to use in a quoted nginx string.
+	tlsFileRegexp = regexp.MustCompile(`^/[^\s"'\\$;]+$`)
+	// iniNameRegexp matches the name of a php.ini directive.
+	iniNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.\-\[\]]+$`)
+	// headerNameRegexp matches an HTTP header name.
+	headerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`)
+	// placeholderRegexp matches a `${NAME}` placeholder.
+	placeholderRegexp = regexp.MustCompile(`\$\{([A-Z_]+)\}`)
+)
+
 // OverrideProperties is the struct for the possible configs that can be overridden.
 type OverrideProperties struct {
 	// ComposerFlags overrides the composer arguments.
@@ -54,6 +91,55 @@ type OverrideProperties struct {
 	PHPIniOverrideFileName string
 	// NginxServesStaticFiles whether Nginx also serves static files for matching URIs.
 	NginxServesStaticFiles bool
+	// PHPFPMSlowlogTimeout is the time after which php-fpm logs the stack trace of a request, or
+	// empty to disable the slow log.
+	PHPFPMSlowlogTimeout string
+	// PHPFPMSlowlogFileName is the path of the php-fpm slow log, or empty to write it to stderr.
+	PHPFPMSlowlogFileName string
+	// PHPFPMSlowlogTraceDepth is the depth of the stack traces in the php-fpm slow log.
+	PHPFPMSlowlogTraceDepth string
+	// PHPFPMWorkers is the number of php-fpm workers, or empty to size them to the container at
+	// launch.
+	PHPFPMWorkers string
+	// PHPFPMWorkerMemory is the estimated memory of a php-fpm worker in MiB the workers are sized
+	// with, or empty for the default.
+	PHPFPMWorkerMemory string
+	// Server is the server the app runs under instead of nginx with php-fpm, or empty for the default.
+	Server string
+	// WorkerScriptFileName is the path of the script the server keeps running in worker mode.
+	WorkerScriptFileName string
+	// Routes are URL path prefixes handled by nginx location blocks of their own.
+	Routes []nginx.Route
+	// Gzip enables the gzip compression of text responses by nginx.
+	Gzip bool
+	// Brotli enables the brotli compression of text responses by nginx.
+	Brotli bool
+	// HTTP2 enables cleartext HTTP/2 (h2c) in nginx.
+	HTTP2 bool
+	// KeepaliveTimeout is the keepalive_timeout of nginx, or empty for the default.
+	KeepaliveTimeout string
+	// KeepaliveRequests is the keepalive_requests of nginx, or empty for the default.
+	KeepaliveRequests string
+	// TLSCertificateFileName is the path of the certificate nginx terminates TLS with, or empty to
+	// disable TLS.
+	TLSCertificateFileName string
+	// TLSCertificateKeyFileName is the path of the key of the TLS certificate.
+	TLSCertificateKeyFileName string
+	// TLSPort is the port nginx terminates TLS on, or empty for the default.
+	TLSPort string
+	// OPcachePreload is the path of the opcache.preload script, php.PreloadClassmap to generate it
+	// from the Composer classmap, or empty to disable preloading.
+	OPcachePreload string
+	// PHPIniDirectives are php.ini directives which take precedence over the php.ini of the
+	// runtime and PHPIniOverrideFileName.
+	PHPIniDirectives map[string]string
+	// WordPress configures nginx for WordPress, set when the application is detected as WordPress.
+	WordPress bool
+	// WordPressUploadsDir is the mounted volume wp-content/uploads is linked to, or empty to keep
+	// the uploads in the application.
+	WordPressUploadsDir string
+	// DevMode enables the development mode, see php.DevModeIniDirectives.
+	DevMode bool
 }
 
 // OverriddenProperties returns whether the property has been overridden and the path to the file.
@@ -78,7 +164,311 @@ func OverriddenProperties(ctx *gcp.Context, runtimeConfig appyaml.RuntimeConfig)
 		NginxServerConfIncludeFileName: nginxServerConfIncludeFileName,
 		NginxHTTPInclude:               nginxHTTPInclude,
 		NginxHTTPIncludeFileName:       nginxHTTPIncludeFileName,
+		PHPFPMSlowlogTimeout:           runtimeConfig.PHPFPMSlowlogTimeout,
+		PHPFPMSlowlogFileName:          appFileName(runtimeConfig.PHPFPMSlowlog),
+		PHPFPMSlowlogTraceDepth:        runtimeConfig.PHPFPMSlowlogTraceDepth,
+		PHPFPMWorkers:                  runtimeConfig.PHPFPMWorkers,
+		PHPFPMWorkerMemory:             runtimeConfig.PHPFPMWorkerMemory,
+		Server:                         runtimeConfig.Server,
+		WorkerScriptFileName:           appFileName(runtimeConfig.WorkerScript),
+		Routes:                         runtimeConfig.Routes,
+		Gzip:                           runtimeConfig.Gzip,
+		Brotli:                         runtimeConfig.Brotli,
+		HTTP2:                          runtimeConfig.HTTP2,
+		KeepaliveTimeout:               runtimeConfig.KeepaliveTimeout,
+		KeepaliveRequests:              runtimeConfig.KeepaliveRequests,
+		TLSCertificateFileName:         appFileName(runtimeConfig.TLSCertificate),
+		TLSCertificateKeyFileName:      appFileName(runtimeConfig.TLSCertificateKey),
+		TLSPort:                        runtimeConfig.TLSPort,
+		OPcachePreload:                 preloadFileName(runtimeConfig.OPcachePreload),
+		PHPIniDirectives:               runtimeConfig.PHPIni,
+		WordPressUploadsDir:            runtimeConfig.WordPressUploadsDir,
+		DevMode:                        runtimeConfig.DevMode,
+	}
+}
+
+// MergeComposerExtra returns props updated with the values set in the google-buildpacks composer
+// extra, which take precedence over the app.yaml runtime_config.
+func MergeComposerExtra(props OverrideProperties, extra php.ComposerExtra) OverrideProperties {
+	if extra.DocumentRoot != "" {
+		props.DocumentRoot = extra.DocumentRoot
+	}
+	if extra.FrontControllerFile != "" {
+		props.FrontController = extra.FrontControllerFile
+	}
+	if extra.NginxConfOverride != "" {
+		props.NginxConfOverride, props.NginxConfOverrideFileName = true, filepath.Join(defaultRoot, extra.NginxConfOverride)
+	}
+	if extra.NginxConfInclude != "" {
+		props.NginxServerConfInclude, props.NginxServerConfIncludeFileName = true, filepath.Join(defaultRoot, extra.NginxConfInclude)
+	}
+	if extra.NginxConfHTTPInclude != "" {
+		props.NginxHTTPInclude, props.NginxHTTPIncludeFileName = true, filepath.Join(defaultRoot, extra.NginxConfHTTPInclude)
+	}
+	if extra.PHPFPMConfOverride != "" {
+		props.PHPFPMOverride, props.PHPFPMOverrideFileName = true, filepath.Join(defaultRoot, extra.PHPFPMConfOverride)
+	}
+	if extra.PHPIniOverride != "" {
+		props.PHPIniOverride, props.PHPIniOverrideFileName = true, filepath.Join(defaultRoot, extra.PHPIniOverride)
+	}
+	if extra.PHPFPMSlowlogTimeout != "" {
+		props.PHPFPMSlowlogTimeout = extra.PHPFPMSlowlogTimeout
+	}
+	if extra.PHPFPMSlowlog != "" {
+		props.PHPFPMSlowlogFileName = appFileName(extra.PHPFPMSlowlog)
+	}
+	if extra.PHPFPMSlowlogTraceDepth != "" {
+		props.PHPFPMSlowlogTraceDepth = extra.PHPFPMSlowlogTraceDepth
+	}
+	if extra.PHPFPMWorkers != "" {
+		props.PHPFPMWorkers = extra.PHPFPMWorkers
+	}
+	if extra.PHPFPMWorkerMemory != "" {
+		props.PHPFPMWorkerMemory = extra.PHPFPMWorkerMemory
+	}
+	if extra.Server != "" {
+		props.Server = extra.Server
+	}
+	if extra.WorkerScript != "" {
+		props.WorkerScriptFileName = appFileName(extra.WorkerScript)
+	}
+	if len(extra.Routes) > 0 {
+		props.Routes = extra.Routes
+	}
+	if extra.Gzip {
+		props.Gzip = true
+	}
+	if extra.Brotli {
+		props.Brotli = true
+	}
+	if extra.HTTP2 {
+		props.HTTP2 = true
+	}
+	if extra.KeepaliveTimeout != "" {
+		props.KeepaliveTimeout = extra.KeepaliveTimeout
+	}
+	if extra.KeepaliveRequests != "" {
+		props.KeepaliveRequests = extra.KeepaliveRequests
+	}
+	if extra.TLSCertificate != "" {
+		props.TLSCertificateFileName = appFileName(extra.TLSCertificate)
+	}
+	if extra.TLSCertificateKey != "" {
+		props.TLSCertificateKeyFileName = appFileName(extra.TLSCertificateKey)
+	}
+	if extra.TLSPort != "" {
+		props.TLSPort = extra.TLSPort
+	}
+	if extra.OPcachePreload != "" {
+		props.OPcachePreload = preloadFileName(extra.OPcachePreload)
+	}
+	if extra.WordPressUploadsDir != "" {
+		props.WordPressUploadsDir = extra.WordPressUploadsDir
+	}
+	if extra.DevMode {
+		props.DevMode = true
+	}
+	// Directives are merged, so that composer.json can override some of the app.yaml directives.
+	if len(extra.PHPIni) > 0 {
+		merged := make(map[string]string, len(props.PHPIniDirectives)+len(extra.PHPIni))
+		for name, value := range props.PHPIniDirectives {
+			merged[name] = value
+		}
+		for name, value := range extra.PHPIni {
+			merged[name] = value
+		}
+		props.PHPIniDirectives = merged
+	}
+	return props
+}
+
+// slowlogFileName returns the path of the php-fpm slow log set to path, which is relative to the
+// application root unless it is absolute.
+func appFileName(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(defaultRoot, path)
+}
+
+// preloadFileName returns the path of the preload script set to preload, or php.PreloadClassmap.
+func preloadFileName(preload string) string {
+	if preload == php.PreloadClassmap {
+		return preload
+	}
+	return appFileName(preload)
+}
+
+// ValidateRoutes returns an error if a route of the composer extra or app.yaml runtime_config
+// cannot be rendered into a valid nginx location block.
+func ValidateRoutes(routes []nginx.Route) error {
+	seen := map[string]bool{}
+	for _, r := range routes {
+		if !routePathRegexp.MatchString(r.Path) || r.Path == "/" || strings.HasSuffix(r.Path, "/") {
+			return gcp.UserErrorf("invalid route path %q, it must start with / and not end with / or contain spaces, quotes, braces or semicolons", r.Path)
+		}
+		if seen[r.Path] {
+			return gcp.UserErrorf("route path %q must be unique", r.Path)
+		}
+		seen[r.Path] = true
+		switch r.Type {
+		case nginx.RoutePHP, nginx.RouteStatic:
+		case nginx.RouteProxy:
+			if !routeTargetRegexp.MatchString(r.Target) {
+				return gcp.UserErrorf("invalid target %q of proxy route %s, it must be an http:// or https:// URL", r.Target, r.Path)
+			}
+		default:
+			return gcp.UserErrorf("invalid type %q of route %s, it must be %s, %s or %s", r.Type, r.Path, nginx.RoutePHP, nginx.RouteStatic, nginx.RouteProxy)
+		}
+		if r.Dir != "" && r.Type != nginx.RouteStatic {
+			return gcp.UserErrorf("dir of route %s is only valid for %s routes", r.Path, nginx.RouteStatic)
+		}
+		if dir := filepath.Clean(r.Dir); r.Dir != "" && (filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") || !routeValueRegexp.MatchString(dir)) {
+			return gcp.UserErrorf("invalid dir %q of route %s, it must be a directory of the application", r.Dir, r.Path)
+		}
+		for name, value := range r.Headers {
+			if !headerNameRegexp.MatchString(name) || !routeValueRegexp.MatchString(value) {
+				return gcp.UserErrorf("invalid header %q: %q of route %s", name, value, r.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateKeepalive returns an error if the keepalive settings of the composer extra or app.yaml
+// runtime_config are not valid nginx values.
+func ValidateKeepalive(props OverrideProperties) error {
+	if t := props.KeepaliveTimeout; t != "" && !nginxTimeRegexp.MatchString(t) {
+		return gcp.UserErrorf("invalid keepalive_timeout %q, it must be a duration such as 75s", t)
+	}
+	if r := props.KeepaliveRequests; r != "" {
+		if n, err := strconv.Atoi(r); err != nil || n <= 0 {
+			return gcp.UserErrorf("invalid keepalive_requests %q, it must be a positive number", r)
+		}
+	}
+	return nil
+}
+
+// ValidateTLS returns an error if the TLS settings of the composer extra or app.yaml runtime_config
+// are incomplete or not valid nginx values.
+func ValidateTLS(props OverrideProperties) error {
+	cert, key := props.TLSCertificateFileName, props.TLSCertificateKeyFileName
+	if cert == "" && key == "" {
+		if props.TLSPort != "" {
+			return gcp.UserErrorf("tls_port is set without tls_certificate and tls_certificate_key")
+		}
+		return nil
+	}
+	if cert == "" || key == "" {
+		return gcp.UserErrorf("tls_certificate and tls_certificate_key must be set together")
+	}
+	for _, f := range []string{cert, key} {
+		if !tlsFileRegexp.MatchString(f) {
+			return gcp.UserErrorf("invalid TLS file %q, it must not contain spaces, quotes, backslashes, dollar signs or semicolons", f)
+		}
+	}
+	if p := props.TLSPort; p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n <= 0 || n > 65535 {
+			return gcp.UserErrorf("invalid tls_port %q, it must be a port number", p)
+		}
+	}
+	return nil
+}
+
+// ValidatePHPIni returns an error if a php_ini directive of the composer extra or app.yaml
+// runtime_config cannot be written to a single line of an ini file.
+func ValidatePHPIni(directives map[string]string) error {
+	for name, value := range directives {
+		if !iniNameRegexp.MatchString(name) {
+			return gcp.UserErrorf("invalid php_ini directive name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return gcp.UserErrorf("php_ini directive %q must not contain line breaks", name)
+		}
+	}
+	return nil
+}
+
+// ExpandPlaceholders returns content with the `${NAME}` placeholders named in values replaced by
+// their values, and whether any placeholder was replaced. Other placeholders are kept as is, as
+// nginx uses the same syntax for its variables and php-fpm for env vars.
+func ExpandPlaceholders(content string, values map[string]string) (string, bool) {
+	expanded := false
+	out := placeholderRegexp.ReplaceAllStringFunc(content, func(p string) string {
+		v, ok := values[placeholderRegexp.FindStringSubmatch(p)[1]]
+		if !ok {
+			return p
+		}
+		expanded = true
+		return v
+	})
+	return out, expanded
+}
+
+// BrotliRequested returns true if the brotli compression is enabled in the composer extra, or in
+// the app.yaml runtime_config on flex, so that the brotli modules are installed with nginx before
+// the nginx config is generated.
+func BrotliRequested(ctx *gcp.Context) (bool, error) {
+	extra, _, err := php.ReadComposerExtra(ctx)
+	if err != nil || extra.Brotli || !env.IsFlex() {
+		return extra.Brotli, err
+	}
+	runtimeConfig, err := appyaml.PhpConfiguration(ctx.ApplicationRoot())
+	if err != nil {
+		return false, err
+	}
+	return runtimeConfig.Brotli, nil
+}
+
+// DevMode returns whether the development mode is enabled, which is the value of php.DevModeEnv if
+// it is set, or configured with dev_mode otherwise.
+func DevMode(configured bool) (bool, error) {
+	v, ok := os.LookupEnv(php.DevModeEnv)
+	if !ok {
+		return configured, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, gcp.UserErrorf("invalid %s %q, it must be true or false", php.DevModeEnv, v)
+	}
+	return enabled, nil
+}
+
+// DevModeRequested returns true if the development mode is enabled with php.DevModeEnv, or unless
+// it is set to false, in the composer extra or in the app.yaml runtime_config on flex, so that
+// Xdebug is installed with the PHP runtime.
+func DevModeRequested(ctx *gcp.Context) (bool, error) {
+	extra, _, err := php.ReadComposerExtra(ctx)
+	if err != nil {
+		return false, err
+	}
+	configured := extra.DevMode
+	if !configured && env.IsFlex() {
+		runtimeConfig, err := appyaml.PhpConfiguration(ctx.ApplicationRoot())
+		if err != nil {
+			return false, err
+		}
+		configured = runtimeConfig.DevMode
+	}
+	return DevMode(configured)
+}
+
+// CheckUnknownKeys reports keys in the given configuration source which are not recognized. The
+// build fails if env.StrictConfig is enabled, otherwise a warning is logged.
+func CheckUnknownKeys(ctx *gcp.Context, source string, unknown, valid []string) error {
+	if len(unknown) == 0 {
+		return nil
+	}
+	strict, err := env.IsPresentAndTrue(env.StrictConfig)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	msg := fmt.Sprintf("unrecognized keys in %s: %s (valid keys: %s)", source, strings.Join(unknown, ", "), strings.Join(valid, ", "))
+	if strict {
+		return gcp.UserErrorf("%s", msg)
 	}
+	ctx.Warnf("Ignoring %s; set %s=true to fail the build on unrecognized keys", msg, env.StrictConfig)
+	return nil
 }
 
 func overrideProperties(ctx *gcp.Context, configValue, defaultFile string) (bool, string) {
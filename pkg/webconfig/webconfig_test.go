@@ -0,0 +1,408 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeComposerExtra(t *testing.T) {
+	props := OverrideProperties{
+		DocumentRoot:       "web",
+		FrontController:    "app.php",
+		PHPFPMWorkerMemory: "96",
+		PHPIniDirectives:   map[string]string{"memory_limit": "256M", "max_execution_time": "30"},
+	}
+	extra := php.ComposerExtra{
+		DocumentRoot:         "public",
+		NginxConfInclude:     "nginx-app.conf",
+		PHPFPMSlowlogTimeout: "5s",
+		PHPFPMSlowlog:        "storage/logs/slow.log",
+		PHPFPMWorkers:        "8",
+		Server:               php.ServerFrankenPHP,
+		WorkerScript:         "public/worker.php",
+		Routes:               []nginx.Route{{Path: "/build", Type: nginx.RouteStatic}},
+		Brotli:               true,
+		HTTP2:                true,
+		KeepaliveTimeout:     "620s",
+		TLSCertificate:       "certs/tls.crt",
+		TLSCertificateKey:    "/secrets/tls.key",
+		OPcachePreload:       "classmap",
+		PHPIni:               php.IniDirectives{"memory_limit": "512M"},
+		WordPressUploadsDir:  "/mnt/uploads",
+		DevMode:              true,
+	}
+	want := OverrideProperties{
+		DocumentRoot:                   "public",
+		FrontController:                "app.php",
+		NginxServerConfInclude:         true,
+		NginxServerConfIncludeFileName: "/workspace/nginx-app.conf",
+		PHPFPMSlowlogTimeout:           "5s",
+		PHPFPMSlowlogFileName:          "/workspace/storage/logs/slow.log",
+		PHPFPMWorkers:                  "8",
+		PHPFPMWorkerMemory:             "96",
+		Server:                         php.ServerFrankenPHP,
+		WorkerScriptFileName:           "/workspace/public/worker.php",
+		Routes:                         []nginx.Route{{Path: "/build", Type: nginx.RouteStatic}},
+		Brotli:                         true,
+		HTTP2:                          true,
+		KeepaliveTimeout:               "620s",
+		TLSCertificateFileName:         "/workspace/certs/tls.crt",
+		TLSCertificateKeyFileName:      "/secrets/tls.key",
+		OPcachePreload:                 "classmap",
+		PHPIniDirectives:               map[string]string{"memory_limit": "512M", "max_execution_time": "30"},
+		WordPressUploadsDir:            "/mnt/uploads",
+		DevMode:                        true,
+	}
+	if diff := cmp.Diff(want, MergeComposerExtra(props, extra)); diff != "" {
+		t.Errorf("MergeComposerExtra() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDevModeRequested(t *testing.T) {
+	testCases := []struct {
+		name     string
+		env      string
+		composer string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "composer extra",
+			composer: `{"extra": {"google-buildpacks": {"dev_mode": true}}}`,
+			want:     true,
+		},
+		{
+			name: "env",
+			env:  "true",
+			want: true,
+		},
+		{
+			name:     "env disables composer extra",
+			env:      "false",
+			composer: `{"extra": {"google-buildpacks": {"dev_mode": true}}}`,
+		},
+		{
+			name:    "invalid env",
+			env:     "yes please",
+			wantErr: true,
+		},
+		{
+			name:     "default",
+			composer: `{"require": {"php": "^8.2"}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.composer != "" {
+				if err := os.WriteFile(filepath.Join(dir, "composer.json"), []byte(tc.composer), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.env != "" {
+				t.Setenv(php.DevModeEnv, tc.env)
+			}
+
+			got, err := DevModeRequested(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("DevModeRequested() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("DevModeRequested() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckUnknownKeys(t *testing.T) {
+	testCases := []struct {
+		name    string
+		strict  string
+		unknown []string
+		wantErr bool
+	}{
+		{
+			name:   "no unknown keys in strict mode",
+			strict: "true",
+		},
+		{
+			name:    "unknown keys without strict mode",
+			unknown: []string{"docment_root"},
+		},
+		{
+			name:    "unknown keys in strict mode",
+			strict:  "true",
+			unknown: []string{"docment_root"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.strict != "" {
+				t.Setenv("GOOGLE_STRICT_CONFIG", tc.strict)
+			}
+			ctx := gcp.NewContext()
+
+			err := CheckUnknownKeys(ctx, "app.yaml runtime_config", tc.unknown, []string{"document_root"})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("CheckUnknownKeys() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	testCases := []struct {
+		name    string
+		routes  []nginx.Route
+		wantErr bool
+	}{
+		{
+			name: "valid routes",
+			routes: []nginx.Route{
+				{Path: "/api", Type: nginx.RoutePHP},
+				{Path: "/build", Type: nginx.RouteStatic, Headers: map[string]string{"Cache-Control": "public, max-age=31536000"}},
+				{Path: "/media", Type: nginx.RouteStatic, Dir: "storage/app/public"},
+				{Path: "/socket", Type: nginx.RouteProxy, Target: "http://127.0.0.1:6001"},
+			},
+		},
+		{
+			name:    "relative path",
+			routes:  []nginx.Route{{Path: "api", Type: nginx.RoutePHP}},
+			wantErr: true,
+		},
+		{
+			name:    "root path",
+			routes:  []nginx.Route{{Path: "/", Type: nginx.RoutePHP}},
+			wantErr: true,
+		},
+		{
+			name:    "path with a brace",
+			routes:  []nginx.Route{{Path: "/api{", Type: nginx.RoutePHP}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate paths",
+			routes:  []nginx.Route{{Path: "/api", Type: nginx.RoutePHP}, {Path: "/api", Type: nginx.RouteStatic}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			routes:  []nginx.Route{{Path: "/api", Type: "fastcgi"}},
+			wantErr: true,
+		},
+		{
+			name:    "proxy without target",
+			routes:  []nginx.Route{{Path: "/socket", Type: nginx.RouteProxy}},
+			wantErr: true,
+		},
+		{
+			name:    "dir of a php route",
+			routes:  []nginx.Route{{Path: "/api", Type: nginx.RoutePHP, Dir: "api"}},
+			wantErr: true,
+		},
+		{
+			name:    "dir outside the application",
+			routes:  []nginx.Route{{Path: "/etc", Type: nginx.RouteStatic, Dir: "../etc"}},
+			wantErr: true,
+		},
+		{
+			name:    "header value with a quote",
+			routes:  []nginx.Route{{Path: "/api", Type: nginx.RoutePHP, Headers: map[string]string{"X-Test": `a"b`}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid header name",
+			routes:  []nginx.Route{{Path: "/api", Type: nginx.RoutePHP, Headers: map[string]string{"X Test": "a"}}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRoutes(tc.routes)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateRoutes() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateKeepalive(t *testing.T) {
+	testCases := []struct {
+		name    string
+		props   OverrideProperties
+		wantErr bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:  "valid",
+			props: OverrideProperties{KeepaliveTimeout: "620s", KeepaliveRequests: "10000"},
+		},
+		{
+			name:  "timeout without a unit",
+			props: OverrideProperties{KeepaliveTimeout: "75"},
+		},
+		{
+			name:    "invalid timeout",
+			props:   OverrideProperties{KeepaliveTimeout: "75 seconds"},
+			wantErr: true,
+		},
+		{
+			name:    "zero requests",
+			props:   OverrideProperties{KeepaliveRequests: "0"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid requests",
+			props:   OverrideProperties{KeepaliveRequests: "many"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateKeepalive(tc.props)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateKeepalive() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	testCases := []struct {
+		name    string
+		props   OverrideProperties
+		wantErr bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:  "valid",
+			props: OverrideProperties{TLSCertificateFileName: "/secrets/tls.crt", TLSCertificateKeyFileName: "/secrets/tls.key", TLSPort: "443"},
+		},
+		{
+			name:    "certificate without key",
+			props:   OverrideProperties{TLSCertificateFileName: "/secrets/tls.crt"},
+			wantErr: true,
+		},
+		{
+			name:    "port without certificate",
+			props:   OverrideProperties{TLSPort: "8443"},
+			wantErr: true,
+		},
+		{
+			name:    "path with a quote",
+			props:   OverrideProperties{TLSCertificateFileName: `/secrets/tls".crt`, TLSCertificateKeyFileName: "/secrets/tls.key"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			props:   OverrideProperties{TLSCertificateFileName: "/secrets/tls.crt", TLSCertificateKeyFileName: "/secrets/tls.key", TLSPort: "70000"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTLS(tc.props)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateTLS() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePHPIni(t *testing.T) {
+	testCases := []struct {
+		name       string
+		directives map[string]string
+		wantErr    bool
+	}{
+		{
+			name:       "valid",
+			directives: map[string]string{"memory_limit": "512M", "opcache.jit": "tracing", "error_reporting": "E_ALL & ~E_DEPRECATED"},
+		},
+		{
+			name:       "name with a space",
+			directives: map[string]string{"memory limit": "512M"},
+			wantErr:    true,
+		},
+		{
+			name:       "value with a line break",
+			directives: map[string]string{"memory_limit": "512M\nextension=evil.so"},
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePHPIni(tc.directives)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidatePHPIni() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	values := map[string]string{
+		PlaceholderPort:         "8080",
+		PlaceholderDocumentRoot: "/workspace/public",
+		PlaceholderPHPFPMSocket: "/layers/google.php.webconfig/webconfig/app.sock",
+	}
+	testCases := []struct {
+		name         string
+		content      string
+		want         string
+		wantExpanded bool
+	}{
+		{
+			name:         "placeholders",
+			content:      "listen ${PORT};\nroot ${DOCUMENT_ROOT};\nfastcgi_pass unix:${PHP_FPM_SOCKET};\n",
+			want:         "listen 8080;\nroot /workspace/public;\nfastcgi_pass unix:/layers/google.php.webconfig/webconfig/app.sock;\n",
+			wantExpanded: true,
+		},
+		{
+			name:    "nginx variables",
+			content: "try_files $uri /index.php?$query_string;\nadd_header X-Host ${host};\nset $PORT 1;\n",
+			want:    "try_files $uri /index.php?$query_string;\nadd_header X-Host ${host};\nset $PORT 1;\n",
+		},
+		{
+			name:    "unknown placeholder",
+			content: "env[APP_ENV] = ${APP_ENV}\n",
+			want:    "env[APP_ENV] = ${APP_ENV}\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, expanded := ExpandPlaceholders(tc.content, values)
+			if got != tc.want {
+				t.Errorf("ExpandPlaceholders() = %q, want %q", got, tc.want)
+			}
+			if expanded != tc.wantExpanded {
+				t.Errorf("ExpandPlaceholders() expanded = %t, want %t", expanded, tc.wantExpanded)
+			}
+		})
+	}
+}
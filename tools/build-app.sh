@@ -0,0 +1,82 @@
+#!/bin/bash
+# Copyright 2024 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# The build-app.sh script builds and publishes an application image on a stateless runner, such as
+# Cloud Build or a CI job without a persistent cache volume.
+#
+# The layer cache is stored in a cache image in the registry next to the application image, and
+# the previously published application image is analyzed so that unchanged launch layers are
+# reused instead of rebuilt and pushed again.
+#
+# With BUILDHASH set, the build is skipped entirely when the source, apphosting.yaml, builder image
+# and pack arguments hash to the value recorded in the previous image, and the reference of that
+# image is printed on the last line instead. A rebuilt builder image, such as one with updated
+# runtimes, changes the hash even if the versions of its buildpacks are unchanged.
+#
+# Usage:
+# ./build-app.sh <image> [<app dir>] [<extra pack args>...]
+#
+# Environment:
+#   BUILDER      builder image to use, defaults to gcr.io/buildpacks/builder.
+#   CACHE_IMAGE  image the layer cache is stored in, defaults to <image>-cache.
+#   BUILDHASH    path of the buildhash tool (tools/buildhash), enables skipping unchanged builds.
+#   APPHOSTING_YAML  apphosting.yaml outside of the app dir to include in the build hash, if any.
+
+set -euo pipefail
+
+readonly image="${1:?image name missing}"
+readonly app="${2:-.}"
+shift $(( $# < 2 ? $# : 2 ))
+readonly builder="${BUILDER:-gcr.io/buildpacks/builder}"
+readonly cache_image="${CACHE_IMAGE:-${image}-cache}"
+
+args=(build "$image" --builder="$builder" --path="$app" --publish --cache-image="$cache_image")
+
+previous=
+if docker manifest inspect "$image" > /dev/null 2>&1; then
+  previous="$image"
+fi
+
+if [[ -n "${BUILDHASH:-}" ]]; then
+  hash_args=(-builder="$builder" -source="$app" -apphosting-yaml="${APPHOSTING_YAML:-}")
+  if [[ -n "$previous" ]]; then
+    hash_args+=(-previous="$previous")
+  fi
+  mapfile -t hash_out < <("$BUILDHASH" "${hash_args[@]}" -- "$@")
+  if (( ${#hash_out[@]} == 0 )); then
+    echo "Failed to compute the build hash" >&2
+    exit 1
+  fi
+  if (( ${#hash_out[@]} > 1 )); then
+    echo "Inputs are unchanged since the previous image, skipping the build"
+    echo "${hash_out[1]}"
+    exit 0
+  fi
+  echo "Build hash ${hash_out[0]}"
+  # The label image buildpack records the hash in the labels of the image for the next build.
+  args+=(--env="GOOGLE_LABEL_BUILD_CONFIG_HASH=${hash_out[0]}")
+fi
+
+# The lifecycle reads the layer metadata of the previous image to reuse its launch layers. A
+# missing image is not an error, it only means this is the first build.
+if [[ -n "$previous" ]]; then
+  echo "Reusing layers of the previous image $image"
+  args+=(--previous-image="$image")
+else
+  echo "No previous image $image found, building from scratch"
+fi
+
+echo "Using cache image $cache_image"
+pack "${args[@]}" "$@"
@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The buildhash binary prints a content-addressed hash of the inputs of a build: the application
+// source, apphosting.yaml, the digest and buildpacks of the builder and the build arguments.
+//
+// When the previously published image is given and recorded the same hash in its labels, the
+// reference of that image is printed on a second line, so the build can be skipped and the image
+// reused:
+//
+//	buildhash -builder=gcr.io/buildpacks/builder [-source=.] [-apphosting-yaml=apphosting.yaml] [-previous=gcr.io/my-project/app] [build args...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildhash"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+var (
+	source         = flag.String("source", ".", "Directory of the application source.")
+	appHostingYAML = flag.String("apphosting-yaml", "", "Path of the apphosting.yaml of the backend, if any.")
+	builder        = flag.String("builder", "", "Reference of the builder image.")
+	previous       = flag.String("previous", "", "Reference of the previously published image, if any.")
+)
+
+func main() {
+	flag.Parse()
+	if *builder == "" {
+		log.Fatal("-builder is required")
+	}
+	builderImg, err := crane.Pull(*builder)
+	if err != nil {
+		log.Fatalf("Error pulling builder %s: %v", *builder, err)
+	}
+	builderDigest, err := builderImg.Digest()
+	if err != nil {
+		log.Fatalf("Error reading the digest of %s: %v", *builder, err)
+	}
+	buildpacks, err := buildhash.BuilderBuildpacks(builderImg)
+	if err != nil {
+		log.Fatalf("Error reading the buildpacks of %s: %v", *builder, err)
+	}
+	hash, err := buildhash.Compute(buildhash.Inputs{
+		SourceDir:      *source,
+		AppHostingYAML: *appHostingYAML,
+		BuilderDigest:  builderDigest.String(),
+		Buildpacks:     buildpacks,
+		Args:           flag.Args(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(hash)
+
+	if *previous == "" {
+		return
+	}
+	ref, err := unchangedImage(*previous, hash)
+	if err != nil {
+		// A missing or unreadable previous image only means the build cannot be skipped.
+		log.Printf("Not reusing %s: %v", *previous, err)
+		return
+	}
+	if ref != "" {
+		fmt.Println(ref)
+	}
+}
+
+// unchangedImage returns the digest reference of the image if it recorded the given hash, or ""
+// otherwise.
+func unchangedImage(image, hash string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	img, err := crane.Pull(image)
+	if err != nil {
+		return "", err
+	}
+	recorded, err := buildhash.Recorded(img)
+	if err != nil {
+		return "", err
+	}
+	if recorded != hash {
+		log.Printf("%s was built from different inputs, hash %q", image, recorded)
+		return "", nil
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+	return ref.Context().Digest(digest.String()).String(), nil
+}
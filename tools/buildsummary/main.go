@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The buildsummary binary prints a markdown summary of a build for a pull request comment: the
+// image size and build duration compared to the previous build, the changed runtime versions and
+// settings, and the new warnings. It reads the builder output files written to $BUILDER_OUTPUT.
+//
+// CI systems usually keep the builder output of the base branch build and pass it as -previous:
+//
+//	buildsummary -output=out/output [-previous=base/output] [-image-size=N] [-previous-image-size=N]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+)
+
+var (
+	output            = flag.String("output", "", "Builder output file of the build.")
+	previous          = flag.String("previous", "", "Builder output file of the previous build, if any.")
+	imageSize         = flag.Int64("image-size", 0, "Size of the built image in bytes, if known.")
+	previousImageSize = flag.Int64("previous-image-size", 0, "Size of the previous image in bytes, if known.")
+)
+
+func main() {
+	flag.Parse()
+	if *output == "" {
+		log.Fatal("-output is required")
+	}
+	cur, err := read(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := builderoutput.Summary{Current: cur, ImageSize: *imageSize, PreviousImageSize: *previousImageSize}
+	if *previous != "" {
+		prev, err := read(*previous)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.Previous = &prev
+	}
+	fmt.Print(s.Markdown())
+}
+
+func read(path string) (builderoutput.BuilderOutput, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return builderoutput.BuilderOutput{}, fmt.Errorf("reading builder output: %w", err)
+	}
+	bo, err := builderoutput.FromJSON(content)
+	if err != nil {
+		return builderoutput.BuilderOutput{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return bo, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The detectplan binary prints the outcome of the detect phase recorded by each buildpack: which
+// buildpacks passed, what they provided and required, and why the others were skipped.
+//
+// Run it in the build container after the detect phase, or point -dir at a copy of the directory:
+//
+//	detectplan [-dir=/tmp/google-detect-plan] [-json]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	dir     = flag.String("dir", gcp.DetectPlanDir(), "Directory containing the detect records, defaults to $GOOGLE_DETECT_PLAN_DIR.")
+	jsonOut = flag.Bool("json", false, "Print the plan as JSON instead of a table.")
+)
+
+func main() {
+	flag.Parse()
+	records, err := gcp.ReadDetectPlan(*dir)
+	if err != nil {
+		log.Fatalf("Error reading the detect plan: %v", err)
+	}
+	if len(records) == 0 {
+		log.Fatalf("No detect records found in %s", *dir)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(records); err != nil {
+			log.Fatalf("Error encoding the detect plan: %v", err)
+		}
+		return
+	}
+	fmt.Print(gcp.FormatDetectPlan(records))
+}
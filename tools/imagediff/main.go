@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The imagediff binary compares the layers of two images built by the buildpacks and prints the
+// layers and files which grew, shrank, were added or were removed.
+//
+// Images are read from tarballs written by `docker save` or `crane pull`, or pulled from a
+// registry if the argument is not a file:
+//
+//	imagediff -old=old.tar -new=gcr.io/my-project/app:latest [-top=10] [-json]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/imagediff"
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+var (
+	oldImage = flag.String("old", "", "Tarball or reference of the old image.")
+	newImage = flag.String("new", "", "Tarball or reference of the new image.")
+	top      = flag.Int("top", 10, "Number of changed files listed per layer.")
+	jsonOut  = flag.Bool("json", false, "Print the report as JSON instead of a table.")
+)
+
+func main() {
+	flag.Parse()
+	if *oldImage == "" || *newImage == "" {
+		log.Fatal("-old and -new are required")
+	}
+	oldLayers, err := readLayers(*oldImage)
+	if err != nil {
+		log.Fatal(err)
+	}
+	newLayers, err := readLayers(*newImage)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report := imagediff.Compare(oldLayers, newLayers)
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Error encoding the report: %v", err)
+		}
+		return
+	}
+	fmt.Print(report.Format(*top))
+}
+
+func readLayers(image string) ([]imagediff.Layer, error) {
+	var img v1.Image
+	var err error
+	if _, statErr := os.Stat(image); statErr == nil {
+		img, err = crane.Load(image)
+	} else {
+		img, err = crane.Pull(image)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading image %s: %w", image, err)
+	}
+	layers, err := imagediff.ReadLayers(img)
+	if err != nil {
+		return nil, fmt.Errorf("reading image %s: %w", image, err)
+	}
+	return layers, nil
+}
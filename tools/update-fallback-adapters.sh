@@ -0,0 +1,95 @@
+#!/bin/bash
+# Copyright 2024 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# The update-fallback-adapters.sh script downloads the last known good tarball of each supported
+# major version of the build adaptors into the adapters directory of the buildpacks, with the
+# tarballs of their dependencies in a directory named after the adaptor tarball. They are bundled
+# in the buildpacks and installed with npm --offline when the npm registry is unreachable. The
+# digest of each adaptor tarball is pinned in pkg/nodejs/adaptor_integrity.json.
+#
+# Usage:
+#   ./tools/update-fallback-adapters.sh
+#
+# Run it from the root of the repository before a release and commit the tarballs and the manifest.
+
+set -euo pipefail
+
+readonly manifest="pkg/nodejs/adaptor_integrity.json"
+
+# Each line is: <buildpack directory> <adaptor package> <major versions>.
+readonly adapters="
+cmd/nodejs/firebasenextjs @apphosting/adapter-nextjs 14 15
+cmd/nodejs/firebaseangular @apphosting/adapter-angular 17 18 19
+cmd/nodejs/firebaseastro @apphosting/adapter-astro 5
+cmd/nodejs/firebasenuxt @apphosting/adapter-nuxt 3
+cmd/nodejs/firebasesveltekit @apphosting/adapter-sveltekit 2
+cmd/nodejs/firebaseremix @apphosting/adapter-remix 2
+cmd/nodejs/firebaseremix @apphosting/adapter-react-router 7
+"
+
+while read -r dir pkg majors; do
+  [[ -z "${dir}" ]] && continue
+  prefix="$(echo "${pkg#@}" | tr / -)-"
+  mkdir -p "${dir}/adapters"
+  rm -rf "${dir}/adapters/${prefix}"[0-9]*
+  for major in ${majors}; do
+    # npm view prints the versions matching the range, the last one is the newest.
+    version="$(npm view "${pkg}@^${major}.0.0" version --json | tr -d '[]" ' | tr , '\n' | grep -v '^$' | tail -n 1)"
+    if [[ -z "${version}" ]]; then
+      echo "No ${pkg} version ${major} found, skipping"
+      continue
+    fi
+    echo "Downloading ${pkg}@${version} to ${dir}/adapters"
+    npm pack --silent --pack-destination "${dir}/adapters" "${pkg}@${version}"
+
+    # Pin the digest of the tarball, in the Subresource Integrity format npm uses.
+    integrity="sha512-$(openssl dgst -sha512 -binary "${dir}/adapters/${prefix}${version}.tgz" | base64 -w 0)"
+    node -e '
+      const fs = require("fs");
+      const [path, key, integrity] = process.argv.slice(1);
+      const manifest = JSON.parse(fs.readFileSync(path, "utf8"));
+      manifest[key] = integrity;
+      const sorted = Object.fromEntries(Object.entries(manifest).sort(([a], [b]) => a.localeCompare(b)));
+      fs.writeFileSync(path, JSON.stringify(sorted, null, 2) + "\n");
+    ' "${manifest}" "${pkg}@${version}" "${integrity}"
+
+    # Vendor the dependencies the adaptor resolves to, so that it installs without the registry.
+    deps="${dir}/adapters/${prefix}${version}"
+    tmp="$(mktemp -d)"
+    npm install --silent --prefix "${tmp}" --ignore-scripts --no-audit --no-fund "${deps}.tgz"
+    mkdir -p "${deps}"
+    node -e '
+      const lock = require(process.argv[1]);
+      const seen = new Map();
+      // Hoisted packages come first, they are the ones a flat install of the tarballs reproduces.
+      const depth = (path) => path.split("node_modules/").length;
+      const packages = Object.entries(lock.packages).sort(([a], [b]) => depth(a) - depth(b));
+      for (const [path, entry] of packages) {
+        if (!path.startsWith("node_modules/") || entry.link || entry.dev) continue;
+        const name = entry.name || path.slice(path.lastIndexOf("node_modules/") + "node_modules/".length);
+        if (name === process.argv[2]) continue;
+        if (seen.has(name) && seen.get(name) !== entry.version) {
+          console.error(`${name} is required in versions ${seen.get(name)} and ${entry.version}, only the hoisted one is vendored`);
+          continue;
+        }
+        seen.set(name, entry.version);
+      }
+      for (const [name, version] of seen) console.log(`${name}@${version}`);
+    ' "${tmp}/package-lock.json" "${pkg}" | while read -r dep; do
+      npm pack --silent --pack-destination "${deps}" "${dep}" > /dev/null
+    done
+    rm -rf "${tmp}"
+  done
+done <<< "${adapters}"